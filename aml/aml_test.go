@@ -0,0 +1,371 @@
+package aml_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/gocql/gocql"
+	es "github.com/novabankapp/common.data/eventstore"
+	"github.com/novabankapp/wallet.data/aml"
+	"github.com/novabankapp/wallet.data/domain"
+	"github.com/novabankapp/wallet.data/es/aggregate"
+	v1 "github.com/novabankapp/wallet.data/es/events/v1"
+	"github.com/novabankapp/wallet.data/es/readmodel/readmodeltest"
+	"github.com/pkg/errors"
+	"github.com/shopspring/decimal"
+)
+
+var (
+	start = time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	now   = time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+)
+
+func amount(value string) decimal.Decimal {
+	return decimal.RequireFromString(value)
+}
+
+// ledger builds the read model of transfers between wallets, with the debit and the
+// credit of a transfer recorded on either side under ids of their own.
+type ledger struct {
+	created map[string]time.Time
+	txs     map[string][]domain.WalletTransaction
+}
+
+func newLedger() *ledger {
+	return &ledger{created: make(map[string]time.Time), txs: make(map[string][]domain.WalletTransaction)}
+}
+
+func (l *ledger) wallet(id string, created time.Time) *ledger {
+	l.created[id] = created
+	return l
+}
+
+// transfer records a transfer and returns the id of its record on the credited wallet.
+func (l *ledger) transfer(from, to, value string, at time.Time) gocql.UUID {
+	tx := domain.WalletTransaction{DebitWalletId: from, CreditWalletId: to, Amount: amount(value), CreatedAt: at, Description: "transfer"}
+	for _, id := range []string{from, to} {
+		if _, ok := l.created[id]; !ok {
+			continue
+		}
+		tx.ID = gocql.TimeUUID()
+		l.txs[id] = append(l.txs[id], tx)
+	}
+	return tx.ID
+}
+
+func (l *ledger) readModel() *readmodeltest.ReadModel {
+	m := readmodeltest.New()
+	for id, created := range l.created {
+		m.Put(readmodeltest.Row(domain.Wallet{ID: id, UserId: "user-" + id, CreatedAt: created}, domain.WalletState{}, l.txs[id]))
+	}
+	return m
+}
+
+func newMonitor(m *readmodeltest.ReadModel) (*aml.Monitor, *aml.MemoryCaseStore) {
+	cases := aml.NewMemoryCaseStore()
+	monitor := aml.NewMonitor(aml.DefaultConfig(), m, cases)
+	monitor.Now = func() time.Time { return now }
+	return monitor, cases
+}
+
+// TestScenarios checks every scenario on wallet a with the default configuration.
+func TestScenarios(t *testing.T) {
+	tests := []struct {
+		name     string
+		build    func(l *ledger)
+		scenario aml.Scenario
+		// evidence is the number of transactions of the alert, zero when none is expected.
+		evidence int
+	}{
+		{
+			name: "structuring",
+			build: func(l *ledger) {
+				l.wallet("a", start)
+				l.transfer("x", "a", "9500", start.Add(time.Hour))
+				l.transfer("x", "a", "9200", start.Add(5*time.Hour))
+				l.transfer("a", "y", "9900", start.Add(20*time.Hour))
+				l.transfer("x", "a", "9999", start.Add(50*time.Hour))
+			},
+			scenario: aml.ScenarioStructuring, evidence: 3,
+		},
+		{
+			name: "too few under the threshold",
+			build: func(l *ledger) {
+				l.wallet("a", start)
+				l.transfer("x", "a", "9500", start.Add(time.Hour))
+				l.transfer("x", "a", "10000", start.Add(2*time.Hour))
+				l.transfer("x", "a", "8000", start.Add(3*time.Hour))
+				l.transfer("x", "a", "9500", start.Add(30*time.Hour))
+			},
+			scenario: aml.ScenarioStructuring,
+		},
+		{
+			name: "rapid movement",
+			build: func(l *ledger) {
+				l.wallet("a", start)
+				l.transfer("x", "a", "5000", start.Add(time.Hour))
+				l.transfer("a", "y", "2500", start.Add(2*time.Hour))
+				l.transfer("a", "z", "2000", start.Add(3*time.Hour))
+			},
+			scenario: aml.ScenarioRapidMovement, evidence: 3,
+		},
+		{
+			name: "paid out too slowly",
+			build: func(l *ledger) {
+				l.wallet("a", start)
+				l.transfer("x", "a", "5000", start.Add(time.Hour))
+				l.transfer("a", "y", "2500", start.Add(2*time.Hour))
+				l.transfer("a", "z", "2000", start.Add(26*time.Hour))
+			},
+			scenario: aml.ScenarioRapidMovement,
+		},
+		{
+			name: "dormant wallet",
+			build: func(l *ledger) {
+				l.wallet("a", start)
+				l.transfer("x", "a", "10", start.Add(time.Hour))
+				l.transfer("x", "a", "6000", start.Add(200*24*time.Hour))
+			},
+			scenario: aml.ScenarioDormantWallet, evidence: 2,
+		},
+		{
+			name: "never used wallet",
+			build: func(l *ledger) {
+				l.wallet("a", start)
+				l.transfer("x", "a", "6000", start.Add(190*24*time.Hour))
+			},
+			scenario: aml.ScenarioDormantWallet, evidence: 1,
+		},
+		{
+			name: "circular flow",
+			build: func(l *ledger) {
+				l.wallet("a", start).wallet("b", start).wallet("c", start)
+				l.transfer("a", "b", "2000", start.Add(time.Hour))
+				l.transfer("b", "c", "1900", start.Add(30*time.Hour))
+				l.transfer("c", "a", "1800", start.Add(60*time.Hour))
+			},
+			scenario: aml.ScenarioCircularFlow, evidence: 3,
+		},
+		{
+			name: "flow back too late",
+			build: func(l *ledger) {
+				l.wallet("a", start).wallet("b", start).wallet("c", start)
+				l.transfer("a", "b", "2000", start.Add(time.Hour))
+				l.transfer("b", "c", "1900", start.Add(30*time.Hour))
+				l.transfer("c", "a", "1800", start.Add(80*time.Hour))
+			},
+			scenario: aml.ScenarioCircularFlow,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := newLedger()
+			tt.build(l)
+			monitor, _ := newMonitor(l.readModel())
+			alerts, err := monitor.Scan(context.Background(), start, time.Time{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var found []aml.Alert
+			for _, a := range alerts {
+				if a.Scenario == tt.scenario {
+					found = append(found, a)
+				}
+			}
+			if tt.evidence == 0 {
+				if len(found) != 0 {
+					t.Fatalf("alerts %+v, want none", found)
+				}
+				return
+			}
+			if len(found) != 1 {
+				t.Fatalf("alerts %+v, want one", found)
+			}
+			a := found[0]
+			if a.WalletID != "a" || a.UserID != "user-a" || len(a.Evidence) != tt.evidence {
+				t.Fatalf("alert %+v, want it on a with %d transactions", a, tt.evidence)
+			}
+			if !a.Amount.Equal(sum(a.Evidence)) || !a.OccurredAt.Equal(a.Evidence[len(a.Evidence)-1].CreatedAt) {
+				t.Fatalf("amount %s at %s of evidence %+v", a.Amount, a.OccurredAt, a.Evidence)
+			}
+		})
+	}
+}
+
+func sum(txs []domain.WalletTransaction) decimal.Decimal {
+	result := decimal.Zero
+	for _, tx := range txs {
+		result = result.Add(tx.Amount)
+	}
+	return result
+}
+
+func TestScanFilesAlertsOnce(t *testing.T) {
+	ctx := context.Background()
+	l := newLedger().wallet("a", start).wallet("b", start)
+	l.transfer("x", "a", "5000", start.Add(time.Hour))
+	l.transfer("a", "b", "4800", start.Add(2*time.Hour))
+	l.transfer("b", "y", "4700", start.Add(3*time.Hour))
+	monitor, cases := newMonitor(l.readModel())
+
+	if alerts, err := monitor.Scan(ctx, start.Add(24*time.Hour), time.Time{}); err != nil || len(alerts) != 0 {
+		t.Fatalf("alerts before the period: %+v, %v", alerts, err)
+	}
+	for i := 0; i < 2; i++ {
+		alerts, err := monitor.Scan(ctx, start, time.Time{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(alerts) != 2 {
+			t.Fatalf("scan %d: alerts %+v, want rapid movement on a and b", i, alerts)
+		}
+	}
+	all, err := cases.ListCases(ctx, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 2 || len(all[0].AlertIDs) != 1 || len(all[1].AlertIDs) != 1 {
+		t.Fatalf("cases %+v, want one with one alert per wallet", all)
+	}
+
+	dismissed, err := cases.ActiveCase(ctx, "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := aml.NewTriage(cases).Dismiss(ctx, dismissed.ID, "analyst-1", "salary"); err != nil {
+		t.Fatal(err)
+	}
+	l.transfer("x", "a", "9500", start.Add(4*time.Hour))
+	l.transfer("x", "a", "9500", start.Add(5*time.Hour))
+	l.transfer("x", "a", "9500", start.Add(6*time.Hour))
+	monitor.ReadModel = l.readModel()
+	if _, err := monitor.Scan(ctx, start, time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+	open, err := cases.ActiveCase(ctx, "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if open.ID == dismissed.ID || len(open.AlertIDs) != 1 {
+		t.Fatalf("case %+v, want a new case for the alert after the dismissal", open)
+	}
+}
+
+func creditEvent(t *testing.T, walletID, from string, id gocql.UUID) es.Event {
+	t.Helper()
+	data, err := json.Marshal(v1.WalletCreditedEvent{Amount: amount("1800"), DebitWalletId: from})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return es.Event{EventID: id.String(), EventType: v1.WalletCredited, Data: data, AggregateID: aggregate.GetWalletStreamID(walletID)}
+}
+
+func TestObserveWaitsForTheReadModel(t *testing.T) {
+	ctx := context.Background()
+	l := newLedger().wallet("a", start).wallet("b", start).wallet("c", start)
+	l.transfer("a", "b", "2000", start.Add(time.Hour))
+	l.transfer("b", "c", "1900", start.Add(2*time.Hour))
+	monitor, cases := newMonitor(l.readModel())
+
+	pending := gocql.TimeUUID()
+	if err := monitor.Observe(ctx, creditEvent(t, "a", "c", pending)); !errors.Is(err, aml.ErrReadModelBehind) {
+		t.Fatalf("Observe = %v, want ErrReadModelBehind", err)
+	}
+
+	credit := l.transfer("c", "a", "1800", start.Add(3*time.Hour))
+	monitor.ReadModel = l.readModel()
+	if err := monitor.Observe(ctx, creditEvent(t, "a", "c", credit)); err != nil {
+		t.Fatal(err)
+	}
+	c, err := cases.ActiveCase(ctx, "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, err := cases.GetAlert(ctx, c.AlertIDs[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.Scenario != aml.ScenarioCircularFlow || len(a.Evidence) != 3 {
+		t.Fatalf("alert %+v, want the flow a, b, c, a", a)
+	}
+}
+
+func TestTriageAndReport(t *testing.T) {
+	ctx := context.Background()
+	l := newLedger().wallet("a", start)
+	l.transfer("x", "a", "9500", start.Add(time.Hour))
+	l.transfer("x", "a", "9600", start.Add(2*time.Hour))
+	l.transfer("x", "a", "9700", start.Add(3*time.Hour))
+	l.transfer("a", "y", "28000", start.Add(4*time.Hour))
+	monitor, cases := newMonitor(l.readModel())
+	if _, err := monitor.CheckWallet(ctx, "a"); err != nil {
+		t.Fatal(err)
+	}
+	c, err := cases.ActiveCase(ctx, "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(c.AlertIDs) != 4 {
+		t.Fatalf("case %+v, want structuring and rapid movement of every credit", c)
+	}
+
+	triage := aml.NewTriage(cases)
+	triage.Now = func() time.Time { return now }
+	if _, err := aml.ExportReport(ctx, cases, c.ID, now); !errors.Is(err, aml.ErrCaseNotEscalated) {
+		t.Fatalf("ExportReport = %v, want ErrCaseNotEscalated", err)
+	}
+	if _, err := triage.Assign(ctx, c.ID, "analyst-1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := triage.AddNote(ctx, c.ID, "analyst-1", "no source of funds on file"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := triage.Escalate(ctx, c.ID, "analyst-1", ""); err == nil {
+		t.Fatal("escalated without a reason")
+	}
+	escalated, err := triage.Escalate(ctx, c.ID, "analyst-1", "structured deposits paid out at once")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if escalated.Status != aml.CaseEscalated || escalated.Assignee != "analyst-1" || len(escalated.Notes) != 2 {
+		t.Fatalf("case %+v", escalated)
+	}
+	if _, err := triage.AddNote(ctx, c.ID, "analyst-2", "late"); !errors.Is(err, aml.ErrCaseClosed) {
+		t.Fatalf("AddNote = %v, want ErrCaseClosed", err)
+	}
+
+	report, err := aml.ExportReport(ctx, cases, c.ID, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Transactions) != 4 || !report.TotalAmount.Equal(amount("56800")) || len(report.Scenarios) != 2 {
+		t.Fatalf("report %+v", report)
+	}
+	if !report.From.Equal(start.Add(time.Hour)) || !report.To.Equal(start.Add(4*time.Hour)) || report.Narrative == "" {
+		t.Fatalf("report period %s to %s, narrative %q", report.From, report.To, report.Narrative)
+	}
+
+	var buf bytes.Buffer
+	if err := aml.WriteReportCSV(&buf, report); err != nil {
+		t.Fatal(err)
+	}
+	rows, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 5 || rows[1][6] != "9500" || rows[4][6] != "28000" {
+		t.Fatalf("csv %v", rows)
+	}
+	buf.Reset()
+	if err := aml.WriteReportJSON(&buf, report); err != nil {
+		t.Fatal(err)
+	}
+	var decoded aml.Report
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil || decoded.CaseID != c.ID || len(decoded.Alerts) != 4 {
+		t.Fatalf("json %s: %v", buf.String(), err)
+	}
+}
@@ -0,0 +1,212 @@
+package aml
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/novabankapp/wallet.data/domain"
+	"github.com/novabankapp/wallet.data/es/pii"
+	"github.com/pkg/errors"
+	"github.com/shopspring/decimal"
+)
+
+var (
+	ErrAlertNotFound    = errors.New("alert not found")
+	ErrCaseNotFound     = errors.New("case not found")
+	ErrCaseClosed       = errors.New("case is closed")
+	ErrCaseNotEscalated = errors.New("case is not escalated")
+)
+
+// Alert is a scenario that matched on a wallet, with the transactions that make its
+// evidence. Its id derives from the scenario, the wallet and the transaction the finding
+// hangs on, so the batch and the streaming monitor raise the same alert only once.
+type Alert struct {
+	ID       string   `json:"id"`
+	Scenario Scenario `json:"scenario"`
+	WalletID string   `json:"wallet_id"`
+	UserID   string   `json:"user_id,omitempty"`
+	Summary  string   `json:"summary"`
+	// Amount is the total of the evidence.
+	Amount     decimal.Decimal            `json:"amount"`
+	Evidence   []domain.WalletTransaction `json:"evidence"`
+	OccurredAt time.Time                  `json:"occurred_at"`
+	DetectedAt time.Time                  `json:"detected_at"`
+}
+
+func alertID(scenario Scenario, walletID string, anchor domain.WalletTransaction) string {
+	return string(scenario) + "/" + walletID + "/" + anchor.ID.String()
+}
+
+// CaseStatus is where a case is in its triage.
+type CaseStatus string
+
+const (
+	CaseOpen      CaseStatus = "open"
+	CaseInReview  CaseStatus = "in_review"
+	CaseDismissed CaseStatus = "dismissed"
+	// CaseEscalated cases are suspicious and reported.
+	CaseEscalated CaseStatus = "escalated"
+)
+
+// Active reports whether the case still takes alerts and triage.
+func (s CaseStatus) Active() bool {
+	return s == CaseOpen || s == CaseInReview
+}
+
+// Note is a remark of an analyst on a case.
+type Note struct {
+	Author string    `json:"author"`
+	Text   string    `json:"text"`
+	At     time.Time `json:"at"`
+}
+
+// Case gathers the alerts of a wallet for triage. A wallet has at most one active case;
+// alerts raised once it is closed open a new one.
+type Case struct {
+	ID       string     `json:"id"`
+	WalletID string     `json:"wallet_id"`
+	UserID   string     `json:"user_id,omitempty"`
+	Status   CaseStatus `json:"status"`
+	Assignee string     `json:"assignee,omitempty"`
+	AlertIDs []string   `json:"alert_ids"`
+	Notes    []Note     `json:"notes,omitempty"`
+	// Resolution is why the case was dismissed or escalated.
+	Resolution string    `json:"resolution,omitempty"`
+	OpenedAt   time.Time `json:"opened_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+type CaseStore interface {
+	// SaveAlert stores a, replacing an alert with the same id, and reports whether the
+	// alert is new.
+	SaveAlert(ctx context.Context, a Alert) (bool, error)
+	GetAlert(ctx context.Context, id string) (*Alert, error)
+	SaveCase(ctx context.Context, c Case) error
+	GetCase(ctx context.Context, id string) (*Case, error)
+	// ActiveCase returns the open or in review case of a wallet, or ErrCaseNotFound.
+	ActiveCase(ctx context.Context, walletID string) (*Case, error)
+	// ListCases returns the cases with the given status, or all of them when it is empty,
+	// oldest first.
+	ListCases(ctx context.Context, status CaseStatus) ([]Case, error)
+}
+
+// MemoryCaseStore is an in-memory CaseStore for tests and local runs; use
+// CassandraCaseStore to keep alerts and cases across restarts.
+type MemoryCaseStore struct {
+	mu     sync.Mutex
+	alerts map[string]Alert
+	cases  map[string]Case
+}
+
+func NewMemoryCaseStore() *MemoryCaseStore {
+	return &MemoryCaseStore{alerts: make(map[string]Alert), cases: make(map[string]Case)}
+}
+
+func (m *MemoryCaseStore) SaveAlert(ctx context.Context, a Alert) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, exists := m.alerts[a.ID]
+	m.alerts[a.ID] = a
+	return !exists, nil
+}
+
+func (m *MemoryCaseStore) GetAlert(ctx context.Context, id string) (*Alert, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	a, ok := m.alerts[id]
+	if !ok {
+		return nil, ErrAlertNotFound
+	}
+	return &a, nil
+}
+
+func (m *MemoryCaseStore) SaveCase(ctx context.Context, c Case) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	c.AlertIDs = append([]string(nil), c.AlertIDs...)
+	c.Notes = append([]Note(nil), c.Notes...)
+	m.cases[c.ID] = c
+	return nil
+}
+
+func (m *MemoryCaseStore) GetCase(ctx context.Context, id string) (*Case, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	c, ok := m.cases[id]
+	if !ok {
+		return nil, ErrCaseNotFound
+	}
+	return &c, nil
+}
+
+func (m *MemoryCaseStore) ActiveCase(ctx context.Context, walletID string) (*Case, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, c := range m.cases {
+		if c.WalletID == walletID && c.Status.Active() {
+			return &c, nil
+		}
+	}
+	return nil, ErrCaseNotFound
+}
+
+func (m *MemoryCaseStore) ListCases(ctx context.Context, status CaseStatus) ([]Case, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var result []Case
+	for _, c := range m.cases {
+		if status == "" || c.Status == status {
+			result = append(result, c)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if !result[i].OpenedAt.Equal(result[j].OpenedAt) {
+			return result[i].OpenedAt.Before(result[j].OpenedAt)
+		}
+		return result[i].ID < result[j].ID
+	})
+	return result, nil
+}
+
+// ErasePersonalData redacts the user id and the evidence descriptions of the alerts and
+// cases of the given wallets. Analyst notes and resolutions are left to the analysts.
+func (m *MemoryCaseStore) ErasePersonalData(ctx context.Context, userID string, walletIDs []string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	wallets := make(map[string]bool, len(walletIDs))
+	for _, walletID := range walletIDs {
+		wallets[walletID] = true
+	}
+	for id, a := range m.alerts {
+		if wallets[a.WalletID] {
+			m.alerts[id] = redactAlert(a)
+		}
+	}
+	for id, c := range m.cases {
+		if wallets[c.WalletID] {
+			c.UserID = redacted(c.UserID)
+			m.cases[id] = c
+		}
+	}
+	return nil
+}
+
+func redactAlert(a Alert) Alert {
+	a.UserID = redacted(a.UserID)
+	evidence := make([]domain.WalletTransaction, len(a.Evidence))
+	for i, tx := range a.Evidence {
+		tx.Description = redacted(tx.Description)
+		evidence[i] = tx
+	}
+	a.Evidence = evidence
+	return a
+}
+
+func redacted(value string) string {
+	if value == "" {
+		return value
+	}
+	return pii.Redacted
+}
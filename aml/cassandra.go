@@ -0,0 +1,207 @@
+package aml
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/gocql/gocql"
+	"github.com/pkg/errors"
+	"github.com/scylladb/gocqlx/v2"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	AlertsTable = "aml_alerts"
+	CasesTable  = "aml_cases"
+
+	alertColumns = "id, scenario, wallet_id, user_id, summary, amount, evidence, occurred_at, detected_at"
+	caseColumns  = "id, wallet_id, user_id, status, assignee, alert_ids, notes, resolution, opened_at, updated_at"
+)
+
+// CassandraCaseStore keeps alerts and cases in the tables created by the migrations
+// package. Evidence and notes are stored as JSON.
+type CassandraCaseStore struct {
+	session gocqlx.Session
+}
+
+var _ CaseStore = (*CassandraCaseStore)(nil)
+
+func NewCassandraCaseStore(session gocqlx.Session) *CassandraCaseStore {
+	return &CassandraCaseStore{session: session}
+}
+
+// SaveAlert inserts the alert with a lightweight transaction to learn whether it is new,
+// and overwrites the existing row otherwise.
+func (s *CassandraCaseStore) SaveAlert(ctx context.Context, a Alert) (bool, error) {
+	evidence, err := json.Marshal(a.Evidence)
+	if err != nil {
+		return false, errors.Wrap(err, "json.Marshal")
+	}
+	values := []interface{}{
+		a.ID, string(a.Scenario), a.WalletID, a.UserID, a.Summary, a.Amount.String(), string(evidence), a.OccurredAt, a.DetectedAt,
+	}
+	stmt := fmt.Sprintf("INSERT INTO %s (%s) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)", AlertsTable, alertColumns)
+	applied, err := s.session.Session.Query(stmt+" IF NOT EXISTS", values...).WithContext(ctx).MapScanCAS(map[string]interface{}{})
+	if err != nil {
+		return false, errors.Wrap(err, "Query.MapScanCAS")
+	}
+	if applied {
+		return true, nil
+	}
+	if err := s.session.Session.Query(stmt, values...).WithContext(ctx).Exec(); err != nil {
+		return false, errors.Wrap(err, "Query.Exec")
+	}
+	return false, nil
+}
+
+func (s *CassandraCaseStore) GetAlert(ctx context.Context, id string) (*Alert, error) {
+	stmt := fmt.Sprintf("SELECT %s FROM %s WHERE id = ?", alertColumns, AlertsTable)
+	alerts, err := scanAlerts(s.session.Session.Query(stmt, id).WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	if len(alerts) == 0 {
+		return nil, ErrAlertNotFound
+	}
+	return &alerts[0], nil
+}
+
+func (s *CassandraCaseStore) SaveCase(ctx context.Context, c Case) error {
+	notes, err := json.Marshal(c.Notes)
+	if err != nil {
+		return errors.Wrap(err, "json.Marshal")
+	}
+	stmt := fmt.Sprintf("INSERT INTO %s (%s) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)", CasesTable, caseColumns)
+	err = s.session.Session.Query(stmt,
+		c.ID, c.WalletID, c.UserID, string(c.Status), c.Assignee, c.AlertIDs, string(notes), c.Resolution, c.OpenedAt, c.UpdatedAt,
+	).WithContext(ctx).Exec()
+	if err != nil {
+		return errors.Wrap(err, "Query.Exec")
+	}
+	return nil
+}
+
+func (s *CassandraCaseStore) GetCase(ctx context.Context, id string) (*Case, error) {
+	stmt := fmt.Sprintf("SELECT %s FROM %s WHERE id = ?", caseColumns, CasesTable)
+	cases, err := scanCases(s.session.Session.Query(stmt, id).WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	if len(cases) == 0 {
+		return nil, ErrCaseNotFound
+	}
+	return &cases[0], nil
+}
+
+// ActiveCase reads the cases of the wallet through the wallet_id index.
+func (s *CassandraCaseStore) ActiveCase(ctx context.Context, walletID string) (*Case, error) {
+	stmt := fmt.Sprintf("SELECT %s FROM %s WHERE wallet_id = ?", caseColumns, CasesTable)
+	cases, err := scanCases(s.session.Session.Query(stmt, walletID).WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	for i := range cases {
+		if cases[i].Status.Active() {
+			return &cases[i], nil
+		}
+	}
+	return nil, ErrCaseNotFound
+}
+
+// ListCases reads the cases with a status through the status index.
+func (s *CassandraCaseStore) ListCases(ctx context.Context, status CaseStatus) ([]Case, error) {
+	query := s.session.Session.Query(fmt.Sprintf("SELECT %s FROM %s", caseColumns, CasesTable))
+	if status != "" {
+		query = s.session.Session.Query(fmt.Sprintf("SELECT %s FROM %s WHERE status = ?", caseColumns, CasesTable), string(status))
+	}
+	cases, err := scanCases(query.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(cases, func(i, j int) bool {
+		if !cases[i].OpenedAt.Equal(cases[j].OpenedAt) {
+			return cases[i].OpenedAt.Before(cases[j].OpenedAt)
+		}
+		return cases[i].ID < cases[j].ID
+	})
+	return cases, nil
+}
+
+// ErasePersonalData redacts the user id and the evidence descriptions of the alerts and
+// cases of the given wallets, read through the wallet_id indexes.
+func (s *CassandraCaseStore) ErasePersonalData(ctx context.Context, userID string, walletIDs []string) error {
+	for _, walletID := range walletIDs {
+		stmt := fmt.Sprintf("SELECT %s FROM %s WHERE wallet_id = ?", alertColumns, AlertsTable)
+		alerts, err := scanAlerts(s.session.Session.Query(stmt, walletID).WithContext(ctx))
+		if err != nil {
+			return err
+		}
+		for _, a := range alerts {
+			if _, err := s.SaveAlert(ctx, redactAlert(a)); err != nil {
+				return err
+			}
+		}
+
+		stmt = fmt.Sprintf("SELECT %s FROM %s WHERE wallet_id = ?", caseColumns, CasesTable)
+		cases, err := scanCases(s.session.Session.Query(stmt, walletID).WithContext(ctx))
+		if err != nil {
+			return err
+		}
+		for _, c := range cases {
+			c.UserID = redacted(c.UserID)
+			if err := s.SaveCase(ctx, c); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func scanAlerts(query *gocql.Query) ([]Alert, error) {
+	iter := query.Iter()
+	var result []Alert
+	var a Alert
+	var scenario, amount, evidence string
+	for iter.Scan(&a.ID, &scenario, &a.WalletID, &a.UserID, &a.Summary, &amount, &evidence, &a.OccurredAt, &a.DetectedAt) {
+		a.Scenario = Scenario(scenario)
+		var err error
+		if a.Amount, err = decimal.NewFromString(amount); err != nil {
+			_ = iter.Close()
+			return nil, errors.Wrapf(err, "alert %s amount", a.ID)
+		}
+		if err := json.Unmarshal([]byte(evidence), &a.Evidence); err != nil {
+			_ = iter.Close()
+			return nil, errors.Wrapf(err, "alert %s evidence", a.ID)
+		}
+		result = append(result, a)
+		a = Alert{}
+	}
+	if err := iter.Close(); err != nil {
+		return nil, errors.Wrap(err, "Iter.Close")
+	}
+	return result, nil
+}
+
+func scanCases(query *gocql.Query) ([]Case, error) {
+	iter := query.Iter()
+	var result []Case
+	var c Case
+	var status, notes string
+	for iter.Scan(&c.ID, &c.WalletID, &c.UserID, &status, &c.Assignee, &c.AlertIDs, &notes, &c.Resolution, &c.OpenedAt, &c.UpdatedAt) {
+		c.Status = CaseStatus(status)
+		if notes != "" {
+			if err := json.Unmarshal([]byte(notes), &c.Notes); err != nil {
+				_ = iter.Close()
+				return nil, errors.Wrapf(err, "case %s notes", c.ID)
+			}
+		}
+		result = append(result, c)
+		c = Case{}
+	}
+	if err := iter.Close(); err != nil {
+		return nil, errors.Wrap(err, "Iter.Close")
+	}
+	return result, nil
+}
@@ -0,0 +1,354 @@
+package aml
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/EventStore/EventStore-Client-Go/esdb"
+	"github.com/google/uuid"
+	es "github.com/novabankapp/common.data/eventstore"
+	"github.com/novabankapp/common.data/logger"
+	"github.com/novabankapp/wallet.data/constants"
+	"github.com/novabankapp/wallet.data/domain"
+	"github.com/novabankapp/wallet.data/es/aggregate"
+	v1 "github.com/novabankapp/wallet.data/es/events/v1"
+	"github.com/novabankapp/wallet.data/es/models"
+	"github.com/novabankapp/wallet.data/es/queries"
+	"github.com/novabankapp/wallet.data/es/store"
+	"github.com/novabankapp/wallet.data/tracing"
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+const ProjectionName = "(AML Monitor)"
+
+// ErrReadModelBehind is returned while the read model has not projected the transaction
+// of an event yet; the event is retried until it has.
+var ErrReadModelBehind = errors.New("read model has not projected the transaction yet")
+
+// ReadModel is the part of the Cassandra read model the monitor reads.
+type ReadModel interface {
+	queries.WalletReadModel
+	List(ctx context.Context) ([]models.WalletProjection, error)
+}
+
+// Monitor runs the scenarios over the transactions of the read model, files the alerts
+// and opens cases for them. Scan monitors in batches, Run as the events come in; both
+// raise an alert once, however often they see it.
+type Monitor struct {
+	Config    Config
+	ReadModel ReadModel
+	Cases     CaseStore
+	GroupName string
+	Log       logger.Logger
+	Now       func() time.Time
+}
+
+func NewMonitor(config Config, readModel ReadModel, cases CaseStore) *Monitor {
+	return &Monitor{Config: config, ReadModel: readModel, Cases: cases, Now: time.Now}
+}
+
+// Scan runs the scenarios over every wallet of the read model and files the alerts whose
+// last transaction is within [from, to). A zero to leaves the period open.
+func (m *Monitor) Scan(ctx context.Context, from, to time.Time) ([]Alert, error) {
+	ctx, span := tracing.StartSpan(ctx, "AMLMonitor.Scan")
+	defer span.End()
+
+	rows, err := m.ReadModel.List(ctx)
+	if err != nil {
+		tracing.TraceErr(span, err)
+		return nil, errors.Wrap(err, "List")
+	}
+	l := m.newLedger()
+	for _, row := range rows {
+		view, err := newWalletView(row)
+		if err != nil {
+			tracing.TraceErr(span, err)
+			return nil, err
+		}
+		l.wallets[view.ID] = view
+	}
+	l.complete = true
+
+	alerts := make([]Alert, 0)
+	for _, row := range rows {
+		found, err := m.check(ctx, l, l.wallets[row.WalletID])
+		if err != nil {
+			tracing.TraceErr(span, err)
+			return nil, err
+		}
+		for _, a := range found {
+			if a.OccurredAt.Before(from) || (!to.IsZero() && !a.OccurredAt.Before(to)) {
+				continue
+			}
+			if err := m.file(ctx, a); err != nil {
+				tracing.TraceErr(span, err)
+				return nil, err
+			}
+			alerts = append(alerts, a)
+		}
+	}
+	span.SetAttributes(attribute.Int("alerts", len(alerts)))
+	return alerts, nil
+}
+
+// CheckWallet runs the scenarios over the transactions of one wallet and files the alerts.
+func (m *Monitor) CheckWallet(ctx context.Context, walletID string) ([]Alert, error) {
+	ctx, span := tracing.StartSpan(ctx, "AMLMonitor.CheckWallet")
+	defer span.End()
+	span.SetAttributes(attribute.String(constants.WalletID, walletID))
+
+	l := m.newLedger()
+	view, err := l.wallet(ctx, walletID)
+	if err != nil {
+		tracing.TraceErr(span, err)
+		return nil, err
+	}
+	alerts, err := m.check(ctx, l, view)
+	if err != nil {
+		tracing.TraceErr(span, err)
+		return nil, err
+	}
+	for _, a := range alerts {
+		if err := m.file(ctx, a); err != nil {
+			tracing.TraceErr(span, err)
+			return nil, err
+		}
+	}
+	return alerts, nil
+}
+
+// Run monitors the events of a persistent subscription. An event is acked once the
+// wallets it moved money between are checked; until then it is retried by the group.
+func (m *Monitor) Run(ctx context.Context, stream store.PersistentSubscription, workerID int) error {
+	for {
+		event := stream.Recv()
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+
+		if event.SubscriptionDropped != nil {
+			m.Log.Errorf("(SubscriptionDropped) err: {%v}", event.SubscriptionDropped.Error)
+			return errors.Wrap(event.SubscriptionDropped.Error, "Subscription Dropped")
+		}
+
+		if event.EventAppeared != nil {
+			m.Log.ProjectionEvent(ProjectionName, m.GroupName, event.EventAppeared, workerID)
+
+			if err := m.Observe(ctx, es.NewEventFromRecorded(event.EventAppeared.Event)); err != nil {
+				m.Log.Errorf("(Monitor.Observe) err: {%v}", err)
+				if err := stream.Nack(err.Error(), esdb.Nack_Retry, event.EventAppeared); err != nil {
+					m.Log.Errorf("(stream.Nack) err: {%v}", err)
+					return errors.Wrap(err, "stream.Nack")
+				}
+				continue
+			}
+
+			if err := stream.Ack(event.EventAppeared); err != nil {
+				m.Log.Errorf("(stream.Ack) err: {%v}", err)
+				return errors.Wrap(err, "stream.Ack")
+			}
+		}
+	}
+}
+
+// Observe checks the wallet of a debit or credit event and its counterparty, as soon as
+// the read model has the transaction of the event. Checking the counterparty finds the
+// flows that come back to it, whichever side of the last transfer is projected first.
+// Other events are ignored.
+func (m *Monitor) Observe(ctx context.Context, evt es.Event) error {
+	ctx, span := tracing.StartEventSpan(ctx, "AMLMonitor.Observe", evt)
+	defer span.End()
+
+	if !strings.HasPrefix(evt.GetAggregateID(), aggregate.GetWalletStreamID("")) {
+		return nil
+	}
+	counterparty, ok, err := eventCounterparty(evt)
+	if err != nil {
+		tracing.TraceErr(span, err)
+		return err
+	}
+	if !ok {
+		return nil
+	}
+	walletID := aggregate.GetWalletAggregateID(evt.GetAggregateID())
+
+	l := m.newLedger()
+	view, err := l.wallet(ctx, walletID)
+	if err != nil {
+		tracing.TraceErr(span, err)
+		return err
+	}
+	if !view.has(aggregate.GetTransactionID(evt).String()) {
+		tracing.TraceErr(span, ErrReadModelBehind)
+		return errors.Wrapf(ErrReadModelBehind, "event %s", evt.GetEventID())
+	}
+
+	for _, id := range []string{walletID, counterparty} {
+		if id == "" {
+			continue
+		}
+		if _, err := m.CheckWallet(ctx, id); err != nil {
+			tracing.TraceErr(span, err)
+			return err
+		}
+	}
+	return nil
+}
+
+// check runs the scenarios over a wallet.
+func (m *Monitor) check(ctx context.Context, l *ledger, w *walletView) ([]Alert, error) {
+	var findings []finding
+	findings = append(findings, detectStructuring(m.Config.Structuring, w)...)
+	findings = append(findings, detectRapidMovement(m.Config.RapidMovement, w)...)
+	findings = append(findings, detectDormantWallet(m.Config.DormantWallet, w)...)
+	circular, err := detectCircularFlow(m.Config.CircularFlow, w, func(walletID string) ([]domain.WalletTransaction, error) {
+		view, err := l.wallet(ctx, walletID)
+		if err != nil {
+			return nil, err
+		}
+		return view.debits(), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	findings = append(findings, circular...)
+
+	now := m.now()
+	alerts := make([]Alert, 0, len(findings))
+	for _, f := range findings {
+		alerts = append(alerts, Alert{
+			ID:         alertID(f.Scenario, w.ID, f.Anchor),
+			Scenario:   f.Scenario,
+			WalletID:   w.ID,
+			UserID:     w.UserID,
+			Summary:    f.Summary,
+			Amount:     total(f.Evidence),
+			Evidence:   f.Evidence,
+			OccurredAt: f.Evidence[len(f.Evidence)-1].CreatedAt,
+			DetectedAt: now,
+		})
+	}
+	return alerts, nil
+}
+
+// file stores an alert and adds it to the active case of its wallet, opening one when
+// there is none. Alerts filed before only get their evidence updated.
+func (m *Monitor) file(ctx context.Context, a Alert) error {
+	if previous, err := m.Cases.GetAlert(ctx, a.ID); err == nil {
+		a.DetectedAt = previous.DetectedAt
+	} else if !errors.Is(err, ErrAlertNotFound) {
+		return errors.Wrap(err, "GetAlert")
+	}
+	created, err := m.Cases.SaveAlert(ctx, a)
+	if err != nil {
+		return errors.Wrap(err, "SaveAlert")
+	}
+	if !created {
+		return nil
+	}
+
+	c, err := m.Cases.ActiveCase(ctx, a.WalletID)
+	if errors.Is(err, ErrCaseNotFound) {
+		c = &Case{ID: uuid.New().String(), WalletID: a.WalletID, UserID: a.UserID, Status: CaseOpen, OpenedAt: a.DetectedAt}
+	} else if err != nil {
+		return errors.Wrap(err, "ActiveCase")
+	}
+	c.AlertIDs = append(c.AlertIDs, a.ID)
+	c.UpdatedAt = a.DetectedAt
+	if err := m.Cases.SaveCase(ctx, *c); err != nil {
+		return errors.Wrap(err, "SaveCase")
+	}
+	return nil
+}
+
+func (m *Monitor) now() time.Time {
+	if m.Now == nil {
+		return time.Now()
+	}
+	return m.Now()
+}
+
+// ledger caches the wallets of the read model for one check. When complete it holds the
+// whole read model, and wallets it does not know have no transactions.
+type ledger struct {
+	queries  *queries.WalletTransactionQueries
+	wallets  map[string]*walletView
+	complete bool
+}
+
+func (m *Monitor) newLedger() *ledger {
+	return &ledger{queries: queries.NewWalletTransactionQueries(m.ReadModel), wallets: make(map[string]*walletView)}
+}
+
+// wallet returns a wallet of the read model. A wallet it does not have, such as an
+// external account, is returned without transactions.
+func (l *ledger) wallet(ctx context.Context, walletID string) (*walletView, error) {
+	if view, ok := l.wallets[walletID]; ok {
+		return view, nil
+	}
+	view := &walletView{ID: walletID}
+	if !l.complete {
+		row, err := l.queries.GetWalletProjection(ctx, walletID)
+		if err != nil && !errors.Is(err, queries.ErrWalletNotFound) {
+			return nil, err
+		}
+		if row != nil {
+			if view, err = newWalletView(*row); err != nil {
+				return nil, err
+			}
+		}
+	}
+	l.wallets[walletID] = view
+	return view, nil
+}
+
+func newWalletView(row models.WalletProjection) (*walletView, error) {
+	view := &walletView{ID: row.WalletID, UserID: row.UserID}
+	if row.Wallet != "" {
+		wallet, err := aggregate.GetEntityFromJsonString[domain.Wallet](row.Wallet)
+		if err != nil {
+			return nil, errors.Wrapf(err, "wallet %s", row.WalletID)
+		}
+		view.CreatedAt = wallet.CreatedAt
+	}
+	if row.WalletTransactions != "" {
+		txs, err := aggregate.GetEntityArrayFromJsonString[domain.WalletTransaction](row.WalletTransactions)
+		if err != nil {
+			return nil, errors.Wrapf(err, "transactions of wallet %s", row.WalletID)
+		}
+		view.Transactions = *txs
+	}
+	sortTransactions(view.Transactions)
+	return view, nil
+}
+
+func (w *walletView) has(transactionID string) bool {
+	for _, tx := range w.Transactions {
+		if tx.ID.String() == transactionID {
+			return true
+		}
+	}
+	return false
+}
+
+func eventCounterparty(evt es.Event) (string, bool, error) {
+	switch evt.GetEventType() {
+	case v1.WalletDebited:
+		var data v1.WalletDebitedEvent
+		if err := evt.GetJsonData(&data); err != nil {
+			return "", false, errors.Wrap(err, "GetJsonData")
+		}
+		return data.CreditWalletId, true, nil
+	case v1.WalletCredited:
+		var data v1.WalletCreditedEvent
+		if err := evt.GetJsonData(&data); err != nil {
+			return "", false, errors.Wrap(err, "GetJsonData")
+		}
+		return data.DebitWalletId, true, nil
+	}
+	return "", false, nil
+}
@@ -0,0 +1,134 @@
+package aml
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/novabankapp/wallet.data/domain"
+	"github.com/pkg/errors"
+	"github.com/shopspring/decimal"
+)
+
+// Report is a suspicious activity report of an escalated case: the subject, what was
+// found, the transactions involved and the analysts' account of it.
+type Report struct {
+	CaseID      string    `json:"case_id"`
+	GeneratedAt time.Time `json:"generated_at"`
+	WalletID    string    `json:"wallet_id"`
+	UserID      string    `json:"user_id,omitempty"`
+	// From and To span the transactions involved.
+	From         time.Time                  `json:"from"`
+	To           time.Time                  `json:"to"`
+	Scenarios    []Scenario                 `json:"scenarios"`
+	TotalAmount  decimal.Decimal            `json:"total_amount"`
+	Narrative    string                     `json:"narrative"`
+	Resolution   string                     `json:"resolution"`
+	Assignee     string                     `json:"assignee,omitempty"`
+	Alerts       []Alert                    `json:"alerts"`
+	Transactions []domain.WalletTransaction `json:"transactions"`
+	Notes        []Note                     `json:"notes,omitempty"`
+}
+
+// ExportReport builds the report of an escalated case.
+func ExportReport(ctx context.Context, cases CaseStore, caseID string, generatedAt time.Time) (*Report, error) {
+	c, err := cases.GetCase(ctx, caseID)
+	if err != nil {
+		return nil, err
+	}
+	if c.Status != CaseEscalated {
+		return nil, errors.Wrapf(ErrCaseNotEscalated, "case %s is %s", caseID, c.Status)
+	}
+
+	report := &Report{
+		CaseID:       c.ID,
+		GeneratedAt:  generatedAt,
+		WalletID:     c.WalletID,
+		UserID:       c.UserID,
+		Resolution:   c.Resolution,
+		Assignee:     c.Assignee,
+		Scenarios:    make([]Scenario, 0),
+		Alerts:       make([]Alert, 0, len(c.AlertIDs)),
+		Transactions: make([]domain.WalletTransaction, 0),
+		Notes:        c.Notes,
+		TotalAmount:  decimal.Zero,
+	}
+	seenScenarios := make(map[Scenario]bool)
+	seenTransactions := make(map[string]bool)
+	var narrative []string
+	for _, id := range c.AlertIDs {
+		a, err := cases.GetAlert(ctx, id)
+		if err != nil {
+			return nil, errors.Wrapf(err, "alert %s", id)
+		}
+		report.Alerts = append(report.Alerts, *a)
+		narrative = append(narrative, fmt.Sprintf("%s: %s.", a.Scenario, a.Summary))
+		if !seenScenarios[a.Scenario] {
+			seenScenarios[a.Scenario] = true
+			report.Scenarios = append(report.Scenarios, a.Scenario)
+		}
+		for _, tx := range a.Evidence {
+			if seenTransactions[tx.ID.String()] {
+				continue
+			}
+			seenTransactions[tx.ID.String()] = true
+			report.Transactions = append(report.Transactions, tx)
+			report.TotalAmount = report.TotalAmount.Add(tx.Amount)
+		}
+	}
+	sortTransactions(report.Transactions)
+	if n := len(report.Transactions); n > 0 {
+		report.From, report.To = report.Transactions[0].CreatedAt, report.Transactions[n-1].CreatedAt
+	}
+	report.Narrative = strings.Join(narrative, " ")
+	return report, nil
+}
+
+// WriteReportJSON writes the report as indented JSON.
+func WriteReportJSON(w io.Writer, r *Report) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(r)
+}
+
+var reportCSVHeader = []string{"case_id", "wallet_id", "transaction_id", "date", "debit_wallet_id", "credit_wallet_id", "amount", "description", "alerts"}
+
+// WriteReportCSV writes the transactions of the report, one row each, with the alerts
+// they are evidence of.
+func WriteReportCSV(w io.Writer, r *Report) error {
+	alerts := make(map[string][]string)
+	for _, a := range r.Alerts {
+		for _, tx := range a.Evidence {
+			alerts[tx.ID.String()] = append(alerts[tx.ID.String()], a.ID)
+		}
+	}
+	writer := csv.NewWriter(w)
+	if err := writer.Write(reportCSVHeader); err != nil {
+		return err
+	}
+	for _, tx := range r.Transactions {
+		ids := alerts[tx.ID.String()]
+		sort.Strings(ids)
+		row := []string{
+			r.CaseID,
+			r.WalletID,
+			tx.ID.String(),
+			tx.CreatedAt.UTC().Format(time.RFC3339),
+			tx.DebitWalletId,
+			tx.CreditWalletId,
+			tx.Amount.String(),
+			tx.Description,
+			strings.Join(ids, " "),
+		}
+		if err := writer.Write(row); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
@@ -0,0 +1,316 @@
+// Package aml monitors wallet transactions for money laundering after the fact. It reads
+// the transactions of the Cassandra read model, in batches over a period or as the events
+// stream in, raises alerts with the transactions that make their evidence, groups them
+// into cases for compliance to triage, and exports escalated cases as suspicious activity
+// reports.
+package aml
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/novabankapp/wallet.data/domain"
+	"github.com/shopspring/decimal"
+)
+
+// Scenario names a money laundering pattern.
+type Scenario string
+
+const (
+	// ScenarioStructuring is many transactions just under the reporting threshold.
+	ScenarioStructuring Scenario = "structuring"
+	// ScenarioRapidMovement is a credit mostly paid out again shortly after.
+	ScenarioRapidMovement Scenario = "rapid_movement"
+	// ScenarioDormantWallet is a large credit to a wallet that was idle for long.
+	ScenarioDormantWallet Scenario = "dormant_wallet"
+	// ScenarioCircularFlow is money that comes back to the wallet it left through other
+	// wallets.
+	ScenarioCircularFlow Scenario = "circular_flow"
+)
+
+// Structuring flags MinCount or more transactions of a wallet within Window whose amounts
+// are under Threshold by at most Margin, a fraction of the threshold. A zero Threshold
+// disables it.
+type Structuring struct {
+	Threshold decimal.Decimal
+	Margin    decimal.Decimal
+	MinCount  int
+	Window    time.Duration
+}
+
+// RapidMovement flags a credit of at least MinAmount of which Ratio or more is debited
+// within Window. A zero Window disables it.
+type RapidMovement struct {
+	MinAmount decimal.Decimal
+	Ratio     decimal.Decimal
+	Window    time.Duration
+}
+
+// DormantWallet flags a credit of at least MinAmount to a wallet without transactions for
+// Idle or longer, counting from its creation when it never had any. A zero Idle disables
+// it.
+type DormantWallet struct {
+	MinAmount decimal.Decimal
+	Idle      time.Duration
+}
+
+// CircularFlow flags transfers of at least MinAmount each that leave a wallet and come
+// back to it through at most MaxHops transfers, each after the one before, within Window.
+// A zero MaxHops disables it.
+type CircularFlow struct {
+	MinAmount decimal.Decimal
+	MaxHops   int
+	Window    time.Duration
+}
+
+// Config holds the parameters of the scenarios.
+type Config struct {
+	Structuring   Structuring
+	RapidMovement RapidMovement
+	DormantWallet DormantWallet
+	CircularFlow  CircularFlow
+}
+
+// DefaultConfig returns parameters in line with a reporting threshold of 10000.
+func DefaultConfig() Config {
+	return Config{
+		Structuring: Structuring{
+			Threshold: decimal.NewFromInt(10000),
+			Margin:    decimal.RequireFromString("0.1"),
+			MinCount:  3,
+			Window:    24 * time.Hour,
+		},
+		RapidMovement: RapidMovement{
+			MinAmount: decimal.NewFromInt(1000),
+			Ratio:     decimal.RequireFromString("0.9"),
+			Window:    24 * time.Hour,
+		},
+		DormantWallet: DormantWallet{
+			MinAmount: decimal.NewFromInt(5000),
+			Idle:      180 * 24 * time.Hour,
+		},
+		CircularFlow: CircularFlow{
+			MinAmount: decimal.NewFromInt(1000),
+			MaxHops:   4,
+			Window:    72 * time.Hour,
+		},
+	}
+}
+
+// walletView is a wallet of the read model with its transactions oldest first.
+type walletView struct {
+	ID           string
+	UserID       string
+	CreatedAt    time.Time
+	Transactions []domain.WalletTransaction
+}
+
+func (w *walletView) isDebit(tx domain.WalletTransaction) bool {
+	return tx.DebitWalletId == w.ID
+}
+
+// debits returns the transfers out of the wallet, oldest first.
+func (w *walletView) debits() []domain.WalletTransaction {
+	var result []domain.WalletTransaction
+	for _, tx := range w.Transactions {
+		if w.isDebit(tx) {
+			result = append(result, tx)
+		}
+	}
+	return result
+}
+
+func sortTransactions(txs []domain.WalletTransaction) {
+	sort.SliceStable(txs, func(i, j int) bool {
+		return txs[i].CreatedAt.Before(txs[j].CreatedAt)
+	})
+}
+
+// finding is what a scenario found on a wallet. Anchor is the transaction the finding
+// hangs on, which keeps its alert id when later transactions add to the evidence.
+type finding struct {
+	Scenario Scenario
+	Anchor   domain.WalletTransaction
+	Summary  string
+	Evidence []domain.WalletTransaction
+}
+
+func detectStructuring(c Structuring, w *walletView) []finding {
+	if !c.Threshold.IsPositive() || c.MinCount < 1 {
+		return nil
+	}
+	floor := c.Threshold.Sub(c.Threshold.Mul(c.Margin))
+	var near []domain.WalletTransaction
+	for _, tx := range w.Transactions {
+		if tx.Amount.GreaterThanOrEqual(floor) && tx.Amount.LessThan(c.Threshold) {
+			near = append(near, tx)
+		}
+	}
+	var findings []finding
+	for i := 0; i < len(near); {
+		end := i
+		for end < len(near) && near[end].CreatedAt.Sub(near[i].CreatedAt) < c.Window {
+			end++
+		}
+		if end-i < c.MinCount {
+			i++
+			continue
+		}
+		evidence := append([]domain.WalletTransaction(nil), near[i:end]...)
+		findings = append(findings, finding{
+			Scenario: ScenarioStructuring,
+			Anchor:   near[i],
+			Summary: fmt.Sprintf("%d transactions totalling %s within %s, each under the threshold of %s by at most %s",
+				len(evidence), total(evidence), c.Window, c.Threshold, c.Threshold.Sub(floor)),
+			Evidence: evidence,
+		})
+		i = end
+	}
+	return findings
+}
+
+func detectRapidMovement(c RapidMovement, w *walletView) []finding {
+	if c.Window <= 0 {
+		return nil
+	}
+	var findings []finding
+	for i, credit := range w.Transactions {
+		if w.isDebit(credit) || credit.Amount.LessThan(c.MinAmount) || !credit.Amount.IsPositive() {
+			continue
+		}
+		target := credit.Amount.Mul(c.Ratio)
+		out := decimal.Zero
+		evidence := []domain.WalletTransaction{credit}
+		for _, tx := range w.Transactions[i+1:] {
+			if tx.CreatedAt.Sub(credit.CreatedAt) >= c.Window {
+				break
+			}
+			if !w.isDebit(tx) {
+				continue
+			}
+			out = out.Add(tx.Amount)
+			evidence = append(evidence, tx)
+			if out.GreaterThanOrEqual(target) {
+				break
+			}
+		}
+		if out.LessThan(target) {
+			continue
+		}
+		findings = append(findings, finding{
+			Scenario: ScenarioRapidMovement,
+			Anchor:   credit,
+			Summary: fmt.Sprintf("%s of a credit of %s debited again within %s",
+				out, credit.Amount, evidence[len(evidence)-1].CreatedAt.Sub(credit.CreatedAt)),
+			Evidence: evidence,
+		})
+	}
+	return findings
+}
+
+func detectDormantWallet(c DormantWallet, w *walletView) []finding {
+	if c.Idle <= 0 {
+		return nil
+	}
+	var findings []finding
+	lastActive := w.CreatedAt
+	var previous *domain.WalletTransaction
+	for i, tx := range w.Transactions {
+		if !w.isDebit(tx) && tx.Amount.GreaterThanOrEqual(c.MinAmount) && !lastActive.IsZero() && tx.CreatedAt.Sub(lastActive) >= c.Idle {
+			evidence := []domain.WalletTransaction{tx}
+			since := "its creation"
+			if previous != nil {
+				evidence = []domain.WalletTransaction{*previous, tx}
+				since = "its last transaction"
+			}
+			findings = append(findings, finding{
+				Scenario: ScenarioDormantWallet,
+				Anchor:   tx,
+				Summary:  fmt.Sprintf("credit of %s after %s without transactions since %s", tx.Amount, tx.CreatedAt.Sub(lastActive), since),
+				Evidence: evidence,
+			})
+		}
+		lastActive = tx.CreatedAt
+		previous = &w.Transactions[i]
+	}
+	return findings
+}
+
+// outgoing returns the transfers out of a wallet, oldest first.
+type outgoing func(walletID string) ([]domain.WalletTransaction, error)
+
+// detectCircularFlow follows every transfer out of the wallet through later transfers
+// until it comes back, reporting the shortest way back found first for each.
+func detectCircularFlow(c CircularFlow, w *walletView, out outgoing) ([]finding, error) {
+	if c.MaxHops < 2 {
+		return nil, nil
+	}
+	var findings []finding
+	for _, first := range w.debits() {
+		if first.Amount.LessThan(c.MinAmount) || first.CreditWalletId == w.ID {
+			continue
+		}
+		path, err := findCycle(c, w.ID, first, out)
+		if err != nil {
+			return nil, err
+		}
+		if path == nil {
+			continue
+		}
+		wallets := []string{w.ID}
+		for _, tx := range path {
+			wallets = append(wallets, tx.CreditWalletId)
+		}
+		findings = append(findings, finding{
+			Scenario: ScenarioCircularFlow,
+			Anchor:   first,
+			Summary: fmt.Sprintf("%s came back within %s through %v",
+				first.Amount, path[len(path)-1].CreatedAt.Sub(first.CreatedAt), wallets),
+			Evidence: path,
+		})
+	}
+	return findings, nil
+}
+
+// findCycle searches breadth first for the shortest chain of transfers from first back to
+// origin, visiting every wallet once.
+func findCycle(c CircularFlow, origin string, first domain.WalletTransaction, out outgoing) ([]domain.WalletTransaction, error) {
+	deadline := first.CreatedAt.Add(c.Window)
+	frontier := [][]domain.WalletTransaction{{first}}
+	visited := map[string]bool{origin: true, first.CreditWalletId: true}
+	for hops := 2; hops <= c.MaxHops && len(frontier) > 0; hops++ {
+		var next [][]domain.WalletTransaction
+		for _, path := range frontier {
+			last := path[len(path)-1]
+			txs, err := out(last.CreditWalletId)
+			if err != nil {
+				return nil, err
+			}
+			for _, tx := range txs {
+				if !tx.CreatedAt.After(last.CreatedAt) || tx.CreatedAt.After(deadline) || tx.Amount.LessThan(c.MinAmount) {
+					continue
+				}
+				extended := append(append([]domain.WalletTransaction(nil), path...), tx)
+				if tx.CreditWalletId == origin {
+					return extended, nil
+				}
+				if visited[tx.CreditWalletId] {
+					continue
+				}
+				visited[tx.CreditWalletId] = true
+				next = append(next, extended)
+			}
+		}
+		frontier = next
+	}
+	return nil, nil
+}
+
+func total(txs []domain.WalletTransaction) decimal.Decimal {
+	sum := decimal.Zero
+	for _, tx := range txs {
+		sum = sum.Add(tx.Amount)
+	}
+	return sum
+}
@@ -0,0 +1,79 @@
+package aml
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Triage moves cases through their review. Closed cases, dismissed or escalated, no
+// longer change.
+type Triage struct {
+	Cases CaseStore
+	Now   func() time.Time
+}
+
+func NewTriage(cases CaseStore) *Triage {
+	return &Triage{Cases: cases, Now: time.Now}
+}
+
+// Assign hands a case to an analyst and puts it in review.
+func (t *Triage) Assign(ctx context.Context, caseID, assignee string) (*Case, error) {
+	return t.update(ctx, caseID, func(c *Case, at time.Time) {
+		c.Assignee = assignee
+		c.Status = CaseInReview
+	})
+}
+
+// AddNote records a remark of an analyst on a case.
+func (t *Triage) AddNote(ctx context.Context, caseID, author, text string) (*Case, error) {
+	return t.update(ctx, caseID, func(c *Case, at time.Time) {
+		c.Notes = append(c.Notes, Note{Author: author, Text: text, At: at})
+	})
+}
+
+// Dismiss closes a case as not suspicious.
+func (t *Triage) Dismiss(ctx context.Context, caseID, author, reason string) (*Case, error) {
+	return t.close(ctx, caseID, CaseDismissed, author, reason)
+}
+
+// Escalate closes a case as suspicious, to be reported with ExportReport.
+func (t *Triage) Escalate(ctx context.Context, caseID, author, reason string) (*Case, error) {
+	return t.close(ctx, caseID, CaseEscalated, author, reason)
+}
+
+func (t *Triage) close(ctx context.Context, caseID string, status CaseStatus, author, reason string) (*Case, error) {
+	if reason == "" {
+		return nil, errors.Errorf("closing case %s as %s needs a reason", caseID, status)
+	}
+	return t.update(ctx, caseID, func(c *Case, at time.Time) {
+		c.Status = status
+		c.Resolution = reason
+		c.Notes = append(c.Notes, Note{Author: author, Text: string(status) + ": " + reason, At: at})
+	})
+}
+
+func (t *Triage) update(ctx context.Context, caseID string, change func(c *Case, at time.Time)) (*Case, error) {
+	c, err := t.Cases.GetCase(ctx, caseID)
+	if err != nil {
+		return nil, err
+	}
+	if !c.Status.Active() {
+		return nil, errors.Wrapf(ErrCaseClosed, "case %s is %s", caseID, c.Status)
+	}
+	at := t.now()
+	change(c, at)
+	c.UpdatedAt = at
+	if err := t.Cases.SaveCase(ctx, *c); err != nil {
+		return nil, errors.Wrap(err, "SaveCase")
+	}
+	return c, nil
+}
+
+func (t *Triage) now() time.Time {
+	if t.Now == nil {
+		return time.Now()
+	}
+	return t.Now()
+}
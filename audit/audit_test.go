@@ -0,0 +1,160 @@
+package audit_test
+
+import (
+	"context"
+	"testing"
+
+	es "github.com/novabankapp/common.data/eventstore"
+	"github.com/novabankapp/wallet.data/audit"
+	"github.com/novabankapp/wallet.data/es/aggregate"
+	"github.com/novabankapp/wallet.data/es/commands"
+	v1 "github.com/novabankapp/wallet.data/es/events/v1"
+	"github.com/novabankapp/wallet.data/es/metadata"
+	"github.com/novabankapp/wallet.data/es/readmodel/readmodeltest"
+	"github.com/novabankapp/wallet.data/es/store"
+	"github.com/pkg/errors"
+	"github.com/shopspring/decimal"
+)
+
+type fixture struct {
+	db      *store.MemoryEventStore
+	bus     *commands.Bus
+	store   *audit.MemoryStore
+	queries *audit.Queries
+}
+
+func newFixture(t *testing.T) *fixture {
+	t.Helper()
+	db := store.NewMemoryEventStore()
+	bus := commands.NewBus(commands.Causation())
+	commands.RegisterWalletHandlers(bus, aggregate.NewWalletCommandExecutor(db))
+	entries := audit.NewMemoryStore()
+	return &fixture{db: db, bus: bus, store: entries, queries: audit.NewQueries(entries)}
+}
+
+func (f *fixture) dispatch(t *testing.T, ctx context.Context, cmd commands.Command) {
+	t.Helper()
+	if _, err := f.bus.Dispatch(ctx, cmd); err != nil {
+		t.Fatalf("%s: %v", cmd.CommandName(), err)
+	}
+}
+
+// project records every event written so far, twice to show redeliveries are harmless.
+func (f *fixture) project(t *testing.T) []es.Event {
+	t.Helper()
+	events, err := f.db.ReadAll(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	projection := audit.NewProjection(f.store, "audit", readmodeltest.NopLogger{})
+	for i := 0; i < 2; i++ {
+		for _, evt := range events {
+			if err := projection.Record(context.Background(), evt); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	return events
+}
+
+func operator(id string) context.Context {
+	return metadata.WithMetadata(context.Background(), metadata.Metadata{
+		ActorID: id, ActorType: metadata.ActorOperator, Channel: metadata.ChannelCLI, ClientIP: "10.0.0.7",
+	})
+}
+
+func TestEveryEventCarriesTheCommandMetadata(t *testing.T) {
+	f := newFixture(t)
+	f.dispatch(t, operator("op-1"), commands.CreateWalletCommand{ID: "w-1", Amount: decimal.NewFromInt(10), UserID: "u-1", AccountID: "a-1"})
+	customer := commands.WithPrincipal(context.Background(), commands.Principal{ID: "u-1"})
+	f.dispatch(t, customer, commands.DebitWalletCommand{ID: "w-1", CreditWalletID: "w-2", Amount: decimal.NewFromInt(3), Description: "coffee"})
+
+	events := f.project(t)
+	if len(events) != 2 {
+		t.Fatalf("%d events", len(events))
+	}
+	created, debited := metadata.FromEvent(events[0]), metadata.FromEvent(events[1])
+	if created.ActorID != "op-1" || created.ActorType != metadata.ActorOperator || created.Channel != metadata.ChannelCLI || created.ClientIP != "10.0.0.7" {
+		t.Fatalf("created by %+v", created)
+	}
+	if debited.ActorID != "u-1" || debited.ActorType != metadata.ActorCustomer {
+		t.Fatalf("debited by %+v", debited)
+	}
+	for _, m := range []metadata.Metadata{created, debited} {
+		if m.CorrelationID == "" || m.CausationID != m.CorrelationID {
+			t.Fatalf("a command without a cause starts its own correlation, got %+v", m)
+		}
+	}
+	if created.CorrelationID == debited.CorrelationID {
+		t.Fatal("separate commands share a correlation")
+	}
+}
+
+func TestActionsByActor(t *testing.T) {
+	f := newFixture(t)
+	f.dispatch(t, operator("op-1"), commands.CreateWalletCommand{ID: "w-1", Amount: decimal.NewFromInt(10), UserID: "u-1", AccountID: "a-1"})
+	f.dispatch(t, operator("op-2"), commands.CreateWalletCommand{ID: "w-2", UserID: "u-2", AccountID: "a-2"})
+	f.dispatch(t, operator("op-1"), commands.LockWalletCommand{ID: "w-1", Description: "fraud report"})
+	f.project(t)
+
+	actions, err := f.queries.ActionsBy(context.Background(), "op-1", audit.Filter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(actions) != 2 || actions[0].EventType != v1.WalletLocked || actions[1].EventType != v1.WalletCreated {
+		t.Fatalf("actions of op-1: %+v", actions)
+	}
+	if actions[0].WalletID != "w-1" || actions[0].Channel != metadata.ChannelCLI {
+		t.Fatalf("lock recorded as %+v", actions[0])
+	}
+
+	latest, err := f.queries.ActionsBy(context.Background(), "op-1", audit.Filter{Limit: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(latest) != 1 || latest[0].EventID != actions[0].EventID {
+		t.Fatalf("latest action of op-1: %+v", latest)
+	}
+}
+
+func TestCausalChainOfADebit(t *testing.T) {
+	f := newFixture(t)
+	request := metadata.WithMetadata(context.Background(), metadata.Metadata{
+		ActorID: "u-1", ActorType: metadata.ActorCustomer, Channel: metadata.ChannelREST,
+		CorrelationID: "corr-1", CausationID: "req-1",
+	})
+	f.dispatch(t, request, commands.CreateWalletCommand{ID: "w-1", Amount: decimal.NewFromInt(10), UserID: "u-1", AccountID: "a-1"})
+	f.dispatch(t, operator("op-1"), commands.CreateWalletCommand{ID: "w-2", UserID: "u-2", AccountID: "a-2"})
+	created := f.project(t)[0]
+
+	// A process reacting to the wallet being created debits it.
+	reaction := metadata.WithMetadata(context.Background(), metadata.CausedBy(created, "welcome-fee"))
+	f.dispatch(t, reaction, commands.DebitWalletCommand{ID: "w-1", CreditWalletID: "w-2", Amount: decimal.NewFromInt(1), Description: "fee"})
+	debit := f.project(t)[2]
+
+	chain, err := f.queries.CausalChain(context.Background(), debit.GetEventID())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(chain.Entries) != 2 || chain.Entries[0].EventID != created.GetEventID() || chain.Entries[1].EventID != debit.GetEventID() {
+		t.Fatalf("chain %+v", chain.Entries)
+	}
+	if chain.RootCauseID != "req-1" {
+		t.Fatalf("root cause %q", chain.RootCauseID)
+	}
+	if reactor := chain.Entries[1]; reactor.ActorID != "welcome-fee" || reactor.ActorType != metadata.ActorSystem || reactor.CorrelationID != "corr-1" {
+		t.Fatalf("debit recorded as %+v", reactor)
+	}
+
+	correlated, err := f.queries.Correlation(context.Background(), "corr-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(correlated) != 2 {
+		t.Fatalf("correlation corr-1: %+v", correlated)
+	}
+
+	if _, err := f.queries.CausalChain(context.Background(), "unknown"); !errors.Is(err, audit.ErrEntryNotFound) {
+		t.Fatalf("chain of an unknown event: %v", err)
+	}
+}
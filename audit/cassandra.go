@@ -0,0 +1,138 @@
+package audit
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/gocql/gocql"
+	"github.com/novabankapp/wallet.data/es/metadata"
+	"github.com/pkg/errors"
+	"github.com/scylladb/gocqlx/v2"
+)
+
+const (
+	EntriesTable              = "audit_entries"
+	EntriesByActorTable       = "audit_entries_by_actor"
+	EntriesByCorrelationTable = "audit_entries_by_correlation"
+
+	entryColumns = "event_id, event_type, wallet_id, version, timestamp, actor_id, actor_type, channel, correlation_id, causation_id, client_ip"
+)
+
+// CassandraStore keeps the audit trail in the tables created by the migrations package:
+// one row per event, and copies partitioned by actor and by correlation for the queries.
+type CassandraStore struct {
+	session gocqlx.Session
+}
+
+var _ Store = (*CassandraStore)(nil)
+
+func NewCassandraStore(session gocqlx.Session) *CassandraStore {
+	return &CassandraStore{session: session}
+}
+
+// SaveEntry writes the entry and its copies in one logged batch. The copies are keyed by
+// the event's timestamp and id, so saving a redelivered event overwrites them.
+func (s *CassandraStore) SaveEntry(ctx context.Context, e Entry) error {
+	values := []interface{}{
+		e.EventID, e.EventType, e.WalletID, e.Version, e.Timestamp,
+		e.ActorID, string(e.ActorType), e.Channel, e.CorrelationID, e.CausationID, e.ClientIP,
+	}
+	bindings := strings.TrimSuffix(strings.Repeat("?, ", len(values)), ", ")
+	batch := s.session.Session.NewBatch(gocql.LoggedBatch).WithContext(ctx)
+	batch.Query(fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)", EntriesTable, entryColumns, bindings), values...)
+	if e.ActorID != "" {
+		batch.Query(fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)", EntriesByActorTable, entryColumns, bindings), values...)
+	}
+	if e.CorrelationID != "" {
+		batch.Query(fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)", EntriesByCorrelationTable, entryColumns, bindings), values...)
+	}
+	if err := s.session.Session.ExecuteBatch(batch); err != nil {
+		return errors.Wrap(err, "Session.ExecuteBatch")
+	}
+	return nil
+}
+
+func (s *CassandraStore) GetEntry(ctx context.Context, eventID string) (*Entry, error) {
+	stmt := fmt.Sprintf("SELECT %s FROM %s WHERE event_id = ?", entryColumns, EntriesTable)
+	entries, err := scanEntries(s.session.Session.Query(stmt, eventID).WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) == 0 {
+		return nil, ErrEntryNotFound
+	}
+	return &entries[0], nil
+}
+
+// ListByActor reads the actor's partition, newest first, within the filter's window.
+func (s *CassandraStore) ListByActor(ctx context.Context, actorID string, filter Filter) ([]Entry, error) {
+	where := []string{"actor_id = ?"}
+	values := []interface{}{actorID}
+	if !filter.Since.IsZero() {
+		where = append(where, "timestamp >= ?")
+		values = append(values, filter.Since)
+	}
+	if !filter.Until.IsZero() {
+		where = append(where, "timestamp < ?")
+		values = append(values, filter.Until)
+	}
+	stmt := fmt.Sprintf("SELECT %s FROM %s WHERE %s", entryColumns, EntriesByActorTable, strings.Join(where, " AND "))
+	entries, err := scanEntries(s.session.Session.Query(stmt, values...).WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(entries, func(i, j int) bool { return newer(&entries[i], &entries[j]) })
+	if filter.Limit > 0 && len(entries) > filter.Limit {
+		entries = entries[:filter.Limit]
+	}
+	return entries, nil
+}
+
+func (s *CassandraStore) ListByCorrelation(ctx context.Context, correlationID string) ([]Entry, error) {
+	stmt := fmt.Sprintf("SELECT %s FROM %s WHERE correlation_id = ?", entryColumns, EntriesByCorrelationTable)
+	entries, err := scanEntries(s.session.Session.Query(stmt, correlationID).WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(entries, func(i, j int) bool { return newer(&entries[j], &entries[i]) })
+	return entries, nil
+}
+
+// ErasePersonalData redacts the actor id and client ip of the entries the erased user
+// acted in. The redacted entries are saved again, which moves their actor copies to the
+// partition of pii.Redacted, and then the user's actor partition is dropped.
+func (s *CassandraStore) ErasePersonalData(ctx context.Context, userID string, walletIDs []string) error {
+	entries, err := s.ListByActor(ctx, userID, Filter{})
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if err := s.SaveEntry(ctx, redactEntry(e)); err != nil {
+			return err
+		}
+	}
+	stmt := fmt.Sprintf("DELETE FROM %s WHERE actor_id = ?", EntriesByActorTable)
+	if err := s.session.Session.Query(stmt, userID).WithContext(ctx).Exec(); err != nil {
+		return errors.Wrap(err, "Query.Exec")
+	}
+	return nil
+}
+
+func scanEntries(query *gocql.Query) ([]Entry, error) {
+	iter := query.Iter()
+	var result []Entry
+	var e Entry
+	var actorType string
+	for iter.Scan(&e.EventID, &e.EventType, &e.WalletID, &e.Version, &e.Timestamp,
+		&e.ActorID, &actorType, &e.Channel, &e.CorrelationID, &e.CausationID, &e.ClientIP) {
+		e.ActorType = metadata.ActorType(actorType)
+		result = append(result, e)
+		e = Entry{}
+	}
+	if err := iter.Close(); err != nil {
+		return nil, errors.Wrap(err, "Iter.Close")
+	}
+	return result, nil
+}
@@ -0,0 +1,50 @@
+// Package audit keeps a read model of who did what to which wallet: one entry per wallet
+// event with the actor, channel and causation recorded in the event's metadata. It
+// answers which actions an actor took and which chain of requests and events led to an
+// event.
+package audit
+
+import (
+	"time"
+
+	es "github.com/novabankapp/common.data/eventstore"
+	"github.com/novabankapp/wallet.data/es/aggregate"
+	"github.com/novabankapp/wallet.data/es/metadata"
+)
+
+// Entry is the audit record of one wallet event.
+type Entry struct {
+	EventID   string    `json:"event_id"`
+	EventType string    `json:"event_type"`
+	WalletID  string    `json:"wallet_id"`
+	Version   int64     `json:"version"`
+	Timestamp time.Time `json:"timestamp"`
+	metadata.Metadata
+}
+
+// NewEntry returns the audit record of evt. Events written without actor metadata get an
+// entry too, with the metadata fields left empty.
+func NewEntry(evt es.Event) Entry {
+	return Entry{
+		EventID:   evt.GetEventID(),
+		EventType: evt.GetEventType(),
+		WalletID:  aggregate.GetWalletAggregateID(evt.GetAggregateID()),
+		Version:   evt.GetVersion(),
+		Timestamp: evt.GetTimeStamp().UTC(),
+		Metadata:  metadata.FromEvent(evt),
+	}
+}
+
+// Filter narrows a query of audit entries. Zero values match everything.
+type Filter struct {
+	Since time.Time
+	Until time.Time
+	Limit int
+}
+
+func (f Filter) matches(e *Entry) bool {
+	if !f.Since.IsZero() && e.Timestamp.Before(f.Since) {
+		return false
+	}
+	return f.Until.IsZero() || e.Timestamp.Before(f.Until)
+}
@@ -0,0 +1,78 @@
+package audit
+
+import (
+	"context"
+	"strings"
+
+	"github.com/EventStore/EventStore-Client-Go/esdb"
+	es "github.com/novabankapp/common.data/eventstore"
+	"github.com/novabankapp/common.data/logger"
+	"github.com/novabankapp/wallet.data/es/aggregate"
+	"github.com/novabankapp/wallet.data/es/store"
+	"github.com/novabankapp/wallet.data/tracing"
+	"github.com/pkg/errors"
+)
+
+const ProjectionName = "(Audit Projection)"
+
+// Projection records an audit entry for every wallet event of a persistent subscription.
+type Projection struct {
+	Store     Store
+	GroupName string
+	Log       logger.Logger
+}
+
+func NewProjection(store Store, groupName string, log logger.Logger) *Projection {
+	return &Projection{Store: store, GroupName: groupName, Log: log}
+}
+
+// Run records the events of a persistent subscription. An event is acked once its entry
+// is stored; if storing fails the event is retried by the group.
+func (p *Projection) Run(ctx context.Context, stream store.PersistentSubscription, workerID int) error {
+	for {
+		event := stream.Recv()
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+
+		if event.SubscriptionDropped != nil {
+			p.Log.Errorf("(SubscriptionDropped) err: {%v}", event.SubscriptionDropped.Error)
+			return errors.Wrap(event.SubscriptionDropped.Error, "Subscription Dropped")
+		}
+
+		if event.EventAppeared != nil {
+			p.Log.ProjectionEvent(ProjectionName, p.GroupName, event.EventAppeared, workerID)
+
+			if err := p.Record(ctx, es.NewEventFromRecorded(event.EventAppeared.Event)); err != nil {
+				p.Log.Errorf("(Projection.Record) err: {%v}", err)
+				if err := stream.Nack(err.Error(), esdb.Nack_Retry, event.EventAppeared); err != nil {
+					p.Log.Errorf("(stream.Nack) err: {%v}", err)
+					return errors.Wrap(err, "stream.Nack")
+				}
+				continue
+			}
+
+			if err := stream.Ack(event.EventAppeared); err != nil {
+				p.Log.Errorf("(stream.Ack) err: {%v}", err)
+				return errors.Wrap(err, "stream.Ack")
+			}
+		}
+	}
+}
+
+// Record stores the audit entry of evt. Events of other aggregates are ignored.
+func (p *Projection) Record(ctx context.Context, evt es.Event) error {
+	ctx, span := tracing.StartEventSpan(ctx, "AuditProjection.Record", evt)
+	defer span.End()
+
+	if !strings.HasPrefix(evt.GetAggregateID(), aggregate.GetWalletStreamID("")) {
+		return nil
+	}
+	if err := p.Store.SaveEntry(ctx, NewEntry(evt)); err != nil {
+		tracing.TraceErr(span, err)
+		return errors.Wrap(err, "SaveEntry")
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package audit
+
+import (
+	"context"
+
+	"github.com/novabankapp/wallet.data/tracing"
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// Chain is the causal chain behind an event: the events that led to it, oldest first and
+// ending with the event itself. RootCauseID is the cause of the oldest event, usually the
+// id of the request that started it all.
+type Chain struct {
+	Entries     []Entry `json:"entries"`
+	RootCauseID string  `json:"root_cause_id,omitempty"`
+}
+
+type Queries struct {
+	Store Store
+}
+
+func NewQueries(store Store) *Queries {
+	return &Queries{Store: store}
+}
+
+// ActionsBy returns the wallet events the actor caused, newest first.
+func (q *Queries) ActionsBy(ctx context.Context, actorID string, filter Filter) ([]Entry, error) {
+	ctx, span := tracing.StartSpan(ctx, "AuditQueries.ActionsBy")
+	defer span.End()
+	span.SetAttributes(attribute.String("ActorID", actorID))
+
+	if actorID == "" {
+		return nil, errors.New("actor id is required")
+	}
+	entries, err := q.Store.ListByActor(ctx, actorID, filter)
+	if err != nil {
+		tracing.TraceErr(span, err)
+		return nil, errors.Wrap(err, "ListByActor")
+	}
+	return entries, nil
+}
+
+// CausalChain follows the causation ids from the event back to the first cause that is
+// not an event. It returns ErrEntryNotFound when the event itself has no entry.
+func (q *Queries) CausalChain(ctx context.Context, eventID string) (*Chain, error) {
+	ctx, span := tracing.StartSpan(ctx, "AuditQueries.CausalChain")
+	defer span.End()
+	span.SetAttributes(attribute.String("EventID", eventID))
+
+	entry, err := q.Store.GetEntry(ctx, eventID)
+	if err != nil {
+		tracing.TraceErr(span, err)
+		return nil, errors.Wrap(err, "GetEntry")
+	}
+	chain := []Entry{*entry}
+	seen := map[string]bool{entry.EventID: true}
+	for entry.CausationID != "" && !seen[entry.CausationID] {
+		cause, err := q.Store.GetEntry(ctx, entry.CausationID)
+		if errors.Is(err, ErrEntryNotFound) {
+			break
+		}
+		if err != nil {
+			tracing.TraceErr(span, err)
+			return nil, errors.Wrap(err, "GetEntry")
+		}
+		seen[cause.EventID] = true
+		chain = append(chain, *cause)
+		entry = cause
+	}
+
+	for i, j := 0, len(chain)-1; i < j; i, j = i+1, j-1 {
+		chain[i], chain[j] = chain[j], chain[i]
+	}
+	return &Chain{Entries: chain, RootCauseID: chain[0].CausationID}, nil
+}
+
+// Correlation returns every event of a correlation, oldest first.
+func (q *Queries) Correlation(ctx context.Context, correlationID string) ([]Entry, error) {
+	ctx, span := tracing.StartSpan(ctx, "AuditQueries.Correlation")
+	defer span.End()
+	span.SetAttributes(attribute.String("CorrelationID", correlationID))
+
+	entries, err := q.Store.ListByCorrelation(ctx, correlationID)
+	if err != nil {
+		tracing.TraceErr(span, err)
+		return nil, errors.Wrap(err, "ListByCorrelation")
+	}
+	return entries, nil
+}
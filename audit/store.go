@@ -0,0 +1,114 @@
+package audit
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"github.com/novabankapp/wallet.data/es/pii"
+	"github.com/pkg/errors"
+)
+
+var ErrEntryNotFound = errors.New("audit entry not found")
+
+type Store interface {
+	// SaveEntry stores e, replacing an entry of the same event, which makes recording a
+	// redelivered event harmless.
+	SaveEntry(ctx context.Context, e Entry) error
+	// GetEntry returns ErrEntryNotFound when no entry has the event id.
+	GetEntry(ctx context.Context, eventID string) (*Entry, error)
+	// ListByActor returns the entries of an actor, newest first.
+	ListByActor(ctx context.Context, actorID string, filter Filter) ([]Entry, error)
+	// ListByCorrelation returns the entries of a correlation, oldest first.
+	ListByCorrelation(ctx context.Context, correlationID string) ([]Entry, error)
+}
+
+// MemoryStore is an in-memory Store for tests and local runs; CassandraStore keeps the
+// trail across restarts.
+type MemoryStore struct {
+	mu      sync.Mutex
+	entries map[string]Entry
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{entries: make(map[string]Entry)}
+}
+
+func (m *MemoryStore) SaveEntry(ctx context.Context, e Entry) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.entries[e.EventID] = e
+	return nil
+}
+
+func (m *MemoryStore) GetEntry(ctx context.Context, eventID string) (*Entry, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e, ok := m.entries[eventID]
+	if !ok {
+		return nil, ErrEntryNotFound
+	}
+	return &e, nil
+}
+
+func (m *MemoryStore) ListByActor(ctx context.Context, actorID string, filter Filter) ([]Entry, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var result []Entry
+	for _, e := range m.entries {
+		if e.ActorID == actorID && filter.matches(&e) {
+			result = append(result, e)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return newer(&result[i], &result[j]) })
+	if filter.Limit > 0 && len(result) > filter.Limit {
+		result = result[:filter.Limit]
+	}
+	return result, nil
+}
+
+func (m *MemoryStore) ListByCorrelation(ctx context.Context, correlationID string) ([]Entry, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var result []Entry
+	for _, e := range m.entries {
+		if e.CorrelationID == correlationID {
+			result = append(result, e)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return newer(&result[j], &result[i]) })
+	return result, nil
+}
+
+// ErasePersonalData redacts the actor id and client ip of the entries the erased user
+// acted in. The entries themselves stay in the trail.
+func (m *MemoryStore) ErasePersonalData(ctx context.Context, userID string, walletIDs []string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for id, e := range m.entries {
+		if e.ActorID == userID {
+			m.entries[id] = redactEntry(e)
+		}
+	}
+	return nil
+}
+
+func redactEntry(e Entry) Entry {
+	e.ActorID = pii.Redacted
+	if e.ClientIP != "" {
+		e.ClientIP = pii.Redacted
+	}
+	return e
+}
+
+// newer orders entries by time, then by wallet version and event id so that events
+// written in the same instant keep a stable order.
+func newer(a, b *Entry) bool {
+	if !a.Timestamp.Equal(b.Timestamp) {
+		return a.Timestamp.After(b.Timestamp)
+	}
+	if a.WalletID == b.WalletID && a.Version != b.Version {
+		return a.Version > b.Version
+	}
+	return a.EventID > b.EventID
+}
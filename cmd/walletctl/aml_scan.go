@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"time"
+
+	"github.com/novabankapp/wallet.data/aml"
+	"github.com/novabankapp/wallet.data/es/readmodel"
+	"github.com/pkg/errors"
+)
+
+// runAMLScan runs the AML scenarios over the Cassandra read model and lists the alerts of
+// a period. The cases it opens live as long as the command, so it is a dry run of the
+// monitor rather than a way to triage.
+func runAMLScan(ctx context.Context, cfg *Config, args []string) error {
+	flags := flag.NewFlagSet("aml-scan", flag.ContinueOnError)
+	from := flags.String("from", "", "start of the period, RFC 3339 (default: 30 days ago)")
+	to := flags.String("to", "", "end of the period, RFC 3339 (default: open)")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	start := time.Now().AddDate(0, 0, -30)
+	if *from != "" {
+		t, err := time.Parse(time.RFC3339, *from)
+		if err != nil {
+			return errors.Wrap(err, "-from")
+		}
+		start = t
+	}
+	var end time.Time
+	if *to != "" {
+		t, err := time.Parse(time.RFC3339, *to)
+		if err != nil {
+			return errors.Wrap(err, "-to")
+		}
+		end = t
+	}
+
+	session, err := cfg.CassandraSession()
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+
+	monitor := aml.NewMonitor(aml.DefaultConfig(), readmodel.NewWalletProjectionRepository(session), aml.NewCassandraCaseStore(session))
+	alerts, err := monitor.Scan(ctx, start, end)
+	if err != nil {
+		return err
+	}
+
+	t := table{header: []string{"OCCURRED", "SCENARIO", "WALLET", "AMOUNT", "SUMMARY"}}
+	for _, a := range alerts {
+		t.add(a.OccurredAt.Format(time.RFC3339), string(a.Scenario), a.WalletID, a.Amount.String(), a.Summary)
+	}
+	return cfg.print(alerts, t)
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"time"
+
+	"github.com/novabankapp/wallet.data/es/aggregate"
+	"github.com/pkg/errors"
+)
+
+func runAsOf(ctx context.Context, cfg *Config, args []string) error {
+	flags := flag.NewFlagSet("as-of", flag.ContinueOnError)
+	walletId := flags.String("wallet", "", "wallet id")
+	at := flags.String("at", "", "point in time, RFC 3339 (e.g. 2024-03-31T23:59:59Z)")
+	version := flags.Int64("version", -1, "last event version to include")
+	noSnapshots := flags.Bool("no-snapshots", false, "replay the whole stream instead of starting from a snapshot")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *walletId == "" {
+		return errors.New("-wallet is required")
+	}
+
+	var asOf aggregate.AsOf
+	if *at != "" {
+		t, err := time.Parse(time.RFC3339, *at)
+		if err != nil {
+			return errors.Wrap(err, "-at")
+		}
+		asOf.Time = &t
+	}
+	if *version >= 0 {
+		asOf.Version = version
+	}
+
+	db, err := cfg.EventStore()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	reader, snapshots, closeReader, err := cfg.historyReaders(db)
+	if err != nil {
+		return err
+	}
+	defer closeReader()
+	if *noSnapshots {
+		snapshots = nil
+	}
+	_, state, err := aggregate.LoadWalletAggregateAsOf(ctx, reader, snapshots, *walletId, asOf)
+	if err != nil {
+		return err
+	}
+
+	return cfg.print(state, stateTable(state))
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"io"
+	"os"
+	"os/user"
+	"strings"
+	"time"
+
+	"github.com/EventStore/EventStore-Client-Go/esdb"
+	"github.com/gocql/gocql"
+	"github.com/novabankapp/wallet.data/es/pii"
+	"github.com/pkg/errors"
+	"github.com/scylladb/gocqlx/v2"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	envConfigFile           = "WALLETCTL_CONFIG"
+	envEventStoreConnection = "WALLETCTL_EVENTSTORE_CONNECTION"
+	envCassandraHosts       = "WALLETCTL_CASSANDRA_HOSTS"
+	envCassandraKeyspace    = "WALLETCTL_CASSANDRA_KEYSPACE"
+	envCassandraUsername    = "WALLETCTL_CASSANDRA_USERNAME"
+	envCassandraPassword    = "WALLETCTL_CASSANDRA_PASSWORD"
+	envCassandraTimeout     = "WALLETCTL_CASSANDRA_TIMEOUT"
+	envOutput               = "WALLETCTL_OUTPUT"
+	envOperator             = "WALLETCTL_OPERATOR"
+
+	defaultKeyspace         = "novabankapp"
+	defaultCassandraTimeout = 10 * time.Second
+)
+
+// Config holds the connection settings. They are read from a YAML file, e.g.
+//
+//	eventstore:
+//	  connection: esdb://localhost:2113?tls=false
+//	cassandra:
+//	  hosts: [cassandra-1:9042, cassandra-2:9042]
+//	  keyspace: novabankapp
+//	  username: walletctl
+//	  password: secret
+//	  timeout: 10s
+//	output: table
+//	operator: jdoe
+//
+// and the WALLETCTL_* environment variables, which take precedence over the file.
+type Config struct {
+	EventStoreDB EventStoreConfig `yaml:"eventstore"`
+	Cassandra    CassandraConfig  `yaml:"cassandra"`
+	Output       string           `yaml:"output"`
+	// Operator is recorded as the actor of the commands walletctl sends; the name of the
+	// OS user when not set.
+	Operator string `yaml:"operator"`
+
+	stdout io.Writer
+}
+
+type EventStoreConfig struct {
+	Connection string `yaml:"connection"`
+}
+
+type CassandraConfig struct {
+	Hosts    []string      `yaml:"hosts"`
+	Keyspace string        `yaml:"keyspace"`
+	Username string        `yaml:"username"`
+	Password string        `yaml:"password"`
+	Timeout  time.Duration `yaml:"timeout"`
+}
+
+// LoadConfig reads the config file at path, or at $WALLETCTL_CONFIG when path is empty,
+// and applies the environment on top. Without either file only the environment is used.
+func LoadConfig(path string) (*Config, error) {
+	cfg := &Config{
+		Cassandra: CassandraConfig{Keyspace: defaultKeyspace, Timeout: defaultCassandraTimeout},
+		Output:    outputTable,
+		stdout:    os.Stdout,
+	}
+	if path == "" {
+		path = os.Getenv(envConfigFile)
+	}
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, errors.Wrap(err, "read config")
+		}
+		if err := yaml.UnmarshalStrict(data, cfg); err != nil {
+			return nil, errors.Wrapf(err, "parse config %s", path)
+		}
+	}
+
+	if value := os.Getenv(envEventStoreConnection); value != "" {
+		cfg.EventStoreDB.Connection = value
+	}
+	if value := os.Getenv(envCassandraHosts); value != "" {
+		cfg.Cassandra.Hosts = strings.Split(value, ",")
+	}
+	if value := os.Getenv(envCassandraKeyspace); value != "" {
+		cfg.Cassandra.Keyspace = value
+	}
+	if value := os.Getenv(envCassandraUsername); value != "" {
+		cfg.Cassandra.Username = value
+	}
+	if value := os.Getenv(envCassandraPassword); value != "" {
+		cfg.Cassandra.Password = value
+	}
+	if value := os.Getenv(envCassandraTimeout); value != "" {
+		timeout, err := time.ParseDuration(value)
+		if err != nil {
+			return nil, errors.Wrap(err, envCassandraTimeout)
+		}
+		cfg.Cassandra.Timeout = timeout
+	}
+	if value := os.Getenv(envOutput); value != "" {
+		cfg.Output = value
+	}
+	if value := os.Getenv(envOperator); value != "" {
+		cfg.Operator = value
+	}
+	if cfg.Operator == "" {
+		if current, err := user.Current(); err == nil {
+			cfg.Operator = current.Username
+		}
+	}
+	return cfg, cfg.validate()
+}
+
+func (c *Config) validate() error {
+	switch c.Output {
+	case outputTable, outputJSON:
+	default:
+		return errors.Errorf("unknown output format %q (want %s or %s)", c.Output, outputTable, outputJSON)
+	}
+	for i, host := range c.Cassandra.Hosts {
+		c.Cassandra.Hosts[i] = strings.TrimSpace(host)
+	}
+	return nil
+}
+
+func (c *Config) EventStore() (*esdb.Client, error) {
+	if c.EventStoreDB.Connection == "" {
+		return nil, errors.Errorf("no EventStoreDB connection: set eventstore.connection or %s", envEventStoreConnection)
+	}
+	settings, err := esdb.ParseConnectionString(c.EventStoreDB.Connection)
+	if err != nil {
+		return nil, errors.Wrap(err, "esdb.ParseConnectionString")
+	}
+	return esdb.NewClient(settings)
+}
+
+func (c *Config) CassandraSession() (gocqlx.Session, error) {
+	if len(c.Cassandra.Hosts) == 0 {
+		return gocqlx.Session{}, errors.Errorf("no Cassandra hosts: set cassandra.hosts or %s", envCassandraHosts)
+	}
+	cluster := gocql.NewCluster(c.Cassandra.Hosts...)
+	cluster.Keyspace = c.Cassandra.Keyspace
+	cluster.Timeout = c.Cassandra.Timeout
+	cluster.ConnectTimeout = c.Cassandra.Timeout
+	if c.Cassandra.Username != "" {
+		cluster.Authenticator = gocql.PasswordAuthenticator{Username: c.Cassandra.Username, Password: c.Cassandra.Password}
+	}
+	session, err := gocqlx.WrapSession(cluster.CreateSession())
+	if err != nil {
+		return gocqlx.Session{}, errors.Wrap(err, "cluster.CreateSession")
+	}
+	return session, nil
+}
+
+// PersonalData returns the cipher of the personal data in wallet events, with its keys in
+// Cassandra, and a func that closes the session it opened.
+func (c *Config) PersonalData() (*pii.Cipher, func(), error) {
+	session, err := c.CassandraSession()
+	if err != nil {
+		return nil, nil, err
+	}
+	return pii.NewCipher(pii.NewCassandraKeyStore(session)), session.Close, nil
+}
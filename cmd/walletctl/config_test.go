@@ -0,0 +1,125 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// clearEnv keeps the caller's WALLETCTL_* settings out of the tests.
+func clearEnv(t *testing.T) {
+	for _, name := range []string{envConfigFile, envEventStoreConnection, envCassandraHosts, envCassandraKeyspace,
+		envCassandraUsername, envCassandraPassword, envCassandraTimeout, envOutput, envOperator} {
+		t.Setenv(name, "")
+	}
+}
+
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "walletctl.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadConfigFromFile(t *testing.T) {
+	clearEnv(t)
+	path := writeConfig(t, `
+eventstore:
+  connection: esdb://localhost:2113?tls=false
+cassandra:
+  hosts: [cassandra-1:9042, cassandra-2:9042]
+  username: walletctl
+  timeout: 3s
+output: json
+`)
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.EventStoreDB.Connection != "esdb://localhost:2113?tls=false" {
+		t.Fatalf("connection %q", cfg.EventStoreDB.Connection)
+	}
+	if !reflect.DeepEqual(cfg.Cassandra.Hosts, []string{"cassandra-1:9042", "cassandra-2:9042"}) {
+		t.Fatalf("hosts %v", cfg.Cassandra.Hosts)
+	}
+	if cfg.Cassandra.Keyspace != defaultKeyspace {
+		t.Fatalf("keyspace %q, expected the default", cfg.Cassandra.Keyspace)
+	}
+	if cfg.Cassandra.Timeout != 3*time.Second || cfg.Cassandra.Username != "walletctl" || cfg.Output != outputJSON {
+		t.Fatalf("unexpected config %+v", cfg)
+	}
+}
+
+func TestEnvironmentOverridesConfigFile(t *testing.T) {
+	clearEnv(t)
+	path := writeConfig(t, `
+eventstore:
+  connection: esdb://file:2113
+cassandra:
+  hosts: [file:9042]
+`)
+	t.Setenv(envEventStoreConnection, "esdb://env:2113")
+	t.Setenv(envCassandraHosts, "env-1:9042, env-2:9042")
+	t.Setenv(envCassandraPassword, "secret")
+
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.EventStoreDB.Connection != "esdb://env:2113" {
+		t.Fatalf("connection %q", cfg.EventStoreDB.Connection)
+	}
+	if !reflect.DeepEqual(cfg.Cassandra.Hosts, []string{"env-1:9042", "env-2:9042"}) {
+		t.Fatalf("hosts %v", cfg.Cassandra.Hosts)
+	}
+	if cfg.Cassandra.Password != "secret" || cfg.Output != outputTable {
+		t.Fatalf("unexpected config %+v", cfg)
+	}
+}
+
+func TestConfigFileFromEnvironment(t *testing.T) {
+	clearEnv(t)
+	t.Setenv(envConfigFile, writeConfig(t, "cassandra:\n  keyspace: wallets\n"))
+	cfg, err := LoadConfig("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Cassandra.Keyspace != "wallets" {
+		t.Fatalf("keyspace %q", cfg.Cassandra.Keyspace)
+	}
+}
+
+func TestLoadConfigRejectsMistakes(t *testing.T) {
+	clearEnv(t)
+	for name, content := range map[string]string{
+		"unknown key":    "eventstore:\n  conection: esdb://localhost:2113\n",
+		"unknown output": "output: xml\n",
+	} {
+		t.Run(name, func(t *testing.T) {
+			if _, err := LoadConfig(writeConfig(t, content)); err == nil {
+				t.Fatal("expected an error")
+			}
+		})
+	}
+	if _, err := LoadConfig(filepath.Join(t.TempDir(), "missing.yaml")); err == nil {
+		t.Fatal("expected an error for a missing file")
+	}
+}
+
+func TestConnectionsNeedSettings(t *testing.T) {
+	clearEnv(t)
+	cfg, err := LoadConfig("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cfg.EventStore(); err == nil {
+		t.Fatal("expected an error without an EventStoreDB connection")
+	}
+	if _, err := cfg.CassandraSession(); err == nil {
+		t.Fatal("expected an error without Cassandra hosts")
+	}
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"strconv"
+	"time"
+
+	"github.com/EventStore/EventStore-Client-Go/esdb"
+	es "github.com/novabankapp/common.data/eventstore"
+	"github.com/novabankapp/wallet.data/es/aggregate"
+	"github.com/novabankapp/wallet.data/es/store"
+	"github.com/pkg/errors"
+)
+
+// eventView is an event with its payload and metadata decoded, so they show up as JSON
+// rather than base64.
+type eventView struct {
+	Version   int64           `json:"version"`
+	EventID   string          `json:"event_id"`
+	EventType string          `json:"event_type"`
+	Timestamp time.Time       `json:"timestamp"`
+	Data      json.RawMessage `json:"data,omitempty"`
+	Metadata  json.RawMessage `json:"metadata,omitempty"`
+}
+
+func newEventView(evt es.Event) eventView {
+	return eventView{
+		Version:   evt.GetVersion(),
+		EventID:   evt.GetEventID(),
+		EventType: evt.GetEventType(),
+		Timestamp: evt.GetTimeStamp(),
+		Data:      rawJSON(evt.GetData()),
+		Metadata:  rawJSON(evt.GetMetadata()),
+	}
+}
+
+// rawJSON passes a JSON payload through and quotes anything else as a string.
+func rawJSON(data []byte) json.RawMessage {
+	if len(data) == 0 {
+		return nil
+	}
+	if json.Valid(data) {
+		var compact bytes.Buffer
+		if err := json.Compact(&compact, data); err == nil {
+			return compact.Bytes()
+		}
+	}
+	quoted, _ := json.Marshal(string(data))
+	return quoted
+}
+
+func runEvents(ctx context.Context, cfg *Config, args []string) error {
+	flags := flag.NewFlagSet("events", flag.ContinueOnError)
+	walletId := flags.String("wallet", "", "wallet id")
+	from := flags.Int64("from", 0, "first event version to show")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *walletId == "" {
+		return errors.New("-wallet is required")
+	}
+
+	db, err := cfg.EventStore()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	reader, closeReader, err := cfg.eventReader(db)
+	if err != nil {
+		return err
+	}
+	defer closeReader()
+	events, err := reader.ReadEvents(ctx, aggregate.GetWalletStreamID(*walletId), *from)
+	if err != nil {
+		return err
+	}
+	return cfg.print(eventViews(events))
+}
+
+// eventReader reads the events of db with their personal data decrypted when Cassandra,
+// which holds the keys, is configured, and as stored otherwise.
+func (c *Config) eventReader(db *esdb.Client) (store.EventReader, func(), error) {
+	reader, _, closeKeys, err := c.historyReaders(db)
+	return reader, closeKeys, err
+}
+
+// historyReaders returns the readers of a wallet's events and snapshots. Without Cassandra
+// there are no personal data keys: events are read as stored, and snapshots, which are
+// encrypted as a whole, are not used.
+func (c *Config) historyReaders(db *esdb.Client) (store.EventReader, store.SnapshotStore, func(), error) {
+	reader := store.NewESDBEventReader(db)
+	if len(c.Cassandra.Hosts) == 0 {
+		return reader, nil, func() {}, nil
+	}
+	cipher, closeKeys, err := c.PersonalData()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return cipher.RevealReader(reader), cipher.ProtectSnapshots(store.NewESDBSnapshotStore(db)), closeKeys, nil
+}
+
+func eventViews(events []es.Event) ([]eventView, table) {
+	views := make([]eventView, 0, len(events))
+	t := table{header: []string{"VERSION", "TYPE", "TIMESTAMP", "DATA"}}
+	for _, evt := range events {
+		view := newEventView(evt)
+		views = append(views, view)
+		t.add(strconv.FormatInt(view.Version, 10), view.EventType, view.Timestamp.Format(time.RFC3339), string(view.Data))
+	}
+	return views, t
+}
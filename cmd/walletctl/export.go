@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/novabankapp/wallet.data/es/pii"
+	"github.com/novabankapp/wallet.data/es/readmodel"
+	"github.com/novabankapp/wallet.data/es/store"
+	"github.com/novabankapp/wallet.data/gdpr"
+	"github.com/pkg/errors"
+)
+
+type exportResult struct {
+	UserID       string `json:"user_id"`
+	Wallets      int    `json:"wallets"`
+	File         string `json:"file"`
+	ChecksumFile string `json:"checksum_file"`
+	SHA256       string `json:"sha256"`
+}
+
+// runExportUser writes the subject access archive of a user. The read model, the wallet
+// links and the keys of the personal data are read from Cassandra when it is configured.
+func runExportUser(ctx context.Context, cfg *Config, args []string) error {
+	flags := flag.NewFlagSet("export-user", flag.ContinueOnError)
+	userId := flags.String("user", "", "user id")
+	out := flags.String("out", "", "archive to write, e.g. user-1.zip; its checksum goes next to it in <out>.sha256")
+	scan := flags.Bool("scan", true, "also find wallets by reading the creation event of every wallet, which the read model may not know yet")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *userId == "" {
+		return errors.New("-user is required")
+	}
+	if *out == "" {
+		return errors.New("-out is required")
+	}
+
+	db, err := cfg.EventStore()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	exporter := gdpr.NewExporter(store.NewESDBEventReader(db), nil)
+	if *scan {
+		exporter.Streams = store.NewESDBStreamLister(db)
+	}
+	if len(cfg.Cassandra.Hosts) > 0 {
+		session, err := cfg.CassandraSession()
+		if err != nil {
+			return err
+		}
+		defer session.Close()
+		exporter.ReadModel = readmodel.NewWalletProjectionRepository(session)
+		cipher := pii.NewCipher(pii.NewCassandraKeyStore(session))
+		exporter.Links = cipher.ProtectLinks(readmodel.NewWalletLinkRepository(session))
+		exporter.PersonalData = cipher
+	}
+
+	export, err := exporter.Collect(ctx, *userId)
+	if err != nil {
+		return err
+	}
+	checksum, err := writeArchive(*out, export)
+	if err != nil {
+		return err
+	}
+	checksumFile := *out + ".sha256"
+	if err := os.WriteFile(checksumFile, []byte(gdpr.ChecksumLine(checksum, filepath.Base(*out))), 0o600); err != nil {
+		return errors.Wrap(err, "os.WriteFile")
+	}
+
+	result := exportResult{UserID: *userId, Wallets: len(export.Wallets), File: *out, ChecksumFile: checksumFile, SHA256: checksum}
+	return cfg.print(result, fields(
+		"user", result.UserID,
+		"wallets", strconv.Itoa(result.Wallets),
+		"file", result.File,
+		"checksum file", result.ChecksumFile,
+		"sha256", result.SHA256,
+	))
+}
+
+// writeArchive writes the archive to path, removing the file again when writing fails.
+func writeArchive(path string, export *gdpr.Export) (string, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return "", errors.Wrap(err, "os.OpenFile")
+	}
+	checksum, err := gdpr.WriteArchive(f, export)
+	if closeErr := f.Close(); err == nil && closeErr != nil {
+		err = errors.Wrap(closeErr, "Close")
+	}
+	if err != nil {
+		os.Remove(path)
+		return "", err
+	}
+	return checksum, nil
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"os"
+
+	"github.com/novabankapp/wallet.data/es/readmodel"
+	"github.com/novabankapp/wallet.data/restrictions"
+)
+
+// runLiftExpired sweeps the restriction expiries of the Cassandra read model once and
+// lifts the locks and blacklistings whose expiry has passed, as the restriction-expiry
+// actor rather than the operator. Restrictions imposed before the restriction_expiries
+// table was created are only found once "replay -all" has filled it.
+func runLiftExpired(ctx context.Context, cfg *Config, args []string) error {
+	flags := flag.NewFlagSet("lift-expired", flag.ContinueOnError)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	session, err := cfg.CassandraSession()
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+	bus, closeBus, err := newBus(cfg)
+	if err != nil {
+		return err
+	}
+	defer closeBus()
+
+	lifter := restrictions.NewLifter(readmodel.NewRestrictionExpiryRepository(session), bus, stderrLogger{w: os.Stderr})
+	lifted, err := lifter.Sweep(ctx)
+	t := table{header: []string{"WALLET"}}
+	for _, walletID := range lifted {
+		t.add(walletID)
+	}
+	if printErr := cfg.print(lifted, t); printErr != nil {
+		return printErr
+	}
+	return err
+}
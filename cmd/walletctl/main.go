@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+)
+
+type command struct {
+	name    string
+	summary string
+	run     func(ctx context.Context, cfg *Config, args []string) error
+}
+
+var subcommands = []command{
+	{name: "show", summary: "show a wallet's current balance and state", run: runShow},
+	{name: "as-of", summary: "show a wallet's balance and state at a point in time", run: runAsOf},
+	{name: "events", summary: "dump a wallet's event stream with decoded payloads", run: runEvents},
+	{name: "lock", summary: "lock a wallet (-reason required, -code, -authority, -evidence, -expires)", run: runLock},
+	{name: "unlock", summary: "unlock a wallet (-reason required)", run: runUnlock},
+	{name: "blacklist", summary: "blacklist a wallet (-reason required, -code, -authority, -evidence, -expires)", run: runBlacklist},
+	{name: "lift-expired", summary: "lift the locks and blacklistings whose expiry has passed", run: runLiftExpired},
+	{name: "credit", summary: "credit a wallet from another wallet (-reason required)", run: runCredit},
+	{name: "debit", summary: "debit a wallet to another wallet (-reason required)", run: runDebit},
+	{name: "erase-user", summary: "erase a user's personal data by shredding their key (-reason required)", run: runEraseUser},
+	{name: "export-user", summary: "write the subject access archive of a user's wallets (-user and -out required)", run: runExportUser},
+	{name: "aml-scan", summary: "list the AML alerts of the read model over a period (-from, -to)", run: runAMLScan},
+	{name: "replay-projection", summary: "rebuild the Cassandra read model of one or all wallets", run: runReplayProjection},
+	{name: "migrate", summary: "apply the Cassandra schema migrations", run: runMigrate},
+}
+
+func main() {
+	global := flag.NewFlagSet("walletctl", flag.ContinueOnError)
+	global.Usage = usage
+	configFile := global.String("config", "", "config file (default $"+envConfigFile+")")
+	output := global.String("o", "", "output format: table or json")
+	if err := global.Parse(os.Args[1:]); err != nil {
+		os.Exit(2)
+	}
+	args := global.Args()
+	if len(args) < 1 {
+		usage()
+		os.Exit(2)
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	for _, cmd := range subcommands {
+		if cmd.name != args[0] {
+			continue
+		}
+		cfg, err := LoadConfig(*configFile)
+		if err == nil && *output != "" {
+			cfg.Output = *output
+			err = cfg.validate()
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "walletctl: %v\n", err)
+			os.Exit(1)
+		}
+		if err := cmd.run(ctx, cfg, args[1:]); err != nil {
+			fmt.Fprintf(os.Stderr, "walletctl %s: %v\n", cmd.name, err)
+			os.Exit(1)
+		}
+		return
+	}
+	usage()
+	os.Exit(2)
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: walletctl [-config file] [-o table|json] <command> [flags]")
+	fmt.Fprintln(os.Stderr, "")
+	fmt.Fprintln(os.Stderr, "commands:")
+	for _, cmd := range subcommands {
+		fmt.Fprintf(os.Stderr, "  %-20s %s\n", cmd.name, cmd.summary)
+	}
+	fmt.Fprintln(os.Stderr, "")
+	fmt.Fprintln(os.Stderr, "Run walletctl <command> -h for the flags of a command.")
+}
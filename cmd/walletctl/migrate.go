@@ -0,0 +1,22 @@
+package main
+
+import (
+	"context"
+	"flag"
+
+	"github.com/novabankapp/wallet.data/migrations"
+)
+
+func runMigrate(ctx context.Context, cfg *Config, args []string) error {
+	flags := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	session, err := cfg.CassandraSession()
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+	return migrations.InitCassandra(&session)
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+)
+
+const (
+	outputTable = "table"
+	outputJSON  = "json"
+)
+
+// table is the human readable form of a command's result.
+type table struct {
+	header []string
+	rows   [][]string
+}
+
+func (t *table) add(cells ...string) {
+	t.rows = append(t.rows, cells)
+}
+
+// fields renders name/value pairs as a two column table.
+func fields(pairs ...string) table {
+	t := table{header: []string{"FIELD", "VALUE"}}
+	for i := 0; i+1 < len(pairs); i += 2 {
+		t.add(pairs[i], pairs[i+1])
+	}
+	return t
+}
+
+// print writes value as indented JSON or t as an aligned table, per the configured output.
+func (c *Config) print(value interface{}, t table) error {
+	return writeOutput(c.stdout, c.Output, value, t)
+}
+
+func writeOutput(w io.Writer, format string, value interface{}, t table) error {
+	if format == outputJSON {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(value)
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	if len(t.header) > 0 {
+		fmt.Fprintln(tw, strings.Join(t.header, "\t"))
+	}
+	for _, row := range t.rows {
+		cells := make([]string, len(row))
+		for i, cell := range row {
+			// A tab or newline in a cell would break the alignment.
+			cells[i] = strings.NewReplacer("\t", " ", "\n", " ").Replace(cell)
+		}
+		fmt.Fprintln(tw, strings.Join(cells, "\t"))
+	}
+	return tw.Flush()
+}
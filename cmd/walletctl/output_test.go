@@ -0,0 +1,65 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	es "github.com/novabankapp/common.data/eventstore"
+)
+
+func TestTableOutputIsAligned(t *testing.T) {
+	tbl := table{header: []string{"WALLET", "EVENTS"}}
+	tbl.add("w-1", "3")
+	tbl.add("a-much-longer-id", "12\tx")
+
+	var out bytes.Buffer
+	if err := writeOutput(&out, outputTable, nil, tbl); err != nil {
+		t.Fatal(err)
+	}
+	expected := "" +
+		"WALLET            EVENTS\n" +
+		"w-1               3\n" +
+		"a-much-longer-id  12 x\n"
+	if out.String() != expected {
+		t.Fatalf("got\n%s\nexpected\n%s", out.String(), expected)
+	}
+}
+
+func TestJSONOutputEncodesTheValue(t *testing.T) {
+	var out bytes.Buffer
+	value := []replayResult{{WalletID: "w-1", Events: 3}}
+	if err := writeOutput(&out, outputJSON, value, table{}); err != nil {
+		t.Fatal(err)
+	}
+	var decoded []replayResult
+	if err := json.Unmarshal(out.Bytes(), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if len(decoded) != 1 || decoded[0] != value[0] {
+		t.Fatalf("decoded %+v", decoded)
+	}
+}
+
+func TestEventViewsDecodePayloads(t *testing.T) {
+	at := time.Date(2024, 3, 31, 12, 0, 0, 0, time.UTC)
+	events := []es.Event{
+		{EventID: "e-1", EventType: "WALLET_CREDITED", Version: 4, Timestamp: at, Data: []byte(`{ "Amount": "10" }`)},
+		{EventID: "e-2", EventType: "LEGACY", Version: 5, Timestamp: at, Data: []byte("not json")},
+	}
+	views, tbl := eventViews(events)
+
+	data, err := json.Marshal(views)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `[{"version":4,"event_id":"e-1","event_type":"WALLET_CREDITED","timestamp":"2024-03-31T12:00:00Z","data":{"Amount":"10"}},` +
+		`{"version":5,"event_id":"e-2","event_type":"LEGACY","timestamp":"2024-03-31T12:00:00Z","data":"not json"}]`
+	if string(data) != expected {
+		t.Fatalf("got\n%s\nexpected\n%s", data, expected)
+	}
+	if len(tbl.rows) != 2 || tbl.rows[0][3] != `{"Amount":"10"}` {
+		t.Fatalf("table rows %v", tbl.rows)
+	}
+}
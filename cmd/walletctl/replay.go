@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+
+	"github.com/EventStore/EventStore-Client-Go/esdb"
+	"github.com/novabankapp/common.data/eventstore/projections"
+	"github.com/novabankapp/common.data/logger"
+	"github.com/novabankapp/wallet.data/es/aggregate"
+	"github.com/novabankapp/wallet.data/es/pii"
+	"github.com/novabankapp/wallet.data/es/readmodel"
+	"github.com/novabankapp/wallet.data/es/store"
+	"github.com/pkg/errors"
+)
+
+type replayResult struct {
+	WalletID string `json:"wallet_id"`
+	Events   int    `json:"events"`
+}
+
+func runReplayProjection(ctx context.Context, cfg *Config, args []string) error {
+	flags := flag.NewFlagSet("replay-projection", flag.ContinueOnError)
+	walletId := flags.String("wallet", "", "wallet id")
+	all := flags.Bool("all", false, "rebuild every wallet; the read model is truncated first")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if (*walletId == "") == !*all {
+		return errors.New("exactly one of -wallet and -all is required")
+	}
+
+	db, err := cfg.EventStore()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	session, err := cfg.CassandraSession()
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+
+	repo := readmodel.NewWalletProjectionRepository(session)
+	expiries := readmodel.NewRestrictionExpiryRepository(session)
+	projection := &aggregate.WalletProjection{
+		CassandraProjection: projections.CassandraProjection{Log: stderrLogger{w: os.Stderr}, Db: db, Cfg: &projections.Config{}},
+		Repo:                repo,
+		PersonalData:        pii.NewCipher(pii.NewCassandraKeyStore(session)),
+		Expiries:            expiries,
+	}
+
+	walletIds := []string{*walletId}
+	if *all {
+		if walletIds, err = walletIDs(ctx, db); err != nil {
+			return err
+		}
+		if err := repo.Truncate(ctx); err != nil {
+			return err
+		}
+		if err := expiries.Truncate(ctx); err != nil {
+			return err
+		}
+	}
+
+	reader := store.NewESDBEventReader(db)
+	results := make([]replayResult, 0, len(walletIds))
+	t := table{header: []string{"WALLET", "EVENTS"}}
+	for _, id := range walletIds {
+		applied, err := projection.Rebuild(ctx, reader, id)
+		if err != nil {
+			return errors.Wrapf(err, "wallet %s", id)
+		}
+		results = append(results, replayResult{WalletID: id, Events: applied})
+		t.add(id, strconv.Itoa(applied))
+	}
+	return cfg.print(results, t)
+}
+
+// walletIDs lists the wallets with a stream, in the order they were created.
+func walletIDs(ctx context.Context, db *esdb.Client) ([]string, error) {
+	streams, err := store.NewESDBStreamLister(db).ListStreams(ctx, aggregate.GetWalletStreamID(""))
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]string, 0, len(streams))
+	for _, streamID := range streams {
+		ids = append(ids, aggregate.GetWalletAggregateID(streamID))
+	}
+	return ids, nil
+}
+
+// stderrLogger reports the projection's warnings while a replay runs. Only the methods the
+// projection calls are implemented.
+type stderrLogger struct {
+	logger.Logger
+	w io.Writer
+}
+
+func (l stderrLogger) Infof(template string, args ...interface{}) {}
+
+func (l stderrLogger) Warnf(template string, args ...interface{}) {
+	fmt.Fprintf(l.w, "warning: "+template+"\n", args...)
+}
+
+func (l stderrLogger) Errorf(template string, args ...interface{}) {
+	fmt.Fprintf(l.w, "error: "+template+"\n", args...)
+}
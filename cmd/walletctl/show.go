@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/novabankapp/wallet.data/domain"
+	"github.com/novabankapp/wallet.data/es/aggregate"
+	"github.com/pkg/errors"
+)
+
+func runShow(ctx context.Context, cfg *Config, args []string) error {
+	flags := flag.NewFlagSet("show", flag.ContinueOnError)
+	walletId := flags.String("wallet", "", "wallet id")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *walletId == "" {
+		return errors.New("-wallet is required")
+	}
+
+	db, err := cfg.EventStore()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	now := time.Now().UTC()
+	reader, snapshots, closeReader, err := cfg.historyReaders(db)
+	if err != nil {
+		return err
+	}
+	defer closeReader()
+	_, state, err := aggregate.LoadWalletAggregateAsOf(ctx, reader, snapshots, *walletId, aggregate.AsOf{Time: &now})
+	if err != nil {
+		return err
+	}
+	if !state.Exists {
+		return errors.Wrap(aggregate.ErrWalletNotCreated, *walletId)
+	}
+	return cfg.print(state, stateTable(state))
+}
+
+func stateTable(state *aggregate.WalletStateAsOf) table {
+	t := fields(
+		"wallet", state.Wallet.ID,
+		"exists", strconv.FormatBool(state.Exists),
+		"version", strconv.FormatInt(state.Version, 10),
+	)
+	if !state.Exists {
+		return t
+	}
+	t.add("last event at", state.LastEventAt.Format(time.RFC3339))
+	t.add("user", state.Wallet.UserId)
+	t.add("account", state.Wallet.AccountId)
+	t.add("balance", state.Wallet.Balance.String())
+	t.add("available balance", state.Wallet.AvailableBalance.String())
+	t.add("holds", state.Holds.String())
+	t.add("locked", strconv.FormatBool(state.WalletState.IsLocked))
+	t.add("blacklisted", strconv.FormatBool(state.WalletState.IsBlacklisted))
+	t.add("deleted", strconv.FormatBool(state.WalletState.IsDeleted))
+	for _, r := range state.WalletState.Restrictions {
+		t.add(string(r.Kind), restrictionSummary(r))
+	}
+	return t
+}
+
+// restrictionSummary is a restriction on one line: its reason code, authority and expiry
+// followed by the description.
+func restrictionSummary(r domain.Restriction) string {
+	parts := []string{string(r.ReasonCode)}
+	if r.Authority != "" {
+		parts = append(parts, "by "+r.Authority)
+	}
+	if r.ExpiresAt != nil {
+		parts = append(parts, "until "+r.ExpiresAt.Format(time.RFC3339))
+	}
+	if len(r.Evidence) > 0 {
+		parts = append(parts, "evidence "+strings.Join(r.Evidence, ","))
+	}
+	return strings.Join(parts, " ") + ": " + r.Description
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/novabankapp/wallet.data/aml"
+	"github.com/novabankapp/wallet.data/audit"
+	"github.com/novabankapp/wallet.data/domain"
+	"github.com/novabankapp/wallet.data/es/aggregate"
+	"github.com/novabankapp/wallet.data/es/commands"
+	"github.com/novabankapp/wallet.data/es/metadata"
+	"github.com/novabankapp/wallet.data/es/pii"
+	"github.com/novabankapp/wallet.data/es/readmodel"
+	"github.com/novabankapp/wallet.data/es/store"
+	"github.com/novabankapp/wallet.data/fraud"
+	"github.com/novabankapp/wallet.data/webhooks"
+	"github.com/pkg/errors"
+	"github.com/shopspring/decimal"
+)
+
+func runLock(ctx context.Context, cfg *Config, args []string) error {
+	return runRestriction(ctx, cfg, "lock", args, func(walletId string, r domain.Restriction) commands.Command {
+		return commands.LockWalletCommand{ID: walletId, Description: r.Description, ReasonCode: r.ReasonCode, Authority: r.Authority, Evidence: r.Evidence, ExpiresAt: r.ExpiresAt}
+	})
+}
+
+func runUnlock(ctx context.Context, cfg *Config, args []string) error {
+	return runStatusChange(ctx, cfg, "unlock", args, func(walletId, reason string) commands.Command {
+		return commands.UnlockWalletCommand{ID: walletId, Description: reason}
+	})
+}
+
+func runBlacklist(ctx context.Context, cfg *Config, args []string) error {
+	return runRestriction(ctx, cfg, "blacklist", args, func(walletId string, r domain.Restriction) commands.Command {
+		return commands.BlacklistWalletCommand{ID: walletId, Description: r.Description, ReasonCode: r.ReasonCode, Authority: r.Authority, Evidence: r.Evidence, ExpiresAt: r.ExpiresAt}
+	})
+}
+
+func runCredit(ctx context.Context, cfg *Config, args []string) error {
+	return runTransfer(ctx, cfg, "credit", "from", "wallet the amount is debited from", args,
+		func(walletId, counterparty string, amount decimal.Decimal, reason string) commands.Command {
+			return commands.CreditWalletCommand{ID: walletId, DebitWalletID: counterparty, Amount: amount, Description: reason}
+		})
+}
+
+func runDebit(ctx context.Context, cfg *Config, args []string) error {
+	return runTransfer(ctx, cfg, "debit", "to", "wallet the amount is credited to", args,
+		func(walletId, counterparty string, amount decimal.Decimal, reason string) commands.Command {
+			return commands.DebitWalletCommand{ID: walletId, CreditWalletID: counterparty, Amount: amount, Description: reason}
+		})
+}
+
+type erasureResult struct {
+	UserID string `json:"user_id"`
+	Erased bool   `json:"erased"`
+}
+
+// runEraseUser shreds the key of a user's personal data and redacts the user's wallets in
+// the read model, the audit trail, the AML cases, the fraud flags and the webhook log.
+func runEraseUser(ctx context.Context, cfg *Config, args []string) error {
+	flags := flag.NewFlagSet("erase-user", flag.ContinueOnError)
+	userId := flags.String("user", "", "user id")
+	reason := flags.String("reason", "", "why the data is erased, e.g. the erasure request (required)")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *userId == "" {
+		return errors.New("-user is required")
+	}
+	if *reason == "" {
+		return errors.New("-reason is required")
+	}
+	return dispatch(ctx, cfg, commands.EraseUserDataCommand{UserID: *userId, Description: *reason})
+}
+
+func runStatusChange(ctx context.Context, cfg *Config, name string, args []string, build func(walletId, reason string) commands.Command) error {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	walletId := flags.String("wallet", "", "wallet id")
+	reason := flags.String("reason", "", "why the operator made the change (required)")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *walletId == "" {
+		return errors.New("-wallet is required")
+	}
+	if *reason == "" {
+		return errors.New("-reason is required")
+	}
+	return dispatch(ctx, cfg, build(*walletId, *reason))
+}
+
+func runRestriction(ctx context.Context, cfg *Config, name string, args []string, build func(walletId string, r domain.Restriction) commands.Command) error {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	walletId := flags.String("wallet", "", "wallet id")
+	reason := flags.String("reason", "", "why the operator made the change (required)")
+	code := flags.String("code", string(domain.ReasonOther), "reason code, e.g. fraud_suspected, sanctions or court_order")
+	authority := flags.String("authority", "", "who imposed the restriction")
+	evidence := flags.String("evidence", "", "comma-separated references to the documents or cases behind it")
+	expires := flags.String("expires", "", "when the restriction is lifted by itself, RFC 3339 (default: never)")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *walletId == "" {
+		return errors.New("-wallet is required")
+	}
+	if *reason == "" {
+		return errors.New("-reason is required")
+	}
+	restriction := domain.Restriction{Description: *reason, ReasonCode: domain.ReasonCode(*code), Authority: *authority}
+	for _, reference := range strings.Split(*evidence, ",") {
+		if reference = strings.TrimSpace(reference); reference != "" {
+			restriction.Evidence = append(restriction.Evidence, reference)
+		}
+	}
+	if *expires != "" {
+		t, err := time.Parse(time.RFC3339, *expires)
+		if err != nil {
+			return errors.Wrap(err, "-expires")
+		}
+		restriction.ExpiresAt = &t
+	}
+	return dispatch(ctx, cfg, build(*walletId, restriction))
+}
+
+func runTransfer(ctx context.Context, cfg *Config, name, counterpartyFlag, counterpartyUsage string, args []string,
+	build func(walletId, counterparty string, amount decimal.Decimal, reason string) commands.Command) error {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	walletId := flags.String("wallet", "", "wallet id")
+	counterparty := flags.String(counterpartyFlag, "", counterpartyUsage)
+	value := flags.String("amount", "", "amount, e.g. 12.50")
+	reason := flags.String("reason", "", "why the operator made the change (required)")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *walletId == "" {
+		return errors.New("-wallet is required")
+	}
+	if *counterparty == "" {
+		return errors.Errorf("-%s is required", counterpartyFlag)
+	}
+	if *reason == "" {
+		return errors.New("-reason is required")
+	}
+	amount, err := decimal.NewFromString(*value)
+	if err != nil {
+		return errors.Wrap(err, "-amount")
+	}
+	return dispatch(ctx, cfg, build(*walletId, *counterparty, amount, *reason))
+}
+
+// dispatch runs an operator command through the same bus and executor the services use,
+// so it is validated, checked against the wallet's invariants and retried on conflicts.
+// Its events name the operator as their actor and have their personal data encrypted.
+func dispatch(ctx context.Context, cfg *Config, cmd commands.Command) error {
+	if cfg.Operator == "" {
+		return errors.Errorf("no operator: set operator or %s", envOperator)
+	}
+	bus, closeBus, err := newBus(cfg)
+	if err != nil {
+		return err
+	}
+	defer closeBus()
+
+	ctx = metadata.WithMetadata(ctx, metadata.Metadata{
+		ActorID:   cfg.Operator,
+		ActorType: metadata.ActorOperator,
+		Channel:   metadata.ChannelCLI,
+	})
+	result, err := bus.Dispatch(ctx, cmd)
+	if err != nil {
+		return err
+	}
+	if erase, ok := cmd.(commands.EraseUserDataCommand); ok {
+		return cfg.print(erasureResult{UserID: erase.UserID, Erased: true}, fields(
+			"command", cmd.CommandName(),
+			"user", erase.UserID,
+			"erased", "true",
+		))
+	}
+	return cfg.print(result, fields(
+		"command", cmd.CommandName(),
+		"wallet", result.WalletID,
+		"version", strconv.FormatInt(result.Version, 10),
+	))
+}
+
+// newBus opens the event store and Cassandra, which holds the personal data keys and the
+// copies an erasure redacts, and registers the wallet handlers on a bus. The returned func
+// closes what was opened.
+func newBus(cfg *Config) (*commands.Bus, func(), error) {
+	db, err := cfg.EventStore()
+	if err != nil {
+		return nil, nil, err
+	}
+	session, err := cfg.CassandraSession()
+	if err != nil {
+		db.Close()
+		return nil, nil, err
+	}
+	cipher := pii.NewCipher(pii.NewCassandraKeyStore(session))
+
+	executor := aggregate.NewWalletCommandExecutor(cipher.ProtectStore(store.NewESDBAggregateStore(db)))
+	executor.Snapshots = cipher.ProtectSnapshots(store.NewESDBSnapshotStore(db))
+	bus := commands.NewBus(commands.Causation())
+	commands.RegisterWalletHandlers(bus, executor)
+	commands.RegisterErasureHandler(bus, cipher.Keys, readmodel.NewWalletProjectionRepository(session),
+		audit.NewCassandraStore(session),
+		aml.NewCassandraCaseStore(session),
+		fraud.NewCassandraReviewStore(session),
+		webhooks.NewCassandraStore(session),
+	)
+	return bus, func() {
+		session.Close()
+		db.Close()
+	}, nil
+}
@@ -0,0 +1,64 @@
+package domain
+
+import "time"
+
+// RestrictionKind is what a restriction stops a wallet from doing.
+type RestrictionKind string
+
+const (
+	RestrictionLock      RestrictionKind = "lock"
+	RestrictionBlacklist RestrictionKind = "blacklist"
+)
+
+// ReasonCode says why a wallet is locked or blacklisted.
+type ReasonCode string
+
+const (
+	ReasonFraudSuspected   ReasonCode = "fraud_suspected"
+	ReasonAMLInvestigation ReasonCode = "aml_investigation"
+	ReasonSanctions        ReasonCode = "sanctions"
+	ReasonCourtOrder       ReasonCode = "court_order"
+	ReasonRegulatorRequest ReasonCode = "regulator_request"
+	ReasonCustomerRequest  ReasonCode = "customer_request"
+	ReasonLostOrStolen     ReasonCode = "lost_or_stolen"
+	ReasonOther            ReasonCode = "other"
+)
+
+// ReasonCodes are the known reason codes.
+var ReasonCodes = []ReasonCode{
+	ReasonFraudSuspected,
+	ReasonAMLInvestigation,
+	ReasonSanctions,
+	ReasonCourtOrder,
+	ReasonRegulatorRequest,
+	ReasonCustomerRequest,
+	ReasonLostOrStolen,
+	ReasonOther,
+}
+
+func (c ReasonCode) IsKnown() bool {
+	for _, known := range ReasonCodes {
+		if c == known {
+			return true
+		}
+	}
+	return false
+}
+
+// Restriction is an active lock or blacklisting of a wallet. Authority is who imposed
+// it, such as a regulator or a court, and Evidence references the documents or cases
+// behind it. A restriction with an ExpiresAt is lifted once that time has passed.
+type Restriction struct {
+	Kind        RestrictionKind `json:"kind"`
+	ReasonCode  ReasonCode      `json:"reason_code"`
+	Authority   string          `json:"authority,omitempty"`
+	Description string          `json:"description"`
+	Evidence    []string        `json:"evidence,omitempty"`
+	ImposedAt   time.Time       `json:"imposed_at"`
+	ExpiresAt   *time.Time      `json:"expires_at,omitempty"`
+}
+
+// Expired reports whether the restriction has an expiry that is not after now.
+func (r Restriction) Expired(now time.Time) bool {
+	return r.ExpiresAt != nil && !now.Before(*r.ExpiresAt)
+}
@@ -0,0 +1,34 @@
+package domain
+
+import "github.com/shopspring/decimal"
+
+type ScreeningOutcome string
+
+const (
+	// ScreeningBlocked commands were refused.
+	ScreeningBlocked ScreeningOutcome = "blocked"
+	// ScreeningQuarantined commands had their amount held on the wallet for review.
+	ScreeningQuarantined ScreeningOutcome = "quarantined"
+)
+
+// Screening is a watchlist hit on a credit or debit of a wallet.
+type Screening struct {
+	ID                   string           `json:"id"`
+	Outcome              ScreeningOutcome `json:"outcome"`
+	Command              string           `json:"command"`
+	CounterpartyWalletId string           `json:"counterparty_wallet_id"`
+	Amount               decimal.Decimal  `json:"amount"`
+	Provider             string           `json:"provider"`
+	// Matches are the watchlist entries that matched, as JSON. They name the parties
+	// screened, so they are personal data.
+	Matches     string `json:"matches"`
+	Description string `json:"description"`
+}
+
+// Quarantine is the amount a quarantined screening holds on a wallet until a review
+// releases or confirms it.
+type Quarantine struct {
+	ScreeningID          string          `json:"screening_id"`
+	Amount               decimal.Decimal `json:"amount"`
+	CounterpartyWalletId string          `json:"counterparty_wallet_id"`
+}
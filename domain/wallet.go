@@ -13,7 +13,7 @@ type Wallet struct {
 	Balance          decimal.Decimal `json:"balance"`
 	AvailableBalance decimal.Decimal `json:"available_balance"`
 	CreatedAt        time.Time
-	Lock             sync.RWMutex
+	Lock             sync.RWMutex `json:"-"`
 }
 
 func (w Wallet) IsNoSQLEntity() bool {
@@ -22,3 +22,8 @@ func (w Wallet) IsNoSQLEntity() bool {
 func (w *Wallet) GetBalance() (realBalance decimal.Decimal, availableBalance decimal.Decimal) {
 	return w.Balance, w.AvailableBalance
 }
+
+// GetHeldBalance returns the part of the balance reserved by holds.
+func (w *Wallet) GetHeldBalance() decimal.Decimal {
+	return w.Balance.Sub(w.AvailableBalance)
+}
@@ -2,6 +2,7 @@ package domain
 
 import (
 	"github.com/gocql/gocql"
+	"github.com/shopspring/decimal"
 	"reflect"
 )
 
@@ -11,6 +12,10 @@ type WalletState struct {
 	IsDeleted     bool       `json:"is_deleted"`
 	WalletId      string     `json:"wallet_id"`
 	ID            gocql.UUID `json:"id"`
+	// Restrictions are the active lock and blacklisting, at most one of each kind.
+	Restrictions []Restriction `json:"restrictions,omitempty"`
+	// Quarantines are the open quarantines, each holding its amount of the balance.
+	Quarantines []Quarantine `json:"quarantines,omitempty"`
 }
 
 func (w WalletState) IsNoSQLEntity() bool {
@@ -31,3 +36,82 @@ func (w *WalletState) CanTransact() bool {
 	}
 	return w.IsBlacklisted && w.IsLocked
 }
+
+// Restriction returns the active restriction of kind, or nil.
+func (w *WalletState) Restriction(kind RestrictionKind) *Restriction {
+	for i := range w.Restrictions {
+		if w.Restrictions[i].Kind == kind {
+			return &w.Restrictions[i]
+		}
+	}
+	return nil
+}
+
+// Restrict imposes r, replacing the restriction of its kind, and sets the matching flag.
+func (w *WalletState) Restrict(r Restriction) {
+	w.Lift(r.Kind)
+	w.Restrictions = append(w.Restrictions, r)
+	w.setRestricted(r.Kind, true)
+}
+
+// Lift removes the restriction of kind and clears the matching flag.
+func (w *WalletState) Lift(kind RestrictionKind) {
+	restrictions := make([]Restriction, 0, len(w.Restrictions))
+	for _, r := range w.Restrictions {
+		if r.Kind != kind {
+			restrictions = append(restrictions, r)
+		}
+	}
+	if len(restrictions) == 0 {
+		restrictions = nil
+	}
+	w.Restrictions = restrictions
+	w.setRestricted(kind, false)
+}
+
+func (w *WalletState) setRestricted(kind RestrictionKind, restricted bool) {
+	switch kind {
+	case RestrictionLock:
+		w.IsLocked = restricted
+	case RestrictionBlacklist:
+		w.IsBlacklisted = restricted
+	}
+}
+
+// Quarantine returns the open quarantine of a screening, or nil.
+func (w *WalletState) Quarantine(screeningID string) *Quarantine {
+	for i := range w.Quarantines {
+		if w.Quarantines[i].ScreeningID == screeningID {
+			return &w.Quarantines[i]
+		}
+	}
+	return nil
+}
+
+// Quarantined returns the amount the open quarantines hold.
+func (w *WalletState) Quarantined() decimal.Decimal {
+	total := decimal.Zero
+	for _, q := range w.Quarantines {
+		total = total.Add(q.Amount)
+	}
+	return total
+}
+
+// OpenQuarantine adds q to the open quarantines.
+func (w *WalletState) OpenQuarantine(q Quarantine) {
+	w.Quarantines = append(w.Quarantines, q)
+}
+
+// CloseQuarantine removes the quarantine of a screening.
+func (w *WalletState) CloseQuarantine(screeningID string) {
+	quarantines := make([]Quarantine, 0, len(w.Quarantines))
+	for _, q := range w.Quarantines {
+		if q.ScreeningID != screeningID {
+			quarantines = append(quarantines, q)
+		}
+	}
+	if len(quarantines) == 0 {
+		quarantines = nil
+	}
+	w.Quarantines = quarantines
+}
@@ -14,3 +14,7 @@ type WalletTransaction struct {
 	Description    string          `json:"description"`
 	ID             gocql.UUID      `json:"id"`
 }
+
+func (w WalletTransaction) IsNoSQLEntity() bool {
+	return true
+}
@@ -19,6 +19,7 @@ type WalletAggregate struct {
 	WalletState        *domain.WalletState
 	WalletLink         *domain.WalletLink
 	WalletTransactions *[]domain.WalletTransaction
+	created            bool
 }
 
 func NewWalletAggregateWithID(id string) *WalletAggregate {
@@ -29,17 +30,28 @@ func NewWalletAggregateWithID(id string) *WalletAggregate {
 	aggregate := NewWalletAggregate()
 	aggregate.SetID(id)
 	aggregate.Wallet.ID = id
+	aggregate.WalletState.WalletId = id
 	return aggregate
 }
 
 func NewWalletAggregate() *WalletAggregate {
-	walletAggregate := &WalletAggregate{Wallet: &domain.Wallet{}}
+	walletAggregate := &WalletAggregate{
+		Wallet:             &domain.Wallet{},
+		WalletState:        &domain.WalletState{},
+		WalletTransactions: &[]domain.WalletTransaction{},
+	}
 	base := es.NewAggregateBase(walletAggregate.When)
 	base.SetType(WalletAggregateType)
 	walletAggregate.AggregateBase = base
 	return walletAggregate
 }
 
+// PersonalDataSubject is the owner of the wallet, whose key encrypts the personal data in
+// the wallet's events.
+func (a *WalletAggregate) PersonalDataSubject() string {
+	return a.Wallet.UserId
+}
+
 func (a *WalletAggregate) When(evt es.Event) error {
 
 	switch evt.GetEventType() {
@@ -54,10 +66,22 @@ func (a *WalletAggregate) When(evt es.Event) error {
 		return a.onWalletBlacklisted(evt)
 	case event1.WalletLocked:
 		return a.onWalletLocked(evt)
+	case event1.WalletUnlocked:
+		return a.onWalletUnlocked(evt)
+	case event1.WalletUnBlacklisted:
+		return a.onWalletUnBlacklisted(evt)
+	case event1.WalletDeleted:
+		return a.onWalletDeleted(evt)
 	case event1.WalletCreditReleased:
 		return a.onWalletCreditReleased(evt)
 	case event1.WalletCreditReserved:
 		return a.onWalletCreditReserved(evt)
+	case event1.WalletScreened:
+		return a.onWalletScreened(evt)
+	case event1.WalletQuarantineReleased:
+		return a.onWalletQuarantineReleased(evt)
+	case event1.WalletQuarantineConfirmed:
+		return a.onWalletQuarantineConfirmed(evt)
 
 	default:
 		return es.ErrInvalidEventType
@@ -72,10 +96,11 @@ func (a *WalletAggregate) onWalletCreated(evt es.Event) error {
 
 	a.Wallet.AccountId = eventData.AccountId
 	a.Wallet.UserId = eventData.UserId
-	a.Wallet.CreatedAt = time.Now()
+	a.Wallet.CreatedAt = evt.GetTimeStamp()
 	a.Wallet.Balance = eventData.Amount
 	a.Wallet.ID = eventData.ID
 	a.Wallet.AvailableBalance = eventData.Amount
+	a.created = true
 
 	return nil
 }
@@ -86,7 +111,7 @@ func (a *WalletAggregate) onWalletCreditReleased(evt es.Event) error {
 		return errors.Wrap(err, "GetJsonData")
 	}
 
-	a.Wallet.Balance = a.Wallet.AvailableBalance.Add(eventData.Amount)
+	a.Wallet.AvailableBalance = a.Wallet.AvailableBalance.Add(eventData.Amount)
 	return nil
 }
 func (a *WalletAggregate) onWalletCreditReserved(evt es.Event) error {
@@ -95,7 +120,58 @@ func (a *WalletAggregate) onWalletCreditReserved(evt es.Event) error {
 		return errors.Wrap(err, "GetJsonData")
 	}
 
-	a.Wallet.Balance = a.Wallet.AvailableBalance.Sub(eventData.Amount)
+	a.Wallet.AvailableBalance = a.Wallet.AvailableBalance.Sub(eventData.Amount)
+	return nil
+}
+
+func (a *WalletAggregate) onWalletScreened(evt es.Event) error {
+	var eventData v1.WalletScreenedEvent
+	if err := evt.GetJsonData(&eventData); err != nil {
+		return errors.Wrap(err, "GetJsonData")
+	}
+
+	if eventData.Outcome == string(domain.ScreeningQuarantined) {
+		a.Wallet.AvailableBalance = a.Wallet.AvailableBalance.Sub(eventData.Amount)
+		a.WalletState.OpenQuarantine(domain.Quarantine{
+			ScreeningID:          eventData.ScreeningID,
+			Amount:               eventData.Amount,
+			CounterpartyWalletId: eventData.CounterpartyWalletId,
+		})
+	}
+	return nil
+}
+
+func (a *WalletAggregate) onWalletQuarantineReleased(evt es.Event) error {
+	var eventData v1.WalletQuarantineReleasedEvent
+	if err := evt.GetJsonData(&eventData); err != nil {
+		return errors.Wrap(err, "GetJsonData")
+	}
+
+	a.Wallet.AvailableBalance = a.Wallet.AvailableBalance.Add(eventData.Amount)
+	a.WalletState.CloseQuarantine(eventData.ScreeningID)
+	return nil
+}
+
+// onWalletQuarantineConfirmed takes the held amount off the balance; the available
+// balance already excludes it.
+func (a *WalletAggregate) onWalletQuarantineConfirmed(evt es.Event) error {
+	var eventData v1.WalletQuarantineConfirmedEvent
+	if err := evt.GetJsonData(&eventData); err != nil {
+		return errors.Wrap(err, "GetJsonData")
+	}
+
+	a.Wallet.Lock.Lock()
+	defer a.Wallet.Lock.Unlock()
+	a.Wallet.Balance = a.Wallet.Balance.Sub(eventData.Amount)
+	a.WalletState.CloseQuarantine(eventData.ScreeningID)
+	*a.WalletTransactions = append(*a.WalletTransactions, domain.WalletTransaction{
+		Amount:         eventData.Amount,
+		CreatedAt:      evt.GetTimeStamp(),
+		Description:    eventData.Description,
+		CreditWalletId: eventData.CreditWalletId,
+		DebitWalletId:  a.Wallet.ID,
+		ID:             GetTransactionID(evt),
+	})
 	return nil
 }
 
@@ -107,12 +183,14 @@ func (a *WalletAggregate) onWalletCredited(evt es.Event) error {
 	a.Wallet.Lock.Lock()
 	defer a.Wallet.Lock.Unlock()
 	a.Wallet.Balance = a.Wallet.Balance.Add(eventData.Amount)
+	a.Wallet.AvailableBalance = a.Wallet.AvailableBalance.Add(eventData.Amount)
 	*a.WalletTransactions = append(*a.WalletTransactions, domain.WalletTransaction{
 		DebitWalletId:  eventData.DebitWalletId,
 		CreditWalletId: a.Wallet.ID,
 		Amount:         eventData.Amount,
-		CreatedAt:      time.Now(),
+		CreatedAt:      evt.GetTimeStamp(),
 		Description:    eventData.Description,
+		ID:             GetTransactionID(evt),
 	})
 	return nil
 }
@@ -127,12 +205,14 @@ func (a *WalletAggregate) onWalletDebited(evt es.Event) error {
 	defer a.Wallet.Lock.Unlock()
 
 	a.Wallet.Balance = a.Wallet.Balance.Sub(eventData.Amount)
+	a.Wallet.AvailableBalance = a.Wallet.AvailableBalance.Sub(eventData.Amount)
 	*a.WalletTransactions = append(*a.WalletTransactions, domain.WalletTransaction{
 		Amount:         eventData.Amount,
-		CreatedAt:      time.Now(),
+		CreatedAt:      evt.GetTimeStamp(),
 		Description:    eventData.Description,
 		CreditWalletId: eventData.CreditWalletId,
 		DebitWalletId:  a.Wallet.ID,
+		ID:             GetTransactionID(evt),
 	})
 	return nil
 }
@@ -142,7 +222,7 @@ func (a *WalletAggregate) onWalletLocked(evt es.Event) error {
 	if err := evt.GetJsonData(&eventData); err != nil {
 		return errors.Wrap(err, "GetJsonData")
 	}
-	a.WalletState.IsLocked = true
+	a.WalletState.Restrict(newRestriction(domain.RestrictionLock, evt, eventData.ReasonCode, eventData.Authority, eventData.Description, eventData.Evidence, eventData.ExpiresAt))
 	return nil
 }
 
@@ -151,6 +231,51 @@ func (a *WalletAggregate) onWalletBlacklisted(evt es.Event) error {
 	if err := evt.GetJsonData(&eventData); err != nil {
 		return errors.Wrap(err, "GetJsonData")
 	}
-	a.WalletState.IsBlacklisted = true
+	a.WalletState.Restrict(newRestriction(domain.RestrictionBlacklist, evt, eventData.ReasonCode, eventData.Authority, eventData.Description, eventData.Evidence, eventData.ExpiresAt))
+	return nil
+}
+
+func (a *WalletAggregate) onWalletUnlocked(evt es.Event) error {
+	var eventData v1.WalletUnlockedEvent
+	if err := evt.GetJsonData(&eventData); err != nil {
+		return errors.Wrap(err, "GetJsonData")
+	}
+	a.WalletState.Lift(domain.RestrictionLock)
+	return nil
+}
+
+func (a *WalletAggregate) onWalletUnBlacklisted(evt es.Event) error {
+	var eventData v1.WalletUnBlacklistedEvent
+	if err := evt.GetJsonData(&eventData); err != nil {
+		return errors.Wrap(err, "GetJsonData")
+	}
+	a.WalletState.Lift(domain.RestrictionBlacklist)
+	return nil
+}
+
+// newRestriction is the restriction of kind imposed by evt. Events written before reason
+// codes were recorded have domain.ReasonOther.
+func newRestriction(kind domain.RestrictionKind, evt es.Event, reasonCode, authority, description string, evidence []string, expiresAt *time.Time) domain.Restriction {
+	code := domain.ReasonCode(reasonCode)
+	if code == "" {
+		code = domain.ReasonOther
+	}
+	return domain.Restriction{
+		Kind:        kind,
+		ReasonCode:  code,
+		Authority:   authority,
+		Description: description,
+		Evidence:    evidence,
+		ImposedAt:   evt.GetTimeStamp(),
+		ExpiresAt:   expiresAt,
+	}
+}
+
+func (a *WalletAggregate) onWalletDeleted(evt es.Event) error {
+	var eventData v1.WalletDeletedEvent
+	if err := evt.GetJsonData(&eventData); err != nil {
+		return errors.Wrap(err, "GetJsonData")
+	}
+	a.WalletState.IsDeleted = true
 	return nil
 }
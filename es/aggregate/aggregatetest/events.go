@@ -0,0 +1,96 @@
+package aggregatetest
+
+import (
+	eventsV1 "github.com/novabankapp/wallet.data/es/events/v1"
+	"github.com/shopspring/decimal"
+)
+
+// Event is an event as a scenario sees it: its type and its payload. Version, id,
+// timestamp and metadata are left out on purpose; scenarios are about what happened,
+// not about when or under which trace.
+type Event struct {
+	Type string
+	Data interface{}
+}
+
+func WalletCreated(amount decimal.Decimal, description, userId, accountId, id string) Event {
+	return Event{Type: eventsV1.WalletCreated, Data: eventsV1.WalletCreatedEvent{
+		Amount:      amount,
+		Description: description,
+		UserId:      userId,
+		AccountId:   accountId,
+		ID:          id,
+	}}
+}
+
+func WalletCredited(debitWalletId string, amount decimal.Decimal, description string) Event {
+	return Event{Type: eventsV1.WalletCredited, Data: eventsV1.WalletCreditedEvent{
+		Amount:        amount,
+		DebitWalletId: debitWalletId,
+		Description:   description,
+	}}
+}
+
+func WalletDebited(creditWalletId string, amount decimal.Decimal, description string) Event {
+	return Event{Type: eventsV1.WalletDebited, Data: eventsV1.WalletDebitedEvent{
+		Amount:         amount,
+		CreditWalletId: creditWalletId,
+		Description:    description,
+	}}
+}
+
+func WalletCreditReserved(amount decimal.Decimal, description string) Event {
+	return Event{Type: eventsV1.WalletCreditReserved, Data: eventsV1.WalletCreditReservedEvent{
+		Amount:      amount,
+		Description: description,
+	}}
+}
+
+func WalletCreditReleased(amount decimal.Decimal, description string) Event {
+	return Event{Type: eventsV1.WalletCreditReleased, Data: eventsV1.WalletCreditReleasedEvent{
+		Amount:      amount,
+		Description: description,
+	}}
+}
+
+func WalletScreened(data eventsV1.WalletScreenedEvent) Event {
+	return Event{Type: eventsV1.WalletScreened, Data: data}
+}
+
+func WalletQuarantineReleased(data eventsV1.WalletQuarantineReleasedEvent) Event {
+	return Event{Type: eventsV1.WalletQuarantineReleased, Data: data}
+}
+
+func WalletQuarantineConfirmed(data eventsV1.WalletQuarantineConfirmedEvent) Event {
+	return Event{Type: eventsV1.WalletQuarantineConfirmed, Data: data}
+}
+
+func WalletLocked(data eventsV1.WalletLockedEvent) Event {
+	return Event{Type: eventsV1.WalletLocked, Data: data}
+}
+
+func WalletUnlocked(description string) Event {
+	return Event{Type: eventsV1.WalletUnlocked, Data: eventsV1.WalletUnlockedEvent{Description: description}}
+}
+
+func WalletBlacklisted(data eventsV1.WalletBlacklistedEvent) Event {
+	return Event{Type: eventsV1.WalletBlacklisted, Data: data}
+}
+
+func WalletUnBlacklisted(description string) Event {
+	return Event{Type: eventsV1.WalletUnBlacklisted, Data: eventsV1.WalletUnBlacklistedEvent{Description: description}}
+}
+
+// WalletLockExpired and WalletBlacklistExpired are the lifts of restrictions whose expiry
+// has passed.
+func WalletLockExpired(description string) Event {
+	return Event{Type: eventsV1.WalletUnlocked, Data: eventsV1.WalletUnlockedEvent{Description: description, Expired: true}}
+}
+
+func WalletBlacklistExpired(description string) Event {
+	return Event{Type: eventsV1.WalletUnBlacklisted, Data: eventsV1.WalletUnBlacklistedEvent{Description: description, Expired: true}}
+}
+
+func WalletDeleted(description string) Event {
+	return Event{Type: eventsV1.WalletDeleted, Data: eventsV1.WalletDeletedEvent{Description: description}}
+}
@@ -0,0 +1,201 @@
+// Package aggregatetest runs given/when/then scenarios against a WalletAggregate:
+// given a history of events, when a command is executed, then exactly these events are
+// raised, or this error is returned and nothing is raised.
+package aggregatetest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	es "github.com/novabankapp/common.data/eventstore"
+	"github.com/novabankapp/wallet.data/es/aggregate"
+	"github.com/pkg/errors"
+	"github.com/shopspring/decimal"
+)
+
+type Scenario struct {
+	t         testing.TB
+	aggregate *aggregate.WalletAggregate
+	executed  bool
+	err       error
+}
+
+// ForWallet starts a scenario for a wallet aggregate with the given id.
+func ForWallet(t testing.TB, id string) *Scenario {
+	t.Helper()
+	wallet := aggregate.NewWalletAggregateWithID(id)
+	if wallet == nil {
+		t.Fatalf("aggregatetest: invalid wallet id %q", id)
+	}
+	return &Scenario{t: t, aggregate: wallet}
+}
+
+// Given replays the history the command runs against, as if loaded from the store.
+func (s *Scenario) Given(history ...Event) *Scenario {
+	s.t.Helper()
+	if s.executed {
+		s.t.Fatal("aggregatetest: Given called after When")
+	}
+	for i, e := range history {
+		evt, err := s.toEvent(e)
+		if err != nil {
+			s.t.Fatalf("aggregatetest: given event %d (%s): %v", i, e.Type, err)
+		}
+		evt.SetVersion(s.aggregate.GetVersion() + 1)
+		if err := s.aggregate.RaiseEvent(evt); err != nil {
+			s.t.Fatalf("aggregatetest: given event %d (%s): %v", i, e.Type, err)
+		}
+	}
+	return s
+}
+
+// When executes the command under test.
+func (s *Scenario) When(command func(ctx context.Context, wallet *aggregate.WalletAggregate) error) *Scenario {
+	s.t.Helper()
+	if s.executed {
+		s.t.Fatal("aggregatetest: When called twice")
+	}
+	s.executed = true
+	s.err = command(context.Background(), s.aggregate)
+	return s
+}
+
+// Then asserts that the command succeeded and raised exactly the expected events, in order.
+func (s *Scenario) Then(expected ...Event) *Scenario {
+	s.t.Helper()
+	s.mustHaveExecuted()
+	if s.err != nil {
+		s.t.Fatalf("expected events, got error: %v", s.err)
+	}
+
+	raised := s.aggregate.GetUncommittedEvents()
+	for i := 0; i < len(expected) || i < len(raised); i++ {
+		switch {
+		case i >= len(raised):
+			s.t.Errorf("event %d: expected %s, nothing raised", i, expected[i].Type)
+		case i >= len(expected):
+			s.t.Errorf("event %d: unexpected %s %s", i, raised[i].GetEventType(), raised[i].GetString())
+		default:
+			if diff := s.compare(expected[i], raised[i]); diff != "" {
+				s.t.Errorf("event %d: %s", i, diff)
+			}
+		}
+	}
+	return s
+}
+
+// ThenError asserts that the command failed with an error matching target (errors.Is)
+// and left the aggregate without uncommitted events.
+func (s *Scenario) ThenError(target error) *Scenario {
+	s.t.Helper()
+	s.mustHaveExecuted()
+	if s.err == nil {
+		s.t.Fatalf("expected error %v, command succeeded", target)
+	}
+	if !errors.Is(s.err, target) {
+		s.t.Errorf("expected error %v, got %v", target, s.err)
+	}
+	for _, evt := range s.aggregate.GetUncommittedEvents() {
+		s.t.Errorf("unexpected %s raised by a failing command", evt.GetEventType())
+	}
+	return s
+}
+
+// ThenState hands the aggregate to check for assertions on its state after the command.
+func (s *Scenario) ThenState(check func(t testing.TB, wallet *aggregate.WalletAggregate)) *Scenario {
+	s.t.Helper()
+	s.mustHaveExecuted()
+	check(s.t, s.aggregate)
+	return s
+}
+
+func (s *Scenario) mustHaveExecuted() {
+	s.t.Helper()
+	if !s.executed {
+		s.t.Fatal("aggregatetest: Then called before When")
+	}
+}
+
+func (s *Scenario) toEvent(e Event) (es.Event, error) {
+	evt := es.NewBaseEvent(s.aggregate, e.Type)
+	if err := evt.SetJsonData(e.Data); err != nil {
+		return es.Event{}, err
+	}
+	return evt, nil
+}
+
+func (s *Scenario) compare(expected Event, raised es.Event) string {
+	if raised.GetEventType() != expected.Type {
+		return fmt.Sprintf("expected %s, raised %s", expected.Type, raised.GetEventType())
+	}
+	if raised.GetAggregateID() != s.aggregate.GetID() {
+		return fmt.Sprintf("%s raised for aggregate %s, expected %s", expected.Type, raised.GetAggregateID(), s.aggregate.GetID())
+	}
+
+	want, err := payload(expected.Data)
+	if err != nil {
+		return fmt.Sprintf("encode expected %s: %v", expected.Type, err)
+	}
+	var got map[string]interface{}
+	if err := raised.GetJsonData(&got); err != nil {
+		return fmt.Sprintf("decode raised %s: %v", expected.Type, err)
+	}
+
+	var diffs []string
+	for _, key := range keys(want, got) {
+		if !sameValue(want[key], got[key]) {
+			diffs = append(diffs, fmt.Sprintf("%s: expected %v, raised %v", key, want[key], got[key]))
+		}
+	}
+	if len(diffs) > 0 {
+		return expected.Type + " " + strings.Join(diffs, "; ")
+	}
+	return ""
+}
+
+func payload(data interface{}) (map[string]interface{}, error) {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	var result map[string]interface{}
+	if err := json.Unmarshal(raw, &result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func keys(maps ...map[string]interface{}) []string {
+	seen := make(map[string]bool)
+	var result []string
+	for _, m := range maps {
+		for key := range m {
+			if !seen[key] {
+				seen[key] = true
+				result = append(result, key)
+			}
+		}
+	}
+	sort.Strings(result)
+	return result
+}
+
+// sameValue compares decoded JSON values; amounts compare by value, so "10" equals "10.00".
+func sameValue(want, got interface{}) bool {
+	if reflect.DeepEqual(want, got) {
+		return true
+	}
+	ws, wok := want.(string)
+	gs, gok := got.(string)
+	if !wok || !gok {
+		return false
+	}
+	wd, werr := decimal.NewFromString(ws)
+	gd, gerr := decimal.NewFromString(gs)
+	return werr == nil && gerr == nil && wd.Equal(gd)
+}
@@ -0,0 +1,134 @@
+package aggregatetest_test
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/novabankapp/wallet.data/es/aggregate"
+	. "github.com/novabankapp/wallet.data/es/aggregate/aggregatetest"
+	"github.com/pkg/errors"
+	"github.com/shopspring/decimal"
+)
+
+const walletID = "w-1"
+
+// recorder is a testing.TB that records failures instead of failing the real test.
+type recorder struct {
+	testing.TB
+	failures []string
+	fatal    bool
+}
+
+func (r *recorder) Helper() {}
+
+func (r *recorder) Errorf(format string, args ...interface{}) {
+	r.failures = append(r.failures, fmt.Sprintf(format, args...))
+}
+
+func (r *recorder) Fatalf(format string, args ...interface{}) {
+	r.Errorf(format, args...)
+	r.fatal = true
+	runtime.Goexit()
+}
+
+func (r *recorder) Fatal(args ...interface{}) {
+	r.Fatalf("%s", fmt.Sprint(args...))
+}
+
+// run plays scenario against a recorder and returns what it reported.
+func run(t *testing.T, scenario func(t testing.TB)) *recorder {
+	r := &recorder{TB: t}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		scenario(r)
+	}()
+	<-done
+	return r
+}
+
+func created() Event {
+	return WalletCreated(decimal.NewFromInt(100), "opening", "user-1", "account-1", walletID)
+}
+
+func credit(value string) func(context.Context, *aggregate.WalletAggregate) error {
+	return func(ctx context.Context, a *aggregate.WalletAggregate) error {
+		return a.CreditWallet(ctx, "w-2", decimal.RequireFromString(value), "top up")
+	}
+}
+
+func TestScenarioPasses(t *testing.T) {
+	r := run(t, func(t testing.TB) {
+		ForWallet(t, walletID).
+			Given(created()).
+			When(credit("10")).
+			Then(WalletCredited("w-2", decimal.RequireFromString("10.00"), "top up")).
+			ThenState(func(t testing.TB, a *aggregate.WalletAggregate) {
+				if a.GetVersion() != 1 {
+					t.Errorf("version %d", a.GetVersion())
+				}
+			})
+	})
+	if len(r.failures) != 0 {
+		t.Fatalf("reported %v", r.failures)
+	}
+}
+
+func TestScenarioReportsDifferences(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		scenario func(t testing.TB)
+		want     string
+	}{
+		{"a different payload", func(t testing.TB) {
+			ForWallet(t, walletID).Given(created()).When(credit("10")).Then(WalletCredited("w-2", decimal.NewFromInt(11), "top up"))
+		}, "Amount: expected 11, raised 10"},
+		{"a different event", func(t testing.TB) {
+			ForWallet(t, walletID).Given(created()).When(credit("10")).Then(WalletDebited("w-2", decimal.NewFromInt(10), "top up"))
+		}, "expected V1_WALLET_DEBITED"},
+		{"a missing event", func(t testing.TB) {
+			ForWallet(t, walletID).Given(created()).When(credit("10")).Then(WalletCredited("w-2", decimal.NewFromInt(10), "top up"), WalletDeleted("closed"))
+		}, "nothing raised"},
+		{"an unexpected event", func(t testing.TB) {
+			ForWallet(t, walletID).Given(created()).When(credit("10")).Then()
+		}, "unexpected"},
+		{"an error instead of events", func(t testing.TB) {
+			ForWallet(t, walletID).When(credit("10")).Then(WalletCredited("w-2", decimal.NewFromInt(10), "top up"))
+		}, "got error"},
+		{"success instead of an error", func(t testing.TB) {
+			ForWallet(t, walletID).Given(created()).When(credit("10")).ThenError(aggregate.ErrWalletLocked)
+		}, "command succeeded"},
+		{"a different error", func(t testing.TB) {
+			ForWallet(t, walletID).When(credit("10")).ThenError(aggregate.ErrWalletLocked)
+		}, "got " + aggregate.ErrWalletNotCreated.Error()},
+		{"Then before When", func(t testing.TB) {
+			ForWallet(t, walletID).Then()
+		}, "Then called before When"},
+		{"Given after When", func(t testing.TB) {
+			ForWallet(t, walletID).When(credit("10")).Given(created())
+		}, "Given called after When"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			r := run(t, tc.scenario)
+			if !strings.Contains(strings.Join(r.failures, "\n"), tc.want) {
+				t.Fatalf("reported %q, want %q", r.failures, tc.want)
+			}
+		})
+	}
+}
+
+func TestScenarioErrorMatchesWrappedErrors(t *testing.T) {
+	r := run(t, func(t testing.TB) {
+		ForWallet(t, walletID).
+			When(func(ctx context.Context, a *aggregate.WalletAggregate) error {
+				return errors.Wrap(aggregate.ErrWalletLocked, "DebitWallet")
+			}).
+			ThenError(aggregate.ErrWalletLocked)
+	})
+	if len(r.failures) != 0 {
+		t.Fatalf("reported %v", r.failures)
+	}
+}
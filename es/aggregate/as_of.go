@@ -0,0 +1,161 @@
+package aggregate
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	es "github.com/novabankapp/common.data/eventstore"
+	"github.com/novabankapp/wallet.data/constants"
+	"github.com/novabankapp/wallet.data/domain"
+	"github.com/novabankapp/wallet.data/es/store"
+	"github.com/novabankapp/wallet.data/tracing"
+	"github.com/pkg/errors"
+	"github.com/shopspring/decimal"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+var ErrInvalidAsOf = errors.New("as-of query needs a timestamp or an event version")
+
+// AsOf selects a point in a wallet's history. When both are set the earlier point wins.
+// Time is inclusive: events recorded at exactly Time are part of the state.
+type AsOf struct {
+	Time    *time.Time
+	Version *int64
+}
+
+func (p AsOf) includes(evt es.Event) bool {
+	if p.Version != nil && evt.GetVersion() > *p.Version {
+		return false
+	}
+	if p.Time != nil && evt.GetTimeStamp().After(*p.Time) {
+		return false
+	}
+	return true
+}
+
+func (p AsOf) includesSnapshot(snapshot store.Snapshot) bool {
+	if p.Version != nil && snapshot.Version > *p.Version {
+		return false
+	}
+	if p.Time != nil && snapshot.Timestamp.After(*p.Time) {
+		return false
+	}
+	return true
+}
+
+// WalletStateAsOf is a wallet as it was at a point in its history.
+type WalletStateAsOf struct {
+	Wallet      *domain.Wallet      `json:"wallet"`
+	WalletState *domain.WalletState `json:"wallet_state"`
+	Holds       decimal.Decimal     `json:"holds"`
+	Version     int64               `json:"version"`
+	LastEventAt time.Time           `json:"last_event_at"`
+	Exists      bool                `json:"exists"`
+}
+
+// walletSnapshotState is the aggregate state kept in a store.Snapshot.
+type walletSnapshotState struct {
+	Wallet             *domain.Wallet             `json:"wallet"`
+	WalletState        *domain.WalletState        `json:"wallet_state"`
+	WalletTransactions []domain.WalletTransaction `json:"wallet_transactions"`
+}
+
+// SaveWalletSnapshot stores the current state of a loaded wallet aggregate. The wallet's
+// owner is the snapshot's Subject, so a protecting store can encrypt it.
+func SaveWalletSnapshot(ctx context.Context, snapshots store.SnapshotStore, wallet *WalletAggregate, lastEventAt time.Time) error {
+	state, err := json.Marshal(walletSnapshotState{
+		Wallet:             wallet.Wallet,
+		WalletState:        wallet.WalletState,
+		WalletTransactions: *wallet.WalletTransactions,
+	})
+	if err != nil {
+		return errors.Wrap(err, "json.Marshal")
+	}
+	return snapshots.SaveSnapshot(ctx, store.Snapshot{
+		StreamID:  wallet.GetID(),
+		Version:   wallet.GetVersion(),
+		Timestamp: lastEventAt,
+		State:     state,
+		Subject:   wallet.PersonalDataSubject(),
+	})
+}
+
+// LoadWalletAggregateAsOf replays a wallet's stream up to asOf, starting from the newest
+// snapshot before that point when snapshots is not nil.
+func LoadWalletAggregateAsOf(ctx context.Context,
+	events store.EventReader,
+	snapshots store.SnapshotStore,
+	aggregateID string,
+	asOf AsOf) (*WalletAggregate, *WalletStateAsOf, error) {
+	ctx, span := tracing.StartSpan(ctx, "LoadWalletAggregateAsOf")
+	defer span.End()
+	span.SetAttributes(attribute.String(constants.AggregateID, aggregateID))
+
+	if asOf.Time == nil && asOf.Version == nil {
+		return nil, nil, ErrInvalidAsOf
+	}
+
+	wallet := NewWalletAggregateWithID(aggregateID)
+	result := &WalletStateAsOf{}
+
+	if snapshots != nil {
+		snapshot, err := snapshots.LoadSnapshot(ctx, wallet.GetID(), asOf.includesSnapshot)
+		if err != nil {
+			tracing.TraceErr(span, err)
+			return nil, nil, errors.Wrap(err, "LoadSnapshot")
+		}
+		if snapshot != nil {
+			var state walletSnapshotState
+			if err := json.Unmarshal(snapshot.State, &state); err != nil {
+				tracing.TraceErr(span, err)
+				return nil, nil, errors.Wrap(err, "json.Unmarshal")
+			}
+			restoreWalletSnapshot(wallet, state)
+			wallet.Version = snapshot.Version
+			result.LastEventAt = snapshot.Timestamp
+			result.Exists = true
+		}
+	}
+
+	stream, err := events.ReadEvents(ctx, wallet.GetID(), wallet.GetVersion()+1)
+	if err != nil {
+		tracing.TraceErr(span, err)
+		return nil, nil, errors.Wrap(err, "ReadEvents")
+	}
+	for _, evt := range stream {
+		if !asOf.includes(evt) {
+			break
+		}
+		if err := wallet.RaiseEvent(evt); err != nil {
+			tracing.TraceErr(span, err)
+			return nil, nil, errors.Wrapf(err, "RaiseEvent %s", evt.GetEventID())
+		}
+		result.LastEventAt = evt.GetTimeStamp()
+		result.Exists = true
+	}
+
+	result.Wallet = wallet.Wallet
+	result.WalletState = wallet.WalletState
+	result.Holds = wallet.Wallet.GetHeldBalance()
+	result.Version = wallet.GetVersion()
+	return wallet, result, nil
+}
+
+func restoreWalletSnapshot(wallet *WalletAggregate, state walletSnapshotState) {
+	if state.Wallet != nil {
+		wallet.Wallet.ID = state.Wallet.ID
+		wallet.Wallet.UserId = state.Wallet.UserId
+		wallet.Wallet.AccountId = state.Wallet.AccountId
+		wallet.Wallet.Balance = state.Wallet.Balance
+		wallet.Wallet.AvailableBalance = state.Wallet.AvailableBalance
+		wallet.Wallet.CreatedAt = state.Wallet.CreatedAt
+		wallet.created = true
+	}
+	if state.WalletState != nil {
+		*wallet.WalletState = *state.WalletState
+	}
+	if state.WalletTransactions != nil {
+		*wallet.WalletTransactions = state.WalletTransactions
+	}
+}
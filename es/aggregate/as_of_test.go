@@ -0,0 +1,166 @@
+package aggregate_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/novabankapp/wallet.data/es/aggregate"
+	"github.com/novabankapp/wallet.data/es/store"
+	"github.com/pkg/errors"
+)
+
+// history runs a wallet through create, four credits and a debit (versions 0 to 5),
+// snapshotting every third event.
+func history(t *testing.T) *store.MemoryEventStore {
+	t.Helper()
+	db := store.NewMemoryEventStore()
+	executor := aggregate.NewWalletCommandExecutor(db)
+	executor.Snapshots = db
+	executor.SnapshotEvery = 3
+
+	commands := []func(context.Context, *aggregate.WalletAggregate) error{
+		func(ctx context.Context, a *aggregate.WalletAggregate) error {
+			return a.CreateWallet(ctx, amount("100"), "opening", "user-1", "account-1", walletID)
+		},
+		func(ctx context.Context, a *aggregate.WalletAggregate) error {
+			return a.CreditWallet(ctx, otherID, amount("10"), "first")
+		},
+		func(ctx context.Context, a *aggregate.WalletAggregate) error {
+			return a.CreditWallet(ctx, otherID, amount("20"), "second")
+		},
+		func(ctx context.Context, a *aggregate.WalletAggregate) error {
+			return a.CreditWallet(ctx, otherID, amount("30"), "third")
+		},
+		func(ctx context.Context, a *aggregate.WalletAggregate) error {
+			return a.CreditWallet(ctx, otherID, amount("40"), "fourth")
+		},
+		func(ctx context.Context, a *aggregate.WalletAggregate) error {
+			return a.DebitWallet(ctx, otherID, amount("50"), "rent")
+		},
+	}
+	for _, command := range commands {
+		if _, err := executor.Execute(context.Background(), walletID, "test", command); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return db
+}
+
+func version(v int64) aggregate.AsOf {
+	return aggregate.AsOf{Version: &v}
+}
+
+func TestExecutorSnapshotsOnCadence(t *testing.T) {
+	db := history(t)
+	streamID := aggregate.NewWalletAggregateWithID(walletID).GetID()
+
+	var versions []int64
+	for v := int64(0); v <= 5; v++ {
+		snapshot, err := db.LoadSnapshot(context.Background(), streamID, func(s store.Snapshot) bool { return s.Version == v })
+		if err != nil {
+			t.Fatal(err)
+		}
+		if snapshot != nil {
+			versions = append(versions, snapshot.Version)
+		}
+	}
+	if len(versions) != 2 || versions[0] != 2 || versions[1] != 5 {
+		t.Fatalf("snapshots at %v, want [2 5]", versions)
+	}
+
+	snapshot, err := db.LoadSnapshot(context.Background(), streamID, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var state struct {
+		WalletTransactions []json.RawMessage `json:"wallet_transactions"`
+	}
+	if err := json.Unmarshal(snapshot.State, &state); err != nil {
+		t.Fatal(err)
+	}
+	if len(state.WalletTransactions) != 5 {
+		t.Fatalf("snapshot holds %d transactions, want 5", len(state.WalletTransactions))
+	}
+}
+
+func TestLoadWalletAggregateAsOfVersion(t *testing.T) {
+	db := history(t)
+	ctx := context.Background()
+
+	for v, balance := range []string{"100", "110", "130", "160", "200", "150"} {
+		replayed, replayedState, err := aggregate.LoadWalletAggregateAsOf(ctx, db, nil, walletID, version(int64(v)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		fromSnapshot, snapshotState, err := aggregate.LoadWalletAggregateAsOf(ctx, db, db, walletID, version(int64(v)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for name, state := range map[string]*aggregate.WalletStateAsOf{"replay": replayedState, "snapshot": snapshotState} {
+			if !state.Exists || state.Version != int64(v) || !state.Wallet.Balance.Equal(amount(balance)) {
+				t.Errorf("%s at version %d: exists %v, version %d, balance %s, want %s", name, v, state.Exists, state.Version, state.Wallet.Balance, balance)
+			}
+		}
+		if len(*replayed.WalletTransactions) != v || len(*fromSnapshot.WalletTransactions) != v {
+			t.Errorf("version %d: %d replayed and %d snapshot transactions", v, len(*replayed.WalletTransactions), len(*fromSnapshot.WalletTransactions))
+		}
+		if !replayedState.LastEventAt.Equal(snapshotState.LastEventAt) {
+			t.Errorf("version %d: last event at %s and %s", v, replayedState.LastEventAt, snapshotState.LastEventAt)
+		}
+	}
+}
+
+func TestLoadWalletAggregateAsOfTimeCrossesSnapshot(t *testing.T) {
+	db := history(t)
+	ctx := context.Background()
+	events, err := db.ReadEvents(ctx, aggregate.NewWalletAggregateWithID(walletID).GetID(), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Starting from a snapshot must give the same wallet as replaying every event.
+	for _, evt := range events {
+		at := evt.GetTimeStamp()
+		replayed, replayedState, err := aggregate.LoadWalletAggregateAsOf(ctx, db, nil, walletID, aggregate.AsOf{Time: &at})
+		if err != nil {
+			t.Fatal(err)
+		}
+		fromSnapshot, snapshotState, err := aggregate.LoadWalletAggregateAsOf(ctx, db, db, walletID, aggregate.AsOf{Time: &at})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if replayedState.Version < evt.GetVersion() || snapshotState.Version != replayedState.Version {
+			t.Fatalf("as of %s: replayed version %d, snapshot version %d", at, replayedState.Version, snapshotState.Version)
+		}
+		if !snapshotState.Wallet.Balance.Equal(replayedState.Wallet.Balance) {
+			t.Fatalf("as of %s: replayed balance %s, snapshot balance %s", at, replayedState.Wallet.Balance, snapshotState.Wallet.Balance)
+		}
+		replayedTransactions, snapshotTransactions := *replayed.WalletTransactions, *fromSnapshot.WalletTransactions
+		if len(snapshotTransactions) != len(replayedTransactions) {
+			t.Fatalf("as of %s: %d replayed and %d snapshot transactions", at, len(replayedTransactions), len(snapshotTransactions))
+		}
+		for i := range replayedTransactions {
+			if snapshotTransactions[i].Description != replayedTransactions[i].Description ||
+				!snapshotTransactions[i].Amount.Equal(replayedTransactions[i].Amount) {
+				t.Fatalf("as of %s: transaction %d is %+v, want %+v", at, i, snapshotTransactions[i], replayedTransactions[i])
+			}
+		}
+	}
+}
+
+func TestLoadWalletAggregateAsOfErrors(t *testing.T) {
+	db := store.NewMemoryEventStore()
+	ctx := context.Background()
+
+	if _, _, err := aggregate.LoadWalletAggregateAsOf(ctx, db, db, walletID, aggregate.AsOf{}); !errors.Is(err, aggregate.ErrInvalidAsOf) {
+		t.Fatalf("got %v, want %v", err, aggregate.ErrInvalidAsOf)
+	}
+	_, state, err := aggregate.LoadWalletAggregateAsOf(ctx, db, db, walletID, version(3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state.Exists {
+		t.Fatalf("missing wallet reported as %+v", state)
+	}
+}
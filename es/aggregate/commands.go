@@ -2,19 +2,26 @@ package aggregate
 
 import (
 	"context"
-	"github.com/novabankapp/common.infrastructure/tracing"
+	"fmt"
 	"github.com/novabankapp/wallet.data/constants"
+	"github.com/novabankapp/wallet.data/domain"
 	eventsV1 "github.com/novabankapp/wallet.data/es/events/v1"
-	"github.com/opentracing/opentracing-go"
-	"github.com/opentracing/opentracing-go/log"
+	"github.com/novabankapp/wallet.data/tracing"
 	"github.com/pkg/errors"
 	"github.com/shopspring/decimal"
+	"go.opentelemetry.io/otel/attribute"
+	"time"
 )
 
 func (a *WalletAggregate) CreateWallet(ctx context.Context, amount decimal.Decimal, description, userId, accountId, eventId string) error {
-	span, _ := opentracing.StartSpanFromContext(ctx, "WalletAggregate.CreateWallet")
-	defer span.Finish()
-	span.LogFields(log.String(constants.AggregateID, a.GetID()))
+	ctx, span := tracing.StartSpan(ctx, "WalletAggregate.CreateWallet")
+	defer span.End()
+	span.SetAttributes(attribute.String(constants.AggregateID, a.GetID()))
+
+	if err := a.checkCanCreate(amount); err != nil {
+		tracing.TraceErr(span, err)
+		return err
+	}
 
 	event, err := eventsV1.NewWalletCreatedEvent(a, amount, description, userId, accountId, eventId)
 	if err != nil {
@@ -22,7 +29,7 @@ func (a *WalletAggregate) CreateWallet(ctx context.Context, amount decimal.Decim
 		return errors.Wrap(err, "NewWalletCreatedEvent")
 	}
 
-	if err := event.SetMetadata(tracing.ExtractTextMapCarrier(span.Context())); err != nil {
+	if err := event.SetMetadata(eventMetadata(ctx)); err != nil {
 		tracing.TraceErr(span, err)
 		return errors.Wrap(err, "SetMetadata")
 	}
@@ -34,9 +41,14 @@ func (a *WalletAggregate) CreditWallet(ctx context.Context,
 	debitWalletId string,
 	amount decimal.Decimal,
 	description string) error {
-	span, _ := opentracing.StartSpanFromContext(ctx, "WalletAggregate.CreditWallet")
-	defer span.Finish()
-	span.LogFields(log.String(constants.AggregateID, a.GetID()))
+	ctx, span := tracing.StartSpan(ctx, "WalletAggregate.CreditWallet")
+	defer span.End()
+	span.SetAttributes(attribute.String(constants.AggregateID, a.GetID()))
+
+	if err := a.checkCanCredit(amount); err != nil {
+		tracing.TraceErr(span, err)
+		return err
+	}
 
 	event, err := eventsV1.NewWalletCreditEvent(a, debitWalletId, amount, description)
 	if err != nil {
@@ -44,7 +56,7 @@ func (a *WalletAggregate) CreditWallet(ctx context.Context,
 		return errors.Wrap(err, "NewWalletCreditEvent")
 	}
 
-	if err := event.SetMetadata(tracing.ExtractTextMapCarrier(span.Context())); err != nil {
+	if err := event.SetMetadata(eventMetadata(ctx)); err != nil {
 		tracing.TraceErr(span, err)
 		return errors.Wrap(err, "SetMetadata")
 	}
@@ -55,9 +67,14 @@ func (a *WalletAggregate) DebitWallet(ctx context.Context,
 	creditWalletId string,
 	amount decimal.Decimal,
 	description string) error {
-	span, _ := opentracing.StartSpanFromContext(ctx, "WalletAggregate.DebitWallet")
-	defer span.Finish()
-	span.LogFields(log.String(constants.AggregateID, a.GetID()))
+	ctx, span := tracing.StartSpan(ctx, "WalletAggregate.DebitWallet")
+	defer span.End()
+	span.SetAttributes(attribute.String(constants.AggregateID, a.GetID()))
+
+	if err := a.checkCanSpend(amount); err != nil {
+		tracing.TraceErr(span, err)
+		return err
+	}
 
 	event, err := eventsV1.NewWalletDebitEvent(a, creditWalletId, amount, description)
 	if err != nil {
@@ -65,7 +82,7 @@ func (a *WalletAggregate) DebitWallet(ctx context.Context,
 		return errors.Wrap(err, "NewWalletDebitEvent")
 	}
 
-	if err := event.SetMetadata(tracing.ExtractTextMapCarrier(span.Context())); err != nil {
+	if err := event.SetMetadata(eventMetadata(ctx)); err != nil {
 		tracing.TraceErr(span, err)
 		return errors.Wrap(err, "SetMetadata")
 	}
@@ -76,9 +93,14 @@ func (a *WalletAggregate) ReserveWalletCredit(
 	ctx context.Context,
 	amount decimal.Decimal,
 	description string) error {
-	span, _ := opentracing.StartSpanFromContext(ctx, "WalletAggregate.ReserveWalletCredit")
-	defer span.Finish()
-	span.LogFields(log.String(constants.AggregateID, a.GetID()))
+	ctx, span := tracing.StartSpan(ctx, "WalletAggregate.ReserveWalletCredit")
+	defer span.End()
+	span.SetAttributes(attribute.String(constants.AggregateID, a.GetID()))
+
+	if err := a.checkCanSpend(amount); err != nil {
+		tracing.TraceErr(span, err)
+		return err
+	}
 
 	event, err := eventsV1.NewWalletCreditReservedEvent(a, amount, description)
 	if err != nil {
@@ -86,7 +108,7 @@ func (a *WalletAggregate) ReserveWalletCredit(
 		return errors.Wrap(err, "NewWalletCreditReservedEvent")
 	}
 
-	if err := event.SetMetadata(tracing.ExtractTextMapCarrier(span.Context())); err != nil {
+	if err := event.SetMetadata(eventMetadata(ctx)); err != nil {
 		tracing.TraceErr(span, err)
 		return errors.Wrap(err, "SetMetadata")
 	}
@@ -97,9 +119,14 @@ func (a *WalletAggregate) ReleaseWalletCredit(
 	ctx context.Context,
 	amount decimal.Decimal,
 	description string) error {
-	span, _ := opentracing.StartSpanFromContext(ctx, "WalletAggregate.ReleaseWalletCredit")
-	defer span.Finish()
-	span.LogFields(log.String(constants.AggregateID, a.GetID()))
+	ctx, span := tracing.StartSpan(ctx, "WalletAggregate.ReleaseWalletCredit")
+	defer span.End()
+	span.SetAttributes(attribute.String(constants.AggregateID, a.GetID()))
+
+	if err := a.checkCanRelease(amount); err != nil {
+		tracing.TraceErr(span, err)
+		return err
+	}
 
 	event, err := eventsV1.NewWalletCreditReleasedEvent(a, amount, description)
 	if err != nil {
@@ -107,25 +134,42 @@ func (a *WalletAggregate) ReleaseWalletCredit(
 		return errors.Wrap(err, "NewWalletCreditReleasedEvent")
 	}
 
-	if err := event.SetMetadata(tracing.ExtractTextMapCarrier(span.Context())); err != nil {
+	if err := event.SetMetadata(eventMetadata(ctx)); err != nil {
 		tracing.TraceErr(span, err)
 		return errors.Wrap(err, "SetMetadata")
 	}
 
 	return a.Apply(event)
 }
-func (a *WalletAggregate) LockWallet(ctx context.Context, description string) error {
-	span, _ := opentracing.StartSpanFromContext(ctx, "WalletAggregate.LockWallet")
-	defer span.Finish()
-	span.LogFields(log.String(constants.AggregateID, a.GetID()))
 
-	event, err := eventsV1.NewWalletLockedEvent(a, description)
+// LockWallet imposes a lock. The kind and the imposition time of restriction are taken
+// from the event; a restriction without a reason code is recorded as domain.ReasonOther.
+func (a *WalletAggregate) LockWallet(ctx context.Context, restriction domain.Restriction) error {
+	ctx, span := tracing.StartSpan(ctx, "WalletAggregate.LockWallet")
+	defer span.End()
+	span.SetAttributes(attribute.String(constants.AggregateID, a.GetID()))
+
+	if err := a.checkCanLock(); err != nil {
+		tracing.TraceErr(span, err)
+		return err
+	}
+
+	if restriction.ReasonCode == "" {
+		restriction.ReasonCode = domain.ReasonOther
+	}
+	event, err := eventsV1.NewWalletLockedEvent(a, eventsV1.WalletLockedEvent{
+		Description: restriction.Description,
+		ReasonCode:  string(restriction.ReasonCode),
+		Authority:   restriction.Authority,
+		Evidence:    restriction.Evidence,
+		ExpiresAt:   restriction.ExpiresAt,
+	})
 	if err != nil {
 		tracing.TraceErr(span, err)
 		return errors.Wrap(err, "NewWalletLockedEvent")
 	}
 
-	if err := event.SetMetadata(tracing.ExtractTextMapCarrier(span.Context())); err != nil {
+	if err := event.SetMetadata(eventMetadata(ctx)); err != nil {
 		tracing.TraceErr(span, err)
 		return errors.Wrap(err, "SetMetadata")
 	}
@@ -133,35 +177,56 @@ func (a *WalletAggregate) LockWallet(ctx context.Context, description string) er
 	return a.Apply(event)
 }
 func (a *WalletAggregate) UnlockWallet(ctx context.Context, description string) error {
-	span, _ := opentracing.StartSpanFromContext(ctx, "WalletAggregate.UnlockWallet")
-	defer span.Finish()
-	span.LogFields(log.String(constants.AggregateID, a.GetID()))
+	ctx, span := tracing.StartSpan(ctx, "WalletAggregate.UnlockWallet")
+	defer span.End()
+	span.SetAttributes(attribute.String(constants.AggregateID, a.GetID()))
+
+	if err := a.checkCanUnlock(); err != nil {
+		tracing.TraceErr(span, err)
+		return err
+	}
 
-	event, err := eventsV1.NewWalletUnlockedEvent(a, description)
+	event, err := eventsV1.NewWalletUnlockedEvent(a, description, false)
 	if err != nil {
 		tracing.TraceErr(span, err)
 		return errors.Wrap(err, "NewWalletUnlockedEvent")
 	}
 
-	if err := event.SetMetadata(tracing.ExtractTextMapCarrier(span.Context())); err != nil {
+	if err := event.SetMetadata(eventMetadata(ctx)); err != nil {
 		tracing.TraceErr(span, err)
 		return errors.Wrap(err, "SetMetadata")
 	}
 
 	return a.Apply(event)
 }
-func (a *WalletAggregate) BlacklistWallet(ctx context.Context, description string) error {
-	span, _ := opentracing.StartSpanFromContext(ctx, "WalletAggregate.BlacklistWallet")
-	defer span.Finish()
-	span.LogFields(log.String(constants.AggregateID, a.GetID()))
 
-	event, err := eventsV1.NewWalletBlacklistedEvent(a, description)
+// BlacklistWallet imposes a blacklisting, like LockWallet imposes a lock.
+func (a *WalletAggregate) BlacklistWallet(ctx context.Context, restriction domain.Restriction) error {
+	ctx, span := tracing.StartSpan(ctx, "WalletAggregate.BlacklistWallet")
+	defer span.End()
+	span.SetAttributes(attribute.String(constants.AggregateID, a.GetID()))
+
+	if err := a.checkCanBlacklist(); err != nil {
+		tracing.TraceErr(span, err)
+		return err
+	}
+
+	if restriction.ReasonCode == "" {
+		restriction.ReasonCode = domain.ReasonOther
+	}
+	event, err := eventsV1.NewWalletBlacklistedEvent(a, eventsV1.WalletBlacklistedEvent{
+		Description: restriction.Description,
+		ReasonCode:  string(restriction.ReasonCode),
+		Authority:   restriction.Authority,
+		Evidence:    restriction.Evidence,
+		ExpiresAt:   restriction.ExpiresAt,
+	})
 	if err != nil {
 		tracing.TraceErr(span, err)
 		return errors.Wrap(err, "NewWalletBlacklistedEvent")
 	}
 
-	if err := event.SetMetadata(tracing.ExtractTextMapCarrier(span.Context())); err != nil {
+	if err := event.SetMetadata(eventMetadata(ctx)); err != nil {
 		tracing.TraceErr(span, err)
 		return errors.Wrap(err, "SetMetadata")
 	}
@@ -169,27 +234,84 @@ func (a *WalletAggregate) BlacklistWallet(ctx context.Context, description strin
 	return a.Apply(event)
 }
 func (a *WalletAggregate) UnBlacklistWallet(ctx context.Context, description string) error {
-	span, _ := opentracing.StartSpanFromContext(ctx, "WalletAggregate.UnBlacklistWallet")
-	defer span.Finish()
-	span.LogFields(log.String(constants.AggregateID, a.GetID()))
+	ctx, span := tracing.StartSpan(ctx, "WalletAggregate.UnBlacklistWallet")
+	defer span.End()
+	span.SetAttributes(attribute.String(constants.AggregateID, a.GetID()))
 
-	event, err := eventsV1.NewWalletUnBlacklistedEvent(a, description)
+	if err := a.checkCanUnBlacklist(); err != nil {
+		tracing.TraceErr(span, err)
+		return err
+	}
+
+	event, err := eventsV1.NewWalletUnBlacklistedEvent(a, description, false)
 	if err != nil {
 		tracing.TraceErr(span, err)
 		return errors.Wrap(err, "NewWalletUnBlacklistedEvent")
 	}
 
-	if err := event.SetMetadata(tracing.ExtractTextMapCarrier(span.Context())); err != nil {
+	if err := event.SetMetadata(eventMetadata(ctx)); err != nil {
 		tracing.TraceErr(span, err)
 		return errors.Wrap(err, "SetMetadata")
 	}
 
 	return a.Apply(event)
 }
+
+// LiftExpiredRestrictions lifts the lock and the blacklisting whose expiry is not after
+// now, marking the events as expired. It fails with ErrNoExpiredRestriction when there
+// is nothing to lift.
+func (a *WalletAggregate) LiftExpiredRestrictions(ctx context.Context, now time.Time) error {
+	ctx, span := tracing.StartSpan(ctx, "WalletAggregate.LiftExpiredRestrictions")
+	defer span.End()
+	span.SetAttributes(attribute.String(constants.AggregateID, a.GetID()))
+
+	if err := a.checkExists(); err != nil {
+		tracing.TraceErr(span, err)
+		return err
+	}
+
+	lifted := false
+	for _, kind := range []domain.RestrictionKind{domain.RestrictionLock, domain.RestrictionBlacklist} {
+		restriction := a.WalletState.Restriction(kind)
+		if restriction == nil || !restriction.Expired(now) {
+			continue
+		}
+		description := fmt.Sprintf("%s expired at %s", kind, restriction.ExpiresAt.UTC().Format(time.RFC3339))
+		newEvent, name := eventsV1.NewWalletUnlockedEvent, "NewWalletUnlockedEvent"
+		if kind == domain.RestrictionBlacklist {
+			newEvent, name = eventsV1.NewWalletUnBlacklistedEvent, "NewWalletUnBlacklistedEvent"
+		}
+		event, err := newEvent(a, description, true)
+		if err != nil {
+			tracing.TraceErr(span, err)
+			return errors.Wrap(err, name)
+		}
+
+		if err := event.SetMetadata(eventMetadata(ctx)); err != nil {
+			tracing.TraceErr(span, err)
+			return errors.Wrap(err, "SetMetadata")
+		}
+		if err := a.Apply(event); err != nil {
+			tracing.TraceErr(span, err)
+			return err
+		}
+		lifted = true
+	}
+	if !lifted {
+		tracing.TraceErr(span, ErrNoExpiredRestriction)
+		return ErrNoExpiredRestriction
+	}
+	return nil
+}
 func (a *WalletAggregate) DeleteWallet(ctx context.Context, description string) error {
-	span, _ := opentracing.StartSpanFromContext(ctx, "WalletAggregate.DeleteWallet")
-	defer span.Finish()
-	span.LogFields(log.String(constants.AggregateID, a.GetID()))
+	ctx, span := tracing.StartSpan(ctx, "WalletAggregate.DeleteWallet")
+	defer span.End()
+	span.SetAttributes(attribute.String(constants.AggregateID, a.GetID()))
+
+	if err := a.checkExists(); err != nil {
+		tracing.TraceErr(span, err)
+		return err
+	}
 
 	event, err := eventsV1.NewWalletDeletedEvent(a, description)
 	if err != nil {
@@ -197,7 +319,106 @@ func (a *WalletAggregate) DeleteWallet(ctx context.Context, description string)
 		return errors.Wrap(err, "NewWalletDeletedEvent")
 	}
 
-	if err := event.SetMetadata(tracing.ExtractTextMapCarrier(span.Context())); err != nil {
+	if err := event.SetMetadata(eventMetadata(ctx)); err != nil {
+		tracing.TraceErr(span, err)
+		return errors.Wrap(err, "SetMetadata")
+	}
+
+	return a.Apply(event)
+}
+
+// RecordScreening records a watchlist hit on a credit or debit of the wallet. A quarantined
+// hit holds its amount, which must be available, until it is released.
+func (a *WalletAggregate) RecordScreening(ctx context.Context, screening domain.Screening) error {
+	ctx, span := tracing.StartSpan(ctx, "WalletAggregate.RecordScreening")
+	defer span.End()
+	span.SetAttributes(attribute.String(constants.AggregateID, a.GetID()))
+
+	if err := a.checkCanRecordScreening(screening); err != nil {
+		tracing.TraceErr(span, err)
+		return err
+	}
+
+	event, err := eventsV1.NewWalletScreenedEvent(a, eventsV1.WalletScreenedEvent{
+		ScreeningID:          screening.ID,
+		Outcome:              string(screening.Outcome),
+		Command:              screening.Command,
+		CounterpartyWalletId: screening.CounterpartyWalletId,
+		Amount:               screening.Amount,
+		Provider:             screening.Provider,
+		Matches:              screening.Matches,
+		Description:          screening.Description,
+	})
+	if err != nil {
+		tracing.TraceErr(span, err)
+		return errors.Wrap(err, "NewWalletScreenedEvent")
+	}
+
+	if err := event.SetMetadata(eventMetadata(ctx)); err != nil {
+		tracing.TraceErr(span, err)
+		return errors.Wrap(err, "SetMetadata")
+	}
+
+	return a.Apply(event)
+}
+
+// ReleaseQuarantine makes the amount held by a quarantined screening available again,
+// after a review cleared the hit.
+func (a *WalletAggregate) ReleaseQuarantine(ctx context.Context, screeningID, description string) error {
+	ctx, span := tracing.StartSpan(ctx, "WalletAggregate.ReleaseQuarantine")
+	defer span.End()
+	span.SetAttributes(attribute.String(constants.AggregateID, a.GetID()))
+
+	quarantine, err := a.checkCanCloseQuarantine(screeningID)
+	if err != nil {
+		tracing.TraceErr(span, err)
+		return err
+	}
+
+	event, err := eventsV1.NewWalletQuarantineReleasedEvent(a, eventsV1.WalletQuarantineReleasedEvent{
+		ScreeningID: screeningID,
+		Amount:      quarantine.Amount,
+		Description: description,
+	})
+	if err != nil {
+		tracing.TraceErr(span, err)
+		return errors.Wrap(err, "NewWalletQuarantineReleasedEvent")
+	}
+
+	if err := event.SetMetadata(eventMetadata(ctx)); err != nil {
+		tracing.TraceErr(span, err)
+		return errors.Wrap(err, "SetMetadata")
+	}
+
+	return a.Apply(event)
+}
+
+// ConfirmQuarantine takes the amount held by a quarantined screening off the wallet after
+// a review upheld the hit. creditWalletID is where the funds go, such as a suspense wallet;
+// crediting it is left to the caller, as with DebitWallet.
+func (a *WalletAggregate) ConfirmQuarantine(ctx context.Context, screeningID, creditWalletID, description string) error {
+	ctx, span := tracing.StartSpan(ctx, "WalletAggregate.ConfirmQuarantine")
+	defer span.End()
+	span.SetAttributes(attribute.String(constants.AggregateID, a.GetID()))
+
+	quarantine, err := a.checkCanCloseQuarantine(screeningID)
+	if err != nil {
+		tracing.TraceErr(span, err)
+		return err
+	}
+
+	event, err := eventsV1.NewWalletQuarantineConfirmedEvent(a, eventsV1.WalletQuarantineConfirmedEvent{
+		ScreeningID:    screeningID,
+		Amount:         quarantine.Amount,
+		CreditWalletId: creditWalletID,
+		Description:    description,
+	})
+	if err != nil {
+		tracing.TraceErr(span, err)
+		return errors.Wrap(err, "NewWalletQuarantineConfirmedEvent")
+	}
+
+	if err := event.SetMetadata(eventMetadata(ctx)); err != nil {
 		tracing.TraceErr(span, err)
 		return errors.Wrap(err, "SetMetadata")
 	}
@@ -0,0 +1,444 @@
+package aggregate_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/novabankapp/wallet.data/domain"
+	"github.com/novabankapp/wallet.data/es/aggregate"
+	. "github.com/novabankapp/wallet.data/es/aggregate/aggregatetest"
+	eventsV1 "github.com/novabankapp/wallet.data/es/events/v1"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	walletID = "w-1"
+	otherID  = "w-2"
+)
+
+func amount(value string) decimal.Decimal {
+	return decimal.RequireFromString(value)
+}
+
+func created(balance string) Event {
+	return WalletCreated(amount(balance), "opening", "user-1", "account-1", walletID)
+}
+
+func locked(description string) Event {
+	return WalletLocked(eventsV1.WalletLockedEvent{Description: description, ReasonCode: string(domain.ReasonOther)})
+}
+
+func blacklisted(description string) Event {
+	return WalletBlacklisted(eventsV1.WalletBlacklistedEvent{Description: description, ReasonCode: string(domain.ReasonOther)})
+}
+
+func quarantinedBy(screeningID, value string) Event {
+	return WalletScreened(eventsV1.WalletScreenedEvent{ScreeningID: screeningID, Outcome: string(domain.ScreeningQuarantined), Command: "CreditWallet", CounterpartyWalletId: otherID, Amount: amount(value)})
+}
+
+func TestCreateWallet(t *testing.T) {
+	create := func(value string) func(context.Context, *aggregate.WalletAggregate) error {
+		return func(ctx context.Context, a *aggregate.WalletAggregate) error {
+			return a.CreateWallet(ctx, amount(value), "opening", "user-1", "account-1", walletID)
+		}
+	}
+
+	t.Run("creates a wallet", func(t *testing.T) {
+		ForWallet(t, walletID).
+			When(create("100")).
+			Then(created("100")).
+			ThenState(func(t testing.TB, a *aggregate.WalletAggregate) {
+				if !a.IsCreated() || !a.Wallet.Balance.Equal(amount("100")) || !a.Wallet.AvailableBalance.Equal(amount("100")) {
+					t.Errorf("unexpected wallet %+v", a.Wallet)
+				}
+			})
+	})
+	t.Run("creates an empty wallet", func(t *testing.T) {
+		ForWallet(t, walletID).When(create("0")).Then(created("0"))
+	})
+}
+
+func TestCreditWallet(t *testing.T) {
+	credit := func(value string) func(context.Context, *aggregate.WalletAggregate) error {
+		return func(ctx context.Context, a *aggregate.WalletAggregate) error {
+			return a.CreditWallet(ctx, otherID, amount(value), "top up")
+		}
+	}
+
+	t.Run("credits the wallet", func(t *testing.T) {
+		ForWallet(t, walletID).
+			Given(created("100")).
+			When(credit("25.50")).
+			Then(WalletCredited(otherID, amount("25.5"), "top up")).
+			ThenState(func(t testing.TB, a *aggregate.WalletAggregate) {
+				if !a.Wallet.Balance.Equal(amount("125.5")) || !a.Wallet.AvailableBalance.Equal(amount("125.5")) {
+					t.Errorf("balance %s available %s", a.Wallet.Balance, a.Wallet.AvailableBalance)
+				}
+				if len(*a.WalletTransactions) != 1 {
+					t.Errorf("expected one transaction, got %d", len(*a.WalletTransactions))
+				}
+			})
+	})
+	t.Run("credits a locked wallet", func(t *testing.T) {
+		ForWallet(t, walletID).
+			Given(created("100"), locked("review")).
+			When(credit("10")).
+			Then(WalletCredited(otherID, amount("10"), "top up"))
+	})
+}
+
+func TestDebitWallet(t *testing.T) {
+	debit := func(value string) func(context.Context, *aggregate.WalletAggregate) error {
+		return func(ctx context.Context, a *aggregate.WalletAggregate) error {
+			return a.DebitWallet(ctx, otherID, amount(value), "payment")
+		}
+	}
+
+	t.Run("debits the wallet", func(t *testing.T) {
+		ForWallet(t, walletID).
+			Given(created("100")).
+			When(debit("40")).
+			Then(WalletDebited(otherID, amount("40"), "payment")).
+			ThenState(func(t testing.TB, a *aggregate.WalletAggregate) {
+				if !a.Wallet.Balance.Equal(amount("60")) || !a.Wallet.AvailableBalance.Equal(amount("60")) {
+					t.Errorf("balance %s available %s", a.Wallet.Balance, a.Wallet.AvailableBalance)
+				}
+			})
+	})
+	t.Run("debits the whole available balance", func(t *testing.T) {
+		ForWallet(t, walletID).Given(created("100")).When(debit("100")).Then(WalletDebited(otherID, amount("100"), "payment"))
+	})
+	t.Run("debits an unlocked wallet", func(t *testing.T) {
+		ForWallet(t, walletID).
+			Given(created("100"), locked("review"), WalletUnlocked("cleared")).
+			When(debit("10")).
+			Then(WalletDebited(otherID, amount("10"), "payment"))
+	})
+}
+
+func TestReserveWalletCredit(t *testing.T) {
+	reserve := func(value string) func(context.Context, *aggregate.WalletAggregate) error {
+		return func(ctx context.Context, a *aggregate.WalletAggregate) error {
+			return a.ReserveWalletCredit(ctx, amount(value), "hold")
+		}
+	}
+
+	t.Run("reserves funds", func(t *testing.T) {
+		ForWallet(t, walletID).
+			Given(created("100")).
+			When(reserve("30")).
+			Then(WalletCreditReserved(amount("30"), "hold")).
+			ThenState(func(t testing.TB, a *aggregate.WalletAggregate) {
+				if !a.Wallet.Balance.Equal(amount("100")) || !a.Wallet.AvailableBalance.Equal(amount("70")) {
+					t.Errorf("balance %s available %s", a.Wallet.Balance, a.Wallet.AvailableBalance)
+				}
+			})
+	})
+}
+
+func TestRecordScreening(t *testing.T) {
+	screening := func(outcome domain.ScreeningOutcome, value string) domain.Screening {
+		return domain.Screening{ID: "s-1", Outcome: outcome, Command: "DebitWallet", CounterpartyWalletId: otherID, Amount: amount(value), Provider: "lists", Matches: "[]", Description: "watchlist hit"}
+	}
+	screened := func(s domain.Screening) Event {
+		return WalletScreened(eventsV1.WalletScreenedEvent{
+			ScreeningID: s.ID, Outcome: string(s.Outcome), Command: s.Command, CounterpartyWalletId: s.CounterpartyWalletId,
+			Amount: s.Amount, Provider: s.Provider, Matches: s.Matches, Description: s.Description,
+		})
+	}
+	record := func(s domain.Screening) func(context.Context, *aggregate.WalletAggregate) error {
+		return func(ctx context.Context, a *aggregate.WalletAggregate) error {
+			return a.RecordScreening(ctx, s)
+		}
+	}
+
+	t.Run("quarantines funds", func(t *testing.T) {
+		s := screening(domain.ScreeningQuarantined, "30")
+		ForWallet(t, walletID).
+			Given(created("100")).
+			When(record(s)).
+			Then(screened(s)).
+			ThenState(func(t testing.TB, a *aggregate.WalletAggregate) {
+				if !a.Wallet.Balance.Equal(amount("100")) || !a.Wallet.GetHeldBalance().Equal(amount("30")) {
+					t.Errorf("balance %s held %s", a.Wallet.Balance, a.Wallet.GetHeldBalance())
+				}
+			})
+	})
+	t.Run("records a block without holding funds", func(t *testing.T) {
+		s := screening(domain.ScreeningBlocked, "300")
+		ForWallet(t, walletID).
+			Given(created("100"), blacklisted("fraud")).
+			When(record(s)).
+			Then(screened(s)).
+			ThenState(func(t testing.TB, a *aggregate.WalletAggregate) {
+				if !a.Wallet.AvailableBalance.Equal(amount("100")) {
+					t.Errorf("available %s", a.Wallet.AvailableBalance)
+				}
+			})
+	})
+	t.Run("quarantines on a locked wallet", func(t *testing.T) {
+		s := screening(domain.ScreeningQuarantined, "30")
+		ForWallet(t, walletID).Given(created("100"), locked("review")).When(record(s)).Then(screened(s))
+	})
+}
+
+func TestReleaseQuarantine(t *testing.T) {
+	release := func(screeningID string) func(context.Context, *aggregate.WalletAggregate) error {
+		return func(ctx context.Context, a *aggregate.WalletAggregate) error {
+			return a.ReleaseQuarantine(ctx, screeningID, "cleared")
+		}
+	}
+
+	t.Run("makes the quarantined funds available", func(t *testing.T) {
+		ForWallet(t, walletID).
+			Given(created("100"), quarantinedBy("s-1", "30"), quarantinedBy("s-2", "20")).
+			When(release("s-1")).
+			Then(WalletQuarantineReleased(eventsV1.WalletQuarantineReleasedEvent{ScreeningID: "s-1", Amount: amount("30"), Description: "cleared"})).
+			ThenState(func(t testing.TB, a *aggregate.WalletAggregate) {
+				if !a.Wallet.AvailableBalance.Equal(amount("80")) || !a.WalletState.Quarantined().Equal(amount("20")) {
+					t.Errorf("available %s quarantined %s", a.Wallet.AvailableBalance, a.WalletState.Quarantined())
+				}
+				if a.WalletState.Quarantine("s-1") != nil {
+					t.Error("quarantine s-1 is still open")
+				}
+			})
+	})
+}
+
+func TestConfirmQuarantine(t *testing.T) {
+	confirm := func(screeningID string) func(context.Context, *aggregate.WalletAggregate) error {
+		return func(ctx context.Context, a *aggregate.WalletAggregate) error {
+			return a.ConfirmQuarantine(ctx, screeningID, "w-suspense", "upheld")
+		}
+	}
+
+	t.Run("debits the quarantined funds", func(t *testing.T) {
+		ForWallet(t, walletID).
+			Given(created("100"), quarantinedBy("s-1", "30")).
+			When(confirm("s-1")).
+			Then(WalletQuarantineConfirmed(eventsV1.WalletQuarantineConfirmedEvent{ScreeningID: "s-1", Amount: amount("30"), CreditWalletId: "w-suspense", Description: "upheld"})).
+			ThenState(func(t testing.TB, a *aggregate.WalletAggregate) {
+				if !a.Wallet.Balance.Equal(amount("70")) || !a.Wallet.AvailableBalance.Equal(amount("70")) {
+					t.Errorf("balance %s available %s", a.Wallet.Balance, a.Wallet.AvailableBalance)
+				}
+				if len(a.WalletState.Quarantines) != 0 {
+					t.Errorf("quarantines %v", a.WalletState.Quarantines)
+				}
+				transactions := *a.WalletTransactions
+				last := transactions[len(transactions)-1]
+				if last.DebitWalletId != walletID || last.CreditWalletId != "w-suspense" || !last.Amount.Equal(amount("30")) {
+					t.Errorf("transaction %+v", last)
+				}
+			})
+	})
+}
+
+func TestReleaseWalletCredit(t *testing.T) {
+	release := func(value string) func(context.Context, *aggregate.WalletAggregate) error {
+		return func(ctx context.Context, a *aggregate.WalletAggregate) error {
+			return a.ReleaseWalletCredit(ctx, amount(value), "release")
+		}
+	}
+
+	t.Run("releases held funds", func(t *testing.T) {
+		ForWallet(t, walletID).
+			Given(created("100"), WalletCreditReserved(amount("30"), "hold")).
+			When(release("20")).
+			Then(WalletCreditReleased(amount("20"), "release")).
+			ThenState(func(t testing.TB, a *aggregate.WalletAggregate) {
+				if !a.Wallet.AvailableBalance.Equal(amount("90")) || !a.Wallet.GetHeldBalance().Equal(amount("10")) {
+					t.Errorf("available %s held %s", a.Wallet.AvailableBalance, a.Wallet.GetHeldBalance())
+				}
+			})
+	})
+	t.Run("releases on a locked wallet", func(t *testing.T) {
+		ForWallet(t, walletID).
+			Given(created("100"), WalletCreditReserved(amount("30"), "hold"), locked("review")).
+			When(release("30")).
+			Then(WalletCreditReleased(amount("30"), "release"))
+	})
+}
+
+func TestLockWallet(t *testing.T) {
+	lock := func(ctx context.Context, a *aggregate.WalletAggregate) error {
+		return a.LockWallet(ctx, domain.Restriction{Description: "review"})
+	}
+
+	t.Run("locks the wallet", func(t *testing.T) {
+		ForWallet(t, walletID).
+			Given(created("100")).
+			When(lock).
+			Then(locked("review")).
+			ThenState(func(t testing.TB, a *aggregate.WalletAggregate) {
+				if !a.WalletState.IsLocked {
+					t.Error("wallet is not locked")
+				}
+			})
+	})
+	t.Run("records the restriction", func(t *testing.T) {
+		expiresAt := time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC)
+		ForWallet(t, walletID).
+			Given(created("100")).
+			When(func(ctx context.Context, a *aggregate.WalletAggregate) error {
+				return a.LockWallet(ctx, domain.Restriction{
+					ReasonCode:  domain.ReasonCourtOrder,
+					Authority:   "district court",
+					Description: "freezing order",
+					Evidence:    []string{"order-17"},
+					ExpiresAt:   &expiresAt,
+				})
+			}).
+			Then(WalletLocked(eventsV1.WalletLockedEvent{
+				Description: "freezing order",
+				ReasonCode:  string(domain.ReasonCourtOrder),
+				Authority:   "district court",
+				Evidence:    []string{"order-17"},
+				ExpiresAt:   &expiresAt,
+			})).
+			ThenState(func(t testing.TB, a *aggregate.WalletAggregate) {
+				r := a.WalletState.Restriction(domain.RestrictionLock)
+				if r == nil || r.ReasonCode != domain.ReasonCourtOrder || r.Authority != "district court" || len(r.Evidence) != 1 ||
+					r.ExpiresAt == nil || !r.ExpiresAt.Equal(expiresAt) || r.ImposedAt.IsZero() || len(a.WalletState.Restrictions) != 1 {
+					t.Errorf("restrictions %+v", a.WalletState.Restrictions)
+				}
+			})
+	})
+	t.Run("reads locks without a reason code as other", func(t *testing.T) {
+		ForWallet(t, walletID).
+			Given(created("100"), WalletLocked(eventsV1.WalletLockedEvent{Description: "review"})).
+			When(func(ctx context.Context, a *aggregate.WalletAggregate) error { return nil }).
+			ThenState(func(t testing.TB, a *aggregate.WalletAggregate) {
+				if r := a.WalletState.Restriction(domain.RestrictionLock); r == nil || r.ReasonCode != domain.ReasonOther || r.Description != "review" {
+					t.Errorf("restrictions %+v", a.WalletState.Restrictions)
+				}
+			})
+	})
+}
+
+func TestUnlockWallet(t *testing.T) {
+	unlock := func(ctx context.Context, a *aggregate.WalletAggregate) error {
+		return a.UnlockWallet(ctx, "cleared")
+	}
+
+	t.Run("unlocks the wallet", func(t *testing.T) {
+		ForWallet(t, walletID).
+			Given(created("100"), locked("review")).
+			When(unlock).
+			Then(WalletUnlocked("cleared")).
+			ThenState(func(t testing.TB, a *aggregate.WalletAggregate) {
+				if a.WalletState.IsLocked || a.WalletState.Restrictions != nil {
+					t.Errorf("wallet is still locked: %+v", a.WalletState)
+				}
+			})
+	})
+}
+
+func TestBlacklistWallet(t *testing.T) {
+	blacklist := func(ctx context.Context, a *aggregate.WalletAggregate) error {
+		return a.BlacklistWallet(ctx, domain.Restriction{Description: "fraud"})
+	}
+
+	t.Run("blacklists the wallet", func(t *testing.T) {
+		ForWallet(t, walletID).
+			Given(created("100")).
+			When(blacklist).
+			Then(blacklisted("fraud")).
+			ThenState(func(t testing.TB, a *aggregate.WalletAggregate) {
+				if !a.WalletState.IsBlacklisted || a.WalletState.Restriction(domain.RestrictionBlacklist) == nil {
+					t.Errorf("wallet is not blacklisted: %+v", a.WalletState)
+				}
+			})
+	})
+	t.Run("blacklists a locked wallet", func(t *testing.T) {
+		ForWallet(t, walletID).Given(created("100"), locked("review")).When(blacklist).Then(blacklisted("fraud"))
+	})
+}
+
+func TestUnBlacklistWallet(t *testing.T) {
+	unBlacklist := func(ctx context.Context, a *aggregate.WalletAggregate) error {
+		return a.UnBlacklistWallet(ctx, "cleared")
+	}
+
+	t.Run("lifts the blacklisting", func(t *testing.T) {
+		ForWallet(t, walletID).
+			Given(created("100"), blacklisted("fraud")).
+			When(unBlacklist).
+			Then(WalletUnBlacklisted("cleared")).
+			ThenState(func(t testing.TB, a *aggregate.WalletAggregate) {
+				if a.WalletState.IsBlacklisted {
+					t.Error("wallet is still blacklisted")
+				}
+			})
+	})
+}
+
+func TestLiftExpiredRestrictions(t *testing.T) {
+	now := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	past, future := now.Add(-time.Hour), now.Add(time.Hour)
+	lift := func(ctx context.Context, a *aggregate.WalletAggregate) error {
+		return a.LiftExpiredRestrictions(ctx, now)
+	}
+	lockUntil := func(expiresAt *time.Time) Event {
+		return WalletLocked(eventsV1.WalletLockedEvent{Description: "review", ReasonCode: string(domain.ReasonFraudSuspected), ExpiresAt: expiresAt})
+	}
+	blacklistUntil := func(expiresAt *time.Time) Event {
+		return WalletBlacklisted(eventsV1.WalletBlacklistedEvent{Description: "sanctions", ReasonCode: string(domain.ReasonSanctions), ExpiresAt: expiresAt})
+	}
+
+	t.Run("lifts an expired lock", func(t *testing.T) {
+		ForWallet(t, walletID).
+			Given(created("100"), lockUntil(&past)).
+			When(lift).
+			Then(WalletLockExpired("lock expired at 2026-10-19T11:00:00Z")).
+			ThenState(func(t testing.TB, a *aggregate.WalletAggregate) {
+				if a.WalletState.IsLocked || a.WalletState.Restrictions != nil {
+					t.Errorf("wallet is still locked: %+v", a.WalletState)
+				}
+			})
+	})
+	t.Run("lifts every expired restriction", func(t *testing.T) {
+		ForWallet(t, walletID).
+			Given(created("100"), lockUntil(&now), blacklistUntil(&past)).
+			When(lift).
+			Then(WalletLockExpired("lock expired at 2026-10-19T12:00:00Z"), WalletBlacklistExpired("blacklist expired at 2026-10-19T11:00:00Z"))
+	})
+	t.Run("keeps what has not expired", func(t *testing.T) {
+		ForWallet(t, walletID).
+			Given(created("100"), lockUntil(&future), blacklistUntil(&past)).
+			When(lift).
+			Then(WalletBlacklistExpired("blacklist expired at 2026-10-19T11:00:00Z")).
+			ThenState(func(t testing.TB, a *aggregate.WalletAggregate) {
+				if !a.WalletState.IsLocked || a.WalletState.IsBlacklisted || len(a.WalletState.Restrictions) != 1 {
+					t.Errorf("state %+v", a.WalletState)
+				}
+			})
+	})
+	t.Run("rejects a wallet without expired restrictions", func(t *testing.T) {
+		ForWallet(t, walletID).Given(created("100"), lockUntil(&future), blacklistUntil(nil)).When(lift).ThenError(aggregate.ErrNoExpiredRestriction)
+	})
+	t.Run("rejects a lock that was lifted", func(t *testing.T) {
+		ForWallet(t, walletID).Given(created("100"), lockUntil(&past), WalletUnlocked("cleared")).When(lift).ThenError(aggregate.ErrNoExpiredRestriction)
+	})
+	t.Run("rejects a missing wallet", func(t *testing.T) {
+		ForWallet(t, walletID).When(lift).ThenError(aggregate.ErrWalletNotCreated)
+	})
+}
+
+func TestDeleteWallet(t *testing.T) {
+	remove := func(ctx context.Context, a *aggregate.WalletAggregate) error {
+		return a.DeleteWallet(ctx, "closed")
+	}
+
+	t.Run("deletes the wallet", func(t *testing.T) {
+		ForWallet(t, walletID).
+			Given(created("100")).
+			When(remove).
+			Then(WalletDeleted("closed")).
+			ThenState(func(t testing.TB, a *aggregate.WalletAggregate) {
+				if !a.WalletState.IsDeleted {
+					t.Error("wallet is not deleted")
+				}
+			})
+	})
+}
@@ -16,3 +16,19 @@ var (
 	ErrOrderShopItemsIsRequired       = errors.New("order shop items is required")
 	ErrInvalidDeliveryAddress         = errors.New("Invalid delivery address")
 )
+
+var (
+	ErrWalletAlreadyCreated      = errors.New("wallet already created")
+	ErrWalletNotCreated          = errors.New("wallet not created")
+	ErrWalletDeleted             = errors.New("wallet is deleted")
+	ErrWalletLocked              = errors.New("wallet is locked")
+	ErrWalletNotLocked           = errors.New("wallet is not locked")
+	ErrWalletBlacklisted         = errors.New("wallet is blacklisted")
+	ErrWalletNotBlacklisted      = errors.New("wallet is not blacklisted")
+	ErrNoExpiredRestriction      = errors.New("wallet has no expired restriction")
+	ErrInvalidAmount             = errors.New("amount must be positive")
+	ErrInsufficientFunds         = errors.New("insufficient available balance")
+	ErrReleaseExceedsHeldBalance = errors.New("release exceeds the held balance")
+	ErrQuarantineExists          = errors.New("screening already holds a quarantine")
+	ErrQuarantineNotFound        = errors.New("wallet has no open quarantine for the screening")
+)
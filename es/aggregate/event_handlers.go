@@ -4,28 +4,26 @@ import (
 	"context"
 	"github.com/google/uuid"
 	es "github.com/novabankapp/common.data/eventstore"
-	"github.com/novabankapp/common.data/tracing"
 	"github.com/novabankapp/wallet.data/constants"
 	"github.com/novabankapp/wallet.data/domain"
 	v1 "github.com/novabankapp/wallet.data/es/events/v1"
 	"github.com/novabankapp/wallet.data/es/models"
-	"github.com/opentracing/opentracing-go"
-	"github.com/opentracing/opentracing-go/log"
+	"github.com/novabankapp/wallet.data/tracing"
 	"github.com/pkg/errors"
-	"time"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 func (c *WalletProjection) onWalletCreated(ctx context.Context, evt es.Event) error {
-	span, ctx := opentracing.StartSpanFromContext(ctx, "cassandraProjection.onWalletCreated")
-	defer span.Finish()
-	span.LogFields(log.String(constants.AggregateID, evt.GetAggregateID()))
+	ctx, span := tracing.StartSpan(ctx, "cassandraProjection.onWalletCreated")
+	defer span.End()
+	span.SetAttributes(attribute.String(constants.AggregateID, evt.GetAggregateID()))
 
 	var eventData v1.WalletCreatedEvent
 	if err := evt.GetJsonData(&eventData); err != nil {
 		tracing.TraceErr(span, err)
 		return errors.Wrap(err, "evt.GetJsonData")
 	}
-	span.LogFields(log.String(constants.WalletID, eventData.ID))
+	span.SetAttributes(attribute.String(constants.WalletID, eventData.ID))
 	aggId := GetWalletAggregateID(evt.AggregateID)
 	op := models.WalletProjection{
 		WalletID: aggId,
@@ -37,7 +35,7 @@ func (c *WalletProjection) onWalletCreated(ctx context.Context, evt es.Event) er
 			AccountId:        eventData.AccountId,
 			Balance:          eventData.Amount,
 			AvailableBalance: eventData.Amount,
-			CreatedAt:        time.Now(),
+			CreatedAt:        evt.GetTimeStamp(),
 		}),
 		WalletState: GetJsonString(domain.WalletState{
 			WalletId:      aggId,
@@ -56,42 +54,43 @@ func (c *WalletProjection) onWalletCreated(ctx context.Context, evt es.Event) er
 }
 
 func (c *WalletProjection) onWalletCredited(ctx context.Context, evt es.Event) error {
-	span, ctx := opentracing.StartSpanFromContext(ctx, "cassandraProjection.onWalletCredited")
-	defer span.Finish()
-	span.LogFields(log.String(constants.AggregateID, evt.GetAggregateID()))
+	ctx, span := tracing.StartSpan(ctx, "cassandraProjection.onWalletCredited")
+	defer span.End()
+	span.SetAttributes(attribute.String(constants.AggregateID, evt.GetAggregateID()))
 	aggId := GetWalletAggregateID(evt.GetAggregateID())
 	var eventData v1.WalletCreditedEvent
 	if err := evt.GetJsonData(&eventData); err != nil {
 		tracing.TraceErr(span, err)
 		return errors.Wrap(err, "evt.GetJsonData")
 	}
-	//span.LogFields(log.String("WalletID", eventData.ID))
-	queries := make([]map[string]string, 1)
+	//span.SetAttributes(attribute.String("WalletID", eventData.ID))
+	queries := make([]map[string]string, 0, 1)
 	m := make(map[string]string)
 	m["column"] = constants.WalletID
 	m["compare"] = "="
 	m["value"] = aggId
 	queries = append(queries, m)
 	ent, err := c.Repo.GetByCondition(ctx, queries)
-	e := *ent
 	if err != nil {
 		return err
 	}
+	e := *ent
 
 	walletTransactionsP, _ := GetEntityArrayFromJsonString[domain.WalletTransaction](e.WalletTransactions)
-	walletP, _ := GetEntityFromJsonString[domain.Wallet](e.Wallet)
-	var wallet = *walletP
+	wallet, _ := GetEntityFromJsonString[domain.Wallet](e.Wallet)
 	var walletTransactions []domain.WalletTransaction = *walletTransactionsP
 	walletTransactions = append(walletTransactions, domain.WalletTransaction{
 		DebitWalletId:  eventData.DebitWalletId,
 		CreditWalletId: wallet.ID,
 		Amount:         eventData.Amount,
-		CreatedAt:      time.Now(),
+		CreatedAt:      evt.GetTimeStamp(),
 		Description:    eventData.Description,
+		ID:             GetTransactionID(evt),
 	})
 	wallet.Balance = wallet.Balance.Add(eventData.Amount)
+	wallet.AvailableBalance = wallet.AvailableBalance.Add(eventData.Amount)
 	e.Wallet = GetJsonString(wallet)
-	e.WalletTransactions = GetJsonString(e.WalletTransactions)
+	e.WalletTransactions = GetJsonString(walletTransactions)
 	update, err := c.Repo.Update(ctx, e, e.ID)
 	if err != nil {
 		return err
@@ -105,38 +104,39 @@ func (c *WalletProjection) onWalletCredited(ctx context.Context, evt es.Event) e
 }
 
 func (c *WalletProjection) onWalletDebited(ctx context.Context, evt es.Event) error {
-	span, ctx := opentracing.StartSpanFromContext(ctx, "cassandraProjection.onWalletDebited")
-	defer span.Finish()
-	span.LogFields(log.String(constants.AggregateID, evt.GetAggregateID()))
+	ctx, span := tracing.StartSpan(ctx, "cassandraProjection.onWalletDebited")
+	defer span.End()
+	span.SetAttributes(attribute.String(constants.AggregateID, evt.GetAggregateID()))
 	aggId := GetWalletAggregateID(evt.GetAggregateID())
 	var eventData v1.WalletDebitedEvent
 	if err := evt.GetJsonData(&eventData); err != nil {
 		tracing.TraceErr(span, err)
 		return errors.Wrap(err, "evt.GetJsonData")
 	}
-	//span.LogFields(log.String("WalletID", eventData.ID))
-	queries := make([]map[string]string, 1)
+	//span.SetAttributes(attribute.String("WalletID", eventData.ID))
+	queries := make([]map[string]string, 0, 1)
 	m := make(map[string]string)
 	m["column"] = constants.WalletID
 	m["compare"] = "="
 	m["value"] = aggId
 	queries = append(queries, m)
 	ent, err := c.Repo.GetByCondition(ctx, queries)
-	e := *ent
 	if err != nil {
 		return err
 	}
+	e := *ent
 	walletTransactionsP, _ := GetEntityArrayFromJsonString[domain.WalletTransaction](e.WalletTransactions)
-	walletP, _ := GetEntityFromJsonString[domain.Wallet](e.Wallet)
-	var wallet domain.Wallet = *walletP
+	wallet, _ := GetEntityFromJsonString[domain.Wallet](e.Wallet)
 	var walletTransactions []domain.WalletTransaction = *walletTransactionsP
 	wallet.Balance = wallet.Balance.Sub(eventData.Amount)
+	wallet.AvailableBalance = wallet.AvailableBalance.Sub(eventData.Amount)
 	walletTransactions = append(walletTransactions, domain.WalletTransaction{
 		DebitWalletId:  wallet.ID,
 		CreditWalletId: eventData.CreditWalletId,
 		Amount:         eventData.Amount,
-		CreatedAt:      time.Now(),
+		CreatedAt:      evt.GetTimeStamp(),
 		Description:    eventData.Description,
+		ID:             GetTransactionID(evt),
 	})
 	e.Wallet = GetJsonString(wallet)
 	e.WalletTransactions = GetJsonString(walletTransactions)
@@ -152,32 +152,80 @@ func (c *WalletProjection) onWalletDebited(ctx context.Context, evt es.Event) er
 }
 
 func (c *WalletProjection) onWalletCreditReserved(ctx context.Context, evt es.Event) error {
-	span, ctx := opentracing.StartSpanFromContext(ctx, "cassandraProjection.onWalletCreditReserved")
-	defer span.Finish()
-	span.LogFields(log.String(constants.AggregateID, evt.GetAggregateID()))
+	ctx, span := tracing.StartSpan(ctx, "cassandraProjection.onWalletCreditReserved")
+	defer span.End()
+	span.SetAttributes(attribute.String(constants.AggregateID, evt.GetAggregateID()))
 	aggId := GetWalletAggregateID(evt.GetAggregateID())
-	var eventData v1.WalletCreditedEvent
+	var eventData v1.WalletCreditReservedEvent
 	if err := evt.GetJsonData(&eventData); err != nil {
 		tracing.TraceErr(span, err)
 		return errors.Wrap(err, "evt.GetJsonData")
 	}
-	//span.LogFields(log.String("WalletID", eventData.ID))
-	queries := make([]map[string]string, 1)
+	//span.SetAttributes(attribute.String("WalletID", eventData.ID))
+	queries := make([]map[string]string, 0, 1)
 	m := make(map[string]string)
 	m["column"] = constants.WalletID
 	m["compare"] = "="
 	m["value"] = aggId
 	queries = append(queries, m)
 	ent, err := c.Repo.GetByCondition(ctx, queries)
+	if err != nil {
+		return err
+	}
 	e := *ent
+
+	wallet, _ := GetEntityFromJsonString[domain.Wallet](e.Wallet)
+	wallet.AvailableBalance = wallet.AvailableBalance.Sub(eventData.Amount)
+	e.Wallet = GetJsonString(wallet)
+	update, err := c.Repo.Update(ctx, e, e.ID)
 	if err != nil {
 		return err
 	}
+	if update {
+		return nil
+	} else {
+		return errors.New("Not found")
+	}
+}
 
-	walletP, _ := GetEntityFromJsonString[domain.Wallet](e.Wallet)
-	var wallet domain.Wallet = *walletP
-	wallet.Balance = wallet.AvailableBalance.Sub(eventData.Amount)
+// onWalletScreened holds the amount of a quarantine; other screenings leave the read model
+// as it is.
+func (c *WalletProjection) onWalletScreened(ctx context.Context, evt es.Event) error {
+	ctx, span := tracing.StartSpan(ctx, "cassandraProjection.onWalletScreened")
+	defer span.End()
+	span.SetAttributes(attribute.String(constants.AggregateID, evt.GetAggregateID()))
+	aggId := GetWalletAggregateID(evt.GetAggregateID())
+	var eventData v1.WalletScreenedEvent
+	if err := evt.GetJsonData(&eventData); err != nil {
+		tracing.TraceErr(span, err)
+		return errors.Wrap(err, "evt.GetJsonData")
+	}
+	if eventData.Outcome != string(domain.ScreeningQuarantined) {
+		return nil
+	}
+	queries := make([]map[string]string, 0, 1)
+	m := make(map[string]string)
+	m["column"] = constants.WalletID
+	m["compare"] = "="
+	m["value"] = aggId
+	queries = append(queries, m)
+	ent, err := c.Repo.GetByCondition(ctx, queries)
+	if err != nil {
+		return err
+	}
+	e := *ent
+
+	wallet, _ := GetEntityFromJsonString[domain.Wallet](e.Wallet)
+	wallet.AvailableBalance = wallet.AvailableBalance.Sub(eventData.Amount)
 	e.Wallet = GetJsonString(wallet)
+	walletStateP, _ := GetEntityFromJsonString[domain.WalletState](e.WalletState)
+	var walletState = *walletStateP
+	walletState.OpenQuarantine(domain.Quarantine{
+		ScreeningID:          eventData.ScreeningID,
+		Amount:               eventData.Amount,
+		CounterpartyWalletId: eventData.CounterpartyWalletId,
+	})
+	e.WalletState = GetJsonString(walletState)
 	update, err := c.Repo.Update(ctx, e, e.ID)
 	if err != nil {
 		return err
@@ -189,30 +237,34 @@ func (c *WalletProjection) onWalletCreditReserved(ctx context.Context, evt es.Ev
 	}
 }
 func (c *WalletProjection) onWalletBlacklisted(ctx context.Context, evt es.Event) error {
-	span, ctx := opentracing.StartSpanFromContext(ctx, "cassandraProjection.onWalletBlacklisted")
-	defer span.Finish()
-	span.LogFields(log.String(constants.AggregateID, evt.GetAggregateID()))
+	ctx, span := tracing.StartSpan(ctx, "cassandraProjection.onWalletBlacklisted")
+	defer span.End()
+	span.SetAttributes(attribute.String(constants.AggregateID, evt.GetAggregateID()))
 	aggId := GetWalletAggregateID(evt.GetAggregateID())
 	var eventData v1.WalletBlacklistedEvent
 	if err := evt.GetJsonData(&eventData); err != nil {
 		tracing.TraceErr(span, err)
 		return errors.Wrap(err, "evt.GetJsonData")
 	}
-	queries := make([]map[string]string, 1)
+	queries := make([]map[string]string, 0, 1)
 	m := make(map[string]string)
 	m["column"] = constants.WalletID
 	m["compare"] = "="
 	m["value"] = aggId
 	queries = append(queries, m)
 	ent, err := c.Repo.GetByCondition(ctx, queries)
-	e := *ent
 	if err != nil {
 		return err
 	}
+	e := *ent
 
 	walletP, _ := GetEntityFromJsonString[domain.WalletState](e.WalletState)
 	var walletState = *walletP
-	walletState.IsBlacklisted = true
+	restriction := newRestriction(domain.RestrictionBlacklist, evt, eventData.ReasonCode, eventData.Authority, eventData.Description, eventData.Evidence, eventData.ExpiresAt)
+	if err := c.reindexExpiry(ctx, aggId, walletState.Restriction(domain.RestrictionBlacklist), &restriction); err != nil {
+		return err
+	}
+	walletState.Restrict(restriction)
 	e.WalletState = GetJsonString(walletState)
 	update, err := c.Repo.Update(ctx, e, e.ID)
 	if err != nil {
@@ -226,29 +278,32 @@ func (c *WalletProjection) onWalletBlacklisted(ctx context.Context, evt es.Event
 
 }
 func (c *WalletProjection) onWalletUnBlacklisted(ctx context.Context, evt es.Event) error {
-	span, ctx := opentracing.StartSpanFromContext(ctx, "cassandraProjection.onWalletUnBlacklisted")
-	defer span.Finish()
-	span.LogFields(log.String(constants.AggregateID, evt.GetAggregateID()))
+	ctx, span := tracing.StartSpan(ctx, "cassandraProjection.onWalletUnBlacklisted")
+	defer span.End()
+	span.SetAttributes(attribute.String(constants.AggregateID, evt.GetAggregateID()))
 	aggId := GetWalletAggregateID(evt.GetAggregateID())
 	var eventData v1.WalletUnBlacklistedEvent
 	if err := evt.GetJsonData(&eventData); err != nil {
 		tracing.TraceErr(span, err)
 		return errors.Wrap(err, "evt.GetJsonData")
 	}
-	queries := make([]map[string]string, 1)
+	queries := make([]map[string]string, 0, 1)
 	m := make(map[string]string)
 	m["column"] = constants.WalletID
 	m["compare"] = "="
 	m["value"] = aggId
 	queries = append(queries, m)
 	ent, err := c.Repo.GetByCondition(ctx, queries)
-	e := *ent
 	if err != nil {
 		return err
 	}
+	e := *ent
 	walletP, _ := GetEntityFromJsonString[domain.WalletState](e.WalletState)
 	var walletState = *walletP
-	walletState.IsBlacklisted = false
+	if err := c.reindexExpiry(ctx, aggId, walletState.Restriction(domain.RestrictionBlacklist), nil); err != nil {
+		return err
+	}
+	walletState.Lift(domain.RestrictionBlacklist)
 	e.WalletState = GetJsonString(walletState)
 	update, err := c.Repo.Update(ctx, e, e.ID)
 	if err != nil {
@@ -262,28 +317,33 @@ func (c *WalletProjection) onWalletUnBlacklisted(ctx context.Context, evt es.Eve
 
 }
 func (c *WalletProjection) onWalletDeleted(ctx context.Context, evt es.Event) error {
-	span, ctx := opentracing.StartSpanFromContext(ctx, "cassandraProjection.onWalletDeleted")
-	defer span.Finish()
-	span.LogFields(log.String(constants.AggregateID, evt.GetAggregateID()))
+	ctx, span := tracing.StartSpan(ctx, "cassandraProjection.onWalletDeleted")
+	defer span.End()
+	span.SetAttributes(attribute.String(constants.AggregateID, evt.GetAggregateID()))
 	aggId := GetWalletAggregateID(evt.GetAggregateID())
 	var eventData v1.WalletDeletedEvent
 	if err := evt.GetJsonData(&eventData); err != nil {
 		tracing.TraceErr(span, err)
 		return errors.Wrap(err, "evt.GetJsonData")
 	}
-	queries := make([]map[string]string, 1)
+	queries := make([]map[string]string, 0, 1)
 	m := make(map[string]string)
 	m["column"] = constants.WalletID
 	m["compare"] = "="
 	m["value"] = aggId
 	queries = append(queries, m)
 	ent, err := c.Repo.GetByCondition(ctx, queries)
-	e := *ent
 	if err != nil {
 		return err
 	}
+	e := *ent
 	walletP, _ := GetEntityFromJsonString[domain.WalletState](e.WalletState)
 	var walletState = *walletP
+	for i := range walletState.Restrictions {
+		if err := c.reindexExpiry(ctx, aggId, &walletState.Restrictions[i], nil); err != nil {
+			return err
+		}
+	}
 	walletState.IsDeleted = true
 	e.WalletState = GetJsonString(walletState)
 	update, err := c.Repo.Update(ctx, e, e.ID)
@@ -297,29 +357,33 @@ func (c *WalletProjection) onWalletDeleted(ctx context.Context, evt es.Event) er
 	}
 }
 func (c *WalletProjection) onWalletLocked(ctx context.Context, evt es.Event) error {
-	span, ctx := opentracing.StartSpanFromContext(ctx, "cassandraProjection.onWalletLocked")
-	defer span.Finish()
-	span.LogFields(log.String(constants.AggregateID, evt.GetAggregateID()))
+	ctx, span := tracing.StartSpan(ctx, "cassandraProjection.onWalletLocked")
+	defer span.End()
+	span.SetAttributes(attribute.String(constants.AggregateID, evt.GetAggregateID()))
 	aggId := GetWalletAggregateID(evt.GetAggregateID())
 	var eventData v1.WalletLockedEvent
 	if err := evt.GetJsonData(&eventData); err != nil {
 		tracing.TraceErr(span, err)
 		return errors.Wrap(err, "evt.GetJsonData")
 	}
-	queries := make([]map[string]string, 1)
+	queries := make([]map[string]string, 0, 1)
 	m := make(map[string]string)
 	m["column"] = constants.WalletID
 	m["compare"] = "="
 	m["value"] = aggId
 	queries = append(queries, m)
 	ent, err := c.Repo.GetByCondition(ctx, queries)
-	e := *ent
 	if err != nil {
 		return err
 	}
+	e := *ent
 	walletP, _ := GetEntityFromJsonString[domain.WalletState](e.WalletState)
 	var walletState = *walletP
-	walletState.IsLocked = true
+	restriction := newRestriction(domain.RestrictionLock, evt, eventData.ReasonCode, eventData.Authority, eventData.Description, eventData.Evidence, eventData.ExpiresAt)
+	if err := c.reindexExpiry(ctx, aggId, walletState.Restriction(domain.RestrictionLock), &restriction); err != nil {
+		return err
+	}
+	walletState.Restrict(restriction)
 	e.WalletState = GetJsonString(walletState)
 	update, err := c.Repo.Update(ctx, e, e.ID)
 	if err != nil {
@@ -333,29 +397,32 @@ func (c *WalletProjection) onWalletLocked(ctx context.Context, evt es.Event) err
 }
 
 func (c *WalletProjection) onWalletUnlocked(ctx context.Context, evt es.Event) error {
-	span, ctx := opentracing.StartSpanFromContext(ctx, "cassandraProjection.onWalletUnlocked")
-	defer span.Finish()
-	span.LogFields(log.String(constants.AggregateID, evt.GetAggregateID()))
+	ctx, span := tracing.StartSpan(ctx, "cassandraProjection.onWalletUnlocked")
+	defer span.End()
+	span.SetAttributes(attribute.String(constants.AggregateID, evt.GetAggregateID()))
 	aggId := GetWalletAggregateID(evt.GetAggregateID())
 	var eventData v1.WalletUnlockedEvent
 	if err := evt.GetJsonData(&eventData); err != nil {
 		tracing.TraceErr(span, err)
 		return errors.Wrap(err, "evt.GetJsonData")
 	}
-	queries := make([]map[string]string, 1)
+	queries := make([]map[string]string, 0, 1)
 	m := make(map[string]string)
 	m["column"] = constants.WalletID
 	m["compare"] = "="
 	m["value"] = aggId
 	queries = append(queries, m)
 	ent, err := c.Repo.GetByCondition(ctx, queries)
-	e := *ent
 	if err != nil {
 		return err
 	}
+	e := *ent
 	walletP, _ := GetEntityFromJsonString[domain.WalletState](e.WalletState)
 	var walletState = *walletP
-	walletState.IsLocked = false
+	if err := c.reindexExpiry(ctx, aggId, walletState.Restriction(domain.RestrictionLock), nil); err != nil {
+		return err
+	}
+	walletState.Lift(domain.RestrictionLock)
 	e.WalletState = GetJsonString(walletState)
 	update, err := c.Repo.Update(ctx, e, e.ID)
 	if err != nil {
@@ -369,32 +436,122 @@ func (c *WalletProjection) onWalletUnlocked(ctx context.Context, evt es.Event) e
 }
 
 func (c *WalletProjection) onWalletCreditReleased(ctx context.Context, evt es.Event) error {
-	span, ctx := opentracing.StartSpanFromContext(ctx, "cassandraProjection.onWalletCreditReleased")
-	defer span.Finish()
-	span.LogFields(log.String(constants.AggregateID, evt.GetAggregateID()))
+	ctx, span := tracing.StartSpan(ctx, "cassandraProjection.onWalletCreditReleased")
+	defer span.End()
+	span.SetAttributes(attribute.String(constants.AggregateID, evt.GetAggregateID()))
 	aggId := GetWalletAggregateID(evt.GetAggregateID())
-	var eventData v1.WalletCreditedEvent
+	var eventData v1.WalletCreditReleasedEvent
 	if err := evt.GetJsonData(&eventData); err != nil {
 		tracing.TraceErr(span, err)
 		return errors.Wrap(err, "evt.GetJsonData")
 	}
-	//span.LogFields(log.String("WalletID", eventData.ID))
-	queries := make([]map[string]string, 1)
+	//span.SetAttributes(attribute.String("WalletID", eventData.ID))
+	queries := make([]map[string]string, 0, 1)
 	m := make(map[string]string)
 	m["column"] = constants.WalletID
 	m["compare"] = "="
 	m["value"] = aggId
 	queries = append(queries, m)
 	ent, err := c.Repo.GetByCondition(ctx, queries)
+	if err != nil {
+		return err
+	}
 	e := *ent
+	wallet, _ := GetEntityFromJsonString[domain.Wallet](e.Wallet)
+	wallet.AvailableBalance = wallet.AvailableBalance.Add(eventData.Amount)
+	e.Wallet = GetJsonString(wallet)
+
+	update, err := c.Repo.Update(ctx, e, e.ID)
 	if err != nil {
 		return err
 	}
-	walletP, _ := GetEntityFromJsonString[domain.Wallet](e.WalletState)
-	var wallet domain.Wallet = *walletP
-	wallet.Balance = wallet.AvailableBalance.Add(eventData.Amount)
+	if update {
+		return nil
+	} else {
+		return errors.New("Not found")
+	}
+}
+
+func (c *WalletProjection) onWalletQuarantineReleased(ctx context.Context, evt es.Event) error {
+	ctx, span := tracing.StartSpan(ctx, "cassandraProjection.onWalletQuarantineReleased")
+	defer span.End()
+	span.SetAttributes(attribute.String(constants.AggregateID, evt.GetAggregateID()))
+	aggId := GetWalletAggregateID(evt.GetAggregateID())
+	var eventData v1.WalletQuarantineReleasedEvent
+	if err := evt.GetJsonData(&eventData); err != nil {
+		tracing.TraceErr(span, err)
+		return errors.Wrap(err, "evt.GetJsonData")
+	}
+	queries := make([]map[string]string, 0, 1)
+	m := make(map[string]string)
+	m["column"] = constants.WalletID
+	m["compare"] = "="
+	m["value"] = aggId
+	queries = append(queries, m)
+	ent, err := c.Repo.GetByCondition(ctx, queries)
+	if err != nil {
+		return err
+	}
+	e := *ent
+
+	wallet, _ := GetEntityFromJsonString[domain.Wallet](e.Wallet)
+	wallet.AvailableBalance = wallet.AvailableBalance.Add(eventData.Amount)
 	e.Wallet = GetJsonString(wallet)
+	walletStateP, _ := GetEntityFromJsonString[domain.WalletState](e.WalletState)
+	var walletState = *walletStateP
+	walletState.CloseQuarantine(eventData.ScreeningID)
+	e.WalletState = GetJsonString(walletState)
+	update, err := c.Repo.Update(ctx, e, e.ID)
+	if err != nil {
+		return err
+	}
+	if update {
+		return nil
+	} else {
+		return errors.New("Not found")
+	}
+}
+
+func (c *WalletProjection) onWalletQuarantineConfirmed(ctx context.Context, evt es.Event) error {
+	ctx, span := tracing.StartSpan(ctx, "cassandraProjection.onWalletQuarantineConfirmed")
+	defer span.End()
+	span.SetAttributes(attribute.String(constants.AggregateID, evt.GetAggregateID()))
+	aggId := GetWalletAggregateID(evt.GetAggregateID())
+	var eventData v1.WalletQuarantineConfirmedEvent
+	if err := evt.GetJsonData(&eventData); err != nil {
+		tracing.TraceErr(span, err)
+		return errors.Wrap(err, "evt.GetJsonData")
+	}
+	queries := make([]map[string]string, 0, 1)
+	m := make(map[string]string)
+	m["column"] = constants.WalletID
+	m["compare"] = "="
+	m["value"] = aggId
+	queries = append(queries, m)
+	ent, err := c.Repo.GetByCondition(ctx, queries)
+	if err != nil {
+		return err
+	}
+	e := *ent
 
+	walletTransactionsP, _ := GetEntityArrayFromJsonString[domain.WalletTransaction](e.WalletTransactions)
+	wallet, _ := GetEntityFromJsonString[domain.Wallet](e.Wallet)
+	var walletTransactions []domain.WalletTransaction = *walletTransactionsP
+	walletTransactions = append(walletTransactions, domain.WalletTransaction{
+		DebitWalletId:  wallet.ID,
+		CreditWalletId: eventData.CreditWalletId,
+		Amount:         eventData.Amount,
+		CreatedAt:      evt.GetTimeStamp(),
+		Description:    eventData.Description,
+		ID:             GetTransactionID(evt),
+	})
+	wallet.Balance = wallet.Balance.Sub(eventData.Amount)
+	e.Wallet = GetJsonString(wallet)
+	e.WalletTransactions = GetJsonString(walletTransactions)
+	walletStateP, _ := GetEntityFromJsonString[domain.WalletState](e.WalletState)
+	var walletState = *walletStateP
+	walletState.CloseQuarantine(eventData.ScreeningID)
+	e.WalletState = GetJsonString(walletState)
 	update, err := c.Repo.Update(ctx, e, e.ID)
 	if err != nil {
 		return err
@@ -405,3 +562,26 @@ func (c *WalletProjection) onWalletCreditReleased(ctx context.Context, evt es.Ev
 		return errors.New("Not found")
 	}
 }
+
+// reindexExpiry moves the expiry of the wallet's restriction from previous to next, either
+// of which may be nil. It runs before the row is updated, so an event retried after a
+// failed update finds previous again.
+func (c *WalletProjection) reindexExpiry(ctx context.Context, walletID string, previous, next *domain.Restriction) error {
+	if c.Expiries == nil {
+		return nil
+	}
+	if previous != nil && previous.ExpiresAt != nil &&
+		(next == nil || next.ExpiresAt == nil || !next.ExpiresAt.Equal(*previous.ExpiresAt)) {
+		expiry := models.RestrictionExpiry{Kind: string(previous.Kind), ExpiresAt: *previous.ExpiresAt, WalletID: walletID}
+		if err := c.Expiries.Remove(ctx, expiry); err != nil {
+			return errors.Wrap(err, "Expiries.Remove")
+		}
+	}
+	if next != nil && next.ExpiresAt != nil {
+		expiry := models.RestrictionExpiry{Kind: string(next.Kind), ExpiresAt: *next.ExpiresAt, WalletID: walletID}
+		if err := c.Expiries.Add(ctx, expiry); err != nil {
+			return errors.Wrap(err, "Expiries.Add")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,197 @@
+package aggregate
+
+import (
+	"context"
+	"math/rand"
+	"time"
+
+	"github.com/EventStore/EventStore-Client-Go/esdb"
+	"github.com/novabankapp/common.data/eventstore"
+	"github.com/novabankapp/wallet.data/constants"
+	"github.com/novabankapp/wallet.data/es/store"
+	"github.com/novabankapp/wallet.data/retry"
+	"github.com/novabankapp/wallet.data/tracing"
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// AttemptOutcome is how one load → command → save attempt of the executor ended.
+type AttemptOutcome string
+
+const (
+	AttemptCommitted AttemptOutcome = "committed"
+	// AttemptConflict means another writer appended to the stream first; the attempt is retried.
+	AttemptConflict AttemptOutcome = "conflict"
+	// AttemptRejected means the command itself returned an error, e.g. ErrInsufficientFunds.
+	AttemptRejected AttemptOutcome = "rejected"
+	// AttemptFailed means loading or saving failed for another reason.
+	AttemptFailed AttemptOutcome = "failed"
+)
+
+// ExecutorMetrics records every attempt of WalletCommandExecutor.
+type ExecutorMetrics interface {
+	ObserveAttempt(command string, attempt int, outcome AttemptOutcome, elapsed time.Duration)
+}
+
+type nopExecutorMetrics struct{}
+
+func (nopExecutorMetrics) ObserveAttempt(string, int, AttemptOutcome, time.Duration) {}
+
+const (
+	DefaultCommandAttempts = 5
+	DefaultSnapshotEvery   = 100
+)
+
+// WalletCommandExecutor runs a command against the current state of a wallet: it loads
+// the aggregate, calls the command and saves the new events. When another writer got to
+// the stream first the save fails with esdb.ErrWrongExpectedStreamRevision; the executor
+// then waits Backoff plus jitter, reloads and runs the command again, up to MaxAttempts
+// attempts in total. The command may therefore run more than once and must not have
+// side effects outside the aggregate.
+//
+// When Snapshots is set, a snapshot of the wallet is saved each time its stream grows
+// past a multiple of SnapshotEvery events, for LoadWalletAggregateAsOf to start from.
+type WalletCommandExecutor struct {
+	Store         eventstore.AggregateStore
+	MaxAttempts   int
+	Backoff       func(retry int) time.Duration
+	Metrics       ExecutorMetrics
+	Snapshots     store.SnapshotStore
+	SnapshotEvery int64
+}
+
+func NewWalletCommandExecutor(store eventstore.AggregateStore) *WalletCommandExecutor {
+	return &WalletCommandExecutor{
+		Store:         store,
+		MaxAttempts:   DefaultCommandAttempts,
+		Backoff:       retry.Exponential(10*time.Millisecond, 500*time.Millisecond),
+		Metrics:       nopExecutorMetrics{},
+		SnapshotEvery: DefaultSnapshotEvery,
+	}
+}
+
+// IsConcurrencyConflict reports whether err is a version conflict on save.
+func IsConcurrencyConflict(err error) bool {
+	return errors.Is(err, esdb.ErrWrongExpectedStreamRevision)
+}
+
+// Execute runs command against the wallet aggregateID and returns the saved aggregate.
+// name identifies the command in spans and metrics. Errors returned by the command are
+// returned as is; when every attempt conflicts the last conflict is returned wrapped.
+func (e *WalletCommandExecutor) Execute(ctx context.Context,
+	aggregateID string,
+	name string,
+	command func(ctx context.Context, wallet *WalletAggregate) error) (*WalletAggregate, error) {
+	ctx, span := tracing.StartSpan(ctx, "WalletCommandExecutor.Execute")
+	defer span.End()
+	span.SetAttributes(attribute.String(constants.AggregateID, aggregateID), attribute.String("Command", name))
+
+	maxAttempts := e.MaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = DefaultCommandAttempts
+	}
+	for attempt := 1; ; attempt++ {
+		wallet, outcome, err := e.attempt(ctx, aggregateID, name, attempt, command)
+		if outcome == AttemptCommitted {
+			span.SetAttributes(attribute.Int("Attempts", attempt))
+			return wallet, nil
+		}
+		if outcome != AttemptConflict {
+			tracing.TraceErr(span, err)
+			return nil, err
+		}
+		if attempt >= maxAttempts {
+			tracing.TraceErr(span, err)
+			return nil, errors.Wrapf(err, "%s: wallet %s still conflicting after %d attempts", name, aggregateID, attempt)
+		}
+		if err := e.wait(ctx, attempt); err != nil {
+			tracing.TraceErr(span, err)
+			return nil, err
+		}
+	}
+}
+
+func (e *WalletCommandExecutor) attempt(ctx context.Context,
+	aggregateID string,
+	name string,
+	attempt int,
+	command func(ctx context.Context, wallet *WalletAggregate) error) (wallet *WalletAggregate, outcome AttemptOutcome, err error) {
+	ctx, span := tracing.StartSpan(ctx, "WalletCommandExecutor.Attempt")
+	defer span.End()
+	span.SetAttributes(attribute.Int("attempt", attempt))
+	started := time.Now()
+	defer func() {
+		span.SetAttributes(attribute.String("outcome", string(outcome)))
+		e.metrics().ObserveAttempt(name, attempt, outcome, time.Since(started))
+	}()
+
+	wallet, err = LoadWalletAggregate(ctx, e.Store, aggregateID)
+	if err != nil {
+		tracing.TraceErr(span, err)
+		return nil, AttemptFailed, errors.Wrap(err, "LoadWalletAggregate")
+	}
+	loadedVersion := wallet.GetVersion()
+	if err := command(ctx, wallet); err != nil {
+		tracing.TraceErr(span, err)
+		return nil, AttemptRejected, err
+	}
+	events := wallet.GetUncommittedEvents()
+	if err := e.Store.Save(ctx, wallet); err != nil {
+		tracing.TraceErr(span, err)
+		if IsConcurrencyConflict(err) {
+			return nil, AttemptConflict, err
+		}
+		return nil, AttemptFailed, errors.Wrap(err, "Save")
+	}
+	if len(events) > 0 {
+		e.snapshot(ctx, wallet, loadedVersion, events[len(events)-1].GetTimeStamp())
+	}
+	return wallet, AttemptCommitted, nil
+}
+
+// snapshot saves a snapshot of the wallet when the events just saved took its stream past
+// a multiple of SnapshotEvery events. The events are committed by then, so a snapshot
+// that fails to save is only recorded on the span; the next multiple writes another.
+func (e *WalletCommandExecutor) snapshot(ctx context.Context, wallet *WalletAggregate, loadedVersion int64, lastEventAt time.Time) {
+	if e.Snapshots == nil || e.SnapshotEvery <= 0 {
+		return
+	}
+	// Versions start at 0, so a stream at version v holds v+1 events.
+	if (loadedVersion+1)/e.SnapshotEvery == (wallet.GetVersion()+1)/e.SnapshotEvery {
+		return
+	}
+	ctx, span := tracing.StartSpan(ctx, "WalletCommandExecutor.Snapshot")
+	defer span.End()
+	span.SetAttributes(attribute.Int64("Version", wallet.GetVersion()))
+	if err := SaveWalletSnapshot(ctx, e.Snapshots, wallet, lastEventAt); err != nil {
+		tracing.TraceErr(span, err)
+	}
+}
+
+// wait sleeps between Backoff(n)/2 and Backoff(n) so that writers that conflicted
+// together do not retry in lockstep.
+func (e *WalletCommandExecutor) wait(ctx context.Context, attempt int) error {
+	if e.Backoff == nil {
+		return ctx.Err()
+	}
+	wait := e.Backoff(attempt)
+	if wait <= 0 {
+		return ctx.Err()
+	}
+	wait = wait/2 + time.Duration(rand.Int63n(int64(wait/2)+1))
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+func (e *WalletCommandExecutor) metrics() ExecutorMetrics {
+	if e.Metrics == nil {
+		return nopExecutorMetrics{}
+	}
+	return e.Metrics
+}
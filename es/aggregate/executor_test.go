@@ -0,0 +1,167 @@
+package aggregate_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	es "github.com/novabankapp/common.data/eventstore"
+	"github.com/novabankapp/wallet.data/es/aggregate"
+	"github.com/novabankapp/wallet.data/es/store"
+	"github.com/pkg/errors"
+)
+
+// interferingStore lets another writer credit the wallet just before each of the first
+// interfere saves, so that those saves conflict.
+type interferingStore struct {
+	*store.MemoryEventStore
+	t         *testing.T
+	interfere int
+	saves     int
+}
+
+func (s *interferingStore) Save(ctx context.Context, agg es.Aggregate) error {
+	s.saves++
+	if s.saves <= s.interfere {
+		other, err := aggregate.LoadWalletAggregate(ctx, s.MemoryEventStore, walletID)
+		if err != nil {
+			s.t.Fatal(err)
+		}
+		if err := other.CreditWallet(ctx, otherID, amount("50"), "concurrent"); err != nil {
+			s.t.Fatal(err)
+		}
+		if err := s.MemoryEventStore.Save(ctx, other); err != nil {
+			s.t.Fatal(err)
+		}
+	}
+	return s.MemoryEventStore.Save(ctx, agg)
+}
+
+type attemptRecorder struct {
+	mu       sync.Mutex
+	outcomes []aggregate.AttemptOutcome
+}
+
+func (r *attemptRecorder) ObserveAttempt(command string, attempt int, outcome aggregate.AttemptOutcome, elapsed time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.outcomes = append(r.outcomes, outcome)
+}
+
+func newExecutor(t *testing.T, interfere int) (*aggregate.WalletCommandExecutor, *interferingStore, *attemptRecorder) {
+	t.Helper()
+	db := &interferingStore{MemoryEventStore: store.NewMemoryEventStore(), t: t, interfere: interfere}
+	wallet := aggregate.NewWalletAggregateWithID(walletID)
+	if err := wallet.CreateWallet(context.Background(), amount("100"), "opening", "user-1", "account-1", walletID); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.MemoryEventStore.Save(context.Background(), wallet); err != nil {
+		t.Fatal(err)
+	}
+	recorder := &attemptRecorder{}
+	executor := aggregate.NewWalletCommandExecutor(db)
+	executor.Backoff = nil
+	executor.Metrics = recorder
+	return executor, db, recorder
+}
+
+func debit(value string) func(context.Context, *aggregate.WalletAggregate) error {
+	return func(ctx context.Context, wallet *aggregate.WalletAggregate) error {
+		return wallet.DebitWallet(ctx, otherID, amount(value), "payment")
+	}
+}
+
+func TestExecutorRetriesConflictsAgainstFreshState(t *testing.T) {
+	executor, db, recorder := newExecutor(t, 1)
+
+	wallet, err := executor.Execute(context.Background(), walletID, "DebitWallet", debit("80"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the retry ran against the reloaded wallet, which includes the concurrent credit
+	if !wallet.Wallet.Balance.Equal(amount("70")) {
+		t.Fatalf("balance %s, want 70", wallet.Wallet.Balance)
+	}
+	stored, err := aggregate.LoadWalletAggregate(context.Background(), db.MemoryEventStore, walletID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !stored.Wallet.Balance.Equal(amount("70")) || stored.GetVersion() != 2 {
+		t.Fatalf("stored balance %s at version %d, want 70 at 2", stored.Wallet.Balance, stored.GetVersion())
+	}
+	want := []aggregate.AttemptOutcome{aggregate.AttemptConflict, aggregate.AttemptCommitted}
+	if len(recorder.outcomes) != len(want) || recorder.outcomes[0] != want[0] || recorder.outcomes[1] != want[1] {
+		t.Fatalf("outcomes %v, want %v", recorder.outcomes, want)
+	}
+}
+
+func TestExecutorDoesNotRetryRejectedCommands(t *testing.T) {
+	executor, _, recorder := newExecutor(t, 0)
+
+	calls := 0
+	_, err := executor.Execute(context.Background(), walletID, "DebitWallet", func(ctx context.Context, wallet *aggregate.WalletAggregate) error {
+		calls++
+		return debit("500")(ctx, wallet)
+	})
+	if !errors.Is(err, aggregate.ErrInsufficientFunds) {
+		t.Fatalf("got %v, want %v", err, aggregate.ErrInsufficientFunds)
+	}
+	if calls != 1 || len(recorder.outcomes) != 1 || recorder.outcomes[0] != aggregate.AttemptRejected {
+		t.Fatalf("command ran %d times with outcomes %v", calls, recorder.outcomes)
+	}
+}
+
+func TestExecutorGivesUpAfterMaxAttempts(t *testing.T) {
+	executor, db, recorder := newExecutor(t, 10)
+	executor.MaxAttempts = 3
+
+	_, err := executor.Execute(context.Background(), walletID, "DebitWallet", debit("10"))
+	if !aggregate.IsConcurrencyConflict(err) {
+		t.Fatalf("got %v, want a concurrency conflict", err)
+	}
+	if db.saves != 3 || len(recorder.outcomes) != 3 {
+		t.Fatalf("%d saves and %d recorded attempts, want 3", db.saves, len(recorder.outcomes))
+	}
+}
+
+func TestExecutorSerializesConcurrentWriters(t *testing.T) {
+	db := store.NewMemoryEventStore()
+	executor := aggregate.NewWalletCommandExecutor(db)
+	executor.MaxAttempts = 100
+	executor.Backoff = func(int) time.Duration { return time.Millisecond }
+	if _, err := executor.Execute(context.Background(), walletID, "CreateWallet", func(ctx context.Context, wallet *aggregate.WalletAggregate) error {
+		return wallet.CreateWallet(ctx, amount("0"), "opening", "user-1", "account-1", walletID)
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	const writers = 10
+	var wg sync.WaitGroup
+	errs := make(chan error, writers)
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := executor.Execute(context.Background(), walletID, "CreditWallet", func(ctx context.Context, wallet *aggregate.WalletAggregate) error {
+				return wallet.CreditWallet(ctx, otherID, amount("10"), "top up")
+			})
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	wallet, err := aggregate.LoadWalletAggregate(context.Background(), db, walletID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !wallet.Wallet.Balance.Equal(amount("100")) {
+		t.Fatalf("balance %s, want 100", wallet.Wallet.Balance)
+	}
+}
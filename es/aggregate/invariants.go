@@ -0,0 +1,163 @@
+package aggregate
+
+import (
+	"github.com/novabankapp/wallet.data/domain"
+	"github.com/shopspring/decimal"
+)
+
+// IsCreated reports whether the wallet's creation event has been applied.
+func (a *WalletAggregate) IsCreated() bool {
+	return a.created
+}
+
+// The checkCan* guards are the wallet's business rules: every command runs one against the
+// current state before it raises an event, and raises nothing when the guard fails.
+// TestWalletInvariants covers them.
+
+func (a *WalletAggregate) checkExists() error {
+	if !a.created {
+		return ErrWalletNotCreated
+	}
+	if a.WalletState.IsDeleted {
+		return ErrWalletDeleted
+	}
+	return nil
+}
+
+func checkAmount(amount decimal.Decimal) error {
+	if !amount.IsPositive() {
+		return ErrInvalidAmount
+	}
+	return nil
+}
+
+// checkCanCredit allows credits to locked wallets so that incoming money is not bounced;
+// blacklisted wallets receive nothing.
+func (a *WalletAggregate) checkCanCredit(amount decimal.Decimal) error {
+	if err := a.checkExists(); err != nil {
+		return err
+	}
+	if a.WalletState.IsBlacklisted {
+		return ErrWalletBlacklisted
+	}
+	return checkAmount(amount)
+}
+
+// checkCanSpend guards every operation that takes money out of the available balance.
+func (a *WalletAggregate) checkCanSpend(amount decimal.Decimal) error {
+	if err := a.checkExists(); err != nil {
+		return err
+	}
+	if a.WalletState.IsBlacklisted {
+		return ErrWalletBlacklisted
+	}
+	if a.WalletState.IsLocked {
+		return ErrWalletLocked
+	}
+	if err := checkAmount(amount); err != nil {
+		return err
+	}
+	if amount.GreaterThan(a.Wallet.AvailableBalance) {
+		return ErrInsufficientFunds
+	}
+	return nil
+}
+
+// checkCanRelease only releases reserved credit: the amounts held by quarantines are
+// released or confirmed by screening id.
+func (a *WalletAggregate) checkCanRelease(amount decimal.Decimal) error {
+	if err := a.checkExists(); err != nil {
+		return err
+	}
+	if err := checkAmount(amount); err != nil {
+		return err
+	}
+	if amount.GreaterThan(a.Wallet.GetHeldBalance().Sub(a.WalletState.Quarantined())) {
+		return ErrReleaseExceedsHeldBalance
+	}
+	return nil
+}
+
+// checkCanRecordScreening records hits on locked and blacklisted wallets too; a quarantine
+// only needs the funds it holds.
+func (a *WalletAggregate) checkCanRecordScreening(screening domain.Screening) error {
+	if err := a.checkExists(); err != nil {
+		return err
+	}
+	if screening.Outcome != domain.ScreeningQuarantined {
+		return nil
+	}
+	if a.WalletState.Quarantine(screening.ID) != nil {
+		return ErrQuarantineExists
+	}
+	if err := checkAmount(screening.Amount); err != nil {
+		return err
+	}
+	if screening.Amount.GreaterThan(a.Wallet.AvailableBalance) {
+		return ErrInsufficientFunds
+	}
+	return nil
+}
+
+// checkCanCloseQuarantine lets reviews release and confirm quarantines on locked and
+// blacklisted wallets too, and returns the quarantine.
+func (a *WalletAggregate) checkCanCloseQuarantine(screeningID string) (*domain.Quarantine, error) {
+	if err := a.checkExists(); err != nil {
+		return nil, err
+	}
+	quarantine := a.WalletState.Quarantine(screeningID)
+	if quarantine == nil {
+		return nil, ErrQuarantineNotFound
+	}
+	return quarantine, nil
+}
+
+func (a *WalletAggregate) checkCanCreate(amount decimal.Decimal) error {
+	if a.created {
+		return ErrWalletAlreadyCreated
+	}
+	if amount.IsNegative() {
+		return ErrInvalidAmount
+	}
+	return nil
+}
+
+func (a *WalletAggregate) checkCanLock() error {
+	if err := a.checkExists(); err != nil {
+		return err
+	}
+	if a.WalletState.IsLocked {
+		return ErrWalletLocked
+	}
+	return nil
+}
+
+func (a *WalletAggregate) checkCanUnlock() error {
+	if err := a.checkExists(); err != nil {
+		return err
+	}
+	if !a.WalletState.IsLocked {
+		return ErrWalletNotLocked
+	}
+	return nil
+}
+
+func (a *WalletAggregate) checkCanBlacklist() error {
+	if err := a.checkExists(); err != nil {
+		return err
+	}
+	if a.WalletState.IsBlacklisted {
+		return ErrWalletBlacklisted
+	}
+	return nil
+}
+
+func (a *WalletAggregate) checkCanUnBlacklist() error {
+	if err := a.checkExists(); err != nil {
+		return err
+	}
+	if !a.WalletState.IsBlacklisted {
+		return ErrWalletNotBlacklisted
+	}
+	return nil
+}
@@ -0,0 +1,177 @@
+package aggregate_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/novabankapp/wallet.data/domain"
+	"github.com/novabankapp/wallet.data/es/aggregate"
+	. "github.com/novabankapp/wallet.data/es/aggregate/aggregatetest"
+	eventsV1 "github.com/novabankapp/wallet.data/es/events/v1"
+)
+
+type command func(ctx context.Context, a *aggregate.WalletAggregate) error
+
+func create(value string) command {
+	return func(ctx context.Context, a *aggregate.WalletAggregate) error {
+		return a.CreateWallet(ctx, amount(value), "opening", "user-1", "account-1", walletID)
+	}
+}
+
+func reserve(value string) command {
+	return func(ctx context.Context, a *aggregate.WalletAggregate) error {
+		return a.ReserveWalletCredit(ctx, amount(value), "hold")
+	}
+}
+
+func release(value string) command {
+	return func(ctx context.Context, a *aggregate.WalletAggregate) error {
+		return a.ReleaseWalletCredit(ctx, amount(value), "release")
+	}
+}
+
+func quarantine(value string) command {
+	return func(ctx context.Context, a *aggregate.WalletAggregate) error {
+		return a.RecordScreening(ctx, domain.Screening{ID: "s-1", Outcome: domain.ScreeningQuarantined, Command: "DebitWallet", Amount: amount(value)})
+	}
+}
+
+func releaseQuarantine(screeningID string) command {
+	return func(ctx context.Context, a *aggregate.WalletAggregate) error {
+		return a.ReleaseQuarantine(ctx, screeningID, "cleared")
+	}
+}
+
+func confirmQuarantine(screeningID string) command {
+	return func(ctx context.Context, a *aggregate.WalletAggregate) error {
+		return a.ConfirmQuarantine(ctx, screeningID, otherID, "upheld")
+	}
+}
+
+func block(ctx context.Context, a *aggregate.WalletAggregate) error {
+	return a.RecordScreening(ctx, domain.Screening{ID: "s-1", Outcome: domain.ScreeningBlocked, Command: "DebitWallet", Amount: amount("1")})
+}
+
+func lock(ctx context.Context, a *aggregate.WalletAggregate) error {
+	return a.LockWallet(ctx, domain.Restriction{Description: "review"})
+}
+
+func unlock(ctx context.Context, a *aggregate.WalletAggregate) error {
+	return a.UnlockWallet(ctx, "cleared")
+}
+
+func blacklist(ctx context.Context, a *aggregate.WalletAggregate) error {
+	return a.BlacklistWallet(ctx, domain.Restriction{Description: "fraud"})
+}
+
+func unBlacklist(ctx context.Context, a *aggregate.WalletAggregate) error {
+	return a.UnBlacklistWallet(ctx, "cleared")
+}
+
+func remove(ctx context.Context, a *aggregate.WalletAggregate) error {
+	return a.DeleteWallet(ctx, "closed")
+}
+
+// TestWalletInvariants covers the business rules every wallet command is checked against
+// before it raises an event. A rejected command raises nothing.
+func TestWalletInvariants(t *testing.T) {
+	deleted := []Event{created("100"), WalletDeleted("closed")}
+	held := []Event{created("100"), WalletCreditReserved(amount("80"), "hold")}
+	quarantined := []Event{created("100"), quarantinedBy("s-1", "30")}
+
+	for _, tc := range []struct {
+		name    string
+		given   []Event
+		command command
+		err     error
+	}{
+		{"create: only once", []Event{created("100")}, create("5"), aggregate.ErrWalletAlreadyCreated},
+		{"create: no negative opening balance", nil, create("-1"), aggregate.ErrInvalidAmount},
+
+		{"credit: missing wallet", nil, credit("10"), aggregate.ErrWalletNotCreated},
+		{"credit: deleted wallet", deleted, credit("10"), aggregate.ErrWalletDeleted},
+		{"credit: blacklisted wallet", []Event{created("100"), blacklisted("fraud")}, credit("10"), aggregate.ErrWalletBlacklisted},
+		{"credit: zero amount", []Event{created("100")}, credit("0"), aggregate.ErrInvalidAmount},
+		{"credit: negative amount", []Event{created("100")}, credit("-5"), aggregate.ErrInvalidAmount},
+
+		{"debit: missing wallet", nil, debit("10"), aggregate.ErrWalletNotCreated},
+		{"debit: deleted wallet", deleted, debit("10"), aggregate.ErrWalletDeleted},
+		{"debit: locked wallet", []Event{created("100"), locked("review")}, debit("10"), aggregate.ErrWalletLocked},
+		{"debit: blacklisted wallet", []Event{created("100"), blacklisted("fraud")}, debit("10"), aggregate.ErrWalletBlacklisted},
+		{"debit: blacklisting wins over a lock", []Event{created("100"), locked("review"), blacklisted("fraud")}, debit("10"), aggregate.ErrWalletBlacklisted},
+		{"debit: zero amount", []Event{created("100")}, debit("0"), aggregate.ErrInvalidAmount},
+		{"debit: more than the balance", []Event{created("100")}, debit("100.01"), aggregate.ErrInsufficientFunds},
+		{"debit: held funds", held, debit("40"), aggregate.ErrInsufficientFunds},
+
+		{"reserve: missing wallet", nil, reserve("10"), aggregate.ErrWalletNotCreated},
+		{"reserve: locked wallet", []Event{created("100"), locked("review")}, reserve("10"), aggregate.ErrWalletLocked},
+		{"reserve: blacklisted wallet", []Event{created("100"), blacklisted("fraud")}, reserve("10"), aggregate.ErrWalletBlacklisted},
+		{"reserve: negative amount", []Event{created("100")}, reserve("-10"), aggregate.ErrInvalidAmount},
+		{"reserve: held funds", held, reserve("30"), aggregate.ErrInsufficientFunds},
+
+		{"release: missing wallet", nil, release("1"), aggregate.ErrWalletNotCreated},
+		{"release: zero amount", held, release("0"), aggregate.ErrInvalidAmount},
+		{"release: nothing held", []Event{created("100")}, release("1"), aggregate.ErrReleaseExceedsHeldBalance},
+		{"release: more than is held", held, release("80.01"), aggregate.ErrReleaseExceedsHeldBalance},
+		{"release: quarantined funds", quarantined, release("1"), aggregate.ErrReleaseExceedsHeldBalance},
+
+		{"screening: missing wallet", nil, block, aggregate.ErrWalletNotCreated},
+		{"screening: deleted wallet", deleted, block, aggregate.ErrWalletDeleted},
+		{"screening: quarantine of a zero amount", []Event{created("100")}, quarantine("0"), aggregate.ErrInvalidAmount},
+		{"screening: quarantine of held funds", held, quarantine("30"), aggregate.ErrInsufficientFunds},
+		{"screening: quarantine twice", quarantined, quarantine("30"), aggregate.ErrQuarantineExists},
+
+		{"release quarantine: missing wallet", nil, releaseQuarantine("s-1"), aggregate.ErrWalletNotCreated},
+		{"release quarantine: unknown screening", quarantined, releaseQuarantine("s-2"), aggregate.ErrQuarantineNotFound},
+		{"release quarantine: already released", []Event{created("100"), quarantinedBy("s-1", "30"), WalletQuarantineReleased(eventsV1.WalletQuarantineReleasedEvent{ScreeningID: "s-1", Amount: amount("30")})}, releaseQuarantine("s-1"), aggregate.ErrQuarantineNotFound},
+		{"confirm quarantine: missing wallet", nil, confirmQuarantine("s-1"), aggregate.ErrWalletNotCreated},
+		{"confirm quarantine: unknown screening", quarantined, confirmQuarantine("s-2"), aggregate.ErrQuarantineNotFound},
+		{"confirm quarantine: a block", []Event{created("100"), WalletScreened(eventsV1.WalletScreenedEvent{ScreeningID: "s-1", Outcome: string(domain.ScreeningBlocked), Amount: amount("30")})}, confirmQuarantine("s-1"), aggregate.ErrQuarantineNotFound},
+
+		{"lock: missing wallet", nil, lock, aggregate.ErrWalletNotCreated},
+		{"lock: deleted wallet", deleted, lock, aggregate.ErrWalletDeleted},
+		{"lock: already locked", []Event{created("100"), locked("review")}, lock, aggregate.ErrWalletLocked},
+		{"unlock: missing wallet", nil, unlock, aggregate.ErrWalletNotCreated},
+		{"unlock: not locked", []Event{created("100")}, unlock, aggregate.ErrWalletNotLocked},
+
+		{"blacklist: missing wallet", nil, blacklist, aggregate.ErrWalletNotCreated},
+		{"blacklist: already blacklisted", []Event{created("100"), blacklisted("fraud")}, blacklist, aggregate.ErrWalletBlacklisted},
+		{"unblacklist: missing wallet", nil, unBlacklist, aggregate.ErrWalletNotCreated},
+		{"unblacklist: not blacklisted", []Event{created("100")}, unBlacklist, aggregate.ErrWalletNotBlacklisted},
+
+		{"delete: missing wallet", nil, remove, aggregate.ErrWalletNotCreated},
+		{"delete: already deleted", deleted, remove, aggregate.ErrWalletDeleted},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			ForWallet(t, walletID).Given(tc.given...).When(tc.command).ThenError(tc.err)
+		})
+	}
+}
+
+// TestWalletInvariantsAllow covers the states the rules deliberately let through.
+func TestWalletInvariantsAllow(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		given   []Event
+		command command
+	}{
+		{"create: an empty wallet", nil, create("0")},
+		{"credit: a locked wallet", []Event{created("100"), locked("review")}, credit("10")},
+		{"debit: the whole available balance", []Event{created("100")}, debit("100")},
+		{"debit: an unlocked wallet", []Event{created("100"), locked("review"), WalletUnlocked("cleared")}, debit("10")},
+		{"release: on a locked wallet", []Event{created("100"), WalletCreditReserved(amount("30"), "hold"), locked("review")}, release("30")},
+		{"screening: quarantine on a locked wallet", []Event{created("100"), locked("review")}, quarantine("30")},
+		{"screening: block on a blacklisted wallet", []Event{created("100"), blacklisted("fraud")}, block},
+		{"release quarantine: a blacklisted wallet", []Event{created("100"), quarantinedBy("s-1", "30"), blacklisted("fraud")}, releaseQuarantine("s-1")},
+		{"confirm quarantine: a locked wallet", []Event{created("100"), quarantinedBy("s-1", "30"), locked("review")}, confirmQuarantine("s-1")},
+		{"blacklist: a locked wallet", []Event{created("100"), locked("review")}, blacklist},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			ForWallet(t, walletID).Given(tc.given...).When(tc.command).ThenState(func(t testing.TB, a *aggregate.WalletAggregate) {
+				if len(a.GetUncommittedEvents()) != 1 {
+					t.Errorf("raised %d events", len(a.GetUncommittedEvents()))
+				}
+			})
+		})
+	}
+}
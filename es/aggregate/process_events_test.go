@@ -0,0 +1,325 @@
+package aggregate_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/EventStore/EventStore-Client-Go/esdb"
+	es "github.com/novabankapp/common.data/eventstore"
+	"github.com/novabankapp/common.data/eventstore/projections"
+	"github.com/novabankapp/wallet.data/constants"
+	"github.com/novabankapp/wallet.data/domain"
+	"github.com/novabankapp/wallet.data/es/aggregate"
+	v1 "github.com/novabankapp/wallet.data/es/events/v1"
+	"github.com/novabankapp/wallet.data/es/models"
+	"github.com/novabankapp/wallet.data/es/parking"
+	"github.com/novabankapp/wallet.data/es/readmodel/readmodeltest"
+	"github.com/novabankapp/wallet.data/es/store"
+	"github.com/novabankapp/wallet.data/retry"
+	"github.com/pkg/errors"
+	"github.com/shopspring/decimal"
+)
+
+const projectionGroup = "wallet-projection"
+
+// flakyRepo fails updates while fail returns an error.
+type flakyRepo struct {
+	*readmodeltest.ReadModel
+	mu      sync.Mutex
+	fail    func() error
+	updates int
+}
+
+func (r *flakyRepo) Update(ctx context.Context, entity models.WalletProjection, id string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.updates++
+	if r.fail != nil {
+		if err := r.fail(); err != nil {
+			return false, err
+		}
+	}
+	return r.ReadModel.Update(ctx, entity, id)
+}
+
+func (r *flakyRepo) balance(t *testing.T) decimal.Decimal {
+	t.Helper()
+	row, err := r.ReadModel.GetByCondition(context.Background(), []map[string]string{{"column": constants.WalletID, "compare": "=", "value": walletID}})
+	if err != nil {
+		return decimal.Zero
+	}
+	wallet, err := aggregate.GetEntityFromJsonString[domain.Wallet](row.Wallet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return wallet.Balance
+}
+
+// countingMetrics counts what the projection reports, by event type.
+type countingMetrics struct {
+	mu        sync.Mutex
+	handled   map[string]int
+	failed    map[string]int
+	acks      map[string]int
+	nacks     map[esdb.Nack_Action]int
+	parked    map[string]int
+	processed uint64
+}
+
+func newCountingMetrics() *countingMetrics {
+	return &countingMetrics{
+		handled: make(map[string]int),
+		failed:  make(map[string]int),
+		acks:    make(map[string]int),
+		nacks:   make(map[esdb.Nack_Action]int),
+		parked:  make(map[string]int),
+	}
+}
+
+func (m *countingMetrics) ObserveHandler(eventType string, _ time.Duration, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.handled[eventType]++
+	if err != nil {
+		m.failed[eventType]++
+	}
+}
+
+func (m *countingMetrics) ObserveAck(eventType string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.acks[eventType]++
+}
+
+func (m *countingMetrics) ObserveNack(_ string, action esdb.Nack_Action) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.nacks[action]++
+}
+
+func (m *countingMetrics) ObserveParked(eventType string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.parked[eventType]++
+}
+
+func (m *countingMetrics) ObserveProcessed(position uint64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.processed = position
+}
+
+type projectionFixture struct {
+	db         *store.MemoryEventStore
+	repo       *flakyRepo
+	lot        *parking.MemoryLot
+	projection *aggregate.WalletProjection
+	wallet     *aggregate.WalletAggregate
+	metrics    *countingMetrics
+}
+
+func newProjectionFixture(t *testing.T) *projectionFixture {
+	t.Helper()
+	f := &projectionFixture{
+		db:      store.NewMemoryEventStore(),
+		repo:    &flakyRepo{ReadModel: readmodeltest.New()},
+		lot:     parking.NewMemoryLot(),
+		wallet:  aggregate.NewWalletAggregateWithID(walletID),
+		metrics: newCountingMetrics(),
+	}
+	if err := f.db.CreatePersistentSubscription(projectionGroup, store.PersistentSubscriptionSettings{MaxRetryCount: 1}); err != nil {
+		t.Fatal(err)
+	}
+	f.projection = &aggregate.WalletProjection{
+		CassandraProjection: projections.CassandraProjection{
+			Log: readmodeltest.NopLogger{},
+			Cfg: &projections.Config{CassandraProjectionGroupName: projectionGroup},
+		},
+		Repo:        f.repo,
+		RetryPolicy: &retry.Policy{MaxAttempts: 3, Backoff: func(int) time.Duration { return time.Millisecond }},
+		Parking:     f.lot,
+		Metrics:     f.metrics,
+	}
+	return f
+}
+
+func (f *projectionFixture) do(t *testing.T, command func(ctx context.Context, a *aggregate.WalletAggregate) error) {
+	t.Helper()
+	ctx := context.Background()
+	if err := command(ctx, f.wallet); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.db.Save(ctx, f.wallet); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// appendPoison appends a credit whose payload cannot be decoded.
+func (f *projectionFixture) appendPoison(t *testing.T) {
+	t.Helper()
+	evt := es.NewBaseEvent(f.wallet, v1.WalletCredited)
+	evt.Data = []byte(`{"Amount": `)
+	version, err := f.db.AppendToStream(context.Background(), f.wallet.GetID(), f.wallet.GetVersion(), evt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The aggregate cannot apply the event itself; move it past the event as a reload
+	// that skipped it would.
+	f.wallet.Version = version
+}
+
+// run processes events until done reports true, then stops the projection.
+func (f *projectionFixture) run(t *testing.T, done func() bool) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	subscription, err := f.db.ConnectToPersistentSubscription(ctx, projectionGroup)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := make(chan error, 1)
+	go func() { result <- f.projection.ProcessEvents(ctx, subscription, 0) }()
+
+	deadline := time.After(5 * time.Second)
+	for !done() {
+		select {
+		case err := <-result:
+			cancel()
+			t.Fatalf("ProcessEvents stopped: %v", err)
+		case <-deadline:
+			cancel()
+			t.Fatal("timed out")
+		case <-time.After(time.Millisecond):
+		}
+	}
+	cancel()
+	if err := <-result; !errors.Is(err, context.Canceled) {
+		t.Fatalf("ProcessEvents returned %v", err)
+	}
+	_ = subscription.Close()
+}
+
+func (f *projectionFixture) parked(t *testing.T) []parking.Entry {
+	t.Helper()
+	entries, err := f.lot.List(context.Background(), projectionGroup, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return entries
+}
+
+func credit(value string) func(ctx context.Context, a *aggregate.WalletAggregate) error {
+	return func(ctx context.Context, a *aggregate.WalletAggregate) error {
+		return a.CreditWallet(ctx, otherID, amount(value), "credit")
+	}
+}
+
+func createWallet(ctx context.Context, a *aggregate.WalletAggregate) error {
+	return a.CreateWallet(ctx, amount("100"), "opening", "user-1", "account-1", walletID)
+}
+
+func TestProcessEventsParksPoisonEventsAndCarriesOn(t *testing.T) {
+	f := newProjectionFixture(t)
+	f.do(t, createWallet)
+	f.appendPoison(t)
+	f.do(t, credit("5"))
+
+	f.run(t, func() bool { return f.repo.balance(t).Equal(amount("105")) })
+
+	parked := f.parked(t)
+	if len(parked) != 1 {
+		t.Fatalf("%d parked events", len(parked))
+	}
+	entry := parked[0]
+	if entry.EventType != v1.WalletCredited || entry.EventNumber != 1 || entry.Class != retry.Permanent || entry.Attempts != 1 {
+		t.Errorf("parked %+v", entry)
+	}
+	if entry.Error == "" || entry.Stack == "" {
+		t.Error("parked event has no error context")
+	}
+	if parkedOnServer, _ := f.db.ParkedEvents(projectionGroup); len(parkedOnServer) != 0 {
+		t.Errorf("%d events also parked on the server", len(parkedOnServer))
+	}
+
+	m := f.metrics
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.acks[v1.WalletCreated] != 1 || m.acks[v1.WalletCredited] != 2 || m.parked[v1.WalletCredited] != 1 || len(m.nacks) != 0 {
+		t.Errorf("acks %v parked %v nacks %v", m.acks, m.parked, m.nacks)
+	}
+	if m.failed[v1.WalletCredited] != 1 || m.handled[v1.WalletCredited] != 2 {
+		t.Errorf("handled %v failed %v", m.handled, m.failed)
+	}
+	if head, _ := f.db.HeadPosition(context.Background()); m.processed != head {
+		t.Errorf("processed position %d, head %d", m.processed, head)
+	}
+}
+
+func TestProcessEventsRetriesTransientErrors(t *testing.T) {
+	f := newProjectionFixture(t)
+	f.do(t, createWallet)
+	f.do(t, credit("5"))
+	failures := 2
+	f.repo.fail = func() error {
+		if failures > 0 {
+			failures--
+			return errors.New("cassandra unavailable")
+		}
+		return nil
+	}
+
+	f.run(t, func() bool { return f.repo.balance(t).Equal(amount("105")) })
+
+	if parked := f.parked(t); len(parked) != 0 {
+		t.Errorf("parked %+v", parked)
+	}
+	if f.repo.updates != 3 {
+		t.Errorf("%d updates, want 3", f.repo.updates)
+	}
+}
+
+func TestProcessEventsParksEventsThatExhaustTheirRetries(t *testing.T) {
+	f := newProjectionFixture(t)
+	f.do(t, createWallet)
+	f.do(t, credit("5"))
+	f.repo.fail = func() error { return errors.New("cassandra unavailable") }
+
+	f.run(t, func() bool { return len(f.parked(t)) == 1 })
+
+	entry := f.parked(t)[0]
+	if entry.Class != retry.Transient || entry.Attempts != 3 || entry.Error != "cassandra unavailable" {
+		t.Errorf("parked %+v", entry)
+	}
+
+	f.repo.fail = nil
+	ops := parking.NewOps(f.lot, f.projection.When)
+	if err := ops.Replay(context.Background(), entry.ID); err != nil {
+		t.Fatal(err)
+	}
+	if !f.repo.balance(t).Equal(amount("105")) {
+		t.Errorf("balance after replay %s", f.repo.balance(t))
+	}
+	if len(f.parked(t)) != 0 {
+		t.Error("replayed event is still parked")
+	}
+}
+
+func TestProcessEventsParksOnTheServerWithoutALot(t *testing.T) {
+	f := newProjectionFixture(t)
+	f.projection.Parking = nil
+	f.do(t, createWallet)
+	f.appendPoison(t)
+
+	f.run(t, func() bool {
+		parked, _ := f.db.ParkedEvents(projectionGroup)
+		return len(parked) == 1
+	})
+
+	m := f.metrics
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.nacks[esdb.Nack_Park] != 1 || m.parked[v1.WalletCredited] != 1 {
+		t.Errorf("nacks %v parked %v", m.nacks, m.parked)
+	}
+}
@@ -6,23 +6,87 @@ import (
 	es "github.com/novabankapp/common.data/eventstore"
 	"github.com/novabankapp/common.data/eventstore/projections"
 	"github.com/novabankapp/common.data/repositories/base"
-	"github.com/novabankapp/common.data/tracing"
 	v1 "github.com/novabankapp/wallet.data/es/events/v1"
 	"github.com/novabankapp/wallet.data/es/models"
-	"github.com/opentracing/opentracing-go/log"
+	"github.com/novabankapp/wallet.data/es/parking"
+	"github.com/novabankapp/wallet.data/es/partition"
+	"github.com/novabankapp/wallet.data/es/pii"
+	"github.com/novabankapp/wallet.data/es/store"
+	"github.com/novabankapp/wallet.data/retry"
+	"github.com/novabankapp/wallet.data/tracing"
 	"github.com/pkg/errors"
+	"time"
 )
 
 const (
 	CassProjection = "(CassandraDB Projection)"
 )
 
+// ProjectionMetrics records how WalletProjection handles events. Positions are commit
+// positions in $all, comparable with store.HeadReader.
+type ProjectionMetrics interface {
+	// ObserveHandler records one call of When, including each retry.
+	ObserveHandler(eventType string, elapsed time.Duration, err error)
+	ObserveAck(eventType string)
+	ObserveNack(eventType string, action esdb.Nack_Action)
+	// ObserveParked records an event that exhausted its retries, parked either in the
+	// parking lot or on the server.
+	ObserveParked(eventType string)
+	// ObserveProcessed records the position of the last event the projection is done with.
+	ObserveProcessed(position uint64)
+}
+
+type nopProjectionMetrics struct{}
+
+func (nopProjectionMetrics) ObserveHandler(string, time.Duration, error) {}
+func (nopProjectionMetrics) ObserveAck(string)                           {}
+func (nopProjectionMetrics) ObserveNack(string, esdb.Nack_Action)        {}
+func (nopProjectionMetrics) ObserveParked(string)                        {}
+func (nopProjectionMetrics) ObserveProcessed(uint64)                     {}
+
 type WalletProjection struct {
 	projections.CassandraProjection
 	Repo base.NoSqlRepository[models.WalletProjection]
+	// RetryPolicy decides how often a failing event is retried before it is parked;
+	// retry.DefaultPolicy() when nil.
+	RetryPolicy *retry.Policy
+	// Parking keeps events that exhausted their retries. When nil they are parked on the
+	// server with Nack_Park instead, where they can only be replayed all at once.
+	Parking parking.Lot
+	// Metrics is not recorded when nil.
+	Metrics ProjectionMetrics
+	// PersonalData decrypts the personal data of events before they are projected, so the
+	// read model holds plain values, or pii.Redacted for erased users. When nil events are
+	// projected as stored.
+	PersonalData *pii.Cipher
+	// Expiries is kept in step with the restrictions that expire, for the lifter to find
+	// them without reading every row. It is not kept when nil.
+	Expiries RestrictionExpiries
 }
 
-func (c *WalletProjection) ProcessEvents(ctx context.Context, stream *esdb.PersistentSubscription, workerID int) error {
+// RestrictionExpiries indexes the active restrictions that have an expiry. Add and Remove
+// are idempotent, so an event that is retried can write them again.
+type RestrictionExpiries interface {
+	Add(ctx context.Context, expiry models.RestrictionExpiry) error
+	Remove(ctx context.Context, expiry models.RestrictionExpiry) error
+}
+
+func (c *WalletProjection) metrics() ProjectionMetrics {
+	if c.Metrics == nil {
+		return nopProjectionMetrics{}
+	}
+	return c.Metrics
+}
+
+// position is the commit position of an event delivered by a subscription.
+func position(resolved *esdb.ResolvedEvent) uint64 {
+	if resolved.Commit != nil {
+		return *resolved.Commit
+	}
+	return resolved.OriginalEvent().Position.Commit
+}
+
+func (c *WalletProjection) ProcessEvents(ctx context.Context, stream store.PersistentSubscription, workerID int) error {
 
 	for {
 		event := stream.Recv()
@@ -39,30 +103,95 @@ func (c *WalletProjection) ProcessEvents(ctx context.Context, stream *esdb.Persi
 
 		if event.EventAppeared != nil {
 			c.Log.ProjectionEvent(CassProjection, c.Cfg.CassandraProjectionGroupName, event.EventAppeared, workerID)
+			if err := c.handle(ctx, stream, event.EventAppeared); err != nil {
+				return err
+			}
+		}
+	}
+}
 
-			err := c.When(ctx, es.NewEventFromRecorded(event.EventAppeared.Event))
-			if err != nil {
-				c.Log.Errorf("(CassProjection.when) err: {%v}", err)
+// ProcessEventsPartitioned is ProcessEvents spread over the runner's workers. Events of
+// one wallet always go to the same worker, in order, so the read-modify-write handlers
+// never race on a row; retries of one wallet's event only hold up that worker.
+func (c *WalletProjection) ProcessEventsPartitioned(ctx context.Context, stream store.PersistentSubscription, runner *partition.Runner) error {
+	return runner.Run(ctx, stream, func(ctx context.Context, stream store.PersistentSubscription, workerID int, event *esdb.ResolvedEvent) error {
+		c.Log.ProjectionEvent(CassProjection, c.Cfg.CassandraProjectionGroupName, event, workerID)
+		return c.handle(ctx, stream, event)
+	})
+}
 
-				if err := stream.Nack(err.Error(), esdb.Nack_Retry, event.EventAppeared); err != nil {
-					c.Log.Errorf("(stream.Nack) err: {%v}", err)
-					return errors.Wrap(err, "stream.Nack")
-				}
-			}
+// handle applies one event, retrying per the retry policy in place so later events of
+// the same wallet cannot overtake it. An event that still fails is parked and acked, so
+// one poison event does not stop the projection; it is never both nacked and acked.
+func (c *WalletProjection) handle(ctx context.Context, stream store.PersistentSubscription, resolved *esdb.ResolvedEvent) error {
+	policy := retry.DefaultPolicy()
+	if c.RetryPolicy != nil {
+		policy = *c.RetryPolicy
+	}
+	result := policy.Do(ctx, func(ctx context.Context) error {
+		return c.When(ctx, es.NewEventFromRecorded(resolved.Event))
+	})
+	metrics := c.metrics()
+	eventType := resolved.Event.EventType
+	if result.Err == nil {
+		if err := stream.Ack(resolved); err != nil {
+			c.Log.Errorf("(stream.Ack) err: {%v}", err)
+			return errors.Wrap(err, "stream.Ack")
+		}
+		metrics.ObserveAck(eventType)
+		metrics.ObserveProcessed(position(resolved))
+		c.Log.Infof("(ACK) event commit: {%v}", position(resolved))
+		return nil
+	}
+	if ctx.Err() != nil {
+		// Shutting down: leave the event unacked so the group delivers it again.
+		return ctx.Err()
+	}
 
-			err = stream.Ack(event.EventAppeared)
-			if err != nil {
-				c.Log.Errorf("(stream.Ack) err: {%v}", err)
-				return errors.Wrap(err, "stream.Ack")
-			}
-			c.Log.Infof("(ACK) event commit: {%v}", *event.EventAppeared.Commit)
+	c.Log.Errorf("(CassProjection.when) event: {%s} attempts: {%d} class: {%s} err: {%v}", resolved.Event.EventID, result.Attempts, result.Class, result.Err)
+	if c.Parking == nil {
+		if err := stream.Nack(result.Err.Error(), esdb.Nack_Park, resolved); err != nil {
+			c.Log.Errorf("(stream.Nack) err: {%v}", err)
+			return errors.Wrap(err, "stream.Nack")
 		}
+		metrics.ObserveNack(eventType, esdb.Nack_Park)
+		metrics.ObserveParked(eventType)
+		metrics.ObserveProcessed(position(resolved))
+		return nil
 	}
+	entry := parking.NewEntry(c.Cfg.CassandraProjectionGroupName, resolved.Event, result, time.Now())
+	if err := c.Parking.Park(ctx, entry); err != nil {
+		// Without a parked copy the event must not be acked; let the group retry it.
+		c.Log.Errorf("(Parking.Park) err: {%v}", err)
+		if err := stream.Nack(err.Error(), esdb.Nack_Retry, resolved); err != nil {
+			return errors.Wrap(err, "stream.Nack")
+		}
+		metrics.ObserveNack(eventType, esdb.Nack_Retry)
+		return nil
+	}
+	c.Log.Warnf("(PARKED) event: {%s} parked as {%s}", resolved.Event.EventID, entry.ID)
+	metrics.ObserveParked(eventType)
+	if err := stream.Ack(resolved); err != nil {
+		c.Log.Errorf("(stream.Ack) err: {%v}", err)
+		return errors.Wrap(err, "stream.Ack")
+	}
+	metrics.ObserveAck(eventType)
+	metrics.ObserveProcessed(position(resolved))
+	return nil
 }
-func (c *WalletProjection) When(ctx context.Context, evt es.Event) error {
-	ctx, span := tracing.StartProjectionTracerSpan(ctx, "cassandraProjection.When", evt)
-	defer span.Finish()
-	span.LogFields(log.String("AggregateID", evt.GetAggregateID()), log.String("EventType", evt.GetEventType()))
+
+func (c *WalletProjection) When(ctx context.Context, evt es.Event) (err error) {
+	ctx, span := tracing.StartEventSpan(ctx, "cassandraProjection.When", evt)
+	defer span.End()
+	started := time.Now()
+	defer func() { c.metrics().ObserveHandler(evt.GetEventType(), time.Since(started), err) }()
+
+	if c.PersonalData != nil {
+		if evt, err = c.PersonalData.Reveal(ctx, evt); err != nil {
+			tracing.TraceErr(span, err)
+			return errors.Wrap(err, "Reveal")
+		}
+	}
 
 	switch evt.GetEventType() {
 
@@ -84,6 +213,14 @@ func (c *WalletProjection) When(ctx context.Context, evt es.Event) error {
 		return c.onWalletUnlocked(ctx, evt)
 	case v1.WalletCreditReleased:
 		return c.onWalletCreditReleased(ctx, evt)
+	case v1.WalletDeleted:
+		return c.onWalletDeleted(ctx, evt)
+	case v1.WalletScreened:
+		return c.onWalletScreened(ctx, evt)
+	case v1.WalletQuarantineReleased:
+		return c.onWalletQuarantineReleased(ctx, evt)
+	case v1.WalletQuarantineConfirmed:
+		return c.onWalletQuarantineConfirmed(ctx, evt)
 
 	default:
 		c.CassandraProjection.Log.Warnf("(cassandraProjection) [When unknown EventType] eventType: {%s}", evt.EventType)
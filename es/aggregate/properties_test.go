@@ -0,0 +1,434 @@
+package aggregate_test
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+
+	es "github.com/novabankapp/common.data/eventstore"
+	"github.com/novabankapp/wallet.data/constants"
+	"github.com/novabankapp/wallet.data/domain"
+	"github.com/novabankapp/wallet.data/es/aggregate"
+	"github.com/novabankapp/wallet.data/es/readmodel/readmodeltest"
+	"github.com/novabankapp/wallet.data/es/store"
+	"github.com/pkg/errors"
+	"github.com/shopspring/decimal"
+	"pgregory.net/rapid"
+)
+
+// The properties below run random command sequences against a wallet and check, after
+// every command and once more after the sequence:
+//
+//   - the balance is the opening amount plus all credits minus all debits;
+//   - the available balance never exceeds the balance, never goes negative, and the
+//     difference is exactly what is still reserved;
+//   - a command is rejected exactly when the model says it must be;
+//   - replaying the stream rebuilds the same wallet, every time;
+//   - the read model projected from the stream agrees with the aggregate.
+//
+// On failure rapid shrinks the sequence to a minimal one before reporting it.
+
+type walletModel struct {
+	created      bool
+	locked       bool
+	blacklisted  bool
+	deleted      bool
+	opening      decimal.Decimal
+	credits      decimal.Decimal
+	debits       decimal.Decimal
+	reserved     decimal.Decimal
+	released     decimal.Decimal
+	transactions int
+}
+
+func (m *walletModel) balance() decimal.Decimal {
+	return m.opening.Add(m.credits).Sub(m.debits)
+}
+
+func (m *walletModel) available() decimal.Decimal {
+	return m.balance().Sub(m.reserved.Sub(m.released))
+}
+
+func (m *walletModel) exists() error {
+	if !m.created {
+		return aggregate.ErrWalletNotCreated
+	}
+	if m.deleted {
+		return aggregate.ErrWalletDeleted
+	}
+	return nil
+}
+
+func (m *walletModel) canSpend(amount decimal.Decimal) error {
+	if err := m.exists(); err != nil {
+		return err
+	}
+	switch {
+	case m.blacklisted:
+		return aggregate.ErrWalletBlacklisted
+	case m.locked:
+		return aggregate.ErrWalletLocked
+	case !amount.IsPositive():
+		return aggregate.ErrInvalidAmount
+	case amount.GreaterThan(m.available()):
+		return aggregate.ErrInsufficientFunds
+	}
+	return nil
+}
+
+type walletCommand struct {
+	name string
+	// expect returns the error the command must fail with, or nil when it must succeed.
+	expect func(m *walletModel, amount decimal.Decimal) error
+	run    func(ctx context.Context, a *aggregate.WalletAggregate, amount decimal.Decimal) error
+	apply  func(m *walletModel, amount decimal.Decimal)
+}
+
+// GoString names the command in rapid's failure reports.
+func (c walletCommand) GoString() string {
+	return c.name
+}
+
+var walletCommands = []walletCommand{
+	{
+		name: "create",
+		expect: func(m *walletModel, amount decimal.Decimal) error {
+			if m.created {
+				return aggregate.ErrWalletAlreadyCreated
+			}
+			if amount.IsNegative() {
+				return aggregate.ErrInvalidAmount
+			}
+			return nil
+		},
+		run: func(ctx context.Context, a *aggregate.WalletAggregate, amount decimal.Decimal) error {
+			return a.CreateWallet(ctx, amount, "opening", "user-1", "account-1", walletID)
+		},
+		apply: func(m *walletModel, amount decimal.Decimal) {
+			m.created = true
+			m.opening = amount
+		},
+	},
+	{
+		name: "credit",
+		expect: func(m *walletModel, amount decimal.Decimal) error {
+			if err := m.exists(); err != nil {
+				return err
+			}
+			if m.blacklisted {
+				return aggregate.ErrWalletBlacklisted
+			}
+			if !amount.IsPositive() {
+				return aggregate.ErrInvalidAmount
+			}
+			return nil
+		},
+		run: func(ctx context.Context, a *aggregate.WalletAggregate, amount decimal.Decimal) error {
+			return a.CreditWallet(ctx, otherID, amount, "credit")
+		},
+		apply: func(m *walletModel, amount decimal.Decimal) {
+			m.credits = m.credits.Add(amount)
+			m.transactions++
+		},
+	},
+	{
+		name:   "debit",
+		expect: (*walletModel).canSpend,
+		run: func(ctx context.Context, a *aggregate.WalletAggregate, amount decimal.Decimal) error {
+			return a.DebitWallet(ctx, otherID, amount, "debit")
+		},
+		apply: func(m *walletModel, amount decimal.Decimal) {
+			m.debits = m.debits.Add(amount)
+			m.transactions++
+		},
+	},
+	{
+		name:   "reserve",
+		expect: (*walletModel).canSpend,
+		run: func(ctx context.Context, a *aggregate.WalletAggregate, amount decimal.Decimal) error {
+			return a.ReserveWalletCredit(ctx, amount, "reserve")
+		},
+		apply: func(m *walletModel, amount decimal.Decimal) {
+			m.reserved = m.reserved.Add(amount)
+		},
+	},
+	{
+		name: "release",
+		expect: func(m *walletModel, amount decimal.Decimal) error {
+			if err := m.exists(); err != nil {
+				return err
+			}
+			if !amount.IsPositive() {
+				return aggregate.ErrInvalidAmount
+			}
+			if amount.GreaterThan(m.reserved.Sub(m.released)) {
+				return aggregate.ErrReleaseExceedsHeldBalance
+			}
+			return nil
+		},
+		run: func(ctx context.Context, a *aggregate.WalletAggregate, amount decimal.Decimal) error {
+			return a.ReleaseWalletCredit(ctx, amount, "release")
+		},
+		apply: func(m *walletModel, amount decimal.Decimal) {
+			m.released = m.released.Add(amount)
+		},
+	},
+	{
+		name: "lock",
+		expect: func(m *walletModel, _ decimal.Decimal) error {
+			if err := m.exists(); err != nil {
+				return err
+			}
+			if m.locked {
+				return aggregate.ErrWalletLocked
+			}
+			return nil
+		},
+		run: func(ctx context.Context, a *aggregate.WalletAggregate, _ decimal.Decimal) error {
+			return a.LockWallet(ctx, domain.Restriction{Description: "lock"})
+		},
+		apply: func(m *walletModel, _ decimal.Decimal) { m.locked = true },
+	},
+	{
+		name: "unlock",
+		expect: func(m *walletModel, _ decimal.Decimal) error {
+			if err := m.exists(); err != nil {
+				return err
+			}
+			if !m.locked {
+				return aggregate.ErrWalletNotLocked
+			}
+			return nil
+		},
+		run: func(ctx context.Context, a *aggregate.WalletAggregate, _ decimal.Decimal) error {
+			return a.UnlockWallet(ctx, "unlock")
+		},
+		apply: func(m *walletModel, _ decimal.Decimal) { m.locked = false },
+	},
+	{
+		name: "blacklist",
+		expect: func(m *walletModel, _ decimal.Decimal) error {
+			if err := m.exists(); err != nil {
+				return err
+			}
+			if m.blacklisted {
+				return aggregate.ErrWalletBlacklisted
+			}
+			return nil
+		},
+		run: func(ctx context.Context, a *aggregate.WalletAggregate, _ decimal.Decimal) error {
+			return a.BlacklistWallet(ctx, domain.Restriction{Description: "blacklist"})
+		},
+		apply: func(m *walletModel, _ decimal.Decimal) { m.blacklisted = true },
+	},
+	{
+		name: "unblacklist",
+		expect: func(m *walletModel, _ decimal.Decimal) error {
+			if err := m.exists(); err != nil {
+				return err
+			}
+			if !m.blacklisted {
+				return aggregate.ErrWalletNotBlacklisted
+			}
+			return nil
+		},
+		run: func(ctx context.Context, a *aggregate.WalletAggregate, _ decimal.Decimal) error {
+			return a.UnBlacklistWallet(ctx, "unblacklist")
+		},
+		apply: func(m *walletModel, _ decimal.Decimal) { m.blacklisted = false },
+	},
+}
+
+func drawAmount(t *rapid.T) decimal.Decimal {
+	return decimal.New(rapid.Int64Range(-100, 1_000_000).Draw(t, "cents"), -2)
+}
+
+func TestWalletAggregateProperties(t *testing.T) {
+	rapid.Check(t, func(t *rapid.T) {
+		ctx := context.Background()
+		wallet := aggregate.NewWalletAggregateWithID(walletID)
+		model := &walletModel{}
+
+		// Most sequences start with a creation; the rest check that nothing works before it.
+		if rapid.IntRange(0, 9).Draw(t, "create first") > 0 {
+			amount := decimal.New(rapid.Int64Range(0, 1_000_000).Draw(t, "opening cents"), -2)
+			if err := wallet.CreateWallet(ctx, amount, "opening", "user-1", "account-1", walletID); err != nil {
+				t.Fatalf("create: %v", err)
+			}
+			walletCommands[0].apply(model, amount)
+		}
+
+		steps := rapid.IntRange(0, 40).Draw(t, "steps")
+		for i := 0; i < steps; i++ {
+			cmd := rapid.SampledFrom(walletCommands).Draw(t, "command")
+			amount := drawAmount(t)
+			expected := cmd.expect(model, amount)
+
+			err := cmd.run(ctx, wallet, amount)
+			switch {
+			case expected == nil && err != nil:
+				t.Fatalf("step %d %s %s: unexpected error %v", i, cmd.name, amount, err)
+			case expected != nil && !errors.Is(err, expected):
+				t.Fatalf("step %d %s %s: expected %v, got %v", i, cmd.name, amount, expected, err)
+			case expected == nil:
+				cmd.apply(model, amount)
+			}
+			checkBalances(t, wallet, model)
+		}
+
+		if !model.created {
+			return
+		}
+		events := save(t, wallet)
+		checkReplay(t, events, wallet)
+		checkProjection(t, events, wallet)
+	})
+}
+
+func TestWalletAggregatePropertiesSurviveDeletion(t *testing.T) {
+	rapid.Check(t, func(t *rapid.T) {
+		ctx := context.Background()
+		wallet := aggregate.NewWalletAggregateWithID(walletID)
+		if err := wallet.CreateWallet(ctx, decimal.NewFromInt(100), "opening", "user-1", "account-1", walletID); err != nil {
+			t.Fatalf("create: %v", err)
+		}
+		if err := wallet.DeleteWallet(ctx, "closed"); err != nil {
+			t.Fatalf("delete: %v", err)
+		}
+		cmd := rapid.SampledFrom(walletCommands[1:]).Draw(t, "command")
+		if err := cmd.run(ctx, wallet, drawAmount(t)); !errors.Is(err, aggregate.ErrWalletDeleted) {
+			t.Fatalf("%s on a deleted wallet: expected %v, got %v", cmd.name, aggregate.ErrWalletDeleted, err)
+		}
+	})
+}
+
+func checkBalances(t *rapid.T, wallet *aggregate.WalletAggregate, model *walletModel) {
+	balance, available := wallet.Wallet.GetBalance()
+	if !balance.Equal(model.balance()) {
+		t.Fatalf("balance %s, expected opening + credits - debits = %s", balance, model.balance())
+	}
+	if available.GreaterThan(balance) {
+		t.Fatalf("available %s exceeds balance %s", available, balance)
+	}
+	if available.IsNegative() {
+		t.Fatalf("available %s is negative", available)
+	}
+	if held := model.reserved.Sub(model.released); !wallet.Wallet.GetHeldBalance().Equal(held) {
+		t.Fatalf("held %s, expected reserved - released = %s", wallet.Wallet.GetHeldBalance(), held)
+	}
+	if len(*wallet.WalletTransactions) != model.transactions {
+		t.Fatalf("%d transactions, expected %d", len(*wallet.WalletTransactions), model.transactions)
+	}
+	if wallet.WalletState.IsLocked != model.locked || wallet.WalletState.IsBlacklisted != model.blacklisted {
+		t.Fatalf("state %+v, expected locked=%v blacklisted=%v", *wallet.WalletState, model.locked, model.blacklisted)
+	}
+}
+
+func save(t *rapid.T, wallet *aggregate.WalletAggregate) []es.Event {
+	ctx := context.Background()
+	db := store.NewMemoryEventStore()
+	if err := db.Save(ctx, wallet); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	events, err := db.ReadEvents(ctx, wallet.GetID(), 0)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	return events
+}
+
+func replay(t *rapid.T, events []es.Event) *aggregate.WalletAggregate {
+	wallet := aggregate.NewWalletAggregateWithID(walletID)
+	for _, evt := range events {
+		if err := wallet.RaiseEvent(evt); err != nil {
+			t.Fatalf("replay %s: %v", evt.GetEventType(), err)
+		}
+	}
+	return wallet
+}
+
+func checkReplay(t *rapid.T, events []es.Event, wallet *aggregate.WalletAggregate) {
+	first := replay(t, events)
+	second := replay(t, events)
+	for _, replayed := range []*aggregate.WalletAggregate{first, second} {
+		if diff := diffWallets(wallet.Wallet, replayed.Wallet); diff != "" {
+			t.Fatalf("replay: %s", diff)
+		}
+		if !reflect.DeepEqual(*replayed.WalletState, *wallet.WalletState) {
+			t.Fatalf("replay: state %+v, expected %+v", *replayed.WalletState, *wallet.WalletState)
+		}
+		if diff := diffTransactions(*wallet.WalletTransactions, *replayed.WalletTransactions); diff != "" {
+			t.Fatalf("replay: %s", diff)
+		}
+		if replayed.GetVersion() != wallet.GetVersion() {
+			t.Fatalf("replay: version %d, expected %d", replayed.GetVersion(), wallet.GetVersion())
+		}
+	}
+}
+
+func checkProjection(t *rapid.T, events []es.Event, wallet *aggregate.WalletAggregate) {
+	ctx := context.Background()
+	repo := readmodeltest.New()
+	projection := &aggregate.WalletProjection{Repo: repo}
+	for _, evt := range events {
+		if err := projection.When(ctx, evt); err != nil {
+			t.Fatalf("project %s: %v", evt.GetEventType(), err)
+		}
+	}
+
+	row, err := repo.GetByCondition(ctx, []map[string]string{{"column": constants.WalletID, "compare": "=", "value": walletID}})
+	if err != nil {
+		t.Fatalf("read model: %v", err)
+	}
+	projected, err := aggregate.GetEntityFromJsonString[domain.Wallet](row.Wallet)
+	if err != nil {
+		t.Fatalf("read model wallet: %v", err)
+	}
+	if diff := diffWallets(wallet.Wallet, projected); diff != "" {
+		t.Fatalf("read model: %s", diff)
+	}
+	state, err := aggregate.GetEntityFromJsonString[domain.WalletState](row.WalletState)
+	if err != nil {
+		t.Fatalf("read model state: %v", err)
+	}
+	if state.IsLocked != wallet.WalletState.IsLocked || state.IsBlacklisted != wallet.WalletState.IsBlacklisted || state.IsDeleted != wallet.WalletState.IsDeleted ||
+		len(state.Restrictions) != len(wallet.WalletState.Restrictions) {
+		t.Fatalf("read model: state %+v, expected %+v", *state, *wallet.WalletState)
+	}
+	transactions, err := aggregate.GetEntityArrayFromJsonString[domain.WalletTransaction](row.WalletTransactions)
+	if err != nil {
+		t.Fatalf("read model transactions: %v", err)
+	}
+	if diff := diffTransactions(*wallet.WalletTransactions, *transactions); diff != "" {
+		t.Fatalf("read model: %s", diff)
+	}
+}
+
+func diffWallets(expected, actual *domain.Wallet) string {
+	switch {
+	case actual.ID != expected.ID || actual.UserId != expected.UserId || actual.AccountId != expected.AccountId:
+		return fmt.Sprintf("wallet %s/%s/%s, expected %s/%s/%s", actual.ID, actual.UserId, actual.AccountId, expected.ID, expected.UserId, expected.AccountId)
+	case !actual.Balance.Equal(expected.Balance):
+		return fmt.Sprintf("balance %s, expected %s", actual.Balance, expected.Balance)
+	case !actual.AvailableBalance.Equal(expected.AvailableBalance):
+		return fmt.Sprintf("available %s, expected %s", actual.AvailableBalance, expected.AvailableBalance)
+	case !actual.CreatedAt.Equal(expected.CreatedAt):
+		return fmt.Sprintf("created at %s, expected %s", actual.CreatedAt, expected.CreatedAt)
+	}
+	return ""
+}
+
+func diffTransactions(expected, actual []domain.WalletTransaction) string {
+	if len(actual) != len(expected) {
+		return fmt.Sprintf("%d transactions, expected %d", len(actual), len(expected))
+	}
+	for i := range expected {
+		e, a := expected[i], actual[i]
+		if a.ID != e.ID || a.DebitWalletId != e.DebitWalletId || a.CreditWalletId != e.CreditWalletId ||
+			!a.Amount.Equal(e.Amount) || !a.CreatedAt.Equal(e.CreatedAt) || a.Description != e.Description {
+			return fmt.Sprintf("transaction %d is %+v, expected %+v", i, a, e)
+		}
+	}
+	return ""
+}
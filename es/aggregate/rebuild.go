@@ -0,0 +1,56 @@
+package aggregate
+
+import (
+	"context"
+
+	"github.com/gocql/gocql"
+	"github.com/novabankapp/wallet.data/constants"
+	"github.com/novabankapp/wallet.data/es/store"
+	"github.com/novabankapp/wallet.data/tracing"
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// Rebuild replaces a wallet's read model row with one projected from its whole stream and
+// returns the number of events applied. It is meant for repairs while the wallet is quiet:
+// an event the subscription applies during the rebuild may be applied twice.
+func (c *WalletProjection) Rebuild(ctx context.Context, reader store.EventReader, walletId string) (int, error) {
+	ctx, span := tracing.StartSpan(ctx, "cassandraProjection.Rebuild")
+	defer span.End()
+	span.SetAttributes(attribute.String(constants.WalletID, walletId))
+
+	events, err := reader.ReadEvents(ctx, GetWalletStreamID(walletId), 0)
+	if err != nil {
+		tracing.TraceErr(span, err)
+		return 0, errors.Wrap(err, "reader.ReadEvents")
+	}
+	if len(events) == 0 {
+		return 0, ErrWalletNotCreated
+	}
+
+	conditions := []map[string]string{{
+		"column":  constants.WalletID,
+		"compare": "=",
+		"value":   walletId,
+	}}
+	row, err := c.Repo.GetByCondition(ctx, conditions)
+	switch {
+	case errors.Is(err, gocql.ErrNotFound):
+	case err != nil:
+		tracing.TraceErr(span, err)
+		return 0, errors.Wrap(err, "Repo.GetByCondition")
+	default:
+		if _, err := c.Repo.Delete(ctx, row.ID); err != nil {
+			tracing.TraceErr(span, err)
+			return 0, errors.Wrap(err, "Repo.Delete")
+		}
+	}
+
+	for i, evt := range events {
+		if err := c.When(ctx, evt); err != nil {
+			tracing.TraceErr(span, err)
+			return i, errors.Wrapf(err, "event %d (%s)", evt.GetVersion(), evt.GetEventType())
+		}
+	}
+	return len(events), nil
+}
@@ -0,0 +1,144 @@
+package aggregate_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gocql/gocql"
+	"github.com/novabankapp/wallet.data/domain"
+	"github.com/novabankapp/wallet.data/es/aggregate"
+	"github.com/novabankapp/wallet.data/es/models"
+	"github.com/novabankapp/wallet.data/es/pii"
+	"github.com/pkg/errors"
+)
+
+func TestRebuildReplacesAStaleRow(t *testing.T) {
+	f := newProjectionFixture(t)
+	f.do(t, createWallet)
+	f.do(t, credit("25"))
+	f.do(t, credit("5"))
+
+	// A row the projection got wrong, e.g. because it missed an event.
+	ctx := context.Background()
+	if _, err := f.repo.Create(ctx, models.WalletProjection{ID: "stale", WalletID: walletID, Wallet: `{"Balance":"100"}`}); err != nil {
+		t.Fatal(err)
+	}
+
+	applied, err := f.projection.Rebuild(ctx, f.db, walletID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if applied != 3 {
+		t.Fatalf("applied %d events, expected 3", applied)
+	}
+	if _, err := f.repo.GetById(ctx, "stale"); !errors.Is(err, gocql.ErrNotFound) {
+		t.Fatalf("stale row was not removed: %v", err)
+	}
+	if rows := f.repo.Rows(); len(rows) != 1 {
+		t.Fatalf("%d rows, expected 1", len(rows))
+	}
+	if got := f.repo.balance(t); !got.Equal(amount("130")) {
+		t.Fatalf("balance %s, expected 130", got)
+	}
+}
+
+func TestRebuildOfAnUnknownWallet(t *testing.T) {
+	f := newProjectionFixture(t)
+	if _, err := f.projection.Rebuild(context.Background(), f.db, "missing"); !errors.Is(err, aggregate.ErrWalletNotCreated) {
+		t.Fatalf("expected %v, got %v", aggregate.ErrWalletNotCreated, err)
+	}
+}
+
+func TestRebuildAfterErasureRedactsPersonalData(t *testing.T) {
+	f := newProjectionFixture(t)
+	keys := pii.NewMemoryKeyStore()
+	f.projection.PersonalData = pii.NewCipher(keys)
+	protected := f.projection.PersonalData.ProtectStore(f.db)
+	ctx := context.Background()
+	for _, command := range []func(context.Context, *aggregate.WalletAggregate) error{createWallet, credit("25"), credit("5")} {
+		if err := command(ctx, f.wallet); err != nil {
+			t.Fatal(err)
+		}
+		if err := protected.Save(ctx, f.wallet); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	rebuild := func() (models.WalletProjection, []domain.WalletTransaction) {
+		t.Helper()
+		if _, err := f.projection.Rebuild(ctx, f.db, walletID); err != nil {
+			t.Fatal(err)
+		}
+		for _, row := range f.repo.Rows() {
+			transactions, err := aggregate.GetEntityArrayFromJsonString[domain.WalletTransaction](row.WalletTransactions)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return row, *transactions
+		}
+		t.Fatal("no row")
+		return models.WalletProjection{}, nil
+	}
+
+	row, transactions := rebuild()
+	if row.UserID != "user-1" || transactions[0].Description != "credit" {
+		t.Fatalf("before erasure: user %q, description %q", row.UserID, transactions[0].Description)
+	}
+
+	if err := keys.Shred(ctx, "user-1"); err != nil {
+		t.Fatal(err)
+	}
+	row, transactions = rebuild()
+	if row.UserID != pii.Redacted || transactions[0].Description != pii.Redacted {
+		t.Fatalf("after erasure: user %q, description %q", row.UserID, transactions[0].Description)
+	}
+	if got := f.repo.balance(t); !got.Equal(amount("130")) {
+		t.Fatalf("balance %s, expected 130", got)
+	}
+}
+
+// expiries is a RestrictionExpiries in a map.
+type expiries map[models.RestrictionExpiry]bool
+
+func (e expiries) Add(ctx context.Context, expiry models.RestrictionExpiry) error {
+	e[expiry] = true
+	return nil
+}
+
+func (e expiries) Remove(ctx context.Context, expiry models.RestrictionExpiry) error {
+	delete(e, expiry)
+	return nil
+}
+
+func TestProjectionKeepsTheExpiriesOfActiveRestrictions(t *testing.T) {
+	f := newProjectionFixture(t)
+	index := expiries{}
+	f.projection.Expiries = index
+	inAnHour, tomorrow := time.Now().Add(time.Hour).UTC(), time.Now().Add(24*time.Hour).UTC()
+	f.do(t, createWallet)
+	f.do(t, func(ctx context.Context, a *aggregate.WalletAggregate) error {
+		return a.LockWallet(ctx, domain.Restriction{Description: "review", ExpiresAt: &inAnHour})
+	})
+	f.do(t, func(ctx context.Context, a *aggregate.WalletAggregate) error {
+		return a.BlacklistWallet(ctx, domain.Restriction{Description: "designation", ExpiresAt: &tomorrow})
+	})
+	f.do(t, unlock)
+
+	ctx := context.Background()
+	if _, err := f.projection.Rebuild(ctx, f.db, walletID); err != nil {
+		t.Fatal(err)
+	}
+	want := models.RestrictionExpiry{Kind: string(domain.RestrictionBlacklist), ExpiresAt: tomorrow, WalletID: walletID}
+	if len(index) != 1 || !index[want] {
+		t.Fatalf("expiries %v, want only %v", index, want)
+	}
+
+	f.do(t, remove)
+	if _, err := f.projection.Rebuild(ctx, f.db, walletID); err != nil {
+		t.Fatal(err)
+	}
+	if len(index) != 0 {
+		t.Fatalf("expiries %v after the wallet was deleted", index)
+	}
+}
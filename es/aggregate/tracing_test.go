@@ -0,0 +1,63 @@
+package aggregate_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/novabankapp/wallet.data/tracing"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestProjectionContinuesTheCommandTrace(t *testing.T) {
+	spans := tracetest.NewSpanRecorder()
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spans)))
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+
+	f := newProjectionFixture(t)
+	ctx, request := tracing.StartSpan(context.Background(), "request")
+	if err := createWallet(ctx, f.wallet); err != nil {
+		t.Fatal(err)
+	}
+	if err := credit("5")(ctx, f.wallet); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.db.Save(ctx, f.wallet); err != nil {
+		t.Fatal(err)
+	}
+	request.End()
+
+	events, err := f.db.ReadEvents(context.Background(), f.wallet.GetID(), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, evt := range events {
+		if err := f.projection.When(context.Background(), evt); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	byID := make(map[string]sdktrace.ReadOnlySpan)
+	for _, span := range spans.Ended() {
+		byID[span.SpanContext().SpanID().String()] = span
+	}
+	handled := 0
+	for _, span := range spans.Ended() {
+		if span.Name() != "cassandraProjection.When" {
+			continue
+		}
+		handled++
+		if span.SpanContext().TraceID() != request.SpanContext().TraceID() {
+			t.Errorf("projection span is in trace %s, not the request's", span.SpanContext().TraceID())
+		}
+		parent, ok := byID[span.Parent().SpanID().String()]
+		if !ok || (parent.Name() != "WalletAggregate.CreateWallet" && parent.Name() != "WalletAggregate.CreditWallet") {
+			t.Errorf("projection span's parent is %v, not the command that wrote the event", span.Parent())
+		}
+	}
+	if handled != len(events) {
+		t.Fatalf("%d projection spans for %d events", handled, len(events))
+	}
+}
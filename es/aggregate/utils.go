@@ -4,11 +4,13 @@ import (
 	"context"
 	"encoding/json"
 	"github.com/EventStore/EventStore-Client-Go/esdb"
+	"github.com/gocql/gocql"
 	base "github.com/novabankapp/common.data/domain/base"
 	"github.com/novabankapp/common.data/eventstore"
-	"github.com/opentracing/opentracing-go"
-	"github.com/opentracing/opentracing-go/log"
+	"github.com/novabankapp/wallet.data/es/metadata"
+	"github.com/novabankapp/wallet.data/tracing"
 	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel/attribute"
 	"strings"
 )
 
@@ -17,6 +19,32 @@ func GetWalletAggregateID(eventAggregateID string) string {
 	return strings.ReplaceAll(eventAggregateID, "wallet-", "")
 }
 
+// GetWalletStreamID get eventstoredb stream id of a wallet, the inverse of GetWalletAggregateID
+func GetWalletStreamID(walletId string) string {
+	return "wallet-" + walletId
+}
+
+// GetTransactionID derives a stable wallet transaction id from the event that recorded it
+func GetTransactionID(evt eventstore.Event) gocql.UUID {
+	id, err := gocql.ParseUUID(evt.GetEventID())
+	if err != nil {
+		return gocql.UUID{}
+	}
+	return id
+}
+
+// eventMetadata is the metadata of the events a command raises: the trace context of the
+// span in ctx and the actor and causation set with metadata.WithMetadata.
+func eventMetadata(ctx context.Context) map[string]string {
+	fields := tracing.EventMetadata(ctx)
+	if m, ok := metadata.FromContext(ctx); ok {
+		for key, value := range m.Fields() {
+			fields[key] = value
+		}
+	}
+	return fields
+}
+
 func IsAggregateNotFound(aggregate eventstore.Aggregate) bool {
 	return aggregate.GetVersion() == 0
 }
@@ -46,9 +74,9 @@ func GetEntityFromJsonString[Entity base.NoSqlEntity](obj string) (result *Entit
 }
 
 func LoadWalletAggregate(ctx context.Context, eventStore eventstore.AggregateStore, aggregateID string) (*WalletAggregate, error) {
-	span, ctx := opentracing.StartSpanFromContext(ctx, "LoadWalletAggregate")
-	defer span.Finish()
-	span.LogFields(log.String("AggregateID", aggregateID))
+	ctx, span := tracing.StartSpan(ctx, "LoadWalletAggregate")
+	defer span.End()
+	span.SetAttributes(attribute.String("AggregateID", aggregateID))
 
 	wallet := NewWalletAggregateWithID(aggregateID)
 
@@ -0,0 +1,75 @@
+package commands
+
+import (
+	"context"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// Result is what a handled command reports back: the wallet it changed and the wallet's
+// version after the change.
+type Result struct {
+	WalletID string `json:"wallet_id"`
+	Version  int64  `json:"version"`
+}
+
+// HandlerFunc handles one command. Handlers registered with Register are only ever
+// called with the command type they were registered for.
+type HandlerFunc func(ctx context.Context, cmd Command) (*Result, error)
+
+// Middleware wraps every dispatched command; the first middleware given to NewBus is
+// the outermost.
+type Middleware func(next HandlerFunc) HandlerFunc
+
+// Bus dispatches commands to the handler registered for their name. Commands are
+// validated after the middleware ran and before the handler is called, so middleware
+// sees, logs and counts invalid commands too.
+type Bus struct {
+	mu         sync.RWMutex
+	handlers   map[string]HandlerFunc
+	middleware []Middleware
+}
+
+func NewBus(middleware ...Middleware) *Bus {
+	return &Bus{handlers: make(map[string]HandlerFunc), middleware: middleware}
+}
+
+// Register makes handle the handler of commands of type C, replacing any handler
+// registered for the same command name.
+func Register[C Command](b *Bus, handle func(ctx context.Context, cmd C) (*Result, error)) {
+	var zero C
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.handlers[zero.CommandName()] = func(ctx context.Context, cmd Command) (*Result, error) {
+		typed, ok := cmd.(C)
+		if !ok {
+			return nil, errors.Wrapf(ErrNoHandler, "%s: handler expects %T, got %T", cmd.CommandName(), zero, cmd)
+		}
+		return handle(ctx, typed)
+	}
+}
+
+// Dispatch runs cmd through the middleware and its handler.
+func (b *Bus) Dispatch(ctx context.Context, cmd Command) (*Result, error) {
+	if cmd == nil {
+		return nil, errors.Wrap(ErrInvalidCommand, "nil command")
+	}
+	b.mu.RLock()
+	handler, ok := b.handlers[cmd.CommandName()]
+	b.mu.RUnlock()
+	if !ok {
+		return nil, errors.Wrap(ErrNoHandler, cmd.CommandName())
+	}
+
+	next := func(ctx context.Context, cmd Command) (*Result, error) {
+		if err := cmd.Validate(); err != nil {
+			return nil, err
+		}
+		return handler(ctx, cmd)
+	}
+	for i := len(b.middleware) - 1; i >= 0; i-- {
+		next = b.middleware[i](next)
+	}
+	return next(ctx, cmd)
+}
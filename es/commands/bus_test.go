@@ -0,0 +1,337 @@
+package commands_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/novabankapp/wallet.data/domain"
+	"github.com/novabankapp/wallet.data/es/aggregate"
+	"github.com/novabankapp/wallet.data/es/commands"
+	"github.com/novabankapp/wallet.data/es/store"
+	"github.com/pkg/errors"
+	"github.com/shopspring/decimal"
+)
+
+const walletID = "w-1"
+
+func amount(value string) decimal.Decimal {
+	return decimal.RequireFromString(value)
+}
+
+type observed struct {
+	command string
+	outcome commands.Outcome
+}
+
+type metricsRecorder struct {
+	mu       sync.Mutex
+	observed []observed
+}
+
+func (m *metricsRecorder) ObserveCommand(command string, outcome commands.Outcome, elapsed time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.observed = append(m.observed, observed{command, outcome})
+}
+
+func newBus(t *testing.T, middleware ...commands.Middleware) (*commands.Bus, *store.MemoryEventStore) {
+	t.Helper()
+	db := store.NewMemoryEventStore()
+	executor := aggregate.NewWalletCommandExecutor(db)
+	bus := commands.NewBus(middleware...)
+	commands.RegisterWalletHandlers(bus, executor)
+	return bus, db
+}
+
+func create(t *testing.T, bus *commands.Bus, ctx context.Context) {
+	t.Helper()
+	if _, err := bus.Dispatch(ctx, commands.CreateWalletCommand{ID: walletID, Amount: amount("100"), Description: "opening", UserID: "user-1", AccountID: "account-1"}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestValidation(t *testing.T) {
+	yesterday := time.Now().AddDate(0, 0, -1)
+	tests := []struct {
+		name  string
+		cmd   commands.Command
+		field string
+	}{
+		{"create without user", commands.CreateWalletCommand{ID: walletID, AccountID: "a"}, "user_id"},
+		{"create with negative amount", commands.CreateWalletCommand{ID: walletID, UserID: "u", AccountID: "a", Amount: amount("-1")}, "amount"},
+		{"credit without wallet", commands.CreditWalletCommand{DebitWalletID: "w-2", Amount: amount("1")}, "wallet_id"},
+		{"credit from itself", commands.CreditWalletCommand{ID: walletID, DebitWalletID: walletID, Amount: amount("1")}, "debit_wallet_id"},
+		{"debit of zero", commands.DebitWalletCommand{ID: walletID, CreditWalletID: "w-2"}, "amount"},
+		{"reserve of negative amount", commands.ReserveWalletCreditCommand{ID: walletID, Amount: amount("-5")}, "amount"},
+		{"lock without reason", commands.LockWalletCommand{ID: walletID, Description: " "}, "description"},
+		{"lock with unknown reason code", commands.LockWalletCommand{ID: walletID, Description: "review", ReasonCode: "bored"}, "reason_code"},
+		{"lock with empty evidence", commands.LockWalletCommand{ID: walletID, Description: "review", Evidence: []string{"case-1", ""}}, "evidence"},
+		{"blacklist expiring in the past", commands.BlacklistWalletCommand{ID: walletID, Description: "sanctions", ExpiresAt: &yesterday}, "expires_at"},
+		{"lift without wallet", commands.LiftExpiredRestrictionsCommand{}, "wallet_id"},
+		{"delete without reason", commands.DeleteWalletCommand{ID: walletID}, "description"},
+		{"release quarantine without screening", commands.ReleaseQuarantineCommand{ID: walletID}, "screening_id"},
+		{"confirm quarantine to itself", commands.ConfirmQuarantineCommand{ID: walletID, ScreeningID: "s-1", CreditWalletID: walletID}, "credit_wallet_id"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.cmd.Validate()
+			var validation *commands.ValidationError
+			if !errors.As(err, &validation) || !errors.Is(err, commands.ErrInvalidCommand) {
+				t.Fatalf("got %v, want a validation error", err)
+			}
+			if validation.Field != tt.field {
+				t.Fatalf("field %q, want %q", validation.Field, tt.field)
+			}
+		})
+	}
+
+	valid := commands.CreateWalletCommand{ID: walletID, UserID: "u", AccountID: "a"}
+	if err := valid.Validate(); err != nil {
+		t.Fatalf("zero opening balance rejected: %v", err)
+	}
+}
+
+func TestDispatchRunsHandlers(t *testing.T) {
+	metrics := &metricsRecorder{}
+	bus, db := newBus(t, commands.Metrics(metrics))
+	ctx := context.Background()
+	create(t, bus, ctx)
+
+	result, err := bus.Dispatch(ctx, commands.DebitWalletCommand{ID: walletID, CreditWalletID: "w-2", Amount: amount("30"), Description: "payment"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.WalletID != walletID || result.Version != 1 {
+		t.Fatalf("unexpected result %+v", result)
+	}
+	if _, err := bus.Dispatch(ctx, commands.DebitWalletCommand{ID: walletID, CreditWalletID: "w-2", Amount: amount("500"), Description: "payment"}); !errors.Is(err, aggregate.ErrInsufficientFunds) {
+		t.Fatalf("got %v, want %v", err, aggregate.ErrInsufficientFunds)
+	}
+	if _, err := bus.Dispatch(ctx, commands.LockWalletCommand{ID: walletID}); !errors.Is(err, commands.ErrInvalidCommand) {
+		t.Fatalf("got %v, want %v", err, commands.ErrInvalidCommand)
+	}
+
+	wallet, err := aggregate.LoadWalletAggregate(ctx, db, walletID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !wallet.Wallet.Balance.Equal(amount("70")) {
+		t.Fatalf("balance %s, want 70", wallet.Wallet.Balance)
+	}
+
+	want := []observed{
+		{commands.CreateWallet, commands.OutcomeSucceeded},
+		{commands.DebitWallet, commands.OutcomeSucceeded},
+		{commands.DebitWallet, commands.OutcomeRejected},
+		{commands.LockWallet, commands.OutcomeInvalid},
+	}
+	if len(metrics.observed) != len(want) {
+		t.Fatalf("observed %v, want %v", metrics.observed, want)
+	}
+	for i := range want {
+		if metrics.observed[i] != want[i] {
+			t.Fatalf("observed %v, want %v", metrics.observed, want)
+		}
+	}
+}
+
+func TestQuarantineReview(t *testing.T) {
+	bus, db := newBus(t)
+	ctx := context.Background()
+	create(t, bus, ctx)
+
+	for _, id := range []string{"s-1", "s-2"} {
+		screening := domain.Screening{ID: id, Outcome: domain.ScreeningQuarantined, Command: commands.DebitWallet, CounterpartyWalletId: "w-2", Amount: amount("30")}
+		if _, err := bus.Dispatch(ctx, commands.RecordScreeningCommand{ID: walletID, Screening: screening}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := bus.Dispatch(ctx, commands.ReleaseQuarantineCommand{ID: walletID, ScreeningID: "s-1", Description: "cleared"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := bus.Dispatch(ctx, commands.ConfirmQuarantineCommand{ID: walletID, ScreeningID: "s-2", CreditWalletID: "w-suspense", Description: "upheld"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := bus.Dispatch(ctx, commands.ReleaseQuarantineCommand{ID: walletID, ScreeningID: "s-2"}); !errors.Is(err, aggregate.ErrQuarantineNotFound) {
+		t.Fatalf("got %v, want %v", err, aggregate.ErrQuarantineNotFound)
+	}
+
+	wallet, err := aggregate.LoadWalletAggregate(ctx, db, walletID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !wallet.Wallet.Balance.Equal(amount("70")) || !wallet.Wallet.AvailableBalance.Equal(amount("70")) {
+		t.Fatalf("balance %s available %s, want 70", wallet.Wallet.Balance, wallet.Wallet.AvailableBalance)
+	}
+}
+
+type unknownCommand struct{}
+
+func (unknownCommand) CommandName() string { return "Unknown" }
+func (unknownCommand) WalletID() string    { return walletID }
+func (unknownCommand) Validate() error     { return nil }
+
+func TestDispatchWithoutHandler(t *testing.T) {
+	bus, _ := newBus(t)
+	if _, err := bus.Dispatch(context.Background(), unknownCommand{}); !errors.Is(err, commands.ErrNoHandler) {
+		t.Fatalf("got %v, want %v", err, commands.ErrNoHandler)
+	}
+}
+
+func TestMiddlewareOrder(t *testing.T) {
+	var calls []string
+	record := func(name string) commands.Middleware {
+		return func(next commands.HandlerFunc) commands.HandlerFunc {
+			return func(ctx context.Context, cmd commands.Command) (*commands.Result, error) {
+				calls = append(calls, name)
+				return next(ctx, cmd)
+			}
+		}
+	}
+	bus, _ := newBus(t, record("outer"), record("inner"))
+	create(t, bus, context.Background())
+	if len(calls) != 2 || calls[0] != "outer" || calls[1] != "inner" {
+		t.Fatalf("middleware ran as %v", calls)
+	}
+}
+
+func TestAuthorization(t *testing.T) {
+	authorizer := commands.RoleAuthorizer(map[string][]string{
+		commands.AnyCommand:   {"admin"},
+		commands.CreateWallet: {"teller"},
+	})
+	bus, _ := newBus(t, commands.Authorization(authorizer))
+
+	if _, err := bus.Dispatch(context.Background(), commands.CreateWalletCommand{}); !errors.Is(err, commands.ErrUnauthorized) {
+		t.Fatalf("without principal: got %v, want %v", err, commands.ErrUnauthorized)
+	}
+
+	teller := commands.WithPrincipal(context.Background(), commands.Principal{ID: "t-1", Roles: []string{"teller"}})
+	create(t, bus, teller)
+	lock := commands.LockWalletCommand{ID: walletID, Description: "fraud review"}
+	if _, err := bus.Dispatch(teller, lock); !errors.Is(err, commands.ErrUnauthorized) {
+		t.Fatalf("teller locking: got %v, want %v", err, commands.ErrUnauthorized)
+	}
+
+	admin := commands.WithPrincipal(context.Background(), commands.Principal{ID: "a-1", Roles: []string{"admin"}})
+	if _, err := bus.Dispatch(admin, lock); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestIdempotency(t *testing.T) {
+	bus, db := newBus(t, commands.Idempotency(commands.NewMemoryIdempotencyStore(time.Hour)))
+	create(t, bus, context.Background())
+
+	ctx := commands.WithIdempotencyKey(context.Background(), "payment-1")
+	debit := commands.DebitWalletCommand{ID: walletID, CreditWalletID: "w-2", Amount: amount("30"), Description: "payment"}
+	first, err := bus.Dispatch(ctx, debit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	retried, err := bus.Dispatch(ctx, debit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *retried != *first {
+		t.Fatalf("retry returned %+v, first returned %+v", retried, first)
+	}
+
+	changed := debit
+	changed.Amount = amount("31")
+	if _, err := bus.Dispatch(ctx, changed); !errors.Is(err, commands.ErrIdempotencyKeyReused) {
+		t.Fatalf("got %v, want %v", err, commands.ErrIdempotencyKeyReused)
+	}
+
+	// a refused command frees its key, so the client can fix the wallet and retry
+	tooMuch := commands.WithIdempotencyKey(context.Background(), "payment-2")
+	large := commands.DebitWalletCommand{ID: walletID, CreditWalletID: "w-2", Amount: amount("100"), Description: "payment"}
+	if _, err := bus.Dispatch(tooMuch, large); !errors.Is(err, aggregate.ErrInsufficientFunds) {
+		t.Fatalf("got %v, want %v", err, aggregate.ErrInsufficientFunds)
+	}
+	if _, err := bus.Dispatch(context.Background(), commands.CreditWalletCommand{ID: walletID, DebitWalletID: "w-2", Amount: amount("50"), Description: "top up"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := bus.Dispatch(tooMuch, large); err != nil {
+		t.Fatal(err)
+	}
+
+	wallet, err := aggregate.LoadWalletAggregate(context.Background(), db, walletID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !wallet.Wallet.Balance.Equal(amount("20")) {
+		t.Fatalf("balance %s, want 20", wallet.Wallet.Balance)
+	}
+}
+
+func TestIdempotencyIsScopedToThePrincipal(t *testing.T) {
+	bus, db := newBus(t, commands.Idempotency(commands.NewMemoryIdempotencyStore(time.Hour)))
+	create(t, bus, context.Background())
+
+	debit := commands.DebitWalletCommand{ID: walletID, CreditWalletID: "w-2", Amount: amount("30"), Description: "payment"}
+	alice := commands.WithIdempotencyKey(commands.WithPrincipal(context.Background(), commands.Principal{ID: "alice"}), "payment-1")
+	bob := commands.WithIdempotencyKey(commands.WithPrincipal(context.Background(), commands.Principal{ID: "bob"}), "payment-1")
+
+	first, err := bus.Dispatch(alice, debit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The same key from another principal is another command, not a retry of alice's.
+	second, err := bus.Dispatch(bob, debit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second.Version == first.Version {
+		t.Fatalf("bob got alice's result %+v", first)
+	}
+	if retried, err := bus.Dispatch(alice, debit); err != nil || *retried != *first {
+		t.Fatalf("alice's retry returned %+v, %v", retried, err)
+	}
+
+	wallet, err := aggregate.LoadWalletAggregate(context.Background(), db, walletID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !wallet.Wallet.Balance.Equal(amount("40")) {
+		t.Fatalf("balance %s, want 40", wallet.Wallet.Balance)
+	}
+
+	aliceFingerprint, err := commands.Fingerprint("alice", debit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bobFingerprint, _ := commands.Fingerprint("bob", debit); bobFingerprint == aliceFingerprint {
+		t.Fatal("fingerprints do not depend on the principal")
+	}
+}
+
+func TestIdempotencyRefusesConcurrentRetry(t *testing.T) {
+	store := commands.NewMemoryIdempotencyStore(time.Hour)
+	release := make(chan struct{})
+	entered := make(chan struct{})
+	bus := commands.NewBus(commands.Idempotency(store))
+	commands.Register(bus, func(ctx context.Context, cmd commands.LockWalletCommand) (*commands.Result, error) {
+		close(entered)
+		<-release
+		return &commands.Result{WalletID: cmd.ID}, nil
+	})
+
+	ctx := commands.WithIdempotencyKey(context.Background(), "lock-1")
+	lock := commands.LockWalletCommand{ID: walletID, Description: "review"}
+	done := make(chan error, 1)
+	go func() {
+		_, err := bus.Dispatch(ctx, lock)
+		done <- err
+	}()
+	<-entered
+	if _, err := bus.Dispatch(ctx, lock); !errors.Is(err, commands.ErrCommandInProgress) {
+		t.Fatalf("got %v, want %v", err, commands.ErrCommandInProgress)
+	}
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+}
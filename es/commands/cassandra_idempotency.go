@@ -0,0 +1,111 @@
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/gocql/gocql"
+	"github.com/pkg/errors"
+	"github.com/scylladb/gocqlx/v2"
+)
+
+const IdempotencyTable = "command_idempotency"
+
+// CassandraIdempotencyStore keeps records in the table created by the migrations package.
+// Rows are written with a TTL, so Cassandra drops a key once TTL has passed since it was
+// reserved.
+type CassandraIdempotencyStore struct {
+	TTL     time.Duration
+	session gocqlx.Session
+	now     func() time.Time
+}
+
+var _ IdempotencyStore = (*CassandraIdempotencyStore)(nil)
+
+func NewCassandraIdempotencyStore(session gocqlx.Session, ttl time.Duration) *CassandraIdempotencyStore {
+	return &CassandraIdempotencyStore{TTL: ttl, session: session, now: time.Now}
+}
+
+// Reserve claims the key with a lightweight transaction, so concurrent retries agree on
+// which one runs the command.
+func (s *CassandraIdempotencyStore) Reserve(ctx context.Context, record IdempotencyRecord) (*IdempotencyRecord, error) {
+	record.CreatedAt = s.now().UTC()
+	stmt := fmt.Sprintf("INSERT INTO %s (key, principal, command, fingerprint, created_at) VALUES (?, ?, ?, ?, ?) IF NOT EXISTS USING TTL ?", IdempotencyTable)
+	existing := map[string]interface{}{}
+	applied, err := s.session.Session.Query(stmt,
+		record.Key, record.Principal, record.Command, record.Fingerprint, record.CreatedAt, ttlSeconds(s.TTL),
+	).WithContext(ctx).MapScanCAS(existing)
+	if err != nil {
+		return nil, errors.Wrap(err, "Query.MapScanCAS")
+	}
+	if applied {
+		return nil, nil
+	}
+	return recordFromRow(existing)
+}
+
+// Complete keeps the row's remaining TTL, so a completed key expires when it would have
+// without a result.
+func (s *CassandraIdempotencyStore) Complete(ctx context.Context, key string, result Result) error {
+	var remaining int
+	stmt := fmt.Sprintf("SELECT TTL(fingerprint) FROM %s WHERE key = ?", IdempotencyTable)
+	err := s.session.Session.Query(stmt, key).WithContext(ctx).Scan(&remaining)
+	if errors.Is(err, gocql.ErrNotFound) {
+		return errors.Errorf("idempotency key %q is not reserved", key)
+	}
+	if err != nil {
+		return errors.Wrap(err, "Query.Scan")
+	}
+
+	data, err := json.Marshal(result)
+	if err != nil {
+		return errors.Wrap(err, "json.Marshal")
+	}
+	stmt = fmt.Sprintf("UPDATE %s USING TTL ? SET result = ? WHERE key = ? IF EXISTS", IdempotencyTable)
+	applied, err := s.session.Session.Query(stmt, remaining, string(data), key).WithContext(ctx).MapScanCAS(map[string]interface{}{})
+	if err != nil {
+		return errors.Wrap(err, "Query.MapScanCAS")
+	}
+	if !applied {
+		return errors.Errorf("idempotency key %q is not reserved", key)
+	}
+	return nil
+}
+
+func (s *CassandraIdempotencyStore) Release(ctx context.Context, key string) error {
+	stmt := fmt.Sprintf("DELETE FROM %s WHERE key = ?", IdempotencyTable)
+	if err := s.session.Session.Query(stmt, key).WithContext(ctx).Exec(); err != nil {
+		return errors.Wrap(err, "Query.Exec")
+	}
+	return nil
+}
+
+// ttlSeconds turns ttl into a Cassandra TTL; 0 keeps rows forever.
+func ttlSeconds(ttl time.Duration) int {
+	if ttl <= 0 {
+		return 0
+	}
+	if seconds := int(ttl / time.Second); seconds > 0 {
+		return seconds
+	}
+	return 1
+}
+
+// recordFromRow reads the row a failed lightweight transaction returns.
+func recordFromRow(row map[string]interface{}) (*IdempotencyRecord, error) {
+	record := &IdempotencyRecord{}
+	record.Key, _ = row["key"].(string)
+	record.Principal, _ = row["principal"].(string)
+	record.Command, _ = row["command"].(string)
+	record.Fingerprint, _ = row["fingerprint"].(string)
+	record.CreatedAt, _ = row["created_at"].(time.Time)
+	if result, _ := row["result"].(string); result != "" {
+		record.Result = &Result{}
+		if err := json.Unmarshal([]byte(result), record.Result); err != nil {
+			return nil, errors.Wrapf(err, "idempotency key %q result", record.Key)
+		}
+	}
+	return record, nil
+}
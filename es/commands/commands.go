@@ -0,0 +1,248 @@
+// Package commands is the application entry point for changing wallets: typed commands
+// are validated and dispatched through a Bus to handlers that load, change and save the
+// wallet aggregate.
+package commands
+
+import (
+	"strings"
+	"time"
+
+	"github.com/novabankapp/wallet.data/domain"
+	"github.com/shopspring/decimal"
+)
+
+// Command is a request to change one wallet.
+type Command interface {
+	CommandName() string
+	WalletID() string
+	Validate() error
+}
+
+const (
+	CreateWallet        = "CreateWallet"
+	CreditWallet        = "CreditWallet"
+	DebitWallet         = "DebitWallet"
+	ReserveWalletCredit = "ReserveWalletCredit"
+	ReleaseWalletCredit = "ReleaseWalletCredit"
+	LockWallet          = "LockWallet"
+	UnlockWallet        = "UnlockWallet"
+	BlacklistWallet     = "BlacklistWallet"
+	UnBlacklistWallet   = "UnBlacklistWallet"
+	DeleteWallet        = "DeleteWallet"
+)
+
+type CreateWalletCommand struct {
+	ID          string          `json:"wallet_id"`
+	Amount      decimal.Decimal `json:"amount"`
+	Description string          `json:"description"`
+	UserID      string          `json:"user_id"`
+	AccountID   string          `json:"account_id"`
+}
+
+type CreditWalletCommand struct {
+	ID            string          `json:"wallet_id"`
+	DebitWalletID string          `json:"debit_wallet_id"`
+	Amount        decimal.Decimal `json:"amount"`
+	Description   string          `json:"description"`
+}
+
+type DebitWalletCommand struct {
+	ID             string          `json:"wallet_id"`
+	CreditWalletID string          `json:"credit_wallet_id"`
+	Amount         decimal.Decimal `json:"amount"`
+	Description    string          `json:"description"`
+}
+
+type ReserveWalletCreditCommand struct {
+	ID          string          `json:"wallet_id"`
+	Amount      decimal.Decimal `json:"amount"`
+	Description string          `json:"description"`
+}
+
+type ReleaseWalletCreditCommand struct {
+	ID          string          `json:"wallet_id"`
+	Amount      decimal.Decimal `json:"amount"`
+	Description string          `json:"description"`
+}
+
+// LockWalletCommand and BlacklistWalletCommand restrict a wallet. ReasonCode defaults to
+// domain.ReasonOther; a restriction with ExpiresAt is lifted by LiftExpiredRestrictions
+// once that time has passed.
+type LockWalletCommand struct {
+	ID          string            `json:"wallet_id"`
+	Description string            `json:"description"`
+	ReasonCode  domain.ReasonCode `json:"reason_code,omitempty"`
+	Authority   string            `json:"authority,omitempty"`
+	Evidence    []string          `json:"evidence,omitempty"`
+	ExpiresAt   *time.Time        `json:"expires_at,omitempty"`
+}
+
+type UnlockWalletCommand struct {
+	ID          string `json:"wallet_id"`
+	Description string `json:"description"`
+}
+
+type BlacklistWalletCommand struct {
+	ID          string            `json:"wallet_id"`
+	Description string            `json:"description"`
+	ReasonCode  domain.ReasonCode `json:"reason_code,omitempty"`
+	Authority   string            `json:"authority,omitempty"`
+	Evidence    []string          `json:"evidence,omitempty"`
+	ExpiresAt   *time.Time        `json:"expires_at,omitempty"`
+}
+
+type UnBlacklistWalletCommand struct {
+	ID          string `json:"wallet_id"`
+	Description string `json:"description"`
+}
+
+type DeleteWalletCommand struct {
+	ID          string `json:"wallet_id"`
+	Description string `json:"description"`
+}
+
+func (c CreateWalletCommand) CommandName() string        { return CreateWallet }
+func (c CreditWalletCommand) CommandName() string        { return CreditWallet }
+func (c DebitWalletCommand) CommandName() string         { return DebitWallet }
+func (c ReserveWalletCreditCommand) CommandName() string { return ReserveWalletCredit }
+func (c ReleaseWalletCreditCommand) CommandName() string { return ReleaseWalletCredit }
+func (c LockWalletCommand) CommandName() string          { return LockWallet }
+func (c UnlockWalletCommand) CommandName() string        { return UnlockWallet }
+func (c BlacklistWalletCommand) CommandName() string     { return BlacklistWallet }
+func (c UnBlacklistWalletCommand) CommandName() string   { return UnBlacklistWallet }
+func (c DeleteWalletCommand) CommandName() string        { return DeleteWallet }
+
+func (c CreateWalletCommand) WalletID() string        { return c.ID }
+func (c CreditWalletCommand) WalletID() string        { return c.ID }
+func (c DebitWalletCommand) WalletID() string         { return c.ID }
+func (c ReserveWalletCreditCommand) WalletID() string { return c.ID }
+func (c ReleaseWalletCreditCommand) WalletID() string { return c.ID }
+func (c LockWalletCommand) WalletID() string          { return c.ID }
+func (c UnlockWalletCommand) WalletID() string        { return c.ID }
+func (c BlacklistWalletCommand) WalletID() string     { return c.ID }
+func (c UnBlacklistWalletCommand) WalletID() string   { return c.ID }
+func (c DeleteWalletCommand) WalletID() string        { return c.ID }
+
+// The aggregate enforces the rules that depend on wallet state; Validate only checks what
+// can be checked from the command alone.
+
+func (c CreateWalletCommand) Validate() error {
+	v := validator{command: CreateWallet}
+	v.required("wallet_id", c.ID)
+	v.required("user_id", c.UserID)
+	v.required("account_id", c.AccountID)
+	if c.Amount.IsNegative() {
+		v.fail("amount", "must not be negative")
+	}
+	return v.err
+}
+
+func (c CreditWalletCommand) Validate() error {
+	v := validator{command: CreditWallet}
+	v.required("wallet_id", c.ID)
+	v.required("debit_wallet_id", c.DebitWalletID)
+	v.otherWallet("debit_wallet_id", c.ID, c.DebitWalletID)
+	v.positive("amount", c.Amount)
+	return v.err
+}
+
+func (c DebitWalletCommand) Validate() error {
+	v := validator{command: DebitWallet}
+	v.required("wallet_id", c.ID)
+	v.required("credit_wallet_id", c.CreditWalletID)
+	v.otherWallet("credit_wallet_id", c.ID, c.CreditWalletID)
+	v.positive("amount", c.Amount)
+	return v.err
+}
+
+func (c ReserveWalletCreditCommand) Validate() error {
+	v := validator{command: ReserveWalletCredit}
+	v.required("wallet_id", c.ID)
+	v.positive("amount", c.Amount)
+	return v.err
+}
+
+func (c ReleaseWalletCreditCommand) Validate() error {
+	v := validator{command: ReleaseWalletCredit}
+	v.required("wallet_id", c.ID)
+	v.positive("amount", c.Amount)
+	return v.err
+}
+
+func (c LockWalletCommand) Validate() error {
+	return validateRestriction(LockWallet, c.ID, c.Description, c.ReasonCode, c.Evidence, c.ExpiresAt)
+}
+
+func (c UnlockWalletCommand) Validate() error {
+	return validateStatusChange(UnlockWallet, c.ID, c.Description)
+}
+
+func (c BlacklistWalletCommand) Validate() error {
+	return validateRestriction(BlacklistWallet, c.ID, c.Description, c.ReasonCode, c.Evidence, c.ExpiresAt)
+}
+
+func (c UnBlacklistWalletCommand) Validate() error {
+	return validateStatusChange(UnBlacklistWallet, c.ID, c.Description)
+}
+
+func (c DeleteWalletCommand) Validate() error {
+	return validateStatusChange(DeleteWallet, c.ID, c.Description)
+}
+
+// validateStatusChange requires a reason: status changes are reviewed later and an
+// empty description leaves nothing to review.
+func validateStatusChange(command, id, description string) error {
+	v := validator{command: command}
+	v.required("wallet_id", id)
+	v.required("description", description)
+	return v.err
+}
+
+// validateRestriction also checks the reason code and the evidence references, and that
+// the restriction does not expire before it is imposed.
+func validateRestriction(command, id, description string, code domain.ReasonCode, evidence []string, expiresAt *time.Time) error {
+	if err := validateStatusChange(command, id, description); err != nil {
+		return err
+	}
+	v := validator{command: command}
+	if code != "" && !code.IsKnown() {
+		v.fail("reason_code", "is not a known reason code")
+	}
+	for _, reference := range evidence {
+		v.required("evidence", reference)
+	}
+	if expiresAt != nil && !expiresAt.After(time.Now()) {
+		v.fail("expires_at", "must be in the future")
+	}
+	return v.err
+}
+
+// validator keeps the first failure.
+type validator struct {
+	command string
+	err     error
+}
+
+func (v *validator) fail(field, reason string) {
+	if v.err == nil {
+		v.err = &ValidationError{Command: v.command, Field: field, Reason: reason}
+	}
+}
+
+func (v *validator) required(field, value string) {
+	if strings.TrimSpace(value) == "" {
+		v.fail(field, "is required")
+	}
+}
+
+func (v *validator) positive(field string, value decimal.Decimal) {
+	if !value.IsPositive() {
+		v.fail(field, "must be positive")
+	}
+}
+
+func (v *validator) otherWallet(field, id, other string) {
+	if id != "" && id == other {
+		v.fail(field, "must differ from wallet_id")
+	}
+}
@@ -0,0 +1,77 @@
+package commands
+
+import (
+	"context"
+
+	"github.com/novabankapp/wallet.data/es/models"
+	"github.com/novabankapp/wallet.data/es/pii"
+	"github.com/pkg/errors"
+)
+
+const EraseUserData = "EraseUserData"
+
+// EraseUserDataCommand erases the personal data of a user from every wallet event by
+// shredding the user's key, and redacts the copies kept outside the event store. Balances
+// are not affected.
+type EraseUserDataCommand struct {
+	UserID      string `json:"user_id"`
+	Description string `json:"description"`
+}
+
+func (c EraseUserDataCommand) CommandName() string { return EraseUserData }
+
+// WalletID is empty: the erasure covers every wallet of the user.
+func (c EraseUserDataCommand) WalletID() string { return "" }
+
+func (c EraseUserDataCommand) Validate() error {
+	v := validator{command: EraseUserData}
+	v.required("user_id", c.UserID)
+	v.required("description", c.Description)
+	return v.err
+}
+
+// PersonalDataEraser redacts the personal data of an erased user from a store that keeps
+// copies of it outside the event store, such as the audit trail or the webhook log.
+type PersonalDataEraser interface {
+	ErasePersonalData(ctx context.Context, userID string, walletIDs []string) error
+}
+
+// WalletReadModel is the read model the wallets of a user are found in. It is erased
+// last, so that a failed erasure can be retried and still finds the wallets.
+type WalletReadModel interface {
+	GetByUser(ctx context.Context, userID string) ([]models.WalletProjection, error)
+	PersonalDataEraser
+}
+
+// RegisterErasureHandler registers the handler of EraseUserDataCommand. It shreds the
+// user's key in keys, then runs the erasers and finally erases readModel, both with the
+// wallets readModel holds for the user. readModel may be nil when there is none; the
+// erasers then only get the user id.
+func RegisterErasureHandler(bus *Bus, keys pii.KeyStore, readModel WalletReadModel, erasers ...PersonalDataEraser) {
+	Register(bus, func(ctx context.Context, cmd EraseUserDataCommand) (*Result, error) {
+		if err := keys.Shred(ctx, cmd.UserID); err != nil {
+			return nil, errors.Wrap(err, "Shred")
+		}
+		var walletIDs []string
+		if readModel != nil {
+			rows, err := readModel.GetByUser(ctx, cmd.UserID)
+			if err != nil {
+				return nil, errors.Wrap(err, "GetByUser")
+			}
+			for _, row := range rows {
+				walletIDs = append(walletIDs, row.WalletID)
+			}
+		}
+		for _, eraser := range erasers {
+			if err := eraser.ErasePersonalData(ctx, cmd.UserID, walletIDs); err != nil {
+				return nil, errors.Wrap(err, "ErasePersonalData")
+			}
+		}
+		if readModel != nil {
+			if err := readModel.ErasePersonalData(ctx, cmd.UserID, walletIDs); err != nil {
+				return nil, errors.Wrap(err, "ErasePersonalData")
+			}
+		}
+		return &Result{}, nil
+	})
+}
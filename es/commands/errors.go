@@ -0,0 +1,37 @@
+package commands
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	ErrInvalidCommand       = errors.New("invalid command")
+	ErrNoHandler            = errors.New("no handler registered for command")
+	ErrUnauthorized         = errors.New("not authorized to run command")
+	ErrIdempotencyKeyReused = errors.New("idempotency key was already used for a different command")
+	ErrCommandInProgress    = errors.New("a command with this idempotency key is still running")
+	// ErrBlocked is returned for commands refused by a middleware screening them, such as
+	// the fraud rules.
+	ErrBlocked = errors.New("command blocked")
+	// ErrQuarantined is returned for debits whose amount was held for review instead of
+	// being sent.
+	ErrQuarantined = errors.New("command quarantined")
+)
+
+// ValidationError says which field of a command is invalid. It matches ErrInvalidCommand
+// with errors.Is.
+type ValidationError struct {
+	Command string
+	Field   string
+	Reason  string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("%s: %s %s", e.Command, e.Field, e.Reason)
+}
+
+func (e *ValidationError) Is(target error) bool {
+	return target == ErrInvalidCommand
+}
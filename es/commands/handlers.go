@@ -0,0 +1,120 @@
+package commands
+
+import (
+	"context"
+	"time"
+
+	"github.com/novabankapp/wallet.data/domain"
+	"github.com/novabankapp/wallet.data/es/aggregate"
+)
+
+// WalletHandlers turns commands into calls on the wallet aggregate. Loading, saving and
+// retrying on version conflicts are left to the executor. Now is the clock that decides
+// which restrictions have expired.
+type WalletHandlers struct {
+	Executor *aggregate.WalletCommandExecutor
+	Now      func() time.Time
+}
+
+func NewWalletHandlers(executor *aggregate.WalletCommandExecutor) *WalletHandlers {
+	return &WalletHandlers{Executor: executor, Now: time.Now}
+}
+
+// RegisterWalletHandlers registers a handler for every wallet command on the bus.
+func RegisterWalletHandlers(bus *Bus, executor *aggregate.WalletCommandExecutor) {
+	h := NewWalletHandlers(executor)
+	Register(bus, h.CreateWallet)
+	Register(bus, h.CreditWallet)
+	Register(bus, h.DebitWallet)
+	Register(bus, h.ReserveWalletCredit)
+	Register(bus, h.ReleaseWalletCredit)
+	Register(bus, h.LockWallet)
+	Register(bus, h.UnlockWallet)
+	Register(bus, h.BlacklistWallet)
+	Register(bus, h.UnBlacklistWallet)
+	Register(bus, h.DeleteWallet)
+	Register(bus, h.LiftExpiredRestrictions)
+	Register(bus, h.RecordScreening)
+	Register(bus, h.ReleaseQuarantine)
+	Register(bus, h.ConfirmQuarantine)
+}
+
+func (h *WalletHandlers) execute(ctx context.Context, cmd Command, command func(ctx context.Context, wallet *aggregate.WalletAggregate) error) (*Result, error) {
+	wallet, err := h.Executor.Execute(ctx, cmd.WalletID(), cmd.CommandName(), command)
+	if err != nil {
+		return nil, err
+	}
+	return &Result{WalletID: cmd.WalletID(), Version: wallet.GetVersion()}, nil
+}
+
+func (h *WalletHandlers) CreateWallet(ctx context.Context, cmd CreateWalletCommand) (*Result, error) {
+	return h.execute(ctx, cmd, func(ctx context.Context, wallet *aggregate.WalletAggregate) error {
+		return wallet.CreateWallet(ctx, cmd.Amount, cmd.Description, cmd.UserID, cmd.AccountID, cmd.ID)
+	})
+}
+
+func (h *WalletHandlers) CreditWallet(ctx context.Context, cmd CreditWalletCommand) (*Result, error) {
+	return h.execute(ctx, cmd, func(ctx context.Context, wallet *aggregate.WalletAggregate) error {
+		return wallet.CreditWallet(ctx, cmd.DebitWalletID, cmd.Amount, cmd.Description)
+	})
+}
+
+func (h *WalletHandlers) DebitWallet(ctx context.Context, cmd DebitWalletCommand) (*Result, error) {
+	return h.execute(ctx, cmd, func(ctx context.Context, wallet *aggregate.WalletAggregate) error {
+		return wallet.DebitWallet(ctx, cmd.CreditWalletID, cmd.Amount, cmd.Description)
+	})
+}
+
+func (h *WalletHandlers) ReserveWalletCredit(ctx context.Context, cmd ReserveWalletCreditCommand) (*Result, error) {
+	return h.execute(ctx, cmd, func(ctx context.Context, wallet *aggregate.WalletAggregate) error {
+		return wallet.ReserveWalletCredit(ctx, cmd.Amount, cmd.Description)
+	})
+}
+
+func (h *WalletHandlers) ReleaseWalletCredit(ctx context.Context, cmd ReleaseWalletCreditCommand) (*Result, error) {
+	return h.execute(ctx, cmd, func(ctx context.Context, wallet *aggregate.WalletAggregate) error {
+		return wallet.ReleaseWalletCredit(ctx, cmd.Amount, cmd.Description)
+	})
+}
+
+func (h *WalletHandlers) LockWallet(ctx context.Context, cmd LockWalletCommand) (*Result, error) {
+	return h.execute(ctx, cmd, func(ctx context.Context, wallet *aggregate.WalletAggregate) error {
+		return wallet.LockWallet(ctx, domain.Restriction{
+			ReasonCode:  cmd.ReasonCode,
+			Authority:   cmd.Authority,
+			Description: cmd.Description,
+			Evidence:    cmd.Evidence,
+			ExpiresAt:   cmd.ExpiresAt,
+		})
+	})
+}
+
+func (h *WalletHandlers) UnlockWallet(ctx context.Context, cmd UnlockWalletCommand) (*Result, error) {
+	return h.execute(ctx, cmd, func(ctx context.Context, wallet *aggregate.WalletAggregate) error {
+		return wallet.UnlockWallet(ctx, cmd.Description)
+	})
+}
+
+func (h *WalletHandlers) BlacklistWallet(ctx context.Context, cmd BlacklistWalletCommand) (*Result, error) {
+	return h.execute(ctx, cmd, func(ctx context.Context, wallet *aggregate.WalletAggregate) error {
+		return wallet.BlacklistWallet(ctx, domain.Restriction{
+			ReasonCode:  cmd.ReasonCode,
+			Authority:   cmd.Authority,
+			Description: cmd.Description,
+			Evidence:    cmd.Evidence,
+			ExpiresAt:   cmd.ExpiresAt,
+		})
+	})
+}
+
+func (h *WalletHandlers) UnBlacklistWallet(ctx context.Context, cmd UnBlacklistWalletCommand) (*Result, error) {
+	return h.execute(ctx, cmd, func(ctx context.Context, wallet *aggregate.WalletAggregate) error {
+		return wallet.UnBlacklistWallet(ctx, cmd.Description)
+	})
+}
+
+func (h *WalletHandlers) DeleteWallet(ctx context.Context, cmd DeleteWalletCommand) (*Result, error) {
+	return h.execute(ctx, cmd, func(ctx context.Context, wallet *aggregate.WalletAggregate) error {
+		return wallet.DeleteWallet(ctx, cmd.Description)
+	})
+}
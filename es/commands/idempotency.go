@@ -0,0 +1,169 @@
+package commands
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+type idempotencyKey struct{}
+
+// WithIdempotencyKey marks the commands dispatched with ctx as retries of each other:
+// only the first one runs, the others get its result.
+func WithIdempotencyKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, idempotencyKey{}, key)
+}
+
+func IdempotencyKeyFromContext(ctx context.Context) (string, bool) {
+	key, ok := ctx.Value(idempotencyKey{}).(string)
+	return key, ok && key != ""
+}
+
+// IdempotencyRecord is stored under the client's key scoped to the principal that sent
+// the command.
+type IdempotencyRecord struct {
+	Key         string    `json:"key"`
+	Principal   string    `json:"principal,omitempty"`
+	Command     string    `json:"command"`
+	Fingerprint string    `json:"fingerprint"`
+	Result      *Result   `json:"result,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// Completed reports whether the command of the record finished successfully.
+func (r *IdempotencyRecord) Completed() bool {
+	return r.Result != nil
+}
+
+type IdempotencyStore interface {
+	// Reserve claims key for a command. When the key is already claimed it returns the
+	// existing record and claims nothing.
+	Reserve(ctx context.Context, record IdempotencyRecord) (*IdempotencyRecord, error)
+	// Complete stores the result of the command that claimed key.
+	Complete(ctx context.Context, key string, result Result) error
+	// Release frees a key whose command failed, so that it can be retried.
+	Release(ctx context.Context, key string) error
+}
+
+// scopedIdempotencyKey is the key a command's record is stored under: the client's key
+// scoped to the principal in ctx, so two clients that pick the same key do not see each
+// other's results. Commands without a principal share one scope.
+func scopedIdempotencyKey(ctx context.Context, key string) string {
+	principal, _ := PrincipalFromContext(ctx)
+	return url.PathEscape(principal.ID) + "/" + key
+}
+
+// Fingerprint identifies the content of a command and who sent it, to tell a retry from
+// a different command sent with the same key.
+func Fingerprint(principalID string, cmd Command) (string, error) {
+	data, err := json.Marshal(cmd)
+	if err != nil {
+		return "", errors.Wrap(err, "json.Marshal")
+	}
+	sum := sha256.Sum256(append([]byte(principalID+"\n"+cmd.CommandName()+"\n"), data...))
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// Idempotency runs a command at most once per idempotency key and principal. A retry of a
+// command that succeeded returns the stored result; a retry while the first is still
+// running, or a different command with a used key, is refused. Failed commands free their
+// key. Commands dispatched without a key are not deduplicated.
+func Idempotency(store IdempotencyStore) Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx context.Context, cmd Command) (*Result, error) {
+			clientKey, ok := IdempotencyKeyFromContext(ctx)
+			if !ok {
+				return next(ctx, cmd)
+			}
+			principal, _ := PrincipalFromContext(ctx)
+			key := scopedIdempotencyKey(ctx, clientKey)
+			fingerprint, err := Fingerprint(principal.ID, cmd)
+			if err != nil {
+				return nil, err
+			}
+			existing, err := store.Reserve(ctx, IdempotencyRecord{
+				Key:         key,
+				Principal:   principal.ID,
+				Command:     cmd.CommandName(),
+				Fingerprint: fingerprint,
+				CreatedAt:   time.Now().UTC(),
+			})
+			if err != nil {
+				return nil, errors.Wrap(err, "IdempotencyStore.Reserve")
+			}
+			if existing != nil {
+				switch {
+				case existing.Fingerprint != fingerprint:
+					return nil, errors.Wrapf(ErrIdempotencyKeyReused, "key %q was used for %s", clientKey, existing.Command)
+				case !existing.Completed():
+					return nil, errors.Wrapf(ErrCommandInProgress, "key %q", clientKey)
+				}
+				result := *existing.Result
+				return &result, nil
+			}
+
+			result, err := next(ctx, cmd)
+			if err != nil {
+				if releaseErr := store.Release(ctx, key); releaseErr != nil {
+					return nil, errors.Wrapf(err, "IdempotencyStore.Release: %v", releaseErr)
+				}
+				return nil, err
+			}
+			if err := store.Complete(ctx, key, *result); err != nil {
+				return nil, errors.Wrap(err, "IdempotencyStore.Complete")
+			}
+			return result, nil
+		}
+	}
+}
+
+// MemoryIdempotencyStore keeps records for TTL after they were reserved.
+type MemoryIdempotencyStore struct {
+	TTL     time.Duration
+	Now     func() time.Time
+	mu      sync.Mutex
+	records map[string]IdempotencyRecord
+}
+
+func NewMemoryIdempotencyStore(ttl time.Duration) *MemoryIdempotencyStore {
+	return &MemoryIdempotencyStore{TTL: ttl, Now: time.Now, records: make(map[string]IdempotencyRecord)}
+}
+
+func (s *MemoryIdempotencyStore) Reserve(ctx context.Context, record IdempotencyRecord) (*IdempotencyRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.Now()
+	if existing, ok := s.records[record.Key]; ok {
+		if s.TTL <= 0 || now.Sub(existing.CreatedAt) < s.TTL {
+			return &existing, nil
+		}
+	}
+	record.CreatedAt = now
+	s.records[record.Key] = record
+	return nil, nil
+}
+
+func (s *MemoryIdempotencyStore) Complete(ctx context.Context, key string, result Result) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	record, ok := s.records[key]
+	if !ok {
+		return errors.Errorf("idempotency key %q is not reserved", key)
+	}
+	record.Result = &result
+	s.records[key] = record
+	return nil
+}
+
+func (s *MemoryIdempotencyStore) Release(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.records, key)
+	return nil
+}
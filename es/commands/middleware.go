@@ -0,0 +1,178 @@
+package commands
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/novabankapp/common.data/logger"
+	"github.com/novabankapp/wallet.data/constants"
+	"github.com/novabankapp/wallet.data/es/metadata"
+	"github.com/novabankapp/wallet.data/tracing"
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// Logging logs every command with its outcome. Commands refused by validation,
+// authorization or the wallet's rules are warnings; everything else that failed is an error.
+func Logging(l logger.Logger) Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx context.Context, cmd Command) (*Result, error) {
+			started := time.Now()
+			result, err := next(ctx, cmd)
+			took := time.Since(started)
+			switch outcome := OutcomeOf(err); outcome {
+			case OutcomeSucceeded:
+				l.Infof("(%s) walletId: {%s}, version: {%d}, took: {%s}", cmd.CommandName(), cmd.WalletID(), result.Version, took)
+			case OutcomeFailed:
+				l.Errorf("(%s) walletId: {%s}, took: {%s}, err: {%v}", cmd.CommandName(), cmd.WalletID(), took, err)
+			default:
+				l.Warnf("(%s) walletId: {%s}, %s: {%v}", cmd.CommandName(), cmd.WalletID(), outcome, err)
+			}
+			return result, err
+		}
+	}
+}
+
+// Tracing starts a span per command; the handler's spans become its children.
+func Tracing() Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx context.Context, cmd Command) (*Result, error) {
+			ctx, span := tracing.StartSpan(ctx, "CommandBus."+cmd.CommandName())
+			defer span.End()
+			span.SetAttributes(attribute.String(constants.AggregateID, cmd.WalletID()))
+			if principal, ok := PrincipalFromContext(ctx); ok {
+				span.SetAttributes(attribute.String(constants.UserID, principal.ID))
+			}
+
+			result, err := next(ctx, cmd)
+			span.SetAttributes(attribute.String("outcome", string(OutcomeOf(err))))
+			if err != nil {
+				tracing.TraceErr(span, err)
+			}
+			return result, err
+		}
+	}
+}
+
+// CommandMetrics records every dispatched command.
+type CommandMetrics interface {
+	ObserveCommand(command string, outcome Outcome, elapsed time.Duration)
+}
+
+func Metrics(m CommandMetrics) Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx context.Context, cmd Command) (*Result, error) {
+			started := time.Now()
+			result, err := next(ctx, cmd)
+			m.ObserveCommand(cmd.CommandName(), OutcomeOf(err), time.Since(started))
+			return result, err
+		}
+	}
+}
+
+// Causation completes the event metadata of every command. The actor defaults to the
+// principal, or to the system when there is none; a command sent without a correlation
+// id starts a new correlation and is its own cause.
+func Causation() Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx context.Context, cmd Command) (*Result, error) {
+			m, _ := metadata.FromContext(ctx)
+			if principal, ok := PrincipalFromContext(ctx); ok && m.ActorID == "" {
+				m.ActorID = principal.ID
+				if m.ActorType == "" {
+					m.ActorType = principal.actorType()
+				}
+			}
+			if m.ActorType == "" {
+				m.ActorType = metadata.ActorSystem
+			}
+			if m.CorrelationID == "" {
+				m.CorrelationID = uuid.New().String()
+			}
+			if m.CausationID == "" {
+				m.CausationID = m.CorrelationID
+			}
+			return next(metadata.WithMetadata(ctx, m), cmd)
+		}
+	}
+}
+
+// Principal is who sends a command.
+type Principal struct {
+	ID    string
+	Roles []string
+	// Type is the kind of actor the principal is; metadata.ActorCustomer when empty.
+	Type metadata.ActorType
+}
+
+func (p Principal) actorType() metadata.ActorType {
+	if p.Type == "" {
+		return metadata.ActorCustomer
+	}
+	return p.Type
+}
+
+func (p Principal) HasRole(role string) bool {
+	for _, r := range p.Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+type principalKey struct{}
+
+func WithPrincipal(ctx context.Context, principal Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, principal)
+}
+
+func PrincipalFromContext(ctx context.Context) (Principal, bool) {
+	principal, ok := ctx.Value(principalKey{}).(Principal)
+	return principal, ok
+}
+
+// Authorizer decides whether the principal may run the command.
+type Authorizer interface {
+	Authorize(ctx context.Context, principal Principal, cmd Command) error
+}
+
+type AuthorizerFunc func(ctx context.Context, principal Principal, cmd Command) error
+
+func (f AuthorizerFunc) Authorize(ctx context.Context, principal Principal, cmd Command) error {
+	return f(ctx, principal, cmd)
+}
+
+// AnyCommand is the key of RoleAuthorizer roles that applies to every command.
+const AnyCommand = "*"
+
+// RoleAuthorizer allows a command when the principal has one of the roles listed for the
+// command's name or for AnyCommand. Commands without roles are denied.
+func RoleAuthorizer(roles map[string][]string) Authorizer {
+	return AuthorizerFunc(func(ctx context.Context, principal Principal, cmd Command) error {
+		allowed := append(append([]string(nil), roles[cmd.CommandName()]...), roles[AnyCommand]...)
+		for _, role := range allowed {
+			if principal.HasRole(role) {
+				return nil
+			}
+		}
+		return errors.Wrapf(ErrUnauthorized, "%s: principal %q", cmd.CommandName(), principal.ID)
+	})
+}
+
+// Authorization refuses commands sent without a principal or that authorizer refuses.
+func Authorization(authorizer Authorizer) Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx context.Context, cmd Command) (*Result, error) {
+			principal, ok := PrincipalFromContext(ctx)
+			if !ok {
+				return nil, errors.Wrapf(ErrUnauthorized, "%s: no principal", cmd.CommandName())
+			}
+			if err := authorizer.Authorize(ctx, principal, cmd); err != nil {
+				return nil, err
+			}
+			return next(ctx, cmd)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package commands
+
+import (
+	"github.com/novabankapp/wallet.data/es/aggregate"
+	"github.com/pkg/errors"
+)
+
+// Outcome sorts how a dispatched command ended, for metrics and for mapping errors to
+// transport status codes.
+type Outcome string
+
+const (
+	OutcomeSucceeded    Outcome = "succeeded"
+	OutcomeInvalid      Outcome = "invalid"
+	OutcomeUnauthorized Outcome = "unauthorized"
+	// OutcomeRejected means the wallet's rules refused the command, e.g. insufficient funds,
+	// or the command was blocked or quarantined.
+	OutcomeRejected Outcome = "rejected"
+	// OutcomeConflict means the idempotency key or the wallet version was in use.
+	OutcomeConflict Outcome = "conflict"
+	OutcomeFailed   Outcome = "failed"
+)
+
+var rejections = []error{
+	aggregate.ErrWalletAlreadyCreated,
+	aggregate.ErrWalletNotCreated,
+	aggregate.ErrWalletDeleted,
+	aggregate.ErrWalletLocked,
+	aggregate.ErrWalletNotLocked,
+	aggregate.ErrWalletBlacklisted,
+	aggregate.ErrWalletNotBlacklisted,
+	aggregate.ErrNoExpiredRestriction,
+	aggregate.ErrInvalidAmount,
+	aggregate.ErrInsufficientFunds,
+	aggregate.ErrReleaseExceedsHeldBalance,
+	ErrBlocked,
+	ErrQuarantined,
+}
+
+// OutcomeOf classifies the error returned by Dispatch; a nil error succeeded.
+func OutcomeOf(err error) Outcome {
+	switch {
+	case err == nil:
+		return OutcomeSucceeded
+	case errors.Is(err, ErrInvalidCommand):
+		return OutcomeInvalid
+	case errors.Is(err, ErrUnauthorized):
+		return OutcomeUnauthorized
+	case errors.Is(err, ErrIdempotencyKeyReused),
+		errors.Is(err, ErrCommandInProgress),
+		aggregate.IsConcurrencyConflict(err):
+		return OutcomeConflict
+	}
+	for _, rejection := range rejections {
+		if errors.Is(err, rejection) {
+			return OutcomeRejected
+		}
+	}
+	return OutcomeFailed
+}
@@ -0,0 +1,31 @@
+package commands
+
+import (
+	"context"
+
+	"github.com/novabankapp/wallet.data/es/aggregate"
+)
+
+const LiftExpiredRestrictions = "LiftExpiredRestrictions"
+
+// LiftExpiredRestrictionsCommand lifts the lock and the blacklisting of a wallet once
+// their expiry has passed by the handler's clock. It fails with
+// aggregate.ErrNoExpiredRestriction when nothing has expired.
+type LiftExpiredRestrictionsCommand struct {
+	ID string `json:"wallet_id"`
+}
+
+func (c LiftExpiredRestrictionsCommand) CommandName() string { return LiftExpiredRestrictions }
+func (c LiftExpiredRestrictionsCommand) WalletID() string    { return c.ID }
+
+func (c LiftExpiredRestrictionsCommand) Validate() error {
+	v := validator{command: LiftExpiredRestrictions}
+	v.required("wallet_id", c.ID)
+	return v.err
+}
+
+func (h *WalletHandlers) LiftExpiredRestrictions(ctx context.Context, cmd LiftExpiredRestrictionsCommand) (*Result, error) {
+	return h.execute(ctx, cmd, func(ctx context.Context, wallet *aggregate.WalletAggregate) error {
+		return wallet.LiftExpiredRestrictions(ctx, h.Now())
+	})
+}
@@ -0,0 +1,115 @@
+package commands
+
+import (
+	"context"
+
+	"github.com/novabankapp/wallet.data/domain"
+	"github.com/novabankapp/wallet.data/es/aggregate"
+)
+
+const (
+	RecordScreening   = "RecordScreening"
+	ReleaseQuarantine = "ReleaseQuarantine"
+	ConfirmQuarantine = "ConfirmQuarantine"
+)
+
+// RecordScreeningCommand records a watchlist hit on a credit or debit of a wallet. Credit
+// is the credit of a quarantined credit: it is applied and its amount held in the same
+// append, so that the funds are never available in between.
+type RecordScreeningCommand struct {
+	ID        string               `json:"wallet_id"`
+	Screening domain.Screening     `json:"screening"`
+	Credit    *CreditWalletCommand `json:"credit,omitempty"`
+}
+
+// ReleaseQuarantineCommand makes the amount held by the quarantine of a screening
+// available again once a review cleared the hit.
+type ReleaseQuarantineCommand struct {
+	ID          string `json:"wallet_id"`
+	ScreeningID string `json:"screening_id"`
+	Description string `json:"description"`
+}
+
+// ConfirmQuarantineCommand debits the amount held by the quarantine of a screening to
+// CreditWalletID once a review upheld the hit. Like DebitWalletCommand, it does not credit
+// CreditWalletID.
+type ConfirmQuarantineCommand struct {
+	ID             string `json:"wallet_id"`
+	ScreeningID    string `json:"screening_id"`
+	CreditWalletID string `json:"credit_wallet_id"`
+	Description    string `json:"description"`
+}
+
+func (c RecordScreeningCommand) CommandName() string   { return RecordScreening }
+func (c RecordScreeningCommand) WalletID() string      { return c.ID }
+func (c ReleaseQuarantineCommand) CommandName() string { return ReleaseQuarantine }
+func (c ReleaseQuarantineCommand) WalletID() string    { return c.ID }
+func (c ConfirmQuarantineCommand) CommandName() string { return ConfirmQuarantine }
+func (c ConfirmQuarantineCommand) WalletID() string    { return c.ID }
+
+func (c RecordScreeningCommand) Validate() error {
+	v := validator{command: RecordScreening}
+	v.required("wallet_id", c.ID)
+	v.required("screening.id", c.Screening.ID)
+	switch c.Screening.Outcome {
+	case domain.ScreeningBlocked:
+	case domain.ScreeningQuarantined:
+		v.positive("screening.amount", c.Screening.Amount)
+	default:
+		v.fail("screening.outcome", "must be blocked or quarantined")
+	}
+	if c.Credit != nil {
+		if c.Screening.Outcome != domain.ScreeningQuarantined {
+			v.fail("credit", "is only applied with a quarantine")
+		}
+		if c.Credit.ID != c.ID {
+			v.fail("credit.wallet_id", "must be wallet_id")
+		}
+		if !c.Credit.Amount.Equal(c.Screening.Amount) {
+			v.fail("credit.amount", "must be the quarantined amount")
+		}
+		if v.err == nil {
+			v.err = c.Credit.Validate()
+		}
+	}
+	return v.err
+}
+
+func (c ReleaseQuarantineCommand) Validate() error {
+	v := validator{command: ReleaseQuarantine}
+	v.required("wallet_id", c.ID)
+	v.required("screening_id", c.ScreeningID)
+	return v.err
+}
+
+func (c ConfirmQuarantineCommand) Validate() error {
+	v := validator{command: ConfirmQuarantine}
+	v.required("wallet_id", c.ID)
+	v.required("screening_id", c.ScreeningID)
+	v.required("credit_wallet_id", c.CreditWalletID)
+	v.otherWallet("credit_wallet_id", c.ID, c.CreditWalletID)
+	return v.err
+}
+
+func (h *WalletHandlers) RecordScreening(ctx context.Context, cmd RecordScreeningCommand) (*Result, error) {
+	return h.execute(ctx, cmd, func(ctx context.Context, wallet *aggregate.WalletAggregate) error {
+		if cmd.Credit != nil {
+			if err := wallet.CreditWallet(ctx, cmd.Credit.DebitWalletID, cmd.Credit.Amount, cmd.Credit.Description); err != nil {
+				return err
+			}
+		}
+		return wallet.RecordScreening(ctx, cmd.Screening)
+	})
+}
+
+func (h *WalletHandlers) ReleaseQuarantine(ctx context.Context, cmd ReleaseQuarantineCommand) (*Result, error) {
+	return h.execute(ctx, cmd, func(ctx context.Context, wallet *aggregate.WalletAggregate) error {
+		return wallet.ReleaseQuarantine(ctx, cmd.ScreeningID, cmd.Description)
+	})
+}
+
+func (h *WalletHandlers) ConfirmQuarantine(ctx context.Context, cmd ConfirmQuarantineCommand) (*Result, error) {
+	return h.execute(ctx, cmd, func(ctx context.Context, wallet *aggregate.WalletAggregate) error {
+		return wallet.ConfirmQuarantine(ctx, cmd.ScreeningID, cmd.CreditWalletID, cmd.Description)
+	})
+}
@@ -1,6 +1,8 @@
 package v1
 
 import (
+	"time"
+
 	es "github.com/novabankapp/common.data/eventstore"
 	"github.com/shopspring/decimal"
 )
@@ -16,8 +18,31 @@ const (
 	WalletUnBlacklisted  = "V1_WALLET_UNBLACKLISTED"
 	WalletCreditReserved = "V1_WALLET_CREDIT_RESERVED"
 	WalletCreditReleased = "V1_WALLET_CREDIT_RELEASED"
+	WalletScreened       = "V1_WALLET_SCREENED"
+
+	WalletQuarantineReleased  = "V1_WALLET_QUARANTINE_RELEASED"
+	WalletQuarantineConfirmed = "V1_WALLET_QUARANTINE_CONFIRMED"
 )
 
+// PersonalDataFields lists the payload fields of each event type that hold personal data
+// of the wallet's owner. They are encrypted with the owner's key when a cipher is in use.
+var PersonalDataFields = map[string][]string{
+	WalletCreated:        {"UserId", "AccountId", "Description"},
+	WalletDebited:        {"Description"},
+	WalletCredited:       {"Description"},
+	WalletCreditReserved: {"Description"},
+	WalletCreditReleased: {"Description"},
+	WalletLocked:         {"Description"},
+	WalletUnlocked:       {"Description"},
+	WalletBlacklisted:    {"Description"},
+	WalletUnBlacklisted:  {"Description"},
+	WalletDeleted:        {"Description"},
+	WalletScreened:       {"Matches", "Description"},
+
+	WalletQuarantineReleased:  {"Description"},
+	WalletQuarantineConfirmed: {"Description"},
+}
+
 type WalletCreatedEvent struct {
 	Amount      decimal.Decimal
 	Description string
@@ -44,20 +69,66 @@ type WalletCreditReleasedEvent struct {
 	Description string
 }
 
+// WalletScreenedEvent records a watchlist hit on a credit or debit. A quarantined hit
+// holds Amount on the wallet like a reserved credit, until a quarantine released or
+// confirmed event with its ScreeningID.
+type WalletScreenedEvent struct {
+	ScreeningID          string
+	Outcome              string
+	Command              string
+	CounterpartyWalletId string
+	Amount               decimal.Decimal
+	Provider             string
+	Matches              string
+	Description          string
+}
+
+// WalletQuarantineReleasedEvent makes the amount held by a quarantined screening available
+// again after a review cleared the hit.
+type WalletQuarantineReleasedEvent struct {
+	ScreeningID string
+	Amount      decimal.Decimal
+	Description string
+}
+
+// WalletQuarantineConfirmedEvent takes the amount held by a quarantined screening off the
+// wallet, to CreditWalletId, after a review upheld the hit.
+type WalletQuarantineConfirmedEvent struct {
+	ScreeningID    string
+	Amount         decimal.Decimal
+	CreditWalletId string
+	Description    string
+}
+
+// WalletLockedEvent and WalletBlacklistedEvent impose a restriction. Events written before
+// restrictions had reason codes carry only a Description.
 type WalletLockedEvent struct {
 	Description string
+	ReasonCode  string
+	Authority   string
+	Evidence    []string
+	ExpiresAt   *time.Time
 }
 type WalletDeletedEvent struct {
 	Description string
 }
+
+// WalletUnlockedEvent and WalletUnBlacklistedEvent lift a restriction. Expired is set when
+// it was lifted because its expiry had passed.
 type WalletUnlockedEvent struct {
 	Description string
+	Expired     bool
 }
 type WalletBlacklistedEvent struct {
 	Description string
+	ReasonCode  string
+	Authority   string
+	Evidence    []string
+	ExpiresAt   *time.Time
 }
 type WalletUnBlacklistedEvent struct {
 	Description string
+	Expired     bool
 }
 
 func NewWalletCreatedEvent(aggregate es.Aggregate,
@@ -129,19 +200,38 @@ func NewWalletCreditReleasedEvent(aggregate es.Aggregate, amount decimal.Decimal
 	}
 	return event, nil
 }
-func NewWalletLockedEvent(aggregate es.Aggregate, description string) (es.Event, error) {
-	eventData := WalletLockedEvent{
-		Description: description,
+func NewWalletScreenedEvent(aggregate es.Aggregate, eventData WalletScreenedEvent) (es.Event, error) {
+	event := es.NewBaseEvent(aggregate, WalletScreened)
+	if err := event.SetJsonData(&eventData); err != nil {
+		return es.Event{}, err
 	}
+	return event, nil
+}
+func NewWalletQuarantineReleasedEvent(aggregate es.Aggregate, eventData WalletQuarantineReleasedEvent) (es.Event, error) {
+	event := es.NewBaseEvent(aggregate, WalletQuarantineReleased)
+	if err := event.SetJsonData(&eventData); err != nil {
+		return es.Event{}, err
+	}
+	return event, nil
+}
+func NewWalletQuarantineConfirmedEvent(aggregate es.Aggregate, eventData WalletQuarantineConfirmedEvent) (es.Event, error) {
+	event := es.NewBaseEvent(aggregate, WalletQuarantineConfirmed)
+	if err := event.SetJsonData(&eventData); err != nil {
+		return es.Event{}, err
+	}
+	return event, nil
+}
+func NewWalletLockedEvent(aggregate es.Aggregate, eventData WalletLockedEvent) (es.Event, error) {
 	event := es.NewBaseEvent(aggregate, WalletLocked)
 	if err := event.SetJsonData(&eventData); err != nil {
 		return es.Event{}, err
 	}
 	return event, nil
 }
-func NewWalletUnlockedEvent(aggregate es.Aggregate, description string) (es.Event, error) {
+func NewWalletUnlockedEvent(aggregate es.Aggregate, description string, expired bool) (es.Event, error) {
 	eventData := WalletUnlockedEvent{
 		Description: description,
+		Expired:     expired,
 	}
 	event := es.NewBaseEvent(aggregate, WalletUnlocked)
 	if err := event.SetJsonData(&eventData); err != nil {
@@ -149,19 +239,17 @@ func NewWalletUnlockedEvent(aggregate es.Aggregate, description string) (es.Even
 	}
 	return event, nil
 }
-func NewWalletBlacklistedEvent(aggregate es.Aggregate, description string) (es.Event, error) {
-	eventData := WalletBlacklistedEvent{
-		Description: description,
-	}
+func NewWalletBlacklistedEvent(aggregate es.Aggregate, eventData WalletBlacklistedEvent) (es.Event, error) {
 	event := es.NewBaseEvent(aggregate, WalletBlacklisted)
 	if err := event.SetJsonData(&eventData); err != nil {
 		return es.Event{}, err
 	}
 	return event, nil
 }
-func NewWalletUnBlacklistedEvent(aggregate es.Aggregate, description string) (es.Event, error) {
+func NewWalletUnBlacklistedEvent(aggregate es.Aggregate, description string, expired bool) (es.Event, error) {
 	eventData := WalletUnBlacklistedEvent{
 		Description: description,
+		Expired:     expired,
 	}
 	event := es.NewBaseEvent(aggregate, WalletUnBlacklisted)
 	if err := event.SetJsonData(&eventData); err != nil {
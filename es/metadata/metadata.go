@@ -0,0 +1,109 @@
+// Package metadata carries who sent a command, through which channel and because of what
+// into the metadata of the events the command writes. Transports put a Metadata into the
+// command context with WithMetadata; the wallet aggregate writes it next to the trace
+// context of every event it raises.
+package metadata
+
+import (
+	"context"
+	"encoding/json"
+
+	es "github.com/novabankapp/common.data/eventstore"
+)
+
+// ActorType is the kind of actor that sent a command.
+type ActorType string
+
+const (
+	ActorCustomer ActorType = "customer"
+	ActorOperator ActorType = "operator"
+	ActorSystem   ActorType = "system"
+)
+
+// Channels the wallet commands arrive through.
+const (
+	ChannelREST = "rest"
+	ChannelGRPC = "grpc"
+	ChannelCLI  = "cli"
+	// ChannelInternal is for commands sent by a process reacting to an event.
+	ChannelInternal = "internal"
+)
+
+// Keys of the fields in event metadata.
+const (
+	ActorIDKey       = "actor_id"
+	ActorTypeKey     = "actor_type"
+	ChannelKey       = "channel"
+	CorrelationIDKey = "correlation_id"
+	CausationIDKey   = "causation_id"
+	ClientIPKey      = "client_ip"
+)
+
+// Metadata describes the cause of a command. CorrelationID is shared by everything that
+// follows from one request; CausationID is the id of the request or event that directly
+// caused the command.
+type Metadata struct {
+	ActorID       string    `json:"actor_id,omitempty"`
+	ActorType     ActorType `json:"actor_type,omitempty"`
+	Channel       string    `json:"channel,omitempty"`
+	CorrelationID string    `json:"correlation_id,omitempty"`
+	CausationID   string    `json:"causation_id,omitempty"`
+	ClientIP      string    `json:"client_ip,omitempty"`
+}
+
+// Fields returns the metadata as event metadata fields, leaving out the empty ones.
+func (m Metadata) Fields() map[string]string {
+	fields := make(map[string]string)
+	for key, value := range map[string]string{
+		ActorIDKey:       m.ActorID,
+		ActorTypeKey:     string(m.ActorType),
+		ChannelKey:       m.Channel,
+		CorrelationIDKey: m.CorrelationID,
+		CausationIDKey:   m.CausationID,
+		ClientIPKey:      m.ClientIP,
+	} {
+		if value != "" {
+			fields[key] = value
+		}
+	}
+	return fields
+}
+
+type metadataKey struct{}
+
+// WithMetadata makes m the metadata of the events written by commands dispatched with ctx.
+func WithMetadata(ctx context.Context, m Metadata) context.Context {
+	return context.WithValue(ctx, metadataKey{}, m)
+}
+
+func FromContext(ctx context.Context) (Metadata, bool) {
+	m, ok := ctx.Value(metadataKey{}).(Metadata)
+	return m, ok
+}
+
+// FromEvent reads the metadata written with evt. Events written before the metadata was
+// introduced, or without it, yield an empty Metadata.
+func FromEvent(evt es.Event) Metadata {
+	var m Metadata
+	if data := evt.GetMetadata(); len(data) > 0 {
+		_ = json.Unmarshal(data, &m)
+	}
+	return m
+}
+
+// CausedBy returns the metadata of a command a process sends in reaction to evt: it stays
+// in the correlation of evt and names evt as its cause.
+func CausedBy(evt es.Event, actorID string) Metadata {
+	cause := FromEvent(evt)
+	correlationID := cause.CorrelationID
+	if correlationID == "" {
+		correlationID = evt.GetEventID()
+	}
+	return Metadata{
+		ActorID:       actorID,
+		ActorType:     ActorSystem,
+		Channel:       ChannelInternal,
+		CorrelationID: correlationID,
+		CausationID:   evt.GetEventID(),
+	}
+}
@@ -0,0 +1,11 @@
+package models
+
+import "time"
+
+// RestrictionExpiry is a restriction of kind imposed on a wallet that expires at ExpiresAt.
+// The projection keeps one for every active restriction with an expiry.
+type RestrictionExpiry struct {
+	Kind      string    `json:"kind"`
+	ExpiresAt time.Time `json:"expires_at"`
+	WalletID  string    `json:"wallet_id"`
+}
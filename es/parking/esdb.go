@@ -0,0 +1,133 @@
+package parking
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"math"
+
+	"github.com/EventStore/EventStore-Client-Go/esdb"
+	"github.com/gofrs/uuid"
+	"github.com/pkg/errors"
+)
+
+const (
+	// ParkingStream must stay outside the "wallet-" prefix: replays, exports and the
+	// consumers following $all take every stream with that prefix for a wallet.
+	ParkingStream = "parking-wallet"
+	EventParked   = "PARKED"
+	EventUpdated  = "PARKED_UPDATED"
+	EventUnparked = "UNPARKED"
+)
+
+type unparked struct {
+	ID     string `json:"id"`
+	Reason string `json:"reason"`
+}
+
+type esdbLot struct {
+	db *esdb.Client
+}
+
+// NewESDBLot keeps the lot as an event stream, so parking, replays and skips leave an
+// audit trail. Reads fold the whole stream; the lot is meant to stay small.
+func NewESDBLot(db *esdb.Client) Lot {
+	return &esdbLot{db: db}
+}
+
+func (l *esdbLot) append(ctx context.Context, eventType string, data interface{}) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return errors.Wrap(err, "json.Marshal")
+	}
+	event := esdb.EventData{
+		EventID:     uuid.Must(uuid.NewV4()),
+		EventType:   eventType,
+		ContentType: esdb.JsonContentType,
+		Data:        payload,
+	}
+	if _, err := l.db.AppendToStream(ctx, ParkingStream, esdb.AppendToStreamOptions{}, event); err != nil {
+		return errors.Wrap(err, "db.AppendToStream")
+	}
+	return nil
+}
+
+func (l *esdbLot) load(ctx context.Context) (map[string]Entry, error) {
+	entries := make(map[string]Entry)
+	stream, err := l.db.ReadStream(ctx, ParkingStream, esdb.ReadStreamOptions{Direction: esdb.Forwards, From: esdb.Start{}}, math.MaxUint64)
+	if err != nil {
+		if errors.Is(err, esdb.ErrStreamNotFound) {
+			return entries, nil
+		}
+		return nil, errors.Wrap(err, "db.ReadStream")
+	}
+	defer stream.Close()
+
+	for {
+		resolved, err := stream.Recv()
+		if errors.Is(err, io.EOF) || errors.Is(err, esdb.ErrStreamNotFound) {
+			return entries, nil
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "stream.Recv")
+		}
+		switch resolved.Event.EventType {
+		case EventParked, EventUpdated:
+			var entry Entry
+			if err := json.Unmarshal(resolved.Event.Data, &entry); err != nil {
+				return nil, errors.Wrap(err, "json.Unmarshal")
+			}
+			entries[entry.ID] = entry
+		case EventUnparked:
+			var removed unparked
+			if err := json.Unmarshal(resolved.Event.Data, &removed); err != nil {
+				return nil, errors.Wrap(err, "json.Unmarshal")
+			}
+			delete(entries, removed.ID)
+		}
+	}
+}
+
+func (l *esdbLot) Park(ctx context.Context, entry Entry) error {
+	return l.append(ctx, EventParked, entry)
+}
+
+func (l *esdbLot) List(ctx context.Context, group string, limit int) ([]Entry, error) {
+	entries, err := l.load(ctx)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]Entry, 0, len(entries))
+	for _, entry := range entries {
+		if group == "" || entry.Group == group {
+			result = append(result, entry)
+		}
+	}
+	return sortAndLimit(result, limit), nil
+}
+
+func (l *esdbLot) Get(ctx context.Context, id string) (*Entry, error) {
+	entries, err := l.load(ctx)
+	if err != nil {
+		return nil, err
+	}
+	entry, ok := entries[id]
+	if !ok {
+		return nil, ErrEntryNotFound
+	}
+	return &entry, nil
+}
+
+func (l *esdbLot) Update(ctx context.Context, entry Entry) error {
+	if _, err := l.Get(ctx, entry.ID); err != nil {
+		return err
+	}
+	return l.append(ctx, EventUpdated, entry)
+}
+
+func (l *esdbLot) Remove(ctx context.Context, id, reason string) error {
+	if _, err := l.Get(ctx, id); err != nil {
+		return err
+	}
+	return l.append(ctx, EventUnparked, unparked{ID: id, Reason: reason})
+}
@@ -0,0 +1,91 @@
+package parking
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// NewHandler serves the ops API under prefix (e.g. "/ops/parked-events"):
+//
+//	GET  {prefix}?group=&limit=   list parked events
+//	GET  {prefix}/{id}            inspect one, with error and stack
+//	POST {prefix}/{id}/replay     replay it into the handler
+//	POST {prefix}/{id}/skip       drop it; body {"reason": "..."}
+func NewHandler(prefix string, ops *Ops) http.Handler {
+	prefix = strings.TrimSuffix(prefix, "/")
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path := strings.TrimPrefix(r.URL.Path, prefix)
+		if path == r.URL.Path && prefix != "" {
+			writeError(w, http.StatusNotFound, errors.New("not found"))
+			return
+		}
+		parts := strings.Split(strings.Trim(path, "/"), "/")
+
+		switch {
+		case parts[0] == "" && r.Method == http.MethodGet:
+			limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+			entries, err := ops.List(r.Context(), r.URL.Query().Get("group"), limit)
+			if err != nil {
+				writeError(w, http.StatusInternalServerError, err)
+				return
+			}
+			writeJSON(w, http.StatusOK, entries)
+		case len(parts) == 1 && r.Method == http.MethodGet:
+			entry, err := ops.Inspect(r.Context(), parts[0])
+			if err != nil {
+				writeError(w, statusOf(err), err)
+				return
+			}
+			writeJSON(w, http.StatusOK, entry)
+		case len(parts) == 2 && parts[1] == "replay" && r.Method == http.MethodPost:
+			if err := ops.Replay(r.Context(), parts[0]); err != nil {
+				writeError(w, statusOf(err), err)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+		case len(parts) == 2 && parts[1] == "skip" && r.Method == http.MethodPost:
+			var body struct {
+				Reason string `json:"reason"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+				writeError(w, http.StatusBadRequest, err)
+				return
+			}
+			if err := ops.Skip(r.Context(), parts[0], body.Reason); err != nil {
+				writeError(w, statusOf(err), err)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			writeError(w, http.StatusNotFound, errors.New("not found"))
+		}
+	})
+}
+
+func statusOf(err error) int {
+	switch {
+	case errors.Is(err, ErrEntryNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, ErrSkipReasonRequired):
+		return http.StatusBadRequest
+	case errors.Is(err, ErrReplayFailed):
+		// The event stays parked, with the new error in its replay errors.
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
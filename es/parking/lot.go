@@ -0,0 +1,156 @@
+// Package parking keeps events a consumer gave up on, with the error that stopped it,
+// until an operator replays or skips them.
+package parking
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/EventStore/EventStore-Client-Go/esdb"
+	"github.com/gofrs/uuid"
+	es "github.com/novabankapp/common.data/eventstore"
+	"github.com/novabankapp/wallet.data/retry"
+	"github.com/pkg/errors"
+)
+
+var ErrEntryNotFound = errors.New("parked event not found")
+
+// Entry is a parked event. Stack is the error printed with %+v, which includes the stack
+// traces recorded by pkg/errors.
+type Entry struct {
+	ID           string           `json:"id"`
+	Group        string           `json:"group"`
+	StreamID     string           `json:"stream_id"`
+	EventID      string           `json:"event_id"`
+	EventType    string           `json:"event_type"`
+	EventNumber  uint64           `json:"event_number"`
+	RecordedAt   time.Time        `json:"recorded_at"`
+	Data         []byte           `json:"data"`
+	Metadata     []byte           `json:"metadata,omitempty"`
+	Error        string           `json:"error"`
+	Stack        string           `json:"stack,omitempty"`
+	Class        retry.ErrorClass `json:"class"`
+	Attempts     int              `json:"attempts"`
+	ParkedAt     time.Time        `json:"parked_at"`
+	ReplayErrors []string         `json:"replay_errors,omitempty"`
+}
+
+// NewEntry records why a consumer group parked an event.
+func NewEntry(group string, recorded *esdb.RecordedEvent, result retry.Result, now time.Time) Entry {
+	return Entry{
+		ID:          uuid.Must(uuid.NewV4()).String(),
+		Group:       group,
+		StreamID:    recorded.StreamID,
+		EventID:     recorded.EventID.String(),
+		EventType:   recorded.EventType,
+		EventNumber: recorded.EventNumber,
+		RecordedAt:  recorded.CreatedDate,
+		Data:        recorded.Data,
+		Metadata:    recorded.UserMetadata,
+		Error:       result.Err.Error(),
+		Stack:       fmt.Sprintf("%+v", result.Err),
+		Class:       result.Class,
+		Attempts:    result.Attempts,
+		ParkedAt:    now.UTC(),
+	}
+}
+
+// Event rebuilds the parked event as the consumer received it.
+func (e *Entry) Event() es.Event {
+	eventID, _ := uuid.FromString(e.EventID)
+	return es.NewEventFromRecorded(&esdb.RecordedEvent{
+		EventID:      eventID,
+		EventType:    e.EventType,
+		StreamID:     e.StreamID,
+		EventNumber:  e.EventNumber,
+		CreatedDate:  e.RecordedAt,
+		Data:         e.Data,
+		UserMetadata: e.Metadata,
+	})
+}
+
+// Lot stores parked events.
+type Lot interface {
+	Park(ctx context.Context, entry Entry) error
+	// List returns the parked events of a group (all groups for ""), oldest first.
+	List(ctx context.Context, group string, limit int) ([]Entry, error)
+	Get(ctx context.Context, id string) (*Entry, error)
+	Update(ctx context.Context, entry Entry) error
+	// Remove takes an event out of the lot; reason says whether it was replayed or skipped.
+	Remove(ctx context.Context, id, reason string) error
+}
+
+// MemoryLot is an in-memory Lot for tests and local runs.
+type MemoryLot struct {
+	mu      sync.Mutex
+	entries map[string]Entry
+}
+
+func NewMemoryLot() *MemoryLot {
+	return &MemoryLot{entries: make(map[string]Entry)}
+}
+
+func (m *MemoryLot) Park(ctx context.Context, entry Entry) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.entries[entry.ID] = entry
+	return nil
+}
+
+func (m *MemoryLot) List(ctx context.Context, group string, limit int) ([]Entry, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	result := make([]Entry, 0, len(m.entries))
+	for _, entry := range m.entries {
+		if group == "" || entry.Group == group {
+			result = append(result, entry)
+		}
+	}
+	return sortAndLimit(result, limit), nil
+}
+
+func (m *MemoryLot) Get(ctx context.Context, id string) (*Entry, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	entry, ok := m.entries[id]
+	if !ok {
+		return nil, ErrEntryNotFound
+	}
+	return &entry, nil
+}
+
+func (m *MemoryLot) Update(ctx context.Context, entry Entry) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.entries[entry.ID]; !ok {
+		return ErrEntryNotFound
+	}
+	m.entries[entry.ID] = entry
+	return nil
+}
+
+func (m *MemoryLot) Remove(ctx context.Context, id, reason string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.entries[id]; !ok {
+		return ErrEntryNotFound
+	}
+	delete(m.entries, id)
+	return nil
+}
+
+func sortAndLimit(entries []Entry, limit int) []Entry {
+	sort.Slice(entries, func(i, j int) bool {
+		if !entries[i].ParkedAt.Equal(entries[j].ParkedAt) {
+			return entries[i].ParkedAt.Before(entries[j].ParkedAt)
+		}
+		return entries[i].ID < entries[j].ID
+	})
+	if limit > 0 && len(entries) > limit {
+		entries = entries[:limit]
+	}
+	return entries
+}
@@ -0,0 +1,67 @@
+package parking
+
+import (
+	"context"
+	"time"
+
+	es "github.com/novabankapp/common.data/eventstore"
+	"github.com/pkg/errors"
+)
+
+const (
+	ReasonReplayed = "replayed"
+	ReasonSkipped  = "skipped"
+)
+
+var (
+	ErrSkipReasonRequired = errors.New("a reason is required to skip a parked event")
+	ErrReplayFailed       = errors.New("replay failed")
+)
+
+// Handler is the consumer a parked event is replayed into, e.g. WalletProjection.When.
+type Handler func(ctx context.Context, evt es.Event) error
+
+// Ops is the operator API over a Lot.
+type Ops struct {
+	Lot     Lot
+	Handler Handler
+	Now     func() time.Time
+}
+
+func NewOps(lot Lot, handler Handler) *Ops {
+	return &Ops{Lot: lot, Handler: handler, Now: time.Now}
+}
+
+func (o *Ops) List(ctx context.Context, group string, limit int) ([]Entry, error) {
+	return o.Lot.List(ctx, group, limit)
+}
+
+func (o *Ops) Inspect(ctx context.Context, id string) (*Entry, error) {
+	return o.Lot.Get(ctx, id)
+}
+
+// Replay hands the parked event to the handler once. On success the event leaves the
+// lot; on failure it stays, with the new error added to its ReplayErrors.
+func (o *Ops) Replay(ctx context.Context, id string) error {
+	entry, err := o.Lot.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+	if err := o.Handler(ctx, entry.Event()); err != nil {
+		entry.ReplayErrors = append(entry.ReplayErrors, o.Now().UTC().Format(time.RFC3339)+": "+err.Error())
+		if updateErr := o.Lot.Update(ctx, *entry); updateErr != nil {
+			return errors.Wrap(updateErr, "Lot.Update")
+		}
+		return errors.Wrap(ErrReplayFailed, err.Error())
+	}
+	return o.Lot.Remove(ctx, id, ReasonReplayed)
+}
+
+// Skip drops a parked event without handling it. The reason is recorded by lots that
+// keep an audit trail.
+func (o *Ops) Skip(ctx context.Context, id, reason string) error {
+	if reason == "" {
+		return ErrSkipReasonRequired
+	}
+	return o.Lot.Remove(ctx, id, ReasonSkipped+": "+reason)
+}
@@ -0,0 +1,130 @@
+package parking_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/EventStore/EventStore-Client-Go/esdb"
+	"github.com/gofrs/uuid"
+	es "github.com/novabankapp/common.data/eventstore"
+	"github.com/novabankapp/wallet.data/es/aggregate"
+	"github.com/novabankapp/wallet.data/es/parking"
+	"github.com/novabankapp/wallet.data/retry"
+	"github.com/pkg/errors"
+)
+
+func park(t *testing.T, lot parking.Lot, eventType string) parking.Entry {
+	t.Helper()
+	recorded := &esdb.RecordedEvent{
+		EventID:     uuid.Must(uuid.NewV4()),
+		EventType:   eventType,
+		StreamID:    "wallet-w-1",
+		EventNumber: 3,
+		CreatedDate: time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC),
+		Data:        []byte(`{"Amount":"5"}`),
+	}
+	result := retry.Result{Attempts: 5, Class: retry.Transient, Err: errors.New("cassandra unavailable")}
+	entry := parking.NewEntry("projection", recorded, result, time.Now())
+	if err := lot.Park(context.Background(), entry); err != nil {
+		t.Fatal(err)
+	}
+	return entry
+}
+
+func TestReplayRemovesTheEventOnlyWhenTheHandlerSucceeds(t *testing.T) {
+	ctx := context.Background()
+	lot := parking.NewMemoryLot()
+	entry := park(t, lot, "V1_WALLET_CREDITED")
+
+	var handled []es.Event
+	fail := true
+	ops := parking.NewOps(lot, func(ctx context.Context, evt es.Event) error {
+		handled = append(handled, evt)
+		if fail {
+			return errors.New("still unavailable")
+		}
+		return nil
+	})
+
+	if err := ops.Replay(ctx, entry.ID); !errors.Is(err, parking.ErrReplayFailed) {
+		t.Fatalf("replay returned %v", err)
+	}
+	kept, err := ops.Inspect(ctx, entry.ID)
+	if err != nil || len(kept.ReplayErrors) != 1 {
+		t.Fatalf("after failed replay: %+v, %v", kept, err)
+	}
+
+	fail = false
+	if err := ops.Replay(ctx, entry.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ops.Inspect(ctx, entry.ID); !errors.Is(err, parking.ErrEntryNotFound) {
+		t.Errorf("replayed event still parked: %v", err)
+	}
+	evt := handled[1]
+	if evt.GetEventID() != entry.EventID || evt.GetAggregateID() != "wallet-w-1" || evt.GetVersion() != 3 || evt.GetString() != `{"Amount":"5"}` {
+		t.Errorf("replayed %+v", evt)
+	}
+}
+
+func TestHTTPHandler(t *testing.T) {
+	lot := parking.NewMemoryLot()
+	first := park(t, lot, "V1_WALLET_CREDITED")
+	second := park(t, lot, "V1_WALLET_DEBITED")
+	ops := parking.NewOps(lot, func(context.Context, es.Event) error { return nil })
+	server := httptest.NewServer(parking.NewHandler("/ops/parked-events", ops))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/ops/parked-events?group=projection")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var entries []parking.Entry
+	if err := json.NewDecoder(resp.Body).Decode(&entries); err != nil || len(entries) != 2 {
+		t.Fatalf("list: %d entries, %v", len(entries), err)
+	}
+	resp.Body.Close()
+
+	resp, err = http.Get(server.URL + "/ops/parked-events/" + first.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var inspected parking.Entry
+	if err := json.NewDecoder(resp.Body).Decode(&inspected); err != nil || inspected.Error != "cassandra unavailable" || inspected.Stack == "" {
+		t.Fatalf("inspect: %+v, %v", inspected, err)
+	}
+	resp.Body.Close()
+
+	for _, c := range []struct {
+		path, body string
+		status     int
+	}{
+		{"/" + first.ID + "/replay", "", http.StatusNoContent},
+		{"/" + first.ID + "/replay", "", http.StatusNotFound},
+		{"/" + second.ID + "/skip", `{}`, http.StatusBadRequest},
+		{"/" + second.ID + "/skip", `{"reason":"duplicate of a manual correction"}`, http.StatusNoContent},
+	} {
+		resp, err := http.Post(server.URL+"/ops/parked-events"+c.path, "application/json", strings.NewReader(c.body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != c.status {
+			t.Errorf("POST %s %s: %d, want %d", c.path, c.body, resp.StatusCode, c.status)
+		}
+	}
+	if remaining, _ := lot.List(context.Background(), "", 0); len(remaining) != 0 {
+		t.Errorf("%d events still parked", len(remaining))
+	}
+}
+
+func TestParkingStreamIsNotAWalletStream(t *testing.T) {
+	if strings.HasPrefix(parking.ParkingStream, aggregate.GetWalletStreamID("")) {
+		t.Fatalf("%s would be read as a wallet stream", parking.ParkingStream)
+	}
+}
@@ -0,0 +1,142 @@
+// Package partition fans the events of one persistent subscription out to several
+// workers without giving up per-aggregate ordering: every event of a stream goes to the
+// same worker, chosen by hashing the stream id.
+package partition
+
+import (
+	"context"
+	"hash/fnv"
+	"sync"
+
+	"github.com/EventStore/EventStore-Client-Go/esdb"
+	"github.com/novabankapp/wallet.data/es/store"
+	"github.com/pkg/errors"
+)
+
+const (
+	DefaultWorkers   = 4
+	DefaultQueueSize = 64
+)
+
+// Handler processes one event on worker workerID and acks or nacks it on stream. It
+// returns an error only when the runner must stop.
+type Handler func(ctx context.Context, stream store.PersistentSubscription, workerID int, event *esdb.ResolvedEvent) error
+
+// Runner reads a subscription on one goroutine and hands each event to the worker that
+// owns its stream. Each worker has a queue of QueueSize events; when a worker's queue is
+// full the reader waits, so a slow wallet slows intake instead of growing memory, and no
+// more than Workers*(QueueSize+1) events are in flight.
+type Runner struct {
+	Workers   int
+	QueueSize int
+}
+
+func NewRunner(workers, queueSize int) *Runner {
+	return &Runner{Workers: workers, QueueSize: queueSize}
+}
+
+// Partition returns the worker, in [0, workers), that owns the stream.
+func Partition(streamID string, workers int) int {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(streamID))
+	return int(h.Sum32() % uint32(workers))
+}
+
+// Run processes events until ctx is done, the subscription drops or a handler fails,
+// and returns why. Events still queued are not acked and will be delivered again. The
+// caller closes the subscription after Run returns; until then the reader may still be
+// waiting in Recv.
+func (r *Runner) Run(ctx context.Context, stream store.PersistentSubscription, handle Handler) error {
+	workers, queueSize := r.Workers, r.QueueSize
+	if workers <= 0 {
+		workers = DefaultWorkers
+	}
+	if queueSize <= 0 {
+		queueSize = DefaultQueueSize
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	acks := &serializedAcks{PersistentSubscription: stream}
+	stop := make(chan error, workers+1)
+	fail := func(err error) {
+		select {
+		case stop <- err:
+		default:
+		}
+		cancel()
+	}
+
+	queues := make([]chan *esdb.ResolvedEvent, workers)
+	var wg sync.WaitGroup
+	for i := range queues {
+		queues[i] = make(chan *esdb.ResolvedEvent, queueSize)
+		wg.Add(1)
+		go func(workerID int, queue <-chan *esdb.ResolvedEvent) {
+			defer wg.Done()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case event := <-queue:
+					if err := handle(ctx, acks, workerID, event); err != nil {
+						fail(err)
+						return
+					}
+				}
+			}
+		}(i, queues[i])
+	}
+
+	go func() {
+		for {
+			event := stream.Recv()
+			if ctx.Err() != nil {
+				fail(ctx.Err())
+				return
+			}
+			if event.SubscriptionDropped != nil {
+				fail(errors.Wrap(event.SubscriptionDropped.Error, "Subscription Dropped"))
+				return
+			}
+			if event.EventAppeared == nil {
+				continue
+			}
+			queue := queues[Partition(event.EventAppeared.Event.StreamID, workers)]
+			select {
+			case queue <- event.EventAppeared:
+			case <-ctx.Done():
+				fail(ctx.Err())
+				return
+			}
+		}
+	}()
+
+	<-ctx.Done()
+	wg.Wait()
+	select {
+	case err := <-stop:
+		return err
+	default:
+		return ctx.Err()
+	}
+}
+
+// serializedAcks makes Ack and Nack safe to call from several workers; the esdb client
+// writes them to a single gRPC stream.
+type serializedAcks struct {
+	store.PersistentSubscription
+	mu sync.Mutex
+}
+
+func (s *serializedAcks) Ack(messages ...*esdb.ResolvedEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.PersistentSubscription.Ack(messages...)
+}
+
+func (s *serializedAcks) Nack(reason string, action esdb.Nack_Action, messages ...*esdb.ResolvedEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.PersistentSubscription.Nack(reason, action, messages...)
+}
@@ -0,0 +1,200 @@
+package partition_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/EventStore/EventStore-Client-Go/esdb"
+	es "github.com/novabankapp/common.data/eventstore"
+	"github.com/novabankapp/wallet.data/es/partition"
+	"github.com/novabankapp/wallet.data/es/store"
+)
+
+const group = "partitioned"
+
+func newStore(t *testing.T, streams, perStream int) *store.MemoryEventStore {
+	t.Helper()
+	db := store.NewMemoryEventStore()
+	if err := db.CreatePersistentSubscription(group, store.PersistentSubscriptionSettings{}); err != nil {
+		t.Fatal(err)
+	}
+	// interleave the streams so the subscription delivers them mixed together
+	for n := 0; n < perStream; n++ {
+		for s := 0; s < streams; s++ {
+			evt := es.Event{EventType: "TEST", Data: []byte(fmt.Sprintf(`{"n":%d}`, n))}
+			if _, err := db.AppendToStream(context.Background(), streamID(s), int64(n)-1, evt); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	return db
+}
+
+func streamID(s int) string {
+	return fmt.Sprintf("wallet-%d", s)
+}
+
+func connect(t *testing.T, ctx context.Context, db *store.MemoryEventStore) *store.MemoryPersistentSubscription {
+	t.Helper()
+	sub, err := db.ConnectToPersistentSubscription(ctx, group)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = sub.Close() })
+	return sub
+}
+
+func TestPartitionIsStable(t *testing.T) {
+	for s := 0; s < 100; s++ {
+		p := partition.Partition(streamID(s), 8)
+		if p < 0 || p >= 8 {
+			t.Fatalf("partition %d out of range", p)
+		}
+		if again := partition.Partition(streamID(s), 8); again != p {
+			t.Fatalf("%s moved from worker %d to %d", streamID(s), p, again)
+		}
+	}
+}
+
+func TestRunnerKeepsPerStreamOrder(t *testing.T) {
+	const streams, perStream = 16, 25
+	db := newStore(t, streams, perStream)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	sub := connect(t, ctx, db)
+
+	var (
+		mu       sync.Mutex
+		seen     = make(map[string][]uint64)
+		workerOf = make(map[string]int)
+		active   int32
+		peak     int32
+		handled  int32
+	)
+	handle := func(ctx context.Context, stream store.PersistentSubscription, workerID int, event *esdb.ResolvedEvent) error {
+		if n := atomic.AddInt32(&active, 1); n > atomic.LoadInt32(&peak) {
+			atomic.StoreInt32(&peak, n)
+		}
+		time.Sleep(time.Millisecond)
+		atomic.AddInt32(&active, -1)
+
+		mu.Lock()
+		id := event.Event.StreamID
+		seen[id] = append(seen[id], event.Event.EventNumber)
+		if w, ok := workerOf[id]; ok && w != workerID {
+			t.Errorf("%s handled by workers %d and %d", id, w, workerID)
+		}
+		workerOf[id] = workerID
+		mu.Unlock()
+
+		if err := stream.Ack(event); err != nil {
+			return err
+		}
+		if atomic.AddInt32(&handled, 1) == streams*perStream {
+			cancel()
+		}
+		return nil
+	}
+
+	err := partition.NewRunner(4, 8).Run(ctx, sub, handle)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Run returned %v", err)
+	}
+	if len(seen) != streams {
+		t.Fatalf("saw %d streams, want %d", len(seen), streams)
+	}
+	for id, numbers := range seen {
+		if len(numbers) != perStream {
+			t.Fatalf("%s: handled %d events, want %d", id, len(numbers), perStream)
+		}
+		for i, n := range numbers {
+			if n != uint64(i) {
+				t.Fatalf("%s: handled out of order: %v", id, numbers)
+			}
+		}
+	}
+	if peak < 2 {
+		t.Fatalf("workers never ran concurrently")
+	}
+}
+
+// countingSubscription counts the events taken off the subscription.
+type countingSubscription struct {
+	store.PersistentSubscription
+	received int32
+}
+
+func (c *countingSubscription) Recv() *esdb.SubscriptionEvent {
+	event := c.PersistentSubscription.Recv()
+	if event.EventAppeared != nil {
+		atomic.AddInt32(&c.received, 1)
+	}
+	return event
+}
+
+func TestRunnerAppliesBackpressure(t *testing.T) {
+	db := newStore(t, 1, 50)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	sub := &countingSubscription{PersistentSubscription: connect(t, ctx, db)}
+
+	release := make(chan struct{})
+	started := make(chan struct{}, 1)
+	handle := func(ctx context.Context, stream store.PersistentSubscription, workerID int, event *esdb.ResolvedEvent) error {
+		select {
+		case started <- struct{}{}:
+		default:
+		}
+		select {
+		case <-release:
+		case <-ctx.Done():
+			return nil
+		}
+		return stream.Ack(event)
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- partition.NewRunner(2, 3).Run(ctx, sub, handle) }()
+	<-started
+	time.Sleep(50 * time.Millisecond)
+
+	// one event in the handler, three queued and one held by the blocked reader
+	if got := atomic.LoadInt32(&sub.received); got != 5 {
+		t.Fatalf("runner took %d events off the subscription, want 5", got)
+	}
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Fatalf("Run returned %v", err)
+	}
+}
+
+func TestRunnerStopsOnHandlerError(t *testing.T) {
+	db := newStore(t, 4, 5)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	sub := connect(t, ctx, db)
+
+	boom := errors.New("boom")
+	handle := func(ctx context.Context, stream store.PersistentSubscription, workerID int, event *esdb.ResolvedEvent) error {
+		if event.Event.StreamID == streamID(2) {
+			return boom
+		}
+		return stream.Ack(event)
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- partition.NewRunner(2, 1).Run(ctx, sub, handle) }()
+	select {
+	case err := <-done:
+		if !errors.Is(err, boom) {
+			t.Fatalf("Run returned %v, want %v", err, boom)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run did not stop after the handler failed")
+	}
+}
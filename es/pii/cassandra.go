@@ -0,0 +1,119 @@
+package pii
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/gocql/gocql"
+	"github.com/pkg/errors"
+	"github.com/scylladb/gocqlx/v2"
+)
+
+const (
+	SubjectKeysTable = "personal_data_keys"
+	KeysTable        = "personal_data_keys_by_id"
+)
+
+// CassandraKeyStore keeps the keys in the tables created by the migrations package: one
+// row per subject, which stays behind without its key as a tombstone once shredded, and
+// one row per key id for decryption.
+type CassandraKeyStore struct {
+	session gocqlx.Session
+	now     func() time.Time
+}
+
+var _ KeyStore = (*CassandraKeyStore)(nil)
+
+func NewCassandraKeyStore(session gocqlx.Session) *CassandraKeyStore {
+	return &CassandraKeyStore{session: session, now: time.Now}
+}
+
+// SubjectKey creates a missing key with a lightweight transaction, so concurrent writers
+// of the same subject agree on one key.
+func (s *CassandraKeyStore) SubjectKey(ctx context.Context, subjectID string) (Key, error) {
+	key, err := s.subjectKey(ctx, subjectID)
+	if !errors.Is(err, gocql.ErrNotFound) {
+		return key, err
+	}
+
+	if key, err = newKey(); err != nil {
+		return Key{}, err
+	}
+	stmt := fmt.Sprintf("INSERT INTO %s (key_id, key) VALUES (?, ?)", KeysTable)
+	if err := s.session.Session.Query(stmt, key.ID, key.Material).WithContext(ctx).Exec(); err != nil {
+		return Key{}, errors.Wrap(err, "Query.Exec")
+	}
+	stmt = fmt.Sprintf("INSERT INTO %s (subject_id, key_id) VALUES (?, ?) IF NOT EXISTS", SubjectKeysTable)
+	applied, err := s.session.Session.Query(stmt, subjectID, key.ID).WithContext(ctx).MapScanCAS(map[string]interface{}{})
+	if err != nil {
+		return Key{}, errors.Wrap(err, "Query.MapScanCAS")
+	}
+	if applied {
+		return key, nil
+	}
+	// Another writer created the subject's key first, or the subject was shredded.
+	if err := s.deleteKey(ctx, key.ID); err != nil {
+		return Key{}, err
+	}
+	key, err = s.subjectKey(ctx, subjectID)
+	if err != nil {
+		return Key{}, errors.Wrap(err, "subjectKey")
+	}
+	return key, nil
+}
+
+// subjectKey returns an error wrapping gocql.ErrNotFound when the subject has no row.
+func (s *CassandraKeyStore) subjectKey(ctx context.Context, subjectID string) (Key, error) {
+	var keyID string
+	var shreddedAt time.Time
+	stmt := fmt.Sprintf("SELECT key_id, shredded_at FROM %s WHERE subject_id = ?", SubjectKeysTable)
+	if err := s.session.Session.Query(stmt, subjectID).WithContext(ctx).Scan(&keyID, &shreddedAt); err != nil {
+		return Key{}, errors.Wrap(err, "Query.Scan")
+	}
+	if !shreddedAt.IsZero() {
+		return Key{}, ErrKeyShredded
+	}
+	return s.Key(ctx, keyID)
+}
+
+func (s *CassandraKeyStore) Key(ctx context.Context, keyID string) (Key, error) {
+	var material []byte
+	stmt := fmt.Sprintf("SELECT key FROM %s WHERE key_id = ?", KeysTable)
+	err := s.session.Session.Query(stmt, keyID).WithContext(ctx).Scan(&material)
+	if errors.Is(err, gocql.ErrNotFound) {
+		return Key{}, ErrKeyNotFound
+	}
+	if err != nil {
+		return Key{}, errors.Wrap(err, "Query.Scan")
+	}
+	return Key{ID: keyID, Material: material}, nil
+}
+
+// Shred marks the subject as shredded before deleting its key: should the delete fail, no
+// new key is handed out and Shred can be called again.
+func (s *CassandraKeyStore) Shred(ctx context.Context, subjectID string) error {
+	var keyID string
+	stmt := fmt.Sprintf("SELECT key_id FROM %s WHERE subject_id = ?", SubjectKeysTable)
+	err := s.session.Session.Query(stmt, subjectID).WithContext(ctx).Scan(&keyID)
+	if err != nil && !errors.Is(err, gocql.ErrNotFound) {
+		return errors.Wrap(err, "Query.Scan")
+	}
+
+	stmt = fmt.Sprintf("UPDATE %s SET shredded_at = ? WHERE subject_id = ?", SubjectKeysTable)
+	if err := s.session.Session.Query(stmt, s.now().UTC(), subjectID).WithContext(ctx).Exec(); err != nil {
+		return errors.Wrap(err, "Query.Exec")
+	}
+	if keyID == "" {
+		return nil
+	}
+	return s.deleteKey(ctx, keyID)
+}
+
+func (s *CassandraKeyStore) deleteKey(ctx context.Context, keyID string) error {
+	stmt := fmt.Sprintf("DELETE FROM %s WHERE key_id = ?", KeysTable)
+	if err := s.session.Session.Query(stmt, keyID).WithContext(ctx).Exec(); err != nil {
+		return errors.Wrap(err, "Query.Exec")
+	}
+	return nil
+}
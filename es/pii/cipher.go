@@ -0,0 +1,245 @@
+// Package pii crypto-shreds the personal data in wallet events. Personal data fields are
+// encrypted with a key per user, held in a KeyStore; erasing a user shreds the key, after
+// which the fields read as Redacted while everything else, balances included, replays
+// as before.
+package pii
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+
+	es "github.com/novabankapp/common.data/eventstore"
+	v1 "github.com/novabankapp/wallet.data/es/events/v1"
+	"github.com/pkg/errors"
+)
+
+// Redacted replaces personal data whose key was shredded.
+const Redacted = "[redacted]"
+
+// envelopePrefix starts an encrypted value: "pii:v1:<key id>:<base64 nonce and ciphertext>".
+const envelopePrefix = "pii:v1:"
+
+var ErrInvalidEnvelope = errors.New("invalid encrypted personal data")
+
+// Cipher encrypts and decrypts the personal data fields of events.
+type Cipher struct {
+	Keys KeyStore
+	// Fields lists the personal data fields per event type; v1.PersonalDataFields by default.
+	Fields map[string][]string
+}
+
+func NewCipher(keys KeyStore) *Cipher {
+	return &Cipher{Keys: keys, Fields: v1.PersonalDataFields}
+}
+
+// IsEncrypted reports whether value was encrypted by Protect.
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, envelopePrefix)
+}
+
+// Protect returns evt with its personal data fields encrypted with the key of subject.
+// Once the subject is erased, or when the subject itself reads as Redacted, the fields
+// are written as Redacted instead. Events without personal data fields or without a
+// subject are returned as they are.
+func (c *Cipher) Protect(ctx context.Context, subjectID string, evt es.Event) (es.Event, error) {
+	fields := c.Fields[evt.GetEventType()]
+	if len(fields) == 0 || subjectID == "" {
+		return evt, nil
+	}
+	if subjectID == Redacted {
+		return redact(evt, fields)
+	}
+	key, err := c.Keys.SubjectKey(ctx, subjectID)
+	if errors.Is(err, ErrKeyShredded) {
+		return redact(evt, fields)
+	}
+	if err != nil {
+		return es.Event{}, errors.Wrap(err, "SubjectKey")
+	}
+	return rewrite(evt, fields, func(value string) (string, error) {
+		if IsEncrypted(value) || value == Redacted {
+			return value, nil
+		}
+		return seal(key, value)
+	})
+}
+
+// Reveal returns evt with its personal data fields decrypted, or Redacted where the key
+// was shredded. Fields written before encryption was enabled are returned as they are.
+func (c *Cipher) Reveal(ctx context.Context, evt es.Event) (es.Event, error) {
+	fields := c.Fields[evt.GetEventType()]
+	if len(fields) == 0 {
+		return evt, nil
+	}
+	keys := make(map[string]*Key)
+	return rewrite(evt, fields, func(value string) (string, error) {
+		if !IsEncrypted(value) {
+			return value, nil
+		}
+		keyID, sealed, err := parseEnvelope(value)
+		if err != nil {
+			return "", err
+		}
+		key, ok := keys[keyID]
+		if !ok {
+			found, err := c.Keys.Key(ctx, keyID)
+			switch {
+			case errors.Is(err, ErrKeyNotFound):
+				key = nil
+			case err != nil:
+				return "", errors.Wrap(err, "Key")
+			default:
+				key = &found
+			}
+			keys[keyID] = key
+		}
+		if key == nil {
+			return Redacted, nil
+		}
+		return open(*key, sealed)
+	})
+}
+
+// Encrypt encrypts a single value with the key of subject, for personal data kept outside
+// events, such as the value of a domain.WalletLink. It returns Redacted once the subject
+// is erased.
+func (c *Cipher) Encrypt(ctx context.Context, subjectID, value string) (string, error) {
+	if value == "" || IsEncrypted(value) {
+		return value, nil
+	}
+	if subjectID == "" {
+		return "", errors.New("personal data without a subject")
+	}
+	if subjectID == Redacted {
+		return Redacted, nil
+	}
+	key, err := c.Keys.SubjectKey(ctx, subjectID)
+	if errors.Is(err, ErrKeyShredded) {
+		return Redacted, nil
+	}
+	if err != nil {
+		return "", errors.Wrap(err, "SubjectKey")
+	}
+	return seal(key, value)
+}
+
+// Decrypt returns the plain value of a value written by Encrypt or Protect, or Redacted
+// when its key was shredded. Values that are not encrypted are returned as they are.
+func (c *Cipher) Decrypt(ctx context.Context, value string) (string, error) {
+	if !IsEncrypted(value) {
+		return value, nil
+	}
+	keyID, sealed, err := parseEnvelope(value)
+	if err != nil {
+		return "", err
+	}
+	key, err := c.Keys.Key(ctx, keyID)
+	if errors.Is(err, ErrKeyNotFound) {
+		return Redacted, nil
+	}
+	if err != nil {
+		return "", errors.Wrap(err, "Key")
+	}
+	return open(key, sealed)
+}
+
+func redact(evt es.Event, fields []string) (es.Event, error) {
+	return rewrite(evt, fields, func(string) (string, error) { return Redacted, nil })
+}
+
+// rewrite replaces the string values of the given top-level fields of the event's JSON
+// payload. Missing, empty and non-string fields are left alone.
+func rewrite(evt es.Event, fields []string, replace func(value string) (string, error)) (es.Event, error) {
+	var payload map[string]json.RawMessage
+	if err := json.Unmarshal(evt.GetData(), &payload); err != nil {
+		return es.Event{}, errors.Wrap(err, "json.Unmarshal")
+	}
+	changed := false
+	for _, field := range fields {
+		raw, ok := payload[field]
+		if !ok {
+			continue
+		}
+		var value string
+		if err := json.Unmarshal(raw, &value); err != nil || value == "" {
+			continue
+		}
+		replaced, err := replace(value)
+		if err != nil {
+			return es.Event{}, errors.Wrapf(err, "%s.%s", evt.GetEventType(), field)
+		}
+		if replaced == value {
+			continue
+		}
+		if payload[field], err = json.Marshal(replaced); err != nil {
+			return es.Event{}, errors.Wrap(err, "json.Marshal")
+		}
+		changed = true
+	}
+	if !changed {
+		return evt, nil
+	}
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return es.Event{}, errors.Wrap(err, "json.Marshal")
+	}
+	evt.SetData(data)
+	return evt, nil
+}
+
+func seal(key Key, plaintext string) (string, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", errors.Wrap(err, "rand.Read")
+	}
+	sealed := aead.Seal(nonce, nonce, []byte(plaintext), []byte(key.ID))
+	return envelopePrefix + key.ID + ":" + base64.RawStdEncoding.EncodeToString(sealed), nil
+}
+
+func open(key Key, sealed []byte) (string, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return "", err
+	}
+	if len(sealed) < aead.NonceSize() {
+		return "", ErrInvalidEnvelope
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	plaintext, err := aead.Open(nil, nonce, ciphertext, []byte(key.ID))
+	if err != nil {
+		return "", errors.Wrap(ErrInvalidEnvelope, err.Error())
+	}
+	return string(plaintext), nil
+}
+
+func parseEnvelope(value string) (keyID string, sealed []byte, err error) {
+	keyID, encoded, ok := strings.Cut(strings.TrimPrefix(value, envelopePrefix), ":")
+	if !ok || keyID == "" {
+		return "", nil, ErrInvalidEnvelope
+	}
+	if sealed, err = base64.RawStdEncoding.DecodeString(encoded); err != nil {
+		return "", nil, errors.Wrap(ErrInvalidEnvelope, err.Error())
+	}
+	return keyID, sealed, nil
+}
+
+func newAEAD(key Key) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key.Material)
+	if err != nil {
+		return nil, errors.Wrap(err, "aes.NewCipher")
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, errors.Wrap(err, "cipher.NewGCM")
+	}
+	return aead, nil
+}
@@ -0,0 +1,101 @@
+package pii
+
+import (
+	"context"
+	"crypto/rand"
+	"sync"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+)
+
+const keySize = 32
+
+var (
+	ErrKeyNotFound = errors.New("personal data key not found")
+	// ErrKeyShredded is returned for a subject whose personal data was erased.
+	ErrKeyShredded = errors.New("personal data key shredded")
+)
+
+// Key encrypts the personal data of one subject. Its ID, not the subject, is written with
+// the encrypted values, so that nothing in the events identifies the subject once the
+// key is gone.
+type Key struct {
+	ID       string
+	Material []byte
+}
+
+func newKey() (Key, error) {
+	material := make([]byte, keySize)
+	if _, err := rand.Read(material); err != nil {
+		return Key{}, errors.Wrap(err, "rand.Read")
+	}
+	return Key{ID: uuid.New().String(), Material: material}, nil
+}
+
+// KeyStore holds one key per subject, usually a user.
+type KeyStore interface {
+	// SubjectKey returns the key of subject, creating one on first use. It returns
+	// ErrKeyShredded once the subject's key was shredded; no new key is created then.
+	SubjectKey(ctx context.Context, subjectID string) (Key, error)
+	// Key returns ErrKeyNotFound when there is no key with the id, also after it was shredded.
+	Key(ctx context.Context, keyID string) (Key, error)
+	// Shred destroys the key of subject, which makes its personal data unreadable. Shredding
+	// a subject without a key keeps one from being created.
+	Shred(ctx context.Context, subjectID string) error
+}
+
+// MemoryKeyStore is an in-memory KeyStore for tests and local runs.
+type MemoryKeyStore struct {
+	mu       sync.Mutex
+	subjects map[string]string
+	shredded map[string]bool
+	keys     map[string][]byte
+}
+
+func NewMemoryKeyStore() *MemoryKeyStore {
+	return &MemoryKeyStore{
+		subjects: make(map[string]string),
+		shredded: make(map[string]bool),
+		keys:     make(map[string][]byte),
+	}
+}
+
+func (m *MemoryKeyStore) SubjectKey(ctx context.Context, subjectID string) (Key, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.shredded[subjectID] {
+		return Key{}, ErrKeyShredded
+	}
+	if id, ok := m.subjects[subjectID]; ok {
+		return Key{ID: id, Material: m.keys[id]}, nil
+	}
+	key, err := newKey()
+	if err != nil {
+		return Key{}, err
+	}
+	m.subjects[subjectID] = key.ID
+	m.keys[key.ID] = key.Material
+	return key, nil
+}
+
+func (m *MemoryKeyStore) Key(ctx context.Context, keyID string) (Key, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	material, ok := m.keys[keyID]
+	if !ok {
+		return Key{}, ErrKeyNotFound
+	}
+	return Key{ID: keyID, Material: material}, nil
+}
+
+func (m *MemoryKeyStore) Shred(ctx context.Context, subjectID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if id, ok := m.subjects[subjectID]; ok {
+		delete(m.keys, id)
+		delete(m.subjects, subjectID)
+	}
+	m.shredded[subjectID] = true
+	return nil
+}
@@ -0,0 +1,51 @@
+package pii
+
+import (
+	"context"
+
+	"github.com/novabankapp/wallet.data/domain"
+	"github.com/pkg/errors"
+)
+
+// LinkStore writes and reads the links of wallets, such as readmodel.WalletLinkRepository.
+type LinkStore interface {
+	Create(ctx context.Context, link domain.WalletLink) error
+	GetByWallet(ctx context.Context, walletID string) ([]domain.WalletLink, error)
+}
+
+// ProtectLinks returns a link store that encrypts link values with the key of the
+// wallet's owner before they are written and decrypts them when they are read. Once the
+// owner is erased the values read as Redacted.
+func (c *Cipher) ProtectLinks(links LinkStore) *ProtectedLinks {
+	return &ProtectedLinks{links: links, cipher: c}
+}
+
+// ProtectedLinks reads and writes links whose values are encrypted at rest.
+type ProtectedLinks struct {
+	links  LinkStore
+	cipher *Cipher
+}
+
+// Create encrypts the link value with the key of ownerID and writes the link.
+func (l *ProtectedLinks) Create(ctx context.Context, ownerID string, link domain.WalletLink) error {
+	value, err := l.cipher.Encrypt(ctx, ownerID, link.Value)
+	if err != nil {
+		return errors.Wrap(err, "Encrypt")
+	}
+	link.Value = value
+	return l.links.Create(ctx, link)
+}
+
+// GetByWallet returns the links of a wallet with their values decrypted.
+func (l *ProtectedLinks) GetByWallet(ctx context.Context, walletID string) ([]domain.WalletLink, error) {
+	links, err := l.links.GetByWallet(ctx, walletID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range links {
+		if links[i].Value, err = l.cipher.Decrypt(ctx, links[i].Value); err != nil {
+			return nil, errors.Wrap(err, "Decrypt")
+		}
+	}
+	return links, nil
+}
@@ -0,0 +1,430 @@
+package pii_test
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/gocql/gocql"
+	es "github.com/novabankapp/common.data/eventstore"
+	"github.com/novabankapp/wallet.data/aml"
+	"github.com/novabankapp/wallet.data/audit"
+	"github.com/novabankapp/wallet.data/domain"
+	"github.com/novabankapp/wallet.data/es/aggregate"
+	"github.com/novabankapp/wallet.data/es/commands"
+	v1 "github.com/novabankapp/wallet.data/es/events/v1"
+	"github.com/novabankapp/wallet.data/es/metadata"
+	"github.com/novabankapp/wallet.data/es/pii"
+	"github.com/novabankapp/wallet.data/es/readmodel/readmodeltest"
+	"github.com/novabankapp/wallet.data/es/store"
+	"github.com/novabankapp/wallet.data/fraud"
+	"github.com/novabankapp/wallet.data/webhooks"
+	"github.com/pkg/errors"
+	"github.com/shopspring/decimal"
+)
+
+const walletID = "w-1"
+
+type fixture struct {
+	db     *store.MemoryEventStore
+	keys   *pii.MemoryKeyStore
+	cipher *pii.Cipher
+	bus    *commands.Bus
+}
+
+func newFixture(t *testing.T) *fixture {
+	t.Helper()
+	db := store.NewMemoryEventStore()
+	keys := pii.NewMemoryKeyStore()
+	cipher := pii.NewCipher(keys)
+	bus := commands.NewBus()
+	commands.RegisterWalletHandlers(bus, aggregate.NewWalletCommandExecutor(cipher.ProtectStore(db)))
+	commands.RegisterErasureHandler(bus, keys, nil)
+	return &fixture{db: db, keys: keys, cipher: cipher, bus: bus}
+}
+
+func (f *fixture) dispatch(t *testing.T, cmd commands.Command) {
+	t.Helper()
+	if _, err := f.bus.Dispatch(context.Background(), cmd); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func (f *fixture) events(t *testing.T, reader store.EventReader) []map[string]interface{} {
+	t.Helper()
+	events, err := reader.ReadEvents(context.Background(), aggregate.GetWalletStreamID(walletID), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	payloads := make([]map[string]interface{}, 0, len(events))
+	for _, evt := range events {
+		var payload map[string]interface{}
+		if err := json.Unmarshal(evt.GetData(), &payload); err != nil {
+			t.Fatal(err)
+		}
+		payloads = append(payloads, payload)
+	}
+	return payloads
+}
+
+func amount(value string) decimal.Decimal {
+	return decimal.RequireFromString(value)
+}
+
+func TestPersonalDataIsEncryptedAtRest(t *testing.T) {
+	f := newFixture(t)
+	f.dispatch(t, commands.CreateWalletCommand{ID: walletID, Amount: amount("100"), Description: "opening", UserID: "user-1", AccountID: "account-1"})
+	f.dispatch(t, commands.DebitWalletCommand{ID: walletID, CreditWalletID: "w-2", Amount: amount("30"), Description: "rent"})
+
+	stored := f.events(t, f.db)
+	for _, field := range []string{"UserId", "AccountId", "Description"} {
+		value, _ := stored[0][field].(string)
+		if !pii.IsEncrypted(value) || strings.Contains(value, "user-1") {
+			t.Fatalf("WalletCreated.%s stored as %q", field, value)
+		}
+	}
+	if value, _ := stored[1]["Description"].(string); !pii.IsEncrypted(value) {
+		t.Fatalf("WalletDebited.Description stored as %q", value)
+	}
+
+	revealed := f.events(t, f.cipher.RevealReader(f.db))
+	if revealed[0]["UserId"] != "user-1" || revealed[1]["Description"] != "rent" {
+		t.Fatalf("revealed %v", revealed)
+	}
+
+	wallet, err := aggregate.LoadWalletAggregate(context.Background(), f.cipher.ProtectStore(f.db), walletID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if wallet.Wallet.UserId != "user-1" || !wallet.Wallet.Balance.Equal(amount("70")) {
+		t.Fatalf("loaded user %q, balance %s", wallet.Wallet.UserId, wallet.Wallet.Balance)
+	}
+}
+
+func TestEraseUserDataShredsTheKey(t *testing.T) {
+	f := newFixture(t)
+	ctx := context.Background()
+	f.dispatch(t, commands.CreateWalletCommand{ID: walletID, Amount: amount("100"), Description: "opening", UserID: "user-1", AccountID: "account-1"})
+	f.dispatch(t, commands.EraseUserDataCommand{UserID: "user-1", Description: "erasure request"})
+
+	if _, err := f.keys.SubjectKey(ctx, "user-1"); !errors.Is(err, pii.ErrKeyShredded) {
+		t.Fatalf("got %v, want %v", err, pii.ErrKeyShredded)
+	}
+
+	wallet, err := aggregate.LoadWalletAggregate(ctx, f.cipher.ProtectStore(f.db), walletID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if wallet.Wallet.UserId != pii.Redacted || !wallet.Wallet.Balance.Equal(amount("100")) {
+		t.Fatalf("loaded user %q, balance %s", wallet.Wallet.UserId, wallet.Wallet.Balance)
+	}
+
+	// The wallet keeps working, but whatever it writes from now on is redacted.
+	f.dispatch(t, commands.CreditWalletCommand{ID: walletID, DebitWalletID: "w-2", Amount: amount("5"), Description: "refund"})
+	stored := f.events(t, f.db)
+	if stored[1]["Description"] != pii.Redacted {
+		t.Fatalf("WalletCredited.Description stored as %v", stored[1]["Description"])
+	}
+	for _, payload := range f.events(t, f.cipher.RevealReader(f.db)) {
+		if payload["Description"] != pii.Redacted {
+			t.Fatalf("revealed %v", payload)
+		}
+	}
+}
+
+func TestPlainEventsAreRevealedAsTheyAre(t *testing.T) {
+	cipher := pii.NewCipher(pii.NewMemoryKeyStore())
+	data, err := json.Marshal(map[string]string{"UserId": "user-1", "Description": "opening"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	evt := es.Event{EventID: "e-1", EventType: v1.WalletCreated, Data: data}
+
+	revealed, err := cipher.Reveal(context.Background(), evt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(revealed.GetData()) != string(data) {
+		t.Fatalf("revealed %s, want %s", revealed.GetData(), data)
+	}
+}
+
+func TestEncryptOutsideEvents(t *testing.T) {
+	keys := pii.NewMemoryKeyStore()
+	cipher := pii.NewCipher(keys)
+	ctx := context.Background()
+
+	encrypted, err := cipher.Encrypt(ctx, "user-1", "+265 999 000 111")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !pii.IsEncrypted(encrypted) {
+		t.Fatalf("not encrypted: %q", encrypted)
+	}
+	if plain, err := cipher.Decrypt(ctx, encrypted); err != nil || plain != "+265 999 000 111" {
+		t.Fatalf("decrypted %q, %v", plain, err)
+	}
+
+	if err := keys.Shred(ctx, "user-1"); err != nil {
+		t.Fatal(err)
+	}
+	if plain, err := cipher.Decrypt(ctx, encrypted); err != nil || plain != pii.Redacted {
+		t.Fatalf("decrypted %q, %v after erasure", plain, err)
+	}
+	if _, err := cipher.Decrypt(ctx, "pii:v1:key:not base64!"); !errors.Is(err, pii.ErrInvalidEnvelope) {
+		t.Fatalf("got %v, want %v", err, pii.ErrInvalidEnvelope)
+	}
+}
+
+func TestEraseUserDataLeavesNoPlaintext(t *testing.T) {
+	keys := pii.NewMemoryKeyStore()
+	cipher := pii.NewCipher(keys)
+	db := store.NewMemoryEventStore()
+	rows := readmodeltest.New()
+	trail := audit.NewMemoryStore()
+	cases := aml.NewMemoryCaseStore()
+	flags := fraud.NewMemoryReviewStore()
+	hooks := webhooks.NewMemoryStore()
+	bus := commands.NewBus()
+	commands.RegisterWalletHandlers(bus, aggregate.NewWalletCommandExecutor(cipher.ProtectStore(db)))
+	commands.RegisterErasureHandler(bus, keys, rows, trail, cases, flags, hooks)
+
+	ctx := metadata.WithMetadata(context.Background(), metadata.Metadata{ActorID: "user-1", ActorType: metadata.ActorCustomer, ClientIP: "10.0.0.7"})
+	for _, cmd := range []commands.Command{
+		commands.CreateWalletCommand{ID: walletID, Amount: amount("100"), Description: "salary from acme", UserID: "user-1", AccountID: "account-1"},
+		commands.DebitWalletCommand{ID: walletID, CreditWalletID: "w-2", Amount: amount("30"), Description: "rent for flat 4"},
+		commands.LockWalletCommand{ID: walletID, Description: "phone stolen", ReasonCode: domain.ReasonLostOrStolen},
+	} {
+		if _, err := bus.Dispatch(ctx, cmd); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Fill every copy from the stored events the way their consumers do.
+	for _, subscription := range []webhooks.Subscription{
+		{ID: "sub-owner", OwnerID: "user-1", URL: "https://example.com/hooks", Secret: "whsec"},
+		{ID: "sub-wallet", WalletID: walletID, URL: "https://example.com/hooks", Secret: "whsec"},
+	} {
+		if err := hooks.SaveSubscription(ctx, subscription); err != nil {
+			t.Fatal(err)
+		}
+	}
+	projection := &aggregate.WalletProjection{Repo: rows, PersonalData: cipher}
+	dispatcher := webhooks.NewDispatcher(hooks, nil, "webhooks", nil)
+	dispatcher.PersonalData = cipher
+	recorder := audit.NewProjection(trail, "audit", nil)
+	events, err := db.ReadEvents(ctx, aggregate.GetWalletStreamID(walletID), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, evt := range events {
+		if err := projection.When(ctx, evt); err != nil {
+			t.Fatal(err)
+		}
+		if err := dispatcher.Dispatch(ctx, evt); err != nil {
+			t.Fatal(err)
+		}
+		if err := recorder.Record(ctx, evt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var evidence []domain.WalletTransaction
+	if err := json.Unmarshal([]byte(rows.Rows()[0].WalletTransactions), &evidence); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cases.SaveAlert(ctx, aml.Alert{ID: "a-1", WalletID: walletID, UserID: "user-1", Evidence: evidence}); err != nil {
+		t.Fatal(err)
+	}
+	if err := cases.SaveCase(ctx, aml.Case{ID: "c-1", WalletID: walletID, UserID: "user-1", Status: aml.CaseOpen, AlertIDs: []string{"a-1"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := flags.SaveFlag(ctx, fraud.Flag{ID: "f-1", WalletID: walletID, Detail: "rent for flat 4 to w-2"}); err != nil {
+		t.Fatal(err)
+	}
+
+	plaintext := []string{"user-1", "account-1", "salary from acme", "rent for flat 4", "phone stolen", "10.0.0.7"}
+	copies := func() map[string]interface{} {
+		entries, err := trail.ListByActor(ctx, pii.Redacted, audit.Filter{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		byUser, err := trail.ListByActor(ctx, "user-1", audit.Filter{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		alert, err := cases.GetAlert(ctx, "a-1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		amlCase, err := cases.GetCase(ctx, "c-1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		flagged, err := flags.ListFlags(ctx, walletID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var payloads []string
+		for _, subscriptionID := range []string{"sub-owner", "sub-wallet"} {
+			deliveries, err := hooks.ListDeliveries(ctx, subscriptionID, webhooks.DeliveryFilter{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, delivery := range deliveries {
+				payloads = append(payloads, string(delivery.Payload))
+			}
+		}
+		subscription, err := hooks.GetSubscription(ctx, "sub-owner")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return map[string]interface{}{
+			"read model":    rows.Rows(),
+			"audit":         append(entries, byUser...),
+			"aml alert":     alert,
+			"aml case":      amlCase,
+			"fraud":         flagged,
+			"webhooks":      payloads,
+			"subscriptions": subscription,
+		}
+	}
+	found := func(held interface{}) []string {
+		data, err := json.Marshal(held)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var result []string
+		for _, value := range plaintext {
+			if strings.Contains(string(data), value) {
+				result = append(result, value)
+			}
+		}
+		return result
+	}
+
+	for name, held := range copies() {
+		if len(found(held)) == 0 {
+			t.Fatalf("%s holds no personal data before the erasure: %+v", name, held)
+		}
+	}
+
+	if _, err := bus.Dispatch(ctx, commands.EraseUserDataCommand{UserID: "user-1", Description: "erasure request"}); err != nil {
+		t.Fatal(err)
+	}
+	for name, held := range copies() {
+		if values := found(held); len(values) > 0 {
+			t.Errorf("%s still holds %v: %+v", name, values, held)
+		}
+	}
+	for _, payload := range eventPayloads(t, cipher.RevealReader(db)) {
+		if values := found(payload); len(values) > 0 {
+			t.Errorf("event %v still holds %v", payload, values)
+		}
+	}
+
+	row := rows.Rows()[0]
+	var wallet struct{ Balance decimal.Decimal }
+	if err := json.Unmarshal([]byte(row.Wallet), &wallet); err != nil || !wallet.Balance.Equal(amount("70")) {
+		t.Errorf("read model wallet %s, %v", row.Wallet, err)
+	}
+}
+
+func eventPayloads(t *testing.T, reader store.EventReader) []string {
+	t.Helper()
+	events, err := reader.ReadEvents(context.Background(), aggregate.GetWalletStreamID(walletID), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	payloads := make([]string, 0, len(events))
+	for _, evt := range events {
+		payloads = append(payloads, string(evt.GetData()))
+	}
+	return payloads
+}
+
+// linkStore keeps links in memory as they would be written to wallet_links.
+type linkStore struct {
+	links []domain.WalletLink
+}
+
+func (s *linkStore) Create(ctx context.Context, link domain.WalletLink) error {
+	s.links = append(s.links, link)
+	return nil
+}
+
+func (s *linkStore) GetByWallet(ctx context.Context, walletID string) ([]domain.WalletLink, error) {
+	links := make([]domain.WalletLink, 0)
+	for _, link := range s.links {
+		if link.WalletId == walletID {
+			links = append(links, link)
+		}
+	}
+	return links, nil
+}
+
+func TestLinksAreEncryptedAtRest(t *testing.T) {
+	keys := pii.NewMemoryKeyStore()
+	cipher := pii.NewCipher(keys)
+	stored := &linkStore{}
+	links := cipher.ProtectLinks(stored)
+	ctx := context.Background()
+
+	link := domain.WalletLink{WalletId: walletID, ID: gocql.TimeUUID(), Value: "jane@example.com"}
+	if err := links.Create(ctx, "user-1", link); err != nil {
+		t.Fatal(err)
+	}
+	if len(stored.links) != 1 || !pii.IsEncrypted(stored.links[0].Value) {
+		t.Fatalf("stored links %+v, want an encrypted value", stored.links)
+	}
+	read, err := links.GetByWallet(ctx, walletID)
+	if err != nil || len(read) != 1 || read[0].Value != "jane@example.com" || read[0].ID != link.ID {
+		t.Fatalf("read %+v, %v", read, err)
+	}
+
+	if err := keys.Shred(ctx, "user-1"); err != nil {
+		t.Fatal(err)
+	}
+	if read, err = links.GetByWallet(ctx, walletID); err != nil || len(read) != 1 || read[0].Value != pii.Redacted {
+		t.Fatalf("read %+v, %v after erasure", read, err)
+	}
+	if err := links.Create(ctx, "user-1", link); err != nil || stored.links[1].Value != pii.Redacted {
+		t.Fatalf("wrote %+v, %v after erasure", stored.links, err)
+	}
+}
+
+func TestSnapshotsAreEncryptedAtRest(t *testing.T) {
+	keys := pii.NewMemoryKeyStore()
+	cipher := pii.NewCipher(keys)
+	db := store.NewMemoryEventStore()
+	snapshots := cipher.ProtectSnapshots(db)
+	ctx := context.Background()
+
+	state := []byte(`{"wallet":{"user_id":"user-1"}}`)
+	if err := snapshots.SaveSnapshot(ctx, store.Snapshot{StreamID: "wallet-w-1", Version: 2, State: state, Subject: "user-1"}); err != nil {
+		t.Fatal(err)
+	}
+
+	stored, err := db.LoadSnapshot(ctx, "wallet-w-1", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !pii.IsEncrypted(string(stored.State)) || strings.Contains(string(stored.State), "user-1") {
+		t.Fatalf("snapshot stored as %s", stored.State)
+	}
+	loaded, err := snapshots.LoadSnapshot(ctx, "wallet-w-1", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded == nil || string(loaded.State) != string(state) || loaded.Version != 2 {
+		t.Fatalf("loaded %+v", loaded)
+	}
+
+	// Once the owner is erased the snapshot is useless and the stream is replayed instead.
+	if err := keys.Shred(ctx, "user-1"); err != nil {
+		t.Fatal(err)
+	}
+	if loaded, err := snapshots.LoadSnapshot(ctx, "wallet-w-1", nil); err != nil || loaded != nil {
+		t.Fatalf("loaded %+v, %v after erasure", loaded, err)
+	}
+}
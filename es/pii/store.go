@@ -0,0 +1,142 @@
+package pii
+
+import (
+	"context"
+
+	es "github.com/novabankapp/common.data/eventstore"
+	"github.com/novabankapp/wallet.data/es/store"
+	"github.com/pkg/errors"
+)
+
+// Subject is implemented by aggregates whose events carry the personal data of one
+// subject, e.g. the owner of a wallet.
+type Subject interface {
+	PersonalDataSubject() string
+}
+
+// ProtectStore returns an aggregate store that encrypts the personal data of the events
+// it saves and decrypts the events it loads, so aggregates only ever see plain values.
+// Aggregates that do not implement Subject are saved as they are.
+func (c *Cipher) ProtectStore(s es.AggregateStore) es.AggregateStore {
+	return &protectingStore{AggregateStore: s, cipher: c}
+}
+
+type protectingStore struct {
+	es.AggregateStore
+	cipher *Cipher
+}
+
+func (s *protectingStore) Load(ctx context.Context, a es.Aggregate) error {
+	return s.AggregateStore.Load(ctx, &revealingAggregate{Aggregate: a, ctx: ctx, cipher: s.cipher})
+}
+
+func (s *protectingStore) Save(ctx context.Context, a es.Aggregate) error {
+	subject, ok := a.(Subject)
+	if !ok {
+		return s.AggregateStore.Save(ctx, a)
+	}
+	events := a.GetUncommittedEvents()
+	protected := make([]es.Event, 0, len(events))
+	for _, evt := range events {
+		evt, err := s.cipher.Protect(ctx, subject.PersonalDataSubject(), evt)
+		if err != nil {
+			return errors.Wrap(err, "Protect")
+		}
+		protected = append(protected, evt)
+	}
+	return s.AggregateStore.Save(ctx, &protectedAggregate{Aggregate: a, events: protected})
+}
+
+// revealingAggregate decrypts the events the store replays into the aggregate.
+type revealingAggregate struct {
+	es.Aggregate
+	ctx    context.Context
+	cipher *Cipher
+}
+
+func (a *revealingAggregate) RaiseEvent(evt es.Event) error {
+	revealed, err := a.cipher.Reveal(a.ctx, evt)
+	if err != nil {
+		return errors.Wrap(err, "Reveal")
+	}
+	return a.Aggregate.RaiseEvent(revealed)
+}
+
+// protectedAggregate hands the encrypted events to the store while the aggregate keeps
+// its plain ones.
+type protectedAggregate struct {
+	es.Aggregate
+	events []es.Event
+}
+
+func (a *protectedAggregate) GetUncommittedEvents() []es.Event {
+	return a.events
+}
+
+// RevealReader returns an event reader that decrypts the events of r, for code that
+// replays events itself, such as projection rebuilds and as-of queries.
+func (c *Cipher) RevealReader(r store.EventReader) store.EventReader {
+	return &revealingReader{reader: r, cipher: c}
+}
+
+type revealingReader struct {
+	reader store.EventReader
+	cipher *Cipher
+}
+
+func (r *revealingReader) ReadEvents(ctx context.Context, streamID string, fromVersion int64) ([]es.Event, error) {
+	events, err := r.reader.ReadEvents(ctx, streamID, fromVersion)
+	if err != nil {
+		return nil, err
+	}
+	for i, evt := range events {
+		if events[i], err = r.cipher.Reveal(ctx, evt); err != nil {
+			return nil, errors.Wrap(err, "Reveal")
+		}
+	}
+	return events, nil
+}
+
+// ProtectSnapshots returns a snapshot store that encrypts the state of the snapshots it
+// saves with the key of their Subject and decrypts the snapshots it loads. Snapshots of an
+// erased subject are neither saved nor loaded, so the aggregate is replayed from its
+// redacted events instead. Snapshots without a subject are kept as they are.
+func (c *Cipher) ProtectSnapshots(s store.SnapshotStore) store.SnapshotStore {
+	return &protectingSnapshotStore{snapshots: s, cipher: c}
+}
+
+type protectingSnapshotStore struct {
+	snapshots store.SnapshotStore
+	cipher    *Cipher
+}
+
+func (s *protectingSnapshotStore) SaveSnapshot(ctx context.Context, snapshot store.Snapshot) error {
+	if snapshot.Subject == "" {
+		return s.snapshots.SaveSnapshot(ctx, snapshot)
+	}
+	state, err := s.cipher.Encrypt(ctx, snapshot.Subject, string(snapshot.State))
+	if err != nil {
+		return errors.Wrap(err, "Encrypt")
+	}
+	if state == Redacted {
+		return nil
+	}
+	snapshot.State = []byte(state)
+	return s.snapshots.SaveSnapshot(ctx, snapshot)
+}
+
+func (s *protectingSnapshotStore) LoadSnapshot(ctx context.Context, streamID string, accept func(store.Snapshot) bool) (*store.Snapshot, error) {
+	snapshot, err := s.snapshots.LoadSnapshot(ctx, streamID, accept)
+	if err != nil || snapshot == nil || !IsEncrypted(string(snapshot.State)) {
+		return snapshot, err
+	}
+	state, err := s.cipher.Decrypt(ctx, string(snapshot.State))
+	if err != nil {
+		return nil, errors.Wrap(err, "Decrypt")
+	}
+	if state == Redacted {
+		return nil, nil
+	}
+	snapshot.State = []byte(state)
+	return snapshot, nil
+}
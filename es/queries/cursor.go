@@ -0,0 +1,59 @@
+package queries
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"time"
+
+	"github.com/novabankapp/wallet.data/domain"
+	"github.com/pkg/errors"
+)
+
+// cursor marks the last transaction returned on a page. Transactions are keyed by
+// (CreatedAt, ID) so a page boundary is stable even when new transactions arrive.
+type cursor struct {
+	CreatedAt time.Time `json:"t"`
+	ID        string    `json:"id"`
+}
+
+func newCursor(tx domain.WalletTransaction) cursor {
+	return cursor{CreatedAt: tx.CreatedAt, ID: tx.ID.String()}
+}
+
+func (c cursor) encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(value string) (*cursor, error) {
+	if value == "" {
+		return nil, nil
+	}
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, errors.Wrap(ErrInvalidCursor, err.Error())
+	}
+	var c cursor
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, errors.Wrap(ErrInvalidCursor, err.Error())
+	}
+	return &c, nil
+}
+
+// compare orders a transaction against the cursor position: -1 before, 0 same, 1 after.
+func (c cursor) compare(tx domain.WalletTransaction) int {
+	switch {
+	case tx.CreatedAt.Before(c.CreatedAt):
+		return -1
+	case tx.CreatedAt.After(c.CreatedAt):
+		return 1
+	}
+	id := tx.ID.String()
+	switch {
+	case id < c.ID:
+		return -1
+	case id > c.ID:
+		return 1
+	}
+	return 0
+}
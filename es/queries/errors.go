@@ -0,0 +1,9 @@
+package queries
+
+import "github.com/pkg/errors"
+
+var (
+	ErrWalletNotFound = errors.New("wallet not found")
+	ErrInvalidCursor  = errors.New("invalid pagination cursor")
+	ErrInvalidFilter  = errors.New("invalid transaction filter")
+)
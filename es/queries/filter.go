@@ -0,0 +1,94 @@
+package queries
+
+import (
+	"strings"
+	"time"
+
+	"github.com/novabankapp/wallet.data/domain"
+	"github.com/pkg/errors"
+	"github.com/shopspring/decimal"
+)
+
+type Direction string
+
+const (
+	DirectionAny      Direction = ""
+	DirectionIncoming Direction = "in"
+	DirectionOutgoing Direction = "out"
+)
+
+type SortOrder string
+
+const (
+	SortNewestFirst SortOrder = "desc"
+	SortOldestFirst SortOrder = "asc"
+)
+
+// TransactionFilter narrows a wallet's transaction history. Zero values mean "no restriction".
+// From is inclusive and To is exclusive.
+type TransactionFilter struct {
+	From                 *time.Time
+	To                   *time.Time
+	Direction            Direction
+	CounterpartyWalletId string
+	MinAmount            *decimal.Decimal
+	MaxAmount            *decimal.Decimal
+	Description          string
+}
+
+func (f TransactionFilter) Validate() error {
+	switch f.Direction {
+	case DirectionAny, DirectionIncoming, DirectionOutgoing:
+	default:
+		return errors.Wrapf(ErrInvalidFilter, "unknown direction %q", f.Direction)
+	}
+	if f.From != nil && f.To != nil && !f.From.Before(*f.To) {
+		return errors.Wrap(ErrInvalidFilter, "from must be before to")
+	}
+	if f.MinAmount != nil && f.MaxAmount != nil && f.MinAmount.GreaterThan(*f.MaxAmount) {
+		return errors.Wrap(ErrInvalidFilter, "min amount must not exceed max amount")
+	}
+	return nil
+}
+
+// Matches reports whether the transaction, seen from walletId, satisfies the filter.
+func (f TransactionFilter) Matches(walletId string, tx domain.WalletTransaction) bool {
+	if f.From != nil && tx.CreatedAt.Before(*f.From) {
+		return false
+	}
+	if f.To != nil && !tx.CreatedAt.Before(*f.To) {
+		return false
+	}
+	incoming := tx.CreditWalletId == walletId
+	switch f.Direction {
+	case DirectionIncoming:
+		if !incoming {
+			return false
+		}
+	case DirectionOutgoing:
+		if incoming {
+			return false
+		}
+	}
+	if f.CounterpartyWalletId != "" && Counterparty(walletId, tx) != f.CounterpartyWalletId {
+		return false
+	}
+	if f.MinAmount != nil && tx.Amount.LessThan(*f.MinAmount) {
+		return false
+	}
+	if f.MaxAmount != nil && tx.Amount.GreaterThan(*f.MaxAmount) {
+		return false
+	}
+	if f.Description != "" && !strings.Contains(strings.ToLower(tx.Description), strings.ToLower(f.Description)) {
+		return false
+	}
+	return true
+}
+
+// Counterparty returns the other wallet of a transaction seen from walletId.
+func Counterparty(walletId string, tx domain.WalletTransaction) string {
+	if tx.CreditWalletId == walletId {
+		return tx.DebitWalletId
+	}
+	return tx.CreditWalletId
+}
@@ -0,0 +1,318 @@
+package queries_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/gocql/gocql"
+	"github.com/novabankapp/wallet.data/domain"
+	"github.com/novabankapp/wallet.data/es/aggregate"
+	"github.com/novabankapp/wallet.data/es/models"
+	"github.com/novabankapp/wallet.data/es/queries"
+	"github.com/novabankapp/wallet.data/es/readmodel/readmodeltest"
+	"github.com/pkg/errors"
+	"github.com/shopspring/decimal"
+)
+
+const walletID = "w-1"
+
+var start = time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+
+func amount(value string) decimal.Decimal {
+	return decimal.RequireFromString(value)
+}
+
+// put stores the row of a wallet with a balance of 100 and no state column.
+func put(repo *readmodeltest.ReadModel, walletID string, txs []domain.WalletTransaction) models.WalletProjection {
+	row := readmodeltest.Row(domain.Wallet{ID: walletID, Balance: amount("100")}, domain.WalletState{}, txs)
+	row.WalletState = ""
+	repo.Put(row)
+	return row
+}
+
+// tx is the n-th transaction of the wallet, n minutes after start. Odd ones are incoming
+// from w-2 and even ones outgoing to w-3.
+func tx(n int, value string) domain.WalletTransaction {
+	t := domain.WalletTransaction{
+		Amount:      amount(value),
+		CreatedAt:   start.Add(time.Duration(n) * time.Minute),
+		Description: fmt.Sprintf("payment %d", n),
+		ID:          id(n * 10),
+	}
+	if n%2 == 1 {
+		t.DebitWalletId, t.CreditWalletId = "w-2", walletID
+	} else {
+		t.DebitWalletId, t.CreditWalletId = walletID, "w-3"
+	}
+	return t
+}
+
+// id is a transaction id that sorts by n.
+func id(n int) gocql.UUID {
+	uuid, err := gocql.ParseUUID(fmt.Sprintf("00000000-0000-0000-0000-%012d", n))
+	if err != nil {
+		panic(err)
+	}
+	return uuid
+}
+
+// history is n transactions stored out of order, as a rebuilt read model may hold them.
+func history(n int) []domain.WalletTransaction {
+	txs := make([]domain.WalletTransaction, 0, n)
+	for i := n; i >= 1; i-- {
+		txs = append(txs, tx(i, fmt.Sprintf("%d", i*10)))
+	}
+	return txs
+}
+
+func descriptions(txs []domain.WalletTransaction) []string {
+	result := make([]string, 0, len(txs))
+	for _, t := range txs {
+		result = append(result, t.Description)
+	}
+	return result
+}
+
+func equal(got, want []string) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// pages follows the cursors from the first page to the last and returns the descriptions
+// of every page.
+func pages(t *testing.T, q *queries.WalletTransactionQueries, filter queries.TransactionFilter, page queries.TransactionPageRequest) [][]string {
+	t.Helper()
+	var result [][]string
+	for {
+		p, err := q.GetWalletTransactions(context.Background(), walletID, filter, page)
+		if err != nil {
+			t.Fatal(err)
+		}
+		result = append(result, descriptions(p.Transactions))
+		if p.NextCursor == "" {
+			return result
+		}
+		if len(result) > 10 {
+			t.Fatalf("too many pages: %v", result)
+		}
+		page.Cursor = p.NextCursor
+	}
+}
+
+func TestGetWalletTransactionsPages(t *testing.T) {
+	repo := readmodeltest.New()
+	put(repo, walletID, history(5))
+	q := queries.NewWalletTransactionQueries(repo)
+
+	cases := []struct {
+		name string
+		page queries.TransactionPageRequest
+		want [][]string
+	}{
+		{
+			name: "newest first by default",
+			page: queries.TransactionPageRequest{Limit: 2},
+			want: [][]string{{"payment 5", "payment 4"}, {"payment 3", "payment 2"}, {"payment 1"}},
+		},
+		{
+			name: "oldest first",
+			page: queries.TransactionPageRequest{Limit: 2, Order: queries.SortOldestFirst},
+			want: [][]string{{"payment 1", "payment 2"}, {"payment 3", "payment 4"}, {"payment 5"}},
+		},
+		{
+			name: "a last page that is full has no cursor",
+			page: queries.TransactionPageRequest{Limit: 5},
+			want: [][]string{{"payment 5", "payment 4", "payment 3", "payment 2", "payment 1"}},
+		},
+		{
+			name: "the default page size",
+			page: queries.TransactionPageRequest{},
+			want: [][]string{{"payment 5", "payment 4", "payment 3", "payment 2", "payment 1"}},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got := pages(t, q, queries.TransactionFilter{}, c.page)
+			if len(got) != len(c.want) {
+				t.Fatalf("pages %v, want %v", got, c.want)
+			}
+			for i := range got {
+				if !equal(got[i], c.want[i]) {
+					t.Fatalf("pages %v, want %v", got, c.want)
+				}
+			}
+		})
+	}
+}
+
+func TestGetWalletTransactionsCapsThePageSize(t *testing.T) {
+	repo := readmodeltest.New()
+	put(repo, walletID, history(queries.MaxPageSize+1))
+	q := queries.NewWalletTransactionQueries(repo)
+
+	page, err := q.GetWalletTransactions(context.Background(), walletID, queries.TransactionFilter{}, queries.TransactionPageRequest{Limit: queries.MaxPageSize * 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Transactions) != queries.MaxPageSize || page.NextCursor == "" {
+		t.Fatalf("got %d transactions, cursor %q", len(page.Transactions), page.NextCursor)
+	}
+}
+
+func TestCursorIsStableWhenTransactionsArrive(t *testing.T) {
+	repo := readmodeltest.New()
+	put(repo, walletID, history(4))
+	q := queries.NewWalletTransactionQueries(repo)
+	ctx := context.Background()
+
+	first, err := q.GetWalletTransactions(ctx, walletID, queries.TransactionFilter{}, queries.TransactionPageRequest{Limit: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := descriptions(first.Transactions); !equal(got, []string{"payment 4", "payment 3"}) {
+		t.Fatalf("first page %v", got)
+	}
+
+	// A newer transaction and one at the same instant as the cursor, ordered after it by id.
+	same := tx(3, "1")
+	same.ID = id(35)
+	same.Description = "payment 3b"
+	put(repo, walletID, append(history(6), same))
+
+	second, err := q.GetWalletTransactions(ctx, walletID, queries.TransactionFilter{}, queries.TransactionPageRequest{Limit: 2, Cursor: first.NextCursor})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := descriptions(second.Transactions); !equal(got, []string{"payment 2", "payment 1"}) || second.NextCursor != "" {
+		t.Fatalf("second page %v, cursor %q", got, second.NextCursor)
+	}
+}
+
+func TestGetWalletTransactionsRejectsInvalidCursors(t *testing.T) {
+	repo := readmodeltest.New()
+	put(repo, walletID, history(2))
+	q := queries.NewWalletTransactionQueries(repo)
+
+	for _, value := range []string{"not base64!", "bm90IGpzb24"} {
+		_, err := q.GetWalletTransactions(context.Background(), walletID, queries.TransactionFilter{}, queries.TransactionPageRequest{Cursor: value})
+		if !errors.Is(err, queries.ErrInvalidCursor) {
+			t.Fatalf("cursor %q: got %v, want %v", value, err, queries.ErrInvalidCursor)
+		}
+	}
+}
+
+func TestGetWalletTransactionsFilters(t *testing.T) {
+	repo := readmodeltest.New()
+	put(repo, walletID, history(6))
+	q := queries.NewWalletTransactionQueries(repo)
+
+	from, to := start.Add(2*time.Minute), start.Add(5*time.Minute)
+	min, max := amount("20"), amount("40")
+	cases := []struct {
+		name   string
+		filter queries.TransactionFilter
+		want   []string
+	}{
+		{"no filter", queries.TransactionFilter{}, []string{"payment 1", "payment 2", "payment 3", "payment 4", "payment 5", "payment 6"}},
+		{"from is inclusive and to exclusive", queries.TransactionFilter{From: &from, To: &to}, []string{"payment 2", "payment 3", "payment 4"}},
+		{"incoming", queries.TransactionFilter{Direction: queries.DirectionIncoming}, []string{"payment 1", "payment 3", "payment 5"}},
+		{"outgoing", queries.TransactionFilter{Direction: queries.DirectionOutgoing}, []string{"payment 2", "payment 4", "payment 6"}},
+		{"counterparty", queries.TransactionFilter{CounterpartyWalletId: "w-3"}, []string{"payment 2", "payment 4", "payment 6"}},
+		{"amount range is inclusive", queries.TransactionFilter{MinAmount: &min, MaxAmount: &max}, []string{"payment 2", "payment 3", "payment 4"}},
+		{"description ignores case", queries.TransactionFilter{Description: "PAYMENT 5"}, []string{"payment 5"}},
+		{"combined", queries.TransactionFilter{Direction: queries.DirectionIncoming, MinAmount: &min}, []string{"payment 3", "payment 5"}},
+		{"nothing matches", queries.TransactionFilter{CounterpartyWalletId: "w-9"}, []string{}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, err := q.GetAllWalletTransactions(context.Background(), walletID, c.filter)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !equal(descriptions(got), c.want) {
+				t.Fatalf("got %v, want %v", descriptions(got), c.want)
+			}
+		})
+	}
+}
+
+func TestFilterAppliesBeforePaging(t *testing.T) {
+	repo := readmodeltest.New()
+	put(repo, walletID, history(6))
+	q := queries.NewWalletTransactionQueries(repo)
+
+	got := pages(t, q, queries.TransactionFilter{Direction: queries.DirectionOutgoing}, queries.TransactionPageRequest{Limit: 2})
+	if len(got) != 2 || !equal(got[0], []string{"payment 6", "payment 4"}) || !equal(got[1], []string{"payment 2"}) {
+		t.Fatalf("pages %v", got)
+	}
+}
+
+func TestInvalidFilters(t *testing.T) {
+	repo := readmodeltest.New()
+	put(repo, walletID, history(1))
+	q := queries.NewWalletTransactionQueries(repo)
+
+	later := start.Add(time.Hour)
+	min, max := amount("50"), amount("10")
+	for name, filter := range map[string]queries.TransactionFilter{
+		"direction":    {Direction: "sideways"},
+		"empty period": {From: &later, To: &later},
+		"reversed":     {From: &later, To: &start},
+		"amount range": {MinAmount: &min, MaxAmount: &max},
+	} {
+		if _, err := q.GetWalletTransactions(context.Background(), walletID, filter, queries.TransactionPageRequest{}); !errors.Is(err, queries.ErrInvalidFilter) {
+			t.Fatalf("%s: got %v, want %v", name, err, queries.ErrInvalidFilter)
+		}
+	}
+}
+
+func TestUnknownWallets(t *testing.T) {
+	q := queries.NewWalletTransactionQueries(readmodeltest.New())
+	ctx := context.Background()
+
+	if _, err := q.GetWalletTransactions(ctx, "w-9", queries.TransactionFilter{}, queries.TransactionPageRequest{}); !errors.Is(err, queries.ErrWalletNotFound) {
+		t.Fatalf("GetWalletTransactions: got %v, want %v", err, queries.ErrWalletNotFound)
+	}
+	if _, err := q.GetWallet(ctx, "w-9"); !errors.Is(err, queries.ErrWalletNotFound) {
+		t.Fatalf("GetWallet: got %v, want %v", err, queries.ErrWalletNotFound)
+	}
+
+	failing := errors.New("connection refused")
+	repo := readmodeltest.New()
+	repo.Err = failing
+	q = queries.NewWalletTransactionQueries(repo)
+	if _, err := q.GetWalletState(ctx, walletID); !errors.Is(err, failing) || errors.Is(err, queries.ErrWalletNotFound) {
+		t.Fatalf("GetWalletState: got %v", err)
+	}
+}
+
+func TestGetWalletState(t *testing.T) {
+	repo := readmodeltest.New()
+	row := put(repo, walletID, nil)
+	q := queries.NewWalletTransactionQueries(repo)
+	ctx := context.Background()
+
+	state, err := q.GetWalletState(ctx, walletID)
+	if err != nil || state.WalletId != walletID || state.IsLocked {
+		t.Fatalf("state %+v, %v without a state column", state, err)
+	}
+
+	row.WalletState = aggregate.GetJsonString(domain.WalletState{WalletId: walletID, IsLocked: true})
+	repo.Put(row)
+	if state, err = q.GetWalletState(ctx, walletID); err != nil || !state.IsLocked {
+		t.Fatalf("state %+v, %v", state, err)
+	}
+	wallet, err := q.GetWallet(ctx, walletID)
+	if err != nil || !wallet.Balance.Equal(amount("100")) {
+		t.Fatalf("wallet %+v, %v", wallet, err)
+	}
+}
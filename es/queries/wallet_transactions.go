@@ -0,0 +1,139 @@
+package queries
+
+import (
+	"context"
+	"sort"
+
+	"github.com/gocql/gocql"
+	"github.com/novabankapp/wallet.data/constants"
+	"github.com/novabankapp/wallet.data/domain"
+	"github.com/novabankapp/wallet.data/es/aggregate"
+	"github.com/novabankapp/wallet.data/es/models"
+	"github.com/novabankapp/wallet.data/tracing"
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+const (
+	DefaultPageSize = 50
+	MaxPageSize     = 500
+)
+
+// WalletReadModel is the part of the Cassandra read model repository the queries need.
+type WalletReadModel interface {
+	GetByCondition(ctx context.Context, queries []map[string]string) (*models.WalletProjection, error)
+}
+
+type TransactionPageRequest struct {
+	Cursor string
+	Limit  int
+	Order  SortOrder
+}
+
+type TransactionPage struct {
+	Transactions []domain.WalletTransaction `json:"transactions"`
+	NextCursor   string                     `json:"next_cursor,omitempty"`
+}
+
+type WalletTransactionQueries struct {
+	Repo WalletReadModel
+}
+
+func NewWalletTransactionQueries(repo WalletReadModel) *WalletTransactionQueries {
+	return &WalletTransactionQueries{Repo: repo}
+}
+
+// GetWalletProjection loads the read model row of a wallet.
+func (q *WalletTransactionQueries) GetWalletProjection(ctx context.Context, walletId string) (*models.WalletProjection, error) {
+	conditions := []map[string]string{{
+		"column":  constants.WalletID,
+		"compare": "=",
+		"value":   walletId,
+	}}
+	ent, err := q.Repo.GetByCondition(ctx, conditions)
+	if errors.Is(err, gocql.ErrNotFound) {
+		return nil, ErrWalletNotFound
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "Repo.GetByCondition")
+	}
+	if ent == nil {
+		return nil, ErrWalletNotFound
+	}
+	return ent, nil
+}
+
+// GetAllWalletTransactions returns every transaction of a wallet matching the filter, oldest first.
+func (q *WalletTransactionQueries) GetAllWalletTransactions(ctx context.Context, walletId string, filter TransactionFilter) ([]domain.WalletTransaction, error) {
+	if err := filter.Validate(); err != nil {
+		return nil, err
+	}
+	ent, err := q.GetWalletProjection(ctx, walletId)
+	if err != nil {
+		return nil, err
+	}
+	all, err := aggregate.GetEntityArrayFromJsonString[domain.WalletTransaction](ent.WalletTransactions)
+	if err != nil {
+		return nil, errors.Wrap(err, "GetEntityArrayFromJsonString")
+	}
+
+	result := make([]domain.WalletTransaction, 0, len(*all))
+	for _, tx := range *all {
+		if filter.Matches(walletId, tx) {
+			result = append(result, tx)
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return newCursor(result[j]).compare(result[i]) < 0
+	})
+	return result, nil
+}
+
+// GetWalletTransactions returns one page of a wallet's transactions. The returned NextCursor
+// is empty on the last page.
+func (q *WalletTransactionQueries) GetWalletTransactions(ctx context.Context,
+	walletId string,
+	filter TransactionFilter,
+	page TransactionPageRequest) (*TransactionPage, error) {
+	ctx, span := tracing.StartSpan(ctx, "WalletTransactionQueries.GetWalletTransactions")
+	defer span.End()
+	span.SetAttributes(attribute.String(constants.WalletID, walletId))
+
+	after, err := decodeCursor(page.Cursor)
+	if err != nil {
+		return nil, err
+	}
+	limit := page.Limit
+	if limit <= 0 {
+		limit = DefaultPageSize
+	}
+	if limit > MaxPageSize {
+		limit = MaxPageSize
+	}
+
+	transactions, err := q.GetAllWalletTransactions(ctx, walletId, filter)
+	if err != nil {
+		return nil, err
+	}
+	if page.Order != SortOldestFirst {
+		for i, j := 0, len(transactions)-1; i < j; i, j = i+1, j-1 {
+			transactions[i], transactions[j] = transactions[j], transactions[i]
+		}
+	}
+
+	result := &TransactionPage{Transactions: make([]domain.WalletTransaction, 0, limit)}
+	for _, tx := range transactions {
+		if after != nil {
+			position := after.compare(tx)
+			if position == 0 || (page.Order == SortOldestFirst) == (position < 0) {
+				continue
+			}
+		}
+		if len(result.Transactions) == limit {
+			result.NextCursor = newCursor(result.Transactions[limit-1]).encode()
+			break
+		}
+		result.Transactions = append(result.Transactions, tx)
+	}
+	return result, nil
+}
@@ -0,0 +1,51 @@
+package queries
+
+import (
+	"context"
+
+	"github.com/novabankapp/wallet.data/constants"
+	"github.com/novabankapp/wallet.data/domain"
+	"github.com/novabankapp/wallet.data/es/aggregate"
+	"github.com/novabankapp/wallet.data/tracing"
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// GetWallet returns the balances of a wallet as the read model last saw them.
+func (q *WalletTransactionQueries) GetWallet(ctx context.Context, walletId string) (*domain.Wallet, error) {
+	ctx, span := tracing.StartSpan(ctx, "WalletTransactionQueries.GetWallet")
+	defer span.End()
+	span.SetAttributes(attribute.String(constants.WalletID, walletId))
+
+	ent, err := q.GetWalletProjection(ctx, walletId)
+	if err != nil {
+		return nil, err
+	}
+	wallet, err := aggregate.GetEntityFromJsonString[domain.Wallet](ent.Wallet)
+	if err != nil {
+		return nil, errors.Wrap(err, "GetEntityFromJsonString")
+	}
+	return wallet, nil
+}
+
+// GetWalletState returns the lock, blacklist and deletion flags of a wallet and its
+// active restrictions.
+func (q *WalletTransactionQueries) GetWalletState(ctx context.Context, walletId string) (*domain.WalletState, error) {
+	ctx, span := tracing.StartSpan(ctx, "WalletTransactionQueries.GetWalletState")
+	defer span.End()
+	span.SetAttributes(attribute.String(constants.WalletID, walletId))
+
+	ent, err := q.GetWalletProjection(ctx, walletId)
+	if err != nil {
+		return nil, err
+	}
+	state := &domain.WalletState{WalletId: walletId}
+	if ent.WalletState == "" {
+		return state, nil
+	}
+	state, err = aggregate.GetEntityFromJsonString[domain.WalletState](ent.WalletState)
+	if err != nil {
+		return nil, errors.Wrap(err, "GetEntityFromJsonString")
+	}
+	return state, nil
+}
@@ -0,0 +1,178 @@
+package readmodel
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/gocql/gocql"
+	es "github.com/novabankapp/common.data/eventstore"
+	"github.com/novabankapp/common.data/repositories/base"
+	"github.com/novabankapp/wallet.data/constants"
+	"github.com/novabankapp/wallet.data/es/models"
+	"github.com/pkg/errors"
+	"github.com/scylladb/gocqlx/v2"
+)
+
+const (
+	WalletProjectionsTable = "wallet_projections"
+
+	selectColumns = "id, wallet_id, user_id, wallet, wallet_state, wallet_transactions"
+)
+
+// conditionColumns maps the column names the projection handlers and queries filter on
+// to the columns of the wallet_projections table.
+var conditionColumns = map[string]string{
+	"ID":               "id",
+	constants.WalletID: "wallet_id",
+	constants.UserID:   "user_id",
+}
+
+var ErrInvalidCondition = errors.New("invalid read model condition")
+
+// WalletProjectionRepository keeps the wallet read model in the wallet_projections table
+// created by the migrations package.
+type WalletProjectionRepository struct {
+	session gocqlx.Session
+}
+
+var _ base.NoSqlRepository[models.WalletProjection] = (*WalletProjectionRepository)(nil)
+
+func NewWalletProjectionRepository(session gocqlx.Session) *WalletProjectionRepository {
+	return &WalletProjectionRepository{session: session}
+}
+
+// Create inserts a new row and returns es.ErrAlreadyExists when its id is taken.
+func (r *WalletProjectionRepository) Create(ctx context.Context, entity models.WalletProjection) (*models.WalletProjection, error) {
+	stmt := fmt.Sprintf("INSERT INTO %s (%s) VALUES (?, ?, ?, ?, ?, ?) IF NOT EXISTS", WalletProjectionsTable, selectColumns)
+	applied, err := r.session.Session.Query(stmt,
+		entity.ID, entity.WalletID, entity.UserID, entity.Wallet, entity.WalletState, entity.WalletTransactions,
+	).WithContext(ctx).MapScanCAS(map[string]interface{}{})
+	if err != nil {
+		return nil, errors.Wrap(err, "Query.MapScanCAS")
+	}
+	if !applied {
+		return nil, errors.Wrapf(es.ErrAlreadyExists, "wallet projection %s", entity.ID)
+	}
+	return &entity, nil
+}
+
+// Update overwrites the row with the given id. It returns false when there is no such row.
+func (r *WalletProjectionRepository) Update(ctx context.Context, entity models.WalletProjection, id string) (bool, error) {
+	stmt := fmt.Sprintf("UPDATE %s SET wallet_id = ?, user_id = ?, wallet = ?, wallet_state = ?, wallet_transactions = ? WHERE id = ? IF EXISTS", WalletProjectionsTable)
+	applied, err := r.session.Session.Query(stmt,
+		entity.WalletID, entity.UserID, entity.Wallet, entity.WalletState, entity.WalletTransactions, id,
+	).WithContext(ctx).MapScanCAS(map[string]interface{}{})
+	if err != nil {
+		return false, errors.Wrap(err, "Query.MapScanCAS")
+	}
+	return applied, nil
+}
+
+// Delete removes the row with the given id. It returns false when there is no such row.
+func (r *WalletProjectionRepository) Delete(ctx context.Context, id string) (bool, error) {
+	stmt := fmt.Sprintf("DELETE FROM %s WHERE id = ? IF EXISTS", WalletProjectionsTable)
+	applied, err := r.session.Session.Query(stmt, id).WithContext(ctx).MapScanCAS(map[string]interface{}{})
+	if err != nil {
+		return false, errors.Wrap(err, "Query.MapScanCAS")
+	}
+	return applied, nil
+}
+
+// GetById returns an error wrapping gocql.ErrNotFound when there is no row with the given id.
+func (r *WalletProjectionRepository) GetById(ctx context.Context, id string) (*models.WalletProjection, error) {
+	stmt := fmt.Sprintf("SELECT %s FROM %s WHERE id = ?", selectColumns, WalletProjectionsTable)
+	return r.scanOne(r.session.Session.Query(stmt, id).WithContext(ctx))
+}
+
+// GetByCondition returns the first row matching all conditions, or an error wrapping
+// gocql.ErrNotFound when none does. Each condition is a map with "column", "compare" and
+// "value" keys, as built by the projection handlers; only equality is supported.
+func (r *WalletProjectionRepository) GetByCondition(ctx context.Context, queries []map[string]string) (*models.WalletProjection, error) {
+	if len(queries) == 0 {
+		return nil, errors.Wrap(ErrInvalidCondition, "no conditions")
+	}
+	where := make([]string, 0, len(queries))
+	values := make([]interface{}, 0, len(queries))
+	for _, condition := range queries {
+		column, ok := conditionColumns[condition["column"]]
+		if !ok {
+			return nil, errors.Wrapf(ErrInvalidCondition, "unknown column %q", condition["column"])
+		}
+		if compare := condition["compare"]; compare != "=" {
+			return nil, errors.Wrapf(ErrInvalidCondition, "unsupported comparison %q", compare)
+		}
+		where = append(where, column+" = ?")
+		values = append(values, condition["value"])
+	}
+	stmt := fmt.Sprintf("SELECT %s FROM %s WHERE %s LIMIT 1", selectColumns, WalletProjectionsTable, strings.Join(where, " AND "))
+	if len(where) > 1 {
+		stmt += " ALLOW FILTERING"
+	}
+	return r.scanOne(r.session.Session.Query(stmt, values...).WithContext(ctx))
+}
+
+// GetByUser returns the rows of every wallet of a user, through the user_id index.
+func (r *WalletProjectionRepository) GetByUser(ctx context.Context, userID string) ([]models.WalletProjection, error) {
+	stmt := fmt.Sprintf("SELECT %s FROM %s WHERE user_id = ?", selectColumns, WalletProjectionsTable)
+	return r.scanAll(r.session.Session.Query(stmt, userID).WithContext(ctx))
+}
+
+// ErasePersonalData redacts the rows of the given wallets with RedactWalletProjection.
+// Run it after every other copy was erased: the wallets of a user are found through
+// GetByUser, which no longer finds them once their rows are redacted.
+func (r *WalletProjectionRepository) ErasePersonalData(ctx context.Context, userID string, walletIDs []string) error {
+	for _, walletID := range walletIDs {
+		row, err := r.GetByCondition(ctx, []map[string]string{{"column": constants.WalletID, "compare": "=", "value": walletID}})
+		if errors.Is(err, gocql.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		redacted, err := RedactWalletProjection(*row)
+		if err != nil {
+			return errors.Wrapf(err, "wallet projection %s", row.ID)
+		}
+		if _, err := r.Update(ctx, redacted, row.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// List returns every row, for batch jobs over the whole read model.
+func (r *WalletProjectionRepository) List(ctx context.Context) ([]models.WalletProjection, error) {
+	stmt := fmt.Sprintf("SELECT %s FROM %s", selectColumns, WalletProjectionsTable)
+	return r.scanAll(r.session.Session.Query(stmt).WithContext(ctx))
+}
+
+// Truncate removes every row, before the read model is rebuilt from the event store.
+func (r *WalletProjectionRepository) Truncate(ctx context.Context) error {
+	if err := r.session.Session.Query("TRUNCATE " + WalletProjectionsTable).WithContext(ctx).Exec(); err != nil {
+		return errors.Wrap(err, "Query.Exec")
+	}
+	return nil
+}
+
+func (r *WalletProjectionRepository) scanOne(query *gocql.Query) (*models.WalletProjection, error) {
+	var row models.WalletProjection
+	err := query.Scan(&row.ID, &row.WalletID, &row.UserID, &row.Wallet, &row.WalletState, &row.WalletTransactions)
+	if err != nil {
+		return nil, errors.Wrap(err, "Query.Scan")
+	}
+	return &row, nil
+}
+
+func (r *WalletProjectionRepository) scanAll(query *gocql.Query) ([]models.WalletProjection, error) {
+	iter := query.Iter()
+	rows := make([]models.WalletProjection, 0)
+	var row models.WalletProjection
+	for iter.Scan(&row.ID, &row.WalletID, &row.UserID, &row.Wallet, &row.WalletState, &row.WalletTransactions) {
+		rows = append(rows, row)
+	}
+	if err := iter.Close(); err != nil {
+		return nil, errors.Wrap(err, "Iter.Close")
+	}
+	return rows, nil
+}
@@ -0,0 +1,71 @@
+package readmodel
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/novabankapp/wallet.data/domain"
+	"github.com/novabankapp/wallet.data/es/models"
+	"github.com/pkg/errors"
+	"github.com/scylladb/gocqlx/v2"
+)
+
+const RestrictionExpiriesTable = "restriction_expiries"
+
+// restrictionKinds are the partitions of the restriction_expiries table.
+var restrictionKinds = []domain.RestrictionKind{domain.RestrictionLock, domain.RestrictionBlacklist}
+
+// RestrictionExpiryRepository reads and writes the restriction_expiries table created by
+// the migrations package. Rows are clustered by expiry within a partition per kind, so
+// Due reads only the rows that are due.
+type RestrictionExpiryRepository struct {
+	session gocqlx.Session
+}
+
+func NewRestrictionExpiryRepository(session gocqlx.Session) *RestrictionExpiryRepository {
+	return &RestrictionExpiryRepository{session: session}
+}
+
+// Add inserts an expiry; adding it again is a no-op.
+func (r *RestrictionExpiryRepository) Add(ctx context.Context, expiry models.RestrictionExpiry) error {
+	stmt := fmt.Sprintf("INSERT INTO %s (kind, expires_at, wallet_id) VALUES (?, ?, ?)", RestrictionExpiriesTable)
+	if err := r.session.Session.Query(stmt, expiry.Kind, expiry.ExpiresAt, expiry.WalletID).WithContext(ctx).Exec(); err != nil {
+		return errors.Wrap(err, "Query.Exec")
+	}
+	return nil
+}
+
+// Remove deletes an expiry; removing one that is not there is a no-op.
+func (r *RestrictionExpiryRepository) Remove(ctx context.Context, expiry models.RestrictionExpiry) error {
+	stmt := fmt.Sprintf("DELETE FROM %s WHERE kind = ? AND expires_at = ? AND wallet_id = ?", RestrictionExpiriesTable)
+	if err := r.session.Session.Query(stmt, expiry.Kind, expiry.ExpiresAt, expiry.WalletID).WithContext(ctx).Exec(); err != nil {
+		return errors.Wrap(err, "Query.Exec")
+	}
+	return nil
+}
+
+// Due returns the expiries that are not after now, oldest first within each kind.
+func (r *RestrictionExpiryRepository) Due(ctx context.Context, now time.Time) ([]models.RestrictionExpiry, error) {
+	stmt := fmt.Sprintf("SELECT kind, expires_at, wallet_id FROM %s WHERE kind = ? AND expires_at <= ?", RestrictionExpiriesTable)
+	expiries := make([]models.RestrictionExpiry, 0)
+	for _, kind := range restrictionKinds {
+		iter := r.session.Session.Query(stmt, string(kind), now).WithContext(ctx).Iter()
+		var expiry models.RestrictionExpiry
+		for iter.Scan(&expiry.Kind, &expiry.ExpiresAt, &expiry.WalletID) {
+			expiries = append(expiries, expiry)
+		}
+		if err := iter.Close(); err != nil {
+			return nil, errors.Wrap(err, "Iter.Close")
+		}
+	}
+	return expiries, nil
+}
+
+// Truncate removes every row, before the read model is rebuilt from the event store.
+func (r *RestrictionExpiryRepository) Truncate(ctx context.Context) error {
+	if err := r.session.Session.Query("TRUNCATE " + RestrictionExpiriesTable).WithContext(ctx).Exec(); err != nil {
+		return errors.Wrap(err, "Query.Exec")
+	}
+	return nil
+}
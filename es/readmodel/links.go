@@ -0,0 +1,50 @@
+package readmodel
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/novabankapp/wallet.data/domain"
+	"github.com/novabankapp/wallet.data/es/pii"
+	"github.com/pkg/errors"
+	"github.com/scylladb/gocqlx/v2"
+)
+
+const WalletLinksTable = "wallet_links"
+
+var _ pii.LinkStore = (*WalletLinkRepository)(nil)
+
+// WalletLinkRepository reads and writes the wallet_links table created by the migrations
+// package. Link values are stored and returned as given; wrap it with
+// pii.Cipher.ProtectLinks to keep them encrypted at rest.
+type WalletLinkRepository struct {
+	session gocqlx.Session
+}
+
+func NewWalletLinkRepository(session gocqlx.Session) *WalletLinkRepository {
+	return &WalletLinkRepository{session: session}
+}
+
+// Create inserts a link, replacing any link with the same id.
+func (r *WalletLinkRepository) Create(ctx context.Context, link domain.WalletLink) error {
+	stmt := fmt.Sprintf("INSERT INTO %s (id, wallet_id, value, link_date) VALUES (?, ?, ?, ?)", WalletLinksTable)
+	if err := r.session.Session.Query(stmt, link.ID, link.WalletId, link.Value, link.LinkDate).WithContext(ctx).Exec(); err != nil {
+		return errors.Wrap(err, "Query.Exec")
+	}
+	return nil
+}
+
+// GetByWallet returns the links of a wallet, through the wallet_id index.
+func (r *WalletLinkRepository) GetByWallet(ctx context.Context, walletID string) ([]domain.WalletLink, error) {
+	stmt := fmt.Sprintf("SELECT id, wallet_id, value, link_date FROM %s WHERE wallet_id = ?", WalletLinksTable)
+	iter := r.session.Session.Query(stmt, walletID).WithContext(ctx).Iter()
+	links := make([]domain.WalletLink, 0)
+	var link domain.WalletLink
+	for iter.Scan(&link.ID, &link.WalletId, &link.Value, &link.LinkDate) {
+		links = append(links, link)
+	}
+	if err := iter.Close(); err != nil {
+		return nil, errors.Wrap(err, "Iter.Close")
+	}
+	return links, nil
+}
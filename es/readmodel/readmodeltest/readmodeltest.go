@@ -0,0 +1,221 @@
+// Package readmodeltest provides an in-memory wallet read model that behaves like
+// readmodel.WalletProjectionRepository, for the tests of the packages that read it.
+package readmodeltest
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"github.com/EventStore/EventStore-Client-Go/esdb"
+	"github.com/gocql/gocql"
+	es "github.com/novabankapp/common.data/eventstore"
+	"github.com/novabankapp/common.data/logger"
+	"github.com/novabankapp/common.data/repositories/base"
+	"github.com/novabankapp/wallet.data/constants"
+	"github.com/novabankapp/wallet.data/domain"
+	"github.com/novabankapp/wallet.data/es/aggregate"
+	"github.com/novabankapp/wallet.data/es/models"
+	"github.com/novabankapp/wallet.data/es/readmodel"
+	"github.com/pkg/errors"
+)
+
+var _ base.NoSqlRepository[models.WalletProjection] = (*ReadModel)(nil)
+
+// ReadModel keeps the rows of the wallet_projections table by id. Its errors are those of
+// readmodel.WalletProjectionRepository: a lookup that finds nothing returns an error
+// wrapping gocql.ErrNotFound, Create of a taken id es.ErrAlreadyExists, and conditions on
+// other columns readmodel.ErrInvalidCondition.
+type ReadModel struct {
+	mu   sync.Mutex
+	rows map[string]models.WalletProjection
+	// Err, when set, is returned by every read.
+	Err error
+}
+
+// New returns a read model holding rows.
+func New(rows ...models.WalletProjection) *ReadModel {
+	m := &ReadModel{rows: make(map[string]models.WalletProjection)}
+	for _, row := range rows {
+		m.rows[row.ID] = row
+	}
+	return m
+}
+
+// Row builds the row of a wallet, encoded as the projection encodes it, with "row-" and
+// the wallet id as its id.
+func Row(wallet domain.Wallet, state domain.WalletState, transactions []domain.WalletTransaction) models.WalletProjection {
+	return models.WalletProjection{
+		ID:                 "row-" + wallet.ID,
+		WalletID:           wallet.ID,
+		UserID:             wallet.UserId,
+		Wallet:             aggregate.GetJsonString(wallet),
+		WalletState:        aggregate.GetJsonString(state),
+		WalletTransactions: aggregate.GetJsonString(transactions),
+	}
+}
+
+// Put inserts or replaces a row.
+func (m *ReadModel) Put(row models.WalletProjection) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.rows[row.ID] = row
+}
+
+// Rows returns every row, ordered by id.
+func (m *ReadModel) Rows() []models.WalletProjection {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.sorted()
+}
+
+func (m *ReadModel) Create(ctx context.Context, entity models.WalletProjection) (*models.WalletProjection, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.rows[entity.ID]; ok {
+		return nil, errors.Wrapf(es.ErrAlreadyExists, "wallet projection %s", entity.ID)
+	}
+	m.rows[entity.ID] = entity
+	return &entity, nil
+}
+
+func (m *ReadModel) Update(ctx context.Context, entity models.WalletProjection, id string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.rows[id]; !ok {
+		return false, nil
+	}
+	entity.ID = id
+	m.rows[id] = entity
+	return true, nil
+}
+
+func (m *ReadModel) Delete(ctx context.Context, id string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.rows[id]; !ok {
+		return false, nil
+	}
+	delete(m.rows, id)
+	return true, nil
+}
+
+func (m *ReadModel) GetById(ctx context.Context, id string) (*models.WalletProjection, error) {
+	return m.GetByCondition(ctx, []map[string]string{{"column": "ID", "compare": "=", "value": id}})
+}
+
+func (m *ReadModel) GetByCondition(ctx context.Context, queries []map[string]string) (*models.WalletProjection, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.Err != nil {
+		return nil, m.Err
+	}
+	if len(queries) == 0 {
+		return nil, errors.Wrap(readmodel.ErrInvalidCondition, "no conditions")
+	}
+	for _, condition := range queries {
+		if _, ok := column(models.WalletProjection{}, condition["column"]); !ok {
+			return nil, errors.Wrapf(readmodel.ErrInvalidCondition, "unknown column %q", condition["column"])
+		}
+		if compare := condition["compare"]; compare != "=" {
+			return nil, errors.Wrapf(readmodel.ErrInvalidCondition, "unsupported comparison %q", compare)
+		}
+	}
+	for _, row := range m.sorted() {
+		if matches(row, queries) {
+			return &row, nil
+		}
+	}
+	return nil, errors.Wrap(gocql.ErrNotFound, "Query.Scan")
+}
+
+func (m *ReadModel) GetByUser(ctx context.Context, userID string) ([]models.WalletProjection, error) {
+	return m.filter(func(row models.WalletProjection) bool { return row.UserID == userID })
+}
+
+// ErasePersonalData redacts the rows of the given wallets, as the repository does.
+func (m *ReadModel) ErasePersonalData(ctx context.Context, userID string, walletIDs []string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, walletID := range walletIDs {
+		for id, row := range m.rows {
+			if row.WalletID != walletID {
+				continue
+			}
+			redacted, err := readmodel.RedactWalletProjection(row)
+			if err != nil {
+				return errors.Wrapf(err, "wallet projection %s", id)
+			}
+			m.rows[id] = redacted
+		}
+	}
+	return nil
+}
+
+func (m *ReadModel) List(ctx context.Context) ([]models.WalletProjection, error) {
+	return m.filter(func(models.WalletProjection) bool { return true })
+}
+
+func (m *ReadModel) Truncate(ctx context.Context) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.rows = make(map[string]models.WalletProjection)
+	return nil
+}
+
+func (m *ReadModel) filter(keep func(models.WalletProjection) bool) ([]models.WalletProjection, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.Err != nil {
+		return nil, m.Err
+	}
+	rows := make([]models.WalletProjection, 0)
+	for _, row := range m.sorted() {
+		if keep(row) {
+			rows = append(rows, row)
+		}
+	}
+	return rows, nil
+}
+
+func (m *ReadModel) sorted() []models.WalletProjection {
+	rows := make([]models.WalletProjection, 0, len(m.rows))
+	for _, row := range m.rows {
+		rows = append(rows, row)
+	}
+	sort.Slice(rows, func(i, j int) bool { return rows[i].ID < rows[j].ID })
+	return rows
+}
+
+func matches(row models.WalletProjection, queries []map[string]string) bool {
+	for _, condition := range queries {
+		if value, _ := column(row, condition["column"]); value != condition["value"] {
+			return false
+		}
+	}
+	return true
+}
+
+// column returns the value of the column a condition names, for the columns
+// readmodel.WalletProjectionRepository can filter on.
+func column(row models.WalletProjection, name string) (string, bool) {
+	switch name {
+	case "ID":
+		return row.ID, true
+	case constants.WalletID:
+		return row.WalletID, true
+	case constants.UserID:
+		return row.UserID, true
+	}
+	return "", false
+}
+
+// NopLogger discards everything the projections and servers under test log.
+type NopLogger struct {
+	logger.Logger
+}
+
+func (NopLogger) Infof(string, ...interface{})                             {}
+func (NopLogger) Warnf(string, ...interface{})                             {}
+func (NopLogger) Errorf(string, ...interface{})                            {}
+func (NopLogger) ProjectionEvent(string, string, *esdb.ResolvedEvent, int) {}
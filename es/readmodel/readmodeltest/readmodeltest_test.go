@@ -0,0 +1,39 @@
+package readmodeltest_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/gocql/gocql"
+	es "github.com/novabankapp/common.data/eventstore"
+	"github.com/novabankapp/wallet.data/constants"
+	"github.com/novabankapp/wallet.data/domain"
+	"github.com/novabankapp/wallet.data/es/readmodel"
+	"github.com/novabankapp/wallet.data/es/readmodel/readmodeltest"
+	"github.com/pkg/errors"
+)
+
+func TestReadModelFailsLikeTheRepository(t *testing.T) {
+	ctx := context.Background()
+	row := readmodeltest.Row(domain.Wallet{ID: "w-1", UserId: "user-1"}, domain.WalletState{}, nil)
+	m := readmodeltest.New(row)
+
+	byWallet := func(walletID string) []map[string]string {
+		return []map[string]string{{"column": constants.WalletID, "compare": "=", "value": walletID}}
+	}
+	if got, err := m.GetByCondition(ctx, byWallet("w-1")); err != nil || got.ID != row.ID {
+		t.Fatalf("GetByCondition = %+v, %v", got, err)
+	}
+	if _, err := m.GetByCondition(ctx, byWallet("w-2")); !errors.Is(err, gocql.ErrNotFound) {
+		t.Fatalf("miss: got %v, want %v", err, gocql.ErrNotFound)
+	}
+	if _, err := m.GetByCondition(ctx, []map[string]string{{"column": "Balance", "compare": "=", "value": "1"}}); !errors.Is(err, readmodel.ErrInvalidCondition) {
+		t.Fatalf("unknown column: got %v, want %v", err, readmodel.ErrInvalidCondition)
+	}
+	if _, err := m.Create(ctx, row); !errors.Is(err, es.ErrAlreadyExists) {
+		t.Fatalf("Create: got %v, want %v", err, es.ErrAlreadyExists)
+	}
+	if updated, err := m.Update(ctx, row, "missing"); updated || err != nil {
+		t.Fatalf("Update of a missing row = %v, %v", updated, err)
+	}
+}
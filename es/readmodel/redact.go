@@ -0,0 +1,64 @@
+package readmodel
+
+import (
+	"encoding/json"
+
+	"github.com/novabankapp/wallet.data/domain"
+	"github.com/novabankapp/wallet.data/es/models"
+	"github.com/novabankapp/wallet.data/es/pii"
+	"github.com/pkg/errors"
+)
+
+// RedactWalletProjection returns row with the personal data of the wallet's owner
+// replaced by pii.Redacted: the user and account ids, and the descriptions of the
+// transactions and restrictions. Balances and flags are kept.
+func RedactWalletProjection(row models.WalletProjection) (models.WalletProjection, error) {
+	row.UserID = redacted(row.UserID)
+
+	var wallet map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(row.Wallet), &wallet); err != nil {
+		return row, errors.Wrap(err, "wallet")
+	}
+	for _, field := range []string{"user_id", "account_id"} {
+		if _, ok := wallet[field]; ok {
+			wallet[field], _ = json.Marshal(pii.Redacted)
+		}
+	}
+	data, err := json.Marshal(wallet)
+	if err != nil {
+		return row, errors.Wrap(err, "json.Marshal")
+	}
+	row.Wallet = string(data)
+
+	var state domain.WalletState
+	if err := json.Unmarshal([]byte(row.WalletState), &state); err != nil {
+		return row, errors.Wrap(err, "wallet state")
+	}
+	for i := range state.Restrictions {
+		state.Restrictions[i].Description = redacted(state.Restrictions[i].Description)
+	}
+	if data, err = json.Marshal(state); err != nil {
+		return row, errors.Wrap(err, "json.Marshal")
+	}
+	row.WalletState = string(data)
+
+	var transactions []domain.WalletTransaction
+	if err := json.Unmarshal([]byte(row.WalletTransactions), &transactions); err != nil {
+		return row, errors.Wrap(err, "wallet transactions")
+	}
+	for i := range transactions {
+		transactions[i].Description = redacted(transactions[i].Description)
+	}
+	if data, err = json.Marshal(transactions); err != nil {
+		return row, errors.Wrap(err, "json.Marshal")
+	}
+	row.WalletTransactions = string(data)
+	return row, nil
+}
+
+func redacted(value string) string {
+	if value == "" {
+		return value
+	}
+	return pii.Redacted
+}
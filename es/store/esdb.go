@@ -0,0 +1,173 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"math"
+	"strings"
+
+	"github.com/EventStore/EventStore-Client-Go/esdb"
+	"github.com/gofrs/uuid"
+	es "github.com/novabankapp/common.data/eventstore"
+	"github.com/pkg/errors"
+)
+
+const (
+	SnapshotStreamPrefix = "snapshot-"
+	SnapshotEventType    = "SNAPSHOT"
+)
+
+type esdbEventReader struct {
+	db *esdb.Client
+}
+
+func NewESDBEventReader(db *esdb.Client) EventReader {
+	return &esdbEventReader{db: db}
+}
+
+func (r *esdbEventReader) ReadEvents(ctx context.Context, streamID string, fromVersion int64) ([]es.Event, error) {
+	opts := esdb.ReadStreamOptions{Direction: esdb.Forwards, From: esdb.Start{}}
+	if fromVersion > 0 {
+		opts.From = esdb.Revision(uint64(fromVersion))
+	}
+	stream, err := r.db.ReadStream(ctx, streamID, opts, math.MaxUint64)
+	if err != nil {
+		if errors.Is(err, esdb.ErrStreamNotFound) {
+			return nil, nil
+		}
+		return nil, errors.Wrap(err, "db.ReadStream")
+	}
+	defer stream.Close()
+
+	events := make([]es.Event, 0)
+	for {
+		resolved, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return events, nil
+		}
+		if errors.Is(err, esdb.ErrStreamNotFound) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "stream.Recv")
+		}
+		events = append(events, es.NewEventFromRecorded(resolved.Event))
+	}
+}
+
+type esdbSnapshotStore struct {
+	db *esdb.Client
+}
+
+// NewESDBSnapshotStore keeps snapshots as events of a "snapshot-<stream>" stream next to
+// the aggregate's own stream.
+func NewESDBSnapshotStore(db *esdb.Client) SnapshotStore {
+	return &esdbSnapshotStore{db: db}
+}
+
+func (s *esdbSnapshotStore) SaveSnapshot(ctx context.Context, snapshot Snapshot) error {
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return errors.Wrap(err, "json.Marshal")
+	}
+	event := esdb.EventData{
+		EventID:     uuid.Must(uuid.NewV4()),
+		EventType:   SnapshotEventType,
+		ContentType: esdb.JsonContentType,
+		Data:        data,
+	}
+	if _, err := s.db.AppendToStream(ctx, SnapshotStreamPrefix+snapshot.StreamID, esdb.AppendToStreamOptions{}, event); err != nil {
+		return errors.Wrap(err, "db.AppendToStream")
+	}
+	return nil
+}
+
+func (s *esdbSnapshotStore) LoadSnapshot(ctx context.Context, streamID string, accept func(Snapshot) bool) (*Snapshot, error) {
+	opts := esdb.ReadStreamOptions{Direction: esdb.Backwards, From: esdb.End{}}
+	stream, err := s.db.ReadStream(ctx, SnapshotStreamPrefix+streamID, opts, math.MaxUint64)
+	if err != nil {
+		if errors.Is(err, esdb.ErrStreamNotFound) {
+			return nil, nil
+		}
+		return nil, errors.Wrap(err, "db.ReadStream")
+	}
+	defer stream.Close()
+
+	for {
+		resolved, err := stream.Recv()
+		if errors.Is(err, io.EOF) || errors.Is(err, esdb.ErrStreamNotFound) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "stream.Recv")
+		}
+		var snapshot Snapshot
+		if err := json.Unmarshal(resolved.Event.Data, &snapshot); err != nil {
+			return nil, errors.Wrap(err, "json.Unmarshal")
+		}
+		if accept == nil || accept(snapshot) {
+			return &snapshot, nil
+		}
+	}
+}
+
+type esdbHeadReader struct {
+	db *esdb.Client
+}
+
+func NewESDBHeadReader(db *esdb.Client) HeadReader {
+	return &esdbHeadReader{db: db}
+}
+
+func (r *esdbHeadReader) HeadPosition(ctx context.Context) (uint64, error) {
+	stream, err := r.db.ReadAll(ctx, esdb.ReadAllOptions{Direction: esdb.Backwards, From: esdb.End{}}, 1)
+	if err != nil {
+		return 0, errors.Wrap(err, "db.ReadAll")
+	}
+	defer stream.Close()
+
+	resolved, err := stream.Recv()
+	if errors.Is(err, io.EOF) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, errors.Wrap(err, "stream.Recv")
+	}
+	return resolved.OriginalEvent().Position.Commit, nil
+}
+
+type esdbStreamLister struct {
+	db *esdb.Client
+}
+
+func NewESDBStreamLister(db *esdb.Client) StreamLister {
+	return &esdbStreamLister{db: db}
+}
+
+// ListStreams reads $all from the start, so it takes as long as a full replay.
+func (l *esdbStreamLister) ListStreams(ctx context.Context, prefix string) ([]string, error) {
+	stream, err := l.db.ReadAll(ctx, esdb.ReadAllOptions{Direction: esdb.Forwards, From: esdb.Start{}}, math.MaxUint64)
+	if err != nil {
+		return nil, errors.Wrap(err, "db.ReadAll")
+	}
+	defer stream.Close()
+
+	seen := make(map[string]bool)
+	ids := make([]string, 0)
+	for {
+		resolved, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return ids, nil
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "stream.Recv")
+		}
+		streamID := resolved.Event.StreamID
+		if !strings.HasPrefix(streamID, prefix) || seen[streamID] {
+			continue
+		}
+		seen[streamID] = true
+		ids = append(ids, streamID)
+	}
+}
@@ -0,0 +1,75 @@
+package store
+
+import (
+	"context"
+
+	"github.com/EventStore/EventStore-Client-Go/esdb"
+	"github.com/gofrs/uuid"
+	es "github.com/novabankapp/common.data/eventstore"
+	"github.com/pkg/errors"
+)
+
+type esdbAggregateStore struct {
+	db     *esdb.Client
+	reader EventReader
+}
+
+// NewESDBAggregateStore loads aggregates by replaying their stream and saves them with an
+// expected revision, so that a concurrent writer makes Save fail with
+// esdb.ErrWrongExpectedStreamRevision.
+func NewESDBAggregateStore(db *esdb.Client) es.AggregateStore {
+	return &esdbAggregateStore{db: db, reader: NewESDBEventReader(db)}
+}
+
+func (s *esdbAggregateStore) Load(ctx context.Context, aggregate es.Aggregate) error {
+	events, err := s.reader.ReadEvents(ctx, aggregate.GetID(), 0)
+	if err != nil {
+		return err
+	}
+	for _, evt := range events {
+		if err := aggregate.RaiseEvent(evt); err != nil {
+			return errors.Wrap(err, "RaiseEvent")
+		}
+	}
+	return nil
+}
+
+func (s *esdbAggregateStore) Save(ctx context.Context, aggregate es.Aggregate) error {
+	events := aggregate.GetUncommittedEvents()
+	if len(events) == 0 {
+		return nil
+	}
+	data := make([]esdb.EventData, 0, len(events))
+	for _, evt := range events {
+		eventData := evt.ToEventData()
+		eventID, err := uuid.FromString(evt.GetEventID())
+		if err != nil {
+			eventID = uuid.Must(uuid.NewV4())
+		}
+		eventData.EventID = eventID
+		data = append(data, eventData)
+	}
+
+	opts := esdb.AppendToStreamOptions{ExpectedRevision: esdb.NoStream{}}
+	if expected := aggregate.GetVersion() - int64(len(events)); expected >= 0 {
+		opts.ExpectedRevision = esdb.Revision(uint64(expected))
+	}
+	if _, err := s.db.AppendToStream(ctx, aggregate.GetID(), opts, data...); err != nil {
+		return errors.Wrap(err, "db.AppendToStream")
+	}
+	aggregate.ClearUncommittedEvents()
+	return nil
+}
+
+// Exists returns esdb.ErrStreamNotFound when the stream has no events.
+func (s *esdbAggregateStore) Exists(ctx context.Context, streamID string) error {
+	stream, err := s.db.ReadStream(ctx, streamID, esdb.ReadStreamOptions{Direction: esdb.Backwards, From: esdb.End{}}, 1)
+	if err != nil {
+		return errors.Wrap(err, "db.ReadStream")
+	}
+	defer stream.Close()
+	if _, err := stream.Recv(); err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,231 @@
+package store
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/EventStore/EventStore-Client-Go/esdb"
+	"github.com/gofrs/uuid"
+	es "github.com/novabankapp/common.data/eventstore"
+	"github.com/pkg/errors"
+)
+
+const jsonContentType = "application/json"
+
+// MemoryEventStore is an in-process stand-in for EventStoreDB. It implements
+// eventstore.AggregateStore, EventReader and SnapshotStore, and offers catch-up and
+// persistent subscriptions with the same event types as the esdb client, so aggregates,
+// projections and other consumers can run end-to-end without a server.
+type MemoryEventStore struct {
+	mu        sync.Mutex
+	streams   map[string][]*esdb.RecordedEvent
+	all       []*esdb.RecordedEvent
+	snapshots map[string][]Snapshot
+	groups    map[string]*persistentGroup
+	changed   chan struct{}
+	now       func() time.Time
+}
+
+func NewMemoryEventStore() *MemoryEventStore {
+	return &MemoryEventStore{
+		streams:   make(map[string][]*esdb.RecordedEvent),
+		snapshots: make(map[string][]Snapshot),
+		groups:    make(map[string]*persistentGroup),
+		changed:   make(chan struct{}),
+		now:       time.Now,
+	}
+}
+
+// notify wakes up every subscription waiting for new events. Callers must hold mu.
+func (m *MemoryEventStore) notify() {
+	close(m.changed)
+	m.changed = make(chan struct{})
+}
+
+// Load replays the aggregate's stream, like the EventStoreDB aggregate store does.
+func (m *MemoryEventStore) Load(ctx context.Context, aggregate es.Aggregate) error {
+	events, err := m.ReadEvents(ctx, aggregate.GetID(), 0)
+	if err != nil {
+		return err
+	}
+	for _, evt := range events {
+		if err := aggregate.RaiseEvent(evt); err != nil {
+			return errors.Wrap(err, "RaiseEvent")
+		}
+	}
+	return nil
+}
+
+// Save appends the aggregate's uncommitted events. The stream must still be at the version
+// the aggregate was loaded at; otherwise esdb.ErrWrongExpectedStreamRevision is returned
+// and nothing is written.
+func (m *MemoryEventStore) Save(ctx context.Context, aggregate es.Aggregate) error {
+	events := aggregate.GetUncommittedEvents()
+	if len(events) == 0 {
+		return nil
+	}
+	expected := aggregate.GetVersion() - int64(len(events))
+	if _, err := m.AppendToStream(ctx, aggregate.GetID(), expected, events...); err != nil {
+		return err
+	}
+	aggregate.ClearUncommittedEvents()
+	return nil
+}
+
+// Exists returns esdb.ErrStreamNotFound when the stream has no events.
+func (m *MemoryEventStore) Exists(ctx context.Context, streamID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(m.streams[streamID]) == 0 {
+		return esdb.ErrStreamNotFound
+	}
+	return nil
+}
+
+// AppendToStream appends events to a stream if its last event version is expectedVersion
+// (-1 for a stream that must not exist yet) and returns the new last version.
+func (m *MemoryEventStore) AppendToStream(ctx context.Context, streamID string, expectedVersion int64, events ...es.Event) (int64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stream := m.streams[streamID]
+	current := int64(len(stream)) - 1
+	if current != expectedVersion {
+		return current, errors.Wrapf(esdb.ErrWrongExpectedStreamRevision, "stream %s is at version %d, expected %d", streamID, current, expectedVersion)
+	}
+
+	for _, evt := range events {
+		eventID, err := uuid.FromString(evt.GetEventID())
+		if err != nil {
+			eventID = uuid.Must(uuid.NewV4())
+		}
+		created := evt.GetTimeStamp()
+		if created.IsZero() {
+			created = m.now().UTC()
+		}
+		position := uint64(len(m.all))
+		recorded := &esdb.RecordedEvent{
+			EventID:        eventID,
+			EventType:      evt.GetEventType(),
+			ContentType:    jsonContentType,
+			StreamID:       streamID,
+			EventNumber:    uint64(len(stream)),
+			Position:       esdb.Position{Commit: position, Prepare: position},
+			CreatedDate:    created,
+			Data:           evt.GetData(),
+			SystemMetadata: map[string]string{"type": evt.GetEventType(), "content-type": jsonContentType},
+			UserMetadata:   evt.GetMetadata(),
+		}
+		stream = append(stream, recorded)
+		m.all = append(m.all, recorded)
+	}
+	m.streams[streamID] = stream
+	m.notify()
+	return int64(len(stream)) - 1, nil
+}
+
+func (m *MemoryEventStore) ReadEvents(ctx context.Context, streamID string, fromVersion int64) ([]es.Event, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stream := m.streams[streamID]
+	if fromVersion < 0 {
+		fromVersion = 0
+	}
+	events := make([]es.Event, 0, len(stream))
+	for i := fromVersion; i < int64(len(stream)); i++ {
+		events = append(events, es.NewEventFromRecorded(stream[i]))
+	}
+	return events, nil
+}
+
+// ReadAll returns every event of every stream in commit order.
+func (m *MemoryEventStore) ReadAll(ctx context.Context) ([]es.Event, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	events := make([]es.Event, 0, len(m.all))
+	for _, recorded := range m.all {
+		events = append(events, es.NewEventFromRecorded(recorded))
+	}
+	return events, nil
+}
+
+// HeadPosition returns the commit position of the last event appended to any stream.
+func (m *MemoryEventStore) HeadPosition(ctx context.Context) (uint64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(m.all) == 0 {
+		return 0, nil
+	}
+	return m.all[len(m.all)-1].Position.Commit, nil
+}
+
+// ListStreams returns the ids of the streams that start with prefix, in the order they
+// were first written to.
+func (m *MemoryEventStore) ListStreams(ctx context.Context, prefix string) ([]string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	seen := make(map[string]bool)
+	ids := make([]string, 0)
+	for _, recorded := range m.all {
+		if !strings.HasPrefix(recorded.StreamID, prefix) || seen[recorded.StreamID] {
+			continue
+		}
+		seen[recorded.StreamID] = true
+		ids = append(ids, recorded.StreamID)
+	}
+	return ids, nil
+}
+
+// StreamIDs returns the ids of all streams, sorted.
+func (m *MemoryEventStore) StreamIDs() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ids := make([]string, 0, len(m.streams))
+	for id := range m.streams {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func (m *MemoryEventStore) SaveSnapshot(ctx context.Context, snapshot Snapshot) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.snapshots[snapshot.StreamID] = append(m.snapshots[snapshot.StreamID], snapshot)
+	return nil
+}
+
+func (m *MemoryEventStore) LoadSnapshot(ctx context.Context, streamID string, accept func(Snapshot) bool) (*Snapshot, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	snapshots := m.snapshots[streamID]
+	for i := len(snapshots) - 1; i >= 0; i-- {
+		if accept == nil || accept(snapshots[i]) {
+			snapshot := snapshots[i]
+			return &snapshot, nil
+		}
+	}
+	return nil, nil
+}
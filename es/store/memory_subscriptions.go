@@ -0,0 +1,286 @@
+package store
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"github.com/EventStore/EventStore-Client-Go/esdb"
+	"github.com/pkg/errors"
+)
+
+var (
+	ErrSubscriptionClosed = errors.New("subscription closed")
+	ErrUnknownGroup       = errors.New("persistent subscription group does not exist")
+	ErrGroupExists        = errors.New("persistent subscription group already exists")
+	ErrNotInFlight        = errors.New("event is not in flight for this subscription")
+)
+
+// SubscriptionFilter limits a subscription to streams starting with StreamPrefix.
+// An empty filter matches every stream.
+type SubscriptionFilter struct {
+	StreamPrefix string
+}
+
+func (f SubscriptionFilter) matches(recorded *esdb.RecordedEvent) bool {
+	return strings.HasPrefix(recorded.StreamID, f.StreamPrefix)
+}
+
+// MemorySubscription is a catch-up subscription: it delivers every matching event from its
+// start position on and then follows new appends.
+type MemorySubscription struct {
+	store    *MemoryEventStore
+	ctx      context.Context
+	filter   SubscriptionFilter
+	streamID string
+	next     int
+	done     chan struct{}
+	once     sync.Once
+}
+
+// SubscribeToAll starts a catch-up subscription over all streams at a commit position
+// (0 for the beginning).
+func (m *MemoryEventStore) SubscribeToAll(ctx context.Context, fromPosition uint64, filter SubscriptionFilter) *MemorySubscription {
+	return &MemorySubscription{store: m, ctx: ctx, filter: filter, next: int(fromPosition), done: make(chan struct{})}
+}
+
+// SubscribeToStream starts a catch-up subscription over one stream at a stream revision
+// (0 for the beginning).
+func (m *MemoryEventStore) SubscribeToStream(ctx context.Context, streamID string, fromRevision uint64) *MemorySubscription {
+	return &MemorySubscription{store: m, ctx: ctx, streamID: streamID, next: int(fromRevision), done: make(chan struct{})}
+}
+
+// source is the log the subscription reads from: one stream or $all. Callers must hold the
+// store lock.
+func (s *MemorySubscription) source() []*esdb.RecordedEvent {
+	if s.streamID != "" {
+		return s.store.streams[s.streamID]
+	}
+	return s.store.all
+}
+
+func (s *MemorySubscription) Recv() *esdb.SubscriptionEvent {
+	for {
+		s.store.mu.Lock()
+		for events := s.source(); s.next < len(events); {
+			recorded := events[s.next]
+			s.next++
+			if s.filter.matches(recorded) {
+				s.store.mu.Unlock()
+				return &esdb.SubscriptionEvent{EventAppeared: resolved(recorded)}
+			}
+		}
+		wait := s.store.changed
+		s.store.mu.Unlock()
+
+		select {
+		case <-wait:
+		case <-s.ctx.Done():
+			return dropped(s.ctx.Err())
+		case <-s.done:
+			return dropped(ErrSubscriptionClosed)
+		}
+	}
+}
+
+func (s *MemorySubscription) Close() error {
+	s.once.Do(func() { close(s.done) })
+	return nil
+}
+
+// PersistentSubscriptionSettings mirrors the esdb settings the in-memory store honours.
+type PersistentSubscriptionSettings struct {
+	Filter        SubscriptionFilter
+	MaxRetryCount int
+}
+
+// ParkedEvent is an event the group gave up on, with the reason of the last Nack.
+type ParkedEvent struct {
+	Event  *esdb.ResolvedEvent
+	Reason string
+}
+
+type inFlight struct {
+	event   *esdb.ResolvedEvent
+	retries int
+	owner   *MemoryPersistentSubscription
+}
+
+type persistentGroup struct {
+	settings PersistentSubscriptionSettings
+	next     int
+	retry    []inFlight
+	inFlight map[string]inFlight
+	parked   []ParkedEvent
+}
+
+// CreatePersistentSubscription creates a consumer group that starts at the beginning of
+// the log. Connections to the same group compete for events.
+func (m *MemoryEventStore) CreatePersistentSubscription(groupName string, settings PersistentSubscriptionSettings) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.groups[groupName]; ok {
+		return ErrGroupExists
+	}
+	if settings.MaxRetryCount <= 0 {
+		settings.MaxRetryCount = int(esdb.SubscriptionSettingsDefault().MaxRetryCount)
+	}
+	m.groups[groupName] = &persistentGroup{settings: settings, inFlight: make(map[string]inFlight)}
+	return nil
+}
+
+func (m *MemoryEventStore) ConnectToPersistentSubscription(ctx context.Context, groupName string) (*MemoryPersistentSubscription, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	group, ok := m.groups[groupName]
+	if !ok {
+		return nil, ErrUnknownGroup
+	}
+	return &MemoryPersistentSubscription{store: m, group: group, ctx: ctx, done: make(chan struct{})}, nil
+}
+
+// ParkedEvents returns the events the group parked, oldest first.
+func (m *MemoryEventStore) ParkedEvents(groupName string) ([]ParkedEvent, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	group, ok := m.groups[groupName]
+	if !ok {
+		return nil, ErrUnknownGroup
+	}
+	return append([]ParkedEvent(nil), group.parked...), nil
+}
+
+// ReplayParkedEvents hands every parked event of the group back to its consumers.
+func (m *MemoryEventStore) ReplayParkedEvents(groupName string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	group, ok := m.groups[groupName]
+	if !ok {
+		return ErrUnknownGroup
+	}
+	for _, parked := range group.parked {
+		group.retry = append(group.retry, inFlight{event: parked.Event})
+	}
+	group.parked = nil
+	m.notify()
+	return nil
+}
+
+// MemoryPersistentSubscription is one connection to a persistent subscription group. It has
+// the same Recv/Ack/Nack contract as *esdb.PersistentSubscription.
+type MemoryPersistentSubscription struct {
+	store *MemoryEventStore
+	group *persistentGroup
+	ctx   context.Context
+	done  chan struct{}
+	once  sync.Once
+}
+
+func (s *MemoryPersistentSubscription) Recv() *esdb.SubscriptionEvent {
+	for {
+		s.store.mu.Lock()
+		if delivery, ok := s.group.take(s.store.all, s); ok {
+			s.store.mu.Unlock()
+			return &esdb.SubscriptionEvent{EventAppeared: delivery}
+		}
+		wait := s.store.changed
+		s.store.mu.Unlock()
+
+		select {
+		case <-wait:
+		case <-s.ctx.Done():
+			return dropped(s.ctx.Err())
+		case <-s.done:
+			return dropped(ErrSubscriptionClosed)
+		}
+	}
+}
+
+// take hands out the next retry or the next new event. Callers must hold the store lock.
+func (g *persistentGroup) take(all []*esdb.RecordedEvent, owner *MemoryPersistentSubscription) (*esdb.ResolvedEvent, bool) {
+	if len(g.retry) > 0 {
+		delivery := g.retry[0]
+		g.retry = g.retry[1:]
+		delivery.owner = owner
+		g.inFlight[delivery.event.Event.EventID.String()] = delivery
+		return delivery.event, true
+	}
+	for g.next < len(all) {
+		recorded := all[g.next]
+		g.next++
+		if !g.settings.Filter.matches(recorded) {
+			continue
+		}
+		delivery := inFlight{event: resolved(recorded), owner: owner}
+		g.inFlight[recorded.EventID.String()] = delivery
+		return delivery.event, true
+	}
+	return nil, false
+}
+
+func (s *MemoryPersistentSubscription) Ack(messages ...*esdb.ResolvedEvent) error {
+	s.store.mu.Lock()
+	defer s.store.mu.Unlock()
+	for _, msg := range messages {
+		id := msg.Event.EventID.String()
+		if _, ok := s.group.inFlight[id]; !ok {
+			return errors.Wrap(ErrNotInFlight, id)
+		}
+		delete(s.group.inFlight, id)
+	}
+	return nil
+}
+
+func (s *MemoryPersistentSubscription) Nack(reason string, action esdb.Nack_Action, messages ...*esdb.ResolvedEvent) error {
+	s.store.mu.Lock()
+	defer s.store.mu.Unlock()
+	for _, msg := range messages {
+		id := msg.Event.EventID.String()
+		delivery, ok := s.group.inFlight[id]
+		if !ok {
+			return errors.Wrap(ErrNotInFlight, id)
+		}
+		delete(s.group.inFlight, id)
+
+		switch action {
+		case esdb.Nack_Retry:
+			delivery.retries++
+			if delivery.retries > s.group.settings.MaxRetryCount {
+				s.group.parked = append(s.group.parked, ParkedEvent{Event: delivery.event, Reason: reason})
+				continue
+			}
+			s.group.retry = append(s.group.retry, delivery)
+		case esdb.Nack_Park:
+			s.group.parked = append(s.group.parked, ParkedEvent{Event: delivery.event, Reason: reason})
+		case esdb.Nack_Skip, esdb.Nack_Stop, esdb.Nack_Unknown:
+		}
+	}
+	s.store.notify()
+	return nil
+}
+
+// Close drops the connection; events it had in flight go back to the group.
+func (s *MemoryPersistentSubscription) Close() error {
+	s.once.Do(func() {
+		close(s.done)
+		s.store.mu.Lock()
+		defer s.store.mu.Unlock()
+		for id, delivery := range s.group.inFlight {
+			if delivery.owner == s {
+				delete(s.group.inFlight, id)
+				s.group.retry = append(s.group.retry, delivery)
+			}
+		}
+		s.store.notify()
+	})
+	return nil
+}
+
+func resolved(recorded *esdb.RecordedEvent) *esdb.ResolvedEvent {
+	commit := recorded.Position.Commit
+	return &esdb.ResolvedEvent{Event: recorded, Commit: &commit}
+}
+
+func dropped(err error) *esdb.SubscriptionEvent {
+	return &esdb.SubscriptionEvent{SubscriptionDropped: &esdb.SubscriptionDropped{Error: err}}
+}
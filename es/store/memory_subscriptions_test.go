@@ -0,0 +1,224 @@
+package store_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/EventStore/EventStore-Client-Go/esdb"
+	"github.com/novabankapp/wallet.data/es/store"
+	"github.com/pkg/errors"
+)
+
+type subscription interface {
+	Recv() *esdb.SubscriptionEvent
+}
+
+// receive reads n events, failing the test if the subscription drops or stalls.
+func receive(t *testing.T, sub subscription, n int) []*esdb.ResolvedEvent {
+	t.Helper()
+	events := make([]*esdb.ResolvedEvent, 0, n)
+	for len(events) < n {
+		received := make(chan *esdb.SubscriptionEvent, 1)
+		go func() { received <- sub.Recv() }()
+		select {
+		case event := <-received:
+			if event.SubscriptionDropped != nil {
+				t.Fatalf("subscription dropped: %v", event.SubscriptionDropped.Error)
+			}
+			events = append(events, event.EventAppeared)
+		case <-time.After(time.Second):
+			t.Fatalf("received %d of %d events", len(events), n)
+		}
+	}
+	return events
+}
+
+func types(events []*esdb.ResolvedEvent) []string {
+	got := make([]string, 0, len(events))
+	for _, evt := range events {
+		got = append(got, evt.Event.EventType)
+	}
+	return got
+}
+
+func TestSubscribeToAll(t *testing.T) {
+	db := store.NewMemoryEventStore()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	appendEvents(t, db, "wallet-1", "A")
+	appendEvents(t, db, "other-1", "B")
+	appendEvents(t, db, "wallet-2", "C")
+
+	sub := db.SubscribeToAll(ctx, 0, store.SubscriptionFilter{StreamPrefix: "wallet-"})
+	defer sub.Close()
+	if got := types(receive(t, sub, 2)); !equal(got, []string{"A", "C"}) {
+		t.Fatalf("caught up with %v", got)
+	}
+
+	appendEvents(t, db, "other-1", "D")
+	appendEvents(t, db, "wallet-1", "E")
+	live := receive(t, sub, 1)
+	if live[0].Event.EventType != "E" || *live[0].Commit != 4 {
+		t.Fatalf("followed %s at %d", live[0].Event.EventType, *live[0].Commit)
+	}
+
+	fromPosition := db.SubscribeToAll(ctx, 2, store.SubscriptionFilter{})
+	defer fromPosition.Close()
+	if got := types(receive(t, fromPosition, 3)); !equal(got, []string{"C", "D", "E"}) {
+		t.Fatalf("from position 2 got %v", got)
+	}
+}
+
+func TestSubscribeToStreamCountsStreamRevisions(t *testing.T) {
+	db := store.NewMemoryEventStore()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	// Interleave another stream so stream revisions and $all positions differ.
+	appendEvents(t, db, "other-1", "X", "Y", "Z")
+	appendEvents(t, db, "wallet-1", "A")
+	appendEvents(t, db, "other-1", "W")
+	appendEvents(t, db, "wallet-1", "B", "C")
+
+	sub := db.SubscribeToStream(ctx, "wallet-1", 1)
+	defer sub.Close()
+	events := receive(t, sub, 2)
+	if got := types(events); !equal(got, []string{"B", "C"}) {
+		t.Fatalf("from revision 1 got %v", got)
+	}
+	if events[0].Event.EventNumber != 1 || events[1].Event.EventNumber != 2 {
+		t.Fatalf("revisions %d and %d", events[0].Event.EventNumber, events[1].Event.EventNumber)
+	}
+
+	appendEvents(t, db, "other-1", "V")
+	appendEvents(t, db, "wallet-1", "D")
+	if got := types(receive(t, sub, 1)); !equal(got, []string{"D"}) {
+		t.Fatalf("followed %v", got)
+	}
+}
+
+func TestSubscribeToStreamAfter(t *testing.T) {
+	db := store.NewMemoryEventStore()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	appendEvents(t, db, "other-1", "X", "Y")
+	appendEvents(t, db, "wallet-1", "A", "B", "C")
+
+	for _, tc := range []struct {
+		after int64
+		want  []string
+	}{
+		{store.FromStreamStart, []string{"A", "B", "C", "D"}},
+		{0, []string{"B", "C", "D"}},
+		{2, []string{"D"}},
+		{store.FromStreamEnd, []string{"E"}},
+	} {
+		sub, err := db.SubscribeToStreamAfter(ctx, "wallet-1", tc.after)
+		if err != nil {
+			t.Fatal(err)
+		}
+		switch tc.after {
+		case store.FromStreamStart:
+			appendEvents(t, db, "wallet-1", "D")
+		case store.FromStreamEnd:
+			appendEvents(t, db, "wallet-1", "E")
+		}
+		if got := types(receive(t, sub, len(tc.want))); !equal(got, tc.want) {
+			t.Errorf("after %d got %v, want %v", tc.after, got, tc.want)
+		}
+		sub.Close()
+	}
+}
+
+func TestSubscriptionDrops(t *testing.T) {
+	db := store.NewMemoryEventStore()
+
+	closed := db.SubscribeToStream(context.Background(), "wallet-1", 0)
+	closed.Close()
+	if event := closed.Recv(); event.SubscriptionDropped == nil || !errors.Is(event.SubscriptionDropped.Error, store.ErrSubscriptionClosed) {
+		t.Fatalf("closed subscription returned %+v", event)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancelled := db.SubscribeToAll(ctx, 0, store.SubscriptionFilter{})
+	cancel()
+	if event := cancelled.Recv(); event.SubscriptionDropped == nil || !errors.Is(event.SubscriptionDropped.Error, context.Canceled) {
+		t.Fatalf("cancelled subscription returned %+v", event)
+	}
+}
+
+func TestPersistentSubscription(t *testing.T) {
+	db := store.NewMemoryEventStore()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := db.CreatePersistentSubscription("group", store.PersistentSubscriptionSettings{
+		Filter:        store.SubscriptionFilter{StreamPrefix: "wallet-"},
+		MaxRetryCount: 1,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.CreatePersistentSubscription("group", store.PersistentSubscriptionSettings{}); !errors.Is(err, store.ErrGroupExists) {
+		t.Fatalf("got %v, want %v", err, store.ErrGroupExists)
+	}
+	if _, err := db.ConnectToPersistentSubscription(ctx, "missing"); !errors.Is(err, store.ErrUnknownGroup) {
+		t.Fatalf("got %v, want %v", err, store.ErrUnknownGroup)
+	}
+	appendEvents(t, db, "wallet-1", "A", "B")
+	appendEvents(t, db, "other-1", "X")
+	appendEvents(t, db, "wallet-2", "C")
+
+	sub, err := db.ConnectToPersistentSubscription(ctx, "group")
+	if err != nil {
+		t.Fatal(err)
+	}
+	events := receive(t, sub, 3)
+	if got := types(events); !equal(got, []string{"A", "B", "C"}) {
+		t.Fatalf("delivered %v", got)
+	}
+	if err := sub.Ack(events[0]); err != nil {
+		t.Fatal(err)
+	}
+	if err := sub.Ack(events[0]); !errors.Is(err, store.ErrNotInFlight) {
+		t.Fatalf("got %v, want %v", err, store.ErrNotInFlight)
+	}
+	if err := sub.Nack("broken", esdb.Nack_Park, events[1]); err != nil {
+		t.Fatal(err)
+	}
+
+	// C is retried once, then parked.
+	if err := sub.Nack("flaky", esdb.Nack_Retry, events[2]); err != nil {
+		t.Fatal(err)
+	}
+	retried := receive(t, sub, 1)
+	if retried[0].Event.EventType != "C" {
+		t.Fatalf("retried %s", retried[0].Event.EventType)
+	}
+	if err := sub.Nack("flaky", esdb.Nack_Retry, retried[0]); err != nil {
+		t.Fatal(err)
+	}
+	parked, err := db.ParkedEvents("group")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(parked) != 2 || parked[0].Reason != "broken" || parked[1].Event.Event.EventType != "C" {
+		t.Fatalf("parked %+v", parked)
+	}
+
+	// Replayed events go to the next connection; events in flight on a closed one too.
+	if err := db.ReplayParkedEvents("group"); err != nil {
+		t.Fatal(err)
+	}
+	receive(t, sub, 1)
+	sub.Close()
+	next, err := db.ConnectToPersistentSubscription(ctx, "group")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer next.Close()
+	if got := types(receive(t, next, 2)); !equal(got, []string{"C", "B"}) {
+		t.Fatalf("redelivered %v", got)
+	}
+	if parked, _ := db.ParkedEvents("group"); len(parked) != 0 {
+		t.Fatalf("still parked %+v", parked)
+	}
+}
@@ -0,0 +1,184 @@
+package store_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/EventStore/EventStore-Client-Go/esdb"
+	es "github.com/novabankapp/common.data/eventstore"
+	"github.com/novabankapp/wallet.data/es/aggregate"
+	"github.com/novabankapp/wallet.data/es/store"
+	"github.com/pkg/errors"
+	"github.com/shopspring/decimal"
+)
+
+func event(eventType string) es.Event {
+	return es.Event{EventType: eventType, Data: []byte(`{}`)}
+}
+
+func appendEvents(t *testing.T, db *store.MemoryEventStore, streamID string, eventTypes ...string) {
+	t.Helper()
+	existing, err := db.ReadEvents(context.Background(), streamID, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	events := make([]es.Event, 0, len(eventTypes))
+	for _, eventType := range eventTypes {
+		events = append(events, event(eventType))
+	}
+	if _, err := db.AppendToStream(context.Background(), streamID, int64(len(existing))-1, events...); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func eventTypes(events []es.Event) []string {
+	types := make([]string, 0, len(events))
+	for _, evt := range events {
+		types = append(types, evt.GetEventType())
+	}
+	return types
+}
+
+func equal(got, want []string) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestAppendToStream(t *testing.T) {
+	db := store.NewMemoryEventStore()
+	ctx := context.Background()
+
+	version, err := db.AppendToStream(ctx, "s-1", -1, event("A"), event("B"))
+	if err != nil || version != 1 {
+		t.Fatalf("got version %d, %v", version, err)
+	}
+	if _, err := db.AppendToStream(ctx, "s-1", -1, event("C")); !errors.Is(err, esdb.ErrWrongExpectedStreamRevision) {
+		t.Fatalf("got %v, want %v", err, esdb.ErrWrongExpectedStreamRevision)
+	}
+	if version, err := db.AppendToStream(ctx, "s-1", 1, event("C")); err != nil || version != 2 {
+		t.Fatalf("got version %d, %v", version, err)
+	}
+
+	events, err := db.ReadEvents(ctx, "s-1", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := eventTypes(events); !equal(got, []string{"B", "C"}) {
+		t.Fatalf("read %v", got)
+	}
+	if events[0].GetVersion() != 1 || events[0].GetTimeStamp().IsZero() {
+		t.Fatalf("event %+v", events[0])
+	}
+
+	if err := db.Exists(ctx, "s-1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Exists(ctx, "s-2"); !errors.Is(err, esdb.ErrStreamNotFound) {
+		t.Fatalf("got %v, want %v", err, esdb.ErrStreamNotFound)
+	}
+}
+
+func TestReadAllAndListStreams(t *testing.T) {
+	db := store.NewMemoryEventStore()
+	ctx := context.Background()
+	appendEvents(t, db, "wallet-2", "A")
+	appendEvents(t, db, "other-1", "B")
+	appendEvents(t, db, "wallet-1", "C")
+	appendEvents(t, db, "wallet-2", "D")
+
+	all, err := db.ReadAll(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := eventTypes(all); !equal(got, []string{"A", "B", "C", "D"}) {
+		t.Fatalf("read %v", got)
+	}
+	if head, err := db.HeadPosition(ctx); err != nil || head != 3 {
+		t.Fatalf("head %d, %v", head, err)
+	}
+
+	streams, err := db.ListStreams(ctx, "wallet-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !equal(streams, []string{"wallet-2", "wallet-1"}) {
+		t.Fatalf("listed %v", streams)
+	}
+	if ids := db.StreamIDs(); !equal(ids, []string{"other-1", "wallet-1", "wallet-2"}) {
+		t.Fatalf("stream ids %v", ids)
+	}
+}
+
+func TestSaveAndLoadAggregate(t *testing.T) {
+	db := store.NewMemoryEventStore()
+	ctx := context.Background()
+
+	wallet := aggregate.NewWalletAggregateWithID("w-1")
+	if err := wallet.CreateWallet(ctx, decimal.NewFromInt(100), "opening", "user-1", "account-1", "w-1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Save(ctx, wallet); err != nil {
+		t.Fatal(err)
+	}
+	if len(wallet.GetUncommittedEvents()) != 0 {
+		t.Fatalf("%d uncommitted events after save", len(wallet.GetUncommittedEvents()))
+	}
+
+	first, err := aggregate.LoadWalletAggregate(ctx, db, "w-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := aggregate.LoadWalletAggregate(ctx, db, "w-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !first.Wallet.Balance.Equal(decimal.NewFromInt(100)) || first.GetVersion() != 0 {
+		t.Fatalf("loaded balance %s at version %d", first.Wallet.Balance, first.GetVersion())
+	}
+
+	if err := first.CreditWallet(ctx, "w-2", decimal.NewFromInt(5), "top up"); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Save(ctx, first); err != nil {
+		t.Fatal(err)
+	}
+	if err := second.CreditWallet(ctx, "w-2", decimal.NewFromInt(7), "top up"); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Save(ctx, second); !errors.Is(err, esdb.ErrWrongExpectedStreamRevision) {
+		t.Fatalf("got %v, want %v", err, esdb.ErrWrongExpectedStreamRevision)
+	}
+}
+
+func TestSnapshots(t *testing.T) {
+	db := store.NewMemoryEventStore()
+	ctx := context.Background()
+
+	if snapshot, err := db.LoadSnapshot(ctx, "s-1", nil); err != nil || snapshot != nil {
+		t.Fatalf("loaded %+v, %v", snapshot, err)
+	}
+	for _, version := range []int64{2, 5, 8} {
+		if err := db.SaveSnapshot(ctx, store.Snapshot{StreamID: "s-1", Version: version, State: []byte(`{}`)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	latest, err := db.LoadSnapshot(ctx, "s-1", nil)
+	if err != nil || latest.Version != 8 {
+		t.Fatalf("loaded %+v, %v", latest, err)
+	}
+	before, err := db.LoadSnapshot(ctx, "s-1", func(s store.Snapshot) bool { return s.Version <= 6 })
+	if err != nil || before.Version != 5 {
+		t.Fatalf("loaded %+v, %v", before, err)
+	}
+	if none, err := db.LoadSnapshot(ctx, "s-1", func(s store.Snapshot) bool { return s.Version < 2 }); err != nil || none != nil {
+		t.Fatalf("loaded %+v, %v", none, err)
+	}
+}
@@ -0,0 +1,54 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"github.com/EventStore/EventStore-Client-Go/esdb"
+	es "github.com/novabankapp/common.data/eventstore"
+)
+
+// EventReader reads the raw events of a stream, for callers that need more control than
+// eventstore.AggregateStore.Load gives them.
+type EventReader interface {
+	// ReadEvents returns the events of a stream with a version >= fromVersion, in order.
+	// A stream that does not exist yields no events.
+	ReadEvents(ctx context.Context, streamID string, fromVersion int64) ([]es.Event, error)
+}
+
+// StreamLister lists the ids of the streams that start with prefix, in the order they were
+// first written to.
+type StreamLister interface {
+	ListStreams(ctx context.Context, prefix string) ([]string, error)
+}
+
+// Snapshot is the serialized state of an aggregate after the event with Version. Subject
+// is the owner of the personal data in State; it is not stored.
+type Snapshot struct {
+	StreamID  string    `json:"stream_id"`
+	Version   int64     `json:"version"`
+	Timestamp time.Time `json:"timestamp"`
+	State     []byte    `json:"state"`
+	Subject   string    `json:"-"`
+}
+
+type SnapshotStore interface {
+	SaveSnapshot(ctx context.Context, snapshot Snapshot) error
+	// LoadSnapshot returns the newest snapshot of the stream accepted by accept, or nil
+	// when there is none.
+	LoadSnapshot(ctx context.Context, streamID string, accept func(Snapshot) bool) (*Snapshot, error)
+}
+
+// PersistentSubscription is the consumer side of an EventStoreDB persistent subscription.
+// *esdb.PersistentSubscription implements it, and so does the in-memory store.
+type PersistentSubscription interface {
+	Recv() *esdb.SubscriptionEvent
+	Ack(messages ...*esdb.ResolvedEvent) error
+	Nack(reason string, action esdb.Nack_Action, messages ...*esdb.ResolvedEvent) error
+}
+
+// HeadReader reports how far the event store has got: the commit position of the last
+// event in $all, or 0 when there are no events. Projection lag is measured against it.
+type HeadReader interface {
+	HeadPosition(ctx context.Context) (uint64, error)
+}
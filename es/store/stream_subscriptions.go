@@ -0,0 +1,68 @@
+package store
+
+import (
+	"context"
+
+	"github.com/EventStore/EventStore-Client-Go/esdb"
+	"github.com/pkg/errors"
+)
+
+const (
+	// FromStreamStart as afterVersion replays the whole stream before following it.
+	FromStreamStart int64 = -1
+	// FromStreamEnd as afterVersion follows only events appended after subscribing.
+	FromStreamEnd int64 = -2
+)
+
+// StreamSubscription follows one stream. *esdb.Subscription implements it.
+type StreamSubscription interface {
+	Recv() *esdb.SubscriptionEvent
+	Close() error
+}
+
+// StreamSubscriber starts catch-up subscriptions on a single stream.
+type StreamSubscriber interface {
+	// SubscribeToStreamAfter delivers the events of streamID with a version greater than
+	// afterVersion, then follows new appends. See FromStreamStart and FromStreamEnd.
+	SubscribeToStreamAfter(ctx context.Context, streamID string, afterVersion int64) (StreamSubscription, error)
+}
+
+type esdbStreamSubscriber struct {
+	db *esdb.Client
+}
+
+func NewESDBStreamSubscriber(db *esdb.Client) StreamSubscriber {
+	return &esdbStreamSubscriber{db: db}
+}
+
+func (s *esdbStreamSubscriber) SubscribeToStreamAfter(ctx context.Context, streamID string, afterVersion int64) (StreamSubscription, error) {
+	opts := esdb.SubscribeToStreamOptions{}
+	switch {
+	case afterVersion == FromStreamEnd:
+		opts.From = esdb.End{}
+	case afterVersion < 0:
+		opts.From = esdb.Start{}
+	default:
+		// a subscription starting at a revision delivers the events after it
+		opts.From = esdb.Revision(uint64(afterVersion))
+	}
+	sub, err := s.db.SubscribeToStream(ctx, streamID, opts)
+	if err != nil {
+		return nil, errors.Wrap(err, "db.SubscribeToStream")
+	}
+	return sub, nil
+}
+
+// SubscribeToStreamAfter implements StreamSubscriber.
+func (m *MemoryEventStore) SubscribeToStreamAfter(ctx context.Context, streamID string, afterVersion int64) (StreamSubscription, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	from := uint64(0)
+	switch {
+	case afterVersion == FromStreamEnd:
+		from = uint64(len(m.streams[streamID]))
+	case afterVersion >= 0:
+		from = uint64(afterVersion + 1)
+	}
+	return m.SubscribeToStream(ctx, streamID, from), nil
+}
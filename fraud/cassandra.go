@@ -0,0 +1,78 @@
+package fraud
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/novabankapp/wallet.data/es/pii"
+	"github.com/pkg/errors"
+	"github.com/scylladb/gocqlx/v2"
+)
+
+const FlagsTable = "fraud_flags"
+
+// CassandraReviewStore keeps flags in the table created by the migrations package,
+// partitioned by wallet.
+type CassandraReviewStore struct {
+	session gocqlx.Session
+}
+
+var _ ReviewStore = (*CassandraReviewStore)(nil)
+
+func NewCassandraReviewStore(session gocqlx.Session) *CassandraReviewStore {
+	return &CassandraReviewStore{session: session}
+}
+
+func (s *CassandraReviewStore) SaveFlag(ctx context.Context, f Flag) error {
+	stmt := fmt.Sprintf("INSERT INTO %s (wallet_id, id, rule, kind, action, detail, stage, source, correlation_id, at) "+
+		"VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)", FlagsTable)
+	err := s.session.Session.Query(stmt,
+		f.WalletID, f.ID, f.Rule, f.Kind, string(f.Action), f.Detail, string(f.Stage), f.Source, f.CorrelationID, f.At,
+	).WithContext(ctx).Exec()
+	if err != nil {
+		return errors.Wrap(err, "Query.Exec")
+	}
+	return nil
+}
+
+func (s *CassandraReviewStore) ListFlags(ctx context.Context, walletID string) ([]Flag, error) {
+	stmt := fmt.Sprintf("SELECT wallet_id, id, rule, kind, action, detail, stage, source, correlation_id, at FROM %s WHERE wallet_id = ?", FlagsTable)
+	iter := s.session.Session.Query(stmt, walletID).WithContext(ctx).Iter()
+	var result []Flag
+	var f Flag
+	var action, stage string
+	for iter.Scan(&f.WalletID, &f.ID, &f.Rule, &f.Kind, &action, &f.Detail, &stage, &f.Source, &f.CorrelationID, &f.At) {
+		f.Action, f.Stage = Action(action), Stage(stage)
+		result = append(result, f)
+		f = Flag{}
+	}
+	if err := iter.Close(); err != nil {
+		return nil, errors.Wrap(err, "Iter.Close")
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if !result[i].At.Equal(result[j].At) {
+			return result[i].At.Before(result[j].At)
+		}
+		return result[i].ID < result[j].ID
+	})
+	return result, nil
+}
+
+// ErasePersonalData redacts the details of the flags of the given wallets, one partition
+// per wallet.
+func (s *CassandraReviewStore) ErasePersonalData(ctx context.Context, userID string, walletIDs []string) error {
+	stmt := fmt.Sprintf("UPDATE %s SET detail = ? WHERE wallet_id = ? AND id = ?", FlagsTable)
+	for _, walletID := range walletIDs {
+		flags, err := s.ListFlags(ctx, walletID)
+		if err != nil {
+			return err
+		}
+		for _, f := range flags {
+			if err := s.session.Session.Query(stmt, pii.Redacted, walletID, f.ID).WithContext(ctx).Exec(); err != nil {
+				return errors.Wrap(err, "Query.Exec")
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,323 @@
+package fraud
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/EventStore/EventStore-Client-Go/esdb"
+	"github.com/google/uuid"
+	es "github.com/novabankapp/common.data/eventstore"
+	"github.com/novabankapp/common.data/logger"
+	"github.com/novabankapp/wallet.data/constants"
+	"github.com/novabankapp/wallet.data/es/aggregate"
+	"github.com/novabankapp/wallet.data/es/commands"
+	v1 "github.com/novabankapp/wallet.data/es/events/v1"
+	"github.com/novabankapp/wallet.data/es/metadata"
+	"github.com/novabankapp/wallet.data/es/store"
+	"github.com/novabankapp/wallet.data/tracing"
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+const ProjectionName = "(Fraud Rules)"
+
+// Hit is a rule that matched.
+type Hit struct {
+	Rule   string `json:"rule"`
+	Kind   string `json:"kind"`
+	Action Action `json:"action"`
+	Lock   bool   `json:"lock,omitempty"`
+	Detail string `json:"detail"`
+}
+
+// Decision is the outcome of evaluating a transaction: the strongest action of the rules
+// that matched, ActionAllow when none did.
+type Decision struct {
+	Action Action `json:"action"`
+	Hits   []Hit  `json:"hits,omitempty"`
+}
+
+// Lock reports whether a matching rule asks for the wallet to be locked.
+func (d Decision) Lock() bool {
+	for _, hit := range d.Hits {
+		if hit.Lock {
+			return true
+		}
+	}
+	return false
+}
+
+func (d Decision) String() string {
+	rules := make([]string, 0, len(d.Hits))
+	for _, hit := range d.Hits {
+		rules = append(rules, hit.Rule+": "+hit.Detail)
+	}
+	return strings.Join(rules, "; ")
+}
+
+// Engine evaluates the debits and credits of wallets against its rules. A wallet's history
+// is replayed from Events for every evaluation, so it is as current as the event store.
+type Engine struct {
+	mu     sync.RWMutex
+	rules  []Rule
+	Events store.EventReader
+	// Reviews receives a Flag for every flagging or blocking match.
+	Reviews ReviewStore
+	// Locker locks the wallet when a matching rule asks for it; such matches are only
+	// flagged without it.
+	Locker    Locker
+	GroupName string
+	Log       logger.Logger
+	Now       func() time.Time
+}
+
+// NewEngine returns an engine with rules from ParseRules or LoadRules.
+func NewEngine(rules []Rule, events store.EventReader, reviews ReviewStore, locker Locker) *Engine {
+	return &Engine{rules: rules, Events: events, Reviews: reviews, Locker: locker, Now: time.Now}
+}
+
+// SetRules replaces the rules, e.g. once the rules file changed, while the engine runs.
+// The rules must come from ParseRules or LoadRules.
+func (e *Engine) SetRules(rules []Rule) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.rules = rules
+}
+
+// Evaluate runs the rules of a stage against a transaction of a wallet with the given
+// history.
+func (e *Engine) Evaluate(stage Stage, h History, tx Transaction) Decision {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	decision := Decision{Action: ActionAllow}
+	for i := range e.rules {
+		rule := &e.rules[i]
+		if rule.Stage != stage {
+			continue
+		}
+		detail, matched := rule.match(rule, h, tx)
+		if !matched {
+			continue
+		}
+		decision.Hits = append(decision.Hits, Hit{Rule: rule.Name, Kind: rule.Kind, Action: rule.Action, Lock: rule.Lock, Detail: detail})
+		if rule.Action.strength() > decision.Action.strength() {
+			decision.Action = rule.Action
+		}
+	}
+	return decision
+}
+
+// Middleware evaluates debit and credit commands before they are handled. Blocked
+// commands fail with an error matching commands.ErrBlocked. It belongs after the
+// authorization middleware, so that refused principals cannot probe the rules.
+func (e *Engine) Middleware() commands.Middleware {
+	return func(next commands.HandlerFunc) commands.HandlerFunc {
+		return func(ctx context.Context, cmd commands.Command) (*commands.Result, error) {
+			tx, ok := commandTransaction(cmd, e.now())
+			if !ok || cmd.Validate() != nil {
+				return next(ctx, cmd)
+			}
+			decision, err := e.screenCommand(ctx, cmd, tx)
+			if err != nil {
+				return nil, err
+			}
+			if decision.Action == ActionBlock {
+				return nil, errors.Wrapf(commands.ErrBlocked, "%s: %s", cmd.CommandName(), decision)
+			}
+			return next(ctx, cmd)
+		}
+	}
+}
+
+func (e *Engine) screenCommand(ctx context.Context, cmd commands.Command, tx Transaction) (Decision, error) {
+	ctx, span := tracing.StartSpan(ctx, "FraudEngine.screenCommand")
+	defer span.End()
+	span.SetAttributes(attribute.String(constants.WalletID, cmd.WalletID()))
+
+	h, err := e.history(ctx, cmd.WalletID(), "")
+	if err != nil {
+		tracing.TraceErr(span, err)
+		return Decision{}, err
+	}
+	decision := e.Evaluate(StageCommand, h, tx)
+	span.SetAttributes(attribute.String("decision", string(decision.Action)))
+	m, _ := metadata.FromContext(ctx)
+	if err := e.enforce(ctx, cmd.WalletID(), decision, StageCommand, cmd.CommandName(), uuid.New().String(), m.CorrelationID); err != nil {
+		tracing.TraceErr(span, err)
+		return Decision{}, err
+	}
+	return decision, nil
+}
+
+// Run evaluates the events of a persistent subscription. An event is acked once its flags
+// and locks are done; if they fail the event is retried by the group.
+func (e *Engine) Run(ctx context.Context, stream store.PersistentSubscription, workerID int) error {
+	for {
+		event := stream.Recv()
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+
+		if event.SubscriptionDropped != nil {
+			e.Log.Errorf("(SubscriptionDropped) err: {%v}", event.SubscriptionDropped.Error)
+			return errors.Wrap(event.SubscriptionDropped.Error, "Subscription Dropped")
+		}
+
+		if event.EventAppeared != nil {
+			e.Log.ProjectionEvent(ProjectionName, e.GroupName, event.EventAppeared, workerID)
+
+			if _, err := e.Observe(ctx, es.NewEventFromRecorded(event.EventAppeared.Event)); err != nil {
+				e.Log.Errorf("(Engine.Observe) err: {%v}", err)
+				if err := stream.Nack(err.Error(), esdb.Nack_Retry, event.EventAppeared); err != nil {
+					e.Log.Errorf("(stream.Nack) err: {%v}", err)
+					return errors.Wrap(err, "stream.Nack")
+				}
+				continue
+			}
+
+			if err := stream.Ack(event.EventAppeared); err != nil {
+				e.Log.Errorf("(stream.Ack) err: {%v}", err)
+				return errors.Wrap(err, "stream.Ack")
+			}
+		}
+	}
+}
+
+// Observe evaluates a debit or credit event against the rules of StageEvent, with the
+// history of the wallet up to the event. Other events are ignored.
+func (e *Engine) Observe(ctx context.Context, evt es.Event) (Decision, error) {
+	ctx, span := tracing.StartEventSpan(ctx, "FraudEngine.Observe", evt)
+	defer span.End()
+
+	if !strings.HasPrefix(evt.GetAggregateID(), aggregate.GetWalletStreamID("")) {
+		return Decision{Action: ActionAllow}, nil
+	}
+	tx, ok, err := eventTransaction(evt)
+	if err != nil {
+		tracing.TraceErr(span, err)
+		return Decision{}, err
+	}
+	if !ok {
+		return Decision{Action: ActionAllow}, nil
+	}
+	walletID := aggregate.GetWalletAggregateID(evt.GetAggregateID())
+	h, err := e.history(ctx, walletID, evt.GetEventID())
+	if err != nil {
+		tracing.TraceErr(span, err)
+		return Decision{}, err
+	}
+	decision := e.Evaluate(StageEvent, h, tx)
+	span.SetAttributes(attribute.String("decision", string(decision.Action)))
+	ctx = metadata.WithMetadata(ctx, metadata.CausedBy(evt, DefaultActorID))
+	if err := e.enforce(ctx, walletID, decision, StageEvent, evt.GetEventID(), evt.GetEventID(), metadata.FromEvent(evt).CorrelationID); err != nil {
+		tracing.TraceErr(span, err)
+		return Decision{}, err
+	}
+	return decision, nil
+}
+
+// enforce files a flag for every flagging or blocking hit and locks the wallet when a hit
+// asks for it. Flag ids derive from flagID and the rule, so enforcing twice is harmless.
+func (e *Engine) enforce(ctx context.Context, walletID string, decision Decision, stage Stage, source, flagID, correlationID string) error {
+	at := e.now()
+	for _, hit := range decision.Hits {
+		if hit.Action == ActionAllow || e.Reviews == nil {
+			continue
+		}
+		f := Flag{
+			ID:            flagID + "/" + hit.Rule,
+			WalletID:      walletID,
+			Rule:          hit.Rule,
+			Kind:          hit.Kind,
+			Action:        hit.Action,
+			Detail:        hit.Detail,
+			Stage:         stage,
+			Source:        source,
+			CorrelationID: correlationID,
+			At:            at,
+		}
+		if err := e.Reviews.SaveFlag(ctx, f); err != nil {
+			return errors.Wrap(err, "SaveFlag")
+		}
+	}
+	if !decision.Lock() || e.Locker == nil {
+		return nil
+	}
+	reason := LockReason{Code: LockReasonCode, Stage: stage, Source: source}
+	for _, hit := range decision.Hits {
+		if hit.Lock {
+			reason.Hits = append(reason.Hits, hit)
+		}
+	}
+	if err := e.Locker.LockWallet(ctx, walletID, reason); err != nil {
+		return errors.Wrap(err, "LockWallet")
+	}
+	return nil
+}
+
+// history replays a wallet's stream up to, but not including, the event with id stopAt,
+// or to its end when stopAt is empty.
+func (e *Engine) history(ctx context.Context, walletID, stopAt string) (History, error) {
+	wallet := aggregate.NewWalletAggregateWithID(walletID)
+	events, err := e.Events.ReadEvents(ctx, wallet.GetID(), 0)
+	if err != nil {
+		return History{}, errors.Wrap(err, "ReadEvents")
+	}
+	for _, evt := range events {
+		if stopAt != "" && evt.GetEventID() == stopAt {
+			break
+		}
+		if err := wallet.RaiseEvent(evt); err != nil {
+			return History{}, errors.Wrapf(err, "RaiseEvent %s", evt.GetEventID())
+		}
+	}
+
+	h := History{Balance: wallet.Wallet.AvailableBalance, Transactions: make([]Transaction, 0, len(*wallet.WalletTransactions))}
+	for _, tx := range *wallet.WalletTransactions {
+		past := Transaction{Direction: Credit, Amount: tx.Amount, Counterparty: tx.DebitWalletId, At: tx.CreatedAt}
+		if tx.DebitWalletId == walletID {
+			past.Direction, past.Counterparty = Debit, tx.CreditWalletId
+		}
+		h.Transactions = append(h.Transactions, past)
+	}
+	return h, nil
+}
+
+func commandTransaction(cmd commands.Command, at time.Time) (Transaction, bool) {
+	switch c := cmd.(type) {
+	case commands.DebitWalletCommand:
+		return Transaction{Direction: Debit, Amount: c.Amount, Counterparty: c.CreditWalletID, At: at}, true
+	case commands.CreditWalletCommand:
+		return Transaction{Direction: Credit, Amount: c.Amount, Counterparty: c.DebitWalletID, At: at}, true
+	}
+	return Transaction{}, false
+}
+
+func eventTransaction(evt es.Event) (Transaction, bool, error) {
+	switch evt.GetEventType() {
+	case v1.WalletDebited:
+		var data v1.WalletDebitedEvent
+		if err := evt.GetJsonData(&data); err != nil {
+			return Transaction{}, false, errors.Wrap(err, "GetJsonData")
+		}
+		return Transaction{Direction: Debit, Amount: data.Amount, Counterparty: data.CreditWalletId, At: evt.GetTimeStamp()}, true, nil
+	case v1.WalletCredited:
+		var data v1.WalletCreditedEvent
+		if err := evt.GetJsonData(&data); err != nil {
+			return Transaction{}, false, errors.Wrap(err, "GetJsonData")
+		}
+		return Transaction{Direction: Credit, Amount: data.Amount, Counterparty: data.DebitWalletId, At: evt.GetTimeStamp()}, true, nil
+	}
+	return Transaction{}, false, nil
+}
+
+func (e *Engine) now() time.Time {
+	if e.Now == nil {
+		return time.Now()
+	}
+	return e.Now()
+}
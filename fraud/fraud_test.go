@@ -0,0 +1,293 @@
+package fraud_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/novabankapp/wallet.data/es/aggregate"
+	"github.com/novabankapp/wallet.data/es/commands"
+	v1 "github.com/novabankapp/wallet.data/es/events/v1"
+	"github.com/novabankapp/wallet.data/es/metadata"
+	"github.com/novabankapp/wallet.data/es/store"
+	"github.com/novabankapp/wallet.data/fraud"
+	"github.com/pkg/errors"
+	"github.com/shopspring/decimal"
+)
+
+const walletID = "w-1"
+
+func amount(value string) decimal.Decimal {
+	return decimal.RequireFromString(value)
+}
+
+func parse(t *testing.T, rules string) []fraud.Rule {
+	t.Helper()
+	parsed, err := fraud.ParseRules([]byte(rules))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return parsed
+}
+
+func TestParseRules(t *testing.T) {
+	rules := parse(t, `
+rules:
+  - name: velocity
+    kind: debit_velocity
+    max: 3
+    window: 10m
+    action: block
+    lock: true
+  - name: drain
+    kind: credit_then_full_debit
+    window: 5m
+    ratio: "0.9"
+    stage: event
+    action: flag
+`)
+	if len(rules) != 2 || rules[0].Window != 10*time.Minute || rules[0].Stage != fraud.StageCommand || rules[1].Stage != fraud.StageEvent {
+		t.Fatalf("parsed %+v", rules)
+	}
+
+	invalid := map[string]string{
+		"unknown kind":      "rules: [{name: a, kind: odd, action: flag}]",
+		"unknown action":    "rules: [{name: a, kind: debit_velocity, max: 1, window: 1m, action: deny}]",
+		"missing max":       "rules: [{name: a, kind: debit_velocity, window: 1m, action: flag}]",
+		"missing window":    "rules: [{name: a, kind: new_counterparty_burst, max: 2, action: flag}]",
+		"bad amount":        "rules: [{name: a, kind: first_transaction_above, amount: lots, action: flag}]",
+		"ratio above one":   "rules: [{name: a, kind: credit_then_full_debit, window: 1m, ratio: '1.5', action: flag}]",
+		"unknown field":     "rules: [{name: a, kind: debit_velocity, max: 1, window: 1m, action: flag, limit: 2}]",
+		"duplicate name":    "rules: [{name: a, kind: first_transaction_above, amount: '1', action: flag}, {name: a, kind: first_transaction_above, amount: '2', action: flag}]",
+		"rule without name": "rules: [{kind: first_transaction_above, amount: '1', action: flag}]",
+	}
+	for name, file := range invalid {
+		t.Run(name, func(t *testing.T) {
+			if _, err := fraud.ParseRules([]byte(file)); !errors.Is(err, fraud.ErrInvalidRule) {
+				t.Fatalf("got %v, want %v", err, fraud.ErrInvalidRule)
+			}
+		})
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	now := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	ago := func(d time.Duration) time.Time { return now.Add(-d) }
+	engine := fraud.NewEngine(parse(t, `
+rules:
+  - {name: velocity, kind: debit_velocity, max: 2, window: 10m, action: block}
+  - {name: first-large, kind: first_transaction_above, amount: "500", action: flag}
+  - {name: new-counterparties, kind: new_counterparty_burst, max: 2, window: 1h, action: flag}
+  - {name: drain, kind: credit_then_full_debit, window: 5m, action: flag}
+  - {name: watch, kind: first_transaction_above, amount: "100", action: allow}
+`), nil, nil, nil)
+
+	tests := []struct {
+		name   string
+		h      fraud.History
+		tx     fraud.Transaction
+		action fraud.Action
+		rules  []string
+	}{
+		{
+			name:   "quiet wallet",
+			h:      fraud.History{Balance: amount("50"), Transactions: []fraud.Transaction{{Direction: fraud.Credit, Amount: amount("50"), Counterparty: "w-9", At: ago(time.Hour * 2)}}},
+			tx:     fraud.Transaction{Direction: fraud.Debit, Amount: amount("10"), Counterparty: "w-9", At: now},
+			action: fraud.ActionAllow,
+		},
+		{
+			name: "third debit in ten minutes",
+			h: fraud.History{Balance: amount("100"), Transactions: []fraud.Transaction{
+				{Direction: fraud.Debit, Amount: amount("1"), Counterparty: "w-2", At: ago(20 * time.Minute)},
+				{Direction: fraud.Debit, Amount: amount("1"), Counterparty: "w-2", At: ago(9 * time.Minute)},
+				{Direction: fraud.Debit, Amount: amount("1"), Counterparty: "w-2", At: ago(time.Minute)},
+			}},
+			tx:     fraud.Transaction{Direction: fraud.Debit, Amount: amount("1"), Counterparty: "w-2", At: now},
+			action: fraud.ActionBlock,
+			rules:  []string{"velocity"},
+		},
+		{
+			name:   "large first transaction is flagged and watched",
+			h:      fraud.History{Balance: amount("0")},
+			tx:     fraud.Transaction{Direction: fraud.Credit, Amount: amount("800"), Counterparty: "w-2", At: now},
+			action: fraud.ActionFlag,
+			rules:  []string{"first-large", "watch"},
+		},
+		{
+			name:   "small first transaction is only watched",
+			h:      fraud.History{Balance: amount("0")},
+			tx:     fraud.Transaction{Direction: fraud.Credit, Amount: amount("200"), Counterparty: "w-2", At: now},
+			action: fraud.ActionAllow,
+			rules:  []string{"watch"},
+		},
+		{
+			name: "third new counterparty in an hour",
+			h: fraud.History{Balance: amount("100"), Transactions: []fraud.Transaction{
+				{Direction: fraud.Credit, Amount: amount("100"), Counterparty: "w-old", At: ago(48 * time.Hour)},
+				{Direction: fraud.Debit, Amount: amount("1"), Counterparty: "w-a", At: ago(30 * time.Minute)},
+				{Direction: fraud.Debit, Amount: amount("1"), Counterparty: "w-old", At: ago(20 * time.Minute)},
+				{Direction: fraud.Credit, Amount: amount("1"), Counterparty: "w-b", At: ago(15 * time.Minute)},
+			}},
+			tx:     fraud.Transaction{Direction: fraud.Debit, Amount: amount("1"), Counterparty: "w-c", At: now},
+			action: fraud.ActionFlag,
+			rules:  []string{"new-counterparties"},
+		},
+		{
+			name: "full debit right after a credit",
+			h: fraud.History{Balance: amount("300"), Transactions: []fraud.Transaction{
+				{Direction: fraud.Credit, Amount: amount("300"), Counterparty: "w-a", At: ago(2 * time.Minute)},
+			}},
+			tx:     fraud.Transaction{Direction: fraud.Debit, Amount: amount("300"), Counterparty: "w-old", At: now},
+			action: fraud.ActionFlag,
+			rules:  []string{"drain"},
+		},
+		{
+			name: "full debit long after a credit",
+			h: fraud.History{Balance: amount("300"), Transactions: []fraud.Transaction{
+				{Direction: fraud.Credit, Amount: amount("300"), Counterparty: "w-a", At: ago(time.Hour)},
+			}},
+			tx:     fraud.Transaction{Direction: fraud.Debit, Amount: amount("300"), Counterparty: "w-a", At: now},
+			action: fraud.ActionAllow,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decision := engine.Evaluate(fraud.StageCommand, tt.h, tt.tx)
+			if decision.Action != tt.action || len(decision.Hits) != len(tt.rules) {
+				t.Fatalf("decision %+v, want %s by %v", decision, tt.action, tt.rules)
+			}
+			for i, rule := range tt.rules {
+				if decision.Hits[i].Rule != rule {
+					t.Fatalf("hits %+v, want %v", decision.Hits, tt.rules)
+				}
+			}
+		})
+	}
+}
+
+type fixture struct {
+	db      *store.MemoryEventStore
+	bus     *commands.Bus
+	engine  *fraud.Engine
+	reviews *fraud.MemoryReviewStore
+}
+
+func newFixture(t *testing.T, rules string) *fixture {
+	t.Helper()
+	db := store.NewMemoryEventStore()
+	reviews := fraud.NewMemoryReviewStore()
+	engine := fraud.NewEngine(parse(t, rules), db, reviews, nil)
+	bus := commands.NewBus(commands.Causation(), engine.Middleware())
+	commands.RegisterWalletHandlers(bus, aggregate.NewWalletCommandExecutor(db))
+	engine.Locker = fraud.NewBusLocker(bus)
+	f := &fixture{db: db, bus: bus, engine: engine, reviews: reviews}
+	f.dispatch(t, commands.CreateWalletCommand{ID: walletID, Amount: amount("100"), Description: "opening", UserID: "user-1", AccountID: "account-1"})
+	return f
+}
+
+func (f *fixture) dispatch(t *testing.T, cmd commands.Command) {
+	t.Helper()
+	if _, err := f.bus.Dispatch(context.Background(), cmd); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func (f *fixture) wallet(t *testing.T) *aggregate.WalletAggregate {
+	t.Helper()
+	wallet, err := aggregate.LoadWalletAggregate(context.Background(), f.db, walletID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return wallet
+}
+
+func TestMiddlewareBlocksAndLocks(t *testing.T) {
+	f := newFixture(t, `
+rules:
+  - {name: velocity, kind: debit_velocity, max: 2, window: 1h, action: block, lock: true}
+`)
+	debit := commands.DebitWalletCommand{ID: walletID, CreditWalletID: "w-2", Amount: amount("10"), Description: "payment"}
+	f.dispatch(t, debit)
+	f.dispatch(t, debit)
+
+	_, err := f.bus.Dispatch(context.Background(), debit)
+	if !errors.Is(err, commands.ErrBlocked) || commands.OutcomeOf(err) != commands.OutcomeRejected {
+		t.Fatalf("got %v, want %v", err, commands.ErrBlocked)
+	}
+	wallet := f.wallet(t)
+	if !wallet.WalletState.IsLocked || !wallet.Wallet.Balance.Equal(amount("80")) {
+		t.Fatalf("locked %v, balance %s", wallet.WalletState.IsLocked, wallet.Wallet.Balance)
+	}
+
+	events, err := f.db.ReadEvents(context.Background(), aggregate.GetWalletStreamID(walletID), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	locked := events[len(events)-1]
+	var data v1.WalletLockedEvent
+	if err := locked.GetJsonData(&data); err != nil {
+		t.Fatal(err)
+	}
+	var reason fraud.LockReason
+	if err := json.Unmarshal([]byte(data.Description), &reason); err != nil {
+		t.Fatal(err)
+	}
+	if reason.Code != fraud.LockReasonCode || reason.Source != commands.DebitWallet || len(reason.Hits) != 1 || reason.Hits[0].Rule != "velocity" {
+		t.Fatalf("lock reason %+v", reason)
+	}
+	if m := metadata.FromEvent(locked); m.ActorID != fraud.DefaultActorID || m.ActorType != metadata.ActorSystem {
+		t.Fatalf("lock metadata %+v", m)
+	}
+
+	flags, err := f.reviews.ListFlags(context.Background(), walletID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(flags) != 1 || flags[0].Action != fraud.ActionBlock || flags[0].Stage != fraud.StageCommand {
+		t.Fatalf("flags %+v", flags)
+	}
+	if _, err := f.bus.Dispatch(context.Background(), debit); !errors.Is(err, commands.ErrBlocked) {
+		t.Fatalf("got %v after the lock, want %v", err, commands.ErrBlocked)
+	}
+}
+
+func TestObserveFlagsEvents(t *testing.T) {
+	f := newFixture(t, `
+rules:
+  - {name: drain, kind: credit_then_full_debit, window: 1h, stage: event, action: block, lock: true}
+`)
+	f.dispatch(t, commands.CreditWalletCommand{ID: walletID, DebitWalletID: "w-2", Amount: amount("400"), Description: "top up"})
+	f.dispatch(t, commands.DebitWalletCommand{ID: walletID, CreditWalletID: "w-3", Amount: amount("500"), Description: "cash out"})
+
+	events, err := f.db.ReadEvents(context.Background(), aggregate.GetWalletStreamID(walletID), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	actions := make([]fraud.Action, 0, len(events))
+	for _, evt := range events {
+		decision, err := f.engine.Observe(context.Background(), evt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		actions = append(actions, decision.Action)
+	}
+	if actions[1] != fraud.ActionAllow || actions[2] != fraud.ActionBlock {
+		t.Fatalf("actions %v", actions)
+	}
+	if !f.wallet(t).WalletState.IsLocked {
+		t.Fatal("wallet not locked")
+	}
+
+	// Observing a redelivered event files no second flag.
+	if _, err := f.engine.Observe(context.Background(), events[2]); err != nil {
+		t.Fatal(err)
+	}
+	flags, err := f.reviews.ListFlags(context.Background(), walletID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(flags) != 1 || flags[0].Source != events[2].GetEventID() || flags[0].Stage != fraud.StageEvent {
+		t.Fatalf("flags %+v", flags)
+	}
+}
@@ -0,0 +1,71 @@
+package fraud
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/novabankapp/wallet.data/domain"
+	"github.com/novabankapp/wallet.data/es/aggregate"
+	"github.com/novabankapp/wallet.data/es/commands"
+	"github.com/novabankapp/wallet.data/es/metadata"
+	"github.com/pkg/errors"
+)
+
+// LockReasonCode marks the locks asked for by the fraud rules.
+const LockReasonCode = "fraud_rule"
+
+// LockReason is why the engine locks a wallet. It is sent as the description of the
+// LockWallet command, encoded as JSON, with the reason code domain.ReasonFraudSuspected.
+type LockReason struct {
+	Code   string `json:"code"`
+	Stage  Stage  `json:"stage"`
+	Source string `json:"source"`
+	Hits   []Hit  `json:"hits"`
+}
+
+func (r LockReason) String() string {
+	data, _ := json.Marshal(r)
+	return string(data)
+}
+
+// Locker locks wallets. Locking a wallet that is already locked succeeds.
+type Locker interface {
+	LockWallet(ctx context.Context, walletID string, reason LockReason) error
+}
+
+// DefaultActorID is the actor of the commands the engine sends.
+const DefaultActorID = "fraud-engine"
+
+// BusLocker locks wallets by dispatching LockWallet commands as Principal, so that the
+// bus authorizes, records and counts them like any other command.
+type BusLocker struct {
+	Bus       *commands.Bus
+	Principal commands.Principal
+}
+
+func NewBusLocker(bus *commands.Bus, roles ...string) *BusLocker {
+	return &BusLocker{Bus: bus, Principal: commands.Principal{ID: DefaultActorID, Roles: roles, Type: metadata.ActorSystem}}
+}
+
+// LockWallet sends the lock in the correlation of ctx, naming its causation as the cause.
+func (l *BusLocker) LockWallet(ctx context.Context, walletID string, reason LockReason) error {
+	cause, _ := metadata.FromContext(ctx)
+	ctx = metadata.WithMetadata(ctx, metadata.Metadata{
+		ActorID:       l.Principal.ID,
+		ActorType:     metadata.ActorSystem,
+		Channel:       metadata.ChannelInternal,
+		CorrelationID: cause.CorrelationID,
+		CausationID:   cause.CausationID,
+	})
+	ctx = commands.WithPrincipal(ctx, l.Principal)
+	_, err := l.Bus.Dispatch(ctx, commands.LockWalletCommand{
+		ID:          walletID,
+		Description: reason.String(),
+		ReasonCode:  domain.ReasonFraudSuspected,
+		Authority:   l.Principal.ID,
+	})
+	if err != nil && !errors.Is(err, aggregate.ErrWalletLocked) {
+		return errors.Wrap(err, "Dispatch")
+	}
+	return nil
+}
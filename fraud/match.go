@@ -0,0 +1,103 @@
+package fraud
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// Direction tells debits from credits.
+type Direction string
+
+const (
+	Debit  Direction = "debit"
+	Credit Direction = "credit"
+)
+
+// Transaction is a debit or credit of a wallet, seen from the wallet.
+type Transaction struct {
+	Direction    Direction
+	Amount       decimal.Decimal
+	Counterparty string
+	At           time.Time
+}
+
+// History is what a wallet did before the transaction being evaluated.
+type History struct {
+	// Transactions are oldest first.
+	Transactions []Transaction
+	// Balance is the available balance before the transaction.
+	Balance decimal.Decimal
+}
+
+// matchFunc reports whether the transaction matches the rule, and why.
+type matchFunc func(r *Rule, h History, tx Transaction) (string, bool)
+
+func matchDebitVelocity(r *Rule, h History, tx Transaction) (string, bool) {
+	if tx.Direction != Debit {
+		return "", false
+	}
+	since := tx.At.Add(-r.Window)
+	debits := 1
+	for _, past := range h.Transactions {
+		if past.Direction == Debit && past.At.After(since) {
+			debits++
+		}
+	}
+	if debits <= r.Max {
+		return "", false
+	}
+	return fmt.Sprintf("%d debits within %s, more than %d", debits, r.Window, r.Max), true
+}
+
+func matchFirstTransactionAbove(r *Rule, h History, tx Transaction) (string, bool) {
+	if len(h.Transactions) > 0 || !tx.Amount.GreaterThan(r.amount) {
+		return "", false
+	}
+	return fmt.Sprintf("first transaction of %s is above %s", tx.Amount, r.amount), true
+}
+
+func matchNewCounterpartyBurst(r *Rule, h History, tx Transaction) (string, bool) {
+	if tx.Counterparty == "" {
+		return "", false
+	}
+	since := tx.At.Add(-r.Window)
+	known := make(map[string]bool)
+	for _, past := range h.Transactions {
+		if !past.At.After(since) {
+			known[past.Counterparty] = true
+		}
+	}
+	if known[tx.Counterparty] {
+		return "", false
+	}
+	fresh := map[string]bool{tx.Counterparty: true}
+	for _, past := range h.Transactions {
+		if past.At.After(since) && past.Counterparty != "" && !known[past.Counterparty] {
+			fresh[past.Counterparty] = true
+		}
+	}
+	if len(fresh) <= r.Max {
+		return "", false
+	}
+	return fmt.Sprintf("%d new counterparties within %s, more than %d", len(fresh), r.Window, r.Max), true
+}
+
+func matchCreditThenDrain(r *Rule, h History, tx Transaction) (string, bool) {
+	if tx.Direction != Debit || !h.Balance.IsPositive() {
+		return "", false
+	}
+	since := tx.At.Add(-r.Window)
+	credited := false
+	for _, past := range h.Transactions {
+		if past.Direction == Credit && past.At.After(since) {
+			credited = true
+		}
+	}
+	if !credited || tx.Amount.LessThan(h.Balance.Mul(r.ratio)) {
+		return "", false
+	}
+	return fmt.Sprintf("debit of %s takes %s%% of the balance within %s of a credit",
+		tx.Amount, tx.Amount.Div(h.Balance).Mul(decimal.NewFromInt(100)).StringFixed(0), r.Window), true
+}
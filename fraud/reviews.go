@@ -0,0 +1,87 @@
+package fraud
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/novabankapp/wallet.data/es/pii"
+)
+
+// Flag asks for a review of a transaction a rule flagged or blocked.
+type Flag struct {
+	ID       string `json:"id"`
+	WalletID string `json:"wallet_id"`
+	Rule     string `json:"rule"`
+	Kind     string `json:"kind"`
+	Action   Action `json:"action"`
+	Detail   string `json:"detail"`
+	Stage    Stage  `json:"stage"`
+	// Source is the command name on StageCommand and the event id on StageEvent.
+	Source        string    `json:"source"`
+	CorrelationID string    `json:"correlation_id,omitempty"`
+	At            time.Time `json:"at"`
+}
+
+type ReviewStore interface {
+	// SaveFlag stores f, replacing a flag with the same id, which makes flagging a
+	// redelivered event harmless.
+	SaveFlag(ctx context.Context, f Flag) error
+	// ListFlags returns the flags of a wallet, oldest first.
+	ListFlags(ctx context.Context, walletID string) ([]Flag, error)
+}
+
+// MemoryReviewStore is an in-memory ReviewStore for tests and local runs; use
+// CassandraReviewStore to keep flags across restarts.
+type MemoryReviewStore struct {
+	mu    sync.Mutex
+	flags map[string]Flag
+}
+
+func NewMemoryReviewStore() *MemoryReviewStore {
+	return &MemoryReviewStore{flags: make(map[string]Flag)}
+}
+
+func (m *MemoryReviewStore) SaveFlag(ctx context.Context, f Flag) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.flags[f.ID] = f
+	return nil
+}
+
+func (m *MemoryReviewStore) ListFlags(ctx context.Context, walletID string) ([]Flag, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var result []Flag
+	for _, f := range m.flags {
+		if f.WalletID == walletID {
+			result = append(result, f)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if !result[i].At.Equal(result[j].At) {
+			return result[i].At.Before(result[j].At)
+		}
+		return result[i].ID < result[j].ID
+	})
+	return result, nil
+}
+
+// ErasePersonalData redacts the details of the flags of the given wallets, which describe
+// the owner's transactions.
+func (m *MemoryReviewStore) ErasePersonalData(ctx context.Context, userID string, walletIDs []string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	wallets := make(map[string]bool, len(walletIDs))
+	for _, walletID := range walletIDs {
+		wallets[walletID] = true
+	}
+	for id, f := range m.flags {
+		if wallets[f.WalletID] && f.Detail != "" {
+			f.Detail = pii.Redacted
+			m.flags[id] = f
+		}
+	}
+	return nil
+}
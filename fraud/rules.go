@@ -0,0 +1,188 @@
+// Package fraud evaluates wallet debits and credits against declarative rules, such as
+// too many debits in a short window. A matching rule lets the transaction through, flags
+// it for review or blocks it, and can lock the wallet. Rules run on commands, before they
+// are handled, or on events, after the fact.
+package fraud
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/shopspring/decimal"
+	"gopkg.in/yaml.v2"
+)
+
+// Action is what a matching rule asks for. When several rules match, the strongest
+// action wins.
+type Action string
+
+const (
+	// ActionAllow only reports the match in the Decision, e.g. to try a new rule out.
+	ActionAllow Action = "allow"
+	ActionFlag  Action = "flag"
+	// ActionBlock refuses a command; on events, which cannot be refused, it flags.
+	ActionBlock Action = "block"
+)
+
+func (a Action) strength() int {
+	switch a {
+	case ActionFlag:
+		return 1
+	case ActionBlock:
+		return 2
+	}
+	return 0
+}
+
+// Stage is where a rule runs.
+type Stage string
+
+const (
+	StageCommand Stage = "command"
+	StageEvent   Stage = "event"
+)
+
+// Kinds of rules.
+const (
+	// KindDebitVelocity matches when a wallet makes more than Max debits within Window.
+	KindDebitVelocity = "debit_velocity"
+	// KindFirstTransactionAbove matches the first debit or credit of a wallet when it is
+	// above Amount.
+	KindFirstTransactionAbove = "first_transaction_above"
+	// KindNewCounterpartyBurst matches when a wallet deals with more than Max counterparties
+	// within Window that it had not dealt with before.
+	KindNewCounterpartyBurst = "new_counterparty_burst"
+	// KindCreditThenDrain matches a debit taking at least Ratio (1 by default) of the
+	// available balance within Window of a credit.
+	KindCreditThenDrain = "credit_then_full_debit"
+)
+
+var ErrInvalidRule = errors.New("invalid fraud rule")
+
+// Rule is one rule of a rules file:
+//
+//	rules:
+//	  - name: debit-velocity
+//	    kind: debit_velocity
+//	    max: 5
+//	    window: 10m
+//	    action: block
+//	    lock: true
+//	  - name: large-first-transaction
+//	    kind: first_transaction_above
+//	    amount: "1000"
+//	    action: flag
+//	  - name: drained-after-credit
+//	    kind: credit_then_full_debit
+//	    window: 5m
+//	    stage: event
+//	    action: flag
+//	    lock: true
+type Rule struct {
+	Name string `yaml:"name"`
+	Kind string `yaml:"kind"`
+	// Stage is StageCommand when empty.
+	Stage  Stage  `yaml:"stage"`
+	Action Action `yaml:"action"`
+	// Lock locks the wallet when the rule matches.
+	Lock   bool          `yaml:"lock"`
+	Max    int           `yaml:"max"`
+	Window time.Duration `yaml:"window"`
+	Amount string        `yaml:"amount"`
+	Ratio  string        `yaml:"ratio"`
+
+	amount decimal.Decimal
+	ratio  decimal.Decimal
+	match  matchFunc
+}
+
+type rulesFile struct {
+	Rules []Rule `yaml:"rules"`
+}
+
+// LoadRules reads the rules file at path.
+func LoadRules(path string) ([]Rule, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "os.ReadFile")
+	}
+	return ParseRules(data)
+}
+
+// ParseRules parses and checks a rules file. Unknown fields are refused, so that a typo
+// does not quietly disable part of a rule.
+func ParseRules(data []byte) ([]Rule, error) {
+	var file rulesFile
+	if err := yaml.UnmarshalStrict(data, &file); err != nil {
+		return nil, errors.Wrap(ErrInvalidRule, err.Error())
+	}
+	names := make(map[string]bool)
+	for i := range file.Rules {
+		rule := &file.Rules[i]
+		if err := rule.compile(); err != nil {
+			return nil, err
+		}
+		if names[rule.Name] {
+			return nil, errors.Wrapf(ErrInvalidRule, "%s: duplicate name", rule.Name)
+		}
+		names[rule.Name] = true
+	}
+	return file.Rules, nil
+}
+
+func (r *Rule) compile() error {
+	invalid := func(format string, args ...interface{}) error {
+		return errors.Wrapf(ErrInvalidRule, "%s: %s", r.Name, fmt.Sprintf(format, args...))
+	}
+	if r.Name == "" {
+		return errors.Wrap(ErrInvalidRule, "rule without a name")
+	}
+	switch r.Stage {
+	case "":
+		r.Stage = StageCommand
+	case StageCommand, StageEvent:
+	default:
+		return invalid("unknown stage %q", r.Stage)
+	}
+	switch r.Action {
+	case ActionAllow, ActionFlag, ActionBlock:
+	default:
+		return invalid("unknown action %q", r.Action)
+	}
+
+	needsMax := false
+	needsWindow := false
+	switch r.Kind {
+	case KindDebitVelocity:
+		needsMax, needsWindow, r.match = true, true, matchDebitVelocity
+	case KindFirstTransactionAbove:
+		amount, err := decimal.NewFromString(r.Amount)
+		if err != nil || amount.IsNegative() {
+			return invalid("amount must be a non-negative decimal")
+		}
+		r.amount, r.match = amount, matchFirstTransactionAbove
+	case KindNewCounterpartyBurst:
+		needsMax, needsWindow, r.match = true, true, matchNewCounterpartyBurst
+	case KindCreditThenDrain:
+		needsWindow, r.match = true, matchCreditThenDrain
+		r.ratio = decimal.NewFromInt(1)
+		if r.Ratio != "" {
+			ratio, err := decimal.NewFromString(r.Ratio)
+			if err != nil || !ratio.IsPositive() || ratio.GreaterThan(decimal.NewFromInt(1)) {
+				return invalid("ratio must be a decimal in (0, 1]")
+			}
+			r.ratio = ratio
+		}
+	default:
+		return invalid("unknown kind %q", r.Kind)
+	}
+	if needsMax && r.Max <= 0 {
+		return invalid("max must be positive")
+	}
+	if needsWindow && r.Window <= 0 {
+		return invalid("window must be positive")
+	}
+	return nil
+}
@@ -0,0 +1,254 @@
+package gdpr
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/novabankapp/wallet.data/domain"
+	"github.com/pkg/errors"
+)
+
+const (
+	// FormatVersion is the version of the archive layout, recorded in the manifest.
+	FormatVersion = 1
+
+	ManifestFile     = "manifest.json"
+	DataFile         = "data.json"
+	WalletsFile      = "wallets.csv"
+	EventsFile       = "events.csv"
+	StateHistoryFile = "state_history.csv"
+	TransactionsFile = "transactions.csv"
+	LinksFile        = "links.csv"
+)
+
+var ErrChecksumMismatch = errors.New("archive file does not match its manifest checksum")
+
+// Manifest describes an archive. It is the last file written, and lists every other file
+// with its SHA-256.
+type Manifest struct {
+	FormatVersion int            `json:"format_version"`
+	UserID        string         `json:"user_id"`
+	GeneratedAt   time.Time      `json:"generated_at"`
+	Sources       []Source       `json:"sources"`
+	Wallets       []string       `json:"wallets"`
+	Files         []ArchivedFile `json:"files"`
+}
+
+type ArchivedFile struct {
+	Name   string `json:"name"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+	// Records is the number of rows of a CSV file, without the header.
+	Records int `json:"records,omitempty"`
+}
+
+// WriteArchive writes the export as a zip archive: data.json with the whole export, a CSV
+// file per kind of record and the manifest. It returns the hex SHA-256 of the archive.
+// The same export always yields the same bytes, and so the same checksum.
+func WriteArchive(w io.Writer, export *Export) (string, error) {
+	hash := sha256.New()
+	archive := zip.NewWriter(io.MultiWriter(w, hash))
+	manifest := Manifest{
+		FormatVersion: FormatVersion,
+		UserID:        export.UserID,
+		GeneratedAt:   export.GeneratedAt,
+		Sources:       export.Sources,
+		Wallets:       make([]string, 0, len(export.Wallets)),
+		Files:         make([]ArchivedFile, 0, 6),
+	}
+	for _, wallet := range export.Wallets {
+		manifest.Wallets = append(manifest.Wallets, wallet.WalletID)
+	}
+
+	data, err := json.MarshalIndent(export, "", "  ")
+	if err != nil {
+		return "", errors.Wrap(err, "json.Marshal")
+	}
+	files := []archiveFile{{name: DataFile, content: data}}
+	for _, table := range csvTables(export) {
+		content, err := table.encode()
+		if err != nil {
+			return "", errors.Wrapf(err, "encode %s", table.name)
+		}
+		files = append(files, archiveFile{name: table.name, content: content, records: len(table.rows)})
+	}
+
+	for _, file := range files {
+		if err := writeFile(archive, file.name, export.GeneratedAt, file.content); err != nil {
+			return "", err
+		}
+		sum := sha256.Sum256(file.content)
+		manifest.Files = append(manifest.Files, ArchivedFile{
+			Name:    file.name,
+			Size:    int64(len(file.content)),
+			SHA256:  hex.EncodeToString(sum[:]),
+			Records: file.records,
+		})
+	}
+	content, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return "", errors.Wrap(err, "json.Marshal")
+	}
+	if err := writeFile(archive, ManifestFile, export.GeneratedAt, content); err != nil {
+		return "", err
+	}
+	if err := archive.Close(); err != nil {
+		return "", errors.Wrap(err, "zip.Close")
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+type archiveFile struct {
+	name    string
+	content []byte
+	records int
+}
+
+func writeFile(archive *zip.Writer, name string, modified time.Time, content []byte) error {
+	f, err := archive.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: modified})
+	if err != nil {
+		return errors.Wrapf(err, "zip.Create %s", name)
+	}
+	if _, err := f.Write(content); err != nil {
+		return errors.Wrapf(err, "write %s", name)
+	}
+	return nil
+}
+
+// VerifyArchive reads the manifest of an archive and checks every file it lists against
+// its checksum.
+func VerifyArchive(r io.ReaderAt, size int64) (*Manifest, error) {
+	archive, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, errors.Wrap(err, "zip.NewReader")
+	}
+	content, err := readFile(archive, ManifestFile)
+	if err != nil {
+		return nil, err
+	}
+	var manifest Manifest
+	if err := json.Unmarshal(content, &manifest); err != nil {
+		return nil, errors.Wrap(err, "json.Unmarshal")
+	}
+	for _, file := range manifest.Files {
+		content, err := readFile(archive, file.Name)
+		if err != nil {
+			return nil, err
+		}
+		sum := sha256.Sum256(content)
+		if hex.EncodeToString(sum[:]) != file.SHA256 || int64(len(content)) != file.Size {
+			return nil, errors.Wrap(ErrChecksumMismatch, file.Name)
+		}
+	}
+	return &manifest, nil
+}
+
+func readFile(archive *zip.Reader, name string) ([]byte, error) {
+	f, err := archive.Open(name)
+	if err != nil {
+		return nil, errors.Wrapf(err, "open %s", name)
+	}
+	defer f.Close()
+	content, err := io.ReadAll(f)
+	if err != nil {
+		return nil, errors.Wrapf(err, "read %s", name)
+	}
+	return content, nil
+}
+
+type csvTable struct {
+	name   string
+	header []string
+	rows   [][]string
+}
+
+func (t *csvTable) add(row ...string) {
+	t.rows = append(t.rows, row)
+}
+
+func (t *csvTable) encode() ([]byte, error) {
+	var buf bytes.Buffer
+	writer := csv.NewWriter(&buf)
+	if err := writer.Write(t.header); err != nil {
+		return nil, err
+	}
+	if err := writer.WriteAll(t.rows); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func csvTables(export *Export) []*csvTable {
+	wallets := &csvTable{name: WalletsFile, header: []string{"source", "wallet_id", "user_id", "account_id", "created_at",
+		"balance", "available_balance", "is_locked", "is_blacklisted", "is_deleted", "version"}}
+	events := &csvTable{name: EventsFile, header: []string{"wallet_id", "version", "event_id", "event_type", "timestamp", "data", "metadata"}}
+	history := &csvTable{name: StateHistoryFile, header: []string{"wallet_id", "version", "event_type", "timestamp",
+		"balance", "available_balance", "is_locked", "is_blacklisted", "is_deleted"}}
+	transactions := &csvTable{name: TransactionsFile, header: []string{"source", "wallet_id", "transaction_id", "created_at",
+		"debit_wallet_id", "credit_wallet_id", "amount", "description"}}
+	links := &csvTable{name: LinksFile, header: []string{"wallet_id", "link_id", "value", "link_date"}}
+
+	for _, w := range export.Wallets {
+		if w.Wallet != nil {
+			wallets.add(walletRow(SourceEventStore, w.Wallet, w.State, strconv.FormatInt(w.Version, 10))...)
+		}
+		if w.ReadModel != nil {
+			wallets.add(walletRow(SourceReadModel, w.ReadModel.Wallet, w.ReadModel.State, "")...)
+		}
+		for _, evt := range w.Events {
+			events.add(w.WalletID, strconv.FormatInt(evt.Version, 10), evt.ID, evt.Type, timestamp(evt.Timestamp), string(evt.Data), string(evt.Metadata))
+		}
+		for _, change := range w.StateHistory {
+			history.add(w.WalletID, strconv.FormatInt(change.Version, 10), change.EventType, timestamp(change.Timestamp),
+				change.Balance.String(), change.AvailableBalance.String(),
+				strconv.FormatBool(change.IsLocked), strconv.FormatBool(change.IsBlacklisted), strconv.FormatBool(change.IsDeleted))
+		}
+		for _, tx := range w.Transactions {
+			transactions.add(transactionRow(SourceEventStore, w.WalletID, tx)...)
+		}
+		if w.ReadModel != nil {
+			for _, tx := range w.ReadModel.Transactions {
+				transactions.add(transactionRow(SourceReadModel, w.WalletID, tx)...)
+			}
+		}
+		for _, link := range w.Links {
+			links.add(w.WalletID, link.ID.String(), link.Value, timestamp(link.LinkDate))
+		}
+	}
+	return []*csvTable{wallets, events, history, transactions, links}
+}
+
+func walletRow(source Source, wallet *domain.Wallet, state *domain.WalletState, version string) []string {
+	if state == nil {
+		state = &domain.WalletState{}
+	}
+	return []string{string(source), wallet.ID, wallet.UserId, wallet.AccountId, timestamp(wallet.CreatedAt),
+		wallet.Balance.String(), wallet.AvailableBalance.String(),
+		strconv.FormatBool(state.IsLocked), strconv.FormatBool(state.IsBlacklisted), strconv.FormatBool(state.IsDeleted), version}
+}
+
+func transactionRow(source Source, walletID string, tx domain.WalletTransaction) []string {
+	return []string{string(source), walletID, tx.ID.String(), timestamp(tx.CreatedAt),
+		tx.DebitWalletId, tx.CreditWalletId, tx.Amount.String(), tx.Description}
+}
+
+func timestamp(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
+// ChecksumLine formats a checksum returned by WriteArchive the way sha256sum does, so the
+// recipient can check the archive with sha256sum -c.
+func ChecksumLine(checksum, fileName string) string {
+	return fmt.Sprintf("%s  %s\n", checksum, fileName)
+}
@@ -0,0 +1,339 @@
+// Package gdpr answers subject access requests: it collects everything held about the
+// wallets of a user, from the event store and from the Cassandra read model, and writes it
+// as an archive of JSON and CSV files with a manifest of checksums.
+package gdpr
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	es "github.com/novabankapp/common.data/eventstore"
+	"github.com/novabankapp/wallet.data/constants"
+	"github.com/novabankapp/wallet.data/domain"
+	"github.com/novabankapp/wallet.data/es/aggregate"
+	v1 "github.com/novabankapp/wallet.data/es/events/v1"
+	"github.com/novabankapp/wallet.data/es/models"
+	"github.com/novabankapp/wallet.data/es/pii"
+	"github.com/novabankapp/wallet.data/es/store"
+	"github.com/novabankapp/wallet.data/tracing"
+	"github.com/pkg/errors"
+	"github.com/shopspring/decimal"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// Source names where a part of the export comes from.
+type Source string
+
+const (
+	SourceEventStore Source = "event_store"
+	SourceReadModel  Source = "read_model"
+)
+
+var (
+	ErrUserRequired = errors.New("subject access export needs a user id")
+	ErrNoSource     = errors.New("subject access export needs the event store or the read model")
+)
+
+// ReadModel is the part of the Cassandra read model the export reads.
+type ReadModel interface {
+	GetByUser(ctx context.Context, userID string) ([]models.WalletProjection, error)
+}
+
+// LinkReader reads the links of a wallet.
+type LinkReader interface {
+	GetByWallet(ctx context.Context, walletID string) ([]domain.WalletLink, error)
+}
+
+// Export is everything held about the wallets of one user.
+type Export struct {
+	UserID      string         `json:"user_id"`
+	GeneratedAt time.Time      `json:"generated_at"`
+	Sources     []Source       `json:"sources"`
+	Wallets     []WalletExport `json:"wallets"`
+}
+
+// WalletExport is one wallet. Wallet, State, Events, StateHistory and Transactions come
+// from the event store; ReadModel is the wallet's row in the Cassandra read model.
+type WalletExport struct {
+	WalletID     string                     `json:"wallet_id"`
+	Wallet       *domain.Wallet             `json:"wallet,omitempty"`
+	State        *domain.WalletState        `json:"state,omitempty"`
+	Version      int64                      `json:"version"`
+	Events       []Event                    `json:"events"`
+	StateHistory []StateChange              `json:"state_history"`
+	Transactions []domain.WalletTransaction `json:"transactions"`
+	Links        []domain.WalletLink        `json:"links"`
+	ReadModel    *ReadModelRow              `json:"read_model,omitempty"`
+}
+
+// Event is a stored event with its payload and metadata as JSON.
+type Event struct {
+	ID        string          `json:"id"`
+	Type      string          `json:"type"`
+	Version   int64           `json:"version"`
+	Timestamp time.Time       `json:"timestamp"`
+	Data      json.RawMessage `json:"data,omitempty"`
+	Metadata  json.RawMessage `json:"metadata,omitempty"`
+}
+
+// StateChange is the state of a wallet right after one of its events.
+type StateChange struct {
+	Version          int64           `json:"version"`
+	EventType        string          `json:"event_type"`
+	Timestamp        time.Time       `json:"timestamp"`
+	Balance          decimal.Decimal `json:"balance"`
+	AvailableBalance decimal.Decimal `json:"available_balance"`
+	IsLocked         bool            `json:"is_locked"`
+	IsBlacklisted    bool            `json:"is_blacklisted"`
+	IsDeleted        bool            `json:"is_deleted"`
+}
+
+type ReadModelRow struct {
+	Wallet       *domain.Wallet             `json:"wallet"`
+	State        *domain.WalletState        `json:"state"`
+	Transactions []domain.WalletTransaction `json:"transactions"`
+}
+
+// Exporter collects exports. Events and ReadModel are both optional, but one is needed.
+type Exporter struct {
+	Events store.EventReader
+	// Streams finds wallets in the event store the read model may not know yet, by reading
+	// the creation event of every wallet. Without it the wallets are found in ReadModel only.
+	Streams   store.StreamLister
+	ReadModel ReadModel
+	Links     LinkReader
+	// PersonalData decrypts the events and link values when they are encrypted.
+	PersonalData *pii.Cipher
+	Now          func() time.Time
+}
+
+func NewExporter(events store.EventReader, readModel ReadModel) *Exporter {
+	return &Exporter{Events: events, ReadModel: readModel, Now: time.Now}
+}
+
+// Collect gathers the export of a user. Wallets the event store shows were created for
+// another user are left out, whatever the read model says.
+func (e *Exporter) Collect(ctx context.Context, userID string) (*Export, error) {
+	ctx, span := tracing.StartSpan(ctx, "Exporter.Collect")
+	defer span.End()
+	span.SetAttributes(attribute.String(constants.UserID, userID))
+
+	if userID == "" {
+		return nil, ErrUserRequired
+	}
+	if e.Events == nil && e.ReadModel == nil {
+		return nil, ErrNoSource
+	}
+
+	export := &Export{UserID: userID, GeneratedAt: e.Now().UTC(), Sources: make([]Source, 0, 2), Wallets: make([]WalletExport, 0)}
+	walletIDs := make([]string, 0)
+	rows := make(map[string]models.WalletProjection)
+	if e.Events != nil {
+		export.Sources = append(export.Sources, SourceEventStore)
+	}
+	if e.ReadModel != nil {
+		export.Sources = append(export.Sources, SourceReadModel)
+		found, err := e.ReadModel.GetByUser(ctx, userID)
+		if err != nil {
+			tracing.TraceErr(span, err)
+			return nil, errors.Wrap(err, "ReadModel.GetByUser")
+		}
+		for _, row := range found {
+			if _, ok := rows[row.WalletID]; !ok {
+				walletIDs = append(walletIDs, row.WalletID)
+			}
+			rows[row.WalletID] = row
+		}
+	}
+	if e.Events != nil && e.Streams != nil {
+		found, err := e.findWallets(ctx, userID)
+		if err != nil {
+			tracing.TraceErr(span, err)
+			return nil, err
+		}
+		for _, walletID := range found {
+			if _, ok := rows[walletID]; !ok {
+				walletIDs = append(walletIDs, walletID)
+			}
+		}
+	}
+
+	for _, walletID := range walletIDs {
+		wallet, owned, err := e.collectWallet(ctx, userID, walletID)
+		if err != nil {
+			tracing.TraceErr(span, err)
+			return nil, errors.Wrapf(err, "wallet %s", walletID)
+		}
+		if !owned {
+			continue
+		}
+		if row, ok := rows[walletID]; ok {
+			if wallet.ReadModel, err = readModelRow(row); err != nil {
+				tracing.TraceErr(span, err)
+				return nil, errors.Wrapf(err, "wallet %s", walletID)
+			}
+		}
+		export.Wallets = append(export.Wallets, *wallet)
+	}
+	return export, nil
+}
+
+// findWallets returns the wallets whose creation event names the user.
+func (e *Exporter) findWallets(ctx context.Context, userID string) ([]string, error) {
+	streams, err := e.Streams.ListStreams(ctx, aggregate.GetWalletStreamID(""))
+	if err != nil {
+		return nil, errors.Wrap(err, "ListStreams")
+	}
+	walletIDs := make([]string, 0)
+	for _, streamID := range streams {
+		events, err := e.readEvents(ctx, streamID)
+		if err != nil {
+			return nil, err
+		}
+		owner, err := creator(events)
+		if err != nil {
+			return nil, errors.Wrapf(err, "stream %s", streamID)
+		}
+		if owner == userID {
+			walletIDs = append(walletIDs, aggregate.GetWalletAggregateID(streamID))
+		}
+	}
+	return walletIDs, nil
+}
+
+// collectWallet replays the wallet's stream. It reports false when the stream shows the
+// wallet belongs to someone else.
+func (e *Exporter) collectWallet(ctx context.Context, userID, walletID string) (*WalletExport, bool, error) {
+	export := &WalletExport{
+		WalletID:     walletID,
+		Events:       make([]Event, 0),
+		StateHistory: make([]StateChange, 0),
+		Transactions: make([]domain.WalletTransaction, 0),
+		Links:        make([]domain.WalletLink, 0),
+	}
+	if e.Events != nil {
+		wallet := aggregate.NewWalletAggregateWithID(walletID)
+		events, err := e.readEvents(ctx, wallet.GetID())
+		if err != nil {
+			return nil, false, err
+		}
+		for _, evt := range events {
+			if err := wallet.RaiseEvent(evt); err != nil {
+				return nil, false, errors.Wrapf(err, "RaiseEvent %s", evt.GetEventID())
+			}
+			export.Events = append(export.Events, newEvent(evt))
+			export.StateHistory = append(export.StateHistory, newStateChange(evt, wallet))
+		}
+		if len(events) > 0 {
+			if wallet.Wallet.UserId != userID {
+				return nil, false, nil
+			}
+			export.Wallet = wallet.Wallet
+			export.State = wallet.WalletState
+			export.Version = wallet.GetVersion()
+			export.Transactions = *wallet.WalletTransactions
+		}
+	}
+	if e.Links != nil {
+		links, err := e.Links.GetByWallet(ctx, walletID)
+		if err != nil {
+			return nil, false, errors.Wrap(err, "Links.GetByWallet")
+		}
+		for _, link := range links {
+			if e.PersonalData != nil {
+				if link.Value, err = e.PersonalData.Decrypt(ctx, link.Value); err != nil {
+					return nil, false, errors.Wrap(err, "Decrypt")
+				}
+			}
+			export.Links = append(export.Links, link)
+		}
+	}
+	return export, true, nil
+}
+
+func (e *Exporter) readEvents(ctx context.Context, streamID string) ([]es.Event, error) {
+	events, err := e.Events.ReadEvents(ctx, streamID, 0)
+	if err != nil {
+		return nil, errors.Wrap(err, "ReadEvents")
+	}
+	if e.PersonalData == nil {
+		return events, nil
+	}
+	for i, evt := range events {
+		if events[i], err = e.PersonalData.Reveal(ctx, evt); err != nil {
+			return nil, errors.Wrap(err, "Reveal")
+		}
+	}
+	return events, nil
+}
+
+// creator returns the user a wallet stream was created for, or "" when the stream does not
+// start with a creation event.
+func creator(events []es.Event) (string, error) {
+	if len(events) == 0 || events[0].GetEventType() != v1.WalletCreated {
+		return "", nil
+	}
+	var created v1.WalletCreatedEvent
+	if err := events[0].GetJsonData(&created); err != nil {
+		return "", errors.Wrap(err, "GetJsonData")
+	}
+	return created.UserId, nil
+}
+
+func newEvent(evt es.Event) Event {
+	return Event{
+		ID:        evt.GetEventID(),
+		Type:      evt.GetEventType(),
+		Version:   evt.GetVersion(),
+		Timestamp: evt.GetTimeStamp(),
+		Data:      rawJSON(evt.GetData()),
+		Metadata:  rawJSON(evt.GetMetadata()),
+	}
+}
+
+// rawJSON passes a JSON payload through and quotes anything else as a string.
+func rawJSON(data []byte) json.RawMessage {
+	if len(data) == 0 {
+		return nil
+	}
+	if json.Valid(data) {
+		return data
+	}
+	quoted, _ := json.Marshal(string(data))
+	return quoted
+}
+
+func newStateChange(evt es.Event, wallet *aggregate.WalletAggregate) StateChange {
+	return StateChange{
+		Version:          wallet.GetVersion(),
+		EventType:        evt.GetEventType(),
+		Timestamp:        evt.GetTimeStamp(),
+		Balance:          wallet.Wallet.Balance,
+		AvailableBalance: wallet.Wallet.AvailableBalance,
+		IsLocked:         wallet.WalletState.IsLocked,
+		IsBlacklisted:    wallet.WalletState.IsBlacklisted,
+		IsDeleted:        wallet.WalletState.IsDeleted,
+	}
+}
+
+func readModelRow(row models.WalletProjection) (*ReadModelRow, error) {
+	result := &ReadModelRow{State: &domain.WalletState{WalletId: row.WalletID}, Transactions: make([]domain.WalletTransaction, 0)}
+	var err error
+	if result.Wallet, err = aggregate.GetEntityFromJsonString[domain.Wallet](row.Wallet); err != nil {
+		return nil, errors.Wrap(err, "GetEntityFromJsonString")
+	}
+	if row.WalletState != "" {
+		if result.State, err = aggregate.GetEntityFromJsonString[domain.WalletState](row.WalletState); err != nil {
+			return nil, errors.Wrap(err, "GetEntityFromJsonString")
+		}
+	}
+	if row.WalletTransactions != "" {
+		transactions, err := aggregate.GetEntityArrayFromJsonString[domain.WalletTransaction](row.WalletTransactions)
+		if err != nil {
+			return nil, errors.Wrap(err, "GetEntityArrayFromJsonString")
+		}
+		result.Transactions = *transactions
+	}
+	return result, nil
+}
@@ -0,0 +1,199 @@
+package gdpr_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"testing"
+	"time"
+
+	"github.com/gocql/gocql"
+	"github.com/novabankapp/wallet.data/domain"
+	"github.com/novabankapp/wallet.data/es/aggregate"
+	"github.com/novabankapp/wallet.data/es/commands"
+	"github.com/novabankapp/wallet.data/es/models"
+	"github.com/novabankapp/wallet.data/es/pii"
+	"github.com/novabankapp/wallet.data/es/store"
+	"github.com/novabankapp/wallet.data/gdpr"
+	"github.com/pkg/errors"
+	"github.com/shopspring/decimal"
+)
+
+var generatedAt = time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+func amount(value string) decimal.Decimal {
+	return decimal.RequireFromString(value)
+}
+
+type readModel map[string][]models.WalletProjection
+
+func (m readModel) GetByUser(ctx context.Context, userID string) ([]models.WalletProjection, error) {
+	return m[userID], nil
+}
+
+type links map[string][]domain.WalletLink
+
+func (l links) GetByWallet(ctx context.Context, walletID string) ([]domain.WalletLink, error) {
+	return l[walletID], nil
+}
+
+type fixture struct {
+	exporter *gdpr.Exporter
+}
+
+// newFixture stores three wallets: w-1 and w-3 of user-1 and w-2 of user-2. The read model
+// knows w-1 and, wrongly, w-2 as wallets of user-1, but has not seen w-3 yet.
+func newFixture(t *testing.T) *fixture {
+	t.Helper()
+	ctx := context.Background()
+	db := store.NewMemoryEventStore()
+	cipher := pii.NewCipher(pii.NewMemoryKeyStore())
+	bus := commands.NewBus()
+	commands.RegisterWalletHandlers(bus, aggregate.NewWalletCommandExecutor(cipher.ProtectStore(db)))
+	for _, cmd := range []commands.Command{
+		commands.CreateWalletCommand{ID: "w-1", Amount: amount("100"), Description: "opening", UserID: "user-1", AccountID: "account-1"},
+		commands.CreateWalletCommand{ID: "w-2", Amount: amount("10"), Description: "opening", UserID: "user-2", AccountID: "account-2"},
+		commands.DebitWalletCommand{ID: "w-1", CreditWalletID: "w-2", Amount: amount("30"), Description: "rent"},
+		commands.LockWalletCommand{ID: "w-1", Description: "lost card"},
+		commands.CreateWalletCommand{ID: "w-3", Amount: amount("5"), Description: "savings", UserID: "user-1", AccountID: "account-1"},
+	} {
+		if _, err := bus.Dispatch(ctx, cmd); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	email, err := cipher.Encrypt(ctx, "user-1", "user-1@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	exporter := gdpr.NewExporter(db, readModel{"user-1": {row("w-1", "70"), row("w-2", "10")}})
+	exporter.Streams = db
+	exporter.Links = links{"w-1": {{WalletId: "w-1", ID: gocql.TimeUUID(), Value: email, LinkDate: generatedAt}}}
+	exporter.PersonalData = cipher
+	exporter.Now = func() time.Time { return generatedAt }
+	return &fixture{exporter: exporter}
+}
+
+func row(walletID, balance string) models.WalletProjection {
+	return models.WalletProjection{
+		ID:                 "row-" + walletID,
+		WalletID:           walletID,
+		UserID:             "user-1",
+		Wallet:             aggregate.GetJsonString(&domain.Wallet{ID: walletID, UserId: "user-1", Balance: amount(balance), AvailableBalance: amount(balance)}),
+		WalletState:        aggregate.GetJsonString(&domain.WalletState{WalletId: walletID, IsLocked: true}),
+		WalletTransactions: aggregate.GetJsonString([]domain.WalletTransaction{{DebitWalletId: walletID, CreditWalletId: "w-2", Amount: amount("30"), Description: "rent"}}),
+	}
+}
+
+func TestCollect(t *testing.T) {
+	f := newFixture(t)
+	export, err := f.exporter.Collect(context.Background(), "user-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(export.Wallets) != 2 || export.Wallets[0].WalletID != "w-1" || export.Wallets[1].WalletID != "w-3" {
+		t.Fatalf("exported wallets %+v, want w-1 and w-3", export.Wallets)
+	}
+
+	w1 := export.Wallets[0]
+	if len(w1.Events) != 3 || len(w1.StateHistory) != 3 {
+		t.Fatalf("%d events and %d states, want 3 each", len(w1.Events), len(w1.StateHistory))
+	}
+	debited := w1.StateHistory[1]
+	if !debited.Balance.Equal(amount("70")) || debited.IsLocked {
+		t.Fatalf("state after the debit %+v", debited)
+	}
+	if !w1.StateHistory[2].IsLocked || !w1.State.IsLocked {
+		t.Fatal("wallet not locked at the end of its history")
+	}
+	if len(w1.Transactions) != 1 || w1.Transactions[0].Description != "rent" {
+		t.Fatalf("transactions %+v", w1.Transactions)
+	}
+	if w1.Wallet.UserId != "user-1" || w1.Wallet.AccountId != "account-1" {
+		t.Fatalf("personal data not decrypted: %+v", w1.Wallet)
+	}
+	if len(w1.Links) != 1 || w1.Links[0].Value != "user-1@example.com" {
+		t.Fatalf("links %+v", w1.Links)
+	}
+	if w1.ReadModel == nil || !w1.ReadModel.Wallet.Balance.Equal(amount("70")) || len(w1.ReadModel.Transactions) != 1 {
+		t.Fatalf("read model %+v", w1.ReadModel)
+	}
+	if export.Wallets[1].ReadModel != nil {
+		t.Fatal("w-3 has no read model row")
+	}
+}
+
+func TestCollectNeedsUserAndSource(t *testing.T) {
+	if _, err := (&gdpr.Exporter{}).Collect(context.Background(), "user-1"); !errors.Is(err, gdpr.ErrNoSource) {
+		t.Fatalf("got %v, want %v", err, gdpr.ErrNoSource)
+	}
+	if _, err := newFixture(t).exporter.Collect(context.Background(), ""); !errors.Is(err, gdpr.ErrUserRequired) {
+		t.Fatalf("got %v, want %v", err, gdpr.ErrUserRequired)
+	}
+}
+
+func TestWriteArchive(t *testing.T) {
+	f := newFixture(t)
+	export, err := f.exporter.Collect(context.Background(), "user-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var archive bytes.Buffer
+	checksum, err := gdpr.WriteArchive(&archive, export)
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := gdpr.WriteArchive(&bytes.Buffer{}, export)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if checksum != again {
+		t.Fatalf("checksums %s and %s of the same export differ", checksum, again)
+	}
+
+	manifest, err := gdpr.VerifyArchive(bytes.NewReader(archive.Bytes()), int64(archive.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if manifest.UserID != "user-1" || len(manifest.Wallets) != 2 || !manifest.GeneratedAt.Equal(generatedAt) {
+		t.Fatalf("manifest %+v", manifest)
+	}
+	records := make(map[string]int)
+	for _, file := range manifest.Files {
+		records[file.Name] = file.Records
+	}
+	want := map[string]int{
+		gdpr.DataFile:         0,
+		gdpr.WalletsFile:      3, // w-1 from both sources, w-3 from the event store
+		gdpr.EventsFile:       4,
+		gdpr.StateHistoryFile: 4,
+		gdpr.TransactionsFile: 2,
+		gdpr.LinksFile:        1,
+	}
+	if len(records) != len(want) {
+		t.Fatalf("manifest files %v, want %v", records, want)
+	}
+	for name, n := range want {
+		if got, ok := records[name]; !ok || got != n {
+			t.Fatalf("%s has %d records, want %d", name, got, n)
+		}
+	}
+
+	reader, err := zip.NewReader(bytes.NewReader(archive.Bytes()), int64(archive.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	file, err := reader.Open(gdpr.LinksFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	rows, err := csv.NewReader(file).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rows[1][0] != "w-1" || rows[1][2] != "user-1@example.com" {
+		t.Fatalf("links.csv %v", rows)
+	}
+}
@@ -0,0 +1,51 @@
+package grpcapi
+
+import (
+	"context"
+
+	"github.com/novabankapp/wallet.data/es/aggregate"
+	"github.com/novabankapp/wallet.data/es/commands"
+	"github.com/novabankapp/wallet.data/es/queries"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ToStatus maps an error of the command bus or the queries to a gRPC status. Errors the
+// caller can act on keep their message; anything else becomes a bare Internal so that
+// internals do not leak to clients.
+func ToStatus(err error) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+	switch {
+	case errors.Is(err, context.Canceled):
+		return status.Error(codes.Canceled, err.Error())
+	case errors.Is(err, context.DeadlineExceeded):
+		return status.Error(codes.DeadlineExceeded, err.Error())
+	case errors.Is(err, queries.ErrWalletNotFound),
+		errors.Is(err, aggregate.ErrWalletNotCreated):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, queries.ErrInvalidCursor),
+		errors.Is(err, queries.ErrInvalidFilter),
+		errors.Is(err, aggregate.ErrInvalidAmount):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, aggregate.ErrWalletAlreadyCreated):
+		return status.Error(codes.AlreadyExists, err.Error())
+	}
+
+	switch commands.OutcomeOf(err) {
+	case commands.OutcomeInvalid:
+		return status.Error(codes.InvalidArgument, err.Error())
+	case commands.OutcomeUnauthorized:
+		return status.Error(codes.PermissionDenied, err.Error())
+	case commands.OutcomeRejected:
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case commands.OutcomeConflict:
+		return status.Error(codes.Aborted, err.Error())
+	}
+	return status.Error(codes.Internal, "internal error")
+}
@@ -0,0 +1,375 @@
+// Package grpcapi serves the wallet commands and read-model queries over gRPC.
+package grpcapi
+
+import (
+	"context"
+	"net"
+	"time"
+
+	es "github.com/novabankapp/common.data/eventstore"
+	"github.com/novabankapp/common.data/logger"
+	"github.com/novabankapp/wallet.data/domain"
+	"github.com/novabankapp/wallet.data/es/aggregate"
+	"github.com/novabankapp/wallet.data/es/commands"
+	eventmetadata "github.com/novabankapp/wallet.data/es/metadata"
+	"github.com/novabankapp/wallet.data/es/queries"
+	"github.com/novabankapp/wallet.data/es/store"
+	walletv1 "github.com/novabankapp/wallet.data/proto/wallet/v1"
+	"github.com/pkg/errors"
+	"github.com/shopspring/decimal"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// IdempotencyKeyHeader is the metadata key that carries the idempotency key of a command.
+const IdempotencyKeyHeader = "idempotency-key"
+
+// CorrelationIDHeader and RequestIDHeader are the metadata keys that name the correlation
+// and the request a command belongs to; they become the correlation and causation ids of
+// its events.
+const (
+	CorrelationIDHeader = "x-correlation-id"
+	RequestIDHeader     = "x-request-id"
+)
+
+type Server struct {
+	walletv1.UnimplementedWalletServiceServer
+	Bus     *commands.Bus
+	Queries *queries.WalletTransactionQueries
+	Events  store.StreamSubscriber
+	Log     logger.Logger
+}
+
+func NewServer(bus *commands.Bus, queries *queries.WalletTransactionQueries, events store.StreamSubscriber, log logger.Logger) *Server {
+	return &Server{Bus: bus, Queries: queries, Events: events, Log: log}
+}
+
+func (s *Server) Register(server *grpc.Server) {
+	walletv1.RegisterWalletServiceServer(server, s)
+}
+
+func (s *Server) dispatch(ctx context.Context, cmd commands.Command) (*walletv1.CommandResponse, error) {
+	m, _ := eventmetadata.FromContext(ctx)
+	m.Channel = eventmetadata.ChannelGRPC
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		m.ClientIP = clientIP(p.Addr)
+	}
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if keys := md.Get(IdempotencyKeyHeader); len(keys) > 0 {
+			ctx = commands.WithIdempotencyKey(ctx, keys[0])
+		}
+		if ids := md.Get(CorrelationIDHeader); len(ids) > 0 {
+			m.CorrelationID = ids[0]
+		}
+		if ids := md.Get(RequestIDHeader); len(ids) > 0 {
+			m.CausationID = ids[0]
+		}
+	}
+	ctx = eventmetadata.WithMetadata(ctx, m)
+	result, err := s.Bus.Dispatch(ctx, cmd)
+	if err != nil {
+		return nil, s.status(err)
+	}
+	return &walletv1.CommandResponse{WalletId: result.WalletID, Version: result.Version}, nil
+}
+
+// clientIP is the host of a peer address, or the whole address when it has no port.
+func clientIP(addr net.Addr) string {
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return addr.String()
+	}
+	return host
+}
+
+func (s *Server) status(err error) error {
+	st := ToStatus(err)
+	if commands.OutcomeOf(err) == commands.OutcomeFailed {
+		s.Log.Errorf("(grpcapi) err: {%v}", err)
+	}
+	return st
+}
+
+func (s *Server) CreateWallet(ctx context.Context, req *walletv1.CreateWalletRequest) (*walletv1.CommandResponse, error) {
+	amount := decimal.Zero
+	if req.GetAmount() != "" {
+		var err error
+		if amount, err = parseAmount(commands.CreateWallet, "amount", req.GetAmount()); err != nil {
+			return nil, ToStatus(err)
+		}
+	}
+	return s.dispatch(ctx, commands.CreateWalletCommand{
+		ID:          req.GetWalletId(),
+		Amount:      amount,
+		Description: req.GetDescription(),
+		UserID:      req.GetUserId(),
+		AccountID:   req.GetAccountId(),
+	})
+}
+
+func (s *Server) CreditWallet(ctx context.Context, req *walletv1.CreditWalletRequest) (*walletv1.CommandResponse, error) {
+	amount, err := parseAmount(commands.CreditWallet, "amount", req.GetAmount())
+	if err != nil {
+		return nil, ToStatus(err)
+	}
+	return s.dispatch(ctx, commands.CreditWalletCommand{
+		ID:            req.GetWalletId(),
+		DebitWalletID: req.GetDebitWalletId(),
+		Amount:        amount,
+		Description:   req.GetDescription(),
+	})
+}
+
+func (s *Server) DebitWallet(ctx context.Context, req *walletv1.DebitWalletRequest) (*walletv1.CommandResponse, error) {
+	amount, err := parseAmount(commands.DebitWallet, "amount", req.GetAmount())
+	if err != nil {
+		return nil, ToStatus(err)
+	}
+	return s.dispatch(ctx, commands.DebitWalletCommand{
+		ID:             req.GetWalletId(),
+		CreditWalletID: req.GetCreditWalletId(),
+		Amount:         amount,
+		Description:    req.GetDescription(),
+	})
+}
+
+func (s *Server) ReserveWalletCredit(ctx context.Context, req *walletv1.HoldRequest) (*walletv1.CommandResponse, error) {
+	amount, err := parseAmount(commands.ReserveWalletCredit, "amount", req.GetAmount())
+	if err != nil {
+		return nil, ToStatus(err)
+	}
+	return s.dispatch(ctx, commands.ReserveWalletCreditCommand{ID: req.GetWalletId(), Amount: amount, Description: req.GetDescription()})
+}
+
+func (s *Server) ReleaseWalletCredit(ctx context.Context, req *walletv1.HoldRequest) (*walletv1.CommandResponse, error) {
+	amount, err := parseAmount(commands.ReleaseWalletCredit, "amount", req.GetAmount())
+	if err != nil {
+		return nil, ToStatus(err)
+	}
+	return s.dispatch(ctx, commands.ReleaseWalletCreditCommand{ID: req.GetWalletId(), Amount: amount, Description: req.GetDescription()})
+}
+
+func (s *Server) LockWallet(ctx context.Context, req *walletv1.RestrictionRequest) (*walletv1.CommandResponse, error) {
+	return s.dispatch(ctx, commands.LockWalletCommand{ID: req.GetWalletId(), Description: req.GetDescription(),
+		ReasonCode: domain.ReasonCode(req.GetReasonCode()), Authority: req.GetAuthority(), Evidence: req.GetEvidence(),
+		ExpiresAt: optionalTime(req.GetExpiresAt())})
+}
+
+func (s *Server) UnlockWallet(ctx context.Context, req *walletv1.StatusChangeRequest) (*walletv1.CommandResponse, error) {
+	return s.dispatch(ctx, commands.UnlockWalletCommand{ID: req.GetWalletId(), Description: req.GetDescription()})
+}
+
+func (s *Server) BlacklistWallet(ctx context.Context, req *walletv1.RestrictionRequest) (*walletv1.CommandResponse, error) {
+	return s.dispatch(ctx, commands.BlacklistWalletCommand{ID: req.GetWalletId(), Description: req.GetDescription(),
+		ReasonCode: domain.ReasonCode(req.GetReasonCode()), Authority: req.GetAuthority(), Evidence: req.GetEvidence(),
+		ExpiresAt: optionalTime(req.GetExpiresAt())})
+}
+
+func (s *Server) UnBlacklistWallet(ctx context.Context, req *walletv1.StatusChangeRequest) (*walletv1.CommandResponse, error) {
+	return s.dispatch(ctx, commands.UnBlacklistWalletCommand{ID: req.GetWalletId(), Description: req.GetDescription()})
+}
+
+func (s *Server) DeleteWallet(ctx context.Context, req *walletv1.StatusChangeRequest) (*walletv1.CommandResponse, error) {
+	return s.dispatch(ctx, commands.DeleteWalletCommand{ID: req.GetWalletId(), Description: req.GetDescription()})
+}
+
+func (s *Server) GetWallet(ctx context.Context, req *walletv1.GetWalletRequest) (*walletv1.Wallet, error) {
+	wallet, err := s.Queries.GetWallet(ctx, req.GetWalletId())
+	if err != nil {
+		return nil, s.status(err)
+	}
+	return toWallet(wallet), nil
+}
+
+func (s *Server) GetWalletState(ctx context.Context, req *walletv1.GetWalletRequest) (*walletv1.WalletState, error) {
+	state, err := s.Queries.GetWalletState(ctx, req.GetWalletId())
+	if err != nil {
+		return nil, s.status(err)
+	}
+	restrictions := make([]*walletv1.Restriction, 0, len(state.Restrictions))
+	for _, r := range state.Restrictions {
+		restrictions = append(restrictions, &walletv1.Restriction{
+			Kind:        string(r.Kind),
+			ReasonCode:  string(r.ReasonCode),
+			Authority:   r.Authority,
+			Description: r.Description,
+			Evidence:    r.Evidence,
+			ImposedAt:   timestamp(r.ImposedAt),
+			ExpiresAt:   optionalTimestamp(r.ExpiresAt),
+		})
+	}
+	return &walletv1.WalletState{
+		WalletId:      req.GetWalletId(),
+		IsLocked:      state.IsLocked,
+		IsBlacklisted: state.IsBlacklisted,
+		IsDeleted:     state.IsDeleted,
+		Restrictions:  restrictions,
+	}, nil
+}
+
+func (s *Server) ListTransactions(ctx context.Context, req *walletv1.ListTransactionsRequest) (*walletv1.ListTransactionsResponse, error) {
+	filter, err := toFilter(req)
+	if err != nil {
+		return nil, ToStatus(err)
+	}
+	order := queries.SortNewestFirst
+	if req.GetOrder() == walletv1.SortOrder_SORT_ORDER_OLDEST_FIRST {
+		order = queries.SortOldestFirst
+	}
+	page, err := s.Queries.GetWalletTransactions(ctx, req.GetWalletId(), filter, queries.TransactionPageRequest{
+		Cursor: req.GetCursor(),
+		Limit:  int(req.GetLimit()),
+		Order:  order,
+	})
+	if err != nil {
+		return nil, s.status(err)
+	}
+	resp := &walletv1.ListTransactionsResponse{
+		Transactions: make([]*walletv1.Transaction, 0, len(page.Transactions)),
+		NextCursor:   page.NextCursor,
+	}
+	for _, tx := range page.Transactions {
+		resp.Transactions = append(resp.Transactions, toTransaction(tx))
+	}
+	return resp, nil
+}
+
+// WatchWalletEvents streams events until the client goes away or the subscription drops.
+// Response headers are sent once the subscription is in place.
+func (s *Server) WatchWalletEvents(req *walletv1.WatchWalletEventsRequest, stream walletv1.WalletService_WatchWalletEventsServer) error {
+	if req.GetWalletId() == "" {
+		return ToStatus(&commands.ValidationError{Command: "WatchWalletEvents", Field: "wallet_id", Reason: "is required"})
+	}
+	after := store.FromStreamEnd
+	if req.AfterVersion != nil {
+		after = req.GetAfterVersion()
+		if after < store.FromStreamStart {
+			after = store.FromStreamStart
+		}
+	}
+
+	ctx := stream.Context()
+	sub, err := s.Events.SubscribeToStreamAfter(ctx, aggregate.GetWalletStreamID(req.GetWalletId()), after)
+	if err != nil {
+		return s.status(err)
+	}
+	defer sub.Close()
+	// headers tell the client the subscription is live: what it writes from now on is streamed
+	if err := stream.SendHeader(metadata.MD{}); err != nil {
+		return err
+	}
+
+	for {
+		event := sub.Recv()
+		if ctx.Err() != nil {
+			return ToStatus(ctx.Err())
+		}
+		if event.SubscriptionDropped != nil {
+			return s.status(errors.Wrap(event.SubscriptionDropped.Error, "Subscription Dropped"))
+		}
+		if event.EventAppeared == nil {
+			continue
+		}
+		evt := es.NewEventFromRecorded(event.EventAppeared.Event)
+		if err := stream.Send(&walletv1.WalletEvent{
+			EventId:    evt.GetEventID(),
+			WalletId:   req.GetWalletId(),
+			EventType:  evt.GetEventType(),
+			Version:    evt.GetVersion(),
+			OccurredAt: timestamppb.New(evt.GetTimeStamp()),
+			Data:       evt.GetData(),
+		}); err != nil {
+			return err
+		}
+	}
+}
+
+func parseAmount(command, field, value string) (decimal.Decimal, error) {
+	amount, err := decimal.NewFromString(value)
+	if err != nil {
+		return decimal.Zero, &commands.ValidationError{Command: command, Field: field, Reason: "must be a decimal number"}
+	}
+	return amount, nil
+}
+
+func toFilter(req *walletv1.ListTransactionsRequest) (queries.TransactionFilter, error) {
+	filter := queries.TransactionFilter{
+		CounterpartyWalletId: req.GetCounterpartyWalletId(),
+		Description:          req.GetDescription(),
+	}
+	switch req.GetDirection() {
+	case walletv1.Direction_DIRECTION_INCOMING:
+		filter.Direction = queries.DirectionIncoming
+	case walletv1.Direction_DIRECTION_OUTGOING:
+		filter.Direction = queries.DirectionOutgoing
+	}
+	if req.From != nil {
+		from := req.GetFrom().AsTime()
+		filter.From = &from
+	}
+	if req.To != nil {
+		to := req.GetTo().AsTime()
+		filter.To = &to
+	}
+	if req.GetMinAmount() != "" {
+		min, err := decimal.NewFromString(req.GetMinAmount())
+		if err != nil {
+			return filter, errors.Wrap(queries.ErrInvalidFilter, "min_amount must be a decimal number")
+		}
+		filter.MinAmount = &min
+	}
+	if req.GetMaxAmount() != "" {
+		max, err := decimal.NewFromString(req.GetMaxAmount())
+		if err != nil {
+			return filter, errors.Wrap(queries.ErrInvalidFilter, "max_amount must be a decimal number")
+		}
+		filter.MaxAmount = &max
+	}
+	return filter, nil
+}
+
+func toWallet(wallet *domain.Wallet) *walletv1.Wallet {
+	return &walletv1.Wallet{
+		Id:               wallet.ID,
+		UserId:           wallet.UserId,
+		AccountId:        wallet.AccountId,
+		Balance:          wallet.Balance.String(),
+		AvailableBalance: wallet.AvailableBalance.String(),
+		CreatedAt:        timestamp(wallet.CreatedAt),
+	}
+}
+
+func toTransaction(tx domain.WalletTransaction) *walletv1.Transaction {
+	return &walletv1.Transaction{
+		Id:             tx.ID.String(),
+		DebitWalletId:  tx.DebitWalletId,
+		CreditWalletId: tx.CreditWalletId,
+		Amount:         tx.Amount.String(),
+		Description:    tx.Description,
+		CreatedAt:      timestamp(tx.CreatedAt),
+	}
+}
+
+func timestamp(t time.Time) *timestamppb.Timestamp {
+	if t.IsZero() {
+		return nil
+	}
+	return timestamppb.New(t)
+}
+
+func optionalTimestamp(t *time.Time) *timestamppb.Timestamp {
+	if t == nil {
+		return nil
+	}
+	return timestamppb.New(*t)
+}
+
+func optionalTime(ts *timestamppb.Timestamp) *time.Time {
+	if ts == nil {
+		return nil
+	}
+	t := ts.AsTime()
+	return &t
+}
@@ -0,0 +1,317 @@
+package grpcapi_test
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/EventStore/EventStore-Client-Go/esdb"
+	"github.com/novabankapp/wallet.data/domain"
+	"github.com/novabankapp/wallet.data/es/aggregate"
+	"github.com/novabankapp/wallet.data/es/commands"
+	v1 "github.com/novabankapp/wallet.data/es/events/v1"
+	"github.com/novabankapp/wallet.data/es/queries"
+	"github.com/novabankapp/wallet.data/es/readmodel/readmodeltest"
+	"github.com/novabankapp/wallet.data/es/store"
+	"github.com/novabankapp/wallet.data/grpcapi"
+	walletv1 "github.com/novabankapp/wallet.data/proto/wallet/v1"
+	"github.com/pkg/errors"
+	"github.com/shopspring/decimal"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const walletID = "w-1"
+
+type fixture struct {
+	db       *store.MemoryEventStore
+	spans    *tracetest.SpanRecorder
+	provider *sdktrace.TracerProvider
+	client   walletv1.WalletServiceClient
+}
+
+func newFixture(t *testing.T) *fixture {
+	t.Helper()
+	created := time.Date(2022, 3, 1, 9, 0, 0, 0, time.UTC)
+	expires := created.AddDate(0, 1, 0)
+	wallet := domain.Wallet{
+		ID: walletID, UserId: "user-1", AccountId: "account-1",
+		Balance: decimal.RequireFromString("100"), AvailableBalance: decimal.RequireFromString("80"), CreatedAt: created,
+	}
+	state := domain.WalletState{WalletId: walletID, IsLocked: true, Restrictions: []domain.Restriction{{
+		Kind: domain.RestrictionLock, ReasonCode: domain.ReasonCourtOrder, Authority: "high court", Description: "freeze",
+		Evidence: []string{"order-7"}, ImposedAt: created, ExpiresAt: &expires,
+	}}}
+	row := readmodeltest.Row(wallet, state, []domain.WalletTransaction{
+		{DebitWalletId: "w-2", CreditWalletId: walletID, Amount: decimal.RequireFromString("10"), CreatedAt: created.Add(time.Hour), Description: "first"},
+		{DebitWalletId: walletID, CreditWalletId: "w-3", Amount: decimal.RequireFromString("5"), CreatedAt: created.Add(2 * time.Hour), Description: "second"},
+	})
+
+	spans := tracetest.NewSpanRecorder()
+	f := &fixture{db: store.NewMemoryEventStore(), spans: spans, provider: sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spans))}
+	tracer := f.provider.Tracer("grpcapi_test")
+	bus := commands.NewBus(commands.Idempotency(commands.NewMemoryIdempotencyStore(time.Hour)))
+	commands.RegisterWalletHandlers(bus, aggregate.NewWalletCommandExecutor(f.db))
+	server := grpcapi.NewServer(bus, queries.NewWalletTransactionQueries(readmodeltest.New(row)), f.db, readmodeltest.NopLogger{})
+
+	listener := bufconn.Listen(1 << 20)
+	g := grpc.NewServer(
+		grpc.UnaryInterceptor(grpcapi.UnaryServerInterceptor(tracer)),
+		grpc.StreamInterceptor(grpcapi.StreamServerInterceptor(tracer)),
+	)
+	server.Register(g)
+	go func() { _ = g.Serve(listener) }()
+	t.Cleanup(g.Stop)
+
+	conn, err := grpc.DialContext(context.Background(), "bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	f.client = walletv1.NewWalletServiceClient(conn)
+	return f
+}
+
+func (f *fixture) create(t *testing.T) {
+	t.Helper()
+	if _, err := f.client.CreateWallet(context.Background(), &walletv1.CreateWalletRequest{
+		WalletId: walletID, Amount: "100", Description: "opening", UserId: "user-1", AccountId: "account-1",
+	}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func requireCode(t *testing.T, err error, code codes.Code) {
+	t.Helper()
+	if got := status.Code(err); got != code {
+		t.Fatalf("got %v (%v), want %v", got, err, code)
+	}
+}
+
+func TestCommands(t *testing.T) {
+	f := newFixture(t)
+	ctx := context.Background()
+	f.create(t)
+
+	resp, err := f.client.DebitWallet(ctx, &walletv1.DebitWalletRequest{WalletId: walletID, CreditWalletId: "w-2", Amount: "30", Description: "payment"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.GetWalletId() != walletID || resp.GetVersion() != 1 {
+		t.Fatalf("unexpected response %v", resp)
+	}
+
+	_, err = f.client.DebitWallet(ctx, &walletv1.DebitWalletRequest{WalletId: walletID, CreditWalletId: "w-2", Amount: "500", Description: "payment"})
+	requireCode(t, err, codes.FailedPrecondition)
+	_, err = f.client.DebitWallet(ctx, &walletv1.DebitWalletRequest{WalletId: walletID, CreditWalletId: "w-2", Amount: "lots", Description: "payment"})
+	requireCode(t, err, codes.InvalidArgument)
+	_, err = f.client.LockWallet(ctx, &walletv1.RestrictionRequest{WalletId: walletID})
+	requireCode(t, err, codes.InvalidArgument)
+	_, err = f.client.LockWallet(ctx, &walletv1.RestrictionRequest{WalletId: "unknown", Description: "review"})
+	requireCode(t, err, codes.NotFound)
+	_, err = f.client.CreateWallet(ctx, &walletv1.CreateWalletRequest{WalletId: walletID, UserId: "user-1", AccountId: "account-1"})
+	requireCode(t, err, codes.AlreadyExists)
+
+	_, err = f.client.LockWallet(ctx, &walletv1.RestrictionRequest{WalletId: walletID, Description: "review", ReasonCode: "bored"})
+	requireCode(t, err, codes.InvalidArgument)
+
+	expires := time.Now().Add(24 * time.Hour).UTC().Truncate(time.Second)
+	if _, err := f.client.LockWallet(ctx, &walletv1.RestrictionRequest{
+		WalletId: walletID, Description: "review", ReasonCode: string(domain.ReasonFraudSuspected),
+		Authority: "fraud team", Evidence: []string{"case-1"}, ExpiresAt: timestamppb.New(expires),
+	}); err != nil {
+		t.Fatal(err)
+	}
+	wallet, err := aggregate.LoadWalletAggregate(ctx, f.db, walletID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !wallet.Wallet.Balance.Equal(decimal.RequireFromString("70")) || !wallet.WalletState.IsLocked {
+		t.Fatalf("unexpected wallet %+v %+v", wallet.Wallet, wallet.WalletState)
+	}
+	lock := wallet.WalletState.Restriction(domain.RestrictionLock)
+	if lock == nil || lock.ReasonCode != domain.ReasonFraudSuspected || lock.Authority != "fraud team" ||
+		len(lock.Evidence) != 1 || lock.ExpiresAt == nil || !lock.ExpiresAt.Equal(expires) {
+		t.Fatalf("unexpected lock %+v", lock)
+	}
+}
+
+func TestIdempotencyKeyMetadata(t *testing.T) {
+	f := newFixture(t)
+	f.create(t)
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(), grpcapi.IdempotencyKeyHeader, "payment-1")
+	req := &walletv1.DebitWalletRequest{WalletId: walletID, CreditWalletId: "w-2", Amount: "30", Description: "payment"}
+	for i := 0; i < 3; i++ {
+		if _, err := f.client.DebitWallet(ctx, req); err != nil {
+			t.Fatal(err)
+		}
+	}
+	req.Amount = "31"
+	_, err := f.client.DebitWallet(ctx, req)
+	requireCode(t, err, codes.Aborted)
+
+	wallet, err := aggregate.LoadWalletAggregate(context.Background(), f.db, walletID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !wallet.Wallet.Balance.Equal(decimal.RequireFromString("70")) {
+		t.Fatalf("balance %s, want 70", wallet.Wallet.Balance)
+	}
+}
+
+func TestQueries(t *testing.T) {
+	f := newFixture(t)
+	ctx := context.Background()
+
+	wallet, err := f.client.GetWallet(ctx, &walletv1.GetWalletRequest{WalletId: walletID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if wallet.GetBalance() != "100" || wallet.GetAvailableBalance() != "80" || wallet.GetUserId() != "user-1" {
+		t.Fatalf("unexpected wallet %v", wallet)
+	}
+	state, err := f.client.GetWalletState(ctx, &walletv1.GetWalletRequest{WalletId: walletID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !state.GetIsLocked() || state.GetIsBlacklisted() || len(state.GetRestrictions()) != 1 {
+		t.Fatalf("unexpected state %v", state)
+	}
+	lock := state.GetRestrictions()[0]
+	if lock.GetKind() != "lock" || lock.GetReasonCode() != "court_order" || lock.GetAuthority() != "high court" ||
+		lock.GetEvidence()[0] != "order-7" || lock.GetExpiresAt().AsTime() != time.Date(2022, 4, 1, 9, 0, 0, 0, time.UTC) {
+		t.Fatalf("unexpected restriction %v", lock)
+	}
+	_, err = f.client.GetWallet(ctx, &walletv1.GetWalletRequest{WalletId: "unknown"})
+	requireCode(t, err, codes.NotFound)
+
+	page, err := f.client.ListTransactions(ctx, &walletv1.ListTransactionsRequest{WalletId: walletID, Limit: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.GetTransactions()) != 1 || page.GetTransactions()[0].GetDescription() != "second" || page.GetNextCursor() == "" {
+		t.Fatalf("unexpected first page %v", page)
+	}
+	page, err = f.client.ListTransactions(ctx, &walletv1.ListTransactionsRequest{WalletId: walletID, Limit: 1, Cursor: page.GetNextCursor()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.GetTransactions()) != 1 || page.GetTransactions()[0].GetDescription() != "first" || page.GetNextCursor() != "" {
+		t.Fatalf("unexpected last page %v", page)
+	}
+
+	incoming, err := f.client.ListTransactions(ctx, &walletv1.ListTransactionsRequest{WalletId: walletID, Direction: walletv1.Direction_DIRECTION_INCOMING})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(incoming.GetTransactions()) != 1 || incoming.GetTransactions()[0].GetAmount() != "10" {
+		t.Fatalf("unexpected incoming transactions %v", incoming)
+	}
+	_, err = f.client.ListTransactions(ctx, &walletv1.ListTransactionsRequest{WalletId: walletID, Cursor: "not a cursor"})
+	requireCode(t, err, codes.InvalidArgument)
+}
+
+func TestWatchWalletEvents(t *testing.T) {
+	f := newFixture(t)
+	f.create(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	history, err := f.client.WatchWalletEvents(ctx, &walletv1.WatchWalletEventsRequest{WalletId: walletID, AfterVersion: int64Ptr(-1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	event, err := history.Recv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if event.GetEventType() != v1.WalletCreated || event.GetVersion() != 0 || event.GetWalletId() != walletID {
+		t.Fatalf("unexpected event %v", event)
+	}
+
+	live, err := f.client.WatchWalletEvents(ctx, &walletv1.WatchWalletEventsRequest{WalletId: walletID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := live.Header(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.client.CreditWallet(ctx, &walletv1.CreditWalletRequest{WalletId: walletID, DebitWalletId: "w-2", Amount: "5", Description: "top up"}); err != nil {
+		t.Fatal(err)
+	}
+	for _, stream := range []walletv1.WalletService_WatchWalletEventsClient{history, live} {
+		event, err := stream.Recv()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if event.GetEventType() != v1.WalletCredited || event.GetVersion() != 1 {
+			t.Fatalf("unexpected event %v", event)
+		}
+	}
+}
+
+func int64Ptr(v int64) *int64 {
+	return &v
+}
+
+func TestTracingContinuesCallerSpan(t *testing.T) {
+	f := newFixture(t)
+	ctx, parent := f.provider.Tracer("caller").Start(context.Background(), "caller")
+	ctx = grpcapi.InjectClientSpan(ctx)
+
+	if _, err := f.client.GetWallet(ctx, &walletv1.GetWalletRequest{WalletId: walletID}); err != nil {
+		t.Fatal(err)
+	}
+	parent.End()
+
+	var server sdktrace.ReadOnlySpan
+	for _, span := range f.spans.Ended() {
+		if span.Name() == "/novabank.wallet.v1.WalletService/GetWallet" {
+			server = span
+		}
+	}
+	if server == nil {
+		t.Fatalf("no server span among %v", f.spans.Ended())
+	}
+	caller := parent.SpanContext()
+	if server.Parent().SpanID() != caller.SpanID() || server.SpanContext().TraceID() != caller.TraceID() {
+		t.Fatalf("server span %v is not a child of the caller span %v", server.SpanContext(), caller)
+	}
+}
+
+func TestToStatus(t *testing.T) {
+	tests := []struct {
+		err  error
+		code codes.Code
+	}{
+		{errors.Wrap(aggregate.ErrInsufficientFunds, "DebitWallet"), codes.FailedPrecondition},
+		{aggregate.ErrWalletLocked, codes.FailedPrecondition},
+		{aggregate.ErrWalletNotCreated, codes.NotFound},
+		{queries.ErrWalletNotFound, codes.NotFound},
+		{queries.ErrInvalidFilter, codes.InvalidArgument},
+		{commands.ErrUnauthorized, codes.PermissionDenied},
+		{commands.ErrCommandInProgress, codes.Aborted},
+		{errors.Wrap(esdb.ErrWrongExpectedStreamRevision, "Save"), codes.Aborted},
+		{context.DeadlineExceeded, codes.DeadlineExceeded},
+		{errors.New("cassandra: connection refused"), codes.Internal},
+	}
+	for _, tt := range tests {
+		if got := status.Code(grpcapi.ToStatus(tt.err)); got != tt.code {
+			t.Errorf("%v: got %v, want %v", tt.err, got, tt.code)
+		}
+	}
+	if st, _ := status.FromError(grpcapi.ToStatus(errors.New("secret host 10.0.0.1"))); st.Message() != "internal error" {
+		t.Errorf("internal error leaked %q", st.Message())
+	}
+}
@@ -0,0 +1,97 @@
+package grpcapi
+
+import (
+	"context"
+	"strings"
+
+	"github.com/novabankapp/wallet.data/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// metadataCarrier reads and writes W3C trace context headers in gRPC metadata.
+type metadataCarrier metadata.MD
+
+func (c metadataCarrier) Get(key string) string {
+	values := metadata.MD(c).Get(key)
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
+
+func (c metadataCarrier) Set(key, val string) {
+	metadata.MD(c).Set(strings.ToLower(key), val)
+}
+
+func (c metadataCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for key := range c {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// startServerSpan starts the span of an incoming call as a child of the caller's span,
+// when the caller sent one, and puts it into ctx for the spans further down.
+func startServerSpan(ctx context.Context, tracer trace.Tracer, method string) (context.Context, trace.Span) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	ctx = tracing.Propagator.Extract(ctx, metadataCarrier(md.Copy()))
+	return tracer.Start(ctx, method,
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(attribute.String("rpc.system", "grpc"), attribute.String("rpc.method", method)),
+	)
+}
+
+// UnaryServerInterceptor traces every unary call.
+func UnaryServerInterceptor(tracer trace.Tracer) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx, span := startServerSpan(ctx, tracer, info.FullMethod)
+		defer span.End()
+		resp, err := handler(ctx, req)
+		if err != nil {
+			tracing.TraceErr(span, err)
+		}
+		return resp, err
+	}
+}
+
+// StreamServerInterceptor traces every streaming call.
+func StreamServerInterceptor(tracer trace.Tracer) grpc.StreamServerInterceptor {
+	return func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, span := startServerSpan(stream.Context(), tracer, info.FullMethod)
+		defer span.End()
+		err := handler(srv, &tracedStream{ServerStream: stream, ctx: ctx})
+		if err != nil {
+			tracing.TraceErr(span, err)
+		}
+		return err
+	}
+}
+
+type tracedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *tracedStream) Context() context.Context {
+	return s.ctx
+}
+
+// InjectClientSpan adds the span in ctx to the outgoing metadata of a call, for clients
+// that want their spans continued by the server.
+func InjectClientSpan(ctx context.Context) context.Context {
+	if !trace.SpanContextFromContext(ctx).IsValid() {
+		return ctx
+	}
+	md, ok := metadata.FromOutgoingContext(ctx)
+	if ok {
+		md = md.Copy()
+	} else {
+		md = metadata.MD{}
+	}
+	tracing.Propagator.Inject(ctx, metadataCarrier(md))
+	return metadata.NewOutgoingContext(ctx, md)
+}
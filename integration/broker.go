@@ -0,0 +1,83 @@
+package integration
+
+import (
+	"context"
+	"strconv"
+	"sync"
+)
+
+// Message headers set on every published integration event.
+const (
+	HeaderEventID       = "event-id"
+	HeaderEventType     = "event-type"
+	HeaderSchemaVersion = "schema-version"
+	HeaderContentType   = "content-type"
+)
+
+// Message is what a Broker publishes. Key is the wallet id; brokers must keep messages
+// with the same key in publish order (a Kafka partition, a NATS subject).
+type Message struct {
+	Topic   string
+	Key     string
+	Headers map[string]string
+	Value   []byte
+}
+
+// Broker is a message broker integration events are published to. Publish returns only
+// once the broker has accepted the message.
+type Broker interface {
+	Publish(ctx context.Context, msg Message) error
+	Close() error
+}
+
+func newMessage(topic string, evt *Event, value []byte) Message {
+	return Message{
+		Topic: topic,
+		Key:   evt.WalletID,
+		Headers: map[string]string{
+			HeaderEventID:       evt.ID,
+			HeaderEventType:     evt.Type,
+			HeaderSchemaVersion: strconv.Itoa(evt.SchemaVersion),
+			HeaderContentType:   "application/json",
+		},
+		Value: value,
+	}
+}
+
+// MemoryBroker keeps published messages in memory, for tests and local runs.
+type MemoryBroker struct {
+	mu       sync.Mutex
+	messages []Message
+	// Fail, when set, is called before each publish; a non-nil error fails the publish.
+	Fail func(msg Message) error
+}
+
+func NewMemoryBroker() *MemoryBroker {
+	return &MemoryBroker{}
+}
+
+func (b *MemoryBroker) Publish(ctx context.Context, msg Message) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.Fail != nil {
+		if err := b.Fail(msg); err != nil {
+			return err
+		}
+	}
+	b.messages = append(b.messages, msg)
+	return nil
+}
+
+// Messages returns the published messages in publish order.
+func (b *MemoryBroker) Messages() []Message {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]Message(nil), b.messages...)
+}
+
+func (b *MemoryBroker) Close() error {
+	return nil
+}
@@ -0,0 +1,70 @@
+// Package integration publishes wallet changes to other services as versioned public
+// integration events, so they never have to read the event store's internal events.
+package integration
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+const (
+	WalletCreated       = "wallet.created"
+	WalletCredited      = "wallet.credited"
+	WalletDebited       = "wallet.debited"
+	WalletFundsReserved = "wallet.funds_reserved"
+	WalletFundsReleased = "wallet.funds_released"
+	WalletLocked        = "wallet.locked"
+	WalletUnlocked      = "wallet.unlocked"
+	WalletBlacklisted   = "wallet.blacklisted"
+	WalletUnBlacklisted = "wallet.unblacklisted"
+	WalletDeleted       = "wallet.deleted"
+)
+
+// SchemaVersion is the version of the payloads below. A breaking change to any payload
+// gets a new version and new payload types; consumers switch on Type and SchemaVersion.
+const SchemaVersion = 1
+
+// Event is the envelope of every integration event. ID is the id of the wallet event it
+// was mapped from, so it is stable across redeliveries and consumers can deduplicate on it.
+// Sequence is the position of that event in the wallet's stream and increases per wallet.
+type Event struct {
+	ID            string          `json:"id"`
+	Type          string          `json:"type"`
+	SchemaVersion int             `json:"schema_version"`
+	WalletID      string          `json:"wallet_id"`
+	Sequence      int64           `json:"sequence"`
+	OccurredAt    time.Time       `json:"occurred_at"`
+	Data          json.RawMessage `json:"data"`
+}
+
+type WalletCreatedV1 struct {
+	UserID         string          `json:"user_id"`
+	AccountID      string          `json:"account_id"`
+	OpeningBalance decimal.Decimal `json:"opening_balance"`
+}
+
+// WalletMovementV1 is the payload of wallet.credited and wallet.debited.
+type WalletMovementV1 struct {
+	Amount               decimal.Decimal `json:"amount"`
+	CounterpartyWalletID string          `json:"counterparty_wallet_id"`
+	Description          string          `json:"description"`
+}
+
+// WalletFundsV1 is the payload of wallet.funds_reserved and wallet.funds_released.
+type WalletFundsV1 struct {
+	Amount      decimal.Decimal `json:"amount"`
+	Description string          `json:"description"`
+}
+
+// WalletStatusV1 is the payload of the lock, blacklist and delete events. ReasonCode,
+// Authority and ExpiresAt are set on locks and blacklistings, and Expired on the unlocks
+// and un-blacklistings that were lifted because their expiry passed.
+type WalletStatusV1 struct {
+	Reason     string     `json:"reason"`
+	ReasonCode string     `json:"reason_code,omitempty"`
+	Authority  string     `json:"authority,omitempty"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	Expired    bool       `json:"expired,omitempty"`
+}
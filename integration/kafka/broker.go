@@ -0,0 +1,58 @@
+// Package kafka publishes integration events to Kafka.
+package kafka
+
+import (
+	"context"
+	"time"
+
+	"github.com/novabankapp/wallet.data/integration"
+	"github.com/pkg/errors"
+	kafkago "github.com/segmentio/kafka-go"
+)
+
+type Config struct {
+	Brokers []string
+	// BatchTimeout bounds how long a publish waits for other messages to batch with.
+	// Defaults to 10ms; the publisher sends one message at a time.
+	BatchTimeout time.Duration
+}
+
+type broker struct {
+	writer *kafkago.Writer
+}
+
+// NewBroker publishes with a hash balancer, so all events of a wallet land on the same
+// partition and keep their order, and waits for all in-sync replicas to acknowledge.
+func NewBroker(cfg Config) integration.Broker {
+	batchTimeout := cfg.BatchTimeout
+	if batchTimeout == 0 {
+		batchTimeout = 10 * time.Millisecond
+	}
+	return &broker{writer: &kafkago.Writer{
+		Addr:         kafkago.TCP(cfg.Brokers...),
+		Balancer:     &kafkago.Hash{},
+		RequiredAcks: kafkago.RequireAll,
+		BatchTimeout: batchTimeout,
+	}}
+}
+
+func (b *broker) Publish(ctx context.Context, msg integration.Message) error {
+	headers := make([]kafkago.Header, 0, len(msg.Headers))
+	for key, value := range msg.Headers {
+		headers = append(headers, kafkago.Header{Key: key, Value: []byte(value)})
+	}
+	err := b.writer.WriteMessages(ctx, kafkago.Message{
+		Topic:   msg.Topic,
+		Key:     []byte(msg.Key),
+		Value:   msg.Value,
+		Headers: headers,
+	})
+	if err != nil {
+		return errors.Wrap(err, "writer.WriteMessages")
+	}
+	return nil
+}
+
+func (b *broker) Close() error {
+	return b.writer.Close()
+}
@@ -0,0 +1,119 @@
+package integration
+
+import (
+	"encoding/json"
+	"time"
+
+	es "github.com/novabankapp/common.data/eventstore"
+	"github.com/novabankapp/wallet.data/es/aggregate"
+	v1 "github.com/novabankapp/wallet.data/es/events/v1"
+	"github.com/pkg/errors"
+)
+
+// ErrUnmappedEvent is returned for internal events that have no public counterpart.
+var ErrUnmappedEvent = errors.New("event has no integration event")
+
+type mapping struct {
+	publicType string
+	payload    func(evt es.Event) (interface{}, error)
+}
+
+var mappings = map[string]mapping{
+	v1.WalletCreated: {WalletCreated, func(evt es.Event) (interface{}, error) {
+		var data v1.WalletCreatedEvent
+		if err := evt.GetJsonData(&data); err != nil {
+			return nil, err
+		}
+		return WalletCreatedV1{UserID: data.UserId, AccountID: data.AccountId, OpeningBalance: data.Amount}, nil
+	}},
+	v1.WalletCredited: {WalletCredited, func(evt es.Event) (interface{}, error) {
+		var data v1.WalletCreditedEvent
+		if err := evt.GetJsonData(&data); err != nil {
+			return nil, err
+		}
+		return WalletMovementV1{Amount: data.Amount, CounterpartyWalletID: data.DebitWalletId, Description: data.Description}, nil
+	}},
+	v1.WalletDebited: {WalletDebited, func(evt es.Event) (interface{}, error) {
+		var data v1.WalletDebitedEvent
+		if err := evt.GetJsonData(&data); err != nil {
+			return nil, err
+		}
+		return WalletMovementV1{Amount: data.Amount, CounterpartyWalletID: data.CreditWalletId, Description: data.Description}, nil
+	}},
+	v1.WalletCreditReserved: {WalletFundsReserved, func(evt es.Event) (interface{}, error) {
+		var data v1.WalletCreditReservedEvent
+		if err := evt.GetJsonData(&data); err != nil {
+			return nil, err
+		}
+		return WalletFundsV1{Amount: data.Amount, Description: data.Description}, nil
+	}},
+	v1.WalletCreditReleased: {WalletFundsReleased, func(evt es.Event) (interface{}, error) {
+		var data v1.WalletCreditReleasedEvent
+		if err := evt.GetJsonData(&data); err != nil {
+			return nil, err
+		}
+		return WalletFundsV1{Amount: data.Amount, Description: data.Description}, nil
+	}},
+	// A confirmed quarantine is published as the debit it books; screenings and released
+	// quarantines stay internal.
+	v1.WalletQuarantineConfirmed: {WalletDebited, func(evt es.Event) (interface{}, error) {
+		var data v1.WalletQuarantineConfirmedEvent
+		if err := evt.GetJsonData(&data); err != nil {
+			return nil, err
+		}
+		return WalletMovementV1{Amount: data.Amount, CounterpartyWalletID: data.CreditWalletId, Description: data.Description}, nil
+	}},
+	v1.WalletLocked:        {WalletLocked, statusPayload},
+	v1.WalletUnlocked:      {WalletUnlocked, statusPayload},
+	v1.WalletBlacklisted:   {WalletBlacklisted, statusPayload},
+	v1.WalletUnBlacklisted: {WalletUnBlacklisted, statusPayload},
+	v1.WalletDeleted:       {WalletDeleted, statusPayload},
+}
+
+// statusPayload works for every status event: they all carry a Description, and the
+// fields the others do not have are left empty.
+func statusPayload(evt es.Event) (interface{}, error) {
+	var data struct {
+		Description string
+		ReasonCode  string
+		Authority   string
+		ExpiresAt   *time.Time
+		Expired     bool
+	}
+	if err := evt.GetJsonData(&data); err != nil {
+		return nil, err
+	}
+	return WalletStatusV1{
+		Reason:     data.Description,
+		ReasonCode: data.ReasonCode,
+		Authority:  data.Authority,
+		ExpiresAt:  data.ExpiresAt,
+		Expired:    data.Expired,
+	}, nil
+}
+
+// Map turns an internal wallet event into its integration event. Personal data fields
+// are copied as they are, so encrypted events must be revealed with pii.Cipher first.
+func Map(evt es.Event) (*Event, error) {
+	m, ok := mappings[evt.GetEventType()]
+	if !ok {
+		return nil, errors.Wrap(ErrUnmappedEvent, evt.GetEventType())
+	}
+	payload, err := m.payload(evt)
+	if err != nil {
+		return nil, errors.Wrap(err, "GetJsonData")
+	}
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, errors.Wrap(err, "json.Marshal")
+	}
+	return &Event{
+		ID:            evt.GetEventID(),
+		Type:          m.publicType,
+		SchemaVersion: SchemaVersion,
+		WalletID:      aggregate.GetWalletAggregateID(evt.GetAggregateID()),
+		Sequence:      evt.GetVersion(),
+		OccurredAt:    evt.GetTimeStamp().UTC(),
+		Data:          data,
+	}, nil
+}
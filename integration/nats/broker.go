@@ -0,0 +1,48 @@
+// Package nats publishes integration events to NATS JetStream.
+package nats
+
+import (
+	"context"
+
+	"github.com/nats-io/nats.go"
+	"github.com/novabankapp/wallet.data/integration"
+	"github.com/pkg/errors"
+)
+
+type broker struct {
+	conn *nats.Conn
+	js   nats.JetStreamContext
+}
+
+// NewBroker publishes every message to the subject "<topic>.<wallet id>", so a stream
+// bound to "<topic>.>" keeps each wallet's events in order. The event id is sent as
+// Nats-Msg-Id, which lets JetStream drop redeliveries within its duplicate window.
+func NewBroker(url string, opts ...nats.Option) (integration.Broker, error) {
+	conn, err := nats.Connect(url, opts...)
+	if err != nil {
+		return nil, errors.Wrap(err, "nats.Connect")
+	}
+	js, err := conn.JetStream()
+	if err != nil {
+		conn.Close()
+		return nil, errors.Wrap(err, "conn.JetStream")
+	}
+	return &broker{conn: conn, js: js}, nil
+}
+
+func (b *broker) Publish(ctx context.Context, msg integration.Message) error {
+	natsMsg := nats.NewMsg(msg.Topic + "." + msg.Key)
+	for key, value := range msg.Headers {
+		natsMsg.Header.Set(key, value)
+	}
+	natsMsg.Data = msg.Value
+
+	if _, err := b.js.PublishMsg(natsMsg, nats.Context(ctx), nats.MsgId(msg.Headers[integration.HeaderEventID])); err != nil {
+		return errors.Wrap(err, "js.PublishMsg")
+	}
+	return nil
+}
+
+func (b *broker) Close() error {
+	return b.conn.Drain()
+}
@@ -0,0 +1,152 @@
+package integration
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/EventStore/EventStore-Client-Go/esdb"
+	es "github.com/novabankapp/common.data/eventstore"
+	"github.com/novabankapp/common.data/logger"
+	"github.com/novabankapp/wallet.data/constants"
+	"github.com/novabankapp/wallet.data/es/pii"
+	"github.com/novabankapp/wallet.data/es/store"
+	"github.com/novabankapp/wallet.data/retry"
+	"github.com/novabankapp/wallet.data/tracing"
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+const (
+	PublisherName     = "(Integration Publisher)"
+	DefaultTopic      = "wallet.events"
+	defaultMinBackoff = 100 * time.Millisecond
+	defaultMaxBackoff = 30 * time.Second
+)
+
+var errUndeliverable = errors.New("event cannot be published")
+
+// Publisher relays wallet events from a persistent subscription to a Broker. The event
+// store is the outbox: an event is acked only after the broker accepted its integration
+// event, so every event is published at least once, also across restarts.
+//
+// A failed publish is retried in place with backoff instead of being nacked, because a
+// nack would let later events of the same wallet overtake it. Run one Publisher per
+// subscription group; the group hands out events in stream order.
+type Publisher struct {
+	Subscription store.PersistentSubscription
+	Broker       Broker
+	Topic        string
+	GroupName    string
+	Log          logger.Logger
+	// Backoff returns the wait before the given retry of a failed publish (1 for the
+	// first retry).
+	Backoff func(retry int) time.Duration
+	// PersonalData decrypts the personal data fields of events before they are mapped;
+	// brokers receive plain values, or pii.Redacted for erased users. When nil events are
+	// mapped as stored.
+	PersonalData *pii.Cipher
+}
+
+func NewPublisher(subscription store.PersistentSubscription, broker Broker, groupName string, log logger.Logger) *Publisher {
+	return &Publisher{
+		Subscription: subscription,
+		Broker:       broker,
+		Topic:        DefaultTopic,
+		GroupName:    groupName,
+		Log:          log,
+		Backoff:      retry.Exponential(defaultMinBackoff, defaultMaxBackoff),
+	}
+}
+
+// Run publishes events until the context is done or the subscription drops. An event
+// being published when Run returns is not acked and will be delivered again.
+func (p *Publisher) Run(ctx context.Context, workerID int) error {
+	for {
+		event := p.Subscription.Recv()
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+
+		if event.SubscriptionDropped != nil {
+			p.Log.Errorf("(SubscriptionDropped) err: {%v}", event.SubscriptionDropped.Error)
+			return errors.Wrap(event.SubscriptionDropped.Error, "Subscription Dropped")
+		}
+
+		if event.EventAppeared != nil {
+			p.Log.ProjectionEvent(PublisherName, p.GroupName, event.EventAppeared, workerID)
+
+			err := p.publish(ctx, es.NewEventFromRecorded(event.EventAppeared.Event))
+			switch {
+			case err == nil, errors.Is(err, ErrUnmappedEvent):
+			case errors.Is(err, errUndeliverable):
+				// A payload that cannot be mapped now never will be; park it for inspection
+				// instead of blocking every event behind it.
+				p.Log.Errorf("(Publisher.publish) err: {%v}", err)
+				if err := p.Subscription.Nack(err.Error(), esdb.Nack_Park, event.EventAppeared); err != nil {
+					p.Log.Errorf("(stream.Nack) err: {%v}", err)
+					return errors.Wrap(err, "stream.Nack")
+				}
+				continue
+			default:
+				return err
+			}
+
+			if err := p.Subscription.Ack(event.EventAppeared); err != nil {
+				p.Log.Errorf("(stream.Ack) err: {%v}", err)
+				return errors.Wrap(err, "stream.Ack")
+			}
+		}
+	}
+}
+
+// publish maps and publishes one event, retrying until the broker accepts it or the
+// context is done.
+func (p *Publisher) publish(ctx context.Context, evt es.Event) error {
+	ctx, span := tracing.StartSpan(ctx, "Publisher.publish")
+	defer span.End()
+	span.SetAttributes(attribute.String(constants.AggregateID, evt.GetAggregateID()), attribute.String("EventType", evt.GetEventType()))
+
+	if p.PersonalData != nil {
+		revealed, err := p.PersonalData.Reveal(ctx, evt)
+		if err != nil {
+			tracing.TraceErr(span, err)
+			return errors.Wrap(err, "Reveal")
+		}
+		evt = revealed
+	}
+	integrationEvent, err := Map(evt)
+	if errors.Is(err, ErrUnmappedEvent) {
+		return err
+	}
+	if err != nil {
+		return errors.Wrap(errUndeliverable, err.Error())
+	}
+	value, err := json.Marshal(integrationEvent)
+	if err != nil {
+		return errors.Wrap(errUndeliverable, err.Error())
+	}
+	msg := newMessage(p.Topic, integrationEvent, value)
+
+	for attempt := 1; ; attempt++ {
+		err := p.Broker.Publish(ctx, msg)
+		if err == nil {
+			return nil
+		}
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		wait := p.Backoff(attempt)
+		p.Log.Warnf("(Broker.Publish) event: {%s} retry: {%d} in {%s} err: {%v}", integrationEvent.ID, attempt, wait, err)
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
@@ -0,0 +1,233 @@
+package integration_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	es "github.com/novabankapp/common.data/eventstore"
+	"github.com/novabankapp/wallet.data/es/aggregate"
+	"github.com/novabankapp/wallet.data/es/pii"
+	"github.com/novabankapp/wallet.data/es/readmodel/readmodeltest"
+	"github.com/novabankapp/wallet.data/es/store"
+	"github.com/novabankapp/wallet.data/integration"
+	"github.com/pkg/errors"
+	"github.com/shopspring/decimal"
+)
+
+const group = "integration-publisher"
+
+func newStore(t *testing.T) *store.MemoryEventStore {
+	t.Helper()
+	db := store.NewMemoryEventStore()
+	if err := db.CreatePersistentSubscription(group, store.PersistentSubscriptionSettings{}); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+// seed writes a created wallet followed by the given number of credits, one save each.
+func seed(t *testing.T, db *store.MemoryEventStore, walletID string, credits int) {
+	t.Helper()
+	seedInto(t, db, walletID, credits)
+}
+
+func seedInto(t *testing.T, db es.AggregateStore, walletID string, credits int) {
+	t.Helper()
+	ctx := context.Background()
+	wallet := aggregate.NewWalletAggregateWithID(walletID)
+	if err := wallet.CreateWallet(ctx, decimal.NewFromInt(100), "opening", "user-"+walletID, "account-"+walletID, walletID); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Save(ctx, wallet); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < credits; i++ {
+		if err := wallet.CreditWallet(ctx, "w-other", decimal.NewFromInt(int64(i+1)), "credit"); err != nil {
+			t.Fatal(err)
+		}
+		if err := db.Save(ctx, wallet); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func start(t *testing.T, db *store.MemoryEventStore, broker integration.Broker) (stop func() error) {
+	t.Helper()
+	return startWith(t, db, broker, nil)
+}
+
+func startWith(t *testing.T, db *store.MemoryEventStore, broker integration.Broker, cipher *pii.Cipher) (stop func() error) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	subscription, err := db.ConnectToPersistentSubscription(ctx, group)
+	if err != nil {
+		t.Fatal(err)
+	}
+	publisher := integration.NewPublisher(subscription, broker, group, readmodeltest.NopLogger{})
+	publisher.Backoff = func(int) time.Duration { return time.Millisecond }
+	publisher.PersonalData = cipher
+
+	done := make(chan error, 1)
+	go func() { done <- publisher.Run(ctx, 0) }()
+	return func() error {
+		cancel()
+		err := <-done
+		_ = subscription.Close()
+		return err
+	}
+}
+
+func waitForMessages(t *testing.T, broker *integration.MemoryBroker, n int) []integration.Message {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		messages := broker.Messages()
+		if len(messages) >= n {
+			return messages
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("got %d messages, want %d", len(messages), n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func decode(t *testing.T, msg integration.Message) integration.Event {
+	t.Helper()
+	var evt integration.Event
+	if err := json.Unmarshal(msg.Value, &evt); err != nil {
+		t.Fatal(err)
+	}
+	return evt
+}
+
+func TestPublisherKeepsOrderPerWallet(t *testing.T) {
+	db := newStore(t)
+	seed(t, db, "w-a", 3)
+	seed(t, db, "w-b", 2)
+	broker := integration.NewMemoryBroker()
+	stop := start(t, db, broker)
+	messages := waitForMessages(t, broker, 7)
+	if err := stop(); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Run returned %v", err)
+	}
+
+	next := map[string]int64{}
+	for _, msg := range messages {
+		evt := decode(t, msg)
+		if msg.Topic != integration.DefaultTopic || msg.Key != evt.WalletID {
+			t.Errorf("message %s/%s for wallet %s", msg.Topic, msg.Key, evt.WalletID)
+		}
+		if msg.Headers[integration.HeaderEventID] != evt.ID || msg.Headers[integration.HeaderEventType] != evt.Type {
+			t.Errorf("headers %v do not match event %s %s", msg.Headers, evt.ID, evt.Type)
+		}
+		if evt.Sequence != next[evt.WalletID] {
+			t.Errorf("wallet %s: sequence %d, want %d", evt.WalletID, evt.Sequence, next[evt.WalletID])
+		}
+		next[evt.WalletID]++
+	}
+	if next["w-a"] != 4 || next["w-b"] != 3 {
+		t.Errorf("published %v", next)
+	}
+
+	created := decode(t, messages[0])
+	var payload integration.WalletCreatedV1
+	if err := json.Unmarshal(created.Data, &payload); err != nil {
+		t.Fatal(err)
+	}
+	if created.Type != integration.WalletCreated || created.SchemaVersion != integration.SchemaVersion ||
+		payload.UserID != "user-w-a" || !payload.OpeningBalance.Equal(decimal.NewFromInt(100)) {
+		t.Errorf("unexpected created event %+v %+v", created, payload)
+	}
+}
+
+func TestPublisherRetriesUntilTheBrokerAccepts(t *testing.T) {
+	db := newStore(t)
+	seed(t, db, "w-a", 2)
+	broker := integration.NewMemoryBroker()
+	attempts := 0
+	broker.Fail = func(integration.Message) error {
+		attempts++
+		if attempts <= 3 {
+			return errors.New("broker unavailable")
+		}
+		return nil
+	}
+	stop := start(t, db, broker)
+	messages := waitForMessages(t, broker, 3)
+	_ = stop()
+
+	for i, msg := range messages {
+		if evt := decode(t, msg); evt.Sequence != int64(i) {
+			t.Errorf("message %d has sequence %d", i, evt.Sequence)
+		}
+	}
+	if attempts != 6 {
+		t.Errorf("%d publish attempts, want 6", attempts)
+	}
+}
+
+func TestPublisherRedeliversUnpublishedEventsAfterRestart(t *testing.T) {
+	db := newStore(t)
+	seed(t, db, "w-a", 1)
+
+	down := integration.NewMemoryBroker()
+	failed := make(chan struct{}, 100)
+	down.Fail = func(integration.Message) error {
+		failed <- struct{}{}
+		return errors.New("broker unavailable")
+	}
+	stop := start(t, db, down)
+	<-failed
+	<-failed
+	if err := stop(); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Run returned %v", err)
+	}
+
+	up := integration.NewMemoryBroker()
+	stop = start(t, db, up)
+	messages := waitForMessages(t, up, 2)
+	_ = stop()
+	if first := decode(t, messages[0]); first.Type != integration.WalletCreated || first.Sequence != 0 {
+		t.Errorf("first message after restart is %s #%d", first.Type, first.Sequence)
+	}
+}
+
+func TestPublisherRevealsEncryptedEvents(t *testing.T) {
+	db := newStore(t)
+	cipher := pii.NewCipher(pii.NewMemoryKeyStore())
+	seedInto(t, cipher.ProtectStore(db), "w-a", 1)
+	broker := integration.NewMemoryBroker()
+	stop := startWith(t, db, broker, cipher)
+	messages := waitForMessages(t, broker, 2)
+	_ = stop()
+
+	var created integration.WalletCreatedV1
+	if err := json.Unmarshal(decode(t, messages[0]).Data, &created); err != nil {
+		t.Fatal(err)
+	}
+	if created.UserID != "user-w-a" || created.AccountID != "account-w-a" {
+		t.Errorf("created event carries %+v, want plain values", created)
+	}
+	var credited integration.WalletMovementV1
+	if err := json.Unmarshal(decode(t, messages[1]).Data, &credited); err != nil {
+		t.Fatal(err)
+	}
+	if credited.Description != "credit" {
+		t.Errorf("credited event carries description %q", credited.Description)
+	}
+
+	events, err := db.ReadEvents(context.Background(), aggregate.GetWalletStreamID("w-a"), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var stored struct{ UserId string }
+	if err := events[0].GetJsonData(&stored); err != nil {
+		t.Fatal(err)
+	}
+	if !pii.IsEncrypted(stored.UserId) {
+		t.Errorf("stored user id %q is not encrypted", stored.UserId)
+	}
+}
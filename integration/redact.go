@@ -0,0 +1,58 @@
+package integration
+
+import (
+	"encoding/json"
+
+	"github.com/novabankapp/wallet.data/es/pii"
+	"github.com/pkg/errors"
+)
+
+// PersonalDataFields lists the payload fields of each integration event type that hold
+// personal data of the wallet's owner, like v1.PersonalDataFields does for wallet events.
+var PersonalDataFields = map[string][]string{
+	WalletCreated:       {"user_id", "account_id"},
+	WalletCredited:      {"description"},
+	WalletDebited:       {"description"},
+	WalletFundsReserved: {"description"},
+	WalletFundsReleased: {"description"},
+	WalletLocked:        {"reason"},
+	WalletUnlocked:      {"reason"},
+	WalletBlacklisted:   {"reason"},
+	WalletUnBlacklisted: {"reason"},
+	WalletDeleted:       {"reason"},
+}
+
+// RedactPersonalData returns a serialized integration event with its personal data
+// fields replaced by pii.Redacted, for copies kept after the owner was erased, such as
+// the webhook delivery log.
+func RedactPersonalData(payload []byte) ([]byte, error) {
+	var evt Event
+	if err := json.Unmarshal(payload, &evt); err != nil {
+		return nil, errors.Wrap(err, "json.Unmarshal")
+	}
+	fields := PersonalDataFields[evt.Type]
+	if len(fields) == 0 || len(evt.Data) == 0 {
+		return payload, nil
+	}
+	var data map[string]json.RawMessage
+	if err := json.Unmarshal(evt.Data, &data); err != nil {
+		return nil, errors.Wrap(err, "json.Unmarshal")
+	}
+	redacted, err := json.Marshal(pii.Redacted)
+	if err != nil {
+		return nil, errors.Wrap(err, "json.Marshal")
+	}
+	for _, field := range fields {
+		if _, ok := data[field]; ok {
+			data[field] = redacted
+		}
+	}
+	if evt.Data, err = json.Marshal(data); err != nil {
+		return nil, errors.Wrap(err, "json.Marshal")
+	}
+	result, err := json.Marshal(evt)
+	if err != nil {
+		return nil, errors.Wrap(err, "json.Marshal")
+	}
+	return result, nil
+}
@@ -0,0 +1,191 @@
+package iso20022
+
+import (
+	"strings"
+	"time"
+
+	es "github.com/novabankapp/common.data/eventstore"
+	"github.com/novabankapp/wallet.data/domain"
+	v1 "github.com/novabankapp/wallet.data/es/events/v1"
+	"github.com/pkg/errors"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	maxIdentifierLength     = 34
+	maxReferenceLength      = 35
+	maxRemittanceLength     = 140
+	bankTransactionDomain   = "PMNT"
+	receivedCreditTransfers = "RCDT"
+	issuedCreditTransfers   = "ICDT"
+	bookTransfer            = "BOOK"
+)
+
+// Account identifies the wallet a message reports on.
+type Account struct {
+	WalletId  string
+	AccountId string
+	Currency  string
+	OwnerName string
+}
+
+// movement is a booked change of a wallet's balance, decoded from one wallet event.
+type movement struct {
+	EventId      string
+	BookedAt     time.Time
+	Amount       decimal.Decimal
+	Indicator    string
+	Counterparty string
+	Description  string
+}
+
+// balances tracks booked and available balance while replaying wallet events.
+type balances struct {
+	Booked    decimal.Decimal
+	Available decimal.Decimal
+}
+
+// decodeEvent applies an event to the running balances and returns the booked movement
+// it represents, or nil for events that do not move money (locks, holds, ...).
+func decodeEvent(evt es.Event, running *balances) (*movement, error) {
+	switch evt.GetEventType() {
+	case v1.WalletCreated:
+		var data v1.WalletCreatedEvent
+		if err := evt.GetJsonData(&data); err != nil {
+			return nil, errors.Wrap(err, "GetJsonData")
+		}
+		running.Booked = data.Amount
+		running.Available = data.Amount
+		if data.Amount.IsZero() {
+			return nil, nil
+		}
+		return &movement{EventId: evt.GetEventID(), BookedAt: evt.GetTimeStamp(), Amount: data.Amount, Indicator: Credit, Description: data.Description}, nil
+	case v1.WalletCredited:
+		var data v1.WalletCreditedEvent
+		if err := evt.GetJsonData(&data); err != nil {
+			return nil, errors.Wrap(err, "GetJsonData")
+		}
+		running.Booked = running.Booked.Add(data.Amount)
+		running.Available = running.Available.Add(data.Amount)
+		return &movement{EventId: evt.GetEventID(), BookedAt: evt.GetTimeStamp(), Amount: data.Amount, Indicator: Credit, Counterparty: data.DebitWalletId, Description: data.Description}, nil
+	case v1.WalletDebited:
+		var data v1.WalletDebitedEvent
+		if err := evt.GetJsonData(&data); err != nil {
+			return nil, errors.Wrap(err, "GetJsonData")
+		}
+		running.Booked = running.Booked.Sub(data.Amount)
+		running.Available = running.Available.Sub(data.Amount)
+		return &movement{EventId: evt.GetEventID(), BookedAt: evt.GetTimeStamp(), Amount: data.Amount, Indicator: Debit, Counterparty: data.CreditWalletId, Description: data.Description}, nil
+	case v1.WalletCreditReserved:
+		var data v1.WalletCreditReservedEvent
+		if err := evt.GetJsonData(&data); err != nil {
+			return nil, errors.Wrap(err, "GetJsonData")
+		}
+		running.Available = running.Available.Sub(data.Amount)
+	case v1.WalletCreditReleased:
+		var data v1.WalletCreditReleasedEvent
+		if err := evt.GetJsonData(&data); err != nil {
+			return nil, errors.Wrap(err, "GetJsonData")
+		}
+		running.Available = running.Available.Add(data.Amount)
+	case v1.WalletScreened:
+		var data v1.WalletScreenedEvent
+		if err := evt.GetJsonData(&data); err != nil {
+			return nil, errors.Wrap(err, "GetJsonData")
+		}
+		if data.Outcome == string(domain.ScreeningQuarantined) {
+			running.Available = running.Available.Sub(data.Amount)
+		}
+	case v1.WalletQuarantineReleased:
+		var data v1.WalletQuarantineReleasedEvent
+		if err := evt.GetJsonData(&data); err != nil {
+			return nil, errors.Wrap(err, "GetJsonData")
+		}
+		running.Available = running.Available.Add(data.Amount)
+	case v1.WalletQuarantineConfirmed:
+		var data v1.WalletQuarantineConfirmedEvent
+		if err := evt.GetJsonData(&data); err != nil {
+			return nil, errors.Wrap(err, "GetJsonData")
+		}
+		running.Booked = running.Booked.Sub(data.Amount)
+		return &movement{EventId: evt.GetEventID(), BookedAt: evt.GetTimeStamp(), Amount: data.Amount, Indicator: Debit, Counterparty: data.CreditWalletId, Description: data.Description}, nil
+	}
+	return nil, nil
+}
+
+func (m *movement) toEntry(account Account) ReportEntry {
+	ref := reference(m.EventId)
+	family := receivedCreditTransfers
+	details := TransactionDetails{
+		References: TransactionReferences{AccountServicerReference: ref, EndToEndId: ref},
+	}
+	if m.Indicator == Debit {
+		family = issuedCreditTransfers
+	}
+	if m.Counterparty != "" {
+		own := &CashAccount{Id: accountId(account.WalletId)}
+		other := &CashAccount{Id: accountId(m.Counterparty)}
+		if m.Indicator == Credit {
+			details.RelatedParties = &RelatedParties{DebtorAccount: other, CreditorAccount: own}
+		} else {
+			details.RelatedParties = &RelatedParties{DebtorAccount: own, CreditorAccount: other}
+		}
+	}
+	if description := strings.TrimSpace(m.Description); description != "" {
+		details.RemittanceInformation = &RemittanceInformation{Unstructured: []string{truncate(description, maxRemittanceLength)}}
+	}
+
+	date := DateAndDateTime{Date: m.BookedAt.UTC().Format(isoDateLayout)}
+	return ReportEntry{
+		EntryReference:           ref,
+		Amount:                   Amount{Currency: account.Currency, Value: m.Amount},
+		CreditDebitIndicator:     m.Indicator,
+		Status:                   EntryStatusBooked,
+		BookingDate:              date,
+		ValueDate:                date,
+		AccountServicerReference: ref,
+		BankTransactionCode: BankTransactionCode{Domain: BankTransactionCodeDomain{
+			Code:   bankTransactionDomain,
+			Family: BankTransactionCodeFamily{Code: family, SubFamilyCode: bookTransfer},
+		}},
+		EntryDetails: []EntryDetails{{TransactionDetails: []TransactionDetails{details}}},
+	}
+}
+
+func accountId(id string) AccountIdentification {
+	return AccountIdentification{Other: GenericAccountIdentification{Id: compactId(id, maxIdentifierLength)}}
+}
+
+func reference(id string) string {
+	return compactId(id, maxReferenceLength)
+}
+
+// compactId drops the dashes of UUID-style identifiers that would not fit the schema's
+// length limit.
+func compactId(id string, max int) string {
+	if len(id) > max {
+		id = strings.ReplaceAll(id, "-", "")
+	}
+	return truncate(id, max)
+}
+
+func truncate(value string, max int) string {
+	runes := []rune(value)
+	if len(runes) <= max {
+		return value
+	}
+	return string(runes[:max])
+}
+
+func balance(code string, currency string, amount decimal.Decimal, date time.Time) Balance {
+	indicator := Credit
+	if amount.IsNegative() {
+		indicator = Debit
+	}
+	return Balance{
+		Type:                 BalanceType{CodeOrProprietary: CodeOrProprietary{Code: code}},
+		Amount:               Amount{Currency: currency, Value: amount.Abs()},
+		CreditDebitIndicator: indicator,
+		Date:                 DateAndDateTime{Date: date.UTC().Format(isoDateLayout)},
+	}
+}
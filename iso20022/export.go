@@ -0,0 +1,179 @@
+package iso20022
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strconv"
+	"time"
+
+	es "github.com/novabankapp/common.data/eventstore"
+	"github.com/pkg/errors"
+	"github.com/shopspring/decimal"
+)
+
+var ErrInvalidPeriod = errors.New("statement period start must be before its end")
+
+// Exporter maps the events of one wallet stream to camt.053 statements and camt.054
+// notifications.
+type Exporter struct {
+	MessageIdPrefix string
+	Now             func() time.Time
+}
+
+func NewExporter(messageIdPrefix string) *Exporter {
+	return &Exporter{MessageIdPrefix: messageIdPrefix, Now: time.Now}
+}
+
+// BuildStatement builds the end-of-day (or any period) statement for [from, to).
+// events must be the wallet's stream in version order, starting with its creation. A
+// statement that fails Validate is returned as an error wrapping ErrInvalidMessage.
+func (e *Exporter) BuildStatement(account Account, events []es.Event, from, to time.Time, sequence int64) (*Camt053Document, error) {
+	if !from.Before(to) {
+		return nil, ErrInvalidPeriod
+	}
+	now := e.Now().UTC()
+
+	var running balances
+	var opening *balances
+	entries := make([]ReportEntry, 0)
+	summary := newSummary()
+	for _, evt := range events {
+		at := evt.GetTimeStamp()
+		if !at.Before(to) {
+			break
+		}
+		if opening == nil && !at.Before(from) {
+			snapshot := running
+			opening = &snapshot
+		}
+		m, err := decodeEvent(evt, &running)
+		if err != nil {
+			return nil, errors.Wrapf(err, "event %s", evt.GetEventID())
+		}
+		if m != nil && !at.Before(from) {
+			entries = append(entries, m.toEntry(account))
+			summary.add(m)
+		}
+	}
+	if opening == nil {
+		snapshot := running
+		opening = &snapshot
+	}
+	closingDate := to.Add(-time.Nanosecond)
+
+	report := AccountReport{
+		Id:                       e.messageId(now, sequence),
+		ElectronicSequenceNumber: sequence,
+		CreationDateTime:         now.Format(isoDateTimeLayout),
+		FromToDate: &DateTimePeriod{
+			FromDateTime: from.UTC().Format(isoDateTimeLayout),
+			ToDateTime:   closingDate.UTC().Format(isoDateTimeLayout),
+		},
+		Account: e.account(account),
+		Balances: []Balance{
+			balance(BalanceOpeningBooked, account.Currency, opening.Booked, from),
+			balance(BalanceClosingBooked, account.Currency, running.Booked, closingDate),
+			balance(BalanceClosingAvailable, account.Currency, running.Available, closingDate),
+		},
+		TransactionsSummary: summary.build(),
+		Entries:             entries,
+	}
+	document := &Camt053Document{
+		Xmlns: Camt053Namespace,
+		Message: BankToCustomerStatement{
+			GroupHeader: GroupHeader{MessageId: e.messageId(now, sequence), CreationDateTime: now.Format(isoDateTimeLayout)},
+			Statements:  []AccountReport{report},
+		},
+	}
+	if err := document.Validate(); err != nil {
+		return nil, err
+	}
+	return document, nil
+}
+
+// BuildNotification builds a debit/credit notification for the money-moving events in
+// events. Events that do not move money are skipped; an empty notification is an error,
+// and so is one that fails Validate.
+func (e *Exporter) BuildNotification(account Account, events []es.Event, sequence int64) (*Camt054Document, error) {
+	now := e.Now().UTC()
+	var running balances
+	entries := make([]ReportEntry, 0, len(events))
+	for _, evt := range events {
+		m, err := decodeEvent(evt, &running)
+		if err != nil {
+			return nil, errors.Wrapf(err, "event %s", evt.GetEventID())
+		}
+		if m != nil {
+			entries = append(entries, m.toEntry(account))
+		}
+	}
+	if len(entries) == 0 {
+		return nil, errors.New("no debit or credit entries to notify")
+	}
+
+	document := &Camt054Document{
+		Xmlns: Camt054Namespace,
+		Message: BankToCustomerDebitCreditNotification{
+			GroupHeader: GroupHeader{MessageId: e.messageId(now, sequence), CreationDateTime: now.Format(isoDateTimeLayout)},
+			Notifications: []AccountReport{{
+				Id:                       e.messageId(now, sequence),
+				ElectronicSequenceNumber: sequence,
+				CreationDateTime:         now.Format(isoDateTimeLayout),
+				Account:                  e.account(account),
+				Entries:                  entries,
+			}},
+		},
+	}
+	if err := document.Validate(); err != nil {
+		return nil, err
+	}
+	return document, nil
+}
+
+// Marshal renders a camt document with an XML declaration.
+func Marshal(document interface{}) ([]byte, error) {
+	body, err := xml.MarshalIndent(document, "", "  ")
+	if err != nil {
+		return nil, errors.Wrap(err, "xml.MarshalIndent")
+	}
+	return append([]byte(xml.Header), body...), nil
+}
+
+func (e *Exporter) messageId(now time.Time, sequence int64) string {
+	return truncate(fmt.Sprintf("%s%s%d", e.MessageIdPrefix, now.Format("20060102150405"), sequence), maxReferenceLength)
+}
+
+func (e *Exporter) account(account Account) CashAccount {
+	acct := CashAccount{Id: accountId(account.AccountId), Currency: account.Currency}
+	if account.OwnerName != "" {
+		acct.Owner = &PartyIdentification{Name: truncate(account.OwnerName, maxRemittanceLength)}
+	}
+	return acct
+}
+
+type summary struct {
+	credits, debits     int
+	creditSum, debitSum decimal.Decimal
+}
+
+func newSummary() *summary {
+	return &summary{creditSum: decimal.Zero, debitSum: decimal.Zero}
+}
+
+func (s *summary) add(m *movement) {
+	if m.Indicator == Credit {
+		s.credits++
+		s.creditSum = s.creditSum.Add(m.Amount)
+	} else {
+		s.debits++
+		s.debitSum = s.debitSum.Add(m.Amount)
+	}
+}
+
+func (s *summary) build() *TransactionsSummary {
+	return &TransactionsSummary{
+		TotalEntries:       NumberAndSumOfTransactions{NumberOfEntries: strconv.Itoa(s.credits + s.debits), Sum: s.creditSum.Add(s.debitSum)},
+		TotalCreditEntries: NumberAndSumOfTransactions{NumberOfEntries: strconv.Itoa(s.credits), Sum: s.creditSum},
+		TotalDebitEntries:  NumberAndSumOfTransactions{NumberOfEntries: strconv.Itoa(s.debits), Sum: s.debitSum},
+	}
+}
@@ -0,0 +1,174 @@
+package iso20022
+
+import (
+	"encoding/xml"
+
+	"github.com/shopspring/decimal"
+)
+
+const (
+	Camt053Namespace = "urn:iso:std:iso:20022:tech:xsd:camt.053.001.02"
+	Camt054Namespace = "urn:iso:std:iso:20022:tech:xsd:camt.054.001.02"
+
+	isoDateLayout     = "2006-01-02"
+	isoDateTimeLayout = "2006-01-02T15:04:05"
+
+	Credit = "CRDT"
+	Debit  = "DBIT"
+
+	BalanceOpeningBooked    = "OPBD"
+	BalanceClosingBooked    = "CLBD"
+	BalanceClosingAvailable = "CLAV"
+
+	EntryStatusBooked = "BOOK"
+)
+
+// The types below follow the element order of the camt.053.001.02 and camt.054.001.02
+// schemas; the order of fields matters for schema validity.
+
+type Camt053Document struct {
+	XMLName xml.Name                `xml:"Document"`
+	Xmlns   string                  `xml:"xmlns,attr"`
+	Message BankToCustomerStatement `xml:"BkToCstmrStmt"`
+}
+
+type BankToCustomerStatement struct {
+	GroupHeader GroupHeader     `xml:"GrpHdr"`
+	Statements  []AccountReport `xml:"Stmt"`
+}
+
+type Camt054Document struct {
+	XMLName xml.Name                              `xml:"Document"`
+	Xmlns   string                                `xml:"xmlns,attr"`
+	Message BankToCustomerDebitCreditNotification `xml:"BkToCstmrDbtCdtNtfctn"`
+}
+
+type BankToCustomerDebitCreditNotification struct {
+	GroupHeader   GroupHeader     `xml:"GrpHdr"`
+	Notifications []AccountReport `xml:"Ntfctn"`
+}
+
+type GroupHeader struct {
+	MessageId        string `xml:"MsgId"`
+	CreationDateTime string `xml:"CreDtTm"`
+}
+
+// AccountReport is the shared shape of a camt.053 Stmt and a camt.054 Ntfctn.
+type AccountReport struct {
+	Id                       string               `xml:"Id"`
+	ElectronicSequenceNumber int64                `xml:"ElctrncSeqNb,omitempty"`
+	CreationDateTime         string               `xml:"CreDtTm"`
+	FromToDate               *DateTimePeriod      `xml:"FrToDt,omitempty"`
+	Account                  CashAccount          `xml:"Acct"`
+	Balances                 []Balance            `xml:"Bal,omitempty"`
+	TransactionsSummary      *TransactionsSummary `xml:"TxsSummry,omitempty"`
+	Entries                  []ReportEntry        `xml:"Ntry,omitempty"`
+}
+
+type DateTimePeriod struct {
+	FromDateTime string `xml:"FrDtTm"`
+	ToDateTime   string `xml:"ToDtTm"`
+}
+
+type CashAccount struct {
+	Id       AccountIdentification `xml:"Id"`
+	Currency string                `xml:"Ccy,omitempty"`
+	Owner    *PartyIdentification  `xml:"Ownr,omitempty"`
+}
+
+type AccountIdentification struct {
+	Other GenericAccountIdentification `xml:"Othr"`
+}
+
+type GenericAccountIdentification struct {
+	Id string `xml:"Id"`
+}
+
+type PartyIdentification struct {
+	Name string `xml:"Nm,omitempty"`
+}
+
+type Balance struct {
+	Type                 BalanceType     `xml:"Tp"`
+	Amount               Amount          `xml:"Amt"`
+	CreditDebitIndicator string          `xml:"CdtDbtInd"`
+	Date                 DateAndDateTime `xml:"Dt"`
+}
+
+type BalanceType struct {
+	CodeOrProprietary CodeOrProprietary `xml:"CdOrPrtry"`
+}
+
+type CodeOrProprietary struct {
+	Code string `xml:"Cd"`
+}
+
+type Amount struct {
+	Currency string          `xml:"Ccy,attr"`
+	Value    decimal.Decimal `xml:",chardata"`
+}
+
+type DateAndDateTime struct {
+	Date string `xml:"Dt"`
+}
+
+type TransactionsSummary struct {
+	TotalEntries       NumberAndSumOfTransactions `xml:"TtlNtries"`
+	TotalCreditEntries NumberAndSumOfTransactions `xml:"TtlCdtNtries"`
+	TotalDebitEntries  NumberAndSumOfTransactions `xml:"TtlDbtNtries"`
+}
+
+type NumberAndSumOfTransactions struct {
+	NumberOfEntries string          `xml:"NbOfNtries"`
+	Sum             decimal.Decimal `xml:"Sum"`
+}
+
+type ReportEntry struct {
+	EntryReference           string              `xml:"NtryRef"`
+	Amount                   Amount              `xml:"Amt"`
+	CreditDebitIndicator     string              `xml:"CdtDbtInd"`
+	Status                   string              `xml:"Sts"`
+	BookingDate              DateAndDateTime     `xml:"BookgDt"`
+	ValueDate                DateAndDateTime     `xml:"ValDt"`
+	AccountServicerReference string              `xml:"AcctSvcrRef"`
+	BankTransactionCode      BankTransactionCode `xml:"BkTxCd"`
+	EntryDetails             []EntryDetails      `xml:"NtryDtls"`
+}
+
+type BankTransactionCode struct {
+	Domain BankTransactionCodeDomain `xml:"Domn"`
+}
+
+type BankTransactionCodeDomain struct {
+	Code   string                    `xml:"Cd"`
+	Family BankTransactionCodeFamily `xml:"Fmly"`
+}
+
+type BankTransactionCodeFamily struct {
+	Code          string `xml:"Cd"`
+	SubFamilyCode string `xml:"SubFmlyCd"`
+}
+
+type EntryDetails struct {
+	TransactionDetails []TransactionDetails `xml:"TxDtls"`
+}
+
+type TransactionDetails struct {
+	References            TransactionReferences  `xml:"Refs"`
+	RelatedParties        *RelatedParties        `xml:"RltdPties,omitempty"`
+	RemittanceInformation *RemittanceInformation `xml:"RmtInf,omitempty"`
+}
+
+type TransactionReferences struct {
+	AccountServicerReference string `xml:"AcctSvcrRef"`
+	EndToEndId               string `xml:"EndToEndId"`
+}
+
+type RelatedParties struct {
+	DebtorAccount   *CashAccount `xml:"DbtrAcct,omitempty"`
+	CreditorAccount *CashAccount `xml:"CdtrAcct,omitempty"`
+}
+
+type RemittanceInformation struct {
+	Unstructured []string `xml:"Ustrd"`
+}
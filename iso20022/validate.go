@@ -0,0 +1,164 @@
+package iso20022
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	maxTotalDigits = 18
+	maxFraction    = 5
+)
+
+var (
+	ErrInvalidMessage = errors.New("invalid ISO 20022 message")
+
+	currencyPattern = regexp.MustCompile(`^[A-Z]{3}$`)
+)
+
+// Validate checks the camt.053.001.02 facets that depend on the exported data: mandatory
+// elements, text lengths, currency codes, amount precision and code lists. It is a quick
+// check BuildStatement runs on every statement it returns, not schema validation; the
+// package's tests validate the rendered messages against the official XSDs.
+func (d *Camt053Document) Validate() error {
+	v := &validator{}
+	v.groupHeader(d.Message.GroupHeader)
+	if len(d.Message.Statements) == 0 {
+		v.fail("BkToCstmrStmt/Stmt", "at least one statement is required")
+	}
+	for i, stmt := range d.Message.Statements {
+		path := fmt.Sprintf("Stmt[%d]", i)
+		v.report(path, stmt)
+		for j, bal := range stmt.Balances {
+			balPath := fmt.Sprintf("%s/Bal[%d]", path, j)
+			switch bal.Type.CodeOrProprietary.Code {
+			case BalanceOpeningBooked, BalanceClosingBooked, BalanceClosingAvailable:
+			default:
+				v.fail(balPath+"/Tp", "unsupported balance type %q", bal.Type.CodeOrProprietary.Code)
+			}
+			v.amount(balPath+"/Amt", bal.Amount)
+			v.indicator(balPath+"/CdtDbtInd", bal.CreditDebitIndicator)
+			v.date(balPath+"/Dt/Dt", bal.Date.Date)
+		}
+	}
+	return v.err()
+}
+
+// Validate checks the camt.054.001.02 facets that depend on the exported data, like
+// Camt053Document.Validate.
+func (d *Camt054Document) Validate() error {
+	v := &validator{}
+	v.groupHeader(d.Message.GroupHeader)
+	if len(d.Message.Notifications) == 0 {
+		v.fail("BkToCstmrDbtCdtNtfctn/Ntfctn", "at least one notification is required")
+	}
+	for i, ntfctn := range d.Message.Notifications {
+		v.report(fmt.Sprintf("Ntfctn[%d]", i), ntfctn)
+	}
+	return v.err()
+}
+
+type validator struct {
+	problems []string
+}
+
+func (v *validator) fail(path, format string, args ...interface{}) {
+	v.problems = append(v.problems, path+": "+fmt.Sprintf(format, args...))
+}
+
+func (v *validator) err() error {
+	if len(v.problems) == 0 {
+		return nil
+	}
+	return errors.Wrapf(ErrInvalidMessage, "%v", v.problems)
+}
+
+func (v *validator) text(path, value string, max int) {
+	if value == "" {
+		v.fail(path, "is required")
+	}
+	if len([]rune(value)) > max {
+		v.fail(path, "exceeds %d characters", max)
+	}
+}
+
+func (v *validator) dateTime(path, value string) {
+	if _, err := time.Parse(isoDateTimeLayout, value); err != nil {
+		v.fail(path, "is not an ISODateTime")
+	}
+}
+
+func (v *validator) date(path, value string) {
+	if _, err := time.Parse(isoDateLayout, value); err != nil {
+		v.fail(path, "is not an ISODate")
+	}
+}
+
+func (v *validator) indicator(path, value string) {
+	if value != Credit && value != Debit {
+		v.fail(path, "must be %s or %s", Credit, Debit)
+	}
+}
+
+func (v *validator) amount(path string, amount Amount) {
+	if !currencyPattern.MatchString(amount.Currency) {
+		v.fail(path+"/@Ccy", "is not an ISO 4217 currency code")
+	}
+	v.decimal(path, amount.Value)
+}
+
+func (v *validator) decimal(path string, value decimal.Decimal) {
+	if value.IsNegative() {
+		v.fail(path, "must not be negative")
+	}
+	if !value.Equal(value.Round(maxFraction)) {
+		v.fail(path, "has more than %d fraction digits", maxFraction)
+	}
+	if len(value.Abs().Coefficient().String()) > maxTotalDigits {
+		v.fail(path, "has more than %d digits", maxTotalDigits)
+	}
+}
+
+func (v *validator) groupHeader(header GroupHeader) {
+	v.text("GrpHdr/MsgId", header.MessageId, maxReferenceLength)
+	v.dateTime("GrpHdr/CreDtTm", header.CreationDateTime)
+}
+
+func (v *validator) report(path string, report AccountReport) {
+	v.text(path+"/Id", report.Id, maxReferenceLength)
+	v.dateTime(path+"/CreDtTm", report.CreationDateTime)
+	v.text(path+"/Acct/Id/Othr/Id", report.Account.Id.Other.Id, maxIdentifierLength)
+	if report.Account.Currency != "" && !currencyPattern.MatchString(report.Account.Currency) {
+		v.fail(path+"/Acct/Ccy", "is not an ISO 4217 currency code")
+	}
+	if report.FromToDate != nil {
+		v.dateTime(path+"/FrToDt/FrDtTm", report.FromToDate.FromDateTime)
+		v.dateTime(path+"/FrToDt/ToDtTm", report.FromToDate.ToDateTime)
+	}
+	for i, entry := range report.Entries {
+		entryPath := fmt.Sprintf("%s/Ntry[%d]", path, i)
+		v.text(entryPath+"/NtryRef", entry.EntryReference, maxReferenceLength)
+		v.amount(entryPath+"/Amt", entry.Amount)
+		v.indicator(entryPath+"/CdtDbtInd", entry.CreditDebitIndicator)
+		if entry.Status != EntryStatusBooked {
+			v.fail(entryPath+"/Sts", "must be %s", EntryStatusBooked)
+		}
+		v.date(entryPath+"/BookgDt/Dt", entry.BookingDate.Date)
+		v.date(entryPath+"/ValDt/Dt", entry.ValueDate.Date)
+		v.text(entryPath+"/AcctSvcrRef", entry.AccountServicerReference, maxReferenceLength)
+		for _, details := range entry.EntryDetails {
+			for _, tx := range details.TransactionDetails {
+				if tx.RemittanceInformation == nil {
+					continue
+				}
+				for _, line := range tx.RemittanceInformation.Unstructured {
+					v.text(entryPath+"/NtryDtls/TxDtls/RmtInf/Ustrd", line, maxRemittanceLength)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,239 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/EventStore/EventStore-Client-Go/esdb"
+	"github.com/novabankapp/wallet.data/es/aggregate"
+	"github.com/novabankapp/wallet.data/es/commands"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const Namespace = "wallet"
+
+// Metrics publishes the command, aggregate and projection metrics to Prometheus. It
+// implements commands.CommandMetrics and aggregate.ExecutorMetrics; Projection returns the
+// aggregate.ProjectionMetrics of one projection and InstrumentAggregateStore wraps an
+// aggregate store.
+type Metrics struct {
+	commands        *prometheus.CounterVec
+	commandDuration *prometheus.HistogramVec
+	attempts        *prometheus.CounterVec
+	attemptDuration *prometheus.HistogramVec
+
+	loadDuration *prometheus.HistogramVec
+	loadedEvents *prometheus.HistogramVec
+	saveDuration *prometheus.HistogramVec
+	savedEvents  *prometheus.CounterVec
+
+	handlerDuration   *prometheus.HistogramVec
+	handlerErrors     *prometheus.CounterVec
+	acks              *prometheus.CounterVec
+	nacks             *prometheus.CounterVec
+	parked            *prometheus.CounterVec
+	processedPosition *prometheus.GaugeVec
+	projectionLag     *prometheus.GaugeVec
+	headPosition      prometheus.Gauge
+	headErrors        prometheus.Counter
+
+	mu        sync.Mutex
+	head      uint64
+	processed map[string]uint64
+}
+
+// New creates the metrics and registers them with registerer, e.g. a
+// prometheus.NewRegistry() the host service serves with Handler.
+func New(registerer prometheus.Registerer) (*Metrics, error) {
+	m := &Metrics{
+		commands: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: Namespace, Subsystem: "command", Name: "total",
+			Help: "Commands dispatched, by command and outcome.",
+		}, []string{"command", "outcome"}),
+		commandDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: Namespace, Subsystem: "command", Name: "duration_seconds",
+			Help:    "Time to dispatch a command, including retries, by command and outcome.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"command", "outcome"}),
+		attempts: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: Namespace, Subsystem: "command", Name: "attempts_total",
+			Help: "Load, command and save attempts of the command executor, by command and outcome.",
+		}, []string{"command", "outcome"}),
+		attemptDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: Namespace, Subsystem: "command", Name: "attempt_duration_seconds",
+			Help:    "Time of one attempt of the command executor, by command and outcome.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"command", "outcome"}),
+
+		loadDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: Namespace, Subsystem: "aggregate", Name: "load_duration_seconds",
+			Help:    "Time to load an aggregate from the event store, by aggregate type and outcome.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"aggregate", "outcome"}),
+		loadedEvents: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: Namespace, Subsystem: "aggregate", Name: "loaded_events",
+			Help:    "Events replayed to load an aggregate, by aggregate type.",
+			Buckets: prometheus.ExponentialBuckets(1, 4, 8),
+		}, []string{"aggregate"}),
+		saveDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: Namespace, Subsystem: "aggregate", Name: "save_duration_seconds",
+			Help:    "Time to append an aggregate's new events, by aggregate type and outcome.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"aggregate", "outcome"}),
+		savedEvents: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: Namespace, Subsystem: "aggregate", Name: "saved_events_total",
+			Help: "Events appended to the event store, by aggregate type and event type.",
+		}, []string{"aggregate", "event_type"}),
+
+		handlerDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: Namespace, Subsystem: "projection", Name: "handler_duration_seconds",
+			Help:    "Time the projection took to apply an event, by projection and event type.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"projection", "event_type"}),
+		handlerErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: Namespace, Subsystem: "projection", Name: "handler_errors_total",
+			Help: "Failed attempts to apply an event, by projection and event type.",
+		}, []string{"projection", "event_type"}),
+		acks: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: Namespace, Subsystem: "projection", Name: "acks_total",
+			Help: "Events acked to the persistent subscription, by projection and event type.",
+		}, []string{"projection", "event_type"}),
+		nacks: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: Namespace, Subsystem: "projection", Name: "nacks_total",
+			Help: "Events nacked to the persistent subscription, by projection, event type and action.",
+		}, []string{"projection", "event_type", "action"}),
+		parked: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: Namespace, Subsystem: "projection", Name: "parked_total",
+			Help: "Events that exhausted their retries and were parked, by projection and event type.",
+		}, []string{"projection", "event_type"}),
+		processedPosition: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: Namespace, Subsystem: "projection", Name: "processed_position",
+			Help: "Commit position of the last event the projection is done with.",
+		}, []string{"projection"}),
+		projectionLag: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: Namespace, Subsystem: "projection", Name: "lag_position",
+			Help: "Event store head position minus the projection's processed position.",
+		}, []string{"projection"}),
+		headPosition: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: Namespace, Subsystem: "eventstore", Name: "head_position",
+			Help: "Commit position of the last event in the event store.",
+		}),
+		headErrors: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: Namespace, Subsystem: "eventstore", Name: "head_errors_total",
+			Help: "Failed reads of the event store head position.",
+		}),
+
+		processed: make(map[string]uint64),
+	}
+
+	collectors := []prometheus.Collector{
+		m.commands, m.commandDuration, m.attempts, m.attemptDuration,
+		m.loadDuration, m.loadedEvents, m.saveDuration, m.savedEvents,
+		m.handlerDuration, m.handlerErrors, m.acks, m.nacks, m.parked,
+		m.processedPosition, m.projectionLag, m.headPosition, m.headErrors,
+	}
+	for _, collector := range collectors {
+		if err := registerer.Register(collector); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// Handler serves the metrics gathered by gatherer in the Prometheus exposition format.
+func Handler(gatherer prometheus.Gatherer) http.Handler {
+	return promhttp.HandlerFor(gatherer, promhttp.HandlerOpts{})
+}
+
+func (m *Metrics) ObserveCommand(command string, outcome commands.Outcome, elapsed time.Duration) {
+	m.commands.WithLabelValues(command, string(outcome)).Inc()
+	m.commandDuration.WithLabelValues(command, string(outcome)).Observe(elapsed.Seconds())
+}
+
+func (m *Metrics) ObserveAttempt(command string, attempt int, outcome aggregate.AttemptOutcome, elapsed time.Duration) {
+	m.attempts.WithLabelValues(command, string(outcome)).Inc()
+	m.attemptDuration.WithLabelValues(command, string(outcome)).Observe(elapsed.Seconds())
+}
+
+// ObserveHead records the event store head position and updates the lag of every
+// projection that has processed an event.
+func (m *Metrics) ObserveHead(position uint64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.head = position
+	m.headPosition.Set(float64(position))
+	for projection := range m.processed {
+		m.updateLag(projection)
+	}
+}
+
+func (m *Metrics) observeProcessed(projection string, position uint64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.processed[projection] = position
+	m.processedPosition.WithLabelValues(projection).Set(float64(position))
+	m.updateLag(projection)
+}
+
+// updateLag must be called with mu held. The head is polled, so a projection can be
+// ahead of the last known head; that counts as no lag.
+func (m *Metrics) updateLag(projection string) {
+	var lag uint64
+	if processed := m.processed[projection]; m.head > processed {
+		lag = m.head - processed
+	}
+	m.projectionLag.WithLabelValues(projection).Set(float64(lag))
+}
+
+// Projection returns the metrics of the projection with the given name, usually its
+// persistent subscription group.
+func (m *Metrics) Projection(name string) aggregate.ProjectionMetrics {
+	return &projectionMetrics{metrics: m, name: name}
+}
+
+type projectionMetrics struct {
+	metrics *Metrics
+	name    string
+}
+
+func (p *projectionMetrics) ObserveHandler(eventType string, elapsed time.Duration, err error) {
+	p.metrics.handlerDuration.WithLabelValues(p.name, eventType).Observe(elapsed.Seconds())
+	if err != nil {
+		p.metrics.handlerErrors.WithLabelValues(p.name, eventType).Inc()
+	}
+}
+
+func (p *projectionMetrics) ObserveAck(eventType string) {
+	p.metrics.acks.WithLabelValues(p.name, eventType).Inc()
+}
+
+func (p *projectionMetrics) ObserveNack(eventType string, action esdb.Nack_Action) {
+	p.metrics.nacks.WithLabelValues(p.name, eventType, nackActionName(action)).Inc()
+}
+
+func (p *projectionMetrics) ObserveParked(eventType string) {
+	p.metrics.parked.WithLabelValues(p.name, eventType).Inc()
+}
+
+func (p *projectionMetrics) ObserveProcessed(position uint64) {
+	p.metrics.observeProcessed(p.name, position)
+}
+
+func nackActionName(action esdb.Nack_Action) string {
+	switch action {
+	case esdb.Nack_Unknown:
+		return "unknown"
+	case esdb.Nack_Park:
+		return "park"
+	case esdb.Nack_Retry:
+		return "retry"
+	case esdb.Nack_Skip:
+		return "skip"
+	case esdb.Nack_Stop:
+		return "stop"
+	}
+	return strconv.Itoa(int(action))
+}
@@ -0,0 +1,190 @@
+package metrics_test
+
+import (
+	"context"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/EventStore/EventStore-Client-Go/esdb"
+	"github.com/novabankapp/wallet.data/es/aggregate"
+	"github.com/novabankapp/wallet.data/es/commands"
+	v1 "github.com/novabankapp/wallet.data/es/events/v1"
+	"github.com/novabankapp/wallet.data/es/store"
+	"github.com/novabankapp/wallet.data/metrics"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/shopspring/decimal"
+)
+
+func newMetrics(t *testing.T) (*metrics.Metrics, *prometheus.Registry) {
+	t.Helper()
+	registry := prometheus.NewRegistry()
+	m, err := metrics.New(registry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m, registry
+}
+
+// value returns the value of the counter or gauge with the given labels, or the sample
+// count of a histogram.
+func value(t *testing.T, registry *prometheus.Registry, name string, labels map[string]string) float64 {
+	t.Helper()
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, family := range families {
+		if family.GetName() != name {
+			continue
+		}
+	metrics:
+		for _, metric := range family.GetMetric() {
+			for _, label := range metric.GetLabel() {
+				if want, ok := labels[label.GetName()]; ok && want != label.GetValue() {
+					continue metrics
+				}
+			}
+			switch {
+			case metric.Counter != nil:
+				return metric.Counter.GetValue()
+			case metric.Gauge != nil:
+				return metric.Gauge.GetValue()
+			case metric.Histogram != nil:
+				return float64(metric.Histogram.GetSampleCount())
+			}
+		}
+	}
+	return 0
+}
+
+func TestCommandAndAggregateMetrics(t *testing.T) {
+	m, registry := newMetrics(t)
+	executor := aggregate.NewWalletCommandExecutor(m.InstrumentAggregateStore(store.NewMemoryEventStore()))
+	executor.Metrics = m
+	bus := commands.NewBus(commands.Metrics(m))
+	commands.RegisterWalletHandlers(bus, executor)
+
+	ctx := context.Background()
+	dispatch := func(cmd commands.Command) error {
+		_, err := bus.Dispatch(ctx, cmd)
+		return err
+	}
+	if err := dispatch(commands.CreateWalletCommand{ID: "w-1", Amount: decimal.NewFromInt(10), UserID: "u-1", AccountID: "a-1"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := dispatch(commands.DebitWalletCommand{ID: "w-1", CreditWalletID: "w-2", Amount: decimal.NewFromInt(3), Description: "rent"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := dispatch(commands.DebitWalletCommand{ID: "w-1", CreditWalletID: "w-2", Amount: decimal.NewFromInt(30), Description: "rent"}); !errors.Is(err, aggregate.ErrInsufficientFunds) {
+		t.Fatalf("expected %v, got %v", aggregate.ErrInsufficientFunds, err)
+	}
+
+	for _, c := range []struct {
+		name   string
+		labels map[string]string
+		want   float64
+	}{
+		{"wallet_command_total", map[string]string{"command": commands.DebitWallet, "outcome": string(commands.OutcomeSucceeded)}, 1},
+		{"wallet_command_total", map[string]string{"command": commands.DebitWallet, "outcome": string(commands.OutcomeRejected)}, 1},
+		{"wallet_command_duration_seconds", map[string]string{"command": commands.CreateWallet}, 1},
+		{"wallet_command_attempts_total", map[string]string{"command": commands.DebitWallet, "outcome": string(aggregate.AttemptRejected)}, 1},
+		{"wallet_aggregate_load_duration_seconds", map[string]string{"aggregate": "wallet", "outcome": "ok"}, 3},
+		{"wallet_aggregate_saved_events_total", map[string]string{"aggregate": "wallet", "event_type": v1.WalletCreated}, 1},
+		{"wallet_aggregate_saved_events_total", map[string]string{"aggregate": "wallet", "event_type": v1.WalletDebited}, 1},
+		{"wallet_aggregate_save_duration_seconds", map[string]string{"aggregate": "wallet", "outcome": "ok"}, 2},
+	} {
+		if got := value(t, registry, c.name, c.labels); got != c.want {
+			t.Errorf("%s%v = %v, want %v", c.name, c.labels, got, c.want)
+		}
+	}
+}
+
+func TestProjectionMetricsAndLag(t *testing.T) {
+	m, registry := newMetrics(t)
+	projection := m.Projection("wallet-projection")
+	labels := map[string]string{"projection": "wallet-projection", "event_type": v1.WalletCredited}
+
+	projection.ObserveHandler(v1.WalletCredited, time.Millisecond, errors.New("cassandra unavailable"))
+	projection.ObserveHandler(v1.WalletCredited, time.Millisecond, nil)
+	projection.ObserveAck(v1.WalletCredited)
+	projection.ObserveNack(v1.WalletCredited, esdb.Nack_Park)
+	projection.ObserveParked(v1.WalletCredited)
+
+	if got := value(t, registry, "wallet_projection_handler_duration_seconds", labels); got != 2 {
+		t.Errorf("handler observations %v", got)
+	}
+	if got := value(t, registry, "wallet_projection_handler_errors_total", labels); got != 1 {
+		t.Errorf("handler errors %v", got)
+	}
+	if got := value(t, registry, "wallet_projection_nacks_total", map[string]string{"action": "park"}); got != 1 {
+		t.Errorf("nacks %v", got)
+	}
+	if got := value(t, registry, "wallet_projection_parked_total", labels); got != 1 {
+		t.Errorf("parked %v", got)
+	}
+
+	lag := func() float64 {
+		return value(t, registry, "wallet_projection_lag_position", map[string]string{"projection": "wallet-projection"})
+	}
+	m.ObserveHead(10)
+	projection.ObserveProcessed(4)
+	if got := lag(); got != 6 {
+		t.Errorf("lag %v, want 6", got)
+	}
+	m.ObserveHead(12)
+	if got := lag(); got != 8 {
+		t.Errorf("lag %v after the head moved, want 8", got)
+	}
+	projection.ObserveProcessed(13)
+	if got := lag(); got != 0 {
+		t.Errorf("lag %v ahead of the head, want 0", got)
+	}
+}
+
+func TestWatchHeadFollowsTheStore(t *testing.T) {
+	m, registry := newMetrics(t)
+	db := store.NewMemoryEventStore()
+	wallet := aggregate.NewWalletAggregateWithID("w-1")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := wallet.CreateWallet(ctx, decimal.NewFromInt(1), "opening", "u-1", "a-1", "w-1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := wallet.CreditWallet(ctx, "w-2", decimal.NewFromInt(1), "top up"); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Save(ctx, wallet); err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		m.WatchHead(ctx, db, time.Millisecond)
+		close(done)
+	}()
+	deadline := time.Now().Add(5 * time.Second)
+	for value(t, registry, "wallet_eventstore_head_position", nil) != 1 {
+		if time.Now().After(deadline) {
+			t.Fatal("head position was not observed")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	<-done
+}
+
+func TestHandlerServesTheRegistry(t *testing.T) {
+	m, registry := newMetrics(t)
+	m.ObserveHead(42)
+
+	recorder := httptest.NewRecorder()
+	metrics.Handler(registry).ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+	body, _ := io.ReadAll(recorder.Result().Body)
+	if !strings.Contains(string(body), "wallet_eventstore_head_position 42") {
+		t.Fatalf("metrics page:\n%s", body)
+	}
+}
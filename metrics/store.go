@@ -0,0 +1,85 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	es "github.com/novabankapp/common.data/eventstore"
+	"github.com/novabankapp/wallet.data/es/aggregate"
+	"github.com/novabankapp/wallet.data/es/store"
+)
+
+const (
+	outcomeOK       = "ok"
+	outcomeConflict = "conflict"
+	outcomeError    = "error"
+)
+
+// InstrumentAggregateStore records load and save times and event counts of the
+// aggregates going through s.
+func (m *Metrics) InstrumentAggregateStore(s es.AggregateStore) es.AggregateStore {
+	return &instrumentedStore{AggregateStore: s, metrics: m}
+}
+
+type instrumentedStore struct {
+	es.AggregateStore
+	metrics *Metrics
+}
+
+func (s *instrumentedStore) Load(ctx context.Context, a es.Aggregate) error {
+	aggregateType := string(a.GetType())
+	before := a.GetVersion()
+	started := time.Now()
+	err := s.AggregateStore.Load(ctx, a)
+	outcome := outcomeOK
+	if err != nil {
+		outcome = outcomeError
+	}
+	s.metrics.loadDuration.WithLabelValues(aggregateType, outcome).Observe(time.Since(started).Seconds())
+	if err == nil {
+		s.metrics.loadedEvents.WithLabelValues(aggregateType).Observe(float64(a.GetVersion() - before))
+	}
+	return err
+}
+
+func (s *instrumentedStore) Save(ctx context.Context, a es.Aggregate) error {
+	aggregateType := string(a.GetType())
+	events := a.GetUncommittedEvents()
+	started := time.Now()
+	err := s.AggregateStore.Save(ctx, a)
+	outcome := outcomeOK
+	switch {
+	case aggregate.IsConcurrencyConflict(err):
+		outcome = outcomeConflict
+	case err != nil:
+		outcome = outcomeError
+	}
+	s.metrics.saveDuration.WithLabelValues(aggregateType, outcome).Observe(time.Since(started).Seconds())
+	if err == nil {
+		for _, evt := range events {
+			s.metrics.savedEvents.WithLabelValues(aggregateType, evt.GetEventType()).Inc()
+		}
+	}
+	return err
+}
+
+// WatchHead polls the event store head position every interval until ctx is done, so
+// that projection lag stays current while no events are processed.
+func (m *Metrics) WatchHead(ctx context.Context, head store.HeadReader, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		position, err := head.HeadPosition(ctx)
+		switch {
+		case err == nil:
+			m.ObserveHead(position)
+		case ctx.Err() == nil:
+			m.headErrors.Inc()
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -23,6 +23,8 @@ func InitCassandra(session *gocqlx.Session) error {
 
 	migrate.Callback = reg.Callback
 	ctx := context.Background()
+	// Files run in name order and must keep it: a new file has to sort after the ones
+	// already applied, hence the numbered cassandra_wallets_ prefix.
 	er := migrate.FromFS(ctx, *session, Files)
 
 	list, err := migrate.List(ctx, *session)
@@ -0,0 +1,603 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.2.0
+// - protoc             (unknown)
+// source: wallet/v1/wallet.proto
+
+package walletv1
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+// WalletServiceClient is the client API for WalletService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type WalletServiceClient interface {
+	CreateWallet(ctx context.Context, in *CreateWalletRequest, opts ...grpc.CallOption) (*CommandResponse, error)
+	CreditWallet(ctx context.Context, in *CreditWalletRequest, opts ...grpc.CallOption) (*CommandResponse, error)
+	DebitWallet(ctx context.Context, in *DebitWalletRequest, opts ...grpc.CallOption) (*CommandResponse, error)
+	ReserveWalletCredit(ctx context.Context, in *HoldRequest, opts ...grpc.CallOption) (*CommandResponse, error)
+	ReleaseWalletCredit(ctx context.Context, in *HoldRequest, opts ...grpc.CallOption) (*CommandResponse, error)
+	LockWallet(ctx context.Context, in *RestrictionRequest, opts ...grpc.CallOption) (*CommandResponse, error)
+	UnlockWallet(ctx context.Context, in *StatusChangeRequest, opts ...grpc.CallOption) (*CommandResponse, error)
+	BlacklistWallet(ctx context.Context, in *RestrictionRequest, opts ...grpc.CallOption) (*CommandResponse, error)
+	UnBlacklistWallet(ctx context.Context, in *StatusChangeRequest, opts ...grpc.CallOption) (*CommandResponse, error)
+	DeleteWallet(ctx context.Context, in *StatusChangeRequest, opts ...grpc.CallOption) (*CommandResponse, error)
+	GetWallet(ctx context.Context, in *GetWalletRequest, opts ...grpc.CallOption) (*Wallet, error)
+	GetWalletState(ctx context.Context, in *GetWalletRequest, opts ...grpc.CallOption) (*WalletState, error)
+	ListTransactions(ctx context.Context, in *ListTransactionsRequest, opts ...grpc.CallOption) (*ListTransactionsResponse, error)
+	// WatchWalletEvents streams the events of one wallet as they are written.
+	WatchWalletEvents(ctx context.Context, in *WatchWalletEventsRequest, opts ...grpc.CallOption) (WalletService_WatchWalletEventsClient, error)
+}
+
+type walletServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewWalletServiceClient(cc grpc.ClientConnInterface) WalletServiceClient {
+	return &walletServiceClient{cc}
+}
+
+func (c *walletServiceClient) CreateWallet(ctx context.Context, in *CreateWalletRequest, opts ...grpc.CallOption) (*CommandResponse, error) {
+	out := new(CommandResponse)
+	err := c.cc.Invoke(ctx, "/novabank.wallet.v1.WalletService/CreateWallet", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *walletServiceClient) CreditWallet(ctx context.Context, in *CreditWalletRequest, opts ...grpc.CallOption) (*CommandResponse, error) {
+	out := new(CommandResponse)
+	err := c.cc.Invoke(ctx, "/novabank.wallet.v1.WalletService/CreditWallet", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *walletServiceClient) DebitWallet(ctx context.Context, in *DebitWalletRequest, opts ...grpc.CallOption) (*CommandResponse, error) {
+	out := new(CommandResponse)
+	err := c.cc.Invoke(ctx, "/novabank.wallet.v1.WalletService/DebitWallet", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *walletServiceClient) ReserveWalletCredit(ctx context.Context, in *HoldRequest, opts ...grpc.CallOption) (*CommandResponse, error) {
+	out := new(CommandResponse)
+	err := c.cc.Invoke(ctx, "/novabank.wallet.v1.WalletService/ReserveWalletCredit", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *walletServiceClient) ReleaseWalletCredit(ctx context.Context, in *HoldRequest, opts ...grpc.CallOption) (*CommandResponse, error) {
+	out := new(CommandResponse)
+	err := c.cc.Invoke(ctx, "/novabank.wallet.v1.WalletService/ReleaseWalletCredit", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *walletServiceClient) LockWallet(ctx context.Context, in *RestrictionRequest, opts ...grpc.CallOption) (*CommandResponse, error) {
+	out := new(CommandResponse)
+	err := c.cc.Invoke(ctx, "/novabank.wallet.v1.WalletService/LockWallet", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *walletServiceClient) UnlockWallet(ctx context.Context, in *StatusChangeRequest, opts ...grpc.CallOption) (*CommandResponse, error) {
+	out := new(CommandResponse)
+	err := c.cc.Invoke(ctx, "/novabank.wallet.v1.WalletService/UnlockWallet", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *walletServiceClient) BlacklistWallet(ctx context.Context, in *RestrictionRequest, opts ...grpc.CallOption) (*CommandResponse, error) {
+	out := new(CommandResponse)
+	err := c.cc.Invoke(ctx, "/novabank.wallet.v1.WalletService/BlacklistWallet", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *walletServiceClient) UnBlacklistWallet(ctx context.Context, in *StatusChangeRequest, opts ...grpc.CallOption) (*CommandResponse, error) {
+	out := new(CommandResponse)
+	err := c.cc.Invoke(ctx, "/novabank.wallet.v1.WalletService/UnBlacklistWallet", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *walletServiceClient) DeleteWallet(ctx context.Context, in *StatusChangeRequest, opts ...grpc.CallOption) (*CommandResponse, error) {
+	out := new(CommandResponse)
+	err := c.cc.Invoke(ctx, "/novabank.wallet.v1.WalletService/DeleteWallet", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *walletServiceClient) GetWallet(ctx context.Context, in *GetWalletRequest, opts ...grpc.CallOption) (*Wallet, error) {
+	out := new(Wallet)
+	err := c.cc.Invoke(ctx, "/novabank.wallet.v1.WalletService/GetWallet", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *walletServiceClient) GetWalletState(ctx context.Context, in *GetWalletRequest, opts ...grpc.CallOption) (*WalletState, error) {
+	out := new(WalletState)
+	err := c.cc.Invoke(ctx, "/novabank.wallet.v1.WalletService/GetWalletState", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *walletServiceClient) ListTransactions(ctx context.Context, in *ListTransactionsRequest, opts ...grpc.CallOption) (*ListTransactionsResponse, error) {
+	out := new(ListTransactionsResponse)
+	err := c.cc.Invoke(ctx, "/novabank.wallet.v1.WalletService/ListTransactions", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *walletServiceClient) WatchWalletEvents(ctx context.Context, in *WatchWalletEventsRequest, opts ...grpc.CallOption) (WalletService_WatchWalletEventsClient, error) {
+	stream, err := c.cc.NewStream(ctx, &WalletService_ServiceDesc.Streams[0], "/novabank.wallet.v1.WalletService/WatchWalletEvents", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &walletServiceWatchWalletEventsClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type WalletService_WatchWalletEventsClient interface {
+	Recv() (*WalletEvent, error)
+	grpc.ClientStream
+}
+
+type walletServiceWatchWalletEventsClient struct {
+	grpc.ClientStream
+}
+
+func (x *walletServiceWatchWalletEventsClient) Recv() (*WalletEvent, error) {
+	m := new(WalletEvent)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// WalletServiceServer is the server API for WalletService service.
+// All implementations must embed UnimplementedWalletServiceServer
+// for forward compatibility
+type WalletServiceServer interface {
+	CreateWallet(context.Context, *CreateWalletRequest) (*CommandResponse, error)
+	CreditWallet(context.Context, *CreditWalletRequest) (*CommandResponse, error)
+	DebitWallet(context.Context, *DebitWalletRequest) (*CommandResponse, error)
+	ReserveWalletCredit(context.Context, *HoldRequest) (*CommandResponse, error)
+	ReleaseWalletCredit(context.Context, *HoldRequest) (*CommandResponse, error)
+	LockWallet(context.Context, *RestrictionRequest) (*CommandResponse, error)
+	UnlockWallet(context.Context, *StatusChangeRequest) (*CommandResponse, error)
+	BlacklistWallet(context.Context, *RestrictionRequest) (*CommandResponse, error)
+	UnBlacklistWallet(context.Context, *StatusChangeRequest) (*CommandResponse, error)
+	DeleteWallet(context.Context, *StatusChangeRequest) (*CommandResponse, error)
+	GetWallet(context.Context, *GetWalletRequest) (*Wallet, error)
+	GetWalletState(context.Context, *GetWalletRequest) (*WalletState, error)
+	ListTransactions(context.Context, *ListTransactionsRequest) (*ListTransactionsResponse, error)
+	// WatchWalletEvents streams the events of one wallet as they are written.
+	WatchWalletEvents(*WatchWalletEventsRequest, WalletService_WatchWalletEventsServer) error
+	mustEmbedUnimplementedWalletServiceServer()
+}
+
+// UnimplementedWalletServiceServer must be embedded to have forward compatible implementations.
+type UnimplementedWalletServiceServer struct {
+}
+
+func (UnimplementedWalletServiceServer) CreateWallet(context.Context, *CreateWalletRequest) (*CommandResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateWallet not implemented")
+}
+func (UnimplementedWalletServiceServer) CreditWallet(context.Context, *CreditWalletRequest) (*CommandResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreditWallet not implemented")
+}
+func (UnimplementedWalletServiceServer) DebitWallet(context.Context, *DebitWalletRequest) (*CommandResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DebitWallet not implemented")
+}
+func (UnimplementedWalletServiceServer) ReserveWalletCredit(context.Context, *HoldRequest) (*CommandResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ReserveWalletCredit not implemented")
+}
+func (UnimplementedWalletServiceServer) ReleaseWalletCredit(context.Context, *HoldRequest) (*CommandResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ReleaseWalletCredit not implemented")
+}
+func (UnimplementedWalletServiceServer) LockWallet(context.Context, *RestrictionRequest) (*CommandResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method LockWallet not implemented")
+}
+func (UnimplementedWalletServiceServer) UnlockWallet(context.Context, *StatusChangeRequest) (*CommandResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UnlockWallet not implemented")
+}
+func (UnimplementedWalletServiceServer) BlacklistWallet(context.Context, *RestrictionRequest) (*CommandResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method BlacklistWallet not implemented")
+}
+func (UnimplementedWalletServiceServer) UnBlacklistWallet(context.Context, *StatusChangeRequest) (*CommandResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UnBlacklistWallet not implemented")
+}
+func (UnimplementedWalletServiceServer) DeleteWallet(context.Context, *StatusChangeRequest) (*CommandResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteWallet not implemented")
+}
+func (UnimplementedWalletServiceServer) GetWallet(context.Context, *GetWalletRequest) (*Wallet, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetWallet not implemented")
+}
+func (UnimplementedWalletServiceServer) GetWalletState(context.Context, *GetWalletRequest) (*WalletState, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetWalletState not implemented")
+}
+func (UnimplementedWalletServiceServer) ListTransactions(context.Context, *ListTransactionsRequest) (*ListTransactionsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListTransactions not implemented")
+}
+func (UnimplementedWalletServiceServer) WatchWalletEvents(*WatchWalletEventsRequest, WalletService_WatchWalletEventsServer) error {
+	return status.Errorf(codes.Unimplemented, "method WatchWalletEvents not implemented")
+}
+func (UnimplementedWalletServiceServer) mustEmbedUnimplementedWalletServiceServer() {}
+
+// UnsafeWalletServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to WalletServiceServer will
+// result in compilation errors.
+type UnsafeWalletServiceServer interface {
+	mustEmbedUnimplementedWalletServiceServer()
+}
+
+func RegisterWalletServiceServer(s grpc.ServiceRegistrar, srv WalletServiceServer) {
+	s.RegisterService(&WalletService_ServiceDesc, srv)
+}
+
+func _WalletService_CreateWallet_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateWalletRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WalletServiceServer).CreateWallet(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/novabank.wallet.v1.WalletService/CreateWallet",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WalletServiceServer).CreateWallet(ctx, req.(*CreateWalletRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _WalletService_CreditWallet_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreditWalletRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WalletServiceServer).CreditWallet(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/novabank.wallet.v1.WalletService/CreditWallet",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WalletServiceServer).CreditWallet(ctx, req.(*CreditWalletRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _WalletService_DebitWallet_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DebitWalletRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WalletServiceServer).DebitWallet(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/novabank.wallet.v1.WalletService/DebitWallet",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WalletServiceServer).DebitWallet(ctx, req.(*DebitWalletRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _WalletService_ReserveWalletCredit_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(HoldRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WalletServiceServer).ReserveWalletCredit(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/novabank.wallet.v1.WalletService/ReserveWalletCredit",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WalletServiceServer).ReserveWalletCredit(ctx, req.(*HoldRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _WalletService_ReleaseWalletCredit_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(HoldRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WalletServiceServer).ReleaseWalletCredit(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/novabank.wallet.v1.WalletService/ReleaseWalletCredit",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WalletServiceServer).ReleaseWalletCredit(ctx, req.(*HoldRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _WalletService_LockWallet_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RestrictionRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WalletServiceServer).LockWallet(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/novabank.wallet.v1.WalletService/LockWallet",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WalletServiceServer).LockWallet(ctx, req.(*RestrictionRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _WalletService_UnlockWallet_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(StatusChangeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WalletServiceServer).UnlockWallet(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/novabank.wallet.v1.WalletService/UnlockWallet",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WalletServiceServer).UnlockWallet(ctx, req.(*StatusChangeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _WalletService_BlacklistWallet_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RestrictionRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WalletServiceServer).BlacklistWallet(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/novabank.wallet.v1.WalletService/BlacklistWallet",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WalletServiceServer).BlacklistWallet(ctx, req.(*RestrictionRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _WalletService_UnBlacklistWallet_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(StatusChangeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WalletServiceServer).UnBlacklistWallet(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/novabank.wallet.v1.WalletService/UnBlacklistWallet",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WalletServiceServer).UnBlacklistWallet(ctx, req.(*StatusChangeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _WalletService_DeleteWallet_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(StatusChangeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WalletServiceServer).DeleteWallet(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/novabank.wallet.v1.WalletService/DeleteWallet",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WalletServiceServer).DeleteWallet(ctx, req.(*StatusChangeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _WalletService_GetWallet_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetWalletRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WalletServiceServer).GetWallet(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/novabank.wallet.v1.WalletService/GetWallet",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WalletServiceServer).GetWallet(ctx, req.(*GetWalletRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _WalletService_GetWalletState_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetWalletRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WalletServiceServer).GetWalletState(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/novabank.wallet.v1.WalletService/GetWalletState",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WalletServiceServer).GetWalletState(ctx, req.(*GetWalletRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _WalletService_ListTransactions_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListTransactionsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WalletServiceServer).ListTransactions(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/novabank.wallet.v1.WalletService/ListTransactions",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WalletServiceServer).ListTransactions(ctx, req.(*ListTransactionsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _WalletService_WatchWalletEvents_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchWalletEventsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(WalletServiceServer).WatchWalletEvents(m, &walletServiceWatchWalletEventsServer{stream})
+}
+
+type WalletService_WatchWalletEventsServer interface {
+	Send(*WalletEvent) error
+	grpc.ServerStream
+}
+
+type walletServiceWatchWalletEventsServer struct {
+	grpc.ServerStream
+}
+
+func (x *walletServiceWatchWalletEventsServer) Send(m *WalletEvent) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+// WalletService_ServiceDesc is the grpc.ServiceDesc for WalletService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var WalletService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "novabank.wallet.v1.WalletService",
+	HandlerType: (*WalletServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "CreateWallet",
+			Handler:    _WalletService_CreateWallet_Handler,
+		},
+		{
+			MethodName: "CreditWallet",
+			Handler:    _WalletService_CreditWallet_Handler,
+		},
+		{
+			MethodName: "DebitWallet",
+			Handler:    _WalletService_DebitWallet_Handler,
+		},
+		{
+			MethodName: "ReserveWalletCredit",
+			Handler:    _WalletService_ReserveWalletCredit_Handler,
+		},
+		{
+			MethodName: "ReleaseWalletCredit",
+			Handler:    _WalletService_ReleaseWalletCredit_Handler,
+		},
+		{
+			MethodName: "LockWallet",
+			Handler:    _WalletService_LockWallet_Handler,
+		},
+		{
+			MethodName: "UnlockWallet",
+			Handler:    _WalletService_UnlockWallet_Handler,
+		},
+		{
+			MethodName: "BlacklistWallet",
+			Handler:    _WalletService_BlacklistWallet_Handler,
+		},
+		{
+			MethodName: "UnBlacklistWallet",
+			Handler:    _WalletService_UnBlacklistWallet_Handler,
+		},
+		{
+			MethodName: "DeleteWallet",
+			Handler:    _WalletService_DeleteWallet_Handler,
+		},
+		{
+			MethodName: "GetWallet",
+			Handler:    _WalletService_GetWallet_Handler,
+		},
+		{
+			MethodName: "GetWalletState",
+			Handler:    _WalletService_GetWalletState_Handler,
+		},
+		{
+			MethodName: "ListTransactions",
+			Handler:    _WalletService_ListTransactions_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "WatchWalletEvents",
+			Handler:       _WalletService_WatchWalletEvents_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "wallet/v1/wallet.proto",
+}
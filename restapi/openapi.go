@@ -0,0 +1,235 @@
+package restapi
+
+import (
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// Version of the API, reported in the OpenAPI document.
+const Version = "1.0.0"
+
+// The subset of OpenAPI 3.0 the generated document uses.
+
+type Document struct {
+	OpenAPI    string               `json:"openapi"`
+	Info       Info                 `json:"info"`
+	Paths      map[string]*PathItem `json:"paths"`
+	Components Components           `json:"components"`
+}
+
+type Info struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+type PathItem struct {
+	Get    *Operation `json:"get,omitempty"`
+	Post   *Operation `json:"post,omitempty"`
+	Delete *Operation `json:"delete,omitempty"`
+}
+
+type Operation struct {
+	OperationID string               `json:"operationId"`
+	Summary     string               `json:"summary,omitempty"`
+	Tags        []string             `json:"tags,omitempty"`
+	Parameters  []Parameter          `json:"parameters,omitempty"`
+	RequestBody *RequestBody         `json:"requestBody,omitempty"`
+	Responses   map[string]*Response `json:"responses"`
+}
+
+// Parameter is either a parameter or, with Ref set, a reference to one in the components.
+type Parameter struct {
+	Ref         string  `json:"$ref,omitempty"`
+	Name        string  `json:"name,omitempty"`
+	In          string  `json:"in,omitempty"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema,omitempty"`
+}
+
+type RequestBody struct {
+	Required bool                  `json:"required"`
+	Content  map[string]*MediaType `json:"content"`
+}
+
+type Response struct {
+	Description string                `json:"description"`
+	Headers     map[string]*Header    `json:"headers,omitempty"`
+	Content     map[string]*MediaType `json:"content,omitempty"`
+}
+
+type Header struct {
+	Description string  `json:"description,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Enum                 []interface{}      `json:"enum,omitempty"`
+	Default              interface{}        `json:"default,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+}
+
+type Components struct {
+	Schemas    map[string]*Schema    `json:"schemas"`
+	Parameters map[string]*Parameter `json:"parameters,omitempty"`
+}
+
+// OpenAPI generates the document from the route table and the request and response types.
+func (s *Server) OpenAPI() *Document {
+	doc := &Document{
+		OpenAPI:    "3.0.3",
+		Info:       Info{Title: "Wallet API", Version: Version},
+		Paths:      make(map[string]*PathItem),
+		Components: Components{Schemas: make(map[string]*Schema)},
+	}
+	problem := doc.schemaOf(reflect.TypeOf(Problem{}))
+	doc.Components.Parameters = map[string]*Parameter{
+		"IdempotencyKey": {
+			Name:        IdempotencyKeyHeader,
+			In:          "header",
+			Description: "retries with the same key and body return the first response",
+			Schema:      &Schema{Type: "string"},
+		},
+		"CorrelationID": {
+			Name:        CorrelationIDHeader,
+			In:          "header",
+			Description: "correlation the request belongs to, recorded with the events it causes",
+			Schema:      &Schema{Type: "string"},
+		},
+		"RequestID": {
+			Name:        RequestIDHeader,
+			In:          "header",
+			Description: "id of the request, recorded as the cause of the events it writes",
+			Schema:      &Schema{Type: "string"},
+		},
+	}
+
+	for _, route := range s.routes() {
+		op := &Operation{
+			OperationID: route.operationID,
+			Summary:     route.summary,
+			Tags:        []string{route.tag},
+			Responses:   make(map[string]*Response),
+		}
+		for _, p := range route.params {
+			schema := p.schema
+			op.Parameters = append(op.Parameters, Parameter{Name: p.name, In: p.in, Description: p.description, Required: p.required, Schema: &schema})
+		}
+		if route.command {
+			op.Parameters = append(op.Parameters,
+				Parameter{Ref: "#/components/parameters/IdempotencyKey"},
+				Parameter{Ref: "#/components/parameters/CorrelationID"},
+				Parameter{Ref: "#/components/parameters/RequestID"},
+			)
+		}
+		if route.body != nil {
+			op.RequestBody = &RequestBody{Required: true, Content: map[string]*MediaType{
+				"application/json": {Schema: doc.schemaOf(reflect.TypeOf(route.body))},
+			}}
+		}
+		success := &Response{
+			Description: http.StatusText(route.status),
+			Headers:     route.headers,
+			Content:     map[string]*MediaType{"application/json": {Schema: doc.schemaOf(reflect.TypeOf(route.response))}},
+		}
+		op.Responses[strconv.Itoa(route.status)] = success
+		for _, status := range append(route.problems, http.StatusInternalServerError) {
+			op.Responses[strconv.Itoa(status)] = &Response{
+				Description: http.StatusText(status),
+				Content:     map[string]*MediaType{ProblemContentType: {Schema: problem}},
+			}
+		}
+
+		item := doc.Paths[route.path]
+		if item == nil {
+			item = &PathItem{}
+			doc.Paths[route.path] = item
+		}
+		switch route.method {
+		case http.MethodGet:
+			item.Get = op
+		case http.MethodPost:
+			item.Post = op
+		case http.MethodDelete:
+			item.Delete = op
+		}
+	}
+	return doc
+}
+
+var (
+	timeType    = reflect.TypeOf(time.Time{})
+	decimalType = reflect.TypeOf(decimal.Decimal{})
+)
+
+// schemaOf describes t; structs are added to the components and referenced.
+func (doc *Document) schemaOf(t reflect.Type) *Schema {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch t {
+	case timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case decimalType:
+		return &Schema{Type: "string", Format: "decimal"}
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.Slice, reflect.Array:
+		return &Schema{Type: "array", Items: doc.schemaOf(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: doc.schemaOf(t.Elem())}
+	case reflect.Struct:
+		ref := &Schema{Ref: "#/components/schemas/" + t.Name()}
+		if _, ok := doc.Components.Schemas[t.Name()]; ok {
+			return ref
+		}
+		schema := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+		doc.Components.Schemas[t.Name()] = schema
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			name := strings.Split(field.Tag.Get("json"), ",")[0]
+			if !field.IsExported() || name == "-" {
+				continue
+			}
+			if name == "" {
+				name = field.Name
+			}
+			property := doc.schemaOf(field.Type)
+			if description := field.Tag.Get("doc"); description != "" && property.Ref == "" {
+				property.Description = description
+			}
+			schema.Properties[name] = property
+			if field.Tag.Get("openapi") == "required" {
+				schema.Required = append(schema.Required, name)
+			}
+		}
+		return ref
+	}
+	return &Schema{}
+}
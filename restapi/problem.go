@@ -0,0 +1,97 @@
+package restapi
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/novabankapp/wallet.data/es/aggregate"
+	"github.com/novabankapp/wallet.data/es/commands"
+	"github.com/novabankapp/wallet.data/es/queries"
+	"github.com/pkg/errors"
+)
+
+const ProblemContentType = "application/problem+json"
+
+// Problem is an RFC 7807 problem details body.
+type Problem struct {
+	Type     string `json:"type" openapi:"required" doc:"URI identifying the kind of problem"`
+	Title    string `json:"title" openapi:"required"`
+	Status   int    `json:"status" openapi:"required"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty" doc:"path of the request"`
+	Field    string `json:"field,omitempty" doc:"the invalid request field, for validation problems"`
+}
+
+const problemTypeBase = "https://wallet.novabank.app/problems/"
+
+type problemKind struct {
+	name   string
+	title  string
+	status int
+}
+
+var (
+	problemInvalid      = problemKind{"invalid-request", "The request is invalid", http.StatusBadRequest}
+	problemUnauthorized = problemKind{"forbidden", "Not allowed to perform this operation", http.StatusForbidden}
+	problemNotFound     = problemKind{"wallet-not-found", "The wallet does not exist", http.StatusNotFound}
+	problemExists       = problemKind{"wallet-exists", "The wallet already exists", http.StatusConflict}
+	problemConflict     = problemKind{"conflict", "The request conflicts with another request", http.StatusConflict}
+	problemRejected     = problemKind{"rejected", "The wallet does not allow this operation", http.StatusUnprocessableEntity}
+	problemInternal     = problemKind{"internal", "Internal error", http.StatusInternalServerError}
+)
+
+func classify(err error) problemKind {
+	switch {
+	case errors.Is(err, queries.ErrWalletNotFound),
+		errors.Is(err, aggregate.ErrWalletNotCreated):
+		return problemNotFound
+	case errors.Is(err, queries.ErrInvalidCursor),
+		errors.Is(err, queries.ErrInvalidFilter),
+		errors.Is(err, aggregate.ErrInvalidAmount):
+		return problemInvalid
+	case errors.Is(err, aggregate.ErrWalletAlreadyCreated):
+		return problemExists
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return problemInternal
+	}
+	switch commands.OutcomeOf(err) {
+	case commands.OutcomeInvalid:
+		return problemInvalid
+	case commands.OutcomeUnauthorized:
+		return problemUnauthorized
+	case commands.OutcomeRejected:
+		return problemRejected
+	case commands.OutcomeConflict:
+		return problemConflict
+	}
+	return problemInternal
+}
+
+// NewProblem describes err for the client. Internal errors get no detail so that
+// internals do not leak.
+func NewProblem(err error, instance string) Problem {
+	kind := classify(err)
+	problem := Problem{Type: problemTypeBase + kind.name, Title: kind.title, Status: kind.status, Instance: instance}
+	if kind != problemInternal {
+		problem.Detail = err.Error()
+	}
+	var validation *commands.ValidationError
+	if errors.As(err, &validation) {
+		problem.Field = validation.Field
+	}
+	return problem
+}
+
+func (s *Server) abort(c *gin.Context, err error) {
+	problem := NewProblem(err, c.Request.URL.Path)
+	if problem.Status == http.StatusInternalServerError {
+		s.Log.Errorf("(restapi) %s %s err: {%v}", c.Request.Method, c.Request.URL.Path, err)
+	}
+	c.Header("Content-Type", ProblemContentType)
+	c.AbortWithStatusJSON(problem.Status, problem)
+}
+
+func invalid(field, reason string) error {
+	return &commands.ValidationError{Command: "request", Field: field, Reason: reason}
+}
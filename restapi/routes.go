@@ -0,0 +1,328 @@
+package restapi
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/novabankapp/wallet.data/domain"
+	"github.com/novabankapp/wallet.data/es/commands"
+	"github.com/novabankapp/wallet.data/es/queries"
+	"github.com/shopspring/decimal"
+)
+
+// route describes one operation, for gin and for the OpenAPI document.
+type route struct {
+	method      string
+	path        string
+	operationID string
+	summary     string
+	tag         string
+	params      []param
+	command     bool
+	body        interface{}
+	status      int
+	response    interface{}
+	headers     map[string]*Header
+	problems    []int
+	handle      gin.HandlerFunc
+}
+
+type param struct {
+	name        string
+	in          string
+	description string
+	schema      Schema
+	required    bool
+}
+
+var walletIDParam = param{name: "id", in: "path", description: "wallet id", schema: Schema{Type: "string"}, required: true}
+
+func (s *Server) routes() []route {
+	commandProblems := []int{http.StatusBadRequest, http.StatusForbidden, http.StatusNotFound, http.StatusConflict, http.StatusUnprocessableEntity}
+	stateChange := func(method, path, operationID, summary string, cmd func(id, description string) commands.Command) route {
+		return route{
+			method: method, path: path, operationID: operationID, summary: summary, tag: "state",
+			params: []param{walletIDParam}, command: true, body: StateChangeRequest{},
+			status: http.StatusOK, response: CommandResponse{}, problems: commandProblems,
+			handle: func(c *gin.Context) {
+				var req StateChangeRequest
+				if !s.bind(c, &req) {
+					return
+				}
+				s.dispatch(c, http.StatusOK, cmd(c.Param("id"), req.Description))
+			},
+		}
+	}
+
+	restriction := func(path, operationID, summary string, cmd func(id string, req RestrictionRequest) commands.Command) route {
+		return route{
+			method: http.MethodPost, path: path, operationID: operationID, summary: summary, tag: "state",
+			params: []param{walletIDParam}, command: true, body: RestrictionRequest{},
+			status: http.StatusOK, response: CommandResponse{}, problems: commandProblems,
+			handle: func(c *gin.Context) {
+				var req RestrictionRequest
+				if !s.bind(c, &req) {
+					return
+				}
+				s.dispatch(c, http.StatusOK, cmd(c.Param("id"), req))
+			},
+		}
+	}
+
+	return []route{
+		{
+			method: http.MethodPost, path: "/wallets", operationID: "createWallet", summary: "Create a wallet", tag: "wallets",
+			command: true, body: CreateWalletRequest{},
+			status: http.StatusCreated, response: CommandResponse{}, problems: commandProblems,
+			headers: map[string]*Header{"Location": {Description: "URL of the new wallet", Schema: &Schema{Type: "string"}}},
+			handle:  s.createWallet,
+		},
+		{
+			method: http.MethodGet, path: "/wallets/{id}", operationID: "getWallet", summary: "Get the balances of a wallet", tag: "wallets",
+			params: []param{walletIDParam},
+			status: http.StatusOK, response: WalletResponse{}, problems: []int{http.StatusNotFound},
+			handle: s.getWallet,
+		},
+		{
+			method: http.MethodDelete, path: "/wallets/{id}", operationID: "deleteWallet", summary: "Delete a wallet", tag: "wallets",
+			params:  []param{walletIDParam, {name: "description", in: "query", description: "why the wallet is deleted", schema: Schema{Type: "string"}, required: true}},
+			command: true, status: http.StatusOK, response: CommandResponse{}, problems: commandProblems,
+			handle: func(c *gin.Context) {
+				s.dispatch(c, http.StatusOK, commands.DeleteWalletCommand{ID: c.Param("id"), Description: c.Query("description")})
+			},
+		},
+		{
+			method: http.MethodGet, path: "/wallets/{id}/state", operationID: "getWalletState", summary: "Get the lock, blacklist and deletion state of a wallet", tag: "state",
+			params: []param{walletIDParam},
+			status: http.StatusOK, response: WalletStateResponse{}, problems: []int{http.StatusNotFound},
+			handle: s.getWalletState,
+		},
+		{
+			method: http.MethodGet, path: "/wallets/{id}/transactions", operationID: "listTransactions", summary: "List the transactions of a wallet, newest first by default", tag: "transactions",
+			params: append([]param{walletIDParam}, transactionQueryParams...),
+			status: http.StatusOK, response: TransactionPageResponse{}, problems: []int{http.StatusBadRequest, http.StatusNotFound},
+			headers: map[string]*Header{"Link": {Description: `URL of the next page, rel="next"`, Schema: &Schema{Type: "string"}}},
+			handle:  s.listTransactions,
+		},
+		{
+			method: http.MethodPost, path: "/wallets/{id}/credits", operationID: "creditWallet", summary: "Credit a wallet", tag: "transactions",
+			params: []param{walletIDParam}, command: true, body: CreditRequest{},
+			status: http.StatusCreated, response: CommandResponse{}, problems: commandProblems,
+			handle: func(c *gin.Context) {
+				var req CreditRequest
+				if !s.bind(c, &req) {
+					return
+				}
+				s.dispatch(c, http.StatusCreated, commands.CreditWalletCommand{ID: c.Param("id"), DebitWalletID: req.DebitWalletID, Amount: req.Amount, Description: req.Description})
+			},
+		},
+		{
+			method: http.MethodPost, path: "/wallets/{id}/debits", operationID: "debitWallet", summary: "Debit a wallet", tag: "transactions",
+			params: []param{walletIDParam}, command: true, body: DebitRequest{},
+			status: http.StatusCreated, response: CommandResponse{}, problems: commandProblems,
+			handle: func(c *gin.Context) {
+				var req DebitRequest
+				if !s.bind(c, &req) {
+					return
+				}
+				s.dispatch(c, http.StatusCreated, commands.DebitWalletCommand{ID: c.Param("id"), CreditWalletID: req.CreditWalletID, Amount: req.Amount, Description: req.Description})
+			},
+		},
+		{
+			method: http.MethodPost, path: "/wallets/{id}/holds", operationID: "reserveWalletCredit", summary: "Hold part of the available balance", tag: "holds",
+			params: []param{walletIDParam}, command: true, body: HoldRequest{},
+			status: http.StatusCreated, response: CommandResponse{}, problems: commandProblems,
+			handle: func(c *gin.Context) {
+				var req HoldRequest
+				if !s.bind(c, &req) {
+					return
+				}
+				s.dispatch(c, http.StatusCreated, commands.ReserveWalletCreditCommand{ID: c.Param("id"), Amount: req.Amount, Description: req.Description})
+			},
+		},
+		{
+			method: http.MethodPost, path: "/wallets/{id}/holds/release", operationID: "releaseWalletCredit", summary: "Release held balance", tag: "holds",
+			params: []param{walletIDParam}, command: true, body: HoldRequest{},
+			status: http.StatusOK, response: CommandResponse{}, problems: commandProblems,
+			handle: func(c *gin.Context) {
+				var req HoldRequest
+				if !s.bind(c, &req) {
+					return
+				}
+				s.dispatch(c, http.StatusOK, commands.ReleaseWalletCreditCommand{ID: c.Param("id"), Amount: req.Amount, Description: req.Description})
+			},
+		},
+		restriction("/wallets/{id}/lock", "lockWallet", "Lock a wallet", func(id string, req RestrictionRequest) commands.Command {
+			return commands.LockWalletCommand{ID: id, Description: req.Description, ReasonCode: domain.ReasonCode(req.ReasonCode),
+				Authority: req.Authority, Evidence: req.Evidence, ExpiresAt: req.ExpiresAt}
+		}),
+		stateChange(http.MethodPost, "/wallets/{id}/unlock", "unlockWallet", "Unlock a wallet", func(id, description string) commands.Command {
+			return commands.UnlockWalletCommand{ID: id, Description: description}
+		}),
+		restriction("/wallets/{id}/blacklist", "blacklistWallet", "Blacklist a wallet", func(id string, req RestrictionRequest) commands.Command {
+			return commands.BlacklistWalletCommand{ID: id, Description: req.Description, ReasonCode: domain.ReasonCode(req.ReasonCode),
+				Authority: req.Authority, Evidence: req.Evidence, ExpiresAt: req.ExpiresAt}
+		}),
+		stateChange(http.MethodPost, "/wallets/{id}/unblacklist", "unBlacklistWallet", "Lift the blacklisting of a wallet", func(id, description string) commands.Command {
+			return commands.UnBlacklistWalletCommand{ID: id, Description: description}
+		}),
+	}
+}
+
+var transactionQueryParams = []param{
+	{name: "cursor", in: "query", description: "next_cursor of the previous page", schema: Schema{Type: "string"}},
+	{name: "limit", in: "query", description: "page size, at most 500", schema: Schema{Type: "integer", Format: "int32", Default: queries.DefaultPageSize}},
+	{name: "order", in: "query", schema: Schema{Type: "string", Enum: []interface{}{"desc", "asc"}, Default: "desc"}},
+	{name: "direction", in: "query", description: "in for credits, out for debits", schema: Schema{Type: "string", Enum: []interface{}{"in", "out"}}},
+	{name: "from", in: "query", description: "inclusive lower bound on created_at", schema: Schema{Type: "string", Format: "date-time"}},
+	{name: "to", in: "query", description: "exclusive upper bound on created_at", schema: Schema{Type: "string", Format: "date-time"}},
+	{name: "counterparty", in: "query", description: "id of the other wallet", schema: Schema{Type: "string"}},
+	{name: "min_amount", in: "query", schema: Schema{Type: "string", Format: "decimal"}},
+	{name: "max_amount", in: "query", schema: Schema{Type: "string", Format: "decimal"}},
+	{name: "description", in: "query", description: "case-insensitive substring of the description", schema: Schema{Type: "string"}},
+}
+
+func (s *Server) bind(c *gin.Context, req interface{}) bool {
+	if err := c.ShouldBindJSON(req); err != nil {
+		s.abort(c, invalid("body", "must be a JSON object: "+err.Error()))
+		return false
+	}
+	return true
+}
+
+func (s *Server) dispatch(c *gin.Context, status int, cmd commands.Command) {
+	result, err := s.Bus.Dispatch(commandContext(c), cmd)
+	if err != nil {
+		s.abort(c, err)
+		return
+	}
+	c.JSON(status, toCommandResponse(result))
+}
+
+func (s *Server) createWallet(c *gin.Context) {
+	var req CreateWalletRequest
+	if !s.bind(c, &req) {
+		return
+	}
+	cmd := commands.CreateWalletCommand{ID: req.WalletID, Amount: req.Amount, Description: req.Description, UserID: req.UserID, AccountID: req.AccountID}
+	result, err := s.Bus.Dispatch(commandContext(c), cmd)
+	if err != nil {
+		s.abort(c, err)
+		return
+	}
+	c.Header("Location", "/wallets/"+url.PathEscape(result.WalletID))
+	c.JSON(http.StatusCreated, toCommandResponse(result))
+}
+
+func (s *Server) getWallet(c *gin.Context) {
+	wallet, err := s.Queries.GetWallet(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		s.abort(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, toWalletResponse(wallet))
+}
+
+func (s *Server) getWalletState(c *gin.Context) {
+	state, err := s.Queries.GetWalletState(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		s.abort(c, err)
+		return
+	}
+	restrictions := make([]RestrictionResponse, 0, len(state.Restrictions))
+	for _, r := range state.Restrictions {
+		restrictions = append(restrictions, RestrictionResponse{
+			Kind:        string(r.Kind),
+			ReasonCode:  string(r.ReasonCode),
+			Authority:   r.Authority,
+			Description: r.Description,
+			Evidence:    r.Evidence,
+			ImposedAt:   r.ImposedAt,
+			ExpiresAt:   r.ExpiresAt,
+		})
+	}
+	c.JSON(http.StatusOK, WalletStateResponse{
+		WalletID:      c.Param("id"),
+		IsLocked:      state.IsLocked,
+		IsBlacklisted: state.IsBlacklisted,
+		IsDeleted:     state.IsDeleted,
+		Restrictions:  restrictions,
+	})
+}
+
+func (s *Server) listTransactions(c *gin.Context) {
+	filter, page, err := transactionQuery(c)
+	if err != nil {
+		s.abort(c, err)
+		return
+	}
+	result, err := s.Queries.GetWalletTransactions(c.Request.Context(), c.Param("id"), filter, page)
+	if err != nil {
+		s.abort(c, err)
+		return
+	}
+	resp := TransactionPageResponse{Transactions: make([]TransactionResponse, 0, len(result.Transactions)), NextCursor: result.NextCursor}
+	for _, tx := range result.Transactions {
+		resp.Transactions = append(resp.Transactions, toTransactionResponse(tx))
+	}
+	if result.NextCursor != "" {
+		next := *c.Request.URL
+		query := next.Query()
+		query.Set("cursor", result.NextCursor)
+		next.RawQuery = query.Encode()
+		c.Header("Link", "<"+next.RequestURI()+`>; rel="next"`)
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+func transactionQuery(c *gin.Context) (queries.TransactionFilter, queries.TransactionPageRequest, error) {
+	filter := queries.TransactionFilter{
+		Direction:            queries.Direction(c.Query("direction")),
+		CounterpartyWalletId: c.Query("counterparty"),
+		Description:          c.Query("description"),
+	}
+	page := queries.TransactionPageRequest{Cursor: c.Query("cursor"), Order: queries.SortNewestFirst}
+
+	if value := c.Query("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit <= 0 {
+			return filter, page, invalid("limit", "must be a positive integer")
+		}
+		page.Limit = limit
+	}
+	switch order := c.Query("order"); order {
+	case "", string(queries.SortNewestFirst):
+	case string(queries.SortOldestFirst):
+		page.Order = queries.SortOldestFirst
+	default:
+		return filter, page, invalid("order", "must be asc or desc")
+	}
+	for _, bound := range []struct {
+		name string
+		into **time.Time
+	}{{"from", &filter.From}, {"to", &filter.To}} {
+		if value := c.Query(bound.name); value != "" {
+			t, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				return filter, page, invalid(bound.name, "must be an RFC 3339 timestamp")
+			}
+			*bound.into = &t
+		}
+	}
+	for _, bound := range []struct {
+		name string
+		into **decimal.Decimal
+	}{{"min_amount", &filter.MinAmount}, {"max_amount", &filter.MaxAmount}} {
+		if value := c.Query(bound.name); value != "" {
+			amount, err := decimal.NewFromString(value)
+			if err != nil {
+				return filter, page, invalid(bound.name, "must be a decimal number")
+			}
+			*bound.into = &amount
+		}
+	}
+	return filter, page, nil
+}
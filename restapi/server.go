@@ -0,0 +1,121 @@
+// Package restapi serves the wallet commands and read-model queries as a REST/JSON API
+// on gin. The route table in routes drives both the gin handlers and the OpenAPI document.
+package restapi
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/novabankapp/common.data/logger"
+	"github.com/novabankapp/wallet.data/es/commands"
+	"github.com/novabankapp/wallet.data/es/metadata"
+	"github.com/novabankapp/wallet.data/es/queries"
+	"github.com/novabankapp/wallet.data/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// IdempotencyKeyHeader carries the idempotency key of a command request; retries with the
+// same key and body get the first response.
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// CorrelationIDHeader and RequestIDHeader name the correlation and the request a command
+// request belongs to; they become the correlation and causation ids of its events.
+const (
+	CorrelationIDHeader = "X-Correlation-ID"
+	RequestIDHeader     = "X-Request-ID"
+)
+
+const OpenAPIPath = "/openapi.json"
+
+type Server struct {
+	Bus     *commands.Bus
+	Queries *queries.WalletTransactionQueries
+	Log     logger.Logger
+}
+
+func NewServer(bus *commands.Bus, queries *queries.WalletTransactionQueries, log logger.Logger) *Server {
+	return &Server{Bus: bus, Queries: queries, Log: log}
+}
+
+// Handler returns a gin engine with recovery, tracing and every route registered.
+func (s *Server) Handler() http.Handler {
+	engine := gin.New()
+	engine.Use(gin.Recovery(), Tracing(tracing.Tracer()))
+	s.Register(engine)
+	return engine
+}
+
+// Register adds the API routes and the OpenAPI document to r.
+func (s *Server) Register(r gin.IRoutes) {
+	for _, route := range s.routes() {
+		r.Handle(route.method, ginPath(route.path), route.handle)
+	}
+	document := s.OpenAPI()
+	r.GET(OpenAPIPath, func(c *gin.Context) {
+		c.JSON(http.StatusOK, document)
+	})
+}
+
+// ginPath turns "/wallets/{id}" into "/wallets/:id".
+func ginPath(path string) string {
+	parts := strings.Split(path, "/")
+	for i, part := range parts {
+		if strings.HasPrefix(part, "{") && strings.HasSuffix(part, "}") {
+			parts[i] = ":" + strings.Trim(part, "{}")
+		}
+	}
+	return strings.Join(parts, "/")
+}
+
+// Tracing starts a server span per request, continuing the caller's trace when the
+// request carries one, so that the command and query spans join it.
+func Tracing(tracer trace.Tracer) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := tracing.Propagator.Extract(c.Request.Context(), propagation.HeaderCarrier(c.Request.Header))
+		ctx, span := tracer.Start(ctx, c.Request.Method+" "+c.FullPath(),
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				attribute.String("http.method", c.Request.Method),
+				attribute.String("http.target", c.Request.URL.Path),
+				attribute.String("http.route", c.FullPath()),
+			),
+		)
+		defer span.End()
+		c.Request = c.Request.WithContext(ctx)
+
+		c.Next()
+
+		status := c.Writer.Status()
+		span.SetAttributes(attribute.Int("http.status_code", status))
+		if len(c.Errors) > 0 {
+			tracing.TraceErr(span, c.Errors.Last())
+		} else if status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(status))
+		}
+	}
+}
+
+// commandContext carries the idempotency key of the request and the event metadata the
+// request determines into the command bus. The actor is left to the principal an
+// authentication middleware put into the request context.
+func commandContext(c *gin.Context) context.Context {
+	ctx := c.Request.Context()
+	if key := c.GetHeader(IdempotencyKeyHeader); key != "" {
+		ctx = commands.WithIdempotencyKey(ctx, key)
+	}
+	m, _ := metadata.FromContext(ctx)
+	m.Channel = metadata.ChannelREST
+	m.ClientIP = c.ClientIP()
+	if id := c.GetHeader(CorrelationIDHeader); id != "" {
+		m.CorrelationID = id
+	}
+	if id := c.GetHeader(RequestIDHeader); id != "" {
+		m.CausationID = id
+	}
+	return metadata.WithMetadata(ctx, m)
+}
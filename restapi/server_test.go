@@ -0,0 +1,313 @@
+package restapi_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/novabankapp/wallet.data/domain"
+	"github.com/novabankapp/wallet.data/es/aggregate"
+	"github.com/novabankapp/wallet.data/es/commands"
+	"github.com/novabankapp/wallet.data/es/metadata"
+	"github.com/novabankapp/wallet.data/es/queries"
+	"github.com/novabankapp/wallet.data/es/readmodel/readmodeltest"
+	"github.com/novabankapp/wallet.data/es/store"
+	"github.com/novabankapp/wallet.data/restapi"
+	"github.com/shopspring/decimal"
+)
+
+const walletID = "w-1"
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+func newServer(t *testing.T) (http.Handler, *store.MemoryEventStore) {
+	t.Helper()
+	created := time.Date(2022, 3, 1, 9, 0, 0, 0, time.UTC)
+	var transactions []domain.WalletTransaction
+	for i := 0; i < 5; i++ {
+		transactions = append(transactions, domain.WalletTransaction{
+			DebitWalletId: "w-2", CreditWalletId: walletID, Amount: decimal.NewFromInt(int64(i + 1)),
+			CreatedAt: created.Add(time.Duration(i) * time.Hour), Description: "top up",
+		})
+	}
+	state := domain.WalletState{WalletId: walletID, IsBlacklisted: true, Restrictions: []domain.Restriction{
+		{Kind: domain.RestrictionBlacklist, ReasonCode: domain.ReasonSanctions, Authority: "OFAC", Description: "listed", ImposedAt: created},
+	}}
+	wallet := domain.Wallet{ID: walletID, UserId: "user-1", AccountId: "account-1", Balance: decimal.RequireFromString("15"), AvailableBalance: decimal.RequireFromString("15"), CreatedAt: created}
+	row := readmodeltest.Row(wallet, state, transactions)
+
+	db := store.NewMemoryEventStore()
+	bus := commands.NewBus(commands.Idempotency(commands.NewMemoryIdempotencyStore(time.Hour)))
+	commands.RegisterWalletHandlers(bus, aggregate.NewWalletCommandExecutor(db))
+	server := restapi.NewServer(bus, queries.NewWalletTransactionQueries(readmodeltest.New(row)), readmodeltest.NopLogger{})
+	return server.Handler(), db
+}
+
+func do(t *testing.T, h http.Handler, method, path string, body interface{}, headers ...string) *httptest.ResponseRecorder {
+	t.Helper()
+	var reader *bytes.Reader
+	if s, ok := body.(string); ok {
+		reader = bytes.NewReader([]byte(s))
+	} else {
+		data, err := json.Marshal(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reader = bytes.NewReader(data)
+	}
+	req := httptest.NewRequest(method, path, reader)
+	req.Header.Set("Content-Type", "application/json")
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func decode[T any](t *testing.T, rec *httptest.ResponseRecorder) T {
+	t.Helper()
+	var v T
+	if err := json.Unmarshal(rec.Body.Bytes(), &v); err != nil {
+		t.Fatalf("%v: %s", err, rec.Body.String())
+	}
+	return v
+}
+
+func requireProblem(t *testing.T, rec *httptest.ResponseRecorder, status int, kind string) restapi.Problem {
+	t.Helper()
+	if rec.Code != status {
+		t.Fatalf("status %d, want %d: %s", rec.Code, status, rec.Body.String())
+	}
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, restapi.ProblemContentType) {
+		t.Fatalf("content type %q, want %q", ct, restapi.ProblemContentType)
+	}
+	problem := decode[restapi.Problem](t, rec)
+	if !strings.HasSuffix(problem.Type, "/"+kind) || problem.Status != status {
+		t.Fatalf("unexpected problem %+v", problem)
+	}
+	return problem
+}
+
+func create(t *testing.T, h http.Handler) {
+	t.Helper()
+	rec := do(t, h, http.MethodPost, "/wallets", restapi.CreateWalletRequest{WalletID: walletID, Amount: decimal.RequireFromString("100"), UserID: "user-1", AccountID: "account-1"})
+	if rec.Code != http.StatusCreated || rec.Header().Get("Location") != "/wallets/"+walletID {
+		t.Fatalf("create: %d %v %s", rec.Code, rec.Header(), rec.Body.String())
+	}
+}
+
+func TestCommands(t *testing.T) {
+	h, db := newServer(t)
+	create(t, h)
+
+	rec := do(t, h, http.MethodPost, "/wallets/"+walletID+"/debits", `{"credit_wallet_id":"w-2","amount":"30","description":"payment"}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("debit: %d %s", rec.Code, rec.Body.String())
+	}
+	if resp := decode[restapi.CommandResponse](t, rec); resp.Version != 1 {
+		t.Fatalf("unexpected response %+v", resp)
+	}
+	rec = do(t, h, http.MethodPost, "/wallets/"+walletID+"/holds", `{"amount":"20","description":"card"}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("hold: %d %s", rec.Code, rec.Body.String())
+	}
+	rec = do(t, h, http.MethodPost, "/wallets/"+walletID+"/holds/release", `{"amount":"5","description":"card"}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("release: %d %s", rec.Code, rec.Body.String())
+	}
+	problem := requireProblem(t, do(t, h, http.MethodPost, "/wallets/"+walletID+"/lock", `{"description":"review","reason_code":"bored"}`), http.StatusBadRequest, "invalid-request")
+	if problem.Field != "reason_code" {
+		t.Fatalf("problem field %q, want reason_code", problem.Field)
+	}
+	expiresAt := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+	rec = do(t, h, http.MethodPost, "/wallets/"+walletID+"/lock", `{"description":"review","reason_code":"fraud_suspected","authority":"fraud desk","evidence":["case-9"],"expires_at":"`+expiresAt+`"}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("lock: %d %s", rec.Code, rec.Body.String())
+	}
+
+	requireProblem(t, do(t, h, http.MethodPost, "/wallets/"+walletID+"/debits", `{"credit_wallet_id":"w-2","amount":"1","description":"payment"}`), http.StatusUnprocessableEntity, "rejected")
+	problem = requireProblem(t, do(t, h, http.MethodPost, "/wallets/"+walletID+"/unlock", `{}`), http.StatusBadRequest, "invalid-request")
+	if problem.Field != "description" {
+		t.Fatalf("problem field %q, want description", problem.Field)
+	}
+	requireProblem(t, do(t, h, http.MethodPost, "/wallets/"+walletID+"/credits", `{"amount":`), http.StatusBadRequest, "invalid-request")
+	requireProblem(t, do(t, h, http.MethodPost, "/wallets/unknown/lock", `{"description":"review"}`), http.StatusNotFound, "wallet-not-found")
+	requireProblem(t, do(t, h, http.MethodPost, "/wallets", restapi.CreateWalletRequest{WalletID: walletID, UserID: "user-1", AccountID: "account-1"}), http.StatusConflict, "wallet-exists")
+	requireProblem(t, do(t, h, http.MethodDelete, "/wallets/"+walletID, nil), http.StatusBadRequest, "invalid-request")
+
+	wallet, err := aggregate.LoadWalletAggregate(context.Background(), db, walletID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !wallet.Wallet.Balance.Equal(decimal.RequireFromString("70")) || !wallet.Wallet.AvailableBalance.Equal(decimal.RequireFromString("55")) {
+		t.Fatalf("unexpected balances %s / %s", wallet.Wallet.Balance, wallet.Wallet.AvailableBalance)
+	}
+	if r := wallet.WalletState.Restriction(domain.RestrictionLock); r == nil || r.ReasonCode != domain.ReasonFraudSuspected || r.Authority != "fraud desk" ||
+		len(r.Evidence) != 1 || r.ExpiresAt == nil {
+		t.Fatalf("unexpected restrictions %+v", wallet.WalletState.Restrictions)
+	}
+
+	if rec := do(t, h, http.MethodDelete, "/wallets/"+walletID+"?description=closed", nil); rec.Code != http.StatusOK {
+		t.Fatalf("delete: %d %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestIdempotencyKey(t *testing.T) {
+	h, db := newServer(t)
+	create(t, h)
+
+	body := `{"credit_wallet_id":"w-2","amount":"30","description":"payment"}`
+	first := do(t, h, http.MethodPost, "/wallets/"+walletID+"/debits", body, restapi.IdempotencyKeyHeader, "k-1")
+	retry := do(t, h, http.MethodPost, "/wallets/"+walletID+"/debits", body, restapi.IdempotencyKeyHeader, "k-1")
+	if first.Code != http.StatusCreated || retry.Code != first.Code || retry.Body.String() != first.Body.String() {
+		t.Fatalf("retry answered %d %s, first %d %s", retry.Code, retry.Body.String(), first.Code, first.Body.String())
+	}
+	requireProblem(t, do(t, h, http.MethodPost, "/wallets/"+walletID+"/debits", `{"credit_wallet_id":"w-2","amount":"31","description":"payment"}`, restapi.IdempotencyKeyHeader, "k-1"), http.StatusConflict, "conflict")
+
+	wallet, err := aggregate.LoadWalletAggregate(context.Background(), db, walletID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !wallet.Wallet.Balance.Equal(decimal.RequireFromString("70")) {
+		t.Fatalf("balance %s, want 70", wallet.Wallet.Balance)
+	}
+}
+
+func TestRequestMetadataIsRecordedWithTheEvents(t *testing.T) {
+	h, db := newServer(t)
+	create(t, h)
+
+	body := `{"credit_wallet_id":"w-2","amount":"5","description":"payment"}`
+	rec := do(t, h, http.MethodPost, "/wallets/"+walletID+"/debits", body,
+		restapi.CorrelationIDHeader, "corr-1", restapi.RequestIDHeader, "req-1")
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("debit: %d %s", rec.Code, rec.Body.String())
+	}
+
+	events, err := db.ReadEvents(context.Background(), aggregate.GetWalletStreamID(walletID), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := metadata.FromEvent(events[len(events)-1])
+	if m.Channel != metadata.ChannelREST || m.CorrelationID != "corr-1" || m.CausationID != "req-1" || m.ClientIP != "192.0.2.1" {
+		t.Fatalf("debit metadata %+v", m)
+	}
+}
+
+func TestQueries(t *testing.T) {
+	h, _ := newServer(t)
+
+	rec := do(t, h, http.MethodGet, "/wallets/"+walletID, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("get: %d %s", rec.Code, rec.Body.String())
+	}
+	if !strings.Contains(rec.Body.String(), `"balance":"15"`) {
+		t.Fatalf("balance not a decimal string: %s", rec.Body.String())
+	}
+	state := decode[restapi.WalletStateResponse](t, do(t, h, http.MethodGet, "/wallets/"+walletID+"/state", nil))
+	if !state.IsBlacklisted || state.IsLocked || len(state.Restrictions) != 1 || state.Restrictions[0].Kind != "blacklist" ||
+		state.Restrictions[0].ReasonCode != "sanctions" || state.Restrictions[0].Authority != "OFAC" {
+		t.Fatalf("unexpected state %+v", state)
+	}
+	requireProblem(t, do(t, h, http.MethodGet, "/wallets/unknown", nil), http.StatusNotFound, "wallet-not-found")
+	requireProblem(t, do(t, h, http.MethodGet, "/wallets/"+walletID+"/transactions?cursor=nope", nil), http.StatusBadRequest, "invalid-request")
+	requireProblem(t, do(t, h, http.MethodGet, "/wallets/"+walletID+"/transactions?from=yesterday", nil), http.StatusBadRequest, "invalid-request")
+}
+
+func TestTransactionPagination(t *testing.T) {
+	h, _ := newServer(t)
+
+	var amounts []string
+	next := "/wallets/" + walletID + "/transactions?limit=2&order=asc&min_amount=2"
+	for pages := 0; next != ""; pages++ {
+		if pages > 5 {
+			t.Fatal("pagination does not end")
+		}
+		rec := do(t, h, http.MethodGet, next, nil)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("list: %d %s", rec.Code, rec.Body.String())
+		}
+		page := decode[restapi.TransactionPageResponse](t, rec)
+		for _, tx := range page.Transactions {
+			amounts = append(amounts, tx.Amount.String())
+		}
+		next = ""
+		if link := rec.Header().Get("Link"); link != "" {
+			if page.NextCursor == "" || !strings.HasSuffix(link, `>; rel="next"`) {
+				t.Fatalf("Link %q without next_cursor %q", link, page.NextCursor)
+			}
+			next = strings.TrimSuffix(strings.TrimPrefix(link, "<"), `>; rel="next"`)
+		}
+	}
+	if strings.Join(amounts, ",") != "2,3,4,5" {
+		t.Fatalf("paged through %v", amounts)
+	}
+}
+
+func TestOpenAPIDocument(t *testing.T) {
+	h, _ := newServer(t)
+	rec := do(t, h, http.MethodGet, restapi.OpenAPIPath, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("openapi: %d", rec.Code)
+	}
+	doc := decode[map[string]interface{}](t, rec)
+	if doc["openapi"] != "3.0.3" {
+		t.Fatalf("openapi version %v", doc["openapi"])
+	}
+	paths := doc["paths"].(map[string]interface{})
+	for _, path := range []string{"/wallets", "/wallets/{id}", "/wallets/{id}/transactions", "/wallets/{id}/holds", "/wallets/{id}/holds/release", "/wallets/{id}/lock", "/wallets/{id}/state"} {
+		if _, ok := paths[path]; !ok {
+			t.Errorf("path %s missing", path)
+		}
+	}
+
+	// every reference must resolve and every operation id must be unique
+	components := doc["components"].(map[string]interface{})
+	ids := make(map[string]bool)
+	var walk func(v interface{})
+	walk = func(v interface{}) {
+		switch v := v.(type) {
+		case map[string]interface{}:
+			if ref, ok := v["$ref"].(string); ok {
+				parts := strings.Split(strings.TrimPrefix(ref, "#/components/"), "/")
+				section, _ := components[parts[0]].(map[string]interface{})
+				if _, ok := section[parts[1]]; !ok {
+					t.Errorf("unresolved reference %s", ref)
+				}
+			}
+			if id, ok := v["operationId"].(string); ok {
+				if ids[id] {
+					t.Errorf("duplicate operation id %s", id)
+				}
+				ids[id] = true
+			}
+			for _, child := range v {
+				walk(child)
+			}
+		case []interface{}:
+			for _, child := range v {
+				walk(child)
+			}
+		}
+	}
+	walk(paths)
+	walk(components)
+	if len(ids) != 13 {
+		t.Errorf("%d operations, want 13", len(ids))
+	}
+
+	create := components["schemas"].(map[string]interface{})["CreateWalletRequest"].(map[string]interface{})
+	required, _ := json.Marshal(create["required"])
+	if string(required) != `["wallet_id","user_id","account_id"]` {
+		t.Errorf("CreateWalletRequest required %s", required)
+	}
+}
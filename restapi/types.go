@@ -0,0 +1,121 @@
+package restapi
+
+import (
+	"time"
+
+	"github.com/novabankapp/wallet.data/domain"
+	"github.com/novabankapp/wallet.data/es/commands"
+	"github.com/shopspring/decimal"
+)
+
+// Request and response bodies. Amounts are decimal strings. The openapi tag feeds the
+// generated document: "required" marks required fields.
+
+type CreateWalletRequest struct {
+	WalletID    string          `json:"wallet_id" openapi:"required" doc:"id of the new wallet"`
+	Amount      decimal.Decimal `json:"amount" doc:"opening balance, zero when omitted"`
+	Description string          `json:"description"`
+	UserID      string          `json:"user_id" openapi:"required"`
+	AccountID   string          `json:"account_id" openapi:"required"`
+}
+
+type CreditRequest struct {
+	DebitWalletID string          `json:"debit_wallet_id" openapi:"required" doc:"wallet the money comes from"`
+	Amount        decimal.Decimal `json:"amount" openapi:"required"`
+	Description   string          `json:"description"`
+}
+
+type DebitRequest struct {
+	CreditWalletID string          `json:"credit_wallet_id" openapi:"required" doc:"wallet the money goes to"`
+	Amount         decimal.Decimal `json:"amount" openapi:"required"`
+	Description    string          `json:"description"`
+}
+
+type HoldRequest struct {
+	Amount      decimal.Decimal `json:"amount" openapi:"required"`
+	Description string          `json:"description"`
+}
+
+type StateChangeRequest struct {
+	Description string `json:"description" openapi:"required" doc:"why the state changes"`
+}
+
+type RestrictionRequest struct {
+	Description string     `json:"description" openapi:"required" doc:"why the wallet is restricted"`
+	ReasonCode  string     `json:"reason_code" doc:"fraud_suspected, aml_investigation, sanctions, court_order, regulator_request, customer_request, lost_or_stolen or other (the default)"`
+	Authority   string     `json:"authority" doc:"who imposed the restriction"`
+	Evidence    []string   `json:"evidence" doc:"references to the documents or cases behind the restriction"`
+	ExpiresAt   *time.Time `json:"expires_at" doc:"when the restriction is lifted by itself"`
+}
+
+type CommandResponse struct {
+	WalletID string `json:"wallet_id" openapi:"required"`
+	Version  int64  `json:"version" openapi:"required" doc:"version of the wallet after the change"`
+}
+
+type WalletResponse struct {
+	ID               string          `json:"id" openapi:"required"`
+	UserID           string          `json:"user_id" openapi:"required"`
+	AccountID        string          `json:"account_id" openapi:"required"`
+	Balance          decimal.Decimal `json:"balance" openapi:"required"`
+	AvailableBalance decimal.Decimal `json:"available_balance" openapi:"required" doc:"balance minus holds"`
+	CreatedAt        time.Time       `json:"created_at"`
+}
+
+type WalletStateResponse struct {
+	WalletID      string                `json:"wallet_id" openapi:"required"`
+	IsLocked      bool                  `json:"is_locked" openapi:"required"`
+	IsBlacklisted bool                  `json:"is_blacklisted" openapi:"required"`
+	IsDeleted     bool                  `json:"is_deleted" openapi:"required"`
+	Restrictions  []RestrictionResponse `json:"restrictions" openapi:"required" doc:"the active lock and blacklisting"`
+}
+
+type RestrictionResponse struct {
+	Kind        string     `json:"kind" openapi:"required" doc:"lock or blacklist"`
+	ReasonCode  string     `json:"reason_code" openapi:"required"`
+	Authority   string     `json:"authority,omitempty"`
+	Description string     `json:"description" openapi:"required"`
+	Evidence    []string   `json:"evidence,omitempty"`
+	ImposedAt   time.Time  `json:"imposed_at" openapi:"required"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+}
+
+type TransactionResponse struct {
+	ID             string          `json:"id" openapi:"required"`
+	DebitWalletID  string          `json:"debit_wallet_id" openapi:"required"`
+	CreditWalletID string          `json:"credit_wallet_id" openapi:"required"`
+	Amount         decimal.Decimal `json:"amount" openapi:"required"`
+	Description    string          `json:"description"`
+	CreatedAt      time.Time       `json:"created_at" openapi:"required"`
+}
+
+type TransactionPageResponse struct {
+	Transactions []TransactionResponse `json:"transactions" openapi:"required"`
+	NextCursor   string                `json:"next_cursor,omitempty" doc:"cursor of the next page, absent on the last page"`
+}
+
+func toCommandResponse(result *commands.Result) CommandResponse {
+	return CommandResponse{WalletID: result.WalletID, Version: result.Version}
+}
+
+func toWalletResponse(wallet *domain.Wallet) WalletResponse {
+	return WalletResponse{
+		ID:               wallet.ID,
+		UserID:           wallet.UserId,
+		AccountID:        wallet.AccountId,
+		Balance:          wallet.Balance,
+		AvailableBalance: wallet.AvailableBalance,
+		CreatedAt:        wallet.CreatedAt,
+	}
+}
+
+func toTransactionResponse(tx domain.WalletTransaction) TransactionResponse {
+	return TransactionResponse{
+		ID:             tx.ID.String(),
+		DebitWalletID:  tx.DebitWalletId,
+		CreditWalletID: tx.CreditWalletId,
+		Amount:         tx.Amount,
+		Description:    tx.Description,
+		CreatedAt:      tx.CreatedAt,
+	}
+}
@@ -0,0 +1,120 @@
+// Package restrictions lifts the locks and blacklistings of wallets once their expiry has
+// passed.
+package restrictions
+
+import (
+	"context"
+	"time"
+
+	"github.com/novabankapp/common.data/logger"
+	"github.com/novabankapp/wallet.data/es/aggregate"
+	"github.com/novabankapp/wallet.data/es/commands"
+	"github.com/novabankapp/wallet.data/es/metadata"
+	"github.com/novabankapp/wallet.data/es/models"
+	"github.com/novabankapp/wallet.data/tracing"
+	"github.com/pkg/errors"
+)
+
+// ReadModel is the part of the Cassandra read model the lifter reads: the expiries the
+// projection keeps, as readmodel.RestrictionExpiryRepository does.
+type ReadModel interface {
+	Due(ctx context.Context, now time.Time) ([]models.RestrictionExpiry, error)
+}
+
+// DefaultActorID is the actor of the commands the lifter sends.
+const DefaultActorID = "restriction-expiry"
+
+// DefaultInterval is how often Run sweeps the read model.
+const DefaultInterval = time.Minute
+
+// Lifter finds the restrictions whose expiry has passed in the read model and lifts them
+// with LiftExpiredRestrictions commands sent as Principal. The aggregate decides what has
+// expired, so a read model that is behind only delays a lift.
+type Lifter struct {
+	ReadModel ReadModel
+	Bus       *commands.Bus
+	Principal commands.Principal
+	Interval  time.Duration
+	Log       logger.Logger
+	Now       func() time.Time
+}
+
+func NewLifter(readModel ReadModel, bus *commands.Bus, log logger.Logger, roles ...string) *Lifter {
+	return &Lifter{
+		ReadModel: readModel,
+		Bus:       bus,
+		Principal: commands.Principal{ID: DefaultActorID, Roles: roles, Type: metadata.ActorSystem},
+		Interval:  DefaultInterval,
+		Log:       log,
+		Now:       time.Now,
+	}
+}
+
+// Run sweeps the read model every Interval until ctx is done. Failed sweeps are logged
+// and tried again on the next tick.
+func (l *Lifter) Run(ctx context.Context) error {
+	ticker := time.NewTicker(l.Interval)
+	defer ticker.Stop()
+	for {
+		if _, err := l.Sweep(ctx); err != nil {
+			l.Log.Errorf("(Lifter.Sweep) err: {%v}", err)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Sweep lifts the restrictions of the read model that are due and returns the ids of the
+// wallets it lifted them from. Wallets whose restrictions were lifted since they were
+// projected are skipped. A wallet that fails does not stop the others; the first error is
+// returned.
+func (l *Lifter) Sweep(ctx context.Context) ([]string, error) {
+	ctx, span := tracing.StartSpan(ctx, "Lifter.Sweep")
+	defer span.End()
+
+	due, err := l.ReadModel.Due(ctx, l.Now())
+	if err != nil {
+		tracing.TraceErr(span, err)
+		return nil, errors.Wrap(err, "ReadModel.Due")
+	}
+
+	// One command lifts every expired restriction of a wallet.
+	seen := make(map[string]bool, len(due))
+	var lifted []string
+	var first error
+	for _, expiry := range due {
+		if seen[expiry.WalletID] {
+			continue
+		}
+		seen[expiry.WalletID] = true
+		err := l.lift(ctx, expiry.WalletID)
+		switch {
+		case err == nil:
+			lifted = append(lifted, expiry.WalletID)
+		case errors.Is(err, aggregate.ErrNoExpiredRestriction):
+		case first == nil:
+			first = errors.Wrap(err, expiry.WalletID)
+		}
+	}
+	if first != nil {
+		tracing.TraceErr(span, first)
+	}
+	return lifted, first
+}
+
+// lift sends the command as Principal in a correlation of its own.
+func (l *Lifter) lift(ctx context.Context, walletID string) error {
+	ctx = metadata.WithMetadata(ctx, metadata.Metadata{
+		ActorID:   l.Principal.ID,
+		ActorType: metadata.ActorSystem,
+		Channel:   metadata.ChannelInternal,
+	})
+	ctx = commands.WithPrincipal(ctx, l.Principal)
+	if _, err := l.Bus.Dispatch(ctx, commands.LiftExpiredRestrictionsCommand{ID: walletID}); err != nil {
+		return errors.Wrap(err, "Dispatch")
+	}
+	return nil
+}
@@ -0,0 +1,231 @@
+package restrictions_test
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/novabankapp/common.data/logger"
+	"github.com/novabankapp/wallet.data/domain"
+	"github.com/novabankapp/wallet.data/es/aggregate"
+	"github.com/novabankapp/wallet.data/es/commands"
+	v1 "github.com/novabankapp/wallet.data/es/events/v1"
+	"github.com/novabankapp/wallet.data/es/metadata"
+	"github.com/novabankapp/wallet.data/es/models"
+	"github.com/novabankapp/wallet.data/es/store"
+	"github.com/novabankapp/wallet.data/restrictions"
+	"github.com/pkg/errors"
+	"github.com/shopspring/decimal"
+)
+
+// readModel is a copy of the wallets' expiries taken with snapshot, so it falls behind
+// like the projection does.
+type readModel []models.RestrictionExpiry
+
+func (m readModel) Due(ctx context.Context, now time.Time) ([]models.RestrictionExpiry, error) {
+	var due []models.RestrictionExpiry
+	for _, expiry := range m {
+		if !expiry.ExpiresAt.After(now) {
+			due = append(due, expiry)
+		}
+	}
+	return due, nil
+}
+
+type failingReadModel struct{}
+
+func (failingReadModel) Due(ctx context.Context, now time.Time) ([]models.RestrictionExpiry, error) {
+	return nil, errors.New("no hosts available")
+}
+
+// errorLogger sends the errors it is given on a channel. Only Errorf is implemented.
+type errorLogger struct {
+	logger.Logger
+	errs chan string
+}
+
+func (l errorLogger) Errorf(template string, args ...interface{}) {
+	l.errs <- fmt.Sprintf(template, args...)
+}
+
+type fixture struct {
+	db  *store.MemoryEventStore
+	bus *commands.Bus
+	now time.Time
+}
+
+func newFixture(t *testing.T) *fixture {
+	t.Helper()
+	f := &fixture{db: store.NewMemoryEventStore(), now: time.Now()}
+	handlers := commands.NewWalletHandlers(aggregate.NewWalletCommandExecutor(f.db))
+	handlers.Now = func() time.Time { return f.now }
+	f.bus = commands.NewBus(commands.Causation())
+	commands.Register(f.bus, handlers.CreateWallet)
+	commands.Register(f.bus, handlers.LockWallet)
+	commands.Register(f.bus, handlers.UnlockWallet)
+	commands.Register(f.bus, handlers.BlacklistWallet)
+	commands.Register(f.bus, handlers.LiftExpiredRestrictions)
+	return f
+}
+
+func (f *fixture) dispatch(t *testing.T, cmd commands.Command) {
+	t.Helper()
+	if _, err := f.bus.Dispatch(context.Background(), cmd); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func (f *fixture) create(t *testing.T, walletID string) {
+	t.Helper()
+	f.dispatch(t, commands.CreateWalletCommand{ID: walletID, Amount: decimal.NewFromInt(100), Description: "opening", UserID: "user-" + walletID, AccountID: "account-" + walletID})
+}
+
+func (f *fixture) wallet(t *testing.T, walletID string) *aggregate.WalletAggregate {
+	t.Helper()
+	wallet, err := aggregate.LoadWalletAggregate(context.Background(), f.db, walletID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return wallet
+}
+
+func (f *fixture) snapshot(t *testing.T, walletIDs ...string) readModel {
+	t.Helper()
+	var rows readModel
+	for _, id := range walletIDs {
+		for _, r := range f.wallet(t, id).WalletState.Restrictions {
+			if r.ExpiresAt != nil {
+				rows = append(rows, models.RestrictionExpiry{Kind: string(r.Kind), ExpiresAt: *r.ExpiresAt, WalletID: id})
+			}
+		}
+	}
+	return rows
+}
+
+func TestSweepLiftsExpiredRestrictions(t *testing.T) {
+	f := newFixture(t)
+	inAnHour, inTwoHours := f.now.Add(time.Hour), f.now.Add(2*time.Hour)
+	for _, id := range []string{"w-1", "w-2", "w-3"} {
+		f.create(t, id)
+	}
+	f.dispatch(t, commands.LockWalletCommand{ID: "w-1", Description: "card reported stolen", ReasonCode: domain.ReasonLostOrStolen, Authority: "customer", ExpiresAt: &inAnHour})
+	f.dispatch(t, commands.BlacklistWalletCommand{ID: "w-2", Description: "temporary designation", ReasonCode: domain.ReasonSanctions, Authority: "OFAC", Evidence: []string{"sdn-7160"}, ExpiresAt: &inTwoHours})
+	f.dispatch(t, commands.LockWalletCommand{ID: "w-3", Description: "investigation", ReasonCode: domain.ReasonAMLInvestigation, Authority: "compliance"})
+
+	lifter := restrictions.NewLifter(f.snapshot(t, "w-1", "w-2", "w-3"), f.bus, nil)
+	lifter.Now = func() time.Time { return f.now }
+
+	lifted, err := lifter.Sweep(context.Background())
+	if err != nil || len(lifted) != 0 {
+		t.Fatalf("Sweep = %v, %v before anything expired", lifted, err)
+	}
+
+	f.now = f.now.Add(90 * time.Minute)
+	lifted, err = lifter.Sweep(context.Background())
+	if err != nil || len(lifted) != 1 || lifted[0] != "w-1" {
+		t.Fatalf("Sweep = %v, %v, want [w-1]", lifted, err)
+	}
+	if wallet := f.wallet(t, "w-1"); wallet.WalletState.IsLocked || wallet.WalletState.Restrictions != nil {
+		t.Fatalf("w-1 state %+v", wallet.WalletState)
+	}
+	events, err := f.db.ReadEvents(context.Background(), aggregate.GetWalletStreamID("w-1"), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	last := events[len(events)-1]
+	var data v1.WalletUnlockedEvent
+	if err := last.GetJsonData(&data); err != nil || last.GetEventType() != v1.WalletUnlocked || !data.Expired {
+		t.Fatalf("last event %s %+v, %v", last.GetEventType(), data, err)
+	}
+	if m := metadata.FromEvent(last); m.ActorID != restrictions.DefaultActorID || m.ActorType != metadata.ActorSystem {
+		t.Fatalf("metadata %+v", m)
+	}
+
+	// The read model still shows the lock of w-1, which is skipped.
+	f.now = f.now.Add(time.Hour)
+	lifted, err = lifter.Sweep(context.Background())
+	if err != nil || len(lifted) != 1 || lifted[0] != "w-2" {
+		t.Fatalf("Sweep = %v, %v, want [w-2]", lifted, err)
+	}
+	if wallet := f.wallet(t, "w-2"); wallet.WalletState.IsBlacklisted {
+		t.Fatalf("w-2 state %+v", wallet.WalletState)
+	}
+	if wallet := f.wallet(t, "w-3"); !wallet.WalletState.IsLocked || wallet.WalletState.Restriction(domain.RestrictionLock).Authority != "compliance" {
+		t.Fatalf("w-3 state %+v", wallet.WalletState)
+	}
+}
+
+func TestSweepSkipsRestrictionsLiftedByHand(t *testing.T) {
+	f := newFixture(t)
+	inAnHour := f.now.Add(time.Hour)
+	f.create(t, "w-1")
+	f.dispatch(t, commands.LockWalletCommand{ID: "w-1", Description: "review", ReasonCode: domain.ReasonFraudSuspected, ExpiresAt: &inAnHour})
+	lifter := restrictions.NewLifter(f.snapshot(t, "w-1"), f.bus, nil)
+	lifter.Now = func() time.Time { return f.now }
+
+	f.dispatch(t, commands.UnlockWalletCommand{ID: "w-1", Description: "cleared"})
+	f.dispatch(t, commands.LockWalletCommand{ID: "w-1", Description: "new review", ReasonCode: domain.ReasonFraudSuspected})
+
+	f.now = f.now.Add(2 * time.Hour)
+	lifted, err := lifter.Sweep(context.Background())
+	if err != nil || len(lifted) != 0 {
+		t.Fatalf("Sweep = %v, %v, want nothing lifted", lifted, err)
+	}
+	if wallet := f.wallet(t, "w-1"); !wallet.WalletState.IsLocked || wallet.WalletState.Restriction(domain.RestrictionLock).Description != "new review" {
+		t.Fatalf("state %+v", wallet.WalletState)
+	}
+}
+
+func TestSweepLiftsAWalletOnceWhenBothItsRestrictionsExpired(t *testing.T) {
+	f := newFixture(t)
+	inAnHour := f.now.Add(time.Hour)
+	f.create(t, "w-1")
+	f.dispatch(t, commands.LockWalletCommand{ID: "w-1", Description: "review", ReasonCode: domain.ReasonFraudSuspected, ExpiresAt: &inAnHour})
+	f.dispatch(t, commands.BlacklistWalletCommand{ID: "w-1", Description: "designation", ReasonCode: domain.ReasonSanctions, ExpiresAt: &inAnHour})
+	lifter := restrictions.NewLifter(f.snapshot(t, "w-1"), f.bus, nil)
+	lifter.Now = func() time.Time { return f.now }
+
+	f.now = f.now.Add(2 * time.Hour)
+	lifted, err := lifter.Sweep(context.Background())
+	if err != nil || len(lifted) != 1 || lifted[0] != "w-1" {
+		t.Fatalf("Sweep = %v, %v, want [w-1]", lifted, err)
+	}
+	if wallet := f.wallet(t, "w-1"); wallet.WalletState.IsLocked || wallet.WalletState.IsBlacklisted {
+		t.Fatalf("state %+v", wallet.WalletState)
+	}
+}
+
+func TestRunLogsFailedSweepsAndKeepsGoing(t *testing.T) {
+	f := newFixture(t)
+	log := errorLogger{errs: make(chan string)}
+	lifter := restrictions.NewLifter(failingReadModel{}, f.bus, log)
+	lifter.Interval = time.Millisecond
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- lifter.Run(ctx) }()
+
+	for i := 0; i < 2; i++ {
+		select {
+		case msg := <-log.errs:
+			if !strings.Contains(msg, "no hosts available") {
+				t.Fatalf("logged %q", msg)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("sweep %d was not logged", i+1)
+		}
+	}
+	cancel()
+	// Run may be logging a last sweep when it is cancelled.
+	go func() {
+		for range log.errs {
+		}
+	}()
+	err := <-done
+	close(log.errs)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Run = %v, want %v", err, context.Canceled)
+	}
+}
@@ -0,0 +1,128 @@
+// Package retry decides whether and when a failed operation is tried again.
+package retry
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	es "github.com/novabankapp/common.data/eventstore"
+	"github.com/pkg/errors"
+)
+
+type ErrorClass string
+
+const (
+	// Transient errors may go away on their own: timeouts, unavailable databases.
+	Transient ErrorClass = "transient"
+	// Permanent errors fail the same way every time: malformed payloads, unknown types.
+	Permanent ErrorClass = "permanent"
+)
+
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// MarkPermanent marks err as not worth retrying.
+func MarkPermanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+// Classify is the default classification: errors marked with MarkPermanent, JSON decoding
+// errors and eventstore's invalid event/aggregate errors are permanent, the rest transient.
+func Classify(err error) ErrorClass {
+	var permanent *permanentError
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &permanent),
+		errors.As(err, &syntaxErr),
+		errors.As(err, &typeErr),
+		errors.Is(err, es.ErrInvalidEventType),
+		errors.Is(err, es.ErrInvalidAggregateID),
+		errors.Is(err, es.ErrInvalidEventVersion):
+		return Permanent
+	}
+	return Transient
+}
+
+// Exponential doubles the wait on every retry, starting at min and capped at max.
+func Exponential(min, max time.Duration) func(retry int) time.Duration {
+	return func(retry int) time.Duration {
+		wait := min
+		for i := 1; i < retry && wait < max; i++ {
+			wait *= 2
+		}
+		if wait > max {
+			return max
+		}
+		return wait
+	}
+}
+
+// Policy retries transient errors up to MaxAttempts attempts in total, waiting
+// Backoff(n) after the n-th failed attempt. Permanent errors are not retried.
+type Policy struct {
+	MaxAttempts int
+	Backoff     func(retry int) time.Duration
+	// Classify overrides the default classification; it may return "" to defer to it.
+	Classify func(err error) ErrorClass
+}
+
+func DefaultPolicy() Policy {
+	return Policy{MaxAttempts: 5, Backoff: Exponential(200*time.Millisecond, 10*time.Second)}
+}
+
+// Result describes the outcome of Do.
+type Result struct {
+	Attempts int
+	Class    ErrorClass
+	Err      error
+}
+
+func (p Policy) classify(err error) ErrorClass {
+	if p.Classify != nil {
+		if class := p.Classify(err); class != "" {
+			return class
+		}
+	}
+	return Classify(err)
+}
+
+// Do runs fn until it succeeds, fails permanently, runs out of attempts or ctx is done.
+// When ctx is done the result carries ctx.Err().
+func (p Policy) Do(ctx context.Context, fn func(ctx context.Context) error) Result {
+	maxAttempts := p.MaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = 1
+	}
+	for attempt := 1; ; attempt++ {
+		err := fn(ctx)
+		if err == nil {
+			return Result{Attempts: attempt}
+		}
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return Result{Attempts: attempt, Class: Transient, Err: ctxErr}
+		}
+		class := p.classify(err)
+		if class == Permanent || attempt >= maxAttempts {
+			return Result{Attempts: attempt, Class: class, Err: err}
+		}
+		if p.Backoff == nil {
+			continue
+		}
+		timer := time.NewTimer(p.Backoff(attempt))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return Result{Attempts: attempt, Class: Transient, Err: ctx.Err()}
+		case <-timer.C:
+		}
+	}
+}
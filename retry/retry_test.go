@@ -0,0 +1,62 @@
+package retry_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	es "github.com/novabankapp/common.data/eventstore"
+	"github.com/novabankapp/wallet.data/retry"
+	"github.com/pkg/errors"
+)
+
+func TestClassify(t *testing.T) {
+	var syntaxErr error = json.Unmarshal([]byte("{"), &struct{}{})
+	for err, want := range map[error]retry.ErrorClass{
+		errors.New("timeout"):                           retry.Transient,
+		retry.MarkPermanent(errors.New("bad")):          retry.Permanent,
+		errors.Wrap(syntaxErr, "GetJsonData"):           retry.Permanent,
+		errors.Wrap(es.ErrInvalidEventType, "When"):     retry.Permanent,
+		errors.Wrap(context.DeadlineExceeded, "Update"): retry.Transient,
+	} {
+		if got := retry.Classify(err); got != want {
+			t.Errorf("Classify(%v) = %s, want %s", err, got, want)
+		}
+	}
+}
+
+func TestPolicyDo(t *testing.T) {
+	policy := retry.Policy{MaxAttempts: 4, Backoff: func(int) time.Duration { return 0 }}
+
+	calls := 0
+	result := policy.Do(context.Background(), func(context.Context) error {
+		calls++
+		if calls < 3 {
+			return errors.New("timeout")
+		}
+		return nil
+	})
+	if result.Err != nil || result.Attempts != 3 {
+		t.Errorf("transient then success: %+v", result)
+	}
+
+	result = policy.Do(context.Background(), func(context.Context) error { return errors.New("timeout") })
+	if result.Err == nil || result.Attempts != 4 || result.Class != retry.Transient {
+		t.Errorf("exhausted: %+v", result)
+	}
+
+	result = policy.Do(context.Background(), func(context.Context) error { return retry.MarkPermanent(errors.New("bad")) })
+	if result.Attempts != 1 || result.Class != retry.Permanent {
+		t.Errorf("permanent: %+v", result)
+	}
+}
+
+func TestExponential(t *testing.T) {
+	backoff := retry.Exponential(time.Second, 5*time.Second)
+	for retryN, want := range []time.Duration{time.Second, time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second} {
+		if got := backoff(retryN); got != want {
+			t.Errorf("backoff(%d) = %s, want %s", retryN, got, want)
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package screening
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// folds maps the accented Latin letters common on watchlists to their base letter.
+var folds = map[rune]rune{
+	'à': 'a', 'á': 'a', 'â': 'a', 'ã': 'a', 'ä': 'a', 'å': 'a', 'ā': 'a', 'ă': 'a', 'ą': 'a',
+	'ç': 'c', 'ć': 'c', 'č': 'c',
+	'ď': 'd', 'đ': 'd',
+	'è': 'e', 'é': 'e', 'ê': 'e', 'ë': 'e', 'ē': 'e', 'ė': 'e', 'ę': 'e', 'ě': 'e',
+	'ğ': 'g',
+	'ì': 'i', 'í': 'i', 'î': 'i', 'ï': 'i', 'ī': 'i', 'ı': 'i',
+	'ł': 'l',
+	'ñ': 'n', 'ń': 'n', 'ň': 'n',
+	'ò': 'o', 'ó': 'o', 'ô': 'o', 'õ': 'o', 'ö': 'o', 'ø': 'o', 'ō': 'o', 'ő': 'o',
+	'ř': 'r',
+	'ś': 's', 'š': 's', 'ş': 's', 'ß': 's',
+	'ť': 't', 'ţ': 't',
+	'ù': 'u', 'ú': 'u', 'û': 'u', 'ü': 'u', 'ū': 'u', 'ů': 'u', 'ű': 'u',
+	'ý': 'y', 'ÿ': 'y',
+	'ź': 'z', 'ż': 'z', 'ž': 'z',
+}
+
+// tokens lower-cases a name, folds its accents and splits it into words, dropping
+// punctuation, so that "PUTIN, Vladimir" and "Vladimir Putin" have the same words.
+func tokens(name string) []string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(name) {
+		if folded, ok := folds[r]; ok {
+			r = folded
+		}
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			b.WriteRune(r)
+		case r == '\'' || r == '’':
+			// O'Brien and OBrien are the same name.
+		default:
+			b.WriteRune(' ')
+		}
+	}
+	return strings.Fields(b.String())
+}
+
+// similarity scores how alike two names are, from 0 to 1, whatever the order of their
+// words. Names whose words are all in the other name, like a name without its middle
+// names, score close to a full match.
+func similarity(a, b []string) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+	shorter, longer := a, b
+	if len(shorter) > len(longer) {
+		shorter, longer = longer, shorter
+	}
+	// A single word, like a surname alone, is too common to stand for a longer name.
+	if len(shorter) == 1 && len(longer) > 1 {
+		return 0
+	}
+	score := jaroWinkler(sortedJoin(a), sortedJoin(b))
+	var matched, total float64
+	for _, word := range shorter {
+		best := 0.0
+		for _, other := range longer {
+			if s := jaroWinkler(word, other); s > best {
+				best = s
+			}
+		}
+		weight := float64(len([]rune(word)))
+		matched += best * weight
+		total += weight
+	}
+	coverage := float64(len(shorter)) / float64(len(longer))
+	if words := matched / total * (0.9 + 0.1*coverage); words > score {
+		score = words
+	}
+	return score
+}
+
+func sortedJoin(words []string) string {
+	sorted := append([]string(nil), words...)
+	sort.Strings(sorted)
+	return strings.Join(sorted, " ")
+}
+
+// jaroWinkler is the Jaro-Winkler similarity of two strings, which favours strings that
+// share their beginning.
+func jaroWinkler(a, b string) float64 {
+	s, t := []rune(a), []rune(b)
+	if len(s) == 0 || len(t) == 0 {
+		return 0
+	}
+	if a == b {
+		return 1
+	}
+	window := len(s)
+	if len(t) > window {
+		window = len(t)
+	}
+	window = window/2 - 1
+	if window < 0 {
+		window = 0
+	}
+
+	sMatched := make([]bool, len(s))
+	tMatched := make([]bool, len(t))
+	matches := 0
+	for i := range s {
+		lo, hi := i-window, i+window+1
+		if lo < 0 {
+			lo = 0
+		}
+		if hi > len(t) {
+			hi = len(t)
+		}
+		for j := lo; j < hi; j++ {
+			if tMatched[j] || s[i] != t[j] {
+				continue
+			}
+			sMatched[i], tMatched[j] = true, true
+			matches++
+			break
+		}
+	}
+	if matches == 0 {
+		return 0
+	}
+
+	transpositions, j := 0, 0
+	for i := range s {
+		if !sMatched[i] {
+			continue
+		}
+		for !tMatched[j] {
+			j++
+		}
+		if s[i] != t[j] {
+			transpositions++
+		}
+		j++
+	}
+	m := float64(matches)
+	jaro := (m/float64(len(s)) + m/float64(len(t)) + (m-float64(transpositions)/2)/m) / 3
+
+	prefix := 0
+	for prefix < 4 && prefix < len(s) && prefix < len(t) && s[prefix] == t[prefix] {
+		prefix++
+	}
+	return jaro + float64(prefix)*0.1*(1-jaro)
+}
@@ -0,0 +1,274 @@
+package screening
+
+import (
+	"encoding/csv"
+	"encoding/xml"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Format is the layout of a watchlist file.
+type Format string
+
+const (
+	// FormatOFAC is the SDN.CSV file of the OFAC list, with its aliases in an ALT.CSV.
+	FormatOFAC Format = "ofac"
+	// FormatUN is the XML file of the UN Security Council consolidated list.
+	FormatUN Format = "un"
+	// FormatCSV is a CSV file with the header id,name,aliases,type,programs, where aliases
+	// and programs are separated by semicolons, for lists kept in house.
+	FormatCSV Format = "csv"
+)
+
+var ErrInvalidList = errors.New("invalid watchlist")
+
+// Entry is a listed person, organisation or vessel.
+type Entry struct {
+	ID       string   `json:"id"`
+	Name     string   `json:"name"`
+	Aliases  []string `json:"aliases,omitempty"`
+	Type     string   `json:"type,omitempty"`
+	Programs []string `json:"programs,omitempty"`
+	// names are the tokens of Name and of every alias.
+	names [][]string
+}
+
+// List is one watchlist.
+type List struct {
+	Name    string
+	Entries []Entry
+}
+
+// ListFile says where to load a list from. AltPath is the aliases file of FormatOFAC and
+// may be empty.
+type ListFile struct {
+	Name    string
+	Format  Format
+	Path    string
+	AltPath string
+}
+
+// LoadList reads a list from its files.
+func LoadList(file ListFile) (*List, error) {
+	f, err := os.Open(file.Path)
+	if err != nil {
+		return nil, errors.Wrap(err, "os.Open")
+	}
+	defer f.Close()
+
+	list := &List{Name: file.Name}
+	switch file.Format {
+	case FormatOFAC:
+		var alt io.Reader
+		if file.AltPath != "" {
+			altFile, err := os.Open(file.AltPath)
+			if err != nil {
+				return nil, errors.Wrap(err, "os.Open")
+			}
+			defer altFile.Close()
+			alt = altFile
+		}
+		list.Entries, err = ParseOFAC(f, alt)
+	case FormatUN:
+		list.Entries, err = ParseUN(f)
+	case FormatCSV:
+		list.Entries, err = ParseCSV(f)
+	default:
+		return nil, errors.Wrapf(ErrInvalidList, "%s: unknown format %q", file.Path, file.Format)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, file.Path)
+	}
+	if list.Name == "" {
+		list.Name = string(file.Format)
+	}
+	return list, nil
+}
+
+// ofacEmpty is how the OFAC files write an empty field.
+const ofacEmpty = "-0-"
+
+// ParseOFAC reads the entries of an OFAC SDN.CSV file and adds the aliases of alt, an
+// ALT.CSV file, when given.
+func ParseOFAC(sdn io.Reader, alt io.Reader) ([]Entry, error) {
+	rows, err := readCSV(sdn)
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]Entry, 0, len(rows))
+	index := make(map[string]int, len(rows))
+	for _, row := range rows {
+		if len(row) < 4 {
+			continue
+		}
+		entry := Entry{ID: ofacField(row[0]), Name: ofacField(row[1]), Type: ofacField(row[2])}
+		if entry.ID == "" || entry.Name == "" {
+			continue
+		}
+		if entry.Type == "" {
+			entry.Type = "entity"
+		}
+		for _, program := range strings.Split(ofacField(row[3]), "] [") {
+			if program = strings.Trim(program, "[] "); program != "" {
+				entry.Programs = append(entry.Programs, program)
+			}
+		}
+		index[entry.ID] = len(entries)
+		entries = append(entries, entry)
+	}
+	if alt != nil {
+		rows, err := readCSV(alt)
+		if err != nil {
+			return nil, err
+		}
+		for _, row := range rows {
+			if len(row) < 4 {
+				continue
+			}
+			i, ok := index[ofacField(row[0])]
+			if name := ofacField(row[3]); ok && name != "" {
+				entries[i].Aliases = append(entries[i].Aliases, name)
+			}
+		}
+	}
+	return compile(entries)
+}
+
+func ofacField(value string) string {
+	value = strings.TrimSpace(value)
+	if value == ofacEmpty {
+		return ""
+	}
+	return value
+}
+
+type unList struct {
+	Individuals []unIndividual `xml:"INDIVIDUALS>INDIVIDUAL"`
+	Entities    []unEntity     `xml:"ENTITIES>ENTITY"`
+}
+
+type unIndividual struct {
+	DataID     string    `xml:"DATAID"`
+	FirstName  string    `xml:"FIRST_NAME"`
+	SecondName string    `xml:"SECOND_NAME"`
+	ThirdName  string    `xml:"THIRD_NAME"`
+	FourthName string    `xml:"FOURTH_NAME"`
+	ListType   string    `xml:"UN_LIST_TYPE"`
+	Aliases    []unAlias `xml:"INDIVIDUAL_ALIAS"`
+}
+
+type unEntity struct {
+	DataID   string    `xml:"DATAID"`
+	Name     string    `xml:"FIRST_NAME"`
+	ListType string    `xml:"UN_LIST_TYPE"`
+	Aliases  []unAlias `xml:"ENTITY_ALIAS"`
+}
+
+type unAlias struct {
+	Name string `xml:"ALIAS_NAME"`
+}
+
+// ParseUN reads the entries of the UN Security Council consolidated list.
+func ParseUN(r io.Reader) ([]Entry, error) {
+	var list unList
+	if err := xml.NewDecoder(r).Decode(&list); err != nil {
+		return nil, errors.Wrapf(ErrInvalidList, "xml: %v", err)
+	}
+	entries := make([]Entry, 0, len(list.Individuals)+len(list.Entities))
+	for _, individual := range list.Individuals {
+		name := strings.Join(strings.Fields(strings.Join([]string{individual.FirstName, individual.SecondName, individual.ThirdName, individual.FourthName}, " ")), " ")
+		entries = append(entries, Entry{ID: individual.DataID, Name: name, Aliases: unAliases(individual.Aliases), Type: "individual", Programs: programs(individual.ListType)})
+	}
+	for _, entity := range list.Entities {
+		entries = append(entries, Entry{ID: entity.DataID, Name: strings.TrimSpace(entity.Name), Aliases: unAliases(entity.Aliases), Type: "entity", Programs: programs(entity.ListType)})
+	}
+	return compile(entries)
+}
+
+func unAliases(aliases []unAlias) []string {
+	var result []string
+	for _, alias := range aliases {
+		if name := strings.TrimSpace(alias.Name); name != "" {
+			result = append(result, name)
+		}
+	}
+	return result
+}
+
+var csvHeader = []string{"id", "name", "aliases", "type", "programs"}
+
+// ParseCSV reads the entries of a FormatCSV file.
+func ParseCSV(r io.Reader) ([]Entry, error) {
+	rows, err := readCSV(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 || len(rows[0]) < len(csvHeader) || !equalFold(rows[0][:len(csvHeader)], csvHeader) {
+		return nil, errors.Wrapf(ErrInvalidList, "csv: header must be %s", strings.Join(csvHeader, ","))
+	}
+	entries := make([]Entry, 0, len(rows)-1)
+	for _, row := range rows[1:] {
+		if len(row) < len(csvHeader) {
+			return nil, errors.Wrapf(ErrInvalidList, "csv: row %v has %d fields", row, len(row))
+		}
+		entries = append(entries, Entry{ID: strings.TrimSpace(row[0]), Name: strings.TrimSpace(row[1]), Aliases: split(row[2]), Type: strings.TrimSpace(row[3]), Programs: split(row[4])})
+	}
+	return compile(entries)
+}
+
+func readCSV(r io.Reader) ([][]string, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+	rows, err := reader.ReadAll()
+	if err != nil {
+		return nil, errors.Wrapf(ErrInvalidList, "csv: %v", err)
+	}
+	return rows, nil
+}
+
+// compile checks the entries and tokenizes their names for matching.
+func compile(entries []Entry) ([]Entry, error) {
+	for i := range entries {
+		e := &entries[i]
+		if e.ID == "" || e.Name == "" {
+			return nil, errors.Wrapf(ErrInvalidList, "entry %d needs an id and a name", i+1)
+		}
+		e.names = e.names[:0]
+		for _, name := range append([]string{e.Name}, e.Aliases...) {
+			if words := tokens(name); len(words) > 0 {
+				e.names = append(e.names, words)
+			}
+		}
+	}
+	return entries, nil
+}
+
+func programs(value string) []string {
+	if value = strings.TrimSpace(value); value == "" {
+		return nil
+	}
+	return []string{value}
+}
+
+func split(value string) []string {
+	var result []string
+	for _, part := range strings.Split(value, ";") {
+		if part = strings.TrimSpace(part); part != "" {
+			result = append(result, part)
+		}
+	}
+	return result
+}
+
+func equalFold(a, b []string) bool {
+	for i := range b {
+		if !strings.EqualFold(strings.TrimSpace(a[i]), b[i]) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,186 @@
+// Package screening checks the parties of credits and debits against sanctions lists and
+// other watchlists before the commands run, and blocks or quarantines the commands whose
+// parties match.
+package screening
+
+import (
+	"context"
+	"sort"
+	"sync"
+)
+
+// Role is the side of a command a party is on.
+type Role string
+
+const (
+	RoleOwner        Role = "owner"
+	RoleCounterparty Role = "counterparty"
+)
+
+// Party is who is behind a wallet.
+type Party struct {
+	WalletID string   `json:"wallet_id"`
+	UserID   string   `json:"user_id,omitempty"`
+	Names    []string `json:"names"`
+}
+
+// Directory tells who is behind a wallet. It returns nil for wallets it does not know,
+// such as accounts at other banks, which are then not screened.
+type Directory interface {
+	Party(ctx context.Context, walletID string) (*Party, error)
+}
+
+// MemoryDirectory is an in-memory Directory for tests and local runs.
+type MemoryDirectory struct {
+	mu      sync.RWMutex
+	parties map[string]Party
+}
+
+func NewMemoryDirectory(parties ...Party) *MemoryDirectory {
+	d := &MemoryDirectory{parties: make(map[string]Party)}
+	for _, p := range parties {
+		d.parties[p.WalletID] = p
+	}
+	return d
+}
+
+func (d *MemoryDirectory) Party(ctx context.Context, walletID string) (*Party, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	p, ok := d.parties[walletID]
+	if !ok {
+		return nil, nil
+	}
+	return &p, nil
+}
+
+// Match is a name of a party that matched a watchlist entry.
+type Match struct {
+	WalletID string `json:"wallet_id"`
+	Role     Role   `json:"role"`
+	// Name is the name of the party, EntryName the name or alias of the entry it matched.
+	Name      string   `json:"name"`
+	List      string   `json:"list"`
+	EntryID   string   `json:"entry_id"`
+	EntryName string   `json:"entry_name"`
+	Programs  []string `json:"programs,omitempty"`
+	// Score is how alike the names are, 1 for the same name.
+	Score float64 `json:"score"`
+}
+
+// Provider screens parties against watchlists. Matches are the best first; Role is set
+// by the caller.
+type Provider interface {
+	Name() string
+	Screen(ctx context.Context, party Party) ([]Match, error)
+}
+
+// DefaultThreshold is the lowest score ListProvider reports.
+const DefaultThreshold = 0.85
+
+// ListProvider screens against lists loaded from local files, matching names fuzzily so
+// that transliterations, typos and reordered names are found.
+type ListProvider struct {
+	mu    sync.RWMutex
+	lists []*List
+	// Threshold is the lowest score reported.
+	Threshold float64
+}
+
+func NewListProvider(lists ...*List) *ListProvider {
+	return &ListProvider{lists: lists, Threshold: DefaultThreshold}
+}
+
+// LoadListProvider loads the lists of files.
+func LoadListProvider(files ...ListFile) (*ListProvider, error) {
+	lists := make([]*List, 0, len(files))
+	for _, file := range files {
+		list, err := LoadList(file)
+		if err != nil {
+			return nil, err
+		}
+		lists = append(lists, list)
+	}
+	return NewListProvider(lists...), nil
+}
+
+// SetLists replaces the lists, e.g. once a new edition was downloaded, while screening
+// goes on.
+func (p *ListProvider) SetLists(lists ...*List) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.lists = lists
+}
+
+func (p *ListProvider) Name() string {
+	return "lists"
+}
+
+// Screen returns the best match of every entry that matches a name of the party.
+func (p *ListProvider) Screen(ctx context.Context, party Party) ([]Match, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	var matches []Match
+	for _, name := range party.Names {
+		words := tokens(name)
+		if len(words) == 0 {
+			continue
+		}
+		for _, list := range p.lists {
+			for i := range list.Entries {
+				entry := &list.Entries[i]
+				best, bestName := 0.0, 0
+				for j, listed := range entry.names {
+					if score := similarity(words, listed); score > best {
+						best, bestName = score, j
+					}
+				}
+				if best < p.Threshold {
+					continue
+				}
+				matches = append(matches, Match{
+					WalletID:  party.WalletID,
+					Name:      name,
+					List:      list.Name,
+					EntryID:   entry.ID,
+					EntryName: entryName(entry, bestName),
+					Programs:  entry.Programs,
+					Score:     best,
+				})
+			}
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].Score > matches[j].Score
+	})
+	return dedupe(matches), nil
+}
+
+// entryName returns the name or alias whose tokens are entry.names[i].
+func entryName(entry *Entry, i int) string {
+	for _, name := range append([]string{entry.Name}, entry.Aliases...) {
+		if len(tokens(name)) == 0 {
+			continue
+		}
+		if i == 0 {
+			return name
+		}
+		i--
+	}
+	return entry.Name
+}
+
+// dedupe keeps the best match of each entry, the first of sorted matches.
+func dedupe(matches []Match) []Match {
+	seen := make(map[string]bool)
+	result := matches[:0]
+	for _, m := range matches {
+		key := m.List + "/" + m.EntryID
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		result = append(result, m)
+	}
+	return result
+}
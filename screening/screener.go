@@ -0,0 +1,204 @@
+package screening
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/novabankapp/wallet.data/constants"
+	"github.com/novabankapp/wallet.data/domain"
+	"github.com/novabankapp/wallet.data/es/aggregate"
+	"github.com/novabankapp/wallet.data/es/commands"
+	"github.com/novabankapp/wallet.data/es/metadata"
+	"github.com/novabankapp/wallet.data/tracing"
+	"github.com/pkg/errors"
+	"github.com/shopspring/decimal"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// Policy turns the best score of a screening into its outcome.
+type Policy struct {
+	// Block is the lowest score that blocks the command.
+	Block float64
+	// Quarantine is the lowest score that holds the amount for review; scores under it
+	// let the command through.
+	Quarantine float64
+}
+
+func DefaultPolicy() Policy {
+	return Policy{Block: 0.97, Quarantine: DefaultThreshold}
+}
+
+// Decision is the outcome of screening a command: empty when no match reached the
+// quarantine score.
+type Decision struct {
+	Outcome domain.ScreeningOutcome `json:"outcome,omitempty"`
+	Matches []Match                 `json:"matches,omitempty"`
+}
+
+func (d Decision) String() string {
+	names := make([]string, 0, len(d.Matches))
+	for _, m := range d.Matches {
+		names = append(names, fmt.Sprintf("%s %q matches %s %s %q (%.2f)", m.Role, m.Name, m.List, m.EntryID, m.EntryName, m.Score))
+	}
+	return strings.Join(names, "; ")
+}
+
+// DefaultActorID is the actor of the commands the screener sends.
+const DefaultActorID = "sanctions-screening"
+
+// Screener screens the owner and the counterparty of credits and debits with Provider.
+// Hits are recorded on the wallet with a RecordScreening command sent as Principal.
+type Screener struct {
+	Provider  Provider
+	Directory Directory
+	Policy    Policy
+	Bus       *commands.Bus
+	Principal commands.Principal
+}
+
+func NewScreener(provider Provider, directory Directory, bus *commands.Bus, roles ...string) *Screener {
+	return &Screener{
+		Provider:  provider,
+		Directory: directory,
+		Policy:    DefaultPolicy(),
+		Bus:       bus,
+		Principal: commands.Principal{ID: DefaultActorID, Roles: roles, Type: metadata.ActorSystem},
+	}
+}
+
+// Middleware screens debit and credit commands before they are handled. Blocked commands
+// fail with an error matching commands.ErrBlocked. A quarantined debit holds its amount
+// on the wallet instead of sending it and fails with commands.ErrQuarantined; a
+// quarantined credit is credited and held, and succeeds. Commands are refused when
+// screening fails. It belongs after the authorization middleware, so that refused
+// principals cannot probe the lists.
+func (s *Screener) Middleware() commands.Middleware {
+	return func(next commands.HandlerFunc) commands.HandlerFunc {
+		return func(ctx context.Context, cmd commands.Command) (*commands.Result, error) {
+			counterparty, amount, ok := screenable(cmd)
+			if !ok || cmd.Validate() != nil {
+				return next(ctx, cmd)
+			}
+			decision, err := s.Screen(ctx, cmd.WalletID(), counterparty)
+			if err != nil {
+				return nil, errors.Wrap(err, "Screen")
+			}
+			if decision.Outcome == "" {
+				return next(ctx, cmd)
+			}
+			return s.enforce(ctx, cmd, counterparty, amount, decision)
+		}
+	}
+}
+
+// Screen screens the parties behind a wallet and its counterparty.
+func (s *Screener) Screen(ctx context.Context, walletID, counterparty string) (Decision, error) {
+	ctx, span := tracing.StartSpan(ctx, "Screener.Screen")
+	defer span.End()
+	span.SetAttributes(attribute.String(constants.WalletID, walletID))
+
+	var decision Decision
+	for _, side := range []struct {
+		walletID string
+		role     Role
+	}{{walletID, RoleOwner}, {counterparty, RoleCounterparty}} {
+		party, err := s.Directory.Party(ctx, side.walletID)
+		if err != nil {
+			tracing.TraceErr(span, err)
+			return Decision{}, errors.Wrap(err, "Directory.Party")
+		}
+		if party == nil {
+			continue
+		}
+		matches, err := s.Provider.Screen(ctx, *party)
+		if err != nil {
+			tracing.TraceErr(span, err)
+			return Decision{}, errors.Wrap(err, "Provider.Screen")
+		}
+		for _, m := range matches {
+			if m.Score < s.Policy.Quarantine {
+				continue
+			}
+			m.WalletID, m.Role = side.walletID, side.role
+			decision.Matches = append(decision.Matches, m)
+			switch {
+			case m.Score >= s.Policy.Block:
+				decision.Outcome = domain.ScreeningBlocked
+			case decision.Outcome == "":
+				decision.Outcome = domain.ScreeningQuarantined
+			}
+		}
+	}
+	span.SetAttributes(attribute.String("outcome", string(decision.Outcome)))
+	return decision, nil
+}
+
+func (s *Screener) enforce(ctx context.Context, cmd commands.Command, counterparty string, amount decimal.Decimal, decision Decision) (*commands.Result, error) {
+	matches, err := json.Marshal(decision.Matches)
+	if err != nil {
+		return nil, errors.Wrap(err, "json.Marshal")
+	}
+	record := commands.RecordScreeningCommand{ID: cmd.WalletID(), Screening: domain.Screening{
+		ID:                   uuid.New().String(),
+		Outcome:              decision.Outcome,
+		Command:              cmd.CommandName(),
+		CounterpartyWalletId: counterparty,
+		Amount:               amount,
+		Provider:             s.Provider.Name(),
+		Matches:              string(matches),
+		Description:          decision.String(),
+	}}
+
+	if decision.Outcome == domain.ScreeningQuarantined {
+		if credit, ok := cmd.(commands.CreditWalletCommand); ok {
+			record.Credit = &credit
+			return s.record(ctx, record)
+		}
+		_, err := s.record(ctx, record)
+		if err == nil {
+			return nil, errors.Wrapf(commands.ErrQuarantined, "%s: %s", cmd.CommandName(), decision)
+		}
+		// Funds that are not there cannot be held; the debit would fail anyway.
+		if !errors.Is(err, aggregate.ErrInsufficientFunds) {
+			return nil, err
+		}
+		record.Screening.Outcome = domain.ScreeningBlocked
+	}
+	if _, err := s.record(ctx, record); err != nil {
+		return nil, err
+	}
+	return nil, errors.Wrapf(commands.ErrBlocked, "%s: %s", cmd.CommandName(), decision)
+}
+
+// record sends the screening in the correlation of ctx, naming its causation as the
+// cause. The idempotency key of ctx belongs to the screened command and is left out.
+func (s *Screener) record(ctx context.Context, cmd commands.RecordScreeningCommand) (*commands.Result, error) {
+	cause, _ := metadata.FromContext(ctx)
+	ctx = metadata.WithMetadata(ctx, metadata.Metadata{
+		ActorID:       s.Principal.ID,
+		ActorType:     metadata.ActorSystem,
+		Channel:       metadata.ChannelInternal,
+		CorrelationID: cause.CorrelationID,
+		CausationID:   cause.CausationID,
+	})
+	ctx = commands.WithPrincipal(ctx, s.Principal)
+	ctx = commands.WithIdempotencyKey(ctx, "")
+	result, err := s.Bus.Dispatch(ctx, cmd)
+	if err != nil {
+		return nil, errors.Wrap(err, "Dispatch")
+	}
+	return result, nil
+}
+
+func screenable(cmd commands.Command) (string, decimal.Decimal, bool) {
+	switch c := cmd.(type) {
+	case commands.DebitWalletCommand:
+		return c.CreditWalletID, c.Amount, true
+	case commands.CreditWalletCommand:
+		return c.DebitWalletID, c.Amount, true
+	}
+	return "", decimal.Zero, false
+}
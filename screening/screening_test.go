@@ -0,0 +1,286 @@
+package screening_test
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/novabankapp/wallet.data/domain"
+	"github.com/novabankapp/wallet.data/es/aggregate"
+	"github.com/novabankapp/wallet.data/es/commands"
+	v1 "github.com/novabankapp/wallet.data/es/events/v1"
+	"github.com/novabankapp/wallet.data/es/metadata"
+	"github.com/novabankapp/wallet.data/es/store"
+	"github.com/novabankapp/wallet.data/screening"
+	"github.com/pkg/errors"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	sdnCSV = `36,"AEROCARIBBEAN AIRLINES",-0- ,"CUBA",-0- ,-0- ,-0- ,-0- ,-0- ,-0- ,-0- ,-0-
+7160,"IVANOV, Sergei Borisovich","individual","RUSSIA-EO14024] [UKRAINE-EO13660",-0- ,-0- ,-0- ,-0- ,-0- ,-0- ,-0- ,"DOB 31 Jan 1953."
+`
+	altCSV = `7160,10001,"aka","IVANOV, Sergey",-0-
+`
+	unXML = `<?xml version="1.0" encoding="UTF-8"?>
+<CONSOLIDATED_LIST dateGenerated="2026-10-01T00:00:00">
+  <INDIVIDUALS>
+    <INDIVIDUAL>
+      <DATAID>6908555</DATAID>
+      <FIRST_NAME>ABDUL</FIRST_NAME>
+      <SECOND_NAME>RAHMAN</SECOND_NAME>
+      <THIRD_NAME>MUSA</THIRD_NAME>
+      <UN_LIST_TYPE>Al-Qaida</UN_LIST_TYPE>
+      <INDIVIDUAL_ALIAS><QUALITY>Good</QUALITY><ALIAS_NAME>Abu Musa</ALIAS_NAME></INDIVIDUAL_ALIAS>
+      <INDIVIDUAL_ALIAS><QUALITY>Low</QUALITY><ALIAS_NAME></ALIAS_NAME></INDIVIDUAL_ALIAS>
+    </INDIVIDUAL>
+  </INDIVIDUALS>
+  <ENTITIES>
+    <ENTITY>
+      <DATAID>110123</DATAID>
+      <FIRST_NAME>GREEN DESERT TRADING LLC</FIRST_NAME>
+      <UN_LIST_TYPE>DPRK</UN_LIST_TYPE>
+    </ENTITY>
+  </ENTITIES>
+</CONSOLIDATED_LIST>`
+	inHouseCSV = `id,name,aliases,type,programs
+ih-1,Jürgen Müller,Juergen Mueller;J. Mueller,individual,internal
+`
+)
+
+func amount(value string) decimal.Decimal {
+	return decimal.RequireFromString(value)
+}
+
+func writeFile(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func loadProvider(t *testing.T) *screening.ListProvider {
+	t.Helper()
+	dir := t.TempDir()
+	provider, err := screening.LoadListProvider(
+		screening.ListFile{Name: "ofac-sdn", Format: screening.FormatOFAC, Path: writeFile(t, dir, "sdn.csv", sdnCSV), AltPath: writeFile(t, dir, "alt.csv", altCSV)},
+		screening.ListFile{Name: "un", Format: screening.FormatUN, Path: writeFile(t, dir, "un.xml", unXML)},
+		screening.ListFile{Format: screening.FormatCSV, Path: writeFile(t, dir, "in-house.csv", inHouseCSV)},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return provider
+}
+
+func TestLoadLists(t *testing.T) {
+	dir := t.TempDir()
+	ofac, err := screening.LoadList(screening.ListFile{Name: "ofac-sdn", Format: screening.FormatOFAC, Path: writeFile(t, dir, "sdn.csv", sdnCSV), AltPath: writeFile(t, dir, "alt.csv", altCSV)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ofac.Entries) != 2 {
+		t.Fatalf("entries %+v", ofac.Entries)
+	}
+	ivanov := ofac.Entries[1]
+	if ivanov.ID != "7160" || ivanov.Type != "individual" || len(ivanov.Programs) != 2 || ivanov.Programs[1] != "UKRAINE-EO13660" ||
+		len(ivanov.Aliases) != 1 || ivanov.Aliases[0] != "IVANOV, Sergey" {
+		t.Fatalf("entry %+v", ivanov)
+	}
+	if ofac.Entries[0].Type != "entity" {
+		t.Fatalf("entry %+v", ofac.Entries[0])
+	}
+
+	un, err := screening.LoadList(screening.ListFile{Format: screening.FormatUN, Path: writeFile(t, dir, "un.xml", unXML)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if un.Name != "un" || len(un.Entries) != 2 || un.Entries[0].Name != "ABDUL RAHMAN MUSA" || len(un.Entries[0].Aliases) != 1 || un.Entries[1].Type != "entity" {
+		t.Fatalf("list %+v", un)
+	}
+
+	if _, err := screening.LoadList(screening.ListFile{Format: screening.FormatCSV, Path: writeFile(t, dir, "bad.csv", "name\nsomeone\n")}); !errors.Is(err, screening.ErrInvalidList) {
+		t.Fatalf("LoadList = %v, want ErrInvalidList", err)
+	}
+	if _, err := screening.LoadList(screening.ListFile{Format: "pdf", Path: writeFile(t, dir, "list.pdf", "")}); !errors.Is(err, screening.ErrInvalidList) {
+		t.Fatalf("LoadList = %v, want ErrInvalidList", err)
+	}
+}
+
+func TestListProviderMatchesFuzzily(t *testing.T) {
+	provider := loadProvider(t)
+	tests := []struct {
+		name  string
+		entry string
+	}{
+		{"Sergei Ivanov", "7160"},
+		{"Sergey IVANOV", "7160"},
+		{"Sergei Ivanow", "7160"},
+		{"Abu Musa", "6908555"},
+		{"Abdul-Rahman Musa", "6908555"},
+		{"Green Desert Trading L.L.C.", "110123"},
+		{"Jurgen Muller", "ih-1"},
+		{"Jane Smith", ""},
+		{"Ivanov", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			matches, err := provider.Screen(context.Background(), screening.Party{WalletID: "w-1", Names: []string{tt.name}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.entry == "" {
+				if len(matches) != 0 {
+					t.Fatalf("matches %+v, want none", matches)
+				}
+				return
+			}
+			if len(matches) == 0 || matches[0].EntryID != tt.entry {
+				t.Fatalf("matches %+v, want %s first", matches, tt.entry)
+			}
+			if m := matches[0]; m.WalletID != "w-1" || m.Name != tt.name || m.Score < screening.DefaultThreshold || m.Score > 1 {
+				t.Fatalf("match %+v", m)
+			}
+		})
+	}
+}
+
+type fixture struct {
+	db  *store.MemoryEventStore
+	bus *commands.Bus
+}
+
+// newFixture screens w-1 and w-2 against the lists; "Sergei Borisovich Ivanov" blocks and
+// "Sergei Ivanow" quarantines.
+func newFixture(t *testing.T, owner, counterparty string) *fixture {
+	t.Helper()
+	db := store.NewMemoryEventStore()
+	directory := screening.NewMemoryDirectory(
+		screening.Party{WalletID: "w-1", UserID: "user-1", Names: []string{owner}},
+		screening.Party{WalletID: "w-2", UserID: "user-2", Names: []string{counterparty}},
+	)
+	// The screener records its screenings on the bus it guards.
+	screener := screening.NewScreener(loadProvider(t), directory, nil)
+	bus := commands.NewBus(commands.Causation(), screener.Middleware())
+	screener.Bus = bus
+	commands.RegisterWalletHandlers(bus, aggregate.NewWalletCommandExecutor(db))
+	f := &fixture{db: db, bus: bus}
+	if _, err := bus.Dispatch(context.Background(), commands.CreateWalletCommand{ID: "w-1", Amount: amount("100"), Description: "opening", UserID: "user-1", AccountID: "account-1"}); err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+func (f *fixture) wallet(t *testing.T) *aggregate.WalletAggregate {
+	t.Helper()
+	wallet, err := aggregate.LoadWalletAggregate(context.Background(), f.db, "w-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return wallet
+}
+
+// screening returns the last event of w-1, which must be a screening.
+func (f *fixture) screening(t *testing.T) (v1.WalletScreenedEvent, metadata.Metadata) {
+	t.Helper()
+	events, err := f.db.ReadEvents(context.Background(), aggregate.GetWalletStreamID("w-1"), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	last := events[len(events)-1]
+	if last.GetEventType() != v1.WalletScreened {
+		t.Fatalf("last event %s, want %s", last.GetEventType(), v1.WalletScreened)
+	}
+	var data v1.WalletScreenedEvent
+	if err := last.GetJsonData(&data); err != nil {
+		t.Fatal(err)
+	}
+	return data, metadata.FromEvent(last)
+}
+
+func TestMiddlewareLetsClearPartiesThrough(t *testing.T) {
+	f := newFixture(t, "Jane Smith", "John Doe")
+	if _, err := f.bus.Dispatch(context.Background(), commands.DebitWalletCommand{ID: "w-1", CreditWalletID: "w-2", Amount: amount("10"), Description: "rent"}); err != nil {
+		t.Fatal(err)
+	}
+	// Wallets the directory does not know are not screened.
+	if _, err := f.bus.Dispatch(context.Background(), commands.CreditWalletCommand{ID: "w-1", DebitWalletID: "bank-1", Amount: amount("5"), Description: "refund"}); err != nil {
+		t.Fatal(err)
+	}
+	if wallet := f.wallet(t); !wallet.Wallet.AvailableBalance.Equal(amount("95")) {
+		t.Fatalf("available %s", wallet.Wallet.AvailableBalance)
+	}
+}
+
+func TestMiddlewareBlocks(t *testing.T) {
+	f := newFixture(t, "Jane Smith", "Sergei Borisovich Ivanov")
+	_, err := f.bus.Dispatch(context.Background(), commands.DebitWalletCommand{ID: "w-1", CreditWalletID: "w-2", Amount: amount("10"), Description: "rent"})
+	if !errors.Is(err, commands.ErrBlocked) || commands.OutcomeOf(err) != commands.OutcomeRejected {
+		t.Fatalf("got %v, want %v", err, commands.ErrBlocked)
+	}
+	if wallet := f.wallet(t); !wallet.Wallet.Balance.Equal(amount("100")) || !wallet.Wallet.AvailableBalance.Equal(amount("100")) {
+		t.Fatalf("balance %s available %s", wallet.Wallet.Balance, wallet.Wallet.AvailableBalance)
+	}
+
+	data, m := f.screening(t)
+	if data.Outcome != string(domain.ScreeningBlocked) || data.Command != commands.DebitWallet || data.CounterpartyWalletId != "w-2" || data.Provider != "lists" {
+		t.Fatalf("screening %+v", data)
+	}
+	var matches []screening.Match
+	if err := json.Unmarshal([]byte(data.Matches), &matches); err != nil {
+		t.Fatal(err)
+	}
+	if len(matches) != 1 || matches[0].Role != screening.RoleCounterparty || matches[0].EntryID != "7160" || matches[0].List != "ofac-sdn" {
+		t.Fatalf("matches %+v", matches)
+	}
+	if m.ActorID != screening.DefaultActorID || m.ActorType != metadata.ActorSystem {
+		t.Fatalf("metadata %+v", m)
+	}
+}
+
+func TestMiddlewareQuarantinesDebits(t *testing.T) {
+	f := newFixture(t, "Sergei Ivanow", "John Doe")
+	_, err := f.bus.Dispatch(context.Background(), commands.DebitWalletCommand{ID: "w-1", CreditWalletID: "w-2", Amount: amount("30"), Description: "rent"})
+	if !errors.Is(err, commands.ErrQuarantined) || commands.OutcomeOf(err) != commands.OutcomeRejected {
+		t.Fatalf("got %v, want %v", err, commands.ErrQuarantined)
+	}
+	wallet := f.wallet(t)
+	if !wallet.Wallet.Balance.Equal(amount("100")) || !wallet.Wallet.GetHeldBalance().Equal(amount("30")) {
+		t.Fatalf("balance %s held %s", wallet.Wallet.Balance, wallet.Wallet.GetHeldBalance())
+	}
+	if data, _ := f.screening(t); data.Outcome != string(domain.ScreeningQuarantined) || !data.Amount.Equal(amount("30")) || !strings.Contains(data.Description, "owner") {
+		t.Fatalf("screening %+v", data)
+	}
+
+	// What cannot be held is blocked.
+	_, err = f.bus.Dispatch(context.Background(), commands.DebitWalletCommand{ID: "w-1", CreditWalletID: "w-2", Amount: amount("80"), Description: "rent"})
+	if !errors.Is(err, commands.ErrBlocked) {
+		t.Fatalf("got %v, want %v", err, commands.ErrBlocked)
+	}
+	if data, _ := f.screening(t); data.Outcome != string(domain.ScreeningBlocked) {
+		t.Fatalf("screening %+v", data)
+	}
+}
+
+func TestMiddlewareQuarantinesCredits(t *testing.T) {
+	f := newFixture(t, "Jane Smith", "Sergei Ivanow")
+	result, err := f.bus.Dispatch(context.Background(), commands.CreditWalletCommand{ID: "w-1", DebitWalletID: "w-2", Amount: amount("50"), Description: "gift"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	wallet := f.wallet(t)
+	if result.Version != wallet.GetVersion() || !wallet.Wallet.Balance.Equal(amount("150")) || !wallet.Wallet.AvailableBalance.Equal(amount("100")) {
+		t.Fatalf("version %d of %d, balance %s available %s", result.Version, wallet.GetVersion(), wallet.Wallet.Balance, wallet.Wallet.AvailableBalance)
+	}
+	if len(*wallet.WalletTransactions) != 1 {
+		t.Fatalf("transactions %+v", *wallet.WalletTransactions)
+	}
+	if data, _ := f.screening(t); data.Outcome != string(domain.ScreeningQuarantined) || data.Command != commands.CreditWallet {
+		t.Fatalf("screening %+v", data)
+	}
+}
@@ -0,0 +1,55 @@
+package statements
+
+import (
+	"encoding/csv"
+	"io"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+const csvDateLayout = "2006-01-02"
+
+var csvHeader = []string{"date", "reference", "description", "counterparty", "debit", "credit", "balance", "currency"}
+
+// WriteCSV renders the statement as CSV with one row per transaction, framed by an
+// opening and a closing balance row.
+func WriteCSV(w io.Writer, s *Statement) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(csvHeader); err != nil {
+		return err
+	}
+	if err := writer.Write(balanceRow(s.From, "Opening balance", s.OpeningBalance, s.Currency)); err != nil {
+		return err
+	}
+	for _, line := range s.Lines {
+		debit, credit := "", ""
+		if line.IsCredit() {
+			credit = line.Amount.StringFixed(2)
+		} else {
+			debit = line.Amount.Abs().StringFixed(2)
+		}
+		row := []string{
+			line.BookingDate.Format(csvDateLayout),
+			line.ID,
+			line.Description,
+			line.Counterparty,
+			debit,
+			credit,
+			line.Balance.StringFixed(2),
+			s.Currency,
+		}
+		if err := writer.Write(row); err != nil {
+			return err
+		}
+	}
+	if err := writer.Write(balanceRow(s.To.Add(-time.Second), "Closing balance", s.ClosingBalance, s.Currency)); err != nil {
+		return err
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+func balanceRow(date time.Time, label string, balance decimal.Decimal, currency string) []string {
+	return []string{date.Format(csvDateLayout), "", label, "", "", "", balance.StringFixed(2), currency}
+}
@@ -0,0 +1,114 @@
+package statements
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+const (
+	mt940DateLayout      = "060102"
+	mt940EntryDateLayout = "0102"
+	mt940LineLength      = 65
+	mt940InfoLines       = 6
+)
+
+// MT940Options carries the values MT940 needs that are not part of a wallet statement.
+type MT940Options struct {
+	Reference       string
+	StatementNumber int
+}
+
+// WriteMT940 renders the statement as a SWIFT MT940 customer statement message body.
+// Free text is reduced to the SWIFT X character set.
+func WriteMT940(w io.Writer, s *Statement, opts MT940Options) error {
+	if opts.StatementNumber <= 0 {
+		opts.StatementNumber = 1
+	}
+	reference := opts.Reference
+	if reference == "" {
+		reference = s.From.UTC().Format("20060102") + strings.ReplaceAll(s.WalletId, "-", "")
+	}
+
+	out := bufio.NewWriter(w)
+	write := func(tag, value string) {
+		fmt.Fprintf(out, ":%s:%s\r\n", tag, value)
+	}
+
+	write("20", truncate(swiftText(reference), 16))
+	write("25", truncate(swiftText(s.AccountId), 35))
+	write("28C", fmt.Sprintf("%05d/001", opts.StatementNumber%100000))
+	write("60F", mt940Balance(s.From, s.Currency, s.OpeningBalance))
+	for _, line := range s.Lines {
+		mark := "D"
+		if line.IsCredit() {
+			mark = "C"
+		}
+		ref := truncate(strings.ReplaceAll(line.ID, "-", ""), 16)
+		if ref == "" {
+			ref = "NONREF"
+		}
+		write("61", fmt.Sprintf("%s%s%s%sNTRF%s",
+			line.BookingDate.UTC().Format(mt940DateLayout),
+			line.BookingDate.UTC().Format(mt940EntryDateLayout),
+			mark,
+			mt940Amount(line.Amount.Abs()),
+			ref))
+		if info := mt940Info(line); info != "" {
+			write("86", info)
+		}
+	}
+	write("62F", mt940Balance(s.To.Add(-time.Second), s.Currency, s.ClosingBalance))
+	fmt.Fprint(out, "-\r\n")
+	return out.Flush()
+}
+
+func mt940Balance(date time.Time, currency string, balance decimal.Decimal) string {
+	mark := "C"
+	if balance.IsNegative() {
+		mark = "D"
+	}
+	return mark + date.UTC().Format(mt940DateLayout) + strings.ToUpper(currency) + mt940Amount(balance.Abs())
+}
+
+// mt940Amount formats an amount with a decimal comma, as SWIFT requires.
+func mt940Amount(amount decimal.Decimal) string {
+	return strings.Replace(amount.StringFixed(2), ".", ",", 1)
+}
+
+func mt940Info(line Line) string {
+	text := swiftText(strings.TrimSpace(line.Description))
+	if line.Counterparty != "" {
+		text = strings.TrimSpace(swiftText("/CPTY/"+line.Counterparty) + " " + text)
+	}
+	var parts []string
+	for len(text) > 0 && len(parts) < mt940InfoLines {
+		n := mt940LineLength
+		if len(text) < n {
+			n = len(text)
+		}
+		parts = append(parts, text[:n])
+		text = text[n:]
+	}
+	return strings.Join(parts, "\r\n")
+}
+
+// swiftText replaces everything outside the SWIFT X character set with a space.
+func swiftText(value string) string {
+	var b strings.Builder
+	for _, r := range value {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			b.WriteRune(r)
+		case strings.ContainsRune("/-?:().,'+ ", r):
+			b.WriteRune(r)
+		default:
+			b.WriteRune(' ')
+		}
+	}
+	return b.String()
+}
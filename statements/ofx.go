@@ -0,0 +1,132 @@
+package statements
+
+import (
+	"encoding/xml"
+	"io"
+	"time"
+)
+
+const (
+	ofxDateLayout = "20060102150405"
+	ofxHeader     = `<?xml version="1.0" encoding="UTF-8" standalone="no"?>` + "\n" +
+		`<?OFX OFXHEADER="200" VERSION="220" SECURITY="NONE" OLDFILEUID="NONE" NEWFILEUID="NONE"?>` + "\n"
+)
+
+type ofxDocument struct {
+	XMLName xml.Name        `xml:"OFX"`
+	SignOn  ofxSignOnMsgs   `xml:"SIGNONMSGSRSV1"`
+	Bank    ofxBankMsgsRsV1 `xml:"BANKMSGSRSV1"`
+}
+
+type ofxStatus struct {
+	Code     int    `xml:"CODE"`
+	Severity string `xml:"SEVERITY"`
+}
+
+type ofxSignOnMsgs struct {
+	Response struct {
+		Status   ofxStatus `xml:"STATUS"`
+		DtServer string    `xml:"DTSERVER"`
+		Language string    `xml:"LANGUAGE"`
+	} `xml:"SONRS"`
+}
+
+type ofxBankMsgsRsV1 struct {
+	Transaction struct {
+		TrnUID    string    `xml:"TRNUID"`
+		Status    ofxStatus `xml:"STATUS"`
+		Statement struct {
+			Currency    string `xml:"CURDEF"`
+			AccountFrom struct {
+				BankID   string `xml:"BANKID"`
+				AcctID   string `xml:"ACCTID"`
+				AcctType string `xml:"ACCTTYPE"`
+			} `xml:"BANKACCTFROM"`
+			TransactionList struct {
+				DtStart      string           `xml:"DTSTART"`
+				DtEnd        string           `xml:"DTEND"`
+				Transactions []ofxTransaction `xml:"STMTTRN"`
+			} `xml:"BANKTRANLIST"`
+			LedgerBalance ofxBalance `xml:"LEDGERBAL"`
+		} `xml:"STMTRS"`
+	} `xml:"STMTTRNRS"`
+}
+
+type ofxTransaction struct {
+	TrnType  string `xml:"TRNTYPE"`
+	DtPosted string `xml:"DTPOSTED"`
+	TrnAmt   string `xml:"TRNAMT"`
+	FitID    string `xml:"FITID"`
+	Name     string `xml:"NAME,omitempty"`
+	Memo     string `xml:"MEMO,omitempty"`
+}
+
+type ofxBalance struct {
+	Amount string `xml:"BALAMT"`
+	DtAsOf string `xml:"DTASOF"`
+}
+
+// OFXOptions carries the values OFX needs that are not part of a wallet statement.
+type OFXOptions struct {
+	BankID      string
+	AccountType string
+}
+
+// WriteOFX renders the statement as an OFX 2.2 bank statement response.
+func WriteOFX(w io.Writer, s *Statement, opts OFXOptions) error {
+	if opts.AccountType == "" {
+		opts.AccountType = "CHECKING"
+	}
+
+	var doc ofxDocument
+	doc.SignOn.Response.Status = ofxStatus{Code: 0, Severity: "INFO"}
+	doc.SignOn.Response.DtServer = s.GeneratedAt.UTC().Format(ofxDateLayout)
+	doc.SignOn.Response.Language = "ENG"
+
+	trn := &doc.Bank.Transaction
+	trn.TrnUID = s.WalletId + "-" + s.From.UTC().Format(ofxDateLayout)
+	trn.Status = ofxStatus{Code: 0, Severity: "INFO"}
+	stmt := &trn.Statement
+	stmt.Currency = s.Currency
+	stmt.AccountFrom.BankID = opts.BankID
+	stmt.AccountFrom.AcctID = s.AccountId
+	stmt.AccountFrom.AcctType = opts.AccountType
+	stmt.TransactionList.DtStart = s.From.UTC().Format(ofxDateLayout)
+	stmt.TransactionList.DtEnd = s.To.UTC().Format(ofxDateLayout)
+	for _, line := range s.Lines {
+		trnType := "DEBIT"
+		if line.IsCredit() {
+			trnType = "CREDIT"
+		}
+		stmt.TransactionList.Transactions = append(stmt.TransactionList.Transactions, ofxTransaction{
+			TrnType:  trnType,
+			DtPosted: line.BookingDate.UTC().Format(ofxDateLayout),
+			TrnAmt:   line.Amount.StringFixed(2),
+			FitID:    line.ID,
+			Name:     truncate(line.Counterparty, 32),
+			Memo:     truncate(line.Description, 255),
+		})
+	}
+	stmt.LedgerBalance = ofxBalance{
+		Amount: s.ClosingBalance.StringFixed(2),
+		DtAsOf: s.To.Add(-time.Second).UTC().Format(ofxDateLayout),
+	}
+
+	if _, err := io.WriteString(w, ofxHeader); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(doc); err != nil {
+		return err
+	}
+	return encoder.Flush()
+}
+
+func truncate(value string, max int) string {
+	runes := []rune(value)
+	if len(runes) <= max {
+		return value
+	}
+	return string(runes[:max])
+}
@@ -0,0 +1,130 @@
+package statements
+
+import (
+	"context"
+	"time"
+
+	"github.com/novabankapp/wallet.data/constants"
+	"github.com/novabankapp/wallet.data/domain"
+	"github.com/novabankapp/wallet.data/es/aggregate"
+	"github.com/novabankapp/wallet.data/es/queries"
+	"github.com/novabankapp/wallet.data/tracing"
+	"github.com/pkg/errors"
+	"github.com/shopspring/decimal"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+var (
+	ErrInvalidPeriod    = errors.New("statement period start must be before its end")
+	ErrCurrencyRequired = errors.New("statement currency is required")
+)
+
+// Line is one booked transaction on a statement. Amount is signed: credits are positive
+// and debits negative. Balance is the running balance after the line.
+type Line struct {
+	ID           string          `json:"id"`
+	BookingDate  time.Time       `json:"booking_date"`
+	Amount       decimal.Decimal `json:"amount"`
+	Counterparty string          `json:"counterparty"`
+	Description  string          `json:"description"`
+	Balance      decimal.Decimal `json:"balance"`
+}
+
+func (l Line) IsCredit() bool {
+	return !l.Amount.IsNegative()
+}
+
+type Statement struct {
+	WalletId       string          `json:"wallet_id"`
+	AccountId      string          `json:"account_id"`
+	Currency       string          `json:"currency"`
+	From           time.Time       `json:"from"`
+	To             time.Time       `json:"to"`
+	OpeningBalance decimal.Decimal `json:"opening_balance"`
+	ClosingBalance decimal.Decimal `json:"closing_balance"`
+	Lines          []Line          `json:"lines"`
+	GeneratedAt    time.Time       `json:"generated_at"`
+}
+
+type Generator struct {
+	Transactions *queries.WalletTransactionQueries
+	Currency     string
+}
+
+func NewGenerator(transactions *queries.WalletTransactionQueries, currency string) *Generator {
+	return &Generator{Transactions: transactions, Currency: currency}
+}
+
+// Generate builds the statement of a wallet for the period [from, to). The balances are
+// derived from the wallet's current balance by backing out later transactions.
+func (g *Generator) Generate(ctx context.Context, walletId string, from, to time.Time) (*Statement, error) {
+	ctx, span := tracing.StartSpan(ctx, "Generator.Generate")
+	defer span.End()
+	span.SetAttributes(attribute.String(constants.WalletID, walletId))
+
+	if !from.Before(to) {
+		return nil, ErrInvalidPeriod
+	}
+	if g.Currency == "" {
+		return nil, ErrCurrencyRequired
+	}
+
+	ent, err := g.Transactions.GetWalletProjection(ctx, walletId)
+	if err != nil {
+		return nil, err
+	}
+	wallet, err := aggregate.GetEntityFromJsonString[domain.Wallet](ent.Wallet)
+	if err != nil {
+		return nil, errors.Wrap(err, "GetEntityFromJsonString")
+	}
+	history, err := g.Transactions.GetAllWalletTransactions(ctx, walletId, queries.TransactionFilter{From: &from})
+	if err != nil {
+		return nil, err
+	}
+
+	closing := wallet.Balance
+	lines := make([]Line, 0, len(history))
+	for _, tx := range history {
+		amount := signedAmount(walletId, tx)
+		if !tx.CreatedAt.Before(to) {
+			closing = closing.Sub(amount)
+			continue
+		}
+		lines = append(lines, Line{
+			ID:           tx.ID.String(),
+			BookingDate:  tx.CreatedAt,
+			Amount:       amount,
+			Counterparty: queries.Counterparty(walletId, tx),
+			Description:  tx.Description,
+		})
+	}
+
+	opening := closing
+	for _, line := range lines {
+		opening = opening.Sub(line.Amount)
+	}
+	running := opening
+	for i := range lines {
+		running = running.Add(lines[i].Amount)
+		lines[i].Balance = running
+	}
+
+	return &Statement{
+		WalletId:       walletId,
+		AccountId:      wallet.AccountId,
+		Currency:       g.Currency,
+		From:           from,
+		To:             to,
+		OpeningBalance: opening,
+		ClosingBalance: closing,
+		Lines:          lines,
+		GeneratedAt:    time.Now().UTC(),
+	}, nil
+}
+
+func signedAmount(walletId string, tx domain.WalletTransaction) decimal.Decimal {
+	if tx.CreditWalletId == walletId {
+		return tx.Amount
+	}
+	return tx.Amount.Neg()
+}
@@ -0,0 +1,166 @@
+package statements_test
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gocql/gocql"
+	"github.com/novabankapp/wallet.data/domain"
+	"github.com/novabankapp/wallet.data/es/queries"
+	"github.com/novabankapp/wallet.data/es/readmodel/readmodeltest"
+	"github.com/novabankapp/wallet.data/statements"
+	"github.com/pkg/errors"
+	"github.com/shopspring/decimal"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+const walletID = "w-1"
+
+var generatedAt = time.Date(2026, 4, 2, 8, 0, 0, 0, time.UTC)
+
+func amount(value string) decimal.Decimal {
+	return decimal.RequireFromString(value)
+}
+
+func transfer(id, at, value, debit, credit, description string) domain.WalletTransaction {
+	uuid, err := gocql.ParseUUID(id)
+	if err != nil {
+		panic(err)
+	}
+	createdAt, err := time.Parse(time.RFC3339, at)
+	if err != nil {
+		panic(err)
+	}
+	return domain.WalletTransaction{
+		ID:             uuid,
+		CreatedAt:      createdAt,
+		Amount:         amount(value),
+		DebitWalletId:  debit,
+		CreditWalletId: credit,
+		Description:    description,
+	}
+}
+
+// generator is backed by a wallet that went into overdraft in March and had one more
+// debit in April: its balance is now -35.50.
+func generator() *statements.Generator {
+	row := readmodeltest.Row(domain.Wallet{ID: walletID, AccountId: "ACC-001", Balance: amount("-35.50")}, domain.WalletState{}, []domain.WalletTransaction{
+		transfer("5b3f0e2a-6c1d-4f7e-9a21-0c8e4d7b1f01", "2026-03-02T10:00:00Z", "100", "w-2", walletID, "Salary March"),
+		transfer("a1c9d8e7-2b4f-4e6a-8d3c-7f5e9b0a2c02", "2026-03-05T16:30:00Z", "130.50", walletID, "w-3", "Rent – März, flat #4"),
+		transfer("e4f7a2b9-8c6d-4b1e-a5f3-2d9c7e1b4a03", "2026-03-20T09:15:00Z", "5", "w-2", walletID, `Refund "deposit"`),
+		transfer("0d2e6f8a-4c1b-4a9d-b7e5-6a3f8c2d9e04", "2026-04-01T00:00:00Z", "10", walletID, "w-3", "April fee"),
+	})
+	return statements.NewGenerator(queries.NewWalletTransactionQueries(readmodeltest.New(row)), "MWK")
+}
+
+func generate(t *testing.T, from, to string) *statements.Statement {
+	t.Helper()
+	start, err := time.Parse(time.RFC3339, from)
+	if err != nil {
+		t.Fatal(err)
+	}
+	end, err := time.Parse(time.RFC3339, to)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := generator().Generate(context.Background(), walletID, start, end)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.GeneratedAt = generatedAt
+	return s
+}
+
+// golden compares got with testdata/name, or rewrites the file when -update is set.
+func golden(t *testing.T, name string, got []byte) {
+	t.Helper()
+	path := filepath.Join("testdata", name)
+	if *update {
+		if err := os.WriteFile(path, got, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Fatalf("%s differs from the golden file:\n%s\nwant:\n%s", name, got, want)
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	s := generate(t, "2026-03-01T00:00:00Z", "2026-04-01T00:00:00Z")
+
+	if !s.OpeningBalance.Equal(amount("0")) || !s.ClosingBalance.Equal(amount("-25.50")) {
+		t.Fatalf("opening %s, closing %s", s.OpeningBalance, s.ClosingBalance)
+	}
+	want := []string{"100", "-30.5", "-25.5"}
+	if len(s.Lines) != len(want) {
+		t.Fatalf("lines %+v", s.Lines)
+	}
+	for i, line := range s.Lines {
+		if !line.Balance.Equal(amount(want[i])) {
+			t.Fatalf("line %d balance %s, want %s", i, line.Balance, want[i])
+		}
+	}
+	if s.Lines[1].IsCredit() || s.Lines[1].Counterparty != "w-3" {
+		t.Fatalf("line 1 %+v", s.Lines[1])
+	}
+}
+
+func TestGenerateRejectsInvalidRequests(t *testing.T) {
+	g := generator()
+	at := generatedAt
+	if _, err := g.Generate(context.Background(), walletID, at, at); !errors.Is(err, statements.ErrInvalidPeriod) {
+		t.Fatalf("got %v, want %v", err, statements.ErrInvalidPeriod)
+	}
+	g.Currency = ""
+	if _, err := g.Generate(context.Background(), walletID, at, at.Add(time.Hour)); !errors.Is(err, statements.ErrCurrencyRequired) {
+		t.Fatalf("got %v, want %v", err, statements.ErrCurrencyRequired)
+	}
+}
+
+func TestRenderers(t *testing.T) {
+	periods := []struct {
+		name     string
+		from, to string
+	}{
+		{"march", "2026-03-01T00:00:00Z", "2026-04-01T00:00:00Z"},
+		{"empty", "2026-05-01T00:00:00Z", "2026-06-01T00:00:00Z"},
+	}
+	renderers := []struct {
+		ext    string
+		render func(buf *bytes.Buffer, s *statements.Statement) error
+	}{
+		{"csv", func(buf *bytes.Buffer, s *statements.Statement) error {
+			return statements.WriteCSV(buf, s)
+		}},
+		{"ofx", func(buf *bytes.Buffer, s *statements.Statement) error {
+			return statements.WriteOFX(buf, s, statements.OFXOptions{BankID: "NOVABANK"})
+		}},
+		{"mt940", func(buf *bytes.Buffer, s *statements.Statement) error {
+			return statements.WriteMT940(buf, s, statements.MT940Options{StatementNumber: 3})
+		}},
+	}
+	for _, period := range periods {
+		s := generate(t, period.from, period.to)
+		for _, r := range renderers {
+			name := period.name + "." + r.ext
+			t.Run(name, func(t *testing.T) {
+				var buf bytes.Buffer
+				if err := r.render(&buf, s); err != nil {
+					t.Fatal(err)
+				}
+				golden(t, name, buf.Bytes())
+			})
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package tracing
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"net/url"
+	"strconv"
+	"strings"
+
+	es "github.com/novabankapp/common.data/eventstore"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	TraceParentKey = "traceparent"
+
+	// Keys of the OpenTracing text-map carrier that events were written with before.
+	jaegerTraceKey      = "uber-trace-id"
+	otTracerTraceIDKey  = "ot-tracer-traceid"
+	otTracerSpanIDKey   = "ot-tracer-spanid"
+	otTracerSampledKey  = "ot-tracer-sampled"
+	traceIDHexLength    = 32
+	spanIDHexLength     = 16
+	jaegerSampledFlag   = 1
+	jaegerTraceIDFields = 4
+)
+
+// EventMetadata returns the trace context of the span in ctx in the form it is stored in
+// event metadata: the W3C traceparent and, when set, tracestate and baggage.
+func EventMetadata(ctx context.Context) map[string]string {
+	carrier := propagation.MapCarrier{}
+	Propagator.Inject(ctx, carrier)
+	return carrier
+}
+
+// ContextFromEvent returns ctx with the span that wrote evt as its remote parent. Events
+// written with the W3C trace context are read with Propagator; older events carry an
+// OpenTracing text map, which is read by the compatibility reader. ctx is returned as
+// is when the metadata holds no usable trace context.
+func ContextFromEvent(ctx context.Context, evt es.Event) context.Context {
+	carrier := metadataCarrier(evt.GetMetadata())
+	if carrier[TraceParentKey] != "" {
+		return Propagator.Extract(ctx, carrier)
+	}
+	if parent, ok := legacySpanContext(carrier); ok {
+		return trace.ContextWithRemoteSpanContext(ctx, parent)
+	}
+	return ctx
+}
+
+// StartEventSpan starts the span of handling evt. It continues the trace of the command
+// that wrote the event; a span already in ctx, e.g. of a replay, is kept as a link.
+func StartEventSpan(ctx context.Context, name string, evt es.Event, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	eventCtx := ContextFromEvent(ctx, evt)
+	if producer := trace.SpanContextFromContext(eventCtx); producer.IsValid() {
+		if current := trace.SpanContextFromContext(ctx); current.IsValid() && !current.Equal(producer) {
+			opts = append(opts, trace.WithLinks(trace.Link{SpanContext: current}))
+		}
+		ctx = eventCtx
+	}
+	opts = append(opts, trace.WithAttributes(
+		attribute.String("AggregateID", evt.GetAggregateID()),
+		attribute.String("EventID", evt.GetEventID()),
+		attribute.String("EventType", evt.GetEventType()),
+	))
+	return StartSpan(ctx, name, opts...)
+}
+
+// metadataCarrier reads the string fields of an event's JSON metadata. Fields that are
+// not strings, such as nested objects, are left out.
+func metadataCarrier(metadata []byte) propagation.MapCarrier {
+	carrier := propagation.MapCarrier{}
+	if len(metadata) == 0 {
+		return carrier
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal(metadata, &fields); err != nil {
+		return carrier
+	}
+	for key, value := range fields {
+		if s, ok := value.(string); ok {
+			carrier[strings.ToLower(key)] = s
+		}
+	}
+	return carrier
+}
+
+// legacySpanContext reads the span context of an OpenTracing text map, as written by the
+// Jaeger client ("uber-trace-id") or by basictracer ("ot-tracer-*").
+func legacySpanContext(carrier propagation.MapCarrier) (trace.SpanContext, bool) {
+	if value := carrier[jaegerTraceKey]; value != "" {
+		if unescaped, err := url.QueryUnescape(value); err == nil {
+			value = unescaped
+		}
+		fields := strings.Split(value, ":")
+		if len(fields) != jaegerTraceIDFields {
+			return trace.SpanContext{}, false
+		}
+		flags, err := strconv.ParseUint(fields[3], 16, 8)
+		if err != nil {
+			return trace.SpanContext{}, false
+		}
+		return remoteSpanContext(fields[0], fields[1], flags&jaegerSampledFlag != 0)
+	}
+	if carrier[otTracerTraceIDKey] != "" {
+		sampled, _ := strconv.ParseBool(carrier[otTracerSampledKey])
+		return remoteSpanContext(carrier[otTracerTraceIDKey], carrier[otTracerSpanIDKey], sampled)
+	}
+	return trace.SpanContext{}, false
+}
+
+// remoteSpanContext builds a span context from hex ids, which OpenTracing tracers write
+// without leading zeros and, for 64-bit trace ids, with only 16 digits.
+func remoteSpanContext(traceIDHex, spanIDHex string, sampled bool) (trace.SpanContext, bool) {
+	if len(traceIDHex) > traceIDHexLength || len(spanIDHex) > spanIDHexLength {
+		return trace.SpanContext{}, false
+	}
+	var traceID trace.TraceID
+	if _, err := hex.Decode(traceID[:], []byte(leftPad(traceIDHex, traceIDHexLength))); err != nil {
+		return trace.SpanContext{}, false
+	}
+	var spanID trace.SpanID
+	if _, err := hex.Decode(spanID[:], []byte(leftPad(spanIDHex, spanIDHexLength))); err != nil {
+		return trace.SpanContext{}, false
+	}
+	config := trace.SpanContextConfig{TraceID: traceID, SpanID: spanID, Remote: true}
+	if sampled {
+		config.TraceFlags = trace.FlagsSampled
+	}
+	spanContext := trace.NewSpanContext(config)
+	return spanContext, spanContext.IsValid()
+}
+
+func leftPad(s string, length int) string {
+	return strings.Repeat("0", length-len(s)) + s
+}
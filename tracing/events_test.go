@@ -0,0 +1,137 @@
+package tracing_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	es "github.com/novabankapp/common.data/eventstore"
+	"github.com/novabankapp/wallet.data/tracing"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func record(t *testing.T) *tracetest.SpanRecorder {
+	t.Helper()
+	spans := tracetest.NewSpanRecorder()
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSampler(sdktrace.AlwaysSample()), sdktrace.WithSpanProcessor(spans)))
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+	return spans
+}
+
+func eventWithMetadata(t *testing.T, metadata interface{}) es.Event {
+	t.Helper()
+	evt := es.Event{EventID: "e-1", EventType: "WALLET_CREDITED", AggregateID: "wallet-w-1"}
+	if metadata != nil {
+		data, err := json.Marshal(metadata)
+		if err != nil {
+			t.Fatal(err)
+		}
+		evt.Metadata = data
+	}
+	return evt
+}
+
+func handle(t *testing.T, ctx context.Context, spans *tracetest.SpanRecorder, evt es.Event) sdktrace.ReadOnlySpan {
+	t.Helper()
+	_, span := tracing.StartEventSpan(ctx, "handle", evt)
+	span.End()
+	ended := spans.Ended()
+	return ended[len(ended)-1]
+}
+
+func TestEventSpanContinuesTheWritersTrace(t *testing.T) {
+	spans := record(t)
+	ctx, writer := tracing.StartSpan(context.Background(), "write")
+	metadata := tracing.EventMetadata(ctx)
+	writer.End()
+	if metadata[tracing.TraceParentKey] == "" {
+		t.Fatalf("metadata %v has no traceparent", metadata)
+	}
+
+	handled := handle(t, context.Background(), spans, eventWithMetadata(t, metadata))
+	if handled.Parent().SpanID() != writer.SpanContext().SpanID() || handled.SpanContext().TraceID() != writer.SpanContext().TraceID() {
+		t.Fatalf("span %v is not a child of the writer %v", handled.Parent(), writer.SpanContext())
+	}
+	if !handled.Parent().IsRemote() {
+		t.Error("parent is not remote")
+	}
+}
+
+func TestEventSpanReadsLegacyMetadata(t *testing.T) {
+	spans := record(t)
+	for name, c := range map[string]struct {
+		metadata map[string]interface{}
+		traceID  string
+		spanID   string
+		sampled  bool
+	}{
+		"jaeger": {
+			metadata: map[string]interface{}{"uber-trace-id": "5b8aa5a2d2c872e8:d2c872e85b8aa5a2:0:1"},
+			traceID:  "00000000000000005b8aa5a2d2c872e8", spanID: "d2c872e85b8aa5a2", sampled: true,
+		},
+		"jaeger url-encoded 128 bit": {
+			metadata: map[string]interface{}{"Uber-Trace-Id": "4bf92f3577b34da6a3ce929d0e0e4736%3A53995c3f42cd8ad8%3A0%3A0"},
+			traceID:  "4bf92f3577b34da6a3ce929d0e0e4736", spanID: "53995c3f42cd8ad8",
+		},
+		"basictracer": {
+			metadata: map[string]interface{}{"ot-tracer-traceid": "a3ce929d0e0e4736", "ot-tracer-spanid": "f42cd8ad8", "ot-tracer-sampled": "true"},
+			traceID:  "0000000000000000a3ce929d0e0e4736", spanID: "0000000f42cd8ad8", sampled: true,
+		},
+		"other fields are ignored": {
+			metadata: map[string]interface{}{"uber-trace-id": "1:2:0:1", "actor": map[string]interface{}{"id": "u-1"}},
+			traceID:  "00000000000000000000000000000001", spanID: "0000000000000002", sampled: true,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			parent := handle(t, context.Background(), spans, eventWithMetadata(t, c.metadata)).Parent()
+			if parent.TraceID().String() != c.traceID || parent.SpanID().String() != c.spanID || parent.IsSampled() != c.sampled {
+				t.Fatalf("parent trace %s span %s sampled %v", parent.TraceID(), parent.SpanID(), parent.IsSampled())
+			}
+		})
+	}
+}
+
+func TestEventSpanWithoutTraceContext(t *testing.T) {
+	spans := record(t)
+	ctx, current := tracing.StartSpan(context.Background(), "replay")
+	defer current.End()
+
+	for name, metadata := range map[string]interface{}{
+		"no metadata":        nil,
+		"no trace context":   map[string]string{"source": "import"},
+		"broken jaeger id":   map[string]string{"uber-trace-id": "not-a-trace"},
+		"broken traceparent": map[string]string{"traceparent": "00-zz-zz-01"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			handled := handle(t, ctx, spans, eventWithMetadata(t, metadata))
+			if handled.Parent().SpanID() != current.SpanContext().SpanID() {
+				t.Fatalf("parent %v, expected the span in ctx", handled.Parent())
+			}
+			if len(handled.Links()) != 0 {
+				t.Fatalf("links %v", handled.Links())
+			}
+		})
+	}
+}
+
+func TestEventSpanLinksTheSpanInContext(t *testing.T) {
+	spans := record(t)
+	writeCtx, writer := tracing.StartSpan(context.Background(), "write")
+	metadata := tracing.EventMetadata(writeCtx)
+	writer.End()
+	ctx, replay := tracing.StartSpan(context.Background(), "replay")
+	defer replay.End()
+
+	handled := handle(t, ctx, spans, eventWithMetadata(t, metadata))
+	if handled.Parent().SpanID() != writer.SpanContext().SpanID() {
+		t.Fatalf("parent %v, expected the writer", handled.Parent())
+	}
+	links := handled.Links()
+	if len(links) != 1 || !links[0].SpanContext.Equal(trace.SpanContextFromContext(ctx)) {
+		t.Fatalf("links %v, expected the replay span", links)
+	}
+}
@@ -0,0 +1,39 @@
+package tracing
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// InstrumentationName names the tracer of this module. Spans go to the global tracer
+// provider, which the host service configures with otel.SetTracerProvider.
+const InstrumentationName = "github.com/novabankapp/wallet.data"
+
+// Propagator reads and writes W3C trace context and baggage, in request headers as well
+// as in event metadata.
+var Propagator propagation.TextMapPropagator = propagation.NewCompositeTextMapPropagator(
+	propagation.TraceContext{},
+	propagation.Baggage{},
+)
+
+func Tracer() trace.Tracer {
+	return otel.Tracer(InstrumentationName)
+}
+
+// StartSpan starts a span as a child of the span in ctx, if any.
+func StartSpan(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	return Tracer().Start(ctx, name, opts...)
+}
+
+// TraceErr records err on the span and marks the span as failed.
+func TraceErr(span trace.Span, err error) {
+	if err == nil {
+		return
+	}
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
@@ -0,0 +1,351 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/gocql/gocql"
+	"github.com/novabankapp/wallet.data/es/pii"
+	"github.com/novabankapp/wallet.data/integration"
+	"github.com/pkg/errors"
+	"github.com/scylladb/gocqlx/v2"
+)
+
+const (
+	SubscriptionsTable     = "webhook_subscriptions"
+	DeliveriesTable        = "webhook_deliveries"
+	DeliveriesByEventTable = "webhook_deliveries_by_event"
+
+	subscriptionColumns = "id, wallet_id, owner_id, url, secret, event_types, disabled, created_at"
+	deliveryColumns     = "id, subscription_id, event_id, event_type, wallet_id, payload, status, next_attempt_at, attempts, created_at, updated_at"
+	// deliveryBindings binds the columns of deliveryColumns after the id.
+	deliveryBindings = "?, ?, ?, ?, ?, ?, ?, ?, ?, ?"
+)
+
+// CassandraStore keeps subscriptions and deliveries in the tables created by the
+// migrations package, so that a Dispatcher acking an event after CreateDelivery does not
+// lose the delivery when the process stops.
+type CassandraStore struct {
+	session gocqlx.Session
+}
+
+var _ Store = (*CassandraStore)(nil)
+
+func NewCassandraStore(session gocqlx.Session) *CassandraStore {
+	return &CassandraStore{session: session}
+}
+
+func (s *CassandraStore) SaveSubscription(ctx context.Context, subscription Subscription) error {
+	if err := subscription.Validate(); err != nil {
+		return err
+	}
+	stmt := fmt.Sprintf("INSERT INTO %s (%s) VALUES (?, ?, ?, ?, ?, ?, ?, ?)", SubscriptionsTable, subscriptionColumns)
+	err := s.session.Session.Query(stmt,
+		subscription.ID, subscription.WalletID, subscription.OwnerID, subscription.URL, subscription.Secret,
+		subscription.EventTypes, subscription.Disabled, subscription.CreatedAt,
+	).WithContext(ctx).Exec()
+	if err != nil {
+		return errors.Wrap(err, "Query.Exec")
+	}
+	return nil
+}
+
+func (s *CassandraStore) GetSubscription(ctx context.Context, id string) (*Subscription, error) {
+	stmt := fmt.Sprintf("SELECT %s FROM %s WHERE id = ?", subscriptionColumns, SubscriptionsTable)
+	subscriptions, err := s.scanSubscriptions(s.session.Session.Query(stmt, id).WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	if len(subscriptions) == 0 {
+		return nil, ErrSubscriptionNotFound
+	}
+	return &subscriptions[0], nil
+}
+
+func (s *CassandraStore) DeleteSubscription(ctx context.Context, id string) error {
+	stmt := fmt.Sprintf("DELETE FROM %s WHERE id = ? IF EXISTS", SubscriptionsTable)
+	applied, err := s.session.Session.Query(stmt, id).WithContext(ctx).MapScanCAS(map[string]interface{}{})
+	if err != nil {
+		return errors.Wrap(err, "Query.MapScanCAS")
+	}
+	if !applied {
+		return ErrSubscriptionNotFound
+	}
+	return nil
+}
+
+// FindSubscriptions reads the subscriptions of the wallet and of the owner through the
+// wallet_id and owner_id indexes.
+func (s *CassandraStore) FindSubscriptions(ctx context.Context, walletID, ownerID string) ([]Subscription, error) {
+	var result []Subscription
+	for column, value := range map[string]string{"wallet_id": walletID, "owner_id": ownerID} {
+		if value == "" {
+			continue
+		}
+		stmt := fmt.Sprintf("SELECT %s FROM %s WHERE %s = ?", subscriptionColumns, SubscriptionsTable, column)
+		subscriptions, err := s.scanSubscriptions(s.session.Session.Query(stmt, value).WithContext(ctx))
+		if err != nil {
+			return nil, err
+		}
+		for _, subscription := range subscriptions {
+			if !subscription.Disabled {
+				result = append(result, subscription)
+			}
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].ID < result[j].ID })
+	return result, nil
+}
+
+// CreateDelivery claims the (subscription, event) pair with a lightweight transaction
+// before writing the delivery, so a redelivered event is recorded once.
+func (s *CassandraStore) CreateDelivery(ctx context.Context, delivery Delivery) error {
+	return createDelivery(ctx, s, delivery)
+}
+
+// deliveryTables are the reads and writes CreateDelivery makes, kept apart so that its
+// recovery from a write that failed after the claim is tested without Cassandra.
+type deliveryTables interface {
+	claimDelivery(ctx context.Context, delivery Delivery) (string, error)
+	insertDelivery(ctx context.Context, delivery Delivery) error
+	GetDelivery(ctx context.Context, id string) (*Delivery, error)
+}
+
+// createDelivery writes the delivery under the claim of its (subscription, event) pair.
+// When the pair was claimed before, the delivery row of the claim is written again if it
+// is missing, because the write after the claim failed and the event was nacked, and
+// ErrDeliveryExists is returned only once the row is there.
+func createDelivery(ctx context.Context, tables deliveryTables, delivery Delivery) error {
+	claimedID, err := tables.claimDelivery(ctx, delivery)
+	if err != nil {
+		return err
+	}
+	if claimedID == delivery.ID {
+		return tables.insertDelivery(ctx, delivery)
+	}
+	_, err = tables.GetDelivery(ctx, claimedID)
+	if err == nil {
+		return ErrDeliveryExists
+	}
+	if !errors.Is(err, ErrDeliveryNotFound) {
+		return err
+	}
+	delivery.ID = claimedID
+	if err := tables.insertDelivery(ctx, delivery); err != nil {
+		return err
+	}
+	return ErrDeliveryExists
+}
+
+// claimDelivery returns the id of the delivery that holds the claim of the delivery's
+// (subscription, event) pair: its own id when the claim was applied.
+func (s *CassandraStore) claimDelivery(ctx context.Context, delivery Delivery) (string, error) {
+	stmt := fmt.Sprintf("INSERT INTO %s (subscription_id, event_id, delivery_id) VALUES (?, ?, ?) IF NOT EXISTS", DeliveriesByEventTable)
+	existing := map[string]interface{}{}
+	applied, err := s.session.Session.Query(stmt, delivery.SubscriptionID, delivery.EventID, delivery.ID).
+		WithContext(ctx).MapScanCAS(existing)
+	if err != nil {
+		return "", errors.Wrap(err, "Query.MapScanCAS")
+	}
+	if applied {
+		return delivery.ID, nil
+	}
+	claimedID, _ := existing["delivery_id"].(string)
+	return claimedID, nil
+}
+
+func (s *CassandraStore) insertDelivery(ctx context.Context, delivery Delivery) error {
+	values, err := deliveryValues(delivery)
+	if err != nil {
+		return err
+	}
+	stmt := fmt.Sprintf("INSERT INTO %s (%s) VALUES (?, %s)", DeliveriesTable, deliveryColumns, deliveryBindings)
+	if err := s.session.Session.Query(stmt, append([]interface{}{delivery.ID}, values...)...).WithContext(ctx).Exec(); err != nil {
+		return errors.Wrap(err, "Query.Exec")
+	}
+	return nil
+}
+
+func (s *CassandraStore) UpdateDelivery(ctx context.Context, delivery Delivery) error {
+	values, err := deliveryValues(delivery)
+	if err != nil {
+		return err
+	}
+	stmt := fmt.Sprintf("UPDATE %s SET subscription_id = ?, event_id = ?, event_type = ?, wallet_id = ?, payload = ?, "+
+		"status = ?, next_attempt_at = ?, attempts = ?, created_at = ?, updated_at = ? WHERE id = ? IF EXISTS", DeliveriesTable)
+	applied, err := s.session.Session.Query(stmt, append(values, delivery.ID)...).WithContext(ctx).MapScanCAS(map[string]interface{}{})
+	if err != nil {
+		return errors.Wrap(err, "Query.MapScanCAS")
+	}
+	if !applied {
+		return ErrDeliveryNotFound
+	}
+	return nil
+}
+
+// deliveryValues returns the columns of a delivery after its id, in deliveryColumns order.
+func deliveryValues(delivery Delivery) ([]interface{}, error) {
+	attempts, err := json.Marshal(delivery.Attempts)
+	if err != nil {
+		return nil, errors.Wrap(err, "json.Marshal")
+	}
+	return []interface{}{
+		delivery.SubscriptionID, delivery.EventID, delivery.EventType, delivery.WalletID, delivery.Payload,
+		string(delivery.Status), delivery.NextAttemptAt, string(attempts), delivery.CreatedAt, delivery.UpdatedAt,
+	}, nil
+}
+
+func (s *CassandraStore) GetDelivery(ctx context.Context, id string) (*Delivery, error) {
+	stmt := fmt.Sprintf("SELECT %s FROM %s WHERE id = ?", deliveryColumns, DeliveriesTable)
+	deliveries, err := s.scanDeliveries(s.session.Session.Query(stmt, id).WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	if len(deliveries) == 0 {
+		return nil, ErrDeliveryNotFound
+	}
+	return &deliveries[0], nil
+}
+
+// ListDeliveries reads the subscription's deliveries through the subscription_id index.
+func (s *CassandraStore) ListDeliveries(ctx context.Context, subscriptionID string, filter DeliveryFilter) ([]Delivery, error) {
+	stmt := fmt.Sprintf("SELECT %s FROM %s WHERE subscription_id = ?", deliveryColumns, DeliveriesTable)
+	deliveries, err := s.scanDeliveries(s.session.Session.Query(stmt, subscriptionID).WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	var result []Delivery
+	for i := range deliveries {
+		if filter.matches(&deliveries[i]) {
+			result = append(result, deliveries[i])
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if !result[i].CreatedAt.Equal(result[j].CreatedAt) {
+			return result[i].CreatedAt.After(result[j].CreatedAt)
+		}
+		return result[i].ID > result[j].ID
+	})
+	if filter.Limit > 0 && len(result) > filter.Limit {
+		result = result[:filter.Limit]
+	}
+	return result, nil
+}
+
+// ClaimDueDeliveries reads pending deliveries through the status index and leases each
+// with a lightweight transaction on its NextAttemptAt; a delivery another sender leased
+// in the meantime is left out.
+func (s *CassandraStore) ClaimDueDeliveries(ctx context.Context, now time.Time, limit int, lease time.Duration) ([]Delivery, error) {
+	stmt := fmt.Sprintf("SELECT %s FROM %s WHERE status = ?", deliveryColumns, DeliveriesTable)
+	pending, err := s.scanDeliveries(s.session.Session.Query(stmt, string(DeliveryPending)).WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	var due []Delivery
+	for _, delivery := range pending {
+		if !delivery.NextAttemptAt.After(now) {
+			due = append(due, delivery)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool {
+		if !due[i].NextAttemptAt.Equal(due[j].NextAttemptAt) {
+			return due[i].NextAttemptAt.Before(due[j].NextAttemptAt)
+		}
+		return due[i].CreatedAt.Before(due[j].CreatedAt)
+	})
+
+	claimed := make([]Delivery, 0, len(due))
+	stmt = fmt.Sprintf("UPDATE %s SET next_attempt_at = ? WHERE id = ? IF status = ? AND next_attempt_at = ?", DeliveriesTable)
+	for _, delivery := range due {
+		if limit > 0 && len(claimed) == limit {
+			break
+		}
+		leasedUntil := now.Add(lease)
+		applied, err := s.session.Session.Query(stmt, leasedUntil, delivery.ID, string(DeliveryPending), delivery.NextAttemptAt).
+			WithContext(ctx).MapScanCAS(map[string]interface{}{})
+		if err != nil {
+			return nil, errors.Wrap(err, "Query.MapScanCAS")
+		}
+		if applied {
+			delivery.NextAttemptAt = leasedUntil
+			claimed = append(claimed, delivery)
+		}
+	}
+	return claimed, nil
+}
+
+// ErasePersonalData redacts the payloads of the deliveries of the given wallets, read
+// through the wallet_id index, and disables the subscriptions of the erased owner,
+// replacing their owner id.
+func (s *CassandraStore) ErasePersonalData(ctx context.Context, userID string, walletIDs []string) error {
+	for _, walletID := range walletIDs {
+		stmt := fmt.Sprintf("SELECT %s FROM %s WHERE wallet_id = ?", deliveryColumns, DeliveriesTable)
+		deliveries, err := s.scanDeliveries(s.session.Session.Query(stmt, walletID).WithContext(ctx))
+		if err != nil {
+			return err
+		}
+		stmt = fmt.Sprintf("UPDATE %s SET payload = ? WHERE id = ?", DeliveriesTable)
+		for _, delivery := range deliveries {
+			payload, err := integration.RedactPersonalData(delivery.Payload)
+			if err != nil {
+				return errors.Wrapf(err, "delivery %s", delivery.ID)
+			}
+			if err := s.session.Session.Query(stmt, payload, delivery.ID).WithContext(ctx).Exec(); err != nil {
+				return errors.Wrap(err, "Query.Exec")
+			}
+		}
+	}
+
+	stmt := fmt.Sprintf("SELECT %s FROM %s WHERE owner_id = ?", subscriptionColumns, SubscriptionsTable)
+	subscriptions, err := s.scanSubscriptions(s.session.Session.Query(stmt, userID).WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	stmt = fmt.Sprintf("UPDATE %s SET owner_id = ?, disabled = ? WHERE id = ?", SubscriptionsTable)
+	for _, subscription := range subscriptions {
+		if err := s.session.Session.Query(stmt, pii.Redacted, true, subscription.ID).WithContext(ctx).Exec(); err != nil {
+			return errors.Wrap(err, "Query.Exec")
+		}
+	}
+	return nil
+}
+
+func (s *CassandraStore) scanSubscriptions(query *gocql.Query) ([]Subscription, error) {
+	iter := query.Iter()
+	var result []Subscription
+	var row Subscription
+	for iter.Scan(&row.ID, &row.WalletID, &row.OwnerID, &row.URL, &row.Secret, &row.EventTypes, &row.Disabled, &row.CreatedAt) {
+		result = append(result, row)
+		row = Subscription{}
+	}
+	if err := iter.Close(); err != nil {
+		return nil, errors.Wrap(err, "Iter.Close")
+	}
+	return result, nil
+}
+
+func (s *CassandraStore) scanDeliveries(query *gocql.Query) ([]Delivery, error) {
+	iter := query.Iter()
+	var result []Delivery
+	var row Delivery
+	var status, attempts string
+	for iter.Scan(&row.ID, &row.SubscriptionID, &row.EventID, &row.EventType, &row.WalletID, &row.Payload,
+		&status, &row.NextAttemptAt, &attempts, &row.CreatedAt, &row.UpdatedAt) {
+		row.Status = DeliveryStatus(status)
+		if attempts != "" {
+			if err := json.Unmarshal([]byte(attempts), &row.Attempts); err != nil {
+				_ = iter.Close()
+				return nil, errors.Wrapf(err, "delivery %s attempts", row.ID)
+			}
+		}
+		result = append(result, row)
+		row = Delivery{}
+	}
+	if err := iter.Close(); err != nil {
+		return nil, errors.Wrap(err, "Iter.Close")
+	}
+	return result, nil
+}
@@ -0,0 +1,73 @@
+package webhooks
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+// fakeDeliveryTables keeps the claims and delivery rows of createDelivery in maps, and
+// fails the next failInserts delivery writes.
+type fakeDeliveryTables struct {
+	claims      map[string]string
+	rows        map[string]Delivery
+	failInserts int
+}
+
+func (f *fakeDeliveryTables) claimDelivery(ctx context.Context, delivery Delivery) (string, error) {
+	key := delivery.SubscriptionID + "/" + delivery.EventID
+	if id, ok := f.claims[key]; ok {
+		return id, nil
+	}
+	f.claims[key] = delivery.ID
+	return delivery.ID, nil
+}
+
+func (f *fakeDeliveryTables) insertDelivery(ctx context.Context, delivery Delivery) error {
+	if f.failInserts > 0 {
+		f.failInserts--
+		return errors.New("write timeout")
+	}
+	f.rows[delivery.ID] = delivery
+	return nil
+}
+
+func (f *fakeDeliveryTables) GetDelivery(ctx context.Context, id string) (*Delivery, error) {
+	delivery, ok := f.rows[id]
+	if !ok {
+		return nil, ErrDeliveryNotFound
+	}
+	return &delivery, nil
+}
+
+func TestCreateDeliveryRecreatesTheRowOfAClaimWhoseWriteFailed(t *testing.T) {
+	ctx := context.Background()
+	tables := &fakeDeliveryTables{claims: map[string]string{}, rows: map[string]Delivery{}, failInserts: 1}
+
+	first := Delivery{ID: "d-1", SubscriptionID: "sub-1", EventID: "e-1", Status: DeliveryPending}
+	if err := createDelivery(ctx, tables, first); err == nil {
+		t.Fatal("the failed write was not returned")
+	}
+
+	// The dispatcher nacked the event; its redelivery creates a delivery with a new id.
+	redelivered := first
+	redelivered.ID = "d-2"
+	if err := createDelivery(ctx, tables, redelivered); !errors.Is(err, ErrDeliveryExists) {
+		t.Fatalf("got %v, want %v", err, ErrDeliveryExists)
+	}
+	delivery, err := tables.GetDelivery(ctx, "d-1")
+	if err != nil {
+		t.Fatalf("the claimed delivery was not written: %v", err)
+	}
+	if delivery.Status != DeliveryPending || delivery.EventID != "e-1" {
+		t.Fatalf("delivery %+v", delivery)
+	}
+	if len(tables.rows) != 1 {
+		t.Fatalf("rows %v", tables.rows)
+	}
+
+	if err := createDelivery(ctx, tables, redelivered); !errors.Is(err, ErrDeliveryExists) {
+		t.Fatalf("got %v, want %v", err, ErrDeliveryExists)
+	}
+}
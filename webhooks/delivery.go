@@ -0,0 +1,56 @@
+package webhooks
+
+import "time"
+
+type DeliveryStatus string
+
+const (
+	// DeliveryPending deliveries are due at NextAttemptAt, for the first time or again.
+	DeliveryPending   DeliveryStatus = "pending"
+	DeliverySucceeded DeliveryStatus = "succeeded"
+	// DeliveryDead deliveries exhausted their attempts and wait for a manual redelivery.
+	DeliveryDead DeliveryStatus = "dead"
+)
+
+// Delivery is one event to be posted to one subscription, with the log of every attempt.
+type Delivery struct {
+	ID             string         `json:"id"`
+	SubscriptionID string         `json:"subscription_id"`
+	EventID        string         `json:"event_id"`
+	EventType      string         `json:"event_type"`
+	WalletID       string         `json:"wallet_id"`
+	Payload        []byte         `json:"payload"`
+	Status         DeliveryStatus `json:"status"`
+	NextAttemptAt  time.Time      `json:"next_attempt_at"`
+	Attempts       []Attempt      `json:"attempts"`
+	CreatedAt      time.Time      `json:"created_at"`
+	UpdatedAt      time.Time      `json:"updated_at"`
+}
+
+// Attempt is one HTTP call. StatusCode is 0 when no response was received.
+type Attempt struct {
+	At         time.Time     `json:"at"`
+	StatusCode int           `json:"status_code,omitempty"`
+	Error      string        `json:"error,omitempty"`
+	Duration   time.Duration `json:"duration"`
+	// Manual marks the first attempt after a manual redelivery.
+	Manual bool `json:"manual,omitempty"`
+}
+
+func (a Attempt) Succeeded() bool {
+	return a.Error == "" && a.StatusCode >= 200 && a.StatusCode < 300
+}
+
+// DeliveryFilter narrows a delivery log query. Zero values match everything.
+type DeliveryFilter struct {
+	Status DeliveryStatus
+	Since  time.Time
+	Limit  int
+}
+
+func (f DeliveryFilter) matches(d *Delivery) bool {
+	if f.Status != "" && d.Status != f.Status {
+		return false
+	}
+	return f.Since.IsZero() || !d.CreatedAt.Before(f.Since)
+}
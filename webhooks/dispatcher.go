@@ -0,0 +1,190 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/EventStore/EventStore-Client-Go/esdb"
+	"github.com/google/uuid"
+	es "github.com/novabankapp/common.data/eventstore"
+	"github.com/novabankapp/common.data/logger"
+	"github.com/novabankapp/wallet.data/constants"
+	"github.com/novabankapp/wallet.data/es/aggregate"
+	v1 "github.com/novabankapp/wallet.data/es/events/v1"
+	"github.com/novabankapp/wallet.data/es/pii"
+	"github.com/novabankapp/wallet.data/es/queries"
+	"github.com/novabankapp/wallet.data/es/store"
+	"github.com/novabankapp/wallet.data/integration"
+	"github.com/novabankapp/wallet.data/tracing"
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+const DispatcherName = "(Webhook Dispatcher)"
+
+// OwnerResolver returns the id of the user owning a wallet.
+type OwnerResolver interface {
+	WalletOwner(ctx context.Context, walletID string) (string, error)
+}
+
+type readModelOwners struct {
+	queries *queries.WalletTransactionQueries
+}
+
+// NewReadModelOwnerResolver looks owners up in the wallet read model.
+func NewReadModelOwnerResolver(q *queries.WalletTransactionQueries) OwnerResolver {
+	return &readModelOwners{queries: q}
+}
+
+func (r *readModelOwners) WalletOwner(ctx context.Context, walletID string) (string, error) {
+	projection, err := r.queries.GetWalletProjection(ctx, walletID)
+	if err != nil {
+		return "", err
+	}
+	return projection.UserID, nil
+}
+
+// Dispatcher turns wallet events into pending deliveries for every matching subscription.
+type Dispatcher struct {
+	Store     Store
+	Owners    OwnerResolver
+	GroupName string
+	Log       logger.Logger
+	Now       func() time.Time
+	// PersonalData decrypts the personal data fields of events before the owner is read
+	// and the payload is built. When nil events are used as stored.
+	PersonalData *pii.Cipher
+}
+
+func NewDispatcher(store Store, owners OwnerResolver, groupName string, log logger.Logger) *Dispatcher {
+	return &Dispatcher{Store: store, Owners: owners, GroupName: groupName, Log: log, Now: time.Now}
+}
+
+// Run dispatches the events of a persistent subscription. An event is acked once its
+// deliveries are recorded; if recording fails the event is retried by the group. The
+// Store must therefore be durable, such as CassandraStore: an acked event is not
+// dispatched again.
+func (d *Dispatcher) Run(ctx context.Context, stream store.PersistentSubscription, workerID int) error {
+	for {
+		event := stream.Recv()
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+
+		if event.SubscriptionDropped != nil {
+			d.Log.Errorf("(SubscriptionDropped) err: {%v}", event.SubscriptionDropped.Error)
+			return errors.Wrap(event.SubscriptionDropped.Error, "Subscription Dropped")
+		}
+
+		if event.EventAppeared != nil {
+			d.Log.ProjectionEvent(DispatcherName, d.GroupName, event.EventAppeared, workerID)
+
+			if err := d.Dispatch(ctx, es.NewEventFromRecorded(event.EventAppeared.Event)); err != nil {
+				d.Log.Errorf("(Dispatcher.Dispatch) err: {%v}", err)
+				if err := stream.Nack(err.Error(), esdb.Nack_Retry, event.EventAppeared); err != nil {
+					d.Log.Errorf("(stream.Nack) err: {%v}", err)
+					return errors.Wrap(err, "stream.Nack")
+				}
+				continue
+			}
+
+			if err := stream.Ack(event.EventAppeared); err != nil {
+				d.Log.Errorf("(stream.Ack) err: {%v}", err)
+				return errors.Wrap(err, "stream.Ack")
+			}
+		}
+	}
+}
+
+// Dispatch records a pending delivery of evt for each subscription it matches. The
+// payload is the event's integration event, the same document brokers receive.
+func (d *Dispatcher) Dispatch(ctx context.Context, evt es.Event) error {
+	ctx, span := tracing.StartSpan(ctx, "Dispatcher.Dispatch")
+	defer span.End()
+	span.SetAttributes(attribute.String(constants.AggregateID, evt.GetAggregateID()), attribute.String("EventType", evt.GetEventType()))
+
+	if !knownEventTypes[evt.GetEventType()] {
+		return nil
+	}
+	if d.PersonalData != nil {
+		revealed, err := d.PersonalData.Reveal(ctx, evt)
+		if err != nil {
+			tracing.TraceErr(span, err)
+			return errors.Wrap(err, "Reveal")
+		}
+		evt = revealed
+	}
+	walletID := aggregate.GetWalletAggregateID(evt.GetAggregateID())
+	ownerID, err := d.owner(ctx, walletID, evt)
+	if err != nil {
+		tracing.TraceErr(span, err)
+		return errors.Wrap(err, "WalletOwner")
+	}
+	subscriptions, err := d.Store.FindSubscriptions(ctx, walletID, ownerID)
+	if err != nil {
+		tracing.TraceErr(span, err)
+		return errors.Wrap(err, "FindSubscriptions")
+	}
+
+	var payload []byte
+	now := d.Now().UTC()
+	for _, subscription := range subscriptions {
+		if !subscription.Matches(walletID, ownerID, evt.GetEventType()) {
+			continue
+		}
+		if payload == nil {
+			if payload, err = integrationPayload(evt); err != nil {
+				tracing.TraceErr(span, err)
+				return err
+			}
+		}
+		err := d.Store.CreateDelivery(ctx, Delivery{
+			ID:             uuid.New().String(),
+			SubscriptionID: subscription.ID,
+			EventID:        evt.GetEventID(),
+			EventType:      evt.GetEventType(),
+			WalletID:       walletID,
+			Payload:        payload,
+			Status:         DeliveryPending,
+			NextAttemptAt:  now,
+			CreatedAt:      now,
+			UpdatedAt:      now,
+		})
+		if err != nil && !errors.Is(err, ErrDeliveryExists) {
+			tracing.TraceErr(span, err)
+			return errors.Wrap(err, "CreateDelivery")
+		}
+	}
+	return nil
+}
+
+// owner takes the owner from the creation event itself, whose read model row may not
+// exist yet, and asks the resolver for every other event.
+func (d *Dispatcher) owner(ctx context.Context, walletID string, evt es.Event) (string, error) {
+	if evt.GetEventType() == v1.WalletCreated {
+		var data struct{ UserId string }
+		if err := evt.GetJsonData(&data); err != nil {
+			return "", errors.Wrap(err, "GetJsonData")
+		}
+		return data.UserId, nil
+	}
+	if d.Owners == nil {
+		return "", nil
+	}
+	return d.Owners.WalletOwner(ctx, walletID)
+}
+
+func integrationPayload(evt es.Event) ([]byte, error) {
+	integrationEvent, err := integration.Map(evt)
+	if err != nil {
+		return nil, errors.Wrap(err, "integration.Map")
+	}
+	payload, err := json.Marshal(integrationEvent)
+	if err != nil {
+		return nil, errors.Wrap(err, "json.Marshal")
+	}
+	return payload, nil
+}
@@ -0,0 +1,185 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/novabankapp/common.data/logger"
+	"github.com/novabankapp/wallet.data/retry"
+	"github.com/novabankapp/wallet.data/tracing"
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+const (
+	HeaderDeliveryID = "Wallet-Delivery-Id"
+	HeaderEventID    = "Wallet-Event-Id"
+	HeaderEventType  = "Wallet-Event-Type"
+
+	DefaultMaxAttempts  = 10
+	defaultMinBackoff   = 30 * time.Second
+	defaultMaxBackoff   = 6 * time.Hour
+	defaultPollInterval = time.Second
+	defaultBatchSize    = 100
+	defaultLease        = time.Minute
+	defaultTimeout      = 10 * time.Second
+	// maxErrorBody bounds how much of a failed response is kept in the delivery log.
+	maxErrorBody = 512
+)
+
+// Sender posts due deliveries. A delivery succeeds on any 2xx response; anything else is
+// retried after Backoff(attempt) until MaxAttempts, then the delivery is dead.
+type Sender struct {
+	Store        Store
+	Client       *http.Client
+	MaxAttempts  int
+	Backoff      func(attempt int) time.Duration
+	PollInterval time.Duration
+	BatchSize    int
+	Log          logger.Logger
+	Now          func() time.Time
+}
+
+func NewSender(store Store, log logger.Logger) *Sender {
+	return &Sender{
+		Store:        store,
+		Client:       &http.Client{Timeout: defaultTimeout},
+		MaxAttempts:  DefaultMaxAttempts,
+		Backoff:      retry.Exponential(defaultMinBackoff, defaultMaxBackoff),
+		PollInterval: defaultPollInterval,
+		BatchSize:    defaultBatchSize,
+		Log:          log,
+		Now:          time.Now,
+	}
+}
+
+// Run sends due deliveries every PollInterval until the context is done.
+func (s *Sender) Run(ctx context.Context) error {
+	ticker := time.NewTicker(s.PollInterval)
+	defer ticker.Stop()
+	for {
+		if _, err := s.SendDue(ctx); err != nil && ctx.Err() == nil {
+			s.Log.Errorf("(Sender.SendDue) err: {%v}", err)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// SendDue makes one attempt for each due delivery and returns how many it attempted.
+func (s *Sender) SendDue(ctx context.Context) (int, error) {
+	deliveries, err := s.Store.ClaimDueDeliveries(ctx, s.Now().UTC(), s.BatchSize, defaultLease)
+	if err != nil {
+		return 0, errors.Wrap(err, "ClaimDueDeliveries")
+	}
+	for _, delivery := range deliveries {
+		if err := s.attempt(ctx, delivery, false); err != nil {
+			return 0, err
+		}
+	}
+	return len(deliveries), nil
+}
+
+// Redeliver posts a delivery again right away, whatever its status, and records the
+// outcome in its log. A dead delivery that fails again stays dead.
+func (s *Sender) Redeliver(ctx context.Context, deliveryID string) (*Delivery, error) {
+	delivery, err := s.Store.GetDelivery(ctx, deliveryID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.attempt(ctx, *delivery, true); err != nil {
+		return nil, err
+	}
+	return s.Store.GetDelivery(ctx, deliveryID)
+}
+
+func (s *Sender) attempt(ctx context.Context, delivery Delivery, manual bool) error {
+	ctx, span := tracing.StartSpan(ctx, "Sender.attempt")
+	defer span.End()
+	span.SetAttributes(attribute.String("DeliveryID", delivery.ID), attribute.String("SubscriptionID", delivery.SubscriptionID))
+
+	subscription, err := s.Store.GetSubscription(ctx, delivery.SubscriptionID)
+	if err != nil && !errors.Is(err, ErrSubscriptionNotFound) {
+		tracing.TraceErr(span, err)
+		return errors.Wrap(err, "GetSubscription")
+	}
+
+	started := s.Now().UTC()
+	var result Attempt
+	if subscription == nil {
+		result = Attempt{At: started, Error: ErrSubscriptionNotFound.Error()}
+	} else {
+		result = s.post(ctx, subscription, &delivery, started)
+	}
+	result.Manual = manual
+	delivery.Attempts = append(delivery.Attempts, result)
+	delivery.UpdatedAt = s.Now().UTC()
+
+	automatic := countAutomatic(delivery.Attempts)
+	switch {
+	case result.Succeeded():
+		delivery.Status = DeliverySucceeded
+	case subscription == nil || automatic >= s.MaxAttempts:
+		delivery.Status = DeliveryDead
+	default:
+		delivery.Status = DeliveryPending
+		delivery.NextAttemptAt = delivery.UpdatedAt.Add(s.Backoff(automatic))
+	}
+	if !result.Succeeded() {
+		s.Log.Warnf("(Sender.attempt) delivery: {%s} attempt: {%d} status: {%s} err: {%s}", delivery.ID, len(delivery.Attempts), delivery.Status, result.Error)
+	}
+
+	if err := s.Store.UpdateDelivery(ctx, delivery); err != nil {
+		tracing.TraceErr(span, err)
+		return errors.Wrap(err, "UpdateDelivery")
+	}
+	return nil
+}
+
+// countAutomatic counts the attempts that draw on the retry budget; manual redeliveries
+// do not, so redelivering a dead delivery never revives its automatic retries.
+func countAutomatic(attempts []Attempt) int {
+	n := 0
+	for _, a := range attempts {
+		if !a.Manual {
+			n++
+		}
+	}
+	return n
+}
+
+func (s *Sender) post(ctx context.Context, subscription *Subscription, delivery *Delivery, started time.Time) Attempt {
+	result := Attempt{At: started}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, subscription.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderDeliveryID, delivery.ID)
+	req.Header.Set(HeaderEventID, delivery.EventID)
+	req.Header.Set(HeaderEventType, delivery.EventType)
+	req.Header.Set(SignatureHeader, Sign(subscription.Secret, started, delivery.Payload))
+
+	resp, err := s.Client.Do(req)
+	result.Duration = s.Now().UTC().Sub(started)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	defer resp.Body.Close()
+
+	result.StatusCode = resp.StatusCode
+	if !result.Succeeded() {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+		result.Error = fmt.Sprintf("unexpected status %d: %s", resp.StatusCode, bytes.TrimSpace(body))
+	}
+	return result
+}
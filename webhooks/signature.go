@@ -0,0 +1,69 @@
+package webhooks
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// SignatureHeader carries "t=<unix seconds>,v1=<hex HMAC-SHA256>". The HMAC is computed
+// with the subscription secret over "<t>.<body>", so a captured request cannot be
+// replayed with a new timestamp.
+const SignatureHeader = "Wallet-Signature"
+
+var ErrInvalidSignature = errors.New("invalid webhook signature")
+
+func Sign(secret string, timestamp time.Time, body []byte) string {
+	t := strconv.FormatInt(timestamp.Unix(), 10)
+	return fmt.Sprintf("t=%s,v1=%s", t, computeSignature(secret, t, body))
+}
+
+// Verify checks a SignatureHeader value, rejecting timestamps further than tolerance from
+// now. Receivers call it before trusting a callback.
+func Verify(secret, header string, body []byte, tolerance time.Duration, now time.Time) error {
+	var t string
+	var signatures []string
+	for _, part := range strings.Split(header, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			continue
+		}
+		switch key {
+		case "t":
+			t = value
+		case "v1":
+			signatures = append(signatures, value)
+		}
+	}
+	if t == "" || len(signatures) == 0 {
+		return errors.Wrap(ErrInvalidSignature, "malformed header")
+	}
+	unix, err := strconv.ParseInt(t, 10, 64)
+	if err != nil {
+		return errors.Wrap(ErrInvalidSignature, "malformed timestamp")
+	}
+	if age := now.Sub(time.Unix(unix, 0)); age > tolerance || age < -tolerance {
+		return errors.Wrap(ErrInvalidSignature, "timestamp outside tolerance")
+	}
+	expected := computeSignature(secret, t, body)
+	for _, signature := range signatures {
+		if hmac.Equal([]byte(signature), []byte(expected)) {
+			return nil
+		}
+	}
+	return ErrInvalidSignature
+}
+
+func computeSignature(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
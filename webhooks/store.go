@@ -0,0 +1,203 @@
+package webhooks
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/novabankapp/wallet.data/es/pii"
+	"github.com/novabankapp/wallet.data/integration"
+	"github.com/pkg/errors"
+)
+
+type Store interface {
+	SaveSubscription(ctx context.Context, subscription Subscription) error
+	GetSubscription(ctx context.Context, id string) (*Subscription, error)
+	DeleteSubscription(ctx context.Context, id string) error
+	// FindSubscriptions returns the enabled subscriptions for the wallet or its owner.
+	FindSubscriptions(ctx context.Context, walletID, ownerID string) ([]Subscription, error)
+
+	// CreateDelivery returns ErrDeliveryExists when the subscription already has a
+	// delivery for the event, which makes dispatching a redelivered event harmless.
+	CreateDelivery(ctx context.Context, delivery Delivery) error
+	UpdateDelivery(ctx context.Context, delivery Delivery) error
+	GetDelivery(ctx context.Context, id string) (*Delivery, error)
+	// ListDeliveries returns the delivery log of a subscription, newest first.
+	ListDeliveries(ctx context.Context, subscriptionID string, filter DeliveryFilter) ([]Delivery, error)
+	// ClaimDueDeliveries returns up to limit pending deliveries due at now and moves their
+	// NextAttemptAt to now+lease, so concurrent senders do not post them twice.
+	ClaimDueDeliveries(ctx context.Context, now time.Time, limit int, lease time.Duration) ([]Delivery, error)
+}
+
+// MemoryStore is an in-memory Store for tests and local runs. Deliveries recorded in it
+// are lost with the process; use CassandraStore behind a Dispatcher.
+type MemoryStore struct {
+	mu            sync.Mutex
+	subscriptions map[string]Subscription
+	deliveries    map[string]Delivery
+	byEvent       map[string]string
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		subscriptions: make(map[string]Subscription),
+		deliveries:    make(map[string]Delivery),
+		byEvent:       make(map[string]string),
+	}
+}
+
+func (m *MemoryStore) SaveSubscription(ctx context.Context, subscription Subscription) error {
+	if err := subscription.Validate(); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	subscription.EventTypes = append([]string(nil), subscription.EventTypes...)
+	m.subscriptions[subscription.ID] = subscription
+	return nil
+}
+
+func (m *MemoryStore) GetSubscription(ctx context.Context, id string) (*Subscription, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	subscription, ok := m.subscriptions[id]
+	if !ok {
+		return nil, ErrSubscriptionNotFound
+	}
+	return &subscription, nil
+}
+
+func (m *MemoryStore) DeleteSubscription(ctx context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.subscriptions[id]; !ok {
+		return ErrSubscriptionNotFound
+	}
+	delete(m.subscriptions, id)
+	return nil
+}
+
+func (m *MemoryStore) FindSubscriptions(ctx context.Context, walletID, ownerID string) ([]Subscription, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var result []Subscription
+	for _, subscription := range m.subscriptions {
+		if subscription.Disabled {
+			continue
+		}
+		if (subscription.WalletID != "" && subscription.WalletID == walletID) ||
+			(subscription.OwnerID != "" && subscription.OwnerID == ownerID) {
+			result = append(result, subscription)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].ID < result[j].ID })
+	return result, nil
+}
+
+func (m *MemoryStore) CreateDelivery(ctx context.Context, delivery Delivery) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	key := delivery.SubscriptionID + "/" + delivery.EventID
+	if _, ok := m.byEvent[key]; ok {
+		return ErrDeliveryExists
+	}
+	m.byEvent[key] = delivery.ID
+	m.deliveries[delivery.ID] = delivery
+	return nil
+}
+
+func (m *MemoryStore) UpdateDelivery(ctx context.Context, delivery Delivery) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.deliveries[delivery.ID]; !ok {
+		return ErrDeliveryNotFound
+	}
+	m.deliveries[delivery.ID] = delivery
+	return nil
+}
+
+func (m *MemoryStore) GetDelivery(ctx context.Context, id string) (*Delivery, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delivery, ok := m.deliveries[id]
+	if !ok {
+		return nil, ErrDeliveryNotFound
+	}
+	return &delivery, nil
+}
+
+func (m *MemoryStore) ListDeliveries(ctx context.Context, subscriptionID string, filter DeliveryFilter) ([]Delivery, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var result []Delivery
+	for _, delivery := range m.deliveries {
+		if delivery.SubscriptionID == subscriptionID && filter.matches(&delivery) {
+			result = append(result, delivery)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if !result[i].CreatedAt.Equal(result[j].CreatedAt) {
+			return result[i].CreatedAt.After(result[j].CreatedAt)
+		}
+		return result[i].ID > result[j].ID
+	})
+	if filter.Limit > 0 && len(result) > filter.Limit {
+		result = result[:filter.Limit]
+	}
+	return result, nil
+}
+
+func (m *MemoryStore) ClaimDueDeliveries(ctx context.Context, now time.Time, limit int, lease time.Duration) ([]Delivery, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var due []Delivery
+	for _, delivery := range m.deliveries {
+		if delivery.Status == DeliveryPending && !delivery.NextAttemptAt.After(now) {
+			due = append(due, delivery)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool {
+		if !due[i].NextAttemptAt.Equal(due[j].NextAttemptAt) {
+			return due[i].NextAttemptAt.Before(due[j].NextAttemptAt)
+		}
+		return due[i].CreatedAt.Before(due[j].CreatedAt)
+	})
+	if limit > 0 && len(due) > limit {
+		due = due[:limit]
+	}
+	for i := range due {
+		due[i].NextAttemptAt = now.Add(lease)
+		m.deliveries[due[i].ID] = due[i]
+	}
+	return due, nil
+}
+
+// ErasePersonalData redacts the payloads of the deliveries of the given wallets and
+// disables the subscriptions of the erased owner, replacing their owner id.
+func (m *MemoryStore) ErasePersonalData(ctx context.Context, userID string, walletIDs []string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	wallets := make(map[string]bool, len(walletIDs))
+	for _, walletID := range walletIDs {
+		wallets[walletID] = true
+	}
+	for id, delivery := range m.deliveries {
+		if !wallets[delivery.WalletID] {
+			continue
+		}
+		payload, err := integration.RedactPersonalData(delivery.Payload)
+		if err != nil {
+			return errors.Wrapf(err, "delivery %s", id)
+		}
+		delivery.Payload = payload
+		m.deliveries[id] = delivery
+	}
+	for id, subscription := range m.subscriptions {
+		if subscription.OwnerID == userID {
+			subscription.OwnerID, subscription.Disabled = pii.Redacted, true
+			m.subscriptions[id] = subscription
+		}
+	}
+	return nil
+}
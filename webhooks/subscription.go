@@ -0,0 +1,95 @@
+// Package webhooks delivers wallet events to merchants as signed HTTP callbacks.
+//
+// A Dispatcher follows the event store and records a Delivery for every subscription
+// an event matches; a Sender posts due deliveries, retries failures with backoff and
+// dead-letters them after MaxAttempts. Dead deliveries can be redelivered by hand, and
+// every attempt is kept in the delivery log.
+package webhooks
+
+import (
+	"net/url"
+	"time"
+
+	v1 "github.com/novabankapp/wallet.data/es/events/v1"
+	"github.com/pkg/errors"
+)
+
+var (
+	ErrSubscriptionNotFound = errors.New("webhook subscription not found")
+	ErrDeliveryNotFound     = errors.New("webhook delivery not found")
+	ErrDeliveryExists       = errors.New("webhook delivery already exists")
+	ErrInvalidSubscription  = errors.New("invalid webhook subscription")
+)
+
+var knownEventTypes = map[string]bool{
+	v1.WalletCreated:             true,
+	v1.WalletCredited:            true,
+	v1.WalletDebited:             true,
+	v1.WalletCreditReserved:      true,
+	v1.WalletCreditReleased:      true,
+	v1.WalletQuarantineConfirmed: true,
+	v1.WalletLocked:              true,
+	v1.WalletUnlocked:            true,
+	v1.WalletBlacklisted:         true,
+	v1.WalletUnBlacklisted:       true,
+	v1.WalletDeleted:             true,
+}
+
+// Subscription asks for callbacks to URL for the events of one wallet (WalletID) or of
+// every wallet of one owner (OwnerID). EventTypes are es/events/v1 event types; an
+// empty list means all of them.
+type Subscription struct {
+	ID         string    `json:"id"`
+	WalletID   string    `json:"wallet_id,omitempty"`
+	OwnerID    string    `json:"owner_id,omitempty"`
+	URL        string    `json:"url"`
+	Secret     string    `json:"-"`
+	EventTypes []string  `json:"event_types,omitempty"`
+	Disabled   bool      `json:"disabled"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+func (s *Subscription) Validate() error {
+	if s.ID == "" {
+		return errors.Wrap(ErrInvalidSubscription, "id is required")
+	}
+	if (s.WalletID == "") == (s.OwnerID == "") {
+		return errors.Wrap(ErrInvalidSubscription, "exactly one of wallet id and owner id is required")
+	}
+	if s.Secret == "" {
+		return errors.Wrap(ErrInvalidSubscription, "secret is required")
+	}
+	u, err := url.Parse(s.URL)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return errors.Wrapf(ErrInvalidSubscription, "invalid url %q", s.URL)
+	}
+	for _, eventType := range s.EventTypes {
+		if !knownEventTypes[eventType] {
+			return errors.Wrapf(ErrInvalidSubscription, "unknown event type %q", eventType)
+		}
+	}
+	return nil
+}
+
+// Matches reports whether an event of eventType on the given wallet, owned by ownerID,
+// is to be delivered to the subscription.
+func (s *Subscription) Matches(walletID, ownerID, eventType string) bool {
+	if s.Disabled {
+		return false
+	}
+	if s.WalletID != "" && s.WalletID != walletID {
+		return false
+	}
+	if s.OwnerID != "" && s.OwnerID != ownerID {
+		return false
+	}
+	if len(s.EventTypes) == 0 {
+		return true
+	}
+	for _, t := range s.EventTypes {
+		if t == eventType {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,295 @@
+package webhooks_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	es "github.com/novabankapp/common.data/eventstore"
+	"github.com/novabankapp/wallet.data/es/aggregate"
+	v1 "github.com/novabankapp/wallet.data/es/events/v1"
+	"github.com/novabankapp/wallet.data/es/pii"
+	"github.com/novabankapp/wallet.data/es/readmodel/readmodeltest"
+	"github.com/novabankapp/wallet.data/integration"
+	"github.com/novabankapp/wallet.data/retry"
+	"github.com/novabankapp/wallet.data/webhooks"
+	"github.com/shopspring/decimal"
+)
+
+const secret = "whsec_test"
+
+type owners map[string]string
+
+func (o owners) WalletOwner(ctx context.Context, walletID string) (string, error) {
+	return o[walletID], nil
+}
+
+type clock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *clock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+type receiver struct {
+	mu       sync.Mutex
+	status   int
+	requests []*http.Request
+	bodies   [][]byte
+}
+
+func (r *receiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, _ := io.ReadAll(req.Body)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.requests = append(r.requests, req)
+	r.bodies = append(r.bodies, body)
+	w.WriteHeader(r.status)
+}
+
+func (r *receiver) setStatus(status int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.status = status
+}
+
+type fixture struct {
+	store      *webhooks.MemoryStore
+	dispatcher *webhooks.Dispatcher
+	sender     *webhooks.Sender
+	receiver   *receiver
+	server     *httptest.Server
+	clock      *clock
+}
+
+func newFixture(t *testing.T) *fixture {
+	t.Helper()
+	f := &fixture{
+		store:    webhooks.NewMemoryStore(),
+		receiver: &receiver{status: http.StatusOK},
+		clock:    &clock{now: time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)},
+	}
+	f.server = httptest.NewServer(f.receiver)
+	t.Cleanup(f.server.Close)
+
+	f.dispatcher = webhooks.NewDispatcher(f.store, owners{"w-1": "user-1", "w-2": "user-1", "w-3": "user-2"}, "webhooks", readmodeltest.NopLogger{})
+	f.dispatcher.Now = f.clock.Now
+	f.sender = webhooks.NewSender(f.store, readmodeltest.NopLogger{})
+	f.sender.Now = f.clock.Now
+	f.sender.Client = f.server.Client()
+	f.sender.MaxAttempts = 3
+	f.sender.Backoff = retry.Exponential(time.Minute, time.Hour)
+	return f
+}
+
+func (f *fixture) subscribe(t *testing.T, subscription webhooks.Subscription) {
+	t.Helper()
+	subscription.URL = f.server.URL
+	subscription.Secret = secret
+	if err := f.store.SaveSubscription(context.Background(), subscription); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// walletEvents creates a wallet owned by ownerID, credits and debits it, and returns
+// the raised events.
+func walletEvents(t *testing.T, walletID, ownerID string) []es.Event {
+	t.Helper()
+	ctx := context.Background()
+	wallet := aggregate.NewWalletAggregateWithID(walletID)
+	if err := wallet.CreateWallet(ctx, decimal.NewFromInt(100), "opening", ownerID, "account-1", walletID); err != nil {
+		t.Fatal(err)
+	}
+	if err := wallet.CreditWallet(ctx, "w-x", decimal.NewFromInt(25), "top up"); err != nil {
+		t.Fatal(err)
+	}
+	if err := wallet.DebitWallet(ctx, "w-y", decimal.NewFromInt(10), "payment"); err != nil {
+		t.Fatal(err)
+	}
+	return wallet.GetUncommittedEvents()
+}
+
+func (f *fixture) dispatch(t *testing.T, events ...es.Event) {
+	t.Helper()
+	for _, evt := range events {
+		if err := f.dispatcher.Dispatch(context.Background(), evt); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func (f *fixture) log(t *testing.T, subscriptionID string, filter webhooks.DeliveryFilter) []webhooks.Delivery {
+	t.Helper()
+	deliveries, err := f.store.ListDeliveries(context.Background(), subscriptionID, filter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return deliveries
+}
+
+func TestDeliversSignedCallbacksForSubscribedEventTypes(t *testing.T) {
+	f := newFixture(t)
+	f.subscribe(t, webhooks.Subscription{ID: "sub-1", WalletID: "w-1", EventTypes: []string{v1.WalletCredited}})
+	f.dispatch(t, walletEvents(t, "w-1", "user-1")...)
+
+	sent, err := f.sender.SendDue(context.Background())
+	if err != nil || sent != 1 {
+		t.Fatalf("sent %d, err %v", sent, err)
+	}
+	if len(f.receiver.requests) != 1 {
+		t.Fatalf("received %d callbacks", len(f.receiver.requests))
+	}
+	req, body := f.receiver.requests[0], f.receiver.bodies[0]
+	if err := webhooks.Verify(secret, req.Header.Get(webhooks.SignatureHeader), body, 5*time.Minute, f.clock.Now()); err != nil {
+		t.Errorf("signature: %v", err)
+	}
+	if err := webhooks.Verify("other", req.Header.Get(webhooks.SignatureHeader), body, 5*time.Minute, f.clock.Now()); err == nil {
+		t.Error("signature verified with the wrong secret")
+	}
+	if req.Header.Get(webhooks.HeaderEventType) != v1.WalletCredited {
+		t.Errorf("event type header %q", req.Header.Get(webhooks.HeaderEventType))
+	}
+	var evt integration.Event
+	if err := json.Unmarshal(body, &evt); err != nil || evt.Type != integration.WalletCredited || evt.WalletID != "w-1" {
+		t.Errorf("payload %s, err %v", body, err)
+	}
+
+	log := f.log(t, "sub-1", webhooks.DeliveryFilter{})
+	if len(log) != 1 || log[0].Status != webhooks.DeliverySucceeded || len(log[0].Attempts) != 1 || log[0].Attempts[0].StatusCode != http.StatusOK {
+		t.Errorf("delivery log %+v", log)
+	}
+}
+
+func TestOwnerSubscriptionsCoverEveryWalletOfTheOwner(t *testing.T) {
+	f := newFixture(t)
+	f.subscribe(t, webhooks.Subscription{ID: "sub-owner", OwnerID: "user-1", EventTypes: []string{v1.WalletCreated, v1.WalletDebited}})
+	f.dispatch(t, walletEvents(t, "w-1", "user-1")...)
+	f.dispatch(t, walletEvents(t, "w-2", "user-1")...)
+	f.dispatch(t, walletEvents(t, "w-3", "user-2")...)
+
+	log := f.log(t, "sub-owner", webhooks.DeliveryFilter{})
+	wallets := map[string]int{}
+	for _, delivery := range log {
+		wallets[delivery.WalletID]++
+	}
+	if len(log) != 4 || wallets["w-1"] != 2 || wallets["w-2"] != 2 {
+		t.Errorf("deliveries per wallet %v", wallets)
+	}
+}
+
+func TestDispatchRevealsEncryptedEvents(t *testing.T) {
+	f := newFixture(t)
+	cipher := pii.NewCipher(pii.NewMemoryKeyStore())
+	f.dispatcher.PersonalData = cipher
+	// w-4 is unknown to the owner resolver, so only its creation event, whose owner is
+	// read from the payload, matches the owner subscription.
+	f.subscribe(t, webhooks.Subscription{ID: "sub-owner", OwnerID: "user-1", EventTypes: []string{v1.WalletCreated, v1.WalletCredited}})
+	ctx := context.Background()
+	for _, evt := range walletEvents(t, "w-4", "user-1") {
+		protected, err := cipher.Protect(ctx, "user-1", evt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.dispatch(t, protected)
+	}
+
+	log := f.log(t, "sub-owner", webhooks.DeliveryFilter{})
+	if len(log) != 1 || log[0].EventType != v1.WalletCreated {
+		t.Fatalf("delivery log %+v", log)
+	}
+	var evt integration.Event
+	if err := json.Unmarshal(log[0].Payload, &evt); err != nil {
+		t.Fatal(err)
+	}
+	var created integration.WalletCreatedV1
+	if err := json.Unmarshal(evt.Data, &created); err != nil {
+		t.Fatal(err)
+	}
+	if created.UserID != "user-1" || created.AccountID != "account-1" {
+		t.Errorf("payload carries %+v, want plain values", created)
+	}
+}
+
+func TestRedispatchedEventsAreDeliveredOnce(t *testing.T) {
+	f := newFixture(t)
+	f.subscribe(t, webhooks.Subscription{ID: "sub-1", WalletID: "w-1"})
+	events := walletEvents(t, "w-1", "user-1")
+	f.dispatch(t, events...)
+	f.dispatch(t, events...)
+
+	if log := f.log(t, "sub-1", webhooks.DeliveryFilter{}); len(log) != len(events) {
+		t.Errorf("%d deliveries for %d events", len(log), len(events))
+	}
+}
+
+func TestFailedDeliveriesBackOffThenDeadLetterAndCanBeRedelivered(t *testing.T) {
+	ctx := context.Background()
+	f := newFixture(t)
+	f.subscribe(t, webhooks.Subscription{ID: "sub-1", WalletID: "w-1", EventTypes: []string{v1.WalletDebited}})
+	f.dispatch(t, walletEvents(t, "w-1", "user-1")...)
+	f.receiver.setStatus(http.StatusServiceUnavailable)
+
+	for i, wait := range []time.Duration{time.Minute, 2 * time.Minute} {
+		if sent, err := f.sender.SendDue(ctx); err != nil || sent != 1 {
+			t.Fatalf("attempt %d: sent %d, err %v", i+1, sent, err)
+		}
+		f.clock.Advance(wait - time.Second)
+		if sent, _ := f.sender.SendDue(ctx); sent != 0 {
+			t.Fatalf("attempt %d retried before its backoff", i+1)
+		}
+		f.clock.Advance(time.Second)
+	}
+	if sent, err := f.sender.SendDue(ctx); err != nil || sent != 1 {
+		t.Fatalf("attempt 3: sent %d, err %v", sent, err)
+	}
+	f.clock.Advance(24 * time.Hour)
+	if sent, _ := f.sender.SendDue(ctx); sent != 0 {
+		t.Fatal("dead delivery retried")
+	}
+
+	dead := f.log(t, "sub-1", webhooks.DeliveryFilter{Status: webhooks.DeliveryDead})
+	if len(dead) != 1 || len(dead[0].Attempts) != 3 || dead[0].Attempts[2].StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("dead letters %+v", dead)
+	}
+
+	f.receiver.setStatus(http.StatusNoContent)
+	delivery, err := f.sender.Redeliver(ctx, dead[0].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if delivery.Status != webhooks.DeliverySucceeded || len(delivery.Attempts) != 4 || !delivery.Attempts[3].Manual {
+		t.Errorf("redelivered %+v", delivery)
+	}
+	if len(f.receiver.requests) != 4 || f.receiver.requests[3].Header.Get(webhooks.HeaderDeliveryID) != delivery.ID {
+		t.Errorf("received %d callbacks", len(f.receiver.requests))
+	}
+}
+
+func TestSubscriptionValidation(t *testing.T) {
+	for name, subscription := range map[string]webhooks.Subscription{
+		"no target":          {ID: "s", URL: "https://example.com/hook", Secret: secret},
+		"wallet and owner":   {ID: "s", WalletID: "w-1", OwnerID: "user-1", URL: "https://example.com/hook", Secret: secret},
+		"no secret":          {ID: "s", WalletID: "w-1", URL: "https://example.com/hook"},
+		"relative url":       {ID: "s", WalletID: "w-1", URL: "/hook", Secret: secret},
+		"unknown event type": {ID: "s", WalletID: "w-1", URL: "https://example.com/hook", Secret: secret, EventTypes: []string{"V1_WALLET_RENAMED"}},
+	} {
+		if err := subscription.Validate(); err == nil {
+			t.Errorf("%s: accepted", name)
+		}
+	}
+}